@@ -6,14 +6,27 @@ php_list() {
     echo "已安装的 PHP 版本:"
     echo "-----------------------------------"
     
+    # /etc/php/<版本>/fpm 表示已安装 FPM，/run/php/php<版本>-fpm.sock 表示正在运行
+    local versions=()
+    local dir socket version
+    for dir in /etc/php/*/fpm; do
+        [ -d "$dir" ] || continue
+        versions+=("$(basename "$(dirname "$dir")")")
+    done
     for socket in /run/php/php*-fpm.sock; do
         [ -S "$socket" ] || continue
         # 只匹配带版本号的 socket (如 php8.3-fpm.sock)
-        local version
         version=$(echo "$socket" | grep -oP "php\K[0-9]+\.[0-9]+")
         [ -z "$version" ] && continue
-        
-        echo "  PHP $version - active"
+        versions+=("$version")
+    done
+    
+    for version in $(printf "%s\n" "${versions[@]}" | sort -u -t. -k1,1nr -k2,2nr); do
+        if [ -S "/run/php/php${version}-fpm.sock" ]; then
+            echo "  PHP $version - active"
+        else
+            echo "  PHP $version - inactive"
+        fi
     done
     
     echo ""
@@ -48,11 +61,21 @@ php_set() {
         return 1
     fi
     
-    # 更新 nginx 配置
+    # 更新 nginx 配置，测试失败时恢复原配置（备份放在配置目录之外，避免被 include 读到）
+    local backup
+    backup=$(mktemp)
+    cp "$nginx_conf" "$backup"
     sed -i "s|fastcgi_pass unix:/run/php/php.*-fpm.sock|fastcgi_pass unix:$socket|g" "$nginx_conf"
     
+    if ! nginx_reload; then
+        cat "$backup" > "$nginx_conf"
+        rm -f "$backup"
+        log_error "配置未生效，已恢复原配置"
+        return 1
+    fi
+    rm -f "$backup"
+    
     log_success "站点 $domain PHP 版本已设置为 $version"
-    log_info "执行 'site nginx reload' 使更改生效"
     return 0
 }
 
@@ -3,22 +3,31 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 type Config struct {
-	DataDir   string
-	BaseDir   string // 网站根目录，用于文件管理器
-	JWTSecret string
-	SiteCLI   string
+	DataDir       string
+	BaseDir       string // 网站根目录，用于文件管理器
+	JWTSecret     string
+	SiteCLI       string
+	MySQLPwdFile  string // MySQL root 密码文件，与 backup_cron.sh 一致
+	ACMEDirectory string // ACME 服务目录地址，测试时可指向 Let's Encrypt 测试环境或 Pebble
+
+	LoginBanThreshold int // 同一 IP 登录失败达到该次数后加入防火墙黑名单，0 表示关闭
 }
 
 func Load() *Config {
 	// 默认配置
 	cfg := &Config{
-		DataDir:   "/opt/site_manager/panel/data",
-		BaseDir:   getEnv("BASE_DIR", "/"),
-		JWTSecret: getEnv("JWT_SECRET", "site_manager_panel_secret_key_change_me"),
-		SiteCLI:   "/usr/local/bin/site-new",
+		DataDir:       "/opt/site_manager/panel/data",
+		BaseDir:       getEnv("BASE_DIR", "/"),
+		JWTSecret:     getEnv("JWT_SECRET", "site_manager_panel_secret_key_change_me"),
+		SiteCLI:       "/usr/local/bin/site-new",
+		MySQLPwdFile:  getEnv("MYSQL_DEFAULTS_FILE", "/www/server/mysql_root.pwd"),
+		ACMEDirectory: getEnv("ACME_DIRECTORY", "https://acme-v02.api.letsencrypt.org/directory"),
+
+		LoginBanThreshold: getEnvInt("LOGIN_BAN_THRESHOLD", 0),
 	}
 
 	// 确保数据目录存在
@@ -34,6 +43,15 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
 func (c *Config) DBPath() string {
 	return filepath.Join(c.DataDir, "panel.db")
 }
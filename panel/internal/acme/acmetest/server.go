@@ -0,0 +1,679 @@
+// Package acmetest 提供类似 Pebble 的本地 ACME 服务端，用于测试 ACME 客户端。
+// 它校验 JWS 签名和 nonce，按 RFC 8555 维护账号、订单、授权和挑战的状态，
+// 并用内置的测试 CA 签发证书。挑战的验证通过 HTTPGet / LookupTXT 回调完成。
+package acmetest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"site_manager_panel/internal/acme"
+)
+
+var b64 = base64.RawURLEncoding
+
+// Server 测试用 ACME 服务端
+type Server struct {
+	*httptest.Server
+
+	// HTTPGet 读取 HTTP-01 验证文件的内容，未设置时 HTTP-01 验证失败
+	HTTPGet func(domain, path string) (string, error)
+	// LookupTXT 查询 TXT 记录，未设置时 DNS-01 验证失败
+	LookupTXT func(fqdn string) ([]string, error)
+	// RetryAfter 轮询订单和授权时返回的 Retry-After 秒数
+	RetryAfter int
+
+	mu         sync.Mutex
+	nextID     int
+	nonces     map[string]bool
+	accounts   map[string]*account // 账号 URL -> 账号
+	orders     map[string]*order
+	authzs     map[string]*authz
+	challenges map[string]*challenge
+	certs      map[string]*issued // 证书 URL -> 证书
+	caKey      *ecdsa.PrivateKey
+	caCert     *x509.Certificate
+	caPEM      []byte
+}
+
+type account struct {
+	url        string
+	thumbprint string
+	key        crypto.PublicKey
+	contact    []string
+}
+
+type order struct {
+	acme.Order
+	account string
+}
+
+type authz struct {
+	acme.Authorization
+	order *order
+}
+
+type challenge struct {
+	acme.Challenge
+	authz *authz
+}
+
+type issued struct {
+	der     []byte
+	chain   []byte
+	account string
+	revoked bool
+}
+
+// NewServer 启动服务端，目录地址为 URL + "/dir"
+func NewServer() *Server {
+	s := &Server{
+		nonces:     map[string]bool{},
+		accounts:   map[string]*account{},
+		orders:     map[string]*order{},
+		authzs:     map[string]*authz{},
+		challenges: map[string]*challenge{},
+		certs:      map[string]*issued{},
+	}
+	s.caKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "acmetest intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &s.caKey.PublicKey, s.caKey)
+	s.caCert, _ = x509.ParseCertificate(der)
+	s.caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dir", s.directory)
+	mux.HandleFunc("/nonce", s.newNonce)
+	mux.HandleFunc("/", s.handlePost)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// DirectoryURL 目录地址
+func (s *Server) DirectoryURL() string {
+	return s.URL + "/dir"
+}
+
+// Issuer 签发证书的 CA 证书
+func (s *Server) Issuer() *x509.Certificate {
+	return s.caCert
+}
+
+// Revoked 证书是否已被吊销
+func (s *Server) Revoked(der []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.certs {
+		if string(c.der) == string(der) {
+			return c.revoked
+		}
+	}
+	return false
+}
+
+// Orders 已创建的订单数量
+func (s *Server) Orders() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.orders)
+}
+
+func (s *Server) id(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s/%s/%d", s.URL, prefix, s.nextID)
+}
+
+func (s *Server) directory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]interface{}{
+		"newNonce":   s.URL + "/nonce",
+		"newAccount": s.URL + "/new-account",
+		"newOrder":   s.URL + "/new-order",
+		"revokeCert": s.URL + "/revoke-cert",
+		"keyChange":  s.URL + "/key-change",
+		"meta":       map[string]string{"termsOfService": s.URL + "/terms"},
+	})
+}
+
+func (s *Server) addNonce(w http.ResponseWriter) {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	nonce := b64.EncodeToString(buf)
+	s.mu.Lock()
+	s.nonces[nonce] = true
+	s.mu.Unlock()
+	w.Header().Set("Replay-Nonce", nonce)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func (s *Server) newNonce(w http.ResponseWriter, r *http.Request) {
+	s.addNonce(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// UseNonce 让一个已发出的 nonce 失效，用于测试 badNonce 重试
+func (s *Server) UseNonce() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for n := range s.nonces {
+		delete(s.nonces, n)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func problem(w http.ResponseWriter, status int, kind, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(acme.Problem{Type: "urn:ietf:params:acme:error:" + kind, Detail: detail, Status: status})
+}
+
+// request 校验通过的请求
+type request struct {
+	url     string
+	account *account         // kid 签名的请求
+	jwk     crypto.PublicKey // jwk 签名的请求
+	payload []byte
+}
+
+// verify 校验 JWS：nonce、url、签名，以及 kid 或 jwk 的使用场合
+func (s *Server) verify(w http.ResponseWriter, r *http.Request) (*request, bool) {
+	if r.Method != http.MethodPost {
+		problem(w, 405, "malformed", "只接受 POST")
+		return nil, false
+	}
+	if r.Header.Get("Content-Type") != "application/jose+json" {
+		problem(w, 415, "malformed", "Content-Type 必须是 application/jose+json")
+		return nil, false
+	}
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		problem(w, 400, "malformed", "无效的 JWS")
+		return nil, false
+	}
+	var jws acme.JWS
+	json.Unmarshal(body, &jws)
+	protected, err := b64.DecodeString(jws.Protected)
+	if err != nil {
+		problem(w, 400, "malformed", "无效的 protected 头部")
+		return nil, false
+	}
+	var header struct {
+		Alg   string    `json:"alg"`
+		Nonce string    `json:"nonce"`
+		URL   string    `json:"url"`
+		JWK   *acme.JWK `json:"jwk"`
+		KID   string    `json:"kid"`
+	}
+	if err := json.Unmarshal(protected, &header); err != nil {
+		problem(w, 400, "malformed", "无效的 protected 头部")
+		return nil, false
+	}
+
+	s.mu.Lock()
+	valid := s.nonces[header.Nonce]
+	delete(s.nonces, header.Nonce)
+	s.mu.Unlock()
+	if !valid {
+		problem(w, 400, "badNonce", "无效的 nonce")
+		return nil, false
+	}
+	if header.URL != s.URL+r.URL.Path {
+		problem(w, 401, "unauthorized", "url 与请求地址不一致")
+		return nil, false
+	}
+	if (header.JWK == nil) == (header.KID == "") {
+		problem(w, 400, "malformed", "jwk 和 kid 必须且只能有一个")
+		return nil, false
+	}
+
+	req := &request{url: header.URL}
+	var pub crypto.PublicKey
+	if header.JWK != nil {
+		if pub, err = header.JWK.PublicKey(); err != nil {
+			problem(w, 400, "badPublicKey", err.Error())
+			return nil, false
+		}
+		req.jwk = pub
+	} else {
+		s.mu.Lock()
+		req.account = s.accounts[header.KID]
+		s.mu.Unlock()
+		if req.account == nil {
+			problem(w, 400, "accountDoesNotExist", "账号不存在")
+			return nil, false
+		}
+		pub = req.account.key
+	}
+	if req.payload, err = acme.Verify(body, pub); err != nil {
+		problem(w, 400, "malformed", err.Error())
+		return nil, false
+	}
+	return req, true
+}
+
+func (s *Server) handlePost(w http.ResponseWriter, r *http.Request) {
+	s.addNonce(w)
+	req, ok := s.verify(w, r)
+	if !ok {
+		return
+	}
+
+	path := r.URL.Path
+	switch {
+	case path == "/new-account":
+		s.newAccount(w, req)
+	case path == "/revoke-cert":
+		s.revokeCert(w, req)
+	case req.account == nil:
+		problem(w, 400, "malformed", "请求必须使用 kid 签名")
+	case path == "/new-order":
+		s.newOrder(w, req)
+	case strings.HasPrefix(path, "/order/"):
+		s.getOrder(w, req)
+	case strings.HasPrefix(path, "/finalize/"):
+		s.finalize(w, req)
+	case strings.HasPrefix(path, "/authz/"):
+		s.getAuthz(w, req)
+	case strings.HasPrefix(path, "/chall/"):
+		s.respondChallenge(w, req)
+	case strings.HasPrefix(path, "/cert/"):
+		s.getCert(w, req)
+	default:
+		problem(w, 404, "malformed", "未知的地址")
+	}
+}
+
+func (s *Server) newAccount(w http.ResponseWriter, req *request) {
+	if req.jwk == nil {
+		problem(w, 400, "malformed", "新建账号必须使用 jwk 签名")
+		return
+	}
+	var payload struct {
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		Contact              []string `json:"contact"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	json.Unmarshal(req.payload, &payload)
+	jwk, _ := acme.NewJWK(req.jwk)
+	thumbprint := jwk.Thumbprint()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.accounts {
+		if a.thumbprint == thumbprint {
+			w.Header().Set("Location", a.url)
+			writeJSON(w, 200, acme.Account{Status: acme.StatusValid, Contact: a.contact})
+			return
+		}
+	}
+	if payload.OnlyReturnExisting {
+		problem(w, 400, "accountDoesNotExist", "账号不存在")
+		return
+	}
+	if !payload.TermsOfServiceAgreed {
+		problem(w, 403, "userActionRequired", "必须同意服务条款")
+		return
+	}
+	for _, c := range payload.Contact {
+		if !strings.HasPrefix(c, "mailto:") || !strings.Contains(c, "@") {
+			problem(w, 400, "invalidContact", "无效的联系方式: "+c)
+			return
+		}
+	}
+	a := &account{url: s.id("account"), thumbprint: thumbprint, key: req.jwk, contact: payload.Contact}
+	s.accounts[a.url] = a
+	w.Header().Set("Location", a.url)
+	writeJSON(w, 201, acme.Account{Status: acme.StatusValid, Contact: a.contact})
+}
+
+func (s *Server) newOrder(w http.ResponseWriter, req *request) {
+	var payload struct {
+		Identifiers []acme.Identifier `json:"identifiers"`
+	}
+	json.Unmarshal(req.payload, &payload)
+	if len(payload.Identifiers) == 0 {
+		problem(w, 400, "malformed", "订单没有域名")
+		return
+	}
+	for _, id := range payload.Identifiers {
+		name := strings.TrimPrefix(id.Value, "*.")
+		if id.Type != "dns" || !strings.Contains(name, ".") || strings.ContainsAny(name, "*_ ") || strings.HasSuffix(name, ".invalid") {
+			problem(w, 400, "rejectedIdentifier", "不允许签发的域名: "+id.Value)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := &order{account: req.account.url}
+	o.URL = s.id("order")
+	o.Status = acme.StatusPending
+	o.Expires = time.Now().Add(7 * 24 * time.Hour).UTC().Truncate(time.Second)
+	o.Identifiers = payload.Identifiers
+	o.Finalize = strings.Replace(o.URL, "/order/", "/finalize/", 1)
+	for _, id := range payload.Identifiers {
+		a := &authz{order: o}
+		a.URL = s.id("authz")
+		a.Status = acme.StatusPending
+		a.Expires = o.Expires
+		a.Identifier = acme.Identifier{Type: "dns", Value: strings.TrimPrefix(id.Value, "*.")}
+		a.Wildcard = strings.HasPrefix(id.Value, "*.")
+		types := []string{acme.ChallengeHTTP01, acme.ChallengeDNS01}
+		if a.Wildcard {
+			types = []string{acme.ChallengeDNS01}
+		}
+		token := make([]byte, 16)
+		rand.Read(token)
+		for _, typ := range types {
+			c := &challenge{authz: a}
+			c.URL = s.id("chall")
+			c.Type = typ
+			c.Status = acme.StatusPending
+			c.Token = b64.EncodeToString(token)
+			s.challenges[c.URL] = c
+			a.Challenges = append(a.Challenges, c.Challenge)
+		}
+		s.authzs[a.URL] = a
+		o.Authorizations = append(o.Authorizations, a.URL)
+	}
+	s.orders[o.URL] = o
+	w.Header().Set("Location", o.URL)
+	writeJSON(w, 201, o.Order)
+}
+
+func (s *Server) retryAfter(w http.ResponseWriter) {
+	if s.RetryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(s.RetryAfter))
+	}
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, req *request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.orders[req.url]
+	if o == nil || o.account != req.account.url {
+		problem(w, 404, "malformed", "订单不存在")
+		return
+	}
+	s.retryAfter(w)
+	writeJSON(w, 200, o.Order)
+}
+
+// snapshot 授权的当前视图，挑战状态以 challenges 中的为准
+func (s *Server) snapshot(a *authz) acme.Authorization {
+	view := a.Authorization
+	view.Challenges = nil
+	for _, c := range a.Challenges {
+		view.Challenges = append(view.Challenges, s.challenges[c.URL].Challenge)
+	}
+	return view
+}
+
+func (s *Server) getAuthz(w http.ResponseWriter, req *request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.authzs[req.url]
+	if a == nil || a.order.account != req.account.url {
+		problem(w, 404, "malformed", "授权不存在")
+		return
+	}
+	s.retryAfter(w)
+	writeJSON(w, 200, s.snapshot(a))
+}
+
+func (s *Server) respondChallenge(w http.ResponseWriter, req *request) {
+	s.mu.Lock()
+	c := s.challenges[req.url]
+	if c == nil || c.authz.order.account != req.account.url {
+		s.mu.Unlock()
+		problem(w, 404, "malformed", "挑战不存在")
+		return
+	}
+	if c.Status != acme.StatusPending || c.authz.Status != acme.StatusPending {
+		view := c.Challenge
+		s.mu.Unlock()
+		writeJSON(w, 200, view)
+		return
+	}
+	c.Status = acme.StatusProcessing
+	view := c.Challenge
+	keyAuth := c.Token + "." + req.account.thumbprint
+	s.mu.Unlock()
+
+	// 与 Pebble 一样异步验证，客户端需要轮询授权状态
+	go s.validate(c, keyAuth)
+	writeJSON(w, 200, view)
+}
+
+func (s *Server) validate(c *challenge, keyAuth string) {
+	domain := c.authz.Identifier.Value
+	var err error
+	switch c.Type {
+	case acme.ChallengeHTTP01:
+		err = s.checkHTTP(domain, c.Token, keyAuth)
+	case acme.ChallengeDNS01:
+		err = s.checkDNS(domain, keyAuth)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := c.authz
+	if err != nil {
+		c.Status = acme.StatusInvalid
+		kind := "incorrectResponse"
+		if e, ok := err.(*kindError); ok {
+			kind = e.kind
+		}
+		c.Error = &acme.Problem{Type: "urn:ietf:params:acme:error:" + kind, Detail: err.Error(), Status: 403}
+		a.Status = acme.StatusInvalid
+		a.order.Status = acme.StatusInvalid
+		a.order.Error = &acme.Problem{
+			Type:        "urn:ietf:params:acme:error:unauthorized",
+			Detail:      "授权失败",
+			Status:      403,
+			Subproblems: []acme.Problem{{Type: c.Error.Type, Detail: c.Error.Detail, Identifier: &a.Identifier}},
+		}
+		return
+	}
+	c.Status = acme.StatusValid
+	c.Validated = time.Now().UTC().Truncate(time.Second)
+	a.Status = acme.StatusValid
+	for _, url := range a.order.Authorizations {
+		if s.authzs[url].Status != acme.StatusValid {
+			return
+		}
+	}
+	a.order.Status = acme.StatusReady
+}
+
+type kindError struct {
+	kind string
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (s *Server) checkHTTP(domain, token, keyAuth string) error {
+	if s.HTTPGet == nil {
+		return &kindError{"connection", "无法连接 " + domain}
+	}
+	body, err := s.HTTPGet(domain, acme.HTTP01Path(token))
+	if err != nil {
+		return &kindError{"connection", err.Error()}
+	}
+	if strings.TrimSpace(body) != keyAuth {
+		return &kindError{"unauthorized", fmt.Sprintf("%s 的验证文件内容不正确: %q", domain, body)}
+	}
+	return nil
+}
+
+func (s *Server) checkDNS(domain, keyAuth string) error {
+	fqdn := "_acme-challenge." + domain
+	if s.LookupTXT == nil {
+		return &kindError{"dns", "无法查询 " + fqdn}
+	}
+	records, err := s.LookupTXT(fqdn)
+	if err != nil {
+		return &kindError{"dns", err.Error()}
+	}
+	want := acme.DNS01Value(keyAuth)
+	for _, r := range records {
+		if r == want {
+			return nil
+		}
+	}
+	return &kindError{"unauthorized", fmt.Sprintf("%s 没有正确的 TXT 记录", fqdn)}
+}
+
+func (s *Server) finalize(w http.ResponseWriter, req *request) {
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	json.Unmarshal(req.payload, &payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.orders[strings.Replace(req.url, "/finalize/", "/order/", 1)]
+	if o == nil || o.account != req.account.url {
+		problem(w, 404, "malformed", "订单不存在")
+		return
+	}
+	if o.Status != acme.StatusReady {
+		problem(w, 403, "orderNotReady", "订单状态为 "+o.Status)
+		return
+	}
+
+	der, err := b64.DecodeString(payload.CSR)
+	if err != nil {
+		problem(w, 400, "badCSR", "无法解码 CSR")
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err == nil {
+		err = csr.CheckSignature()
+	}
+	if err != nil {
+		problem(w, 400, "badCSR", "CSR 无效: "+err.Error())
+		return
+	}
+	want := []string{}
+	for _, id := range o.Identifiers {
+		want = append(want, id.Value)
+	}
+	got := append([]string{}, csr.DNSNames...)
+	sort.Strings(want)
+	sort.Strings(got)
+	if strings.Join(want, ",") != strings.Join(got, ",") {
+		problem(w, 400, "badCSR", "CSR 中的域名与订单不一致")
+		return
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, tmpl, s.caCert, csr.PublicKey, s.caKey)
+	if err != nil {
+		problem(w, 500, "serverInternal", err.Error())
+		return
+	}
+	chain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf}), s.caPEM...)
+	certURL := strings.Replace(o.URL, "/order/", "/cert/", 1)
+	s.certs[certURL] = &issued{der: leaf, chain: chain, account: o.account}
+	o.Status = acme.StatusValid
+	o.Certificate = certURL
+	writeJSON(w, 200, o.Order)
+}
+
+func (s *Server) getCert(w http.ResponseWriter, req *request) {
+	s.mu.Lock()
+	c := s.certs[req.url]
+	s.mu.Unlock()
+	if c == nil || c.account != req.account.url {
+		problem(w, 404, "malformed", "证书不存在")
+		return
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Write(c.chain)
+}
+
+func (s *Server) revokeCert(w http.ResponseWriter, req *request) {
+	var payload struct {
+		Certificate string `json:"certificate"`
+		Reason      int    `json:"reason"`
+	}
+	json.Unmarshal(req.payload, &payload)
+	der, err := b64.DecodeString(payload.Certificate)
+	if err != nil {
+		problem(w, 400, "malformed", "无法解码证书")
+		return
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		problem(w, 400, "malformed", "无效的证书")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var target *issued
+	for _, c := range s.certs {
+		if string(c.der) == string(der) {
+			target = c
+		}
+	}
+	if target == nil {
+		problem(w, 404, "malformed", "证书不是本服务签发的")
+		return
+	}
+	// 签发账号或证书私钥本身有权吊销
+	authorized := req.account != nil && req.account.url == target.account
+	if req.jwk != nil {
+		a, _ := acme.NewJWK(req.jwk)
+		b, _ := acme.NewJWK(cert.PublicKey)
+		authorized = a != nil && b != nil && a.Thumbprint() == b.Thumbprint()
+	}
+	if !authorized {
+		problem(w, 403, "unauthorized", "无权吊销该证书")
+		return
+	}
+	if target.revoked {
+		problem(w, 400, "alreadyRevoked", "证书已吊销")
+		return
+	}
+	target.revoked = true
+	w.WriteHeader(200)
+}
@@ -0,0 +1,356 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxNonceRetries 服务端返回 badNonce 时的重试次数
+const maxNonceRetries = 3
+
+// Client ACME 客户端，一个客户端对应一个账号私钥
+type Client struct {
+	DirectoryURL string
+	Key          crypto.Signer
+	HTTPClient   *http.Client
+	PollInterval time.Duration // 轮询订单、授权状态的间隔；服务端的 Retry-After 优先
+	PollTimeout  time.Duration // 等待单个订单或授权完成的最长时间
+
+	mu     sync.Mutex
+	dir    *Directory
+	kid    string
+	nonces []string
+}
+
+// NewClient 创建客户端
+func NewClient(directoryURL string, key crypto.Signer) *Client {
+	return &Client{
+		DirectoryURL: directoryURL,
+		Key:          key,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		PollInterval: 2 * time.Second,
+		PollTimeout:  2 * time.Minute,
+	}
+}
+
+// Directory 获取并缓存服务目录
+func (c *Client) Directory() (*Directory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dir != nil {
+		return c.dir, nil
+	}
+
+	resp, err := c.HTTPClient.Get(c.DirectoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("acme: 获取目录失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var dir Directory
+	if err := json.NewDecoder(resp.Body).Decode(&dir); err != nil {
+		return nil, fmt.Errorf("acme: 目录格式错误: %w", err)
+	}
+	c.dir = &dir
+	return c.dir, nil
+}
+
+// responseError 将错误响应解析为 Problem，无法解析时保留 HTTP 状态
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	p := &Problem{}
+	if err := json.Unmarshal(data, p); err != nil || p.Type == "" {
+		return &Problem{Type: "about:blank", Detail: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(data)), Status: resp.StatusCode}
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+	return p
+}
+
+func (c *Client) nonce() (string, error) {
+	c.mu.Lock()
+	if n := len(c.nonces); n > 0 {
+		nonce := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		c.mu.Unlock()
+		return nonce, nil
+	}
+	c.mu.Unlock()
+
+	dir, err := c.Directory()
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTPClient.Head(dir.NewNonce)
+	if err != nil {
+		return "", fmt.Errorf("acme: 获取 nonce 失败: %w", err)
+	}
+	resp.Body.Close()
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("acme: 服务端没有返回 nonce")
+	}
+	return nonce, nil
+}
+
+func (c *Client) saveNonce(resp *http.Response) {
+	if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+		c.mu.Lock()
+		c.nonces = append(c.nonces, nonce)
+		c.mu.Unlock()
+	}
+}
+
+// post 发送签名请求；key 不为空时使用 jwk 签名（新建账号、证书私钥吊销），否则使用账号 kid。
+// payload 为 nil 时为 POST-as-GET。返回的响应体已读取完毕
+func (c *Client) post(url string, key crypto.Signer, payload interface{}) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		nonce, err := c.nonce()
+		if err != nil {
+			return nil, nil, err
+		}
+		header := jwsHeader{Nonce: nonce, URL: url}
+		signer := c.Key
+		if key != nil {
+			signer = key
+			if header.JWK, err = NewJWK(key.Public()); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			c.mu.Lock()
+			header.KID = c.kid
+			c.mu.Unlock()
+			if header.KID == "" {
+				return nil, nil, fmt.Errorf("acme: 账号尚未注册")
+			}
+		}
+		body, err := sign(signer, header, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		resp, err := c.HTTPClient.Post(url, "application/jose+json", bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, fmt.Errorf("acme: 请求 %s 失败: %w", url, err)
+		}
+		c.saveNonce(resp)
+		if resp.StatusCode >= 400 {
+			err := responseError(resp)
+			resp.Body.Close()
+			if p, ok := err.(*Problem); ok && p.Kind() == "badNonce" && attempt < maxNonceRetries {
+				continue
+			}
+			return nil, nil, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, data, err
+	}
+}
+
+// Register 注册账号；私钥已注册时服务端返回已有账号
+func (c *Client) Register(email string) (*Account, error) {
+	dir, err := c.Directory()
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]interface{}{"termsOfServiceAgreed": true}
+	if email != "" {
+		req["contact"] = []string{"mailto:" + email}
+	}
+	resp, data, err := c.post(dir.NewAccount, c.Key, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var account Account
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("acme: 账号响应格式错误: %w", err)
+	}
+	account.URL = resp.Header.Get("Location")
+	if account.URL == "" {
+		return nil, fmt.Errorf("acme: 服务端没有返回账号地址")
+	}
+	c.mu.Lock()
+	c.kid = account.URL
+	c.mu.Unlock()
+	return &account, nil
+}
+
+// NewOrder 为域名创建订单
+func (c *Client) NewOrder(domains []string) (*Order, error) {
+	dir, err := c.Directory()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]Identifier, len(domains))
+	for i, d := range domains {
+		ids[i] = Identifier{Type: "dns", Value: d}
+	}
+	resp, data, err := c.post(dir.NewOrder, nil, map[string]interface{}{"identifiers": ids})
+	if err != nil {
+		return nil, err
+	}
+	order, err := decodeOrder(data)
+	if err != nil {
+		return nil, err
+	}
+	order.URL = resp.Header.Get("Location")
+	return order, nil
+}
+
+func decodeOrder(data []byte) (*Order, error) {
+	var order Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("acme: 订单响应格式错误: %w", err)
+	}
+	return &order, nil
+}
+
+// Order 查询订单
+func (c *Client) Order(url string) (*Order, error) {
+	order, _, err := c.order(url)
+	return order, err
+}
+
+func (c *Client) order(url string) (*Order, time.Duration, error) {
+	resp, data, err := c.post(url, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	order, err := decodeOrder(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	order.URL = url
+	return order, c.pollDelay(resp), nil
+}
+
+// Authorization 查询授权
+func (c *Client) Authorization(url string) (*Authorization, error) {
+	authz, _, err := c.authorization(url)
+	return authz, err
+}
+
+func (c *Client) authorization(url string) (*Authorization, time.Duration, error) {
+	resp, data, err := c.post(url, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	var authz Authorization
+	if err := json.Unmarshal(data, &authz); err != nil {
+		return nil, 0, fmt.Errorf("acme: 授权响应格式错误: %w", err)
+	}
+	authz.URL = url
+	return &authz, c.pollDelay(resp), nil
+}
+
+// Accept 通知服务端验证挑战，验证文件或 TXT 记录应已就绪
+func (c *Client) Accept(ch *Challenge) error {
+	_, _, err := c.post(ch.URL, nil, map[string]interface{}{})
+	return err
+}
+
+// WaitAuthorization 轮询授权直到验证完成；验证失败时返回挑战的错误
+func (c *Client) WaitAuthorization(url string) (*Authorization, error) {
+	deadline := time.Now().Add(c.PollTimeout)
+	for {
+		authz, delay, err := c.authorization(url)
+		if err != nil {
+			return nil, err
+		}
+		switch authz.Status {
+		case StatusValid:
+			return authz, nil
+		case StatusInvalid, StatusDeactivated, StatusExpired, StatusRevoked:
+			for _, ch := range authz.Challenges {
+				if ch.Error != nil {
+					if ch.Error.Identifier == nil {
+						ch.Error.Identifier = &authz.Identifier
+					}
+					return authz, ch.Error
+				}
+			}
+			return authz, &Problem{Type: problemPrefix + "unauthorized", Detail: fmt.Sprintf("%s 授权状态为 %s", authz.Domain(), authz.Status)}
+		}
+		if time.Now().After(deadline) {
+			return authz, fmt.Errorf("acme: 等待 %s 验证超时", authz.Domain())
+		}
+		time.Sleep(delay)
+	}
+}
+
+// Finalize 提交证书请求
+func (c *Client) Finalize(order *Order, csr []byte) (*Order, error) {
+	_, data, err := c.post(order.Finalize, nil, map[string]string{"csr": b64.EncodeToString(csr)})
+	if err != nil {
+		return nil, err
+	}
+	finalized, err := decodeOrder(data)
+	if err != nil {
+		return nil, err
+	}
+	finalized.URL = order.URL
+	return finalized, nil
+}
+
+// WaitOrder 轮询订单直到证书签发或失败
+func (c *Client) WaitOrder(url string) (*Order, error) {
+	deadline := time.Now().Add(c.PollTimeout)
+	for {
+		order, delay, err := c.order(url)
+		if err != nil {
+			return nil, err
+		}
+		switch order.Status {
+		case StatusValid:
+			return order, nil
+		case StatusInvalid:
+			if order.Error != nil {
+				return order, order.Error
+			}
+			return order, fmt.Errorf("acme: 订单失败")
+		}
+		if time.Now().After(deadline) {
+			return order, fmt.Errorf("acme: 等待签发超时，订单状态 %s", order.Status)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// Certificate 下载证书链（PEM，叶子证书在前）
+func (c *Client) Certificate(url string) ([]byte, error) {
+	_, data, err := c.post(url, nil, nil)
+	return data, err
+}
+
+// Revoke 吊销证书。certKey 不为空时用证书私钥签名，适用于其他账号签发的证书（如 certbot）
+func (c *Client) Revoke(certDER []byte, certKey crypto.Signer, reason int) error {
+	dir, err := c.Directory()
+	if err != nil {
+		return err
+	}
+	_, _, err = c.post(dir.RevokeCert, certKey, map[string]interface{}{
+		"certificate": b64.EncodeToString(certDER),
+		"reason":      reason,
+	})
+	return err
+}
+
+// pollDelay 下次轮询前的等待时间：服务端给出 Retry-After 时使用它（不超过 PollTimeout），否则为 PollInterval
+func (c *Client) pollDelay(resp *http.Response) time.Duration {
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		return min(time.Duration(s)*time.Second, c.PollTimeout)
+	}
+	return c.PollInterval
+}
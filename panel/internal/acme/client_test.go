@@ -0,0 +1,242 @@
+package acme_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"site_manager_panel/internal/acme"
+	"site_manager_panel/internal/acme/acmetest"
+)
+
+func newClient(t *testing.T, srv *acmetest.Server) *acme.Client {
+	t.Helper()
+	key, err := acme.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := acme.NewClient(srv.DirectoryURL(), key)
+	c.PollInterval = 10 * time.Millisecond
+	c.PollTimeout = 5 * time.Second
+	if _, err := c.Register("admin@example.com"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return c
+}
+
+// obtain 走完整的签发流程，solve 负责放置挑战响应
+func obtain(t *testing.T, c *acme.Client, names []string, typ string, solve func(a *acme.Authorization, keyAuth string)) ([]byte, error) {
+	t.Helper()
+	order, err := c.NewOrder(names)
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range order.Authorizations {
+		authz, err := c.Authorization(url)
+		if err != nil {
+			return nil, err
+		}
+		ch := authz.Challenge(typ)
+		if ch == nil {
+			return nil, fmt.Errorf("no %s challenge for %s", typ, authz.Domain())
+		}
+		keyAuth, _ := acme.KeyAuthorization(c.Key, ch.Token)
+		solve(authz, keyAuth)
+		if err := c.Accept(ch); err != nil {
+			return nil, err
+		}
+		if _, err := c.WaitAuthorization(url); err != nil {
+			return nil, err
+		}
+	}
+	key, _ := acme.GenerateKey()
+	csr, err := acme.NewCSR(key, names)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Finalize(order, csr); err != nil {
+		return nil, err
+	}
+	order, err = c.WaitOrder(order.URL)
+	if err != nil {
+		return nil, err
+	}
+	return c.Certificate(order.Certificate)
+}
+
+func parseChain(t *testing.T, data []byte) []*x509.Certificate {
+	t.Helper()
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs
+}
+
+func TestObtainHTTP01(t *testing.T) {
+	srv := acmetest.NewServer()
+	defer srv.Close()
+	files := sync.Map{}
+	srv.HTTPGet = func(domain, path string) (string, error) {
+		if v, ok := files.Load(domain + path); ok {
+			return v.(string), nil
+		}
+		return "", fmt.Errorf("404")
+	}
+
+	c := newClient(t, srv)
+	chain, err := obtain(t, c, []string{"example.com", "www.example.com"}, acme.ChallengeHTTP01, func(a *acme.Authorization, keyAuth string) {
+		token := a.Challenge(acme.ChallengeHTTP01).Token
+		files.Store(a.Identifier.Value+acme.HTTP01Path(token), keyAuth)
+	})
+	if err != nil {
+		t.Fatalf("obtain failed: %v", err)
+	}
+	certs := parseChain(t, chain)
+	if len(certs) != 2 || certs[0].VerifyHostname("www.example.com") != nil {
+		t.Fatalf("Unexpected chain: %d certs", len(certs))
+	}
+	if err := certs[0].CheckSignatureFrom(srv.Issuer()); err != nil {
+		t.Errorf("Leaf not signed by issuer: %v", err)
+	}
+
+	// 同一私钥再次注册返回同一账号
+	again := acme.NewClient(srv.DirectoryURL(), c.Key)
+	a1, _ := c.Register("admin@example.com")
+	a2, err := again.Register("admin@example.com")
+	if err != nil || a1.URL != a2.URL {
+		t.Errorf("Expected existing account, got %v %v", a2, err)
+	}
+}
+
+func TestObtainDNS01Wildcard(t *testing.T) {
+	srv := acmetest.NewServer()
+	defer srv.Close()
+	srv.RetryAfter = 1
+	records := sync.Map{}
+	srv.LookupTXT = func(fqdn string) ([]string, error) {
+		if v, ok := records.Load(fqdn); ok {
+			return v.([]string), nil
+		}
+		return nil, nil
+	}
+
+	c := newClient(t, srv)
+	var mu sync.Mutex
+	chain, err := obtain(t, c, []string{"*.example.com", "example.com"}, acme.ChallengeDNS01, func(a *acme.Authorization, keyAuth string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fqdn := "_acme-challenge." + a.Identifier.Value
+		prev, _ := records.Load(fqdn)
+		list, _ := prev.([]string)
+		records.Store(fqdn, append(list, acme.DNS01Value(keyAuth)))
+	})
+	if err != nil {
+		t.Fatalf("obtain failed: %v", err)
+	}
+	if certs := parseChain(t, chain); certs[0].VerifyHostname("a.example.com") != nil {
+		t.Errorf("Expected wildcard certificate, got %v", certs[0].DNSNames)
+	}
+}
+
+func TestChallengeFailure(t *testing.T) {
+	srv := acmetest.NewServer()
+	defer srv.Close()
+	srv.HTTPGet = func(domain, path string) (string, error) {
+		return "wrong", nil
+	}
+
+	c := newClient(t, srv)
+	_, err := obtain(t, c, []string{"example.com"}, acme.ChallengeHTTP01, func(*acme.Authorization, string) {})
+	p, ok := err.(*acme.Problem)
+	if !ok {
+		t.Fatalf("Expected Problem, got %T %v", err, err)
+	}
+	if p.Kind() != "unauthorized" || p.Identifier == nil || p.Identifier.Value != "example.com" {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+
+	// 通配符只能使用 DNS-01
+	order, _ := c.NewOrder([]string{"*.example.com"})
+	authz, _ := c.Authorization(order.Authorizations[0])
+	if authz.Challenge(acme.ChallengeHTTP01) != nil || authz.Domain() != "*.example.com" {
+		t.Errorf("Unexpected wildcard authorization: %+v", authz)
+	}
+
+	// 未完成验证的订单不能提交 CSR
+	key, _ := acme.GenerateKey()
+	csr, _ := acme.NewCSR(key, []string{"*.example.com"})
+	if _, err := c.Finalize(order, csr); err == nil || err.(*acme.Problem).Kind() != "orderNotReady" {
+		t.Errorf("Expected orderNotReady, got %v", err)
+	}
+
+	if _, err := c.NewOrder([]string{"localhost"}); err == nil || err.(*acme.Problem).Kind() != "rejectedIdentifier" {
+		t.Errorf("Expected rejectedIdentifier, got %v", err)
+	}
+}
+
+func TestBadNonceRetry(t *testing.T) {
+	srv := acmetest.NewServer()
+	defer srv.Close()
+	c := newClient(t, srv)
+
+	// 客户端缓存的 nonce 失效后应自动重试
+	srv.UseNonce()
+	if _, err := c.NewOrder([]string{"example.com"}); err != nil {
+		t.Fatalf("Expected retry after badNonce, got %v", err)
+	}
+	if srv.Orders() != 1 {
+		t.Errorf("Expected 1 order, got %d", srv.Orders())
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	srv := acmetest.NewServer()
+	defer srv.Close()
+	srv.HTTPGet = func(domain, path string) (string, error) {
+		return "", fmt.Errorf("unreachable")
+	}
+	records := sync.Map{}
+	srv.LookupTXT = func(fqdn string) ([]string, error) {
+		v, _ := records.Load(fqdn)
+		list, _ := v.([]string)
+		return list, nil
+	}
+
+	c := newClient(t, srv)
+	chain, err := obtain(t, c, []string{"example.com"}, acme.ChallengeDNS01, func(a *acme.Authorization, keyAuth string) {
+		records.Store("_acme-challenge."+a.Identifier.Value, []string{acme.DNS01Value(keyAuth)})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := parseChain(t, chain)[0]
+
+	// 其他账号无权吊销
+	other := newClient(t, srv)
+	if err := other.Revoke(leaf.Raw, nil, 0); err == nil {
+		t.Error("Expected other account to be refused")
+	}
+	if err := c.Revoke(leaf.Raw, nil, 0); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if !srv.Revoked(leaf.Raw) {
+		t.Error("Expected certificate revoked")
+	}
+	err = c.Revoke(leaf.Raw, nil, 0)
+	if p, ok := err.(*acme.Problem); !ok || p.Kind() != "alreadyRevoked" {
+		t.Errorf("Expected alreadyRevoked, got %v", err)
+	}
+}
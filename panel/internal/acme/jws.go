@@ -0,0 +1,243 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+var b64 = base64.RawURLEncoding
+
+// GenerateKey 生成 P-256 私钥，用于账号和证书
+func GenerateKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// MarshalKey 将私钥编码为 PEM
+func MarshalKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParseKey 解析 PEM 私钥，支持 PKCS#8、EC 和 PKCS#1（certbot 旧版 RSA 私钥）
+func ParseKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("acme: 无法解析私钥")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("acme: 不支持的私钥格式 %s", block.Type)
+}
+
+// NewCSR 生成包含 names 的证书请求（DER），第一个域名作为 CN
+func NewCSR(key crypto.Signer, names []string) ([]byte, error) {
+	tmpl := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: names[0]},
+		DNSNames: names,
+	}
+	return x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+}
+
+// JWK 公钥的 JSON Web Key 表示（RFC 7517），字段顺序即指纹计算要求的字典序
+type JWK struct {
+	Crv string `json:"crv,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kty string `json:"kty"`
+	N   string `json:"n,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// NewJWK 由公钥生成 JWK
+func NewJWK(pub crypto.PublicKey) (*JWK, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("acme: 只支持 P-256 曲线")
+		}
+		return &JWK{Kty: "EC", Crv: "P-256", X: b64.EncodeToString(pad32(k.X)), Y: b64.EncodeToString(pad32(k.Y))}, nil
+	case *rsa.PublicKey:
+		return &JWK{Kty: "RSA", N: b64.EncodeToString(k.N.Bytes()), E: b64.EncodeToString(big.NewInt(int64(k.E)).Bytes())}, nil
+	}
+	return nil, fmt.Errorf("acme: 不支持的密钥类型 %T", pub)
+}
+
+// PublicKey 由 JWK 还原公钥
+func (j *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("acme: 不支持的曲线 %s", j.Crv)
+		}
+		x, err1 := b64.DecodeString(j.X)
+		y, err2 := b64.DecodeString(j.Y)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("acme: 无效的 JWK")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "RSA":
+		n, err1 := b64.DecodeString(j.N)
+		e, err2 := b64.DecodeString(j.E)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("acme: 无效的 JWK")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("acme: 不支持的密钥类型 %s", j.Kty)
+}
+
+// Thumbprint JWK 指纹（RFC 7638），base64url 编码
+func (j *JWK) Thumbprint() string {
+	data, _ := json.Marshal(j)
+	sum := sha256.Sum256(data)
+	return b64.EncodeToString(sum[:])
+}
+
+func pad32(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) >= 32 {
+		return b
+	}
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+// jwsHeader JWS 受保护头部；新建账号和用证书私钥吊销时使用 jwk，其余请求使用账号 URL（kid）
+type jwsHeader struct {
+	Alg   string `json:"alg"`
+	Nonce string `json:"nonce"`
+	URL   string `json:"url"`
+	JWK   *JWK   `json:"jwk,omitempty"`
+	KID   string `json:"kid,omitempty"`
+}
+
+// JWS Flattened JSON 序列化的 JWS
+type JWS struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+func algorithm(key crypto.Signer) (string, error) {
+	switch key.Public().(type) {
+	case *ecdsa.PublicKey:
+		return "ES256", nil
+	case *rsa.PublicKey:
+		return "RS256", nil
+	}
+	return "", fmt.Errorf("acme: 不支持的密钥类型 %T", key.Public())
+}
+
+// sign 对 payload 签名；payload 为 nil 时是 POST-as-GET，载荷为空字符串
+func sign(key crypto.Signer, header jwsHeader, payload interface{}) ([]byte, error) {
+	alg, err := algorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	header.Alg = alg
+
+	protected, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	jws := JWS{Protected: b64.EncodeToString(protected)}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		jws.Payload = b64.EncodeToString(data)
+	}
+
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	// JWS 的 ES256 签名是定长的 r||s，而不是 ASN.1
+	if ec, ok := key.Public().(*ecdsa.PublicKey); ok {
+		var parsed struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+			return nil, err
+		}
+		size := (ec.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		parsed.R.FillBytes(sig[:size])
+		parsed.S.FillBytes(sig[size:])
+	}
+	jws.Signature = b64.EncodeToString(sig)
+	return json.Marshal(jws)
+}
+
+// Verify 校验 JWS 签名并返回载荷，供 acmetest 服务端使用
+func Verify(data []byte, pub crypto.PublicKey) ([]byte, error) {
+	var jws JWS
+	if err := json.Unmarshal(data, &jws); err != nil {
+		return nil, err
+	}
+	sig, err := b64.DecodeString(jws.Signature)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return nil, fmt.Errorf("acme: 签名长度错误")
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return nil, fmt.Errorf("acme: 签名无效")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, fmt.Errorf("acme: 签名无效")
+		}
+	default:
+		return nil, fmt.Errorf("acme: 不支持的密钥类型 %T", pub)
+	}
+	return b64.DecodeString(jws.Payload)
+}
+
+// KeyAuthorization 挑战的 key authorization：token.账号公钥指纹
+func KeyAuthorization(key crypto.Signer, token string) (string, error) {
+	jwk, err := NewJWK(key.Public())
+	if err != nil {
+		return "", err
+	}
+	return token + "." + jwk.Thumbprint(), nil
+}
+
+// DNS01Value DNS-01 的 TXT 记录值
+func DNS01Value(keyAuth string) string {
+	sum := sha256.Sum256([]byte(keyAuth))
+	return b64.EncodeToString(sum[:])
+}
+
+// HTTP01Path HTTP-01 验证文件相对站点根目录的路径
+func HTTP01Path(token string) string {
+	return "/.well-known/acme-challenge/" + token
+}
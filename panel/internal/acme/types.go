@@ -0,0 +1,136 @@
+package acme
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 订单、授权、挑战的状态（RFC 8555 7.1.6）
+const (
+	StatusPending     = "pending"
+	StatusReady       = "ready"
+	StatusProcessing  = "processing"
+	StatusValid       = "valid"
+	StatusInvalid     = "invalid"
+	StatusDeactivated = "deactivated"
+	StatusExpired     = "expired"
+	StatusRevoked     = "revoked"
+)
+
+// 挑战类型
+const (
+	ChallengeHTTP01 = "http-01"
+	ChallengeDNS01  = "dns-01"
+)
+
+// LetsEncrypt Let's Encrypt 正式环境目录
+const LetsEncrypt = "https://acme-v02.api.letsencrypt.org/directory"
+
+// LetsEncryptStaging Let's Encrypt 测试环境目录
+const LetsEncryptStaging = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+// Directory ACME 服务目录
+type Directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+	RevokeCert string `json:"revokeCert"`
+	KeyChange  string `json:"keyChange"`
+	Meta       struct {
+		TermsOfService string `json:"termsOfService"`
+	} `json:"meta"`
+}
+
+// Account ACME 账号
+type Account struct {
+	URL     string   `json:"url"`
+	Status  string   `json:"status"`
+	Contact []string `json:"contact,omitempty"`
+	Orders  string   `json:"orders,omitempty"`
+}
+
+// Identifier 订单中的域名
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Order 证书订单
+type Order struct {
+	URL            string       `json:"url"`
+	Status         string       `json:"status"`
+	Expires        time.Time    `json:"expires,omitempty"`
+	Identifiers    []Identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+	Error          *Problem     `json:"error,omitempty"`
+}
+
+// Authorization 单个域名的授权
+type Authorization struct {
+	URL        string      `json:"url"`
+	Identifier Identifier  `json:"identifier"`
+	Status     string      `json:"status"`
+	Expires    time.Time   `json:"expires,omitempty"`
+	Challenges []Challenge `json:"challenges"`
+	Wildcard   bool        `json:"wildcard,omitempty"`
+}
+
+// Domain 授权对应的证书域名，通配符授权带上 *.
+func (a *Authorization) Domain() string {
+	if a.Wildcard {
+		return "*." + a.Identifier.Value
+	}
+	return a.Identifier.Value
+}
+
+// Challenge 返回指定类型的挑战
+func (a *Authorization) Challenge(typ string) *Challenge {
+	for i := range a.Challenges {
+		if a.Challenges[i].Type == typ {
+			return &a.Challenges[i]
+		}
+	}
+	return nil
+}
+
+// Challenge 授权的一种验证方式
+type Challenge struct {
+	Type      string    `json:"type"`
+	URL       string    `json:"url"`
+	Status    string    `json:"status"`
+	Token     string    `json:"token"`
+	Validated time.Time `json:"validated,omitempty"`
+	Error     *Problem  `json:"error,omitempty"`
+}
+
+// problemPrefix ACME 错误类型的命名空间
+const problemPrefix = "urn:ietf:params:acme:error:"
+
+// Problem ACME 服务返回的错误（RFC 7807 / RFC 8555 6.7）
+type Problem struct {
+	Type        string      `json:"type"`
+	Detail      string      `json:"detail"`
+	Status      int         `json:"status,omitempty"`
+	Identifier  *Identifier `json:"identifier,omitempty"`
+	Subproblems []Problem   `json:"subproblems,omitempty"`
+}
+
+// Kind 去掉命名空间的错误类型，如 badNonce、unauthorized
+func (p *Problem) Kind() string {
+	return strings.TrimPrefix(p.Type, problemPrefix)
+}
+
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("acme: %s: %s", p.Kind(), p.Detail)
+	for _, sub := range p.Subproblems {
+		if sub.Identifier != nil {
+			msg += fmt.Sprintf("; %s: %s", sub.Identifier.Value, sub.Detail)
+		} else {
+			msg += "; " + sub.Detail
+		}
+	}
+	return msg
+}
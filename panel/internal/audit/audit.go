@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/models"
+)
+
+const (
+	maxValueLen   = 256  // 单个字段最多保留的字符数
+	maxSummaryLen = 4096 // 摘要最大长度
+)
+
+// sensitiveKey 需要脱敏的字段名
+var sensitiveKey = regexp.MustCompile(`(?i)(pass|secret|token|private|credential|recovery|authorization|api_?key|access_?key|^code$)`)
+
+// targetKeys 没有路由参数时，依次从请求体中取这些字段作为操作目标
+var targetKeys = []string{"domain", "path", "name", "target", "username", "file", "source", "id"}
+
+// Record 写入一条审计日志，失败只记录到标准日志，不影响业务
+func Record(entry *models.AuditLog) {
+	if models.DB == nil {
+		return
+	}
+	if err := models.InsertAuditLog(entry); err != nil {
+		log.Printf("[audit] 写入审计日志失败: %v", err)
+	}
+}
+
+// Middleware 记录所有非只读请求；需放在路由注册之前，用户信息在 c.Next() 之后由认证中间件写入
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			}
+		}
+
+		fields := requestFields(c)
+		userID, _ := c.Locals("user_id").(int64)
+		username, _ := c.Locals("username").(string)
+		if username == "" {
+			// 未登录的请求（如登录接口）使用提交的用户名
+			username, _ = fields["username"].(string)
+		}
+
+		Record(&models.AuditLog{
+			UserID:   userID,
+			Username: username,
+			Method:   c.Method(),
+			Route:    c.Route().Path,
+			Path:     c.Path(),
+			Target:   target(c, fields),
+			Summary:  summarize(c, fields),
+			Status:   status,
+			IP:       c.IP(),
+		})
+		return err
+	}
+}
+
+// requestFields 解析请求体为键值对（JSON、表单或 multipart）
+func requestFields(c *fiber.Ctx) map[string]interface{} {
+	fields := map[string]interface{}{}
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationJSON):
+		var body interface{}
+		if json.Unmarshal(c.Body(), &body) == nil {
+			if m, ok := body.(map[string]interface{}); ok {
+				return m
+			}
+			fields["body"] = body
+		}
+	case strings.HasPrefix(contentType, fiber.MIMEMultipartForm):
+		form, err := c.MultipartForm()
+		if err != nil {
+			break
+		}
+		for k, v := range form.Value {
+			fields[k] = strings.Join(v, ",")
+		}
+		for k, files := range form.File {
+			names := []string{}
+			for _, f := range files {
+				names = append(names, fmt.Sprintf("%s (%d bytes)", f.Filename, f.Size))
+			}
+			fields[k] = strings.Join(names, ",")
+		}
+	case strings.HasPrefix(contentType, fiber.MIMEApplicationForm):
+		c.Request().PostArgs().VisitAll(func(k, v []byte) {
+			fields[string(k)] = string(v)
+		})
+	}
+	return fields
+}
+
+// target 优先使用路由参数（如 :domain），否则取请求体中的常见字段
+func target(c *fiber.Ctx, fields map[string]interface{}) string {
+	values := []string{}
+	for _, name := range c.Route().Params {
+		if v := c.Params(name); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) > 0 {
+		return strings.Join(values, "/")
+	}
+
+	for _, k := range targetKeys {
+		if v, ok := fields[k]; ok && v != nil && !sensitiveKey.MatchString(k) {
+			return truncate(fmt.Sprint(v), maxValueLen)
+		}
+	}
+	return ""
+}
+
+// summarize 生成脱敏后的请求摘要（JSON）
+func summarize(c *fiber.Ctx, fields map[string]interface{}) string {
+	summary := map[string]interface{}{}
+
+	query := map[string]interface{}{}
+	c.Request().URI().QueryArgs().VisitAll(func(k, v []byte) {
+		query[string(k)] = string(v)
+	})
+	if len(query) > 0 {
+		summary["query"] = redact(query)
+	}
+	if len(fields) > 0 {
+		summary["body"] = redact(fields)
+	}
+	if len(summary) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return ""
+	}
+	return truncate(string(data), maxSummaryLen)
+}
+
+// redact 递归替换敏感字段并截断过长的值
+func redact(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if sensitiveKey.MatchString(k) {
+				out[k] = "******"
+				continue
+			}
+			out[k] = redact(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = redact(item)
+		}
+		return out
+	case string:
+		return truncate(val, maxValueLen)
+	default:
+		return val
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// 避免截断在 UTF-8 多字节字符中间
+	cut := n
+	for cut > 0 && !isRuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(%d bytes)", s[:cut], len(s))
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/models"
+)
+
+func setupApp(t *testing.T) *fiber.App {
+	t.Helper()
+	if err := models.InitDB(t.TempDir()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	t.Cleanup(func() { models.DB.Close() })
+
+	app := fiber.New()
+	api := app.Group("/api", Middleware())
+	api.Post("/auth/login", func(c *fiber.Ctx) error {
+		return c.Status(401).JSON(fiber.Map{"status": false})
+	})
+
+	// 模拟认证中间件
+	protected := api.Group("", func(c *fiber.Ctx) error {
+		c.Locals("user_id", int64(7))
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	ok := func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"status": true}) }
+	protected.Get("/sites", ok)
+	protected.Post("/sites", ok)
+	protected.Put("/sites/:domain/nginx", ok)
+	protected.Post("/files/upload", ok)
+	protected.Post("/fail", func(c *fiber.Ctx) error { return fiber.ErrForbidden })
+
+	NewAuditHandler().RegisterRoutes(protected)
+	return app
+}
+
+func send(t *testing.T, app *fiber.App, method, path, contentType string, body io.Reader) *httpResponse {
+	t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return &httpResponse{status: resp.StatusCode, body: data, header: resp.Header.Get}
+}
+
+type httpResponse struct {
+	status int
+	body   []byte
+	header func(string) string
+}
+
+func allLogs(t *testing.T) []*models.AuditLog {
+	t.Helper()
+	logs := []*models.AuditLog{}
+	if err := models.QueryAuditLogs(models.AuditFilter{}, func(l *models.AuditLog) error {
+		logs = append(logs, l)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+func TestMiddlewareRecordsMutatingRequests(t *testing.T) {
+	app := setupApp(t)
+
+	send(t, app, "GET", "/api/sites", "", nil)
+	send(t, app, "POST", "/api/sites", "application/json",
+		strings.NewReader(`{"domain":"example.com","db_password":"hunter2","options":{"api_key":"k","php":"8.3"}}`))
+	send(t, app, "PUT", "/api/sites/example.com/nginx?reload=1", "application/json",
+		strings.NewReader(`{"content":"`+strings.Repeat("x", 1000)+`"}`))
+	send(t, app, "POST", "/api/auth/login", "application/json",
+		strings.NewReader(`{"username":"mallory","password":"guess"}`))
+	send(t, app, "POST", "/api/fail", "", nil)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("path", "/www/wwwroot/example.com")
+	fw, _ := mw.CreateFormFile("file", "index.php")
+	fw.Write([]byte("<?php echo 1;"))
+	mw.Close()
+	send(t, app, "POST", "/api/files/upload", mw.FormDataContentType(), &buf)
+
+	logs := allLogs(t)
+	if len(logs) != 5 {
+		t.Fatalf("Expected 5 entries (GET skipped), got %d", len(logs))
+	}
+	byPath := map[string]*models.AuditLog{}
+	for _, l := range logs {
+		byPath[l.Path] = l
+	}
+
+	create := byPath["/api/sites"]
+	if create.Username != "alice" || create.UserID != 7 || create.Target != "example.com" || create.Status != 200 {
+		t.Errorf("Unexpected create entry: %+v", create)
+	}
+	if strings.Contains(create.Summary, "hunter2") || strings.Contains(create.Summary, `"k"`) || !strings.Contains(create.Summary, `"php":"8.3"`) {
+		t.Errorf("Secrets not redacted correctly: %s", create.Summary)
+	}
+
+	nginx := byPath["/api/sites/example.com/nginx"]
+	if nginx.Route != "/api/sites/:domain/nginx" || nginx.Target != "example.com" {
+		t.Errorf("Unexpected route/target: %+v", nginx)
+	}
+	if len(nginx.Summary) > 600 || !strings.Contains(nginx.Summary, "(1000 bytes)") || !strings.Contains(nginx.Summary, `"reload":"1"`) {
+		t.Errorf("Expected truncated content and query in summary: %s", nginx.Summary)
+	}
+
+	login := byPath["/api/auth/login"]
+	if login.Username != "mallory" || login.Status != 401 || strings.Contains(login.Summary, "guess") {
+		t.Errorf("Unexpected login entry: %+v", login)
+	}
+
+	if fail := byPath["/api/fail"]; fail.Status != 403 {
+		t.Errorf("Expected error status 403, got %d", fail.Status)
+	}
+
+	upload := byPath["/api/files/upload"]
+	if upload.Target != "/www/wwwroot/example.com" || !strings.Contains(upload.Summary, "index.php (13 bytes)") || strings.Contains(upload.Summary, "echo") {
+		t.Errorf("Unexpected upload entry: %+v", upload)
+	}
+}
+
+func TestListAndExport(t *testing.T) {
+	app := setupApp(t)
+
+	Record(&models.AuditLog{Username: "bob", Method: "WS", Route: "/ws/terminal", Path: "/ws/terminal", Status: 101})
+	send(t, app, "POST", "/api/sites", "application/json", strings.NewReader(`{"domain":"a.com"}`))
+	send(t, app, "POST", "/api/sites", "application/json", strings.NewReader(`{"domain":"b.com"}`))
+	send(t, app, "POST", "/api/fail", "", nil)
+
+	tests := []struct {
+		query string
+		total int
+	}{
+		{"", 4},
+		{"?username=alice", 3},
+		{"?route=terminal", 1},
+		{"?target=b.com", 1},
+		{"?failed=true", 1},
+		{"?status=200&method=post", 2},
+		{"?q=a.com", 1},
+		{"?from=2000-01-01&to=2000-01-02", 0},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "GET", "/api/audit"+tt.query, "", nil)
+		var result struct {
+			Data struct {
+				Total int                `json:"total"`
+				Items []*models.AuditLog `json:"items"`
+			} `json:"data"`
+		}
+		json.Unmarshal(resp.body, &result)
+		if resp.status != 200 || result.Data.Total != tt.total || len(result.Data.Items) != tt.total {
+			t.Errorf("GET /api/audit%s: status %d, total %d, items %d; want %d", tt.query, resp.status, result.Data.Total, len(result.Data.Items), tt.total)
+		}
+	}
+
+	if resp := send(t, app, "GET", "/api/audit?from=yesterday", "", nil); resp.status != 400 {
+		t.Errorf("Expected 400 for invalid time, got %d", resp.status)
+	}
+
+	resp := send(t, app, "GET", "/api/audit/export?username=alice", "", nil)
+	if !strings.Contains(resp.header("Content-Disposition"), ".csv") {
+		t.Errorf("Expected csv attachment, got %q", resp.header("Content-Disposition"))
+	}
+	records, err := csv.NewReader(bytes.NewReader(resp.body)).ReadAll()
+	if err != nil || len(records) != 4 || records[0][0] != "id" {
+		t.Fatalf("Unexpected CSV export: %v %v", records, err)
+	}
+
+	resp = send(t, app, "GET", "/api/audit/export?format=jsonl", "", nil)
+	lines := strings.Split(strings.TrimSpace(string(resp.body)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 JSONL lines, got %d: %s", len(lines), resp.body)
+	}
+	var first models.AuditLog
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.Path != "/api/fail" {
+		t.Errorf("Unexpected JSONL line: %s (%v)", lines[0], err)
+	}
+
+	if resp := send(t, app, "GET", "/api/audit/export?format=xml", "", nil); resp.status != 400 {
+		t.Errorf("Expected 400 for unsupported format, got %d", resp.status)
+	}
+}
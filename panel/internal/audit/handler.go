@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/models"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct{}
+
+// NewAuditHandler 创建处理器
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+// RegisterRoutes 注册路由
+func (h *AuditHandler) RegisterRoutes(router fiber.Router) {
+	a := router.Group("/audit")
+	a.Get("", h.List)
+	a.Get("/export", h.Export)
+}
+
+// parseTime 支持 RFC3339、日期时间和纯日期
+func parseTime(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的时间: %s", s)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
+
+// parseFilter 从查询参数解析过滤条件
+func parseFilter(c *fiber.Ctx) (models.AuditFilter, error) {
+	f := models.AuditFilter{
+		Username: c.Query("username"),
+		Method:   c.Query("method"),
+		Route:    c.Query("route"),
+		Target:   c.Query("target"),
+		Keyword:  c.Query("q"),
+		Failed:   c.QueryBool("failed"),
+	}
+
+	if s := c.Query("status"); s != "" {
+		status, err := strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("无效的状态码")
+		}
+		f.Status = status
+	}
+
+	var err error
+	if f.From, err = parseTime(c.Query("from"), false); err != nil {
+		return f, err
+	}
+	if f.To, err = parseTime(c.Query("to"), true); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// List 分页查询审计日志
+func (h *AuditHandler) List(c *fiber.Ctx) error {
+	f, err := parseFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	f.Limit = c.QueryInt("limit", 50)
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 50
+	}
+	f.Offset = c.QueryInt("offset", 0)
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	total, err := models.CountAuditLogs(f)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "查询审计日志失败: " + err.Error()})
+	}
+
+	logs := []*models.AuditLog{}
+	err = models.QueryAuditLogs(f, func(l *models.AuditLog) error {
+		logs = append(logs, l)
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "查询审计日志失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"total": total,
+			"items": logs,
+		},
+	})
+}
+
+var csvHeader = []string{"id", "created_at", "user_id", "username", "ip", "method", "route", "path", "target", "status", "summary"}
+
+// Export 导出审计日志，format=csv（默认）或 jsonl
+func (h *AuditHandler) Export(c *fiber.Ctx) error {
+	f, err := parseFilter(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "format 仅支持 csv 或 jsonl"})
+	}
+
+	// 先查询一次确保数据库可用，出错时还能返回 JSON
+	if _, err := models.CountAuditLogs(f); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "查询审计日志失败: " + err.Error()})
+	}
+
+	filename := fmt.Sprintf("audit_%s.%s", time.Now().Format("20060102_150405"), format)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer w.Flush()
+
+		if format == "jsonl" {
+			enc := json.NewEncoder(w)
+			models.QueryAuditLogs(f, func(l *models.AuditLog) error {
+				return enc.Encode(l)
+			})
+			return
+		}
+
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		models.QueryAuditLogs(f, func(l *models.AuditLog) error {
+			return cw.Write([]string{
+				strconv.FormatInt(l.ID, 10),
+				l.CreatedAt.Local().Format(time.RFC3339),
+				strconv.FormatInt(l.UserID, 10),
+				l.Username,
+				l.IP,
+				l.Method,
+				l.Route,
+				l.Path,
+				l.Target,
+				strconv.Itoa(l.Status),
+				l.Summary,
+			})
+		})
+		cw.Flush()
+	})
+	return nil
+}
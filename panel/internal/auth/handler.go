@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -66,6 +67,18 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
+	// 暴力破解防护：IP 或用户名处于退避/锁定期时直接拒绝
+	wait, err := loginRetryAfter(c.IP(), req.Username)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+	if wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+
 	user, err := models.GetUserByUsername(req.Username)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
@@ -75,36 +88,77 @@ func Login(c *fiber.Ctx) error {
 	}
 
 	if user == nil || !user.CheckPassword(req.Password) {
+		recordLoginFailure(c.IP(), req.Username)
 		return c.Status(401).JSON(fiber.Map{
 			"status":  false,
 			"message": "Invalid username or password",
 		})
 	}
 
-	// 生成 JWT
-	expiresAt := time.Now().Add(24 * time.Hour)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"exp":      expiresAt.Unix(),
-	})
+	// 已启用两步验证：返回短期挑战令牌，由 /auth/login/2fa 完成登录
+	if user.TOTPEnabled {
+		challenge, expiresAt, err := issueChallenge(user)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  false,
+				"message": "Failed to generate token",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data": TwoFactorChallenge{
+				TwoFactorRequired: true,
+				ChallengeToken:    challenge,
+				ExpiresAt:         expiresAt.Unix(),
+			},
+		})
+	}
 
-	tokenString, err := token.SignedString(jwtSecret)
+	resp, err := issueToken(c, user)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  false,
 			"message": "Failed to generate token",
 		})
 	}
+	clearLoginFailures(c.IP(), user.Username)
 
 	return c.JSON(fiber.Map{
 		"status": true,
-		"data": LoginResponse{
-			Token:     tokenString,
-			ExpiresAt: expiresAt.Unix(),
-			User:      user,
-		},
+		"data":   resp,
+	})
+}
+
+// issueToken 签发 JWT 并记录会话
+func issueToken(c *fiber.Ctx, user *models.User) (*LoginResponse, error) {
+	jtiBytes := make([]byte, 16)
+	if _, err := rand.Read(jtiBytes); err != nil {
+		return nil, err
+	}
+	jti := hex.EncodeToString(jtiBytes)
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"jti":      jti,
+		"exp":      expiresAt.Unix(),
 	})
+
+	tokenString, err := token.SignedString(jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := models.CreateSession(user.ID, jti, c.IP(), c.Get("User-Agent"), time.Unix(expiresAt.Unix(), 0)); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:     tokenString,
+		ExpiresAt: expiresAt.Unix(),
+		User:      user,
+	}, nil
 }
 
 func Me(c *fiber.Ctx) error {
@@ -119,19 +173,101 @@ func Me(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"status": true,
-		"data":   user,
+		"status":      true,
+		"data":        user,
+		"permissions": user.EffectivePermissions(),
 	})
 }
 
 func Logout(c *fiber.Ctx) error {
-	// JWT 是无状态的，客户端删除 token 即可
+	jti, _ := c.Locals("jti").(string)
+	if err := models.RevokeSessionByJTI(jti); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to revoke session",
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"status":  true,
 		"message": "Logged out successfully",
 	})
 }
 
+// ListSessions 列出当前用户的有效会话
+func ListSessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(int64)
+	jti, _ := c.Locals("jti").(string)
+
+	sessions, err := models.ListSessions(userID, jti)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to list sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   sessions,
+	})
+}
+
+// RevokeSession 吊销当前用户的指定会话
+func RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(int64)
+
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid session ID",
+		})
+	}
+
+	found, err := models.RevokeSession(userID, id)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to revoke session",
+		})
+	}
+	if !found {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  false,
+			"message": "Session not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "Session revoked",
+	})
+}
+
+// RevokeAllSessions 吊销当前用户的所有会话；默认保留当前会话，?include_current=true 时一并吊销
+func RevokeAllSessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(int64)
+	keep, _ := c.Locals("jti").(string)
+	if c.QueryBool("include_current") {
+		keep = ""
+	}
+
+	count, err := models.RevokeUserSessions(userID, keep)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to revoke sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "Sessions revoked",
+		"data":    fiber.Map{"revoked": count},
+	})
+}
+
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password"`
 	NewPassword string `json:"new_password"`
@@ -184,6 +320,15 @@ func ChangePassword(c *fiber.Ctx) error {
 		})
 	}
 
+	// 修改密码后其他设备上的登录全部失效
+	jti, _ := c.Locals("jti").(string)
+	if _, err := models.RevokeUserSessions(userID, jti); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to revoke sessions",
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"status":  true,
 		"message": "Password changed successfully",
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/firewall"
+	"site_manager_panel/internal/models"
+)
+
+// 登录失败策略：前几次不限制，之后按指数退避，达到阈值后锁定
+const (
+	freeAttempts     = 3
+	backoffBase      = time.Second
+	maxBackoff       = 5 * time.Minute
+	lockoutThreshold = 10
+	lockoutDuration  = 30 * time.Minute
+	failureWindow    = 24 * time.Hour // 超过此时间没有新的失败则重新计数
+)
+
+var (
+	// banThreshold IP 连续失败达到该次数时通过防火墙封禁，0 表示关闭
+	banThreshold = 0
+
+	timeNow      = time.Now
+	denyIP       = firewall.DenyIP
+	removeDenyIP = firewall.RemoveDenyIP
+)
+
+// SetLoginBanThreshold 设置防火墙封禁阈值
+func SetLoginBanThreshold(n int) {
+	if n < 0 {
+		n = 0
+	}
+	banThreshold = n
+}
+
+// lockDuration 根据连续失败次数计算需要等待的时间
+func lockDuration(failures int) time.Duration {
+	if failures >= lockoutThreshold {
+		return lockoutDuration
+	}
+	if failures <= freeAttempts {
+		return 0
+	}
+	shift := failures - freeAttempts - 1
+	d := backoffBase * time.Duration(math.Pow(2, float64(shift)))
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+type attemptKey struct {
+	kind string
+	key  string
+}
+
+func loginKeys(ip, username string) []attemptKey {
+	keys := []attemptKey{}
+	if ip != "" {
+		keys = append(keys, attemptKey{models.AttemptIP, ip})
+	}
+	if username != "" {
+		keys = append(keys, attemptKey{models.AttemptUsername, username})
+	}
+	return keys
+}
+
+// loginRetryAfter 返回 IP 或用户名仍需等待的时间，0 表示允许尝试
+func loginRetryAfter(ip, username string) (time.Duration, error) {
+	now := timeNow()
+	var wait time.Duration
+	for _, k := range loginKeys(ip, username) {
+		a, err := models.GetLoginAttempt(k.kind, k.key)
+		if err != nil {
+			return 0, err
+		}
+		if a != nil && a.LockedUntil.After(now) {
+			if d := a.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure 记录一次失败并更新锁定时间。计数在数据库中原子累加，
+// 并发的失败请求不会相互覆盖而绕过锁定
+func recordLoginFailure(ip, username string) {
+	now := timeNow()
+	for _, k := range loginKeys(ip, username) {
+		a, err := models.AddLoginFailure(k.kind, k.key, now, now.Add(-failureWindow))
+		if err != nil {
+			log.Printf("[auth] 保存登录失败记录失败: %v", err)
+			continue
+		}
+		if err := models.ExtendLoginLock(k.kind, k.key, now.Add(lockDuration(a.Failures))); err != nil {
+			log.Printf("[auth] 保存登录锁定时间失败: %v", err)
+		}
+
+		if k.kind == models.AttemptIP && banThreshold > 0 && a.Failures >= banThreshold && !a.Banned {
+			banIP(k.key, a.Failures)
+		}
+	}
+}
+
+// banIP 通过防火墙封禁 IP，并发的失败请求只有一个会执行封禁
+func banIP(ip string, failures int) {
+	claimed, err := models.SetLoginAttemptBanned(models.AttemptIP, ip, true)
+	if err != nil {
+		log.Printf("[auth] 保存封禁状态失败: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	if err := denyIP(ip, "Site Manager login brute-force"); err != nil {
+		log.Printf("[auth] 防火墙封禁 %s 失败: %v", ip, err)
+		models.SetLoginAttemptBanned(models.AttemptIP, ip, false)
+		return
+	}
+	log.Printf("[auth] %s 连续登录失败 %d 次，已通过防火墙封禁", ip, failures)
+}
+
+// clearLoginFailures 登录成功后清除失败记录
+func clearLoginFailures(ip, username string) {
+	for _, k := range loginKeys(ip, username) {
+		if err := models.DeleteLoginAttempt(k.kind, k.key); err != nil {
+			log.Printf("[auth] 清除登录失败记录失败: %v", err)
+		}
+	}
+}
+
+// tooManyAttempts 返回 429 和 Retry-After
+func tooManyAttempts(c *fiber.Ctx, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(429).JSON(fiber.Map{
+		"status":      false,
+		"message":     fmt.Sprintf("Too many failed attempts, try again in %d seconds", seconds),
+		"retry_after": seconds,
+	})
+}
+
+// ListLockouts 列出登录失败记录（管理员）
+func ListLockouts(c *fiber.Ctx) error {
+	attempts, err := models.ListLoginAttempts()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to list lockouts",
+		})
+	}
+
+	now := timeNow()
+	data := make([]fiber.Map, 0, len(attempts))
+	for _, a := range attempts {
+		data = append(data, fiber.Map{
+			"kind":            a.Kind,
+			"key":             a.Key,
+			"failures":        a.Failures,
+			"last_failure_at": a.LastFailureAt,
+			"locked_until":    a.LockedUntil,
+			"locked":          a.LockedUntil.After(now),
+			"banned":          a.Banned,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   data,
+	})
+}
+
+// clearAttempt 删除记录，若已被防火墙封禁则一并解除
+func clearAttempt(a *models.LoginAttempt) error {
+	if a.Kind == models.AttemptIP && a.Banned {
+		if err := removeDenyIP(a.Key); err != nil {
+			return fmt.Errorf("failed to remove firewall rule: %w", err)
+		}
+	}
+	return models.DeleteLoginAttempt(a.Kind, a.Key)
+}
+
+// ClearLockout 解除单个 IP 或用户名的锁定（管理员）
+func ClearLockout(c *fiber.Ctx) error {
+	kind, key := c.Params("kind"), c.Params("key")
+	if kind != models.AttemptIP && kind != models.AttemptUsername {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid lockout kind",
+		})
+	}
+
+	a, err := models.GetLoginAttempt(kind, key)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+	if a == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  false,
+			"message": "Lockout not found",
+		})
+	}
+
+	if err := clearAttempt(a); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "Lockout cleared",
+	})
+}
+
+// ClearAllLockouts 清除全部登录失败记录（管理员）
+func ClearAllLockouts(c *fiber.Ctx) error {
+	attempts, err := models.ListLoginAttempts()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to list lockouts",
+		})
+	}
+
+	for _, a := range attempts {
+		if err := clearAttempt(a); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  false,
+				"message": err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "All lockouts cleared",
+	})
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"site_manager_panel/internal/models"
+)
+
+func TestLockDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{9, 32 * time.Second},
+		{10, lockoutDuration},
+		{50, lockoutDuration},
+	}
+
+	for _, tt := range tests {
+		if got := lockDuration(tt.failures); got != tt.want {
+			t.Errorf("lockDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// fakeClock 替换 timeNow，测试结束后恢复
+func fakeClock(t *testing.T) *time.Time {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+	return &now
+}
+
+func TestLoginBackoffAndLockout(t *testing.T) {
+	setupUsers(t)
+	app := newSessionApp()
+	now := fakeClock(t)
+
+	wrong := LoginRequest{Username: "operator", Password: "wrong"}
+	right := LoginRequest{Username: "operator", Password: "secret123"}
+
+	for i := 1; i <= freeAttempts+1; i++ {
+		if status, _ := sessionRequest(t, app, "POST", "/api/auth/login", "", wrong); status != 401 {
+			t.Fatalf("Attempt %d: expected 401, got %d", i, status)
+		}
+	}
+
+	// 第 4 次失败后进入退避期，正确密码也被拒绝
+	status, result := sessionRequest(t, app, "POST", "/api/auth/login", "", right)
+	if status != 429 || result["retry_after"].(float64) != 1 {
+		t.Fatalf("Expected 429 with retry_after=1, got %d %v", status, result)
+	}
+
+	a, _ := models.GetLoginAttempt(models.AttemptUsername, "operator")
+	if a == nil || a.Failures != freeAttempts+1 {
+		t.Fatalf("Expected username failures to be tracked, got %+v", a)
+	}
+
+	*now = now.Add(2 * time.Second)
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/login", "", right); status != 200 {
+		t.Fatalf("Expected login after backoff, got %d", status)
+	}
+	if a, _ := models.GetLoginAttempt(models.AttemptUsername, "operator"); a != nil {
+		t.Errorf("Expected failures to be cleared after success, got %+v", a)
+	}
+
+	// 连续失败达到阈值后锁定
+	for i := 0; i < lockoutThreshold; i++ {
+		sessionRequest(t, app, "POST", "/api/auth/login", "", wrong)
+		*now = now.Add(maxBackoff)
+	}
+	*now = now.Add(-maxBackoff)
+	a, _ = models.GetLoginAttempt(models.AttemptUsername, "operator")
+	if a == nil || !a.LockedUntil.Equal(now.Add(lockoutDuration)) {
+		t.Fatalf("Expected lockout of %v, got %+v", lockoutDuration, a)
+	}
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/login", "", right); status != 429 {
+		t.Errorf("Expected locked account to get 429, got %d", status)
+	}
+}
+
+func TestFirewallBanAndClear(t *testing.T) {
+	setupUsers(t)
+	app := newSessionApp()
+	app.Delete("/security/lockouts/:kind/:key", ClearLockout)
+	now := fakeClock(t)
+
+	denied := []string{}
+	removed := []string{}
+	origDeny, origRemove := denyIP, removeDenyIP
+	denyIP = func(ip, comment string) error { denied = append(denied, ip); return nil }
+	removeDenyIP = func(ip string) error { removed = append(removed, ip); return nil }
+	SetLoginBanThreshold(5)
+	t.Cleanup(func() {
+		SetLoginBanThreshold(0)
+		denyIP, removeDenyIP = origDeny, origRemove
+	})
+
+	// 每次换一个用户名，只有 IP 维度在累计
+	for i := 0; i < 6; i++ {
+		sessionRequest(t, app, "POST", "/api/auth/login", "", LoginRequest{Username: "user" + string(rune('a'+i)), Password: "x"})
+		*now = now.Add(maxBackoff)
+	}
+
+	if len(denied) != 1 {
+		t.Fatalf("Expected exactly one firewall ban, got %v", denied)
+	}
+	ip := denied[0]
+	a, _ := models.GetLoginAttempt(models.AttemptIP, ip)
+	if a == nil || !a.Banned || a.Failures != 6 {
+		t.Fatalf("Expected banned IP record, got %+v", a)
+	}
+
+	if status, _ := sessionRequest(t, app, "DELETE", "/security/lockouts/ip/"+ip, "", nil); status != 200 {
+		t.Fatalf("ClearLockout failed: %d", status)
+	}
+	if len(removed) != 1 || removed[0] != ip {
+		t.Errorf("Expected firewall rule removal for %s, got %v", ip, removed)
+	}
+	if a, _ := models.GetLoginAttempt(models.AttemptIP, ip); a != nil {
+		t.Errorf("Expected record to be cleared, got %+v", a)
+	}
+	if status, _ := sessionRequest(t, app, "DELETE", "/security/lockouts/ip/"+ip, "", nil); status != 404 {
+		t.Errorf("Expected 404 for missing lockout, got %d", status)
+	}
+}
+
+func TestConcurrentLoginFailures(t *testing.T) {
+	setupUsers(t)
+	now := fakeClock(t)
+
+	var denied int32
+	origDeny := denyIP
+	denyIP = func(ip, comment string) error { atomic.AddInt32(&denied, 1); return nil }
+	SetLoginBanThreshold(5)
+	t.Cleanup(func() {
+		SetLoginBanThreshold(0)
+		denyIP = origDeny
+	})
+
+	// 并发的失败请求各自计数，不会读到同一个旧值而相互覆盖
+	const attempts = 50
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			recordLoginFailure("10.0.0.1", "operator")
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, k := range loginKeys("10.0.0.1", "operator") {
+		a, _ := models.GetLoginAttempt(k.kind, k.key)
+		if a == nil || a.Failures != attempts || !a.LockedUntil.Equal(now.Add(lockoutDuration)) {
+			t.Errorf("Expected %d failures and lockout for %s, got %+v", attempts, k.key, a)
+		}
+	}
+	if denied != 1 {
+		t.Errorf("Expected exactly one firewall ban, got %d", denied)
+	}
+}
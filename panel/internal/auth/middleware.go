@@ -5,6 +5,8 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+
+	"site_manager_panel/internal/models"
 )
 
 func JWTMiddleware() fiber.Handler {
@@ -45,9 +47,92 @@ func JWTMiddleware() fiber.Handler {
 			})
 		}
 
+		userID, _ := claims["user_id"].(float64)
+		username, _ := claims["username"].(string)
+		jti, _ := claims["jti"].(string)
+
+		// 令牌必须对应一个未吊销的会话
+		active, err := models.IsSessionActive(int64(userID), jti)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  false,
+				"message": "Internal server error",
+			})
+		}
+		if !active {
+			return c.Status(401).JSON(fiber.Map{
+				"status":  false,
+				"message": "Session has been revoked",
+			})
+		}
+
 		// 将用户信息存入 context
-		c.Locals("user_id", int64(claims["user_id"].(float64)))
-		c.Locals("username", claims["username"].(string))
+		c.Locals("user_id", int64(userID))
+		c.Locals("username", username)
+		c.Locals("jti", jti)
+
+		return c.Next()
+	}
+}
+
+// downloadSuffixes 下载完整数据的 GET 接口，需要 模块:write。
+// 备份文件和数据库导出包含站点源码和数据库全部数据，只读用户只能查看列表
+var downloadSuffixes = map[string]string{
+	"backups":   "/download",
+	"databases": "/export",
+}
+
+// RequiredPermission 根据请求方法和路径推导所需权限
+// 路径第一段为模块名，GET/HEAD 需要 模块:read，其余需要 模块:write；
+// /auth 下的接口对所有登录用户开放，返回空字符串。
+// 未登记的模块会得到一个不存在的权限，只有 admin 能访问。
+func RequiredPermission(method, path string) string {
+	path = strings.TrimPrefix(path, "/api")
+	module := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+
+	switch module {
+	case "auth":
+		return ""
+	case "terminal":
+		return "terminal:" + models.ActionUse
+	}
+	if suffix, ok := downloadSuffixes[module]; ok && strings.HasSuffix(strings.TrimSuffix(path, "/"), suffix) {
+		return module + ":" + models.ActionWrite
+	}
+
+	if method == fiber.MethodGet || method == fiber.MethodHead {
+		return module + ":" + models.ActionRead
+	}
+	return module + ":" + models.ActionWrite
+}
+
+// RBACMiddleware 按角色和权限校验请求，需放在 JWTMiddleware 之后
+func RBACMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int64)
+		user, err := models.GetUserByID(userID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  false,
+				"message": "Internal server error",
+			})
+		}
+		if user == nil {
+			return c.Status(401).JSON(fiber.Map{
+				"status":  false,
+				"message": "User not found",
+			})
+		}
+
+		c.Locals("role", user.Role)
+
+		perm := RequiredPermission(c.Method(), c.Path())
+		if perm != "" && !user.HasPermission(perm) {
+			return c.Status(403).JSON(fiber.Map{
+				"status":  false,
+				"message": "Permission denied: " + perm,
+			})
+		}
 
 		return c.Next()
 	}
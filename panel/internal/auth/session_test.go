@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/models"
+)
+
+func newSessionApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/api/auth/login", Login)
+	app.Post("/api/auth/login/2fa", LoginTwoFactor)
+	protected := app.Group("/api", JWTMiddleware())
+	protected.Get("/auth/me", Me)
+	protected.Post("/auth/logout", Logout)
+	protected.Post("/auth/password", ChangePassword)
+	protected.Get("/auth/sessions", ListSessions)
+	protected.Delete("/auth/sessions", RevokeAllSessions)
+	protected.Delete("/auth/sessions/:id", RevokeSession)
+	protected.Post("/auth/2fa/setup", SetupTwoFactor)
+	protected.Post("/auth/2fa/enable", EnableTwoFactor)
+	protected.Delete("/users/:id/2fa", ResetUserTwoFactor)
+	return app
+}
+
+func sessionRequest(t *testing.T, app *fiber.App, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "session-test")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func login(t *testing.T, app *fiber.App, username, password string) string {
+	t.Helper()
+	status, result := sessionRequest(t, app, "POST", "/api/auth/login", "", LoginRequest{Username: username, Password: password})
+	if status != 200 {
+		t.Fatalf("Login failed: %d %v", status, result)
+	}
+	return result["data"].(map[string]interface{})["token"].(string)
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	setupUsers(t)
+	app := newSessionApp()
+	token := login(t, app, "operator", "secret123")
+
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", token, nil); status != 200 {
+		t.Fatalf("Expected token to be valid, got %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/logout", token, nil); status != 200 {
+		t.Fatalf("Logout failed: %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", token, nil); status != 401 {
+		t.Errorf("Expected revoked token to be rejected, got %d", status)
+	}
+}
+
+func TestListAndRevokeSessions(t *testing.T) {
+	setupUsers(t)
+	app := newSessionApp()
+	first := login(t, app, "operator", "secret123")
+	second := login(t, app, "operator", "secret123")
+	third := login(t, app, "operator", "secret123")
+	other := login(t, app, "viewer", "secret123")
+
+	status, result := sessionRequest(t, app, "GET", "/api/auth/sessions", first, nil)
+	if status != 200 {
+		t.Fatalf("List failed: %d", status)
+	}
+	sessions := result["data"].([]interface{})
+	if len(sessions) != 3 {
+		t.Fatalf("Expected 3 sessions, got %d", len(sessions))
+	}
+
+	var secondID float64
+	currentCount := 0
+	for _, s := range sessions {
+		m := s.(map[string]interface{})
+		if m["user_agent"] != "session-test" || m["ip"] == "" {
+			t.Errorf("Unexpected session metadata: %v", m)
+		}
+		if m["current"] == true {
+			currentCount++
+		}
+	}
+	if currentCount != 1 {
+		t.Errorf("Expected exactly one current session, got %d", currentCount)
+	}
+
+	// 会话按创建时间倒序，找出 second 对应的 ID
+	_, result = sessionRequest(t, app, "GET", "/api/auth/sessions", second, nil)
+	for _, s := range result["data"].([]interface{}) {
+		if m := s.(map[string]interface{}); m["current"] == true {
+			secondID = m["id"].(float64)
+		}
+	}
+
+	// 不能吊销其他用户的会话
+	if status, _ := sessionRequest(t, app, "DELETE", fmt.Sprintf("/api/auth/sessions/%d", int64(secondID)), other, nil); status != 404 {
+		t.Errorf("Expected 404 when revoking another user's session, got %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "DELETE", fmt.Sprintf("/api/auth/sessions/%d", int64(secondID)), first, nil); status != 200 {
+		t.Fatalf("Revoke failed: %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", second, nil); status != 401 {
+		t.Errorf("Expected revoked session to be rejected, got %d", status)
+	}
+
+	// 吊销全部（保留当前）
+	if status, _ := sessionRequest(t, app, "DELETE", "/api/auth/sessions", first, nil); status != 200 {
+		t.Fatalf("Revoke all failed: %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", third, nil); status != 401 {
+		t.Errorf("Expected third session to be revoked, got %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", first, nil); status != 200 {
+		t.Errorf("Expected current session to survive, got %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", other, nil); status != 200 {
+		t.Errorf("Expected other user's session to survive, got %d", status)
+	}
+}
+
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	setupUsers(t)
+	app := newSessionApp()
+	current := login(t, app, "operator", "secret123")
+	otherDevice := login(t, app, "operator", "secret123")
+
+	status, result := sessionRequest(t, app, "POST", "/api/auth/password", current, ChangePasswordRequest{
+		OldPassword: "secret123",
+		NewPassword: "newsecret456",
+	})
+	if status != 200 {
+		t.Fatalf("ChangePassword failed: %d %v", status, result)
+	}
+
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", otherDevice, nil); status != 401 {
+		t.Errorf("Expected other session to be revoked, got %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", current, nil); status != 200 {
+		t.Errorf("Expected current session to survive, got %d", status)
+	}
+
+	user, _ := models.GetUserByUsername("operator")
+	if !user.CheckPassword("newsecret456") {
+		t.Error("Password was not updated")
+	}
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+
+	"site_manager_panel/internal/models"
+)
+
+func TestHOTPRFC6238Vectors(t *testing.T) {
+	// RFC 6238 附录 B 测试向量（8 位）
+	seeds := map[string]struct {
+		key []byte
+		h   func() hash.Hash
+	}{
+		"SHA1":   {[]byte("12345678901234567890"), sha1.New},
+		"SHA256": {[]byte("12345678901234567890123456789012"), sha256.New},
+		"SHA512": {[]byte("1234567890123456789012345678901234567890123456789012345678901234"), sha512.New},
+	}
+
+	tests := []struct {
+		unix int64
+		algo string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, tt := range tests {
+		seed := seeds[tt.algo]
+		step := totpStep(time.Unix(tt.unix, 0))
+		if got := hotp(seed.key, uint64(step), 8, seed.h); got != tt.want {
+			t.Errorf("TOTP(%d, %s) = %s, want %s", tt.unix, tt.algo, got, tt.want)
+		}
+	}
+}
+
+// codeAt 使用与 verifyTOTP 相同的参数生成 6 位验证码
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hotp(key, uint64(totpStep(at)), totpDigits, sha1.New)
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	// "12345678901234567890" 的 Base32 编码
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0)
+
+	if got := codeAt(t, secret, now); got != "050471" {
+		t.Fatalf("Expected 6-digit RFC vector 050471, got %s", got)
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		want     bool
+	}{
+		{"Current step", "050471", 0, true},
+		{"Previous step within skew", codeAt(t, secret, now.Add(-30*time.Second)), 0, true},
+		{"Next step within skew", codeAt(t, secret, now.Add(30*time.Second)), 0, true},
+		{"Outside skew", codeAt(t, secret, now.Add(-90*time.Second)), 0, false},
+		{"Replayed step", "050471", totpStep(now), false},
+		{"Wrong length", "50471", 0, false},
+		{"Wrong code", "000000", 0, false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := verifyTOTP(secret, tt.code, now, tt.lastStep); ok != tt.want {
+			t.Errorf("%s: verifyTOTP = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+
+	// 带空格的小写密钥同样可用
+	if _, ok := verifyTOTP(strings.ToLower("GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ"), "050471", now, 0); !ok {
+		t.Error("Expected secret normalisation to accept spaced lowercase secret")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := provisioningURI("admin", "JBSWY3DPEHPK3PXP")
+	for _, part := range []string{"otpauth://totp/Site%20Manager:admin?", "secret=JBSWY3DPEHPK3PXP", "issuer=Site+Manager", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("Expected %q in %s", part, uri)
+		}
+	}
+}
+
+func TestTwoFactorLoginFlow(t *testing.T) {
+	ids := setupUsers(t)
+	app := newSessionApp()
+
+	token := login(t, app, "operator", "secret123")
+
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/2fa/setup", token, TwoFactorRequest{Password: "wrong"}); status != 400 {
+		t.Errorf("Expected setup with wrong password to fail, got %d", status)
+	}
+	status, result := sessionRequest(t, app, "POST", "/api/auth/2fa/setup", token, TwoFactorRequest{Password: "secret123"})
+	if status != 200 {
+		t.Fatalf("Setup failed: %d %v", status, result)
+	}
+	secret := result["data"].(map[string]interface{})["secret"].(string)
+
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/2fa/enable", token, TwoFactorRequest{Code: "000000"}); status != 400 {
+		t.Errorf("Expected enable with wrong code to fail, got %d", status)
+	}
+	now := time.Now()
+	status, result = sessionRequest(t, app, "POST", "/api/auth/2fa/enable", token, TwoFactorRequest{Code: codeAt(t, secret, now)})
+	if status != 200 {
+		t.Fatalf("Enable failed: %d %v", status, result)
+	}
+	codes := result["data"].(map[string]interface{})["recovery_codes"].([]interface{})
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(codes))
+	}
+
+	// 第一步只返回挑战令牌
+	status, result = sessionRequest(t, app, "POST", "/api/auth/login", "", LoginRequest{Username: "operator", Password: "secret123"})
+	data := result["data"].(map[string]interface{})
+	if status != 200 || data["two_factor_required"] != true || data["token"] != nil {
+		t.Fatalf("Expected challenge, got %d %v", status, result)
+	}
+	challenge := data["challenge_token"].(string)
+
+	// 挑战令牌不能直接访问接口
+	if status, _ := sessionRequest(t, app, "GET", "/api/auth/me", challenge, nil); status != 401 {
+		t.Errorf("Expected challenge token to be rejected by middleware, got %d", status)
+	}
+
+	// 启用时用过的验证码不能重放
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/login/2fa", "", TwoFactorLoginRequest{ChallengeToken: challenge, Code: codeAt(t, secret, now)}); status != 401 {
+		t.Errorf("Expected replayed code to be rejected, got %d", status)
+	}
+
+	status, result = sessionRequest(t, app, "POST", "/api/auth/login/2fa", "", TwoFactorLoginRequest{ChallengeToken: challenge, Code: codeAt(t, secret, now.Add(30*time.Second))})
+	if status != 200 {
+		t.Fatalf("Second step failed: %d %v", status, result)
+	}
+	if tok, _ := result["data"].(map[string]interface{})["token"].(string); tok == "" {
+		t.Fatal("Expected token after second step")
+	}
+
+	// 恢复码只能使用一次
+	recovery := strings.ToUpper(codes[0].(string))
+	req := TwoFactorLoginRequest{ChallengeToken: challenge, RecoveryCode: recovery}
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/login/2fa", "", req); status != 200 {
+		t.Errorf("Expected recovery code to work, got %d", status)
+	}
+	if status, _ := sessionRequest(t, app, "POST", "/api/auth/login/2fa", "", req); status != 401 {
+		t.Errorf("Expected used recovery code to be rejected, got %d", status)
+	}
+
+	// 管理员重置后恢复单步登录
+	if status, _ := sessionRequest(t, app, "DELETE", fmt.Sprintf("/api/users/%d/2fa", ids["operator"]), token, nil); status != 200 {
+		t.Fatalf("Reset failed: %d", status)
+	}
+	user, _ := models.GetUserByID(ids["operator"])
+	if user.TOTPEnabled || user.TOTPSecret != "" {
+		t.Errorf("Expected 2FA to be cleared, got %+v", user)
+	}
+	login(t, app, "operator", "secret123")
+}
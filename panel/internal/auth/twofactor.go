@@ -0,0 +1,446 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"site_manager_panel/internal/models"
+)
+
+// challengeTTL 两步验证挑战令牌有效期
+const challengeTTL = 5 * time.Minute
+
+const challengePurpose = "2fa"
+
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresAt         int64  `json:"expires_at"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// issueChallenge 签发挑战令牌；它不对应任何会话，无法通过 JWTMiddleware
+func issueChallenge(user *models.User) (string, time.Time, error) {
+	expiresAt := time.Now().Add(challengeTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"purpose": challengePurpose,
+		"exp":     expiresAt.Unix(),
+	})
+
+	tokenString, err := token.SignedString(jwtSecret)
+	return tokenString, expiresAt, err
+}
+
+// parseChallenge 校验挑战令牌并返回对应用户
+func parseChallenge(tokenString string) (*models.User, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid or expired challenge")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != challengePurpose {
+		return nil, fmt.Errorf("invalid challenge")
+	}
+	userID, _ := claims["user_id"].(float64)
+
+	user, err := models.GetUserByID(int64(userID))
+	if err != nil || user == nil || !user.TOTPEnabled {
+		return nil, fmt.Errorf("invalid challenge")
+	}
+	return user, nil
+}
+
+// checkSecondFactor 校验验证码或恢复码，成功时记录时间步 / 消耗恢复码
+func checkSecondFactor(user *models.User, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return user.UseRecoveryCode(hashRecoveryCode(recoveryCode))
+	}
+
+	step, ok := verifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	return true, user.SetTOTPLastStep(step)
+}
+
+// LoginTwoFactor 登录第二步：挑战令牌 + 验证码（或恢复码）换取正式令牌
+func LoginTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Challenge token and code are required",
+		})
+	}
+
+	user, err := parseChallenge(req.ChallengeToken)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid or expired challenge",
+		})
+	}
+
+	wait, err := loginRetryAfter(c.IP(), user.Username)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+	if wait > 0 {
+		return tooManyAttempts(c, wait)
+	}
+
+	ok, err := checkSecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+	if !ok {
+		recordLoginFailure(c.IP(), user.Username)
+		return c.Status(401).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid verification code",
+		})
+	}
+
+	resp, err := issueToken(c, user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to generate token",
+		})
+	}
+	clearLoginFailures(c.IP(), user.Username)
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   resp,
+	})
+}
+
+func currentUser(c *fiber.Ctx) (*models.User, error) {
+	userID, _ := c.Locals("user_id").(int64)
+	user, err := models.GetUserByID(userID)
+	if err == nil && user == nil {
+		err = fmt.Errorf("user not found")
+	}
+	return user, err
+}
+
+// TwoFactorStatus 查看当前用户的两步验证状态
+func TwoFactorStatus(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	remaining, err := user.RecoveryCodeCount()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"enabled":                  user.TOTPEnabled,
+			"recovery_codes_remaining": remaining,
+		},
+	})
+}
+
+// SetupTwoFactor 生成新的 TOTP 密钥，需调用 EnableTwoFactor 确认后生效
+func SetupTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	if !user.CheckPassword(req.Password) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Password is incorrect",
+		})
+	}
+	if user.TOTPEnabled {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := generateTOTPSecret()
+	if err == nil {
+		err = user.SetTOTPSecret(secret)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to generate secret",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"secret": secret,
+			"uri":    provisioningURI(user.Username, secret),
+		},
+	})
+}
+
+// EnableTwoFactor 用验证码确认密钥，启用两步验证并返回恢复码（仅显示一次）
+func EnableTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	if user.TOTPEnabled {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+	if user.TOTPSecret == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Call setup first",
+		})
+	}
+
+	step, ok := verifyTOTP(user.TOTPSecret, req.Code, time.Now(), 0)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid verification code",
+		})
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = user.EnableTOTP(hashes, step)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to enable two-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "Two-factor authentication enabled",
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTwoFactor 关闭两步验证，需要密码和验证码（或恢复码）
+func DisableTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	if !user.TOTPEnabled {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+	if !user.CheckPassword(req.Password) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Password is incorrect",
+		})
+	}
+
+	ok, err := checkSecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid verification code",
+		})
+	}
+
+	if err := user.DisableTOTP(); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to disable two-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes 用验证码换取一组新的恢复码，旧恢复码全部作废
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req TwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	if !user.TOTPEnabled {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+
+	step, ok := verifyTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid verification code",
+		})
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = user.SetRecoveryCodes(hashes)
+	}
+	if err == nil {
+		err = user.SetTOTPLastStep(step)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to generate recovery codes",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// ResetUserTwoFactor 管理员为丢失设备的用户关闭两步验证
+func ResetUserTwoFactor(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	user, err := models.GetUserByID(id)
+	if err != nil || user == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	if msg := checkGrant(c, user, "", nil); msg != "" {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  false,
+			"message": msg,
+		})
+	}
+
+	if err := user.DisableTOTP(); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to reset two-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "Two-factor authentication reset",
+	})
+}
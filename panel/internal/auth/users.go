@@ -0,0 +1,348 @@
+package auth
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/models"
+)
+
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+type UserRequest struct {
+	Username    string   `json:"username"`
+	Password    string   `json:"password"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+func validateRoleAndPermissions(role string, permissions []string) string {
+	if !models.IsValidRole(role) {
+		return "Invalid role"
+	}
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return "Invalid permission: " + p
+		}
+	}
+	return ""
+}
+
+// privileged 角色或权限是否等同 root（admin 角色，或用户管理、终端、计划任务写入、文件管理权限）
+func privileged(role string, permissions []string) bool {
+	if role == models.RoleAdmin {
+		return true
+	}
+	for _, p := range permissions {
+		if models.IsPrivilegedPermission(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGrant 只有管理员可以授予 admin 角色或等同 root 的权限，也只有管理员可以修改、删除拥有这些权限的用户；
+// 否则持有 users:write 的用户可以创建管理员、给自己的小号开终端或重置管理员密码。target 为 nil 表示新建用户；
+// 用户修改自己时只能改密码，由调用方先行拦截角色和权限的变化
+func checkGrant(c *fiber.Ctx, target *models.User, role string, permissions []string) string {
+	if caller, _ := c.Locals("role").(string); caller == models.RoleAdmin {
+		return ""
+	}
+	if currentID, _ := c.Locals("user_id").(int64); target != nil && target.ID != currentID && privileged(target.Role, target.Permissions) {
+		return "Only admins can modify users with privileged permissions"
+	}
+	changed := target == nil || role != target.Role || !samePermissions(permissions, target.Permissions)
+	if changed && privileged(role, permissions) {
+		return "Only admins can grant the admin role or privileged permissions (users, terminal, cron:write, files)"
+	}
+	return ""
+}
+
+// samePermissions 两组权限是否相同（忽略顺序）
+func samePermissions(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isLastAdmin 检查用户是否为唯一的管理员
+func isLastAdmin(user *models.User) (bool, error) {
+	if user.Role != models.RoleAdmin {
+		return false, nil
+	}
+	count, err := models.CountAdmins()
+	if err != nil {
+		return false, err
+	}
+	return count <= 1, nil
+}
+
+func ListUsers(c *fiber.Ctx) error {
+	users, err := models.ListUsers()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to list users",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   users,
+	})
+}
+
+// ListRoles 返回可选角色及其权限，供前端编辑用户时使用
+func ListRoles(c *fiber.Ctx) error {
+	roles := fiber.Map{}
+	for _, role := range []string{models.RoleAdmin, models.RoleOperator, models.RoleReadOnly} {
+		roles[role] = (&models.User{Role: role}).EffectivePermissions()
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"roles":       roles,
+			"permissions": models.AllPermissions(),
+		},
+	})
+}
+
+func CreateUser(c *fiber.Ctx) error {
+	var req UserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if !usernameRegex.MatchString(req.Username) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Username must be 3-32 letters, digits, '_', '.' or '-'",
+		})
+	}
+	if len(req.Password) < 6 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Password must be at least 6 characters",
+		})
+	}
+	if req.Role == "" {
+		req.Role = models.RoleReadOnly
+	}
+	if msg := validateRoleAndPermissions(req.Role, req.Permissions); msg != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": msg,
+		})
+	}
+	if msg := checkGrant(c, nil, req.Role, req.Permissions); msg != "" {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  false,
+			"message": msg,
+		})
+	}
+
+	existing, err := models.GetUserByUsername(req.Username)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+	if existing != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Username already exists",
+		})
+	}
+
+	user, err := models.CreateUser(req.Username, req.Password, req.Role, req.Permissions)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to create user",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "User created successfully",
+		"data":    user,
+	})
+}
+
+func UpdateUser(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	var req UserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := models.GetUserByID(id)
+	if err != nil || user == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	if req.Role == "" {
+		req.Role = user.Role
+	}
+	if req.Permissions == nil {
+		req.Permissions = user.Permissions
+	}
+	if msg := validateRoleAndPermissions(req.Role, req.Permissions); msg != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": msg,
+		})
+	}
+	if req.Password != "" && len(req.Password) < 6 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Password must be at least 6 characters",
+		})
+	}
+	// 任何人都不能修改自己的角色和权限，管理员之间也只能互相调整
+	if currentID, _ := c.Locals("user_id").(int64); currentID == id &&
+		(req.Role != user.Role || !samePermissions(req.Permissions, user.Permissions)) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Cannot change your own role or permissions",
+		})
+	}
+	if msg := checkGrant(c, user, req.Role, req.Permissions); msg != "" {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  false,
+			"message": msg,
+		})
+	}
+
+	if req.Role != models.RoleAdmin {
+		last, err := isLastAdmin(user)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  false,
+				"message": "Internal server error",
+			})
+		}
+		if last {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  false,
+				"message": "Cannot demote the last admin",
+			})
+		}
+	}
+
+	if err := user.UpdateRole(req.Role, req.Permissions); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to update user",
+		})
+	}
+	if req.Password != "" {
+		if err := user.UpdatePassword(req.Password); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  false,
+				"message": "Failed to update password",
+			})
+		}
+		// 管理员重置密码后该用户需要重新登录
+		if _, err := models.RevokeUserSessions(user.ID, ""); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  false,
+				"message": "Failed to revoke sessions",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "User updated successfully",
+		"data":    user,
+	})
+}
+
+func DeleteUser(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	if currentID, _ := c.Locals("user_id").(int64); currentID == id {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Cannot delete the current user",
+		})
+	}
+
+	user, err := models.GetUserByID(id)
+	if err != nil || user == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  false,
+			"message": "User not found",
+		})
+	}
+
+	if msg := checkGrant(c, user, "", nil); msg != "" {
+		return c.Status(403).JSON(fiber.Map{
+			"status":  false,
+			"message": msg,
+		})
+	}
+
+	last, err := isLastAdmin(user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Internal server error",
+		})
+	}
+	if last {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "Cannot delete the last admin",
+		})
+	}
+
+	if err := models.DeleteUser(id); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "Failed to delete user",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "User deleted successfully",
+	})
+}
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/models"
+)
+
+func TestRequiredPermission(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/api/sites", "sites:read"},
+		{"POST", "/api/sites/example.com/enable", "sites:write"},
+		{"GET", "/api/files/read", "files:read"},
+		{"POST", "/api/files/save", "files:write"},
+		{"POST", "/api/terminal/exec", "terminal:use"},
+		{"GET", "/api/auth/me", ""},
+		{"DELETE", "/api/users/2", "users:write"},
+		{"GET", "/api/backups", "backups:read"},
+		{"GET", "/api/databases", "databases:read"},
+		{"GET", "/api/databases/shop/export", "databases:write"},
+		{"GET", "/api/backups/site/example.com_20240101_000000.tar.gz/download", "backups:write"},
+		{"GET", "/api/unknown", "unknown:read"},
+	}
+
+	for _, tt := range tests {
+		if got := RequiredPermission(tt.method, tt.path); got != tt.want {
+			t.Errorf("RequiredPermission(%s, %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+// setupUsers 初始化临时数据库并创建各角色用户，返回 用户名 -> ID
+func setupUsers(t *testing.T) map[string]int64 {
+	t.Helper()
+	if err := models.InitDB(t.TempDir()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	t.Cleanup(func() { models.DB.Close() })
+
+	ids := map[string]int64{}
+	admin, _ := models.GetUserByUsername("admin")
+	ids["admin"] = admin.ID
+
+	for _, u := range []struct {
+		name  string
+		role  string
+		perms []string
+	}{
+		{"operator", models.RoleOperator, nil},
+		{"viewer", models.RoleReadOnly, nil},
+		{"contractor", models.RoleReadOnly, []string{"files:write"}},
+	} {
+		user, err := models.CreateUser(u.name, "secret123", u.role, u.perms)
+		if err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		ids[u.name] = user.ID
+	}
+	return ids
+}
+
+// newRBACApp 用请求头 X-User-ID 模拟已通过 JWT 认证的用户
+func newRBACApp() *fiber.App {
+	app := fiber.New()
+	api := app.Group("/api", func(c *fiber.Ctx) error {
+		userID, _ := strconv.ParseInt(c.Get("X-User-ID"), 10, 64)
+		c.Locals("user_id", userID)
+		return c.Next()
+	}, RBACMiddleware())
+
+	ok := func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"status": true}) }
+	api.Get("/sites", ok)
+	api.Post("/sites", ok)
+	api.Post("/files/save", ok)
+	api.Get("/files/read", ok)
+	api.Get("/cron", ok)
+	api.Post("/cron", ok)
+	api.Post("/terminal/exec", ok)
+	api.Get("/users", ListUsers)
+	api.Post("/users", CreateUser)
+	api.Put("/users/:id", UpdateUser)
+	api.Delete("/users/:id", DeleteUser)
+	api.Delete("/users/:id/2fa", ResetUserTwoFactor)
+	return app
+}
+
+func doRequest(t *testing.T, app *fiber.App, userID int64, method, path string, body interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", strconv.FormatInt(userID, 10))
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestRBACMiddleware(t *testing.T) {
+	ids := setupUsers(t)
+	app := newRBACApp()
+
+	tests := []struct {
+		name       string
+		user       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"Admin can use terminal", "admin", "POST", "/api/terminal/exec", 200},
+		{"Operator can write sites", "operator", "POST", "/api/sites", 200},
+		{"Operator cannot use terminal", "operator", "POST", "/api/terminal/exec", 403},
+		{"Operator can read cron", "operator", "GET", "/api/cron", 200},
+		{"Operator cannot write cron", "operator", "POST", "/api/cron", 403},
+		{"Operator cannot save files", "operator", "POST", "/api/files/save", 403},
+		{"Viewer cannot read files", "viewer", "GET", "/api/files/read", 403},
+		{"Operator cannot list users", "operator", "GET", "/api/users", 403},
+		{"Viewer can read sites", "viewer", "GET", "/api/sites", 200},
+		{"Viewer cannot write sites", "viewer", "POST", "/api/sites", 403},
+		{"Viewer cannot save files", "viewer", "POST", "/api/files/save", 403},
+		{"Contractor can save files", "contractor", "POST", "/api/files/save", 200},
+		{"Contractor cannot use terminal", "contractor", "POST", "/api/terminal/exec", 403},
+		{"Unknown user", "", "GET", "/api/sites", 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doRequest(t, app, ids[tt.user], tt.method, tt.path, nil); got != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, got)
+			}
+		})
+	}
+}
+
+func TestUserCRUD(t *testing.T) {
+	ids := setupUsers(t)
+	app := newRBACApp()
+	admin := ids["admin"]
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		wantStatus int
+	}{
+		{"Create user", "POST", "/api/users", UserRequest{Username: "dev", Password: "secret123", Role: "operator"}, 200},
+		{"Duplicate username", "POST", "/api/users", UserRequest{Username: "dev", Password: "secret123"}, 400},
+		{"Invalid role", "POST", "/api/users", UserRequest{Username: "dev2", Password: "secret123", Role: "root"}, 400},
+		{"Invalid permission", "POST", "/api/users", UserRequest{Username: "dev2", Password: "secret123", Permissions: []string{"files:delete"}}, 400},
+		{"Short password", "POST", "/api/users", UserRequest{Username: "dev2", Password: "123"}, 400},
+		{"Grant terminal", "PUT", "/api/users/" + strconv.FormatInt(ids["viewer"], 10), UserRequest{Permissions: []string{"terminal:use"}}, 200},
+		{"Demote last admin", "PUT", "/api/users/" + strconv.FormatInt(admin, 10), UserRequest{Role: "operator"}, 400},
+		{"Delete self", "DELETE", "/api/users/" + strconv.FormatInt(admin, 10), nil, 400},
+		{"Delete user", "DELETE", "/api/users/" + strconv.FormatInt(ids["contractor"], 10), nil, 200},
+		{"Delete missing user", "DELETE", "/api/users/9999", nil, 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doRequest(t, app, admin, tt.method, tt.path, tt.body); got != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, got)
+			}
+		})
+	}
+
+	viewer, _ := models.GetUserByID(ids["viewer"])
+	if viewer.Role != models.RoleReadOnly || !viewer.HasPermission("terminal:use") {
+		t.Errorf("Expected viewer to keep role and gain terminal:use, got %+v", viewer)
+	}
+	if doRequest(t, app, ids["viewer"], "POST", "/api/terminal/exec", nil) != 200 {
+		t.Error("Expected granted permission to take effect immediately")
+	}
+}
+
+func TestUserSelfPromotion(t *testing.T) {
+	ids := setupUsers(t)
+	app := newRBACApp()
+	manager, err := models.CreateUser("manager", "secret123", models.RoleReadOnly, []string{"users:read", "users:write"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	self := "/api/users/" + strconv.FormatInt(manager.ID, 10)
+
+	tests := []struct {
+		name       string
+		user       int64
+		method     string
+		path       string
+		body       interface{}
+		wantStatus int
+	}{
+		{"Create admin", manager.ID, "POST", "/api/users", UserRequest{Username: "evil", Password: "secret123", Role: "admin"}, 403},
+		{"Create user manager", manager.ID, "POST", "/api/users", UserRequest{Username: "evil", Password: "secret123", Permissions: []string{"users:write"}}, 403},
+		{"Promote self to admin", manager.ID, "PUT", self, UserRequest{Role: "admin"}, 400},
+		{"Promote self to operator", manager.ID, "PUT", self, UserRequest{Role: "operator"}, 400},
+		{"Grant self terminal", manager.ID, "PUT", self, UserRequest{Permissions: []string{"users:read", "users:write", "terminal:use"}}, 400},
+		{"Create terminal user", manager.ID, "POST", "/api/users", UserRequest{Username: "evil", Password: "secret123", Permissions: []string{"terminal:use"}}, 403},
+		{"Create file manager", manager.ID, "POST", "/api/users", UserRequest{Username: "evil", Password: "secret123", Permissions: []string{"files:read"}}, 403},
+		{"Grant others cron:write", manager.ID, "PUT", "/api/users/" + strconv.FormatInt(ids["viewer"], 10), UserRequest{Permissions: []string{"cron:write"}}, 403},
+		{"Modify file manager", manager.ID, "PUT", "/api/users/" + strconv.FormatInt(ids["contractor"], 10), UserRequest{Password: "owned123"}, 403},
+		{"Grant others users:write", manager.ID, "PUT", "/api/users/" + strconv.FormatInt(ids["viewer"], 10), UserRequest{Permissions: []string{"users:write"}}, 403},
+		{"Reset admin password", manager.ID, "PUT", "/api/users/" + strconv.FormatInt(ids["admin"], 10), UserRequest{Password: "owned123"}, 403},
+		{"Delete admin", manager.ID, "DELETE", "/api/users/" + strconv.FormatInt(ids["admin"], 10), nil, 403},
+		{"Reset admin 2FA", manager.ID, "DELETE", "/api/users/" + strconv.FormatInt(ids["admin"], 10) + "/2fa", nil, 403},
+		{"Reset viewer 2FA", manager.ID, "DELETE", "/api/users/" + strconv.FormatInt(ids["viewer"], 10) + "/2fa", nil, 200},
+		{"Change own password", manager.ID, "PUT", self, UserRequest{Password: "secret456"}, 200},
+		{"Create operator", manager.ID, "POST", "/api/users", UserRequest{Username: "dev", Password: "secret123", Role: "operator"}, 200},
+		{"Admin grants users:write", ids["admin"], "PUT", "/api/users/" + strconv.FormatInt(ids["viewer"], 10), UserRequest{Permissions: []string{"users:write"}}, 200},
+		{"Admin changes own permissions", ids["admin"], "PUT", "/api/users/" + strconv.FormatInt(ids["admin"], 10), UserRequest{Permissions: []string{"terminal:use"}}, 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doRequest(t, app, tt.user, tt.method, tt.path, tt.body); got != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, got)
+			}
+		})
+	}
+
+	if u, _ := models.GetUserByID(manager.ID); u.Role != models.RoleReadOnly || u.HasPermission("terminal:use") {
+		t.Errorf("Expected manager to keep role and permissions, got %+v", u)
+	}
+	if u, _ := models.GetUserByUsername("evil"); u != nil {
+		t.Errorf("Expected no user to be created, got %+v", u)
+	}
+	if admin, _ := models.GetUserByID(ids["admin"]); admin.CheckPassword("owned123") {
+		t.Error("Expected admin password to be unchanged")
+	}
+}
@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 与 bin/backup_cron.sh 使用同一份配置
+const (
+	DefaultConfigFile  = "/opt/site_manager/config/backup.conf"
+	DefaultExcludeFile = "/opt/site_manager/config/backup_exclude.conf"
+)
+
+// FTPConfig FTP 远程备份配置
+type FTPConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	User        string `json:"user"`
+	Pass        string `json:"-"`
+	Path        string `json:"path"`
+	DeleteLocal bool   `json:"delete_local"`
+}
+
+// Config 备份配置
+type Config struct {
+	BackupDir         string    `json:"backup_dir"`
+	DefaultBackupPath string    `json:"default_backup_path"`
+	DBKeep            int       `json:"db_keep"`
+	SiteKeep          int       `json:"site_keep"`
+	PathKeep          int       `json:"path_keep"`
+	FTP               FTPConfig `json:"ftp"`
+	Excludes          []string  `json:"excludes"`
+}
+
+// LoadConfig 读取 backup.conf 和 backup_exclude.conf，缺省值与 backup_cron.sh 相同
+func LoadConfig(confFile, excludeFile string) *Config {
+	cfg := &Config{
+		BackupDir:         "/www/backup",
+		DefaultBackupPath: "/www/wwwroot",
+		DBKeep:            51,
+		SiteKeep:          7,
+		PathKeep:          7,
+		FTP:               FTPConfig{Port: 21},
+		Excludes:          []string{},
+	}
+
+	vars := parseShellVars(confFile)
+	if v := vars["BACKUP_DIR"]; v != "" {
+		cfg.BackupDir = v
+	}
+	if v := vars["DEFAULT_BACKUP_PATH"]; v != "" {
+		cfg.DefaultBackupPath = v
+	}
+	cfg.DBKeep = atoiOr(vars["DB_KEEP"], cfg.DBKeep)
+	cfg.SiteKeep = atoiOr(vars["SITE_KEEP"], cfg.SiteKeep)
+	cfg.PathKeep = atoiOr(vars["PATH_KEEP"], cfg.PathKeep)
+
+	cfg.FTP.Enabled = vars["FTP_ENABLED"] == "true"
+	cfg.FTP.Host = vars["FTP_HOST"]
+	cfg.FTP.Port = atoiOr(vars["FTP_PORT"], cfg.FTP.Port)
+	cfg.FTP.User = vars["FTP_USER"]
+	cfg.FTP.Pass = vars["FTP_PASS"]
+	cfg.FTP.Path = vars["FTP_PATH"]
+	cfg.FTP.DeleteLocal = vars["FTP_DELETE_LOCAL"] == "true"
+
+	cfg.Excludes = readExcludes(excludeFile)
+
+	return cfg
+}
+
+// Keep 返回指定类型的默认保留份数
+func (c *Config) Keep(kind string) int {
+	switch kind {
+	case KindDatabase:
+		return c.DBKeep
+	case KindSite:
+		return c.SiteKeep
+	default:
+		return c.PathKeep
+	}
+}
+
+// parseShellVars 解析 KEY="value" 形式的 shell 变量赋值
+func parseShellVars(file string) map[string]string {
+	vars := map[string]string{}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return vars
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		vars[key] = value
+	}
+
+	return vars
+}
+
+// readExcludes 读取排除规则，每行一个，忽略空行和注释
+func readExcludes(file string) []string {
+	excludes := []string{}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return excludes
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		excludes = append(excludes, line)
+	}
+
+	return excludes
+}
+
+func atoiOr(s string, fallback int) int {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
@@ -0,0 +1,314 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BackupHandler 备份管理处理器
+type BackupHandler struct {
+	manager *Manager
+}
+
+// NewBackupHandler 创建处理器
+func NewBackupHandler(manager *Manager) *BackupHandler {
+	return &BackupHandler{manager: manager}
+}
+
+// RegisterRoutes 注册路由
+func (h *BackupHandler) RegisterRoutes(router fiber.Router) {
+	b := router.Group("/backups")
+	b.Get("", h.List)
+	b.Post("", h.Create)
+	b.Get("/config", h.GetConfig)
+	b.Get("/schedules", h.ListSchedules)
+	b.Post("/schedules", h.AddSchedule)
+	b.Delete("/schedules/:id", h.RemoveSchedule)
+	b.Get("/targets", h.ListTargets)
+	b.Post("/targets", h.SaveTarget)
+	b.Delete("/targets/:name", h.RemoveTarget)
+	b.Post("/targets/:name/test", h.TestTarget)
+	b.Get("/targets/:name/files", h.ListRemote)
+	b.Post("/targets/:name/prune", h.PruneRemote)
+	b.Get("/:kind/:file/download", h.Download)
+	b.Post("/:kind/:file/restore", h.Restore)
+	b.Post("/:kind/:file/upload", h.Upload)
+	b.Delete("/:kind/:file", h.Delete)
+}
+
+// List 列出备份
+func (h *BackupHandler) List(c *fiber.Ctx) error {
+	entries, err := h.manager.List(c.Query("kind"), c.Query("name"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   entries,
+	})
+}
+
+// Create 立即备份
+func (h *BackupHandler) Create(c *fiber.Ctx) error {
+	var req struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+		Keep int    `json:"keep"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	var entry *Entry
+	var err error
+	switch req.Kind {
+	case KindSite:
+		entry, err = h.manager.BackupSite(req.Name, req.Keep)
+	case KindPath:
+		entry, err = h.manager.BackupPath(req.Name, req.Keep)
+	case KindDatabase:
+		entry, err = h.manager.BackupDatabase(req.Name, req.Keep)
+	default:
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的备份类型"})
+	}
+
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "备份失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "备份成功",
+		"data":    entry,
+	})
+}
+
+// GetConfig 获取备份配置（不含 FTP 密码）
+func (h *BackupHandler) GetConfig(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   h.manager.Config(),
+	})
+}
+
+// Download 下载备份文件
+func (h *BackupHandler) Download(c *fiber.Ctx) error {
+	entry, err := h.manager.Get(c.Params("kind"), c.Params("file"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "备份不存在"})
+	}
+
+	return c.Download(entry.Path)
+}
+
+// Delete 删除备份文件
+func (h *BackupHandler) Delete(c *fiber.Ctx) error {
+	if err := h.manager.Delete(c.Params("kind"), c.Params("file")); err != nil {
+		if os.IsNotExist(err) {
+			return c.Status(404).JSON(fiber.Map{"status": false, "message": "备份不存在"})
+		}
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "备份已删除"})
+}
+
+// Restore 恢复备份
+func (h *BackupHandler) Restore(c *fiber.Ctx) error {
+	var req struct {
+		Target string `json:"target"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+		}
+	}
+
+	if err := h.manager.Restore(c.Params("kind"), c.Params("file"), req.Target); err != nil {
+		if os.IsNotExist(err) {
+			return c.Status(404).JSON(fiber.Map{"status": false, "message": "备份不存在"})
+		}
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "恢复失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "恢复成功"})
+}
+
+// ListSchedules 列出备份计划
+func (h *BackupHandler) ListSchedules(c *fiber.Ctx) error {
+	schedules, err := h.manager.ListSchedules()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取备份计划失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   schedules,
+	})
+}
+
+// AddSchedule 添加备份计划
+func (h *BackupHandler) AddSchedule(c *fiber.Ctx) error {
+	var req Schedule
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	if err := h.manager.AddSchedule(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "备份计划已添加"})
+}
+
+// RemoveSchedule 删除备份计划
+func (h *BackupHandler) RemoveSchedule(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的 ID"})
+	}
+
+	if err := h.manager.RemoveSchedule(id); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "备份计划已删除"})
+}
+
+// ListTargets 列出远程备份目标（密钥已隐藏）
+func (h *BackupHandler) ListTargets(c *fiber.Ctx) error {
+	targets, err := h.manager.ListTargets()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	data := make([]TargetConfig, 0, len(targets))
+	for _, t := range targets {
+		data = append(data, t.Redacted())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   data,
+	})
+}
+
+// SaveTarget 新增或更新远程备份目标
+func (h *BackupHandler) SaveTarget(c *fiber.Ctx) error {
+	var req TargetConfig
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	if err := h.manager.SaveTarget(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "备份目标已保存"})
+}
+
+// RemoveTarget 删除远程备份目标
+func (h *BackupHandler) RemoveTarget(c *fiber.Ctx) error {
+	if err := h.manager.RemoveTarget(c.Params("name")); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "备份目标已删除"})
+}
+
+// TestTarget 测试远程目标连接
+func (h *BackupHandler) TestTarget(c *fiber.Ctx) error {
+	target, _, err := h.manager.GetTarget(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	if err := target.Test(); err != nil {
+		return c.JSON(fiber.Map{"status": false, "message": "连接失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "连接成功"})
+}
+
+// ListRemote 列出远程目标上的备份
+func (h *BackupHandler) ListRemote(c *fiber.Ctx) error {
+	kind := c.Query("kind", KindSite)
+	if !isValidKind(kind) {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的备份类型"})
+	}
+
+	target, _, err := h.manager.GetTarget(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	files, err := target.List(kind)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取远程列表失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   files,
+	})
+}
+
+// PruneRemote 按保留份数清理远程旧备份
+func (h *BackupHandler) PruneRemote(c *fiber.Ctx) error {
+	var req struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+		Keep int    `json:"keep"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	if !isValidKind(req.Kind) || req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "请指定备份类型和名称"})
+	}
+
+	target, cfg, err := h.manager.GetTarget(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	keep := req.Keep
+	if keep <= 0 {
+		keep = cfg.Keep
+	}
+	if keep <= 0 {
+		keep = h.manager.Config().Keep(req.Kind)
+	}
+
+	removed, err := PruneRemote(target, req.Kind, req.Name, keep)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "清理失败: " + err.Error(), "data": removed})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": fmt.Sprintf("已清理 %d 份远程备份", len(removed)),
+		"data":    removed,
+	})
+}
+
+// Upload 将已有备份推送到指定目标
+func (h *BackupHandler) Upload(c *fiber.Ctx) error {
+	result, err := h.manager.PushTo(c.Params("kind"), c.Params("file"), c.Query("target"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	if !result.Success {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "上传失败: " + result.Error, "data": result})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "上传成功",
+		"data":    result,
+	})
+}
@@ -0,0 +1,431 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"site_manager_panel/internal/database"
+)
+
+// 备份类型，对应 BACKUP_DIR 下的子目录
+const (
+	KindDatabase = "database"
+	KindSite     = "site"
+	KindPath     = "path"
+)
+
+const timeLayout = "20060102_150405"
+
+// 备份文件名: <name>_<YYYYmmdd_HHMMSS>.sql.gz / .tar.gz
+var fileRegex = regexp.MustCompile(`^(.+)_(\d{8}_\d{6})\.(sql\.gz|tar\.gz)$`)
+
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Entry 备份文件
+type Entry struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	File      string    `json:"file"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source,omitempty"` // 目录备份的原路径，面板创建的备份才有
+}
+
+// sourceSuffix 目录备份旁记录原路径的文件后缀，不匹配 fileRegex，不会被当作备份列出
+const sourceSuffix = ".source"
+
+// Manager 备份目录管理，与 backup_cron.sh 共享同一目录结构和保留规则
+type Manager struct {
+	cfg         *Config
+	db          database.Client
+	sitesDir    string
+	cronFile    string
+	targetsFile string
+	mu          sync.Mutex
+}
+
+// NewManager 创建备份管理器
+func NewManager(cfg *Config, db database.Client) *Manager {
+	return &Manager{
+		cfg:         cfg,
+		db:          db,
+		sitesDir:    "/www/wwwroot",
+		cronFile:    DefaultCronFile,
+		targetsFile: DefaultTargetsFile,
+	}
+}
+
+// Config 返回当前配置
+func (m *Manager) Config() *Config {
+	return m.cfg
+}
+
+func isValidKind(kind string) bool {
+	return kind == KindDatabase || kind == KindSite || kind == KindPath
+}
+
+func (m *Manager) kindDir(kind string) string {
+	return filepath.Join(m.cfg.BackupDir, kind)
+}
+
+// parseEntry 从文件名解析备份信息
+func parseEntry(kind, dir, file string) (*Entry, bool) {
+	matches := fileRegex.FindStringSubmatch(file)
+	if matches == nil {
+		return nil, false
+	}
+
+	path := filepath.Join(dir, file)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+
+	createdAt, err := time.ParseInLocation(timeLayout, matches[2], time.Local)
+	if err != nil {
+		createdAt = info.ModTime()
+	}
+
+	entry := &Entry{
+		Kind:      kind,
+		Name:      matches[1],
+		File:      file,
+		Path:      path,
+		Size:      info.Size(),
+		CreatedAt: createdAt,
+	}
+	if kind == KindPath {
+		if source, err := os.ReadFile(path + sourceSuffix); err == nil {
+			entry.Source = strings.TrimSpace(string(source))
+		}
+	}
+	return entry, true
+}
+
+// List 列出备份，kind/name 为空表示不过滤，按时间倒序
+func (m *Manager) List(kind, name string) ([]Entry, error) {
+	kinds := []string{KindDatabase, KindSite, KindPath}
+	if kind != "" {
+		if !isValidKind(kind) {
+			return nil, fmt.Errorf("无效的备份类型: %s", kind)
+		}
+		kinds = []string{kind}
+	}
+
+	entries := []Entry{}
+	for _, k := range kinds {
+		dir := m.kindDir(k)
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			entry, ok := parseEntry(k, dir, f.Name())
+			if !ok || (name != "" && entry.Name != name) {
+				continue
+			}
+			entries = append(entries, *entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// Get 按类型和文件名查找备份，防止路径穿越
+func (m *Manager) Get(kind, file string) (*Entry, error) {
+	if !isValidKind(kind) {
+		return nil, fmt.Errorf("无效的备份类型: %s", kind)
+	}
+	if file != filepath.Base(file) {
+		return nil, fmt.Errorf("无效的文件名")
+	}
+
+	entry, ok := parseEntry(kind, m.kindDir(kind), file)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return entry, nil
+}
+
+// Delete 删除备份文件
+func (m *Manager) Delete(kind, file string) error {
+	entry, err := m.Get(kind, file)
+	if err != nil {
+		return err
+	}
+	os.Remove(entry.Path + sourceSuffix)
+	return os.Remove(entry.Path)
+}
+
+// Prune 按保留份数清理旧备份（同 backup_cron.sh 的 cleanup），返回被删除的文件
+func (m *Manager) Prune(kind, name string, keep int) ([]string, error) {
+	if keep <= 0 {
+		keep = m.cfg.Keep(kind)
+	}
+
+	entries, err := m.List(kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	for i := keep; i < len(entries); i++ {
+		if err := os.Remove(entries[i].Path); err == nil {
+			os.Remove(entries[i].Path + sourceSuffix)
+			removed = append(removed, entries[i].File)
+		}
+	}
+	return removed, nil
+}
+
+func (m *Manager) newFile(kind, name, ext string) (string, error) {
+	dir := m.kindDir(kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s_%s.%s", name, time.Now().Format(timeLayout), ext)), nil
+}
+
+func (m *Manager) excludeArgs() []string {
+	args := []string{}
+	for _, e := range m.cfg.Excludes {
+		args = append(args, "--exclude="+e)
+	}
+	return args
+}
+
+// archive 打包 parent/name 到 kind 目录，并执行保留策略
+func (m *Manager) archive(kind, parent, name string, keep int) (*Entry, error) {
+	file, err := m.newFile(kind, name, "tar.gz")
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"-czf", file}, m.excludeArgs()...)
+	args = append(args, "-C", parent, name)
+	var stderr bytes.Buffer
+	cmd := exec.Command("tar", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(file)
+		return nil, fmt.Errorf("打包失败: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return m.finish(kind, name, file, keep)
+}
+
+// finish 校验备份文件、清理旧备份并推送到远程目标
+func (m *Manager) finish(kind, name, file string, keep int) (*Entry, error) {
+	entry, ok := parseEntry(kind, filepath.Dir(file), filepath.Base(file))
+	if !ok || entry.Size == 0 {
+		os.Remove(file)
+		return nil, fmt.Errorf("备份文件为空")
+	}
+
+	m.Prune(kind, name, keep)
+	m.Push(entry, keep)
+	return entry, nil
+}
+
+// BackupSite 备份 wwwroot 下的站点目录
+func (m *Manager) BackupSite(name string, keep int) (*Entry, error) {
+	if !nameRegex.MatchString(name) {
+		return nil, fmt.Errorf("无效的站点名: %s", name)
+	}
+	if info, err := os.Stat(filepath.Join(m.sitesDir, name)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("网站不存在: %s", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.archive(KindSite, m.sitesDir, name, keep)
+}
+
+// checkBackupPath 目录备份只能备份站点目录下的目录，或 backup.conf 中配置的 DEFAULT_BACKUP_PATH。
+// 备份文件可以下载，不加限制时可借此读取 /etc、/root 等任意目录
+func (m *Manager) checkBackupPath(target string) error {
+	if m.cfg.DefaultBackupPath != "" && target == filepath.Clean(m.cfg.DefaultBackupPath) {
+		return nil
+	}
+	if within(target, m.sitesDir) && within(resolvePath(target), resolvePath(m.sitesDir)) {
+		return nil
+	}
+	return fmt.Errorf("只能备份站点目录 %s 下的目录", m.sitesDir)
+}
+
+// BackupPath 备份站点目录下的目录，target 为空时使用 DEFAULT_BACKUP_PATH
+func (m *Manager) BackupPath(target string, keep int) (*Entry, error) {
+	if target == "" {
+		target = m.cfg.DefaultBackupPath
+	}
+	target = filepath.Clean(target)
+	if !filepath.IsAbs(target) || target == "/" {
+		return nil, fmt.Errorf("无效的目录: %s", target)
+	}
+	if err := m.checkBackupPath(target); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(target); err != nil {
+		return nil, fmt.Errorf("目录不存在: %s", target)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, err := m.archive(KindPath, filepath.Dir(target), filepath.Base(target), keep)
+	if err != nil {
+		return nil, err
+	}
+	// 记录原路径，恢复时只允许回到原位置或站点目录
+	if err := os.WriteFile(entry.Path+sourceSuffix, []byte(target+"\n"), 0644); err != nil {
+		return nil, err
+	}
+	entry.Source = target
+	return entry, nil
+}
+
+// within path 是否为 root 本身或其下的路径
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// resolvePath 解析路径中的符号链接，末尾不存在的部分原样保留
+func resolvePath(path string) string {
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if dir == filepath.Dir(dir) {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// restoreDir 站点和目录备份的解压目录。站点备份只能解压到站点目录之下；
+// 目录备份还可以解压回原路径的上级目录，没有记录原路径（如 backup_cron.sh 创建的备份）时只能解压到站点目录之下
+func (m *Manager) restoreDir(entry *Entry, target string) (string, error) {
+	origin := ""
+	if entry.Source != "" {
+		origin = filepath.Dir(entry.Source)
+	}
+	if target == "" {
+		switch {
+		case entry.Kind == KindSite:
+			return m.sitesDir, nil
+		case origin != "":
+			return origin, nil
+		}
+		return "", fmt.Errorf("请指定恢复目录")
+	}
+
+	target = filepath.Clean(target)
+	if !filepath.IsAbs(target) {
+		return "", fmt.Errorf("无效的目录: %s", target)
+	}
+	// 按解析符号链接后的路径判断，站点目录中指向其他位置的链接不能绕过限制
+	resolved, sites := resolvePath(target), resolvePath(m.sitesDir)
+	if within(target, m.sitesDir) && within(resolved, sites) {
+		return target, nil
+	}
+	if origin != "" && target == origin {
+		return target, nil
+	}
+	if origin != "" {
+		return "", fmt.Errorf("只能恢复到原位置 %s 或站点目录 %s 下", origin, m.sitesDir)
+	}
+	return "", fmt.Errorf("只能恢复到站点目录 %s 下", m.sitesDir)
+}
+
+// BackupDatabase 导出数据库为 .sql.gz
+func (m *Manager) BackupDatabase(name string, keep int) (*Entry, error) {
+	if m.db == nil {
+		return nil, fmt.Errorf("数据库未配置")
+	}
+	if !nameRegex.MatchString(name) {
+		return nil, fmt.Errorf("无效的数据库名: %s", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := m.newFile(KindDatabase, name, "sql.gz")
+	if err != nil {
+		return nil, err
+	}
+	if err := database.ExportToFile(m.db, name, file); err != nil {
+		return nil, err
+	}
+	return m.finish(KindDatabase, name, file, keep)
+}
+
+// Restore 恢复备份
+//   - database: 导入到 target 数据库（默认原库名）
+//   - site: 解压到 wwwroot（target 可指定 wwwroot 下的其他父目录）
+//   - path: 解压到原路径的上级目录（默认）或 wwwroot 下的 target 目录
+func (m *Manager) Restore(kind, file, target string) error {
+	entry, err := m.Get(kind, file)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch kind {
+	case KindDatabase:
+		if m.db == nil {
+			return fmt.Errorf("数据库未配置")
+		}
+		if target == "" {
+			target = entry.Name
+		}
+		if !nameRegex.MatchString(target) {
+			return fmt.Errorf("无效的数据库名: %s", target)
+		}
+		f, err := os.Open(entry.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		if err := m.db.CreateDatabase(target); err != nil {
+			return err
+		}
+		return m.db.Import(target, gz)
+
+	default:
+		target, err := m.restoreDir(entry, target)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command("tar", "-xzf", entry.Path, "-C", target)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("解压失败: %s", strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,310 @@
+package backup
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"site_manager_panel/internal/database"
+)
+
+type fakeDB struct {
+	data map[string]string
+}
+
+func (f *fakeDB) ListDatabases() ([]database.Database, error)      { return nil, nil }
+func (f *fakeDB) CreateDatabase(name string) error                 { return nil }
+func (f *fakeDB) DropDatabase(name string) error                   { return nil }
+func (f *fakeDB) ListUsers() ([]database.User, error)              { return nil, nil }
+func (f *fakeDB) CreateUser(username, host, password string) error { return nil }
+func (f *fakeDB) DropUser(username, host string) error             { return nil }
+func (f *fakeDB) Grant(database, username, host string) error      { return nil }
+func (f *fakeDB) Export(name string, w io.Writer) error {
+	_, err := io.WriteString(w, f.data[name])
+	return err
+}
+func (f *fakeDB) Import(name string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	f.data[name] = string(b)
+	return err
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeDB) {
+	root := t.TempDir()
+	cfg := LoadConfig(filepath.Join(root, "missing.conf"), filepath.Join(root, "missing_exclude.conf"))
+	cfg.BackupDir = filepath.Join(root, "backup")
+
+	db := &fakeDB{data: map[string]string{}}
+	m := NewManager(cfg, db)
+	m.sitesDir = filepath.Join(root, "wwwroot")
+	m.cronFile = filepath.Join(root, "cron")
+	m.targetsFile = filepath.Join(root, "backup_targets.json")
+	os.MkdirAll(m.sitesDir, 0755)
+	return m, db
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "backup.conf")
+	exclude := filepath.Join(dir, "backup_exclude.conf")
+
+	os.WriteFile(conf, []byte(`# 备份配置
+BACKUP_DIR="/data/backup"
+DB_KEEP=10
+SITE_KEEP=3
+FTP_ENABLED=true
+FTP_HOST="ftp.example.com"
+FTP_PORT=2100
+FTP_PASS='p@ss word'
+`), 0644)
+	os.WriteFile(exclude, []byte("# 排除\nnode_modules\n\n*.log\n"), 0644)
+
+	cfg := LoadConfig(conf, exclude)
+	if cfg.BackupDir != "/data/backup" || cfg.DBKeep != 10 || cfg.SiteKeep != 3 || cfg.PathKeep != 7 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if !cfg.FTP.Enabled || cfg.FTP.Host != "ftp.example.com" || cfg.FTP.Port != 2100 || cfg.FTP.Pass != "p@ss word" {
+		t.Errorf("Unexpected FTP config: %+v", cfg.FTP)
+	}
+	if len(cfg.Excludes) != 2 || cfg.Excludes[0] != "node_modules" || cfg.Excludes[1] != "*.log" {
+		t.Errorf("Unexpected excludes: %v", cfg.Excludes)
+	}
+}
+
+func TestListAndPrune(t *testing.T) {
+	m, _ := newTestManager(t)
+	dir := filepath.Join(m.cfg.BackupDir, KindSite)
+	os.MkdirAll(dir, 0755)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		name := "example.com_" + base.Add(time.Duration(i)*time.Hour).Format(timeLayout) + ".tar.gz"
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "other.com_20240101_000000.tar.gz"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("x"), 0644)
+
+	entries, err := m.List(KindSite, "example.com")
+	if err != nil || len(entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %d (%v)", len(entries), err)
+	}
+	if !entries[0].CreatedAt.After(entries[4].CreatedAt) {
+		t.Error("Entries should be sorted newest first")
+	}
+
+	removed, _ := m.Prune(KindSite, "example.com", 2)
+	if len(removed) != 3 {
+		t.Fatalf("Expected 3 removed, got %v", removed)
+	}
+	entries, _ = m.List(KindSite, "")
+	if len(entries) != 3 {
+		t.Errorf("Expected 3 remaining entries, got %d", len(entries))
+	}
+	if _, err := m.Get(KindSite, "other.com_20240101_000000.tar.gz"); err != nil {
+		t.Error("Other site backups must not be pruned")
+	}
+}
+
+func TestGetRejectsTraversal(t *testing.T) {
+	m, _ := newTestManager(t)
+	if _, err := m.Get(KindSite, "../../etc/passwd"); err == nil {
+		t.Error("Expected error for path traversal")
+	}
+	if _, err := m.Get("etc", "x_20240101_000000.tar.gz"); err == nil {
+		t.Error("Expected error for invalid kind")
+	}
+}
+
+func TestSiteBackupAndRestore(t *testing.T) {
+	m, _ := newTestManager(t)
+	m.cfg.Excludes = []string{"node_modules", "*.log"}
+
+	site := filepath.Join(m.sitesDir, "example.com")
+	os.MkdirAll(filepath.Join(site, "node_modules"), 0755)
+	os.WriteFile(filepath.Join(site, "index.html"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(site, "debug.log"), []byte("log"), 0644)
+	os.WriteFile(filepath.Join(site, "node_modules", "pkg.js"), []byte("js"), 0644)
+
+	entry, err := m.BackupSite("example.com", 0)
+	if err != nil {
+		t.Fatalf("BackupSite failed: %v", err)
+	}
+	if entry.Kind != KindSite || entry.Name != "example.com" || entry.Size == 0 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	os.RemoveAll(site)
+	if err := m.Restore(KindSite, entry.File, ""); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(site, "index.html"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Restored content mismatch: %q %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(site, "debug.log")); err == nil {
+		t.Error("Excluded *.log should not be in backup")
+	}
+	if _, err := os.Stat(filepath.Join(site, "node_modules")); err == nil {
+		t.Error("Excluded node_modules should not be in backup")
+	}
+}
+
+func TestRestoreTargets(t *testing.T) {
+	m, _ := newTestManager(t)
+	root := filepath.Dir(m.sitesDir)
+	site := filepath.Join(m.sitesDir, "example.com")
+	os.MkdirAll(site, 0755)
+	os.WriteFile(filepath.Join(site, "index.html"), []byte("hello"), 0644)
+	data := filepath.Join(root, "data", "app")
+	os.MkdirAll(data, 0755)
+	os.WriteFile(filepath.Join(data, "config.yml"), []byte("key: value"), 0644)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.Symlink(filepath.Join(root, "etc"), filepath.Join(site, "etc"))
+	m.cfg.DefaultBackupPath = data // backup.conf 中配置的目录可以在站点目录之外
+
+	siteEntry, err := m.BackupSite("example.com", 0)
+	if err != nil {
+		t.Fatalf("BackupSite failed: %v", err)
+	}
+	pathEntry, err := m.BackupPath("", 0)
+	if err != nil {
+		t.Fatalf("BackupPath failed: %v", err)
+	}
+	if got, _ := m.Get(KindPath, pathEntry.File); got.Source != data {
+		t.Errorf("Expected original path to be recorded, got %+v", got)
+	}
+
+	for _, tt := range []struct {
+		kind, file, target string
+	}{
+		{KindSite, siteEntry.File, "/"},
+		{KindSite, siteEntry.File, filepath.Join(root, "etc")},
+		{KindSite, siteEntry.File, m.sitesDir + "/../etc"},
+		{KindSite, siteEntry.File, filepath.Join(site, "etc")},
+		{KindSite, siteEntry.File, "relative"},
+		{KindPath, pathEntry.File, "/etc"},
+		{KindPath, pathEntry.File, filepath.Join(root, "data", "other")},
+	} {
+		if err := m.Restore(tt.kind, tt.file, tt.target); err == nil {
+			t.Errorf("%s %s: expected restore to be rejected", tt.kind, tt.target)
+		}
+	}
+	if files, _ := os.ReadDir(filepath.Join(root, "etc")); len(files) != 0 {
+		t.Errorf("Expected nothing to be written outside the allowed directories, got %v", files)
+	}
+
+	// 目录备份默认恢复到原位置，也可以恢复到站点目录下
+	os.RemoveAll(data)
+	if err := m.Restore(KindPath, pathEntry.File, ""); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(data, "config.yml")); string(content) != "key: value" {
+		t.Errorf("Restored content mismatch: %q", content)
+	}
+	if err := m.Restore(KindPath, pathEntry.File, site); err != nil {
+		t.Fatalf("Restore into site failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(site, "app", "config.yml")); err != nil {
+		t.Errorf("Expected restored directory in site: %v", err)
+	}
+
+	// 没有原路径记录的目录备份只能恢复到站点目录下
+	os.Remove(pathEntry.Path + sourceSuffix)
+	if err := m.Restore(KindPath, pathEntry.File, filepath.Join(root, "data")); err == nil {
+		t.Error("Expected restore without recorded source to be rejected")
+	}
+	if err := m.Delete(KindPath, pathEntry.File); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupPathConfined(t *testing.T) {
+	m, _ := newTestManager(t)
+	root := filepath.Dir(m.sitesDir)
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.WriteFile(filepath.Join(root, "etc", "shadow"), []byte("root:x"), 0600)
+	site := filepath.Join(m.sitesDir, "example.com")
+	os.MkdirAll(filepath.Join(site, "uploads"), 0755)
+	os.WriteFile(filepath.Join(site, "uploads", "a.jpg"), []byte("jpg"), 0644)
+	os.Symlink(filepath.Join(root, "etc"), filepath.Join(site, "etc"))
+
+	for _, target := range []string{"/etc", filepath.Join(root, "etc"), m.sitesDir + "/../etc", filepath.Join(site, "etc")} {
+		if _, err := m.BackupPath(target, 0); err == nil {
+			t.Errorf("%s: expected backup to be rejected", target)
+		}
+		if err := m.AddSchedule(Schedule{Kind: KindPath, Name: target}); err == nil {
+			t.Errorf("%s: expected schedule to be rejected", target)
+		}
+	}
+	if _, err := m.BackupPath(filepath.Join(site, "uploads"), 0); err != nil {
+		t.Errorf("BackupPath failed: %v", err)
+	}
+}
+
+func TestDatabaseBackupAndRestore(t *testing.T) {
+	m, db := newTestManager(t)
+	db.data["shop"] = "INSERT INTO t VALUES (1);"
+
+	entry, err := m.BackupDatabase("shop", 0)
+	if err != nil {
+		t.Fatalf("BackupDatabase failed: %v", err)
+	}
+	if !strings.HasSuffix(entry.File, ".sql.gz") || filepath.Dir(entry.Path) != filepath.Join(m.cfg.BackupDir, "database") {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	if err := m.Restore(KindDatabase, entry.File, "shop_copy"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if db.data["shop_copy"] != "INSERT INTO t VALUES (1);" {
+		t.Errorf("Restored content mismatch: %q", db.data["shop_copy"])
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	if err := m.AddSchedule(Schedule{Kind: KindSite, Name: "example.com", Keep: 5, Hour: "2"}); err != nil {
+		t.Fatalf("AddSchedule failed: %v", err)
+	}
+	if err := m.AddSchedule(Schedule{Kind: KindDatabase}); err != nil {
+		t.Fatalf("AddSchedule failed: %v", err)
+	}
+
+	invalid := []Schedule{
+		{Kind: "rm"},
+		{Kind: KindSite, Name: "a;b"},
+		{Kind: KindPath, Name: "/opt/$(id)"},
+		{Kind: KindPath, Name: "/www/wwwroot/a\n* * * * * root id"},
+		{Kind: KindSite, Name: "example.com", Hour: "2\n* * * * * root id #"},
+		{Kind: KindSite, Minute: "* * * * *"},
+		{Kind: KindDatabase, Keep: 3},
+	}
+	for _, s := range invalid {
+		if err := m.AddSchedule(s); err == nil {
+			t.Errorf("Expected error for %+v", s)
+		}
+	}
+
+	content, _ := os.ReadFile(m.cronFile)
+	if !strings.Contains(string(content), "0 2 * * * root "+BackupScript+" site example.com 5 >/dev/null 2>&1") {
+		t.Errorf("Unexpected cron file:\n%s", content)
+	}
+
+	schedules, _ := m.ListSchedules()
+	if len(schedules) != 2 || schedules[0].Name != "example.com" || schedules[0].Keep != 5 || schedules[1].Kind != KindDatabase {
+		t.Fatalf("Unexpected schedules: %+v", schedules)
+	}
+
+	if err := m.RemoveSchedule(0); err != nil {
+		t.Fatalf("RemoveSchedule failed: %v", err)
+	}
+	schedules, _ = m.ListSchedules()
+	if len(schedules) != 1 || schedules[0].Kind != KindDatabase {
+		t.Errorf("Unexpected schedules after remove: %+v", schedules)
+	}
+}
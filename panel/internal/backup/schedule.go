@@ -0,0 +1,190 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultCronFile 面板管理的备份计划任务
+	DefaultCronFile = "/etc/cron.d/site-manager-backup"
+	// BackupScript 计划任务调用的备份脚本
+	BackupScript = "/opt/site_manager/bin/backup_cron.sh"
+)
+
+var cronFieldRegex = regexp.MustCompile(`^[\d\*,\-/]+$`)
+
+// Schedule 备份计划
+type Schedule struct {
+	ID      int    `json:"id"`
+	Kind    string `json:"kind"` // database/site/path/all
+	Name    string `json:"name"` // 为空表示全部
+	Keep    int    `json:"keep"`
+	Minute  string `json:"minute"`
+	Hour    string `json:"hour"`
+	Day     string `json:"day"`
+	Weekday string `json:"weekday"`
+}
+
+// Validate 校验并填充默认值
+func (s *Schedule) Validate() error {
+	// 各字段写入 cron.d 的同一行，换行等控制字符可以注入新的计划任务
+	for _, f := range []string{s.Kind, s.Name, s.Minute, s.Hour, s.Day, s.Weekday} {
+		if strings.IndexFunc(f, unicode.IsControl) >= 0 {
+			return fmt.Errorf("计划内容不能包含控制字符")
+		}
+	}
+	if s.Minute == "" {
+		s.Minute = "0"
+	}
+	if s.Hour == "" {
+		s.Hour = "3"
+	}
+	if s.Day == "" {
+		s.Day = "*"
+	}
+	if s.Weekday == "" {
+		s.Weekday = "*"
+	}
+
+	for _, f := range []string{s.Minute, s.Hour, s.Day, s.Weekday} {
+		if !cronFieldRegex.MatchString(f) {
+			return fmt.Errorf("无效的时间字段: %s", f)
+		}
+	}
+
+	switch s.Kind {
+	case KindDatabase, KindSite:
+		if s.Name != "" && !nameRegex.MatchString(s.Name) {
+			return fmt.Errorf("无效的名称: %s", s.Name)
+		}
+	case KindPath:
+		if s.Name != "" && (!strings.HasPrefix(s.Name, "/") || strings.ContainsAny(s.Name, " \t'\"`$;&|<>\\")) {
+			return fmt.Errorf("无效的目录: %s", s.Name)
+		}
+	case "all":
+		s.Name = ""
+		s.Keep = 0
+	default:
+		return fmt.Errorf("无效的备份类型: %s", s.Kind)
+	}
+
+	if s.Keep < 0 {
+		return fmt.Errorf("保留份数无效")
+	}
+	if s.Keep > 0 && s.Name == "" && s.Kind != "all" {
+		// backup_cron.sh 的位置参数要求先有名称才能指定保留份数
+		return fmt.Errorf("指定保留份数时必须指定名称")
+	}
+	return nil
+}
+
+// line 生成 cron.d 行
+func (s *Schedule) line() string {
+	args := []string{BackupScript, s.Kind}
+	if s.Name != "" {
+		args = append(args, s.Name)
+		if s.Keep > 0 {
+			args = append(args, strconv.Itoa(s.Keep))
+		}
+	}
+	return fmt.Sprintf("%s %s %s * %s root %s >/dev/null 2>&1",
+		s.Minute, s.Hour, s.Day, s.Weekday, strings.Join(args, " "))
+}
+
+// parseScheduleLine 解析 cron.d 行，非备份任务返回 false
+func parseScheduleLine(line string) (Schedule, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 8 || strings.HasPrefix(fields[0], "#") || fields[6] != BackupScript {
+		return Schedule{}, false
+	}
+
+	s := Schedule{
+		Minute:  fields[0],
+		Hour:    fields[1],
+		Day:     fields[2],
+		Weekday: fields[4],
+		Kind:    fields[7],
+	}
+	rest := fields[8:]
+	for i, f := range rest {
+		if strings.HasPrefix(f, ">") || strings.HasPrefix(f, "2>") {
+			rest = rest[:i]
+			break
+		}
+	}
+	if len(rest) > 0 {
+		s.Name = rest[0]
+	}
+	if len(rest) > 1 {
+		s.Keep, _ = strconv.Atoi(rest[1])
+	}
+	return s, true
+}
+
+// ListSchedules 读取备份计划
+func (m *Manager) ListSchedules() ([]Schedule, error) {
+	schedules := []Schedule{}
+
+	content, err := os.ReadFile(m.cronFile)
+	if os.IsNotExist(err) {
+		return schedules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if s, ok := parseScheduleLine(line); ok {
+			s.ID = len(schedules)
+			schedules = append(schedules, s)
+		}
+	}
+	return schedules, nil
+}
+
+// AddSchedule 添加备份计划
+func (m *Manager) AddSchedule(s Schedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if s.Kind == KindPath && s.Name != "" {
+		if err := m.checkBackupPath(filepath.Clean(s.Name)); err != nil {
+			return err
+		}
+	}
+
+	schedules, err := m.ListSchedules()
+	if err != nil {
+		return err
+	}
+	return m.writeSchedules(append(schedules, s))
+}
+
+// RemoveSchedule 删除备份计划
+func (m *Manager) RemoveSchedule(id int) error {
+	schedules, err := m.ListSchedules()
+	if err != nil {
+		return err
+	}
+	if id < 0 || id >= len(schedules) {
+		return fmt.Errorf("备份计划不存在")
+	}
+	return m.writeSchedules(append(schedules[:id], schedules[id+1:]...))
+}
+
+func (m *Manager) writeSchedules(schedules []Schedule) error {
+	var b strings.Builder
+	b.WriteString("# Site Manager 备份计划（由面板管理，请勿手动修改）\n")
+	b.WriteString("SHELL=/bin/bash\n")
+	b.WriteString("PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin\n")
+	for _, s := range schedules {
+		b.WriteString(s.line() + "\n")
+	}
+	return os.WriteFile(m.cronFile, []byte(b.String()), 0644)
+}
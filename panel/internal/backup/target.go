@@ -0,0 +1,368 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTargetsFile 远程备份目标配置（与 dns_accounts.json 等放在同一目录）
+const DefaultTargetsFile = "/opt/site_manager/config/backup_targets.json"
+
+// ConfTargetName backup.conf 中 FTP 配置对应的内置目标
+const ConfTargetName = "default"
+
+// 远程目标类型
+const (
+	TargetFTP   = "ftp"
+	TargetSFTP  = "sftp"
+	TargetS3    = "s3"
+	TargetLocal = "local"
+)
+
+var targetNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// RemoteFile 远程备份文件
+type RemoteFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// BackupTarget 远程备份目标
+// dir 为相对目标根路径的子目录（database/site/path），与 backup_cron.sh 的 FTP 目录结构一致
+type BackupTarget interface {
+	Test() error
+	Upload(localPath, dir string) error
+	List(dir string) ([]RemoteFile, error)
+	Delete(dir, name string) error
+}
+
+// TargetConfig 远程目标配置
+type TargetConfig struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+	Keep    int    `json:"keep"` // 远程保留份数，0 表示与本地一致
+	Path    string `json:"path"` // 远程根路径 / 本地目录 / S3 前缀
+
+	// FTP / SFTP
+	Host       string `json:"host,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	TLS        bool   `json:"tls,omitempty"`         // FTP 显式 TLS (AUTH TLS)
+	PrivateKey string `json:"private_key,omitempty"` // SFTP 私钥 (PEM)
+	HostKey    string `json:"host_key,omitempty"`    // SFTP 主机公钥指纹 (SHA256:...)，必填
+
+	// S3 兼容存储
+	Endpoint  string `json:"endpoint,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	AccessKey string `json:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+	PathStyle bool   `json:"path_style,omitempty"`
+}
+
+// Redacted 返回去掉密钥的副本，用于接口输出
+func (t TargetConfig) Redacted() TargetConfig {
+	if t.Password != "" {
+		t.Password = "******"
+	}
+	if t.PrivateKey != "" {
+		t.PrivateKey = "******"
+	}
+	if t.SecretKey != "" {
+		t.SecretKey = "******"
+	}
+	return t
+}
+
+// NewTarget 根据配置创建目标
+func NewTarget(cfg TargetConfig) (BackupTarget, error) {
+	switch cfg.Type {
+	case TargetFTP:
+		if cfg.Host == "" {
+			return nil, fmt.Errorf("FTP 主机不能为空")
+		}
+		return &FTPTarget{cfg: cfg}, nil
+	case TargetSFTP:
+		if cfg.Host == "" {
+			return nil, fmt.Errorf("SFTP 主机不能为空")
+		}
+		// 不校验主机公钥时，中间人可以截获密码和备份内容
+		if !strings.HasPrefix(cfg.HostKey, "SHA256:") {
+			return nil, fmt.Errorf("SFTP 主机指纹必须为 SHA256:... 格式，可在服务器上执行 ssh-keygen -lf /etc/ssh/ssh_host_ed25519_key.pub 获取")
+		}
+		return &SFTPTarget{cfg: cfg}, nil
+	case TargetS3:
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("S3 endpoint 和 bucket 不能为空")
+		}
+		return &S3Target{cfg: cfg}, nil
+	case TargetLocal:
+		if !path.IsAbs(cfg.Path) {
+			return nil, fmt.Errorf("本地目录必须是绝对路径")
+		}
+		return &LocalTarget{dir: cfg.Path}, nil
+	default:
+		return nil, fmt.Errorf("不支持的目标类型: %s", cfg.Type)
+	}
+}
+
+// PruneRemote 按保留份数清理远程备份，规则与本地 Prune 相同
+func PruneRemote(t BackupTarget, dir, name string, keep int) ([]string, error) {
+	files, err := t.List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type remote struct {
+		name string
+		at   string
+	}
+	matched := []remote{}
+	for _, f := range files {
+		m := fileRegex.FindStringSubmatch(f.Name)
+		if m == nil || m[1] != name {
+			continue
+		}
+		matched = append(matched, remote{name: f.Name, at: m[2]})
+	}
+	// 时间戳格式可直接按字符串倒序
+	sort.Slice(matched, func(i, j int) bool { return matched[i].at > matched[j].at })
+
+	removed := []string{}
+	for i := keep; i < len(matched); i++ {
+		if err := t.Delete(dir, matched[i].name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, matched[i].name)
+	}
+	return removed, nil
+}
+
+// confTarget 由 backup.conf 的 FTP 配置生成内置目标
+func (m *Manager) confTarget() (TargetConfig, bool) {
+	ftp := m.cfg.FTP
+	if ftp.Host == "" {
+		return TargetConfig{}, false
+	}
+	return TargetConfig{
+		Name:     ConfTargetName,
+		Type:     TargetFTP,
+		Enabled:  ftp.Enabled,
+		Host:     ftp.Host,
+		Port:     ftp.Port,
+		User:     ftp.User,
+		Password: ftp.Pass,
+		Path:     ftp.Path,
+	}, true
+}
+
+func (m *Manager) loadTargets() ([]TargetConfig, error) {
+	targets := []TargetConfig{}
+	data, err := os.ReadFile(m.targetsFile)
+	if os.IsNotExist(err) {
+		return targets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", m.targetsFile, err)
+	}
+	return targets, nil
+}
+
+func (m *Manager) saveTargets(targets []TargetConfig) error {
+	data, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 含密码，仅 root 可读
+	return os.WriteFile(m.targetsFile, data, 0600)
+}
+
+// ListTargets 列出所有目标（含 backup.conf 内置 FTP）
+func (m *Manager) ListTargets() ([]TargetConfig, error) {
+	targets, err := m.loadTargets()
+	if err != nil {
+		return nil, err
+	}
+	if conf, ok := m.confTarget(); ok {
+		targets = append([]TargetConfig{conf}, targets...)
+	}
+	return targets, nil
+}
+
+// GetTarget 按名称获取目标
+func (m *Manager) GetTarget(name string) (BackupTarget, TargetConfig, error) {
+	targets, err := m.ListTargets()
+	if err != nil {
+		return nil, TargetConfig{}, err
+	}
+	for _, cfg := range targets {
+		if cfg.Name == name {
+			t, err := NewTarget(cfg)
+			return t, cfg, err
+		}
+	}
+	return nil, TargetConfig{}, fmt.Errorf("备份目标不存在: %s", name)
+}
+
+// SaveTarget 新增或更新目标；密钥字段为 "******" 或空时保留原值
+func (m *Manager) SaveTarget(cfg TargetConfig) error {
+	if !targetNameRegex.MatchString(cfg.Name) {
+		return fmt.Errorf("无效的目标名称")
+	}
+	if cfg.Name == ConfTargetName {
+		return fmt.Errorf("%s 由 backup.conf 管理，请直接修改配置文件", ConfTargetName)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	targets, err := m.loadTargets()
+	if err != nil {
+		return err
+	}
+
+	idx := -1
+	for i, t := range targets {
+		if t.Name == cfg.Name {
+			idx = i
+			break
+		}
+	}
+	if idx >= 0 {
+		old := targets[idx]
+		if cfg.Password == "" || cfg.Password == "******" {
+			cfg.Password = old.Password
+		}
+		if cfg.PrivateKey == "" || cfg.PrivateKey == "******" {
+			cfg.PrivateKey = old.PrivateKey
+		}
+		if cfg.SecretKey == "" || cfg.SecretKey == "******" {
+			cfg.SecretKey = old.SecretKey
+		}
+	}
+
+	if _, err := NewTarget(cfg); err != nil {
+		return err
+	}
+
+	if idx >= 0 {
+		targets[idx] = cfg
+	} else {
+		targets = append(targets, cfg)
+	}
+	return m.saveTargets(targets)
+}
+
+// RemoveTarget 删除目标
+func (m *Manager) RemoveTarget(name string) error {
+	if name == ConfTargetName {
+		return fmt.Errorf("%s 由 backup.conf 管理，请直接修改配置文件", ConfTargetName)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	targets, err := m.loadTargets()
+	if err != nil {
+		return err
+	}
+	for i, t := range targets {
+		if t.Name == name {
+			return m.saveTargets(append(targets[:i], targets[i+1:]...))
+		}
+	}
+	return fmt.Errorf("备份目标不存在: %s", name)
+}
+
+// PushResult 单个目标的推送结果
+type PushResult struct {
+	Target  string   `json:"target"`
+	Success bool     `json:"success"`
+	Error   string   `json:"error,omitempty"`
+	Pruned  []string `json:"pruned,omitempty"`
+}
+
+// pushTo 上传备份到单个目标并按保留份数清理远程旧备份
+func pushTo(t BackupTarget, cfg TargetConfig, entry *Entry, keep int) PushResult {
+	result := PushResult{Target: cfg.Name}
+	if err := t.Upload(entry.Path, entry.Kind); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Success = true
+
+	if cfg.Keep > 0 {
+		keep = cfg.Keep
+	}
+	pruned, err := PruneRemote(t, entry.Kind, entry.Name, keep)
+	if err != nil {
+		result.Error = "清理远程旧备份失败: " + err.Error()
+	}
+	result.Pruned = pruned
+	return result
+}
+
+// Push 推送备份到所有启用的目标；backup.conf 设置 FTP_DELETE_LOCAL=true 时上传成功后删除本地文件
+func (m *Manager) Push(entry *Entry, keep int) []PushResult {
+	if keep <= 0 {
+		keep = m.cfg.Keep(entry.Kind)
+	}
+
+	results := []PushResult{}
+	targets, err := m.ListTargets()
+	if err != nil {
+		log.Printf("[backup] 读取备份目标失败: %v", err)
+		return results
+	}
+
+	deleteLocal := false
+	for _, cfg := range targets {
+		if !cfg.Enabled {
+			continue
+		}
+		t, err := NewTarget(cfg)
+		if err != nil {
+			results = append(results, PushResult{Target: cfg.Name, Error: err.Error()})
+			continue
+		}
+		result := pushTo(t, cfg, entry, keep)
+		if !result.Success {
+			log.Printf("[backup] 上传 %s 到 %s 失败: %s", entry.File, cfg.Name, result.Error)
+		}
+		if cfg.Name == ConfTargetName && result.Success && m.cfg.FTP.DeleteLocal {
+			deleteLocal = true
+		}
+		results = append(results, result)
+	}
+
+	if deleteLocal {
+		os.Remove(entry.Path)
+	}
+	return results
+}
+
+// PushTo 推送指定备份到指定目标
+func (m *Manager) PushTo(kind, file, targetName string) (PushResult, error) {
+	entry, err := m.Get(kind, file)
+	if err != nil {
+		return PushResult{}, err
+	}
+	t, cfg, err := m.GetTarget(targetName)
+	if err != nil {
+		return PushResult{}, err
+	}
+	return pushTo(t, cfg, entry, m.cfg.Keep(kind)), nil
+}
@@ -0,0 +1,272 @@
+package backup
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const remoteTimeout = 15 * time.Second
+
+var pasvRegex = regexp.MustCompile(`(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+
+// FTPTarget FTP/FTPS 远程目标（取代 backup_cron.sh 中基于 curl 的 upload_ftp）
+type FTPTarget struct {
+	cfg TargetConfig
+}
+
+// ftpConn FTP 控制连接
+type ftpConn struct {
+	conn      net.Conn
+	text      *textproto.Conn
+	host      string
+	tlsConfig *tls.Config
+}
+
+func (t *FTPTarget) dial() (*ftpConn, error) {
+	port := t.cfg.Port
+	if port == 0 {
+		port = 21
+	}
+	addr := net.JoinHostPort(t.cfg.Host, strconv.Itoa(port))
+
+	conn, err := net.DialTimeout("tcp", addr, remoteTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("连接 FTP 失败: %w", err)
+	}
+
+	c := &ftpConn{conn: conn, text: textproto.NewConn(conn), host: t.cfg.Host}
+	conn.SetDeadline(time.Now().Add(remoteTimeout))
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if t.cfg.TLS {
+		if _, _, err := c.cmd(2, "AUTH TLS"); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("服务器不支持 AUTH TLS: %w", err)
+		}
+		c.tlsConfig = &tls.Config{
+			ServerName:         t.cfg.Host,
+			ClientSessionCache: tls.NewLRUClientSessionCache(4),
+		}
+		tlsConn := tls.Client(conn, c.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("TLS 握手失败: %w", err)
+		}
+		c.conn = tlsConn
+		c.text = textproto.NewConn(tlsConn)
+		if _, _, err := c.cmd(2, "PBSZ 0"); err != nil {
+			c.Close()
+			return nil, err
+		}
+		if _, _, err := c.cmd(2, "PROT P"); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	code, _, err := c.cmd(0, "USER %s", t.cfg.User)
+	if err == nil && code == 331 {
+		code, _, err = c.cmd(0, "PASS %s", t.cfg.Password)
+	}
+	if err == nil && code != 230 && code != 202 {
+		err = fmt.Errorf("FTP 登录失败 (%d)", code)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if _, _, err := c.cmd(2, "TYPE I"); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *ftpConn) cmd(expect int, format string, args ...interface{}) (int, string, error) {
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	return c.text.ReadResponse(expect)
+}
+
+func (c *ftpConn) Close() error {
+	c.cmd(0, "QUIT")
+	return c.conn.Close()
+}
+
+// openData 进入被动模式并建立数据连接，忽略服务器返回的 IP（NAT 环境下常常是内网地址）
+func (c *ftpConn) openData() (net.Conn, error) {
+	_, msg, err := c.cmd(2, "PASV")
+	if err != nil {
+		return nil, err
+	}
+	m := pasvRegex.FindStringSubmatch(msg)
+	if m == nil {
+		return nil, fmt.Errorf("无法解析 PASV 响应: %s", msg)
+	}
+	p1, _ := strconv.Atoi(m[5])
+	p2, _ := strconv.Atoi(m[6])
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(c.host, strconv.Itoa(p1*256+p2)), remoteTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.tlsConfig != nil {
+		return tls.Client(conn, c.tlsConfig), nil
+	}
+	return conn, nil
+}
+
+// transfer 执行一次数据传输命令
+func (c *ftpConn) transfer(fn func(net.Conn) error, format string, args ...interface{}) error {
+	data, err := c.openData()
+	if err != nil {
+		return err
+	}
+	if _, _, err := c.cmd(1, format, args...); err != nil {
+		data.Close()
+		return err
+	}
+	err = fn(data)
+	if cerr := data.Close(); err == nil {
+		err = cerr
+	}
+	if _, _, rerr := c.text.ReadResponse(2); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// mkdirAll 逐级创建目录（同 curl --ftp-create-dirs），已存在的目录忽略错误
+func (c *ftpConn) mkdirAll(dir string) {
+	current := ""
+	for _, part := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current += "/" + part
+		c.cmd(0, "MKD %s", current)
+	}
+}
+
+func (t *FTPTarget) remoteDir(dir string) string {
+	return path.Join("/", t.cfg.Path, dir)
+}
+
+// Test 登录并确认根目录可用
+func (t *FTPTarget) Test() error {
+	c, err := t.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	root := t.remoteDir("")
+	c.mkdirAll(root)
+	if _, _, err := c.cmd(2, "CWD %s", root); err != nil {
+		return fmt.Errorf("无法进入远程目录 %s: %w", root, err)
+	}
+	return nil
+}
+
+// Upload 上传文件到 <path>/<dir>/<文件名>
+func (t *FTPTarget) Upload(localPath, dir string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	c, err := t.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	remoteDir := t.remoteDir(dir)
+	c.mkdirAll(remoteDir)
+
+	return c.transfer(func(conn net.Conn) error {
+		_, err := io.Copy(conn, f)
+		return err
+	}, "STOR %s", path.Join(remoteDir, path.Base(localPath)))
+}
+
+// List 使用 NLST 列出文件名，再用 SIZE 获取大小（vsftpd 等不支持 MLSD）
+func (t *FTPTarget) List(dir string) ([]RemoteFile, error) {
+	c, err := t.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	remoteDir := t.remoteDir(dir)
+	var names []string
+	err = c.transfer(func(conn net.Conn) error {
+		data, err := io.ReadAll(conn)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if name := path.Base(strings.TrimSpace(line)); name != "" && name != "." && name != ".." && name != "/" {
+				names = append(names, name)
+			}
+		}
+		return nil
+	}, "NLST %s", remoteDir)
+
+	files := []RemoteFile{}
+	if err != nil {
+		// 目录不存在时返回空列表
+		if protoErr, ok := err.(*textproto.Error); ok && protoErr.Code >= 450 && protoErr.Code < 600 {
+			return files, nil
+		}
+		return nil, err
+	}
+
+	for _, name := range names {
+		file := RemoteFile{Name: name}
+		if _, msg, err := c.cmd(2, "SIZE %s", path.Join(remoteDir, name)); err == nil {
+			file.Size, _ = strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+		}
+		if m := fileRegex.FindStringSubmatch(name); m != nil {
+			file.ModTime, _ = time.ParseInLocation(timeLayout, m[2], time.Local)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Delete 删除远程文件
+func (t *FTPTarget) Delete(dir, name string) error {
+	if name != path.Base(name) {
+		return fmt.Errorf("无效的文件名")
+	}
+
+	c, err := t.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, _, err = c.cmd(2, "DELE %s", path.Join(t.remoteDir(dir), name))
+	return err
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalTarget 复制到另一个本地目录（如挂载的 NAS / 第二块磁盘）
+type LocalTarget struct {
+	dir string
+}
+
+// Test 检查目录可写
+func (t *LocalTarget) Test() error {
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(t.dir, ".write_test_")
+	if err != nil {
+		return fmt.Errorf("目录不可写: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Upload 复制文件，先写临时文件再重命名，避免留下不完整的备份
+func (t *LocalTarget) Upload(localPath, dir string) error {
+	destDir := filepath.Join(t.dir, dir)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest := filepath.Join(destDir, filepath.Base(localPath))
+	tmp := dest + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// List 列出目录下的文件
+func (t *LocalTarget) List(dir string) ([]RemoteFile, error) {
+	files := []RemoteFile{}
+	entries, err := os.ReadDir(filepath.Join(t.dir, dir))
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, RemoteFile{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Delete 删除文件
+func (t *LocalTarget) Delete(dir, name string) error {
+	if name != filepath.Base(name) {
+		return fmt.Errorf("无效的文件名")
+	}
+	return os.Remove(filepath.Join(t.dir, dir, name))
+}
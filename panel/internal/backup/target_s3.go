@@ -0,0 +1,335 @@
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Target S3 兼容对象存储（AWS S3 / MinIO / 各云厂商 OSS 的 S3 接口）
+type S3Target struct {
+	cfg TargetConfig
+}
+
+func (t *S3Target) region() string {
+	if t.cfg.Region == "" {
+		return "us-east-1"
+	}
+	return t.cfg.Region
+}
+
+// objectURL 生成对象地址，key 为空时表示 bucket 本身
+func (t *S3Target) objectURL(key string) (*url.URL, error) {
+	endpoint := t.cfg.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.cfg.PathStyle {
+		u.Path = "/" + t.cfg.Bucket + "/" + key
+	} else {
+		u.Host = t.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u, nil
+}
+
+func (t *S3Target) key(dir, name string) string {
+	return strings.TrimPrefix(path.Join(t.cfg.Path, dir, name), "/")
+}
+
+// do 签名并发送请求，非 2xx 时解析 S3 错误信息
+func (t *S3Target) do(method string, u *url.URL, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signer := sigV4{
+		AccessKey: t.cfg.AccessKey,
+		SecretKey: t.cfg.SecretKey,
+		Region:    t.region(),
+		Service:   "s3",
+	}
+	signer.Sign(req, payloadHash, time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var s3err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if xml.Unmarshal(data, &s3err) == nil && s3err.Code != "" {
+			return nil, fmt.Errorf("S3 %s: %s (%s)", resp.Status, s3err.Code, s3err.Message)
+		}
+		return nil, fmt.Errorf("S3 %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// Test 列出一个对象以验证凭据和 bucket 权限
+func (t *S3Target) Test() error {
+	_, _, err := t.listPage(t.key("", "")+"/", "", 1)
+	return err
+}
+
+// Upload 上传文件（计算 SHA256 作为签名载荷，兼容不接受 UNSIGNED-PAYLOAD 的实现）
+func (t *S3Target) Upload(localPath, dir string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	u, err := t.objectURL(t.key(dir, path.Base(localPath)))
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(http.MethodPut, u, f, info.Size(), hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (t *S3Target) listPage(prefix, token string, maxKeys int) (*listBucketResult, string, error) {
+	u, err := t.objectURL("")
+	if err != nil {
+		return nil, "", err
+	}
+	q := url.Values{}
+	q.Set("list-type", "2")
+	q.Set("prefix", strings.TrimPrefix(prefix, "/"))
+	if token != "" {
+		q.Set("continuation-token", token)
+	}
+	if maxKeys > 0 {
+		q.Set("max-keys", fmt.Sprint(maxKeys))
+	}
+	u.RawQuery = q.Encode()
+
+	resp, err := t.do(http.MethodGet, u, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var result listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("解析 S3 列表失败: %w", err)
+	}
+	next := ""
+	if result.IsTruncated {
+		next = result.NextContinuationToken
+	}
+	return &result, next, nil
+}
+
+// List 列出 <prefix>/<dir>/ 下的对象（不含更深层级）
+func (t *S3Target) List(dir string) ([]RemoteFile, error) {
+	prefix := t.key(dir, "") + "/"
+	files := []RemoteFile{}
+
+	token := ""
+	for {
+		page, next, err := t.listPage(prefix, token, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			files = append(files, RemoteFile{Name: name, Size: obj.Size, ModTime: obj.LastModified})
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	return files, nil
+}
+
+// Delete 删除对象
+func (t *S3Target) Delete(dir, name string) error {
+	if name != path.Base(name) {
+		return fmt.Errorf("无效的文件名")
+	}
+	u, err := t.objectURL(t.key(dir, name))
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(http.MethodDelete, u, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// sigV4 AWS Signature Version 4 签名
+type sigV4 struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+	Service   string
+}
+
+// Sign 为请求添加 X-Amz-Date 和 Authorization 头
+// 签名的头: host、所有 x-amz-*，以及已设置的 content-type / content-md5
+func (s sigV4) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" || lk == "content-md5" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(req.URL.EscapedPath(), false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		values := append([]string{}, q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape 按 AWS 规则编码：仅保留 A-Z a-z 0-9 - _ . ~；路径已转义时先还原，避免二次编码
+func awsEscape(s string, encodeSlash bool) string {
+	if !encodeSlash {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			s = unescaped
+		}
+		if s == "" {
+			return "/"
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,170 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPTarget SFTP 远程目标，支持密码或私钥认证
+type SFTPTarget struct {
+	cfg TargetConfig
+}
+
+// hostKeyCallback 按配置的 SHA256 指纹校验主机公钥，NewTarget 保证指纹不为空
+func (t *SFTPTarget) hostKeyCallback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if fp := ssh.FingerprintSHA256(key); fp != t.cfg.HostKey {
+			return fmt.Errorf("主机指纹不匹配: %s", fp)
+		}
+		return nil
+	}
+}
+
+func (t *SFTPTarget) dial() (*sftp.Client, func(), error) {
+	auth := []ssh.AuthMethod{}
+	if t.cfg.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(t.cfg.PrivateKey))
+		if err != nil {
+			return nil, nil, fmt.Errorf("私钥无效: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if t.cfg.Password != "" {
+		auth = append(auth, ssh.Password(t.cfg.Password))
+	}
+
+	port := t.cfg.Port
+	if port == 0 {
+		port = 22
+	}
+
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(t.cfg.Host, strconv.Itoa(port)), &ssh.ClientConfig{
+		User:            t.cfg.User,
+		Auth:            auth,
+		HostKeyCallback: t.hostKeyCallback(),
+		Timeout:         remoteTimeout,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("连接 SFTP 失败: %w", err)
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return client, func() {
+		client.Close()
+		conn.Close()
+	}, nil
+}
+
+func (t *SFTPTarget) remoteDir(dir string) string {
+	root := t.cfg.Path
+	if root == "" {
+		root = "."
+	}
+	return path.Join(root, dir)
+}
+
+// Test 登录并确认根目录可用
+func (t *SFTPTarget) Test() error {
+	client, closeFn, err := t.dial()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	root := t.remoteDir("")
+	if err := client.MkdirAll(root); err != nil {
+		return fmt.Errorf("无法创建远程目录 %s: %w", root, err)
+	}
+	_, err = client.Stat(root)
+	return err
+}
+
+// Upload 上传文件，先写 .part 再重命名
+func (t *SFTPTarget) Upload(localPath, dir string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	client, closeFn, err := t.dial()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	remoteDir := t.remoteDir(dir)
+	if err := client.MkdirAll(remoteDir); err != nil {
+		return err
+	}
+
+	dest := path.Join(remoteDir, path.Base(localPath))
+	tmp := dest + ".part"
+	out, err := client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		client.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		client.Remove(tmp)
+		return err
+	}
+	client.Remove(dest)
+	return client.Rename(tmp, dest)
+}
+
+// List 列出远程目录文件
+func (t *SFTPTarget) List(dir string) ([]RemoteFile, error) {
+	client, closeFn, err := t.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	files := []RemoteFile{}
+	infos, err := client.ReadDir(t.remoteDir(dir))
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		files = append(files, RemoteFile{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Delete 删除远程文件
+func (t *SFTPTarget) Delete(dir, name string) error {
+	if name != path.Base(name) {
+		return fmt.Errorf("无效的文件名")
+	}
+
+	client, closeFn, err := t.dial()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	return client.Remove(path.Join(t.remoteDir(dir), name))
+}
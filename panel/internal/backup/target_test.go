@@ -0,0 +1,615 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// writeBackups 在 dir 下生成指定时间戳的备份文件
+func writeBackups(t *testing.T, dir, name string, stamps ...string) []string {
+	t.Helper()
+	os.MkdirAll(dir, 0755)
+	files := []string{}
+	for _, s := range stamps {
+		p := filepath.Join(dir, name+"_"+s+".tar.gz")
+		if err := os.WriteFile(p, []byte("backup "+s), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, p)
+	}
+	return files
+}
+
+// exerciseTarget 对目标执行 Test / Upload / List / PruneRemote 的通用检查
+func exerciseTarget(t *testing.T, target BackupTarget) {
+	t.Helper()
+	if err := target.Test(); err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+
+	empty, err := target.List(KindSite)
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("Expected empty list, got %v, %v", empty, err)
+	}
+
+	files := writeBackups(t, t.TempDir(), "example.com", "20240101_000000", "20240102_000000", "20240103_000000")
+	for _, f := range files {
+		if err := target.Upload(f, KindSite); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+	}
+
+	list, err := target.List(KindSite)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("Expected 3 remote files, got %+v", list)
+	}
+	for _, f := range list {
+		if f.Size != int64(len("backup 20240101_000000")) {
+			t.Errorf("Unexpected size for %s: %d", f.Name, f.Size)
+		}
+	}
+
+	removed, err := PruneRemote(target, KindSite, "example.com", 2)
+	if err != nil {
+		t.Fatalf("PruneRemote failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "example.com_20240101_000000.tar.gz" {
+		t.Errorf("Unexpected pruned files: %v", removed)
+	}
+
+	list, _ = target.List(KindSite)
+	names := []string{}
+	for _, f := range list {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "example.com_20240102_000000.tar.gz,example.com_20240103_000000.tar.gz" {
+		t.Errorf("Unexpected remaining files: %v", names)
+	}
+
+	if err := target.Delete(KindSite, "../escape"); err == nil {
+		t.Error("Expected error for traversal in Delete")
+	}
+}
+
+func TestLocalTarget(t *testing.T) {
+	target, err := NewTarget(TargetConfig{Name: "nas", Type: TargetLocal, Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseTarget(t, target)
+
+	if _, err := NewTarget(TargetConfig{Type: TargetLocal, Path: "relative"}); err == nil {
+		t.Error("Expected error for relative local path")
+	}
+}
+
+// fakeFTPServer 仅实现上传/列表/删除所需命令的内存 FTP 服务器
+type fakeFTPServer struct {
+	ln    net.Listener
+	user  string
+	pass  string
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+func newFakeFTPServer(t *testing.T, user, pass string) *fakeFTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeFTPServer{ln: ln, user: user, pass: pass, files: map[string][]byte{}, dirs: map[string]bool{"/": true}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeFTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var data net.Listener
+	loggedIn := false
+	user := ""
+	reply("220 fake ftp")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd, arg, _ := strings.Cut(line, " ")
+
+		if !loggedIn && cmd != "USER" && cmd != "PASS" && cmd != "QUIT" {
+			reply("530 Not logged in")
+			continue
+		}
+
+		switch cmd {
+		case "USER":
+			user = arg
+			reply("331 Password required")
+		case "PASS":
+			if user == s.user && arg == s.pass {
+				loggedIn = true
+				reply("230 Logged in")
+			} else {
+				reply("530 Login incorrect")
+			}
+		case "TYPE":
+			reply("200 Type set")
+		case "MKD":
+			s.mu.Lock()
+			s.dirs[arg] = true
+			s.mu.Unlock()
+			reply("257 Created")
+		case "CWD":
+			s.mu.Lock()
+			ok := s.dirs[arg]
+			s.mu.Unlock()
+			if ok {
+				reply("250 OK")
+			} else {
+				reply("550 No such directory")
+			}
+		case "PASV":
+			data, _ = net.Listen("tcp", "127.0.0.1:0")
+			p := data.Addr().(*net.TCPAddr).Port
+			// 故意返回不可达的地址，客户端应使用控制连接的主机
+			reply("227 Entering Passive Mode (10,0,0,1,%d,%d)", p/256, p%256)
+		case "STOR", "NLST":
+			if data == nil {
+				reply("425 Use PASV first")
+				continue
+			}
+			s.mu.Lock()
+			dirOK := s.dirs[path.Dir(arg)]
+			if cmd == "NLST" {
+				dirOK = s.dirs[arg]
+			}
+			s.mu.Unlock()
+			if !dirOK {
+				data.Close()
+				data = nil
+				reply("550 No such file or directory")
+				continue
+			}
+
+			reply("150 Opening data connection")
+			dc, err := data.Accept()
+			data.Close()
+			data = nil
+			if err != nil {
+				reply("425 Cannot open data connection")
+				continue
+			}
+			if cmd == "STOR" {
+				b, _ := io.ReadAll(dc)
+				s.mu.Lock()
+				s.files[arg] = b
+				s.mu.Unlock()
+			} else {
+				s.mu.Lock()
+				for p := range s.files {
+					if path.Dir(p) == arg {
+						fmt.Fprintf(dc, "%s\r\n", p)
+					}
+				}
+				s.mu.Unlock()
+			}
+			dc.Close()
+			reply("226 Transfer complete")
+		case "SIZE":
+			s.mu.Lock()
+			b, ok := s.files[arg]
+			s.mu.Unlock()
+			if ok {
+				reply("213 %d", len(b))
+			} else {
+				reply("550 Not found")
+			}
+		case "DELE":
+			s.mu.Lock()
+			_, ok := s.files[arg]
+			delete(s.files, arg)
+			s.mu.Unlock()
+			if ok {
+				reply("250 Deleted")
+			} else {
+				reply("550 Not found")
+			}
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func TestFTPTarget(t *testing.T) {
+	server := newFakeFTPServer(t, "backup", "secret")
+
+	target, err := NewTarget(TargetConfig{
+		Name: "ftp", Type: TargetFTP,
+		Host: "127.0.0.1", Port: server.port(),
+		User: "backup", Password: "secret", Path: "/remote",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseTarget(t, target)
+
+	if _, ok := server.files["/remote/site/example.com_20240103_000000.tar.gz"]; !ok {
+		t.Errorf("Expected file under /remote/site, got %v", server.files)
+	}
+
+	bad, _ := NewTarget(TargetConfig{
+		Name: "ftp", Type: TargetFTP,
+		Host: "127.0.0.1", Port: server.port(),
+		User: "backup", Password: "wrong",
+	})
+	if err := bad.Test(); err == nil {
+		t.Error("Expected login failure with wrong password")
+	}
+}
+
+// startSFTPServer 启动只接受密码认证的进程内 SSH/SFTP 服务器
+func startSFTPServer(t *testing.T, user, pass string) (int, string) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(p) == pass {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChan := range chans {
+					if newChan.ChannelType() != "session" {
+						newChan.Reject(ssh.UnknownChannelType, "unsupported")
+						continue
+					}
+					channel, requests, err := newChan.Accept()
+					if err != nil {
+						continue
+					}
+					go func(in <-chan *ssh.Request) {
+						for req := range in {
+							ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+							req.Reply(ok, nil)
+						}
+					}(requests)
+					go func() {
+						server, err := sftp.NewServer(channel)
+						if err != nil {
+							channel.Close()
+							return
+						}
+						server.Serve()
+						server.Close()
+					}()
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port, ssh.FingerprintSHA256(signer.PublicKey())
+}
+
+func TestSFTPTarget(t *testing.T) {
+	port, fingerprint := startSFTPServer(t, "backup", "secret")
+	root := filepath.Join(t.TempDir(), "remote")
+
+	target, err := NewTarget(TargetConfig{
+		Name: "sftp", Type: TargetSFTP,
+		Host: "127.0.0.1", Port: port,
+		User: "backup", Password: "secret",
+		Path: root, HostKey: fingerprint,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseTarget(t, target)
+
+	if _, err := os.Stat(filepath.Join(root, KindSite, "example.com_20240103_000000.tar.gz")); err != nil {
+		t.Errorf("Expected uploaded file on disk: %v", err)
+	}
+
+	mismatch, _ := NewTarget(TargetConfig{
+		Name: "sftp", Type: TargetSFTP,
+		Host: "127.0.0.1", Port: port,
+		User: "backup", Password: "secret",
+		Path: root, HostKey: "SHA256:invalid",
+	})
+	if err := mismatch.Test(); err == nil {
+		t.Error("Expected host key mismatch error")
+	}
+
+	// 未配置主机指纹时拒绝创建，不会退回到不校验
+	if _, err := NewTarget(TargetConfig{
+		Name: "sftp", Type: TargetSFTP,
+		Host: "127.0.0.1", Port: port,
+		User: "backup", Password: "secret",
+		Path: root,
+	}); err == nil || !strings.Contains(err.Error(), "主机指纹") {
+		t.Errorf("Expected missing host key to be rejected, got %v", err)
+	}
+}
+
+// fakeS3Server path-style 的内存 S3，只检查签名头格式和载荷哈希
+func fakeS3Server(t *testing.T, bucket, accessKey string) (*httptest.Server, map[string][]byte) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+accessKey+"/") || r.Header.Get("X-Amz-Date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>InvalidAccessKeyId</Code><Message>bad key</Message></Error>`)
+			return
+		}
+
+		p := strings.TrimPrefix(r.URL.Path, "/")
+		b, key, _ := strings.Cut(p, "/")
+		if b != bucket {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchBucket</Code><Message>missing</Message></Error>`)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `<Error><Code>XAmzContentSHA256Mismatch</Code><Message>hash</Message></Error>`)
+				return
+			}
+			objects[key] = body
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			if r.URL.Query().Get("list-type") != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			prefix := r.URL.Query().Get("prefix")
+			keys := []string{}
+			for k := range objects {
+				if strings.HasPrefix(k, prefix) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			// 每页最多两个对象，覆盖分页逻辑
+			start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+			end := start + 2
+			if end > len(keys) {
+				end = len(keys)
+			}
+			var result struct {
+				XMLName  xml.Name `xml:"ListBucketResult"`
+				Contents []struct {
+					Key          string
+					Size         int
+					LastModified string
+				}
+				IsTruncated           bool
+				NextContinuationToken string `xml:",omitempty"`
+			}
+			for _, k := range keys[start:end] {
+				result.Contents = append(result.Contents, struct {
+					Key          string
+					Size         int
+					LastModified string
+				}{k, len(objects[k]), time.Now().UTC().Format(time.RFC3339)})
+			}
+			if end < len(keys) {
+				result.IsTruncated = true
+				result.NextContinuationToken = strconv.Itoa(end)
+			}
+			xml.NewEncoder(w).Encode(result)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, objects
+}
+
+func TestS3Target(t *testing.T) {
+	server, objects := fakeS3Server(t, "backups", "AKIDTEST")
+
+	target, err := NewTarget(TargetConfig{
+		Name: "s3", Type: TargetS3,
+		Endpoint: server.URL, Bucket: "backups", PathStyle: true,
+		AccessKey: "AKIDTEST", SecretKey: "secret", Path: "panel",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseTarget(t, target)
+
+	if _, ok := objects["panel/site/example.com_20240103_000000.tar.gz"]; !ok {
+		t.Errorf("Expected object under panel/site/, got %v", objects)
+	}
+
+	bad, _ := NewTarget(TargetConfig{
+		Name: "s3", Type: TargetS3,
+		Endpoint: server.URL, Bucket: "backups", PathStyle: true,
+		AccessKey: "WRONG", SecretKey: "secret",
+	})
+	if err := bad.Test(); err == nil || !strings.Contains(err.Error(), "InvalidAccessKeyId") {
+		t.Errorf("Expected InvalidAccessKeyId error, got %v", err)
+	}
+}
+
+func TestSigV4KnownVector(t *testing.T) {
+	// AWS SigV4 测试套件 get-vanilla
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signer := sigV4{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "service",
+	}
+	signer.Sign(req, emptyPayloadHash, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("Unexpected Authorization:\n got %s\nwant %s", got, expected)
+	}
+}
+
+func TestSaveTargetKeepsSecrets(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	cfg := TargetConfig{
+		Name: "offsite", Type: TargetS3, Enabled: true,
+		Endpoint: "https://s3.example.com", Bucket: "b",
+		AccessKey: "AK", SecretKey: "topsecret",
+	}
+	if err := m.SaveTarget(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// 前端回传的是脱敏后的配置
+	if err := m.SaveTarget(cfg.Redacted()); err != nil {
+		t.Fatal(err)
+	}
+	_, saved, err := m.GetTarget("offsite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.SecretKey != "topsecret" {
+		t.Errorf("Expected secret to be preserved, got %q", saved.SecretKey)
+	}
+
+	if err := m.SaveTarget(TargetConfig{Name: ConfTargetName, Type: TargetLocal, Path: "/tmp"}); err == nil {
+		t.Error("Expected error when overriding the backup.conf target")
+	}
+	if err := m.SaveTarget(TargetConfig{Name: "bad name", Type: TargetLocal, Path: "/tmp"}); err == nil {
+		t.Error("Expected error for invalid name")
+	}
+
+	if err := m.RemoveTarget("offsite"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.GetTarget("offsite"); err == nil {
+		t.Error("Expected target to be removed")
+	}
+}
+
+func TestBackupPushesToTargets(t *testing.T) {
+	m, _ := newTestManager(t)
+	remote := t.TempDir()
+	if err := m.SaveTarget(TargetConfig{Name: "nas", Type: TargetLocal, Enabled: true, Keep: 1, Path: remote}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveTarget(TargetConfig{Name: "off", Type: TargetLocal, Enabled: false, Path: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(filepath.Join(m.sitesDir, "example.com"), 0755)
+	os.WriteFile(filepath.Join(m.sitesDir, "example.com", "index.html"), []byte("hi"), 0644)
+
+	// 预置一份更早的远程备份，Keep=1 时应被清理
+	writeBackups(t, filepath.Join(remote, KindSite), "example.com", "20000101_000000")
+
+	entry, err := m.BackupSite("example.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := os.ReadDir(filepath.Join(remote, KindSite))
+	if len(files) != 1 || files[0].Name() != entry.File {
+		t.Errorf("Expected only %s on target, got %v", entry.File, files)
+	}
+	if _, err := os.Stat(entry.Path); err != nil {
+		t.Errorf("Local backup should be kept: %v", err)
+	}
+
+	result, err := m.PushTo(KindSite, entry.File, "off")
+	if err != nil || !result.Success {
+		t.Errorf("PushTo failed: %+v, %v", result, err)
+	}
+}
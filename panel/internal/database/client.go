@@ -0,0 +1,283 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// 系统库，不允许在面板中列出或删除
+var systemDatabases = map[string]bool{
+	"information_schema": true,
+	"performance_schema": true,
+	"mysql":              true,
+	"sys":                true,
+}
+
+// 系统账号，不允许在面板中列出或删除
+var systemUsers = map[string]bool{
+	"root":             true,
+	"mysql.sys":        true,
+	"mysql.session":    true,
+	"mysql.infoschema": true,
+	"mariadb.sys":      true,
+	"debian-sys-maint": true,
+}
+
+// ErrUserExists 账号已存在；CreateUser 不会修改已有账号的密码
+var ErrUserExists = errors.New("账号已存在")
+
+// Database 数据库信息
+type Database struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// User 数据库账号
+type User struct {
+	Username  string   `json:"username"`
+	Host      string   `json:"host"`
+	Databases []string `json:"databases"`
+}
+
+// Client 数据库操作接口，便于测试时替换为假实现
+type Client interface {
+	ListDatabases() ([]Database, error)
+	CreateDatabase(name string) error
+	DropDatabase(name string) error
+	ListUsers() ([]User, error)
+	CreateUser(username, host, password string) error
+	DropUser(username, host string) error
+	Grant(database, username, host string) error
+	Import(name string, r io.Reader) error
+	Export(name string, w io.Writer) error
+}
+
+// runner 执行外部命令，测试时可替换
+type runner func(name string, args []string, env []string, stdin io.Reader, stdout io.Writer) error
+
+func execRunner(name string, args []string, env []string, stdin io.Reader, stdout io.Writer) error {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", name, msg)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// MySQLClient 通过 mysql/mysqldump 命令操作数据库
+// 凭据与 backup_cron.sh 一致：root 用户 + 密码文件
+type MySQLClient struct {
+	User         string
+	PasswordFile string
+	run          runner
+
+	systemCommand *bool // mysql 客户端是否支持 --system-command，首次导入时检测
+}
+
+// NewMySQLClient 创建客户端
+func NewMySQLClient(passwordFile string) *MySQLClient {
+	return &MySQLClient{
+		User:         "root",
+		PasswordFile: passwordFile,
+		run:          execRunner,
+	}
+}
+
+// credentials 返回连接参数和环境变量（密码通过 MYSQL_PWD 传递，避免出现在进程列表中）
+func (m *MySQLClient) credentials() ([]string, []string, error) {
+	data, err := os.ReadFile(m.PasswordFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("未找到 MySQL 密码文件: %s", m.PasswordFile)
+	}
+	password := strings.TrimSpace(string(data))
+	return []string{"-u" + m.User}, []string{"MYSQL_PWD=" + password}, nil
+}
+
+// query 执行查询并返回按制表符分割的结果行
+func (m *MySQLClient) query(sql string) ([][]string, error) {
+	args, env, err := m.credentials()
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, "-N", "-B", "-e", sql)
+
+	var out bytes.Buffer
+	if err := m.run("mysql", args, env, nil, &out); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		rows = append(rows, strings.Split(line, "\t"))
+	}
+	return rows, nil
+}
+
+// exec 通过标准输入执行 SQL 语句
+func (m *MySQLClient) exec(statements ...string) error {
+	args, env, err := m.credentials()
+	if err != nil {
+		return err
+	}
+	sql := strings.Join(statements, ";\n") + ";\n"
+	return m.run("mysql", args, env, strings.NewReader(sql), io.Discard)
+}
+
+// ListDatabases 列出用户数据库及其大小
+func (m *MySQLClient) ListDatabases() ([]Database, error) {
+	rows, err := m.query(`SELECT s.schema_name, COALESCE(SUM(t.data_length + t.index_length), 0)
+FROM information_schema.schemata s
+LEFT JOIN information_schema.tables t ON t.table_schema = s.schema_name
+GROUP BY s.schema_name ORDER BY s.schema_name`)
+	if err != nil {
+		return nil, err
+	}
+
+	databases := []Database{}
+	for _, row := range rows {
+		if systemDatabases[row[0]] {
+			continue
+		}
+		db := Database{Name: row[0]}
+		if len(row) > 1 {
+			db.Size, _ = strconv.ParseInt(row[1], 10, 64)
+		}
+		databases = append(databases, db)
+	}
+	return databases, nil
+}
+
+// CreateDatabase 创建数据库（utf8mb4）
+func (m *MySQLClient) CreateDatabase(name string) error {
+	return m.exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci", quoteIdent(name)))
+}
+
+// DropDatabase 删除数据库
+func (m *MySQLClient) DropDatabase(name string) error {
+	return m.exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", quoteIdent(name)))
+}
+
+// ListUsers 列出普通账号及其授权的数据库
+func (m *MySQLClient) ListUsers() ([]User, error) {
+	rows, err := m.query(`SELECT u.user, u.host, COALESCE(GROUP_CONCAT(d.db), '')
+FROM mysql.user u
+LEFT JOIN mysql.db d ON d.user = u.user AND d.host = u.host
+GROUP BY u.user, u.host ORDER BY u.user`)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []User{}
+	for _, row := range rows {
+		if len(row) < 2 || row[0] == "" || systemUsers[row[0]] {
+			continue
+		}
+		user := User{Username: row[0], Host: row[1], Databases: []string{}}
+		if len(row) > 2 && row[2] != "" {
+			user.Databases = strings.Split(row[2], ",")
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// CreateUser 创建账号，账号已存在时返回 ErrUserExists
+func (m *MySQLClient) CreateUser(username, host, password string) error {
+	rows, err := m.query(fmt.Sprintf("SELECT 1 FROM mysql.user WHERE user = %s AND host = %s", quoteString(username), quoteString(host)))
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		return ErrUserExists
+	}
+	return m.exec(
+		fmt.Sprintf("CREATE USER %s IDENTIFIED BY %s", quoteAccount(username, host), quoteString(password)),
+		"FLUSH PRIVILEGES",
+	)
+}
+
+// DropUser 删除账号
+func (m *MySQLClient) DropUser(username, host string) error {
+	return m.exec(
+		fmt.Sprintf("DROP USER IF EXISTS %s", quoteAccount(username, host)),
+		"FLUSH PRIVILEGES",
+	)
+}
+
+// Grant 授予账号对数据库的全部权限
+func (m *MySQLClient) Grant(database, username, host string) error {
+	return m.exec(
+		fmt.Sprintf("GRANT ALL PRIVILEGES ON %s.* TO %s", quoteIdent(database), quoteAccount(username, host)),
+		"FLUSH PRIVILEGES",
+	)
+}
+
+// supportsSystemCommand 客户端是否支持 --system-command（MySQL 8.0.40 起），MariaDB 等旧客户端不支持
+func (m *MySQLClient) supportsSystemCommand() bool {
+	if m.systemCommand == nil {
+		var out bytes.Buffer
+		ok := m.run("mysql", []string{"--help"}, nil, nil, &out) == nil && strings.Contains(out.String(), "--system-command")
+		m.systemCommand = &ok
+	}
+	return *m.systemCommand
+}
+
+// Import 将 SQL 导入数据库。导入内容来自上传文件，不以 root 执行：
+// 使用只对目标库有权限的临时账号，USE 其他库会被拒绝；--binary-mode 关闭 \! 等客户端命令
+func (m *MySQLClient) Import(name string, r io.Reader) error {
+	user, password := "import_"+randomPassword(8), randomPassword(24)
+	account := quoteAccount(user, "localhost")
+	if err := m.exec(
+		fmt.Sprintf("CREATE USER %s IDENTIFIED BY %s", account, quoteString(password)),
+		fmt.Sprintf("GRANT ALL PRIVILEGES ON %s.* TO %s", quoteIdent(name), account),
+	); err != nil {
+		return err
+	}
+	defer m.exec(fmt.Sprintf("DROP USER IF EXISTS %s", account))
+
+	args := []string{"-u" + user, "--binary-mode"}
+	if m.supportsSystemCommand() {
+		args = append(args, "--system-command=OFF")
+	}
+	return m.run("mysql", append(args, name), []string{"MYSQL_PWD=" + password}, r, io.Discard)
+}
+
+// Export 导出数据库为 SQL
+func (m *MySQLClient) Export(name string, w io.Writer) error {
+	args, env, err := m.credentials()
+	if err != nil {
+		return err
+	}
+	args = append(args, "--single-transaction", "--quick", name)
+	return m.run("mysqldump", args, env, nil, w)
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+func quoteAccount(username, host string) string {
+	return quoteString(username) + "@" + quoteString(host)
+}
@@ -0,0 +1,326 @@
+package database
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	nameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]{1,64}$`)
+	hostRegex = regexp.MustCompile(`^[a-zA-Z0-9.%_:-]{1,255}$`)
+)
+
+// DatabaseHandler 数据库管理处理器
+type DatabaseHandler struct {
+	client    Client
+	backupDir string
+}
+
+// NewDatabaseHandler 创建处理器，导出文件写入 backupDir/database（与 backup_cron.sh 一致）
+func NewDatabaseHandler(client Client, backupDir string) *DatabaseHandler {
+	return &DatabaseHandler{client: client, backupDir: backupDir}
+}
+
+// RegisterRoutes 注册路由
+func (h *DatabaseHandler) RegisterRoutes(router fiber.Router) {
+	db := router.Group("/databases")
+	db.Get("", h.List)
+	db.Post("", h.Create)
+	db.Get("/users", h.ListUsers)
+	db.Post("/users", h.CreateUser)
+	db.Delete("/users/:username", h.DropUser)
+	db.Delete("/:name", h.Drop)
+	db.Post("/:name/import", h.Import)
+	db.Get("/:name/export", h.Export)
+}
+
+func isValidName(name string) bool {
+	return nameRegex.MatchString(name)
+}
+
+func isValidHost(host string) bool {
+	return hostRegex.MatchString(host)
+}
+
+// randomPassword 生成随机密码（字母数字，与 CLI random_string 一致）
+func randomPassword(length int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, length)
+	for i := range b {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		b[i] = chars[n.Int64()]
+	}
+	return string(b)
+}
+
+// userError 账号已存在返回 400，其余为 500
+func userError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, ErrUserExists) {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "账号已存在，请使用其他用户名或先删除该账号"})
+	}
+	return c.Status(500).JSON(fiber.Map{"status": false, "message": "账号创建失败: " + err.Error()})
+}
+
+// List 列出数据库
+func (h *DatabaseHandler) List(c *fiber.Ctx) error {
+	databases, err := h.client.ListDatabases()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "获取数据库列表失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   databases,
+	})
+}
+
+// Create 创建数据库，并创建同名账号授权（与 site db create 一致）
+func (h *DatabaseHandler) Create(c *fiber.Ctx) error {
+	var req struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Host     string `json:"host"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	if req.Username == "" {
+		req.Username = req.Name
+	}
+	if req.Host == "" {
+		req.Host = "localhost"
+	}
+	if req.Password == "" {
+		req.Password = randomPassword(16)
+	}
+
+	if !isValidName(req.Name) || !isValidName(req.Username) {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的数据库名或用户名"})
+	}
+	if !isValidHost(req.Host) {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的主机"})
+	}
+	if systemDatabases[req.Name] || systemUsers[req.Username] {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "不能使用系统保留名称"})
+	}
+
+	// 先创建账号：账号已存在时不返回一个从未生效的密码
+	if err := h.client.CreateUser(req.Username, req.Host, req.Password); err != nil {
+		return userError(c, err)
+	}
+	if err := h.client.CreateDatabase(req.Name); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "数据库创建失败: " + err.Error()})
+	}
+	if err := h.client.Grant(req.Name, req.Username, req.Host); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "授权失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "数据库创建成功",
+		"data": fiber.Map{
+			"name":     req.Name,
+			"username": req.Username,
+			"password": req.Password,
+			"host":     req.Host,
+		},
+	})
+}
+
+// Drop 删除数据库；keep_user=true 时保留同名账号
+func (h *DatabaseHandler) Drop(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if !isValidName(name) || systemDatabases[name] {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的数据库名"})
+	}
+
+	if err := h.client.DropDatabase(name); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "数据库删除失败: " + err.Error()})
+	}
+
+	if !c.QueryBool("keep_user", false) && !systemUsers[name] {
+		if err := h.client.DropUser(name, c.Query("host", "localhost")); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": false, "message": "账号删除失败: " + err.Error()})
+		}
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "数据库已删除"})
+}
+
+// ListUsers 列出数据库账号
+func (h *DatabaseHandler) ListUsers(c *fiber.Ctx) error {
+	users, err := h.client.ListUsers()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "获取账号列表失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   users,
+	})
+}
+
+// CreateUser 创建账号，可选授权到已有数据库
+func (h *DatabaseHandler) CreateUser(c *fiber.Ctx) error {
+	var req struct {
+		Username  string   `json:"username"`
+		Password  string   `json:"password"`
+		Host      string   `json:"host"`
+		Databases []string `json:"databases"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	if req.Host == "" {
+		req.Host = "localhost"
+	}
+	if req.Password == "" {
+		req.Password = randomPassword(16)
+	}
+
+	if !isValidName(req.Username) || systemUsers[req.Username] {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的用户名"})
+	}
+	if !isValidHost(req.Host) {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的主机"})
+	}
+	for _, db := range req.Databases {
+		if !isValidName(db) || systemDatabases[db] {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的数据库名: " + db})
+		}
+	}
+
+	if err := h.client.CreateUser(req.Username, req.Host, req.Password); err != nil {
+		return userError(c, err)
+	}
+	for _, db := range req.Databases {
+		if err := h.client.Grant(db, req.Username, req.Host); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": false, "message": "授权失败: " + err.Error()})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "账号创建成功",
+		"data": fiber.Map{
+			"username": req.Username,
+			"password": req.Password,
+			"host":     req.Host,
+		},
+	})
+}
+
+// DropUser 删除账号
+func (h *DatabaseHandler) DropUser(c *fiber.Ctx) error {
+	username := c.Params("username")
+	host := c.Query("host", "localhost")
+	if !isValidName(username) || systemUsers[username] || !isValidHost(host) {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的用户名"})
+	}
+
+	if err := h.client.DropUser(username, host); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "账号删除失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "账号已删除"})
+}
+
+// Import 导入上传的 .sql 或 .sql.gz 文件
+func (h *DatabaseHandler) Import(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if !isValidName(name) || systemDatabases[name] {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的数据库名"})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "请上传 SQL 文件"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取文件失败"})
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(fileHeader.Filename, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的 gzip 文件"})
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	if err := h.client.Import(name, reader); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "数据库导入失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "数据库导入成功"})
+}
+
+// Export 导出数据库为 .sql.gz；download=true 时直接下载
+func (h *DatabaseHandler) Export(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if !isValidName(name) || systemDatabases[name] {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的数据库名"})
+	}
+
+	dir := filepath.Join(h.backupDir, "database")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "创建备份目录失败"})
+	}
+
+	file := filepath.Join(dir, fmt.Sprintf("%s_%s.sql.gz", name, time.Now().Format("20060102_150405")))
+	if err := ExportToFile(h.client, name, file); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "数据库导出失败: " + err.Error()})
+	}
+
+	if c.QueryBool("download", false) {
+		return c.Download(file)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "数据库导出成功",
+		"data": fiber.Map{
+			"file": file,
+		},
+	})
+}
+
+// ExportToFile 导出到 gzip 文件，失败时删除不完整的文件
+func ExportToFile(client Client, name, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	err = client.Export(name, gz)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file)
+	}
+	return err
+}
@@ -0,0 +1,375 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeClient 内存中的假数据库实现
+type fakeClient struct {
+	databases map[string]string // name -> 内容
+	users     map[string]*User  // user@host -> user
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		databases: map[string]string{},
+		users:     map[string]*User{},
+	}
+}
+
+func (f *fakeClient) ListDatabases() ([]Database, error) {
+	list := []Database{}
+	for name, content := range f.databases {
+		list = append(list, Database{Name: name, Size: int64(len(content))})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (f *fakeClient) CreateDatabase(name string) error {
+	if _, ok := f.databases[name]; !ok {
+		f.databases[name] = ""
+	}
+	return nil
+}
+
+func (f *fakeClient) DropDatabase(name string) error {
+	delete(f.databases, name)
+	return nil
+}
+
+func (f *fakeClient) ListUsers() ([]User, error) {
+	list := []User{}
+	for _, u := range f.users {
+		list = append(list, *u)
+	}
+	return list, nil
+}
+
+func (f *fakeClient) CreateUser(username, host, password string) error {
+	if f.users[username+"@"+host] != nil {
+		return ErrUserExists
+	}
+	f.users[username+"@"+host] = &User{Username: username, Host: host, Databases: []string{}}
+	return nil
+}
+
+func (f *fakeClient) DropUser(username, host string) error {
+	delete(f.users, username+"@"+host)
+	return nil
+}
+
+func (f *fakeClient) Grant(database, username, host string) error {
+	u := f.users[username+"@"+host]
+	u.Databases = append(u.Databases, database)
+	return nil
+}
+
+func (f *fakeClient) Import(name string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.databases[name] += string(data)
+	return nil
+}
+
+func (f *fakeClient) Export(name string, w io.Writer) error {
+	_, err := io.WriteString(w, f.databases[name])
+	return err
+}
+
+func newTestApp(t *testing.T) (*fiber.App, *fakeClient, string) {
+	client := newFakeClient()
+	backupDir := t.TempDir()
+	app := fiber.New()
+	NewDatabaseHandler(client, backupDir).RegisterRoutes(app)
+	return app, client, backupDir
+}
+
+func doJSON(t *testing.T, app *fiber.App, method, url string, body interface{}) (int, map[string]interface{}) {
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, url, reader)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestCreateAndDropDatabase(t *testing.T) {
+	app, client, _ := newTestApp(t)
+
+	status, result := doJSON(t, app, "POST", "/databases", map[string]string{"name": "shop"})
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d: %v", status, result)
+	}
+	data := result["data"].(map[string]interface{})
+	if data["username"] != "shop" || len(data["password"].(string)) != 16 {
+		t.Errorf("Unexpected create response: %v", data)
+	}
+	if _, ok := client.databases["shop"]; !ok {
+		t.Fatal("Database was not created")
+	}
+	if u := client.users["shop@localhost"]; u == nil || len(u.Databases) != 1 || u.Databases[0] != "shop" {
+		t.Fatalf("User was not granted: %+v", u)
+	}
+
+	// 账号已存在时不返回新密码，也不创建数据库
+	client.CreateUser("blog", "localhost", "old")
+	status, result = doJSON(t, app, "POST", "/databases", map[string]string{"name": "blog"})
+	if status != 400 || !strings.Contains(result["message"].(string), "已存在") {
+		t.Errorf("Expected 400 for existing account, got %d: %v", status, result)
+	}
+	if _, ok := client.databases["blog"]; ok {
+		t.Error("Database should not be created for an existing account")
+	}
+	if status, _ := doJSON(t, app, "POST", "/databases/users", map[string]string{"username": "blog"}); status != 400 {
+		t.Errorf("Expected 400 for existing account, got %d", status)
+	}
+	client.DropUser("blog", "localhost")
+
+	status, _ = doJSON(t, app, "DELETE", "/databases/shop", nil)
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if len(client.databases) != 0 || len(client.users) != 0 {
+		t.Errorf("Database or user still exists: %v %v", client.databases, client.users)
+	}
+}
+
+func TestCreateDatabaseValidation(t *testing.T) {
+	app, client, _ := newTestApp(t)
+
+	tests := []struct {
+		name string
+		body map[string]string
+	}{
+		{"Empty name", map[string]string{}},
+		{"Injection", map[string]string{"name": "a`; DROP DATABASE mysql; --"}},
+		{"System database", map[string]string{"name": "mysql"}},
+		{"System user", map[string]string{"name": "app", "username": "root"}},
+		{"Invalid host", map[string]string{"name": "app", "host": "a'b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, result := doJSON(t, app, "POST", "/databases", tt.body)
+			if status != 400 {
+				t.Errorf("Expected 400, got %d: %v", status, result)
+			}
+		})
+	}
+
+	if len(client.databases) != 0 {
+		t.Errorf("No database should be created, got %v", client.databases)
+	}
+}
+
+func TestDropSystemDatabase(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	for _, name := range []string{"mysql", "sys", "information_schema"} {
+		if status, _ := doJSON(t, app, "DELETE", "/databases/"+name, nil); status != 400 {
+			t.Errorf("Dropping %s: expected 400, got %d", name, status)
+		}
+	}
+	if status, _ := doJSON(t, app, "DELETE", "/databases/users/root", nil); status != 400 {
+		t.Errorf("Dropping root: expected 400, got %d", status)
+	}
+}
+
+func TestImportAndExport(t *testing.T) {
+	app, client, backupDir := newTestApp(t)
+	client.CreateDatabase("blog")
+
+	var gzBuf bytes.Buffer
+	gz := gzip.NewWriter(&gzBuf)
+	gz.Write([]byte("CREATE TABLE posts (id INT);"))
+	gz.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "dump.sql.gz")
+	fw.Write(gzBuf.Bytes())
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/databases/blog/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, b)
+	}
+	if client.databases["blog"] != "CREATE TABLE posts (id INT);" {
+		t.Fatalf("Import content mismatch: %q", client.databases["blog"])
+	}
+
+	status, result := doJSON(t, app, "GET", "/databases/blog/export", nil)
+	if status != 200 {
+		t.Fatalf("Expected 200, got %d: %v", status, result)
+	}
+	file := result["data"].(map[string]interface{})["file"].(string)
+	if filepath.Dir(file) != filepath.Join(backupDir, "database") || !strings.HasPrefix(filepath.Base(file), "blog_") {
+		t.Errorf("Unexpected export path: %s", file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("Export file missing: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Export is not gzip: %v", err)
+	}
+	content, _ := io.ReadAll(zr)
+	if string(content) != "CREATE TABLE posts (id INT);" {
+		t.Errorf("Export content mismatch: %q", content)
+	}
+}
+
+func TestMySQLClientStatements(t *testing.T) {
+	pwdFile := filepath.Join(t.TempDir(), "mysql_root.pwd")
+	os.WriteFile(pwdFile, []byte("s3cret\n"), 0600)
+
+	var gotArgs []string
+	var gotEnv []string
+	var gotSQL string
+	client := NewMySQLClient(pwdFile)
+	client.run = func(name string, args []string, env []string, stdin io.Reader, stdout io.Writer) error {
+		gotArgs, gotEnv = args, env
+		if stdin != nil {
+			b, _ := io.ReadAll(stdin)
+			gotSQL = string(b)
+		}
+		return nil
+	}
+
+	if err := client.CreateUser("app", "localhost", "pa'ss"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if !strings.Contains(gotSQL, `CREATE USER 'app'@'localhost' IDENTIFIED BY 'pa\'ss'`) {
+		t.Errorf("Unexpected SQL: %s", gotSQL)
+	}
+	if gotArgs[0] != "-uroot" {
+		t.Errorf("Unexpected args: %v", gotArgs)
+	}
+	if len(gotEnv) != 1 || gotEnv[0] != "MYSQL_PWD=s3cret" {
+		t.Errorf("Password should be passed via MYSQL_PWD, got %v", gotEnv)
+	}
+	for _, arg := range gotArgs {
+		if strings.Contains(arg, "s3cret") {
+			t.Errorf("Password leaked into command line: %v", gotArgs)
+		}
+	}
+
+	client.CreateDatabase("we`ird")
+	if !strings.Contains(gotSQL, "CREATE DATABASE IF NOT EXISTS `we``ird`") {
+		t.Errorf("Identifier not quoted: %s", gotSQL)
+	}
+}
+
+func TestMySQLClientCreateExistingUser(t *testing.T) {
+	pwdFile := filepath.Join(t.TempDir(), "mysql_root.pwd")
+	os.WriteFile(pwdFile, []byte("s3cret\n"), 0600)
+	client := NewMySQLClient(pwdFile)
+	var statements []string
+	client.run = func(name string, args []string, env []string, stdin io.Reader, stdout io.Writer) error {
+		if stdin != nil {
+			b, _ := io.ReadAll(stdin)
+			statements = append(statements, string(b))
+		}
+		io.WriteString(stdout, "1\n")
+		return nil
+	}
+	if err := client.CreateUser("app", "localhost", "new"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	if len(statements) != 0 {
+		t.Errorf("Expected no statements for an existing account, got %v", statements)
+	}
+}
+
+func TestMySQLClientImport(t *testing.T) {
+	pwdFile := filepath.Join(t.TempDir(), "mysql_root.pwd")
+	os.WriteFile(pwdFile, []byte("s3cret\n"), 0600)
+
+	for _, help := range []string{"  --system-command    Enable (by default) or disable the system mysql command.", "  --binary-mode"} {
+		client := NewMySQLClient(pwdFile)
+		type call struct {
+			args  []string
+			env   []string
+			stdin string
+		}
+		var calls []call
+		client.run = func(name string, args []string, env []string, stdin io.Reader, stdout io.Writer) error {
+			c := call{args: args, env: env}
+			if stdin != nil {
+				b, _ := io.ReadAll(stdin)
+				c.stdin = string(b)
+			}
+			if len(args) == 1 && args[0] == "--help" {
+				io.WriteString(stdout, help)
+			}
+			calls = append(calls, c)
+			return nil
+		}
+
+		if err := client.Import("shop", strings.NewReader("\\! id\nUSE mysql;\n")); err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if len(calls) != 4 {
+			t.Fatalf("Unexpected calls: %+v", calls)
+		}
+		// 临时账号只对目标库有权限，导入结束后删除
+		create, importCall, drop := calls[0], calls[2], calls[3]
+		user := strings.TrimPrefix(importCall.args[0], "-u")
+		if !strings.HasPrefix(user, "import_") || !strings.Contains(create.stdin, "CREATE USER '"+user+"'@'localhost'") ||
+			!strings.Contains(create.stdin, "GRANT ALL PRIVILEGES ON `shop`.* TO '"+user+"'@'localhost'") {
+			t.Errorf("Unexpected setup: %q (import args %v)", create.stdin, importCall.args)
+		}
+		if !strings.Contains(drop.stdin, "DROP USER IF EXISTS '"+user+"'@'localhost'") {
+			t.Errorf("Expected temporary account to be dropped, got %q", drop.stdin)
+		}
+		if importCall.env[0] == "MYSQL_PWD=s3cret" || importCall.stdin != "\\! id\nUSE mysql;\n" {
+			t.Errorf("Import must not run as root: %+v", importCall)
+		}
+		args := strings.Join(importCall.args, " ")
+		wantSystem := strings.Contains(help, "--system-command")
+		if !strings.Contains(args, "--binary-mode") || strings.Contains(args, "--system-command=OFF") != wantSystem || !strings.HasSuffix(args, " shop") {
+			t.Errorf("Unexpected import args: %v", importCall.args)
+		}
+	}
+}
+
+func TestMySQLClientMissingPasswordFile(t *testing.T) {
+	client := NewMySQLClient(filepath.Join(t.TempDir(), "missing"))
+	if _, err := client.ListDatabases(); err == nil {
+		t.Error("Expected error when password file is missing")
+	}
+}
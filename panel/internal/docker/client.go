@@ -0,0 +1,504 @@
+// Package docker 通过 unix socket 调用 Docker Engine API：管理容器、镜像、数据卷和网络，
+// 读取资源占用，在容器内执行命令，并在其上实现 compose 项目的 up / down / pull / ps / logs，
+// 不依赖 docker CLI 或 docker-compose。
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultSocket Docker Engine 默认监听的 unix socket
+	DefaultSocket = "/var/run/docker.sock"
+	// apiVersion 请求使用的 API 版本（Docker 20.10 及以上）
+	apiVersion = "v1.41"
+)
+
+// ErrNotFound 容器、镜像或网络不存在
+var ErrNotFound = errors.New("docker: not found")
+
+// APIError Engine API 返回的错误响应
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker: %s", e.Message)
+}
+
+// Is 404 响应视为 ErrNotFound
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.Status == http.StatusNotFound
+}
+
+// Client Docker Engine API 客户端
+type Client struct {
+	Socket string
+	http   *http.Client
+}
+
+// NewClient 创建连接到 socket 的客户端，socket 为空时使用 DefaultSocket
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	return &Client{
+		Socket: socket,
+		http: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+}
+
+// newRequest 构造请求，body 为 io.Reader 时按 tar 上传，其余编码为 JSON
+func newRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var r io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r, contentType = b, "application/x-tar"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		r, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	u := "http://docker/" + apiVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+// do 发送请求，非 2xx / 3xx 响应转换为 *APIError
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	req, err := newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("连接 Docker 失败: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &APIError{Status: resp.StatusCode, Message: e.Message}
+	}
+	return resp, nil
+}
+
+// call 发送请求并把 JSON 响应解码到 out，out 为 nil 时丢弃响应
+func (c *Client) call(method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.do(context.Background(), method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stream 读取 pull / build 返回的 JSON 消息流，消息中带 error 时返回错误
+func (c *Client) stream(method, path string, query url.Values, body interface{}, progress func(string)) error {
+	resp, err := c.do(context.Background(), method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Stream string `json:"stream"`
+			Status string `json:"status"`
+			ID     string `json:"id"`
+			Error  string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("docker: %s", msg.Error)
+		}
+		line := strings.TrimSpace(msg.Stream)
+		if msg.Status != "" {
+			line = strings.TrimSpace(msg.ID + " " + msg.Status)
+		}
+		if line != "" && progress != nil {
+			progress(line)
+		}
+	}
+}
+
+// labelFilter 按标签过滤的 filters 参数
+func labelFilter(labels ...string) url.Values {
+	q := url.Values{}
+	if len(labels) > 0 {
+		f, _ := json.Marshal(map[string][]string{"label": labels})
+		q.Set("filters", string(f))
+	}
+	return q
+}
+
+// Ping 检查 Docker Engine 是否可用
+func (c *Client) Ping() error {
+	return c.call("GET", "/_ping", nil, nil, nil)
+}
+
+// Version Docker Engine 版本信息
+type Version struct {
+	Version       string `json:"Version"`
+	APIVersion    string `json:"ApiVersion"`
+	Os            string `json:"Os"`
+	Arch          string `json:"Arch"`
+	KernelVersion string `json:"KernelVersion"`
+}
+
+// Version 获取 Docker Engine 版本
+func (c *Client) Version() (*Version, error) {
+	var v Version
+	if err := c.call("GET", "/version", nil, nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Container 容器列表项
+type Container struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	Command string            `json:"Command"`
+	Created int64             `json:"Created"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Ports   []Port            `json:"Ports"`
+	Labels  map[string]string `json:"Labels"`
+}
+
+// Name 容器名（去掉开头的 /）
+func (c *Container) Name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// Port 容器端口及其映射
+type Port struct {
+	IP          string `json:"IP,omitempty"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort,omitempty"`
+	Type        string `json:"Type"`
+}
+
+func (p Port) String() string {
+	if p.PublicPort == 0 {
+		return fmt.Sprintf("%d/%s", p.PrivatePort, p.Type)
+	}
+	return fmt.Sprintf("%s:%d->%d/%s", p.IP, p.PublicPort, p.PrivatePort, p.Type)
+}
+
+// ContainerConfig 创建容器的参数
+type ContainerConfig struct {
+	Image            string              `json:"Image"`
+	Cmd              []string            `json:"Cmd,omitempty"`
+	Entrypoint       []string            `json:"Entrypoint,omitempty"`
+	Env              []string            `json:"Env,omitempty"`
+	WorkingDir       string              `json:"WorkingDir,omitempty"`
+	User             string              `json:"User,omitempty"`
+	Tty              bool                `json:"Tty,omitempty"`
+	Labels           map[string]string   `json:"Labels,omitempty"`
+	ExposedPorts     map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig       *HostConfig         `json:"HostConfig,omitempty"`
+	NetworkingConfig *NetworkingConfig   `json:"NetworkingConfig,omitempty"`
+}
+
+// HostConfig 容器的宿主机配置：挂载、端口映射、重启策略和网络
+type HostConfig struct {
+	Binds         []string                 `json:"Binds,omitempty"`
+	PortBindings  map[string][]PortBinding `json:"PortBindings,omitempty"`
+	RestartPolicy RestartPolicy            `json:"RestartPolicy"`
+	NetworkMode   string                   `json:"NetworkMode,omitempty"`
+}
+
+// PortBinding 端口映射到的宿主机地址
+type PortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// RestartPolicy 重启策略：no、always、unless-stopped、on-failure
+type RestartPolicy struct {
+	Name string `json:"Name"`
+}
+
+// NetworkingConfig 创建时加入的网络
+type NetworkingConfig struct {
+	EndpointsConfig map[string]EndpointSettings `json:"EndpointsConfig"`
+}
+
+// EndpointSettings 容器在网络中的别名
+type EndpointSettings struct {
+	Aliases []string `json:"Aliases,omitempty"`
+}
+
+// ContainerInfo 容器详情
+type ContainerInfo struct {
+	ID         string          `json:"Id"`
+	Name       string          `json:"Name"`
+	Created    string          `json:"Created"`
+	Image      string          `json:"Image"`
+	State      ContainerState  `json:"State"`
+	Config     ContainerConfig `json:"Config"`
+	HostConfig HostConfig      `json:"HostConfig"`
+}
+
+// ContainerState 容器运行状态
+type ContainerState struct {
+	Status     string `json:"Status"`
+	Running    bool   `json:"Running"`
+	Pid        int    `json:"Pid"`
+	ExitCode   int    `json:"ExitCode"`
+	Error      string `json:"Error"`
+	StartedAt  string `json:"StartedAt"`
+	FinishedAt string `json:"FinishedAt"`
+}
+
+// ListContainers 列出容器，all 为 false 时只列出运行中的；labels 形如 key=value
+func (c *Client) ListContainers(all bool, labels ...string) ([]Container, error) {
+	q := labelFilter(labels...)
+	if all {
+		q.Set("all", "1")
+	}
+	var list []Container
+	if err := c.call("GET", "/containers/json", q, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// InspectContainer 获取容器详情
+func (c *Client) InspectContainer(id string) (*ContainerInfo, error) {
+	var info ContainerInfo
+	if err := c.call("GET", "/containers/"+url.PathEscape(id)+"/json", nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// CreateContainer 创建容器，返回容器 ID
+func (c *Client) CreateContainer(name string, config *ContainerConfig) (string, error) {
+	q := url.Values{}
+	if name != "" {
+		q.Set("name", name)
+	}
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	if err := c.call("POST", "/containers/create", q, config, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// StartContainer 启动容器，已在运行时不报错
+func (c *Client) StartContainer(id string) error {
+	return c.call("POST", "/containers/"+url.PathEscape(id)+"/start", nil, nil, nil)
+}
+
+// StopContainer 停止容器，超过 timeout 秒后强制结束
+func (c *Client) StopContainer(id string, timeout int) error {
+	q := url.Values{"t": {strconv.Itoa(timeout)}}
+	return c.call("POST", "/containers/"+url.PathEscape(id)+"/stop", q, nil, nil)
+}
+
+// RestartContainer 重启容器，停止时超过 timeout 秒后强制结束
+func (c *Client) RestartContainer(id string, timeout int) error {
+	q := url.Values{"t": {strconv.Itoa(timeout)}}
+	return c.call("POST", "/containers/"+url.PathEscape(id)+"/restart", q, nil, nil)
+}
+
+// RemoveContainer 删除容器，force 为 true 时先结束运行中的容器
+func (c *Client) RemoveContainer(id string, force bool) error {
+	q := url.Values{}
+	if force {
+		q.Set("force", "1")
+	}
+	return c.call("DELETE", "/containers/"+url.PathEscape(id), q, nil, nil)
+}
+
+// ImageExists 本地是否已有镜像
+func (c *Client) ImageExists(ref string) (bool, error) {
+	err := c.call("GET", "/images/"+ref+"/json", nil, nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// PullImage 拉取镜像，未指定标签时使用 latest；progress 接收拉取进度
+func (c *Client) PullImage(ref string, progress func(string)) error {
+	name, tag := splitRef(ref)
+	q := url.Values{"fromImage": {name}, "tag": {tag}}
+	return c.stream("POST", "/images/create", q, nil, progress)
+}
+
+// splitRef 拆分镜像名和标签（或摘要），镜像仓库地址中的端口不算标签
+func splitRef(ref string) (name, tag string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+// BuildImage 以 contextDir 为构建上下文构建镜像并打上 tag，dockerfile 为相对上下文的路径
+func (c *Client) BuildImage(contextDir, dockerfile, tag string, progress func(string)) error {
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(writeTar(pw, contextDir)) }()
+	defer pr.Close()
+	q := url.Values{"t": {tag}, "dockerfile": {dockerfile}, "rm": {"1"}}
+	return c.stream("POST", "/build", q, pr, progress)
+}
+
+// writeTar 把目录打包为 tar 写入 w，.git 目录不打包
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// Network 网络
+type Network struct {
+	ID       string            `json:"Id"`
+	Name     string            `json:"Name"`
+	Created  string            `json:"Created"`
+	Scope    string            `json:"Scope"`
+	Driver   string            `json:"Driver"`
+	Internal bool              `json:"Internal"`
+	IPAM     IPAM              `json:"IPAM"`
+	Labels   map[string]string `json:"Labels"`
+}
+
+// IPAM 网络的地址分配
+type IPAM struct {
+	Config []IPAMConfig `json:"Config"`
+}
+
+// IPAMConfig 子网和网关
+type IPAMConfig struct {
+	Subnet  string `json:"Subnet,omitempty"`
+	Gateway string `json:"Gateway,omitempty"`
+}
+
+// ListNetworks 列出网络；labels 形如 key=value
+func (c *Client) ListNetworks(labels ...string) ([]Network, error) {
+	var list []Network
+	if err := c.call("GET", "/networks", labelFilter(labels...), nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreateNetwork 创建 bridge 网络，返回网络 ID
+func (c *Client) CreateNetwork(name string, labels map[string]string) (string, error) {
+	body := map[string]interface{}{"Name": name, "Driver": "bridge", "Labels": labels, "CheckDuplicate": true}
+	var resp struct {
+		ID string `json:"Id"`
+	}
+	if err := c.call("POST", "/networks/create", nil, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// RemoveNetwork 删除网络
+func (c *Client) RemoveNetwork(id string) error {
+	return c.call("DELETE", "/networks/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,784 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeFile compose 文件名，与 CLI 的 docker_up / docker_down 相同
+const ComposeFile = "docker-compose.yml"
+
+// 与 docker compose 相同的标签，CLI 用 docker-compose 启动的容器同样能被识别
+const (
+	LabelProject    = "com.docker.compose.project"
+	LabelService    = "com.docker.compose.service"
+	labelNumber     = "com.docker.compose.container-number"
+	labelOneoff     = "com.docker.compose.oneoff"
+	labelConfigHash = "com.docker.compose.config-hash"
+	labelNetwork    = "com.docker.compose.network"
+)
+
+// Project compose 项目，支持 services 下常用的短语法字段
+type Project struct {
+	Name     string
+	Dir      string
+	Services []*Service
+}
+
+// Service compose 服务
+type Service struct {
+	Name          string     `yaml:"-"`
+	Image         string     `yaml:"image"`
+	Build         *Build     `yaml:"build"`
+	Command       stringList `yaml:"command"`
+	Entrypoint    stringList `yaml:"entrypoint"`
+	Environment   mapping    `yaml:"environment"`
+	Ports         stringList `yaml:"ports"`
+	Volumes       []string   `yaml:"volumes"`
+	Restart       string     `yaml:"restart"`
+	WorkingDir    string     `yaml:"working_dir"`
+	User          string     `yaml:"user"`
+	Tty           bool       `yaml:"tty"`
+	ContainerName string     `yaml:"container_name"`
+	Labels        mapping    `yaml:"labels"`
+	DependsOn     dependsOn  `yaml:"depends_on"`
+}
+
+// Build 构建配置，可写作 build: . 或 build: {context, dockerfile}
+type Build struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+func (b *Build) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		b.Context = node.Value
+		return nil
+	}
+	type plain Build
+	return node.Decode((*plain)(b))
+}
+
+// stringList 字符串或列表，字符串按 shell 规则拆分（command / entrypoint），ports 中的数字按字符串处理
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*l = splitWords(node.Value)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("第 %d 行: 只支持短语法", item.Line)
+			}
+			*l = append(*l, item.Value)
+		}
+	default:
+		return fmt.Errorf("第 %d 行: 应为字符串或列表", node.Line)
+	}
+	return nil
+}
+
+// mapping 键值对，可写作映射或 KEY=value 列表（environment / labels）
+type mapping map[string]string
+
+func (m *mapping) UnmarshalYAML(node *yaml.Node) error {
+	*m = mapping{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if value.Tag == "!!null" {
+				(*m)[node.Content[i].Value] = ""
+				continue
+			}
+			(*m)[node.Content[i].Value] = value.Value
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			key, value, _ := strings.Cut(item.Value, "=")
+			(*m)[key] = value
+		}
+	default:
+		return fmt.Errorf("第 %d 行: 应为映射或列表", node.Line)
+	}
+	return nil
+}
+
+// dependsOn 依赖的服务，可写作列表或带 condition 的映射
+type dependsOn []string
+
+func (d *dependsOn) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			*d = append(*d, item.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			*d = append(*d, node.Content[i].Value)
+		}
+	default:
+		return fmt.Errorf("第 %d 行: 应为列表或映射", node.Line)
+	}
+	return nil
+}
+
+// splitWords 按 shell 规则拆分命令，支持单双引号和反斜杠转义
+func splitWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '\\' && quote == '"' && i+1 < len(s) {
+				i++
+				word.WriteByte(s[i])
+			} else {
+				word.WriteByte(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote, inWord = ch, true
+		case ch == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+var projectNameRe = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// ProjectName 由目录名得到项目名，规则与 docker compose 相同（如 app.example.com -> appexamplecom）
+func ProjectName(dir string) string {
+	return projectNameRe.ReplaceAllString(strings.ToLower(filepath.Base(dir)), "")
+}
+
+// LoadProject 读取 dir 下的 docker-compose.yml
+func LoadProject(dir string) (*Project, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ComposeFile))
+	if err != nil {
+		return nil, err
+	}
+	return ParseProject(ProjectName(dir), dir, data)
+}
+
+// dockerSockets 挂载后即可控制 Docker 守护进程的 socket
+var dockerSockets = []string{"/var/run/docker.sock", "/run/docker.sock"}
+
+// bindSource 挂载声明中的宿主机路径，命名卷和匿名卷返回空
+func bindSource(volume string) string {
+	source, _, ok := strings.Cut(volume, ":")
+	if !ok || (!strings.HasPrefix(source, ".") && !strings.HasPrefix(source, "/")) {
+		return ""
+	}
+	return source
+}
+
+// resolvePath 解析路径中的符号链接；末尾不存在的部分原样保留（Docker 会创建缺失的挂载目录）
+func resolvePath(path string) string {
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if dir == filepath.Dir(dir) {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// within path 是否为 root 本身或其下的路径
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// CheckPaths 校验服务可访问的宿主机路径：绑定挂载和构建目录必须位于项目目录或 roots 之下，
+// 按解析符号链接前后的路径分别判断；任何情况下都不允许挂载 Docker socket。
+// compose 文件由站点管理员编辑，不加限制时挂载 / 或 socket 即可取得宿主机 root
+func (p *Project) CheckPaths(roots ...string) error {
+	allowed := []string{}
+	for _, r := range append([]string{p.Dir}, roots...) {
+		r = filepath.Clean(r)
+		allowed = append(allowed, r, resolvePath(r))
+	}
+	check := func(s *Service, source string) error {
+		path := filepath.Clean(source)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.Dir, source)
+		}
+		for _, candidate := range []string{path, resolvePath(path)} {
+			for _, sock := range dockerSockets {
+				if candidate == sock {
+					return fmt.Errorf("服务 %s: 不允许挂载 Docker socket", s.Name)
+				}
+			}
+			ok := false
+			for _, r := range allowed {
+				ok = ok || within(candidate, r)
+			}
+			if !ok {
+				return fmt.Errorf("服务 %s: 不允许访问 %s 之外的宿主机路径 %s", s.Name, strings.Join(append([]string{p.Dir}, roots...), "、"), source)
+			}
+		}
+		return nil
+	}
+	for _, s := range p.Services {
+		for _, v := range s.Volumes {
+			if source := bindSource(v); source != "" {
+				if err := check(s, source); err != nil {
+					return err
+				}
+			}
+		}
+		if s.Build != nil {
+			if err := check(s, s.Build.Context); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ParseProject 解析并校验 compose 文件，服务保持文件中的顺序
+func ParseProject(name, dir string, data []byte) (*Project, error) {
+	var file struct {
+		Services yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("compose 文件格式错误: %w", err)
+	}
+	if file.Services.Kind != yaml.MappingNode || len(file.Services.Content) == 0 {
+		return nil, errors.New("compose 文件中没有定义 services")
+	}
+
+	p := &Project{Name: name, Dir: dir}
+	for i := 0; i+1 < len(file.Services.Content); i += 2 {
+		s := &Service{Name: file.Services.Content[i].Value}
+		if err := file.Services.Content[i+1].Decode(s); err != nil {
+			return nil, fmt.Errorf("服务 %s: %w", s.Name, err)
+		}
+		if s.Image == "" && s.Build == nil {
+			return nil, fmt.Errorf("服务 %s: 必须指定 image 或 build", s.Name)
+		}
+		for _, port := range s.Ports {
+			if _, err := ParsePort(port); err != nil {
+				return nil, fmt.Errorf("服务 %s: %w", s.Name, err)
+			}
+		}
+		for _, v := range s.Volumes {
+			if !strings.Contains(v, ":") {
+				continue // 匿名卷
+			}
+			if parts := strings.Split(v, ":"); len(parts) > 3 || parts[1] == "" {
+				return nil, fmt.Errorf("服务 %s: 无效的挂载 %q", s.Name, v)
+			}
+		}
+		p.Services = append(p.Services, s)
+	}
+	for _, s := range p.Services {
+		for _, dep := range s.DependsOn {
+			if p.Service(dep) == nil {
+				return nil, fmt.Errorf("服务 %s: 依赖的服务 %s 不存在", s.Name, dep)
+			}
+		}
+	}
+	if _, err := p.ordered(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Service 按名称查找服务
+func (p *Project) Service(name string) *Service {
+	for _, s := range p.Services {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// ordered 按 depends_on 排序，被依赖的服务先启动
+func (p *Project) ordered() ([]*Service, error) {
+	var list []*Service
+	state := map[string]int{} // 1 访问中，2 已完成
+	var visit func(s *Service) error
+	visit = func(s *Service) error {
+		switch state[s.Name] {
+		case 1:
+			return fmt.Errorf("服务 %s 存在循环依赖", s.Name)
+		case 2:
+			return nil
+		}
+		state[s.Name] = 1
+		for _, dep := range s.DependsOn {
+			if err := visit(p.Service(dep)); err != nil {
+				return err
+			}
+		}
+		state[s.Name] = 2
+		list = append(list, s)
+		return nil
+	}
+	for _, s := range p.Services {
+		if err := visit(s); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// PortMapping 解析后的端口映射
+type PortMapping struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+}
+
+// ParsePort 解析短语法端口：80、8080:80、127.0.0.1:8080:80，可带 /tcp 或 /udp
+func ParsePort(s string) (PortMapping, error) {
+	m := PortMapping{Protocol: "tcp"}
+	spec := s
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		m.Protocol = spec[i+1:]
+		spec = spec[:i]
+	}
+	if m.Protocol != "tcp" && m.Protocol != "udp" {
+		return m, fmt.Errorf("无效的端口协议 %q", s)
+	}
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return m, fmt.Errorf("不支持的端口格式 %q", s)
+	}
+	if len(parts) == 3 {
+		m.HostIP = parts[0]
+		parts = parts[1:]
+	}
+	var err error
+	if m.ContainerPort, err = strconv.Atoi(parts[len(parts)-1]); err != nil || m.ContainerPort < 1 || m.ContainerPort > 65535 {
+		return m, fmt.Errorf("无效的端口 %q", s)
+	}
+	if len(parts) == 2 && parts[0] != "" {
+		if m.HostPort, err = strconv.Atoi(parts[0]); err != nil || m.HostPort < 1 || m.HostPort > 65535 {
+			return m, fmt.Errorf("无效的端口 %q", s)
+		}
+	}
+	return m, nil
+}
+
+// PublishedPort 第一个映射到宿主机的 TCP 端口，站点的反向代理指向该端口；没有时返回 0
+func (p *Project) PublishedPort() int {
+	for _, s := range p.Services {
+		for _, port := range s.Ports {
+			if m, err := ParsePort(port); err == nil && m.HostPort != 0 && m.Protocol == "tcp" {
+				return m.HostPort
+			}
+		}
+	}
+	return 0
+}
+
+func (p *Project) networkName() string {
+	return p.Name + "_default"
+}
+
+func (p *Project) containerName(s *Service) string {
+	if s.ContainerName != "" {
+		return s.ContainerName
+	}
+	return p.Name + "-" + s.Name + "-1"
+}
+
+// imageName 服务使用的镜像，只有 build 时使用 <项目>-<服务>
+func (p *Project) imageName(s *Service) string {
+	if s.Image != "" {
+		return s.Image
+	}
+	return p.Name + "-" + s.Name
+}
+
+// containerConfig 服务对应的容器参数
+func (p *Project) containerConfig(s *Service) *ContainerConfig {
+	network := p.networkName()
+	cfg := &ContainerConfig{
+		Image:      p.imageName(s),
+		Cmd:        s.Command,
+		Entrypoint: s.Entrypoint,
+		WorkingDir: s.WorkingDir,
+		User:       s.User,
+		Tty:        s.Tty,
+		Labels: map[string]string{
+			LabelProject: p.Name,
+			LabelService: s.Name,
+			labelNumber:  "1",
+			labelOneoff:  "False",
+		},
+		HostConfig: &HostConfig{
+			RestartPolicy: RestartPolicy{Name: s.Restart},
+			NetworkMode:   network,
+		},
+		NetworkingConfig: &NetworkingConfig{EndpointsConfig: map[string]EndpointSettings{
+			network: {Aliases: []string{s.Name}},
+		}},
+	}
+	for _, k := range sortedKeys(s.Environment) {
+		cfg.Env = append(cfg.Env, k+"="+s.Environment[k])
+	}
+	for k, v := range s.Labels {
+		cfg.Labels[k] = v
+	}
+	for _, port := range s.Ports {
+		m, _ := ParsePort(port)
+		key := fmt.Sprintf("%d/%s", m.ContainerPort, m.Protocol)
+		if cfg.ExposedPorts == nil {
+			cfg.ExposedPorts = map[string]struct{}{}
+			cfg.HostConfig.PortBindings = map[string][]PortBinding{}
+		}
+		cfg.ExposedPorts[key] = struct{}{}
+		hostPort := ""
+		if m.HostPort != 0 {
+			hostPort = strconv.Itoa(m.HostPort)
+		}
+		cfg.HostConfig.PortBindings[key] = append(cfg.HostConfig.PortBindings[key], PortBinding{HostIP: m.HostIP, HostPort: hostPort})
+	}
+	for _, v := range s.Volumes {
+		source, target, ok := strings.Cut(v, ":")
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(source, "."):
+			source = filepath.Join(p.Dir, source)
+		case !strings.HasPrefix(source, "/"):
+			source = p.Name + "_" + source // 命名卷
+		}
+		cfg.HostConfig.Binds = append(cfg.HostConfig.Binds, source+":"+target)
+	}
+	return cfg
+}
+
+// configHash 容器参数的摘要，变化时 up 会重建容器
+func configHash(cfg *ContainerConfig) string {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containers 项目的所有容器（含已停止的），按服务名索引
+func (c *Client) containers(p *Project) (map[string]Container, error) {
+	list, err := c.ListContainers(true, LabelProject+"="+p.Name)
+	if err != nil {
+		return nil, err
+	}
+	byService := map[string]Container{}
+	for _, ct := range list {
+		byService[ct.Labels[LabelService]] = ct
+	}
+	return byService, nil
+}
+
+// Up 创建网络、准备镜像（缺少时构建或拉取），创建或重建配置有变化的容器并启动；
+// compose 文件中已删除的服务的容器会被移除
+func (c *Client) Up(p *Project, progress func(string)) error {
+	if progress == nil {
+		progress = func(string) {}
+	}
+	services, err := p.ordered()
+	if err != nil {
+		return err
+	}
+	if err := c.ensureNetwork(p, progress); err != nil {
+		return err
+	}
+	existing, err := c.containers(p)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range services {
+		if err := c.ensureImage(p, s, progress); err != nil {
+			return err
+		}
+		cfg := p.containerConfig(s)
+		hash := configHash(cfg)
+		cfg.Labels[labelConfigHash] = hash
+		name := p.containerName(s)
+
+		if old, ok := existing[s.Name]; ok {
+			delete(existing, s.Name)
+			if old.Labels[labelConfigHash] == hash {
+				if old.State != "running" {
+					progress("启动容器 " + name)
+					if err := c.StartContainer(old.ID); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			progress("重建容器 " + name)
+			if err := c.RemoveContainer(old.ID, true); err != nil {
+				return err
+			}
+		}
+
+		progress("创建容器 " + name)
+		id, err := c.CreateContainer(name, cfg)
+		if err != nil {
+			return err
+		}
+		if err := c.StartContainer(id); err != nil {
+			return err
+		}
+	}
+
+	for service, orphan := range existing {
+		progress("移除已删除的服务 " + service)
+		if err := c.RemoveContainer(orphan.ID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) ensureNetwork(p *Project, progress func(string)) error {
+	networks, err := c.ListNetworks(LabelProject + "=" + p.Name)
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if n.Name == p.networkName() {
+			return nil
+		}
+	}
+	progress("创建网络 " + p.networkName())
+	_, err = c.CreateNetwork(p.networkName(), map[string]string{LabelProject: p.Name, labelNetwork: "default"})
+	return err
+}
+
+// ensureImage 本地没有镜像时构建或拉取
+func (c *Client) ensureImage(p *Project, s *Service, progress func(string)) error {
+	image := p.imageName(s)
+	ok, err := c.ImageExists(image)
+	if err != nil || ok {
+		return err
+	}
+	if s.Build != nil {
+		progress("构建镜像 " + image)
+		return c.BuildImage(filepath.Join(p.Dir, s.Build.Context), s.Build.Dockerfile, image, progress)
+	}
+	progress("拉取镜像 " + image)
+	return c.PullImage(image, progress)
+}
+
+// Pull 拉取项目中所有服务的镜像（只构建的服务跳过）
+func (c *Client) Pull(p *Project, progress func(string)) error {
+	for _, s := range p.Services {
+		if s.Image == "" {
+			continue
+		}
+		if progress != nil {
+			progress("拉取镜像 " + s.Image)
+		}
+		if err := c.PullImage(s.Image, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop 停止项目中运行的容器，保留容器
+func (c *Client) Stop(p *Project) error {
+	list, err := c.ListContainers(false, LabelProject+"="+p.Name)
+	if err != nil {
+		return err
+	}
+	for _, ct := range list {
+		if err := c.StopContainer(ct.ID, 10); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down 停止并删除项目的容器和网络，命名卷保留
+func (c *Client) Down(p *Project, progress func(string)) error {
+	if progress == nil {
+		progress = func(string) {}
+	}
+	list, err := c.ListContainers(true, LabelProject+"="+p.Name)
+	if err != nil {
+		return err
+	}
+	for _, ct := range list {
+		progress("删除容器 " + ct.Name())
+		if ct.State == "running" {
+			if err := c.StopContainer(ct.ID, 10); err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		if err := c.RemoveContainer(ct.ID, true); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	networks, err := c.ListNetworks(LabelProject + "=" + p.Name)
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		progress("删除网络 " + n.Name)
+		if err := c.RemoveNetwork(n.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServiceState 服务容器的状态
+type ServiceState struct {
+	Service   string   `json:"service"`
+	Container string   `json:"container"`
+	ID        string   `json:"id"`
+	Image     string   `json:"image"`
+	State     string   `json:"state"` // created、running、exited 等，未创建时为空
+	Status    string   `json:"status"`
+	Ports     []string `json:"ports"`
+}
+
+// PS 项目中每个服务的容器状态，按 compose 文件中的顺序；文件中已删除的服务排在最后
+func (c *Client) PS(p *Project) ([]ServiceState, error) {
+	existing, err := c.containers(p)
+	if err != nil {
+		return nil, err
+	}
+	list := []ServiceState{}
+	add := func(service string) {
+		st := ServiceState{Service: service, Ports: []string{}}
+		if ct, ok := existing[service]; ok {
+			st.Container, st.ID, st.Image, st.State, st.Status = ct.Name(), shortID(ct.ID), ct.Image, ct.State, ct.Status
+			for _, port := range ct.Ports {
+				st.Ports = append(st.Ports, port.String())
+			}
+			delete(existing, service)
+		} else if s := p.Service(service); s != nil {
+			st.Container, st.Image = p.containerName(s), p.imageName(s)
+		}
+		list = append(list, st)
+	}
+	for _, s := range p.Services {
+		add(s.Name)
+	}
+	orphans := make([]string, 0, len(existing))
+	for service := range existing {
+		orphans = append(orphans, service)
+	}
+	sort.Strings(orphans)
+	for _, service := range orphans {
+		add(service)
+	}
+	return list, nil
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// Logs 把服务的日志写入 w；service 为空时输出所有服务，每行以 "服务名 | " 开头。
+// Follow 时持续输出直到 ctx 取消
+func (c *Client) Logs(ctx context.Context, p *Project, service string, opts LogsOptions, w io.Writer) error {
+	existing, err := c.containers(p)
+	if err != nil {
+		return err
+	}
+	var targets []string
+	if service != "" {
+		if _, ok := existing[service]; !ok {
+			return fmt.Errorf("服务 %s 没有容器: %w", service, ErrNotFound)
+		}
+		targets = []string{service}
+	} else {
+		for _, s := range p.Services {
+			if _, ok := existing[s.Name]; ok {
+				targets = append(targets, s.Name)
+			}
+		}
+	}
+
+	width := 0
+	for _, name := range targets {
+		width = max(width, len(name))
+	}
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		first error
+	)
+	for _, name := range targets {
+		out := &prefixWriter{mu: &mu, w: w}
+		if service == "" {
+			out.prefix = fmt.Sprintf("%-*s | ", width, name)
+		}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := c.ContainerLogs(ctx, id, opts, out)
+			if err == nil {
+				err = out.Flush()
+			}
+			mu.Lock()
+			if err != nil && first == nil {
+				first = err
+			}
+			mu.Unlock()
+		}(existing[name].ID)
+	}
+	wg.Wait()
+	return first
+}
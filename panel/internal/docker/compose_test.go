@@ -0,0 +1,306 @@
+package docker_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"site_manager_panel/internal/docker"
+	"site_manager_panel/internal/docker/dockertest"
+)
+
+const composeYAML = `services:
+  web:
+    image: node:20-alpine
+    working_dir: /app
+    command: node server.js --name "my app"
+    ports:
+      - "127.0.0.1:3000:3000"
+      - 9229
+    volumes:
+      - ./src:/app:ro
+      - data:/data
+    environment:
+      - PORT=3000
+      - NODE_ENV=production
+    restart: unless-stopped
+    depends_on:
+      - redis
+  redis:
+    image: redis:7
+    environment:
+      APPENDONLY: "yes"
+`
+
+func TestParseProject(t *testing.T) {
+	p, err := docker.ParseProject("appexamplecom", "/www/docker/app.example.com", []byte(composeYAML))
+	if err != nil {
+		t.Fatalf("ParseProject failed: %v", err)
+	}
+	if len(p.Services) != 2 || p.Services[0].Name != "web" || p.Services[1].Name != "redis" {
+		t.Fatalf("Expected services in file order, got %+v", p.Services)
+	}
+	web := p.Service("web")
+	if strings.Join(web.Command, "|") != "node|server.js|--name|my app" {
+		t.Errorf("Unexpected command: %q", web.Command)
+	}
+	if web.Environment["PORT"] != "3000" || p.Service("redis").Environment["APPENDONLY"] != "yes" {
+		t.Errorf("Unexpected environment: %v", web.Environment)
+	}
+	if p.PublishedPort() != 3000 {
+		t.Errorf("Expected published port 3000, got %d", p.PublishedPort())
+	}
+	if name := docker.ProjectName("/www/docker/App.Example.com"); name != "appexamplecom" {
+		t.Errorf("Unexpected project name: %s", name)
+	}
+
+	m, err := docker.ParsePort("8080:80/udp")
+	if err != nil || m.HostPort != 8080 || m.ContainerPort != 80 || m.Protocol != "udp" {
+		t.Errorf("Unexpected port mapping: %+v %v", m, err)
+	}
+
+	for name, data := range map[string]string{
+		"no services":   "version: '3'\n",
+		"no image":      "services:\n  web:\n    ports: ['80']\n",
+		"bad port":      "services:\n  web:\n    image: nginx\n    ports: ['80:abc']\n",
+		"unknown dep":   "services:\n  web:\n    image: nginx\n    depends_on: [db]\n",
+		"cycle":         "services:\n  a:\n    image: nginx\n    depends_on: [b]\n  b:\n    image: nginx\n    depends_on: [a]\n",
+		"invalid yaml":  "services: [\n",
+		"long syntax":   "services:\n  web:\n    image: nginx\n    ports:\n      - target: 80\n",
+		"bad volume":    "services:\n  web:\n    image: nginx\n    volumes: ['./a::ro']\n",
+		"unknown proto": "services:\n  web:\n    image: nginx\n    ports: ['80/sctp']\n",
+	} {
+		if _, err := docker.ParseProject("test", "/tmp", []byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestProjectCheckPaths(t *testing.T) {
+	root := t.TempDir()
+	dir, site := filepath.Join(root, "docker", "app.example.com"), filepath.Join(root, "wwwroot", "app.example.com")
+	os.MkdirAll(dir, 0755)
+	os.MkdirAll(site, 0755)
+	os.Symlink("/etc", filepath.Join(site, "etc"))
+
+	check := func(volume, context string) error {
+		data := "services:\n  web:\n    image: nginx\n    volumes: ['" + volume + "']\n"
+		if context != "" {
+			data = "services:\n  web:\n    build: " + context + "\n"
+		}
+		p, err := docker.ParseProject("test", dir, []byte(data))
+		if err != nil {
+			t.Fatalf("ParseProject failed: %v", err)
+		}
+		return p.CheckPaths(site)
+	}
+
+	for _, volume := range []string{"./src:/app:ro", "data:/data", site + ":/app", site + "/public:/app", "./:/compose"} {
+		if err := check(volume, ""); err != nil {
+			t.Errorf("%s: unexpected error: %v", volume, err)
+		}
+	}
+	for _, volume := range []string{
+		"/:/host",
+		"../../etc:/etc",
+		"./../../..:/host",
+		"/etc/passwd:/passwd",
+		"/var/run/docker.sock:/var/run/docker.sock",
+		"/run/docker.sock:/docker.sock",
+		site + "/../other.example.com:/app",
+		site + "/etc:/host-etc",
+	} {
+		if err := check(volume, ""); err == nil {
+			t.Errorf("%s: expected error", volume)
+		}
+	}
+	if err := check("", "./src"); err != nil {
+		t.Errorf("Unexpected error for build context: %v", err)
+	}
+	if err := check("", "/"); err == nil || !strings.Contains(err.Error(), "宿主机路径") {
+		t.Errorf("Expected build context outside the project to be rejected, got %v", err)
+	}
+}
+
+func TestComposeLifecycle(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	if err := client.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "app.example.com")
+	os.MkdirAll(dir, 0755)
+	p, err := docker.ParseProject(docker.ProjectName(dir), dir, []byte(composeYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 镜像不存在时拉取，被依赖的服务先创建
+	server.Registry = map[string]bool{"node:20-alpine": true}
+	if err := client.Up(p, nil); err == nil || !strings.Contains(err.Error(), "pull access denied") {
+		t.Fatalf("Expected pull error, got %v", err)
+	}
+	server.Registry["redis:7"] = true
+	var output []string
+	if err := client.Up(p, func(line string) { output = append(output, line) }); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	log := strings.Join(output, "\n")
+	if strings.Join(server.Networks(), ",") != "appexamplecom_default" || strings.Index(log, "创建容器 appexamplecom-redis-1") > strings.Index(log, "创建容器 appexamplecom-web-1") {
+		t.Errorf("Unexpected progress:\n%s", log)
+	}
+	if server.State("appexamplecom-web-1") != "running" || server.State("appexamplecom-redis-1") != "running" {
+		t.Fatal("Expected containers to be running")
+	}
+	cfg := server.Config("appexamplecom-web-1")
+	if strings.Join(cfg.HostConfig.Binds, ",") != filepath.Join(dir, "src")+":/app:ro,appexamplecom_data:/data" ||
+		cfg.HostConfig.PortBindings["3000/tcp"][0].HostIP != "127.0.0.1" || cfg.HostConfig.RestartPolicy.Name != "unless-stopped" ||
+		strings.Join(cfg.Env, ",") != "NODE_ENV=production,PORT=3000" || cfg.Labels[docker.LabelService] != "web" {
+		t.Errorf("Unexpected container config: %+v %+v", cfg, cfg.HostConfig)
+	}
+
+	// 配置未变化时不重建，已停止的容器重新启动
+	if err := client.Stop(p); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	before := len(server.Calls())
+	if err := client.Up(p, nil); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	for _, call := range server.Calls()[before:] {
+		if call == "POST /containers/create" || call == "POST /images/create" {
+			t.Errorf("Unexpected call on second up: %s", call)
+		}
+	}
+	if server.State("appexamplecom-web-1") != "running" {
+		t.Error("Expected stopped container to be started")
+	}
+
+	// 修改配置后重建，删除的服务被移除
+	changed := strings.Replace(composeYAML, "PORT=3000", "PORT=3001", 1)
+	changed = changed[:strings.Index(changed, "    depends_on")]
+	p2, err := docker.ParseProject(p.Name, dir, []byte(changed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Up(p2, nil); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if cfg := server.Config("appexamplecom-web-1"); !strings.Contains(strings.Join(cfg.Env, ","), "PORT=3001") {
+		t.Errorf("Expected container to be recreated, got %v", cfg.Env)
+	}
+	if server.State("appexamplecom-redis-1") != "" {
+		t.Error("Expected orphan container to be removed")
+	}
+
+	ps, err := client.PS(p)
+	if err != nil {
+		t.Fatalf("PS failed: %v", err)
+	}
+	if len(ps) != 2 || ps[0].Service != "web" || ps[0].State != "running" || ps[1].State != "" ||
+		!strings.Contains(strings.Join(ps[0].Ports, ","), "127.0.0.1:3000->3000/tcp") {
+		t.Errorf("Unexpected ps: %+v", ps)
+	}
+
+	if err := client.Down(p, nil); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if server.State("appexamplecom-web-1") != "" || len(server.Networks()) != 0 {
+		t.Errorf("Expected containers and network to be removed, got networks %v", server.Networks())
+	}
+}
+
+func TestComposeBuildAndPull(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	dir := filepath.Join(t.TempDir(), "build.example.com")
+	os.MkdirAll(dir, 0755)
+	data := "services:\n  app:\n    build: .\n    ports: ['3000:3000']\n  cache:\n    image: redis\n"
+	p, err := docker.ParseProject(docker.ProjectName(dir), dir, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Up(p, nil); err == nil || !strings.Contains(err.Error(), "Dockerfile") {
+		t.Fatalf("Expected build to fail without Dockerfile, got %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM node:20-alpine\n"), 0644)
+	if err := client.Up(p, nil); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if !server.HasImage("buildexamplecom-app") || server.Config("buildexamplecom-app-1").Image != "buildexamplecom-app" {
+		t.Error("Expected image to be built and used")
+	}
+
+	// pull 只拉取指定了 image 的服务
+	before := len(server.Calls())
+	var output []string
+	if err := client.Pull(p, func(line string) { output = append(output, line) }); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	pulls := 0
+	for _, call := range server.Calls()[before:] {
+		if call == "POST /images/create" {
+			pulls++
+		}
+	}
+	if pulls != 1 || !strings.Contains(strings.Join(output, "\n"), "Downloaded newer image for redis:latest") {
+		t.Errorf("Unexpected pull: %d %v", pulls, output)
+	}
+}
+
+func TestComposeLogs(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	dir := filepath.Join(t.TempDir(), "logs.example.com")
+	data := "services:\n  web:\n    image: nginx\n  worker:\n    image: busybox\n    tty: true\n"
+	p, _ := docker.ParseProject(docker.ProjectName(dir), dir, []byte(data))
+	server.AddImage("nginx")
+	server.AddImage("busybox")
+	if err := client.Up(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	server.SetLogs("logsexamplecom-web-1", "GET / 200\nGET /favicon.ico 404\n")
+	server.SetLogs("logsexamplecom-worker-1", "job done")
+
+	var buf bytes.Buffer
+	if err := client.Logs(context.Background(), p, "", docker.LogsOptions{}, &buf); err != nil {
+		t.Fatalf("Logs failed: %v", err)
+	}
+	for _, line := range []string{"web    | GET / 200\n", "web    | GET /favicon.ico 404\n", "worker | job done\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected %q in logs:\n%s", line, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := client.Logs(context.Background(), p, "web", docker.LogsOptions{Tail: 1}, &buf); err != nil || buf.String() != "GET /favicon.ico 404\n" {
+		t.Errorf("Unexpected tail: %q %v", buf.String(), err)
+	}
+	if err := client.Logs(context.Background(), p, "db", docker.LogsOptions{}, &buf); !errors.Is(err, docker.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// follow 持续到 ctx 取消
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	buf.Reset()
+	start := time.Now()
+	if err := client.Logs(ctx, p, "web", docker.LogsOptions{Follow: true}, &buf); err != nil {
+		t.Errorf("Follow logs failed: %v", err)
+	}
+	if time.Since(start) < 150*time.Millisecond || !strings.Contains(buf.String(), "GET / 200") {
+		t.Errorf("Expected follow to block until cancelled, got %q after %v", buf.String(), time.Since(start))
+	}
+}
@@ -0,0 +1,812 @@
+// Package dockertest 提供监听 unix socket 的假 Docker Engine，用于测试 docker.Client 及依赖它的代码。
+// 它在内存中维护镜像、容器、数据卷和网络，实现面板用到的 Engine API 子集。
+// exec 会话像一个回显输入的 shell，收到 exit 一行时以退出码 0 结束。
+package dockertest
+
+import (
+	"archive/tar"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"site_manager_panel/internal/docker"
+)
+
+const prefix = "/v1.41"
+
+// Server 假 Docker Engine
+type Server struct {
+	Socket string
+
+	// Registry 可拉取的镜像，不在其中的镜像拉取失败；未设置时任何镜像都能拉取
+	Registry map[string]bool
+
+	mu         sync.Mutex
+	dir        string
+	listener   net.Listener
+	srv        *http.Server
+	images     map[string]bool
+	containers map[string]*container
+	networks   map[string]*docker.Network
+	volumes    map[string]*docker.Volume
+	execs      map[string]*execSession
+	logs       map[string]string
+	calls      []string
+}
+
+type execSession struct {
+	id        string
+	container string
+	config    docker.ExecConfig
+	running   bool
+	exitCode  int
+	cols      uint16
+	rows      uint16
+}
+
+// ExecRecord 容器中执行过的命令
+type ExecRecord struct {
+	Cmd     []string
+	Tty     bool
+	Running bool
+	Cols    uint16
+	Rows    uint16
+}
+
+type container struct {
+	id      string
+	name    string
+	created int64
+	state   string
+	config  docker.ContainerConfig
+}
+
+// NewServer 在临时目录中创建 socket 并开始监听
+func NewServer() *Server {
+	dir, err := os.MkdirTemp("", "dockertest")
+	if err != nil {
+		panic(err)
+	}
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		Socket:     socket,
+		dir:        dir,
+		listener:   listener,
+		images:     map[string]bool{},
+		containers: map[string]*container{},
+		networks:   map[string]*docker.Network{},
+		volumes:    map[string]*docker.Volume{},
+		execs:      map[string]*execSession{},
+		logs:       map[string]string{},
+	}
+	s.srv = &http.Server{Handler: http.HandlerFunc(s.serve)}
+	go s.srv.Serve(listener)
+	return s
+}
+
+// Close 停止服务并删除 socket
+func (s *Server) Close() {
+	s.srv.Close()
+	os.RemoveAll(s.dir)
+}
+
+// Client 连接到该服务的客户端
+func (s *Server) Client() *docker.Client {
+	return docker.NewClient(s.Socket)
+}
+
+// AddImage 添加本地镜像，未指定标签时为 latest
+func (s *Server) AddImage(ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.images[normalize(ref)] = true
+}
+
+// HasImage 本地是否有镜像
+func (s *Server) HasImage(ref string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.images[normalize(ref)]
+}
+
+// SetLogs 设置容器（按容器名）的日志输出
+func (s *Server) SetLogs(name, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs[name] = output
+}
+
+// Calls 按顺序返回请求记录，如 "POST /containers/create"
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.calls...)
+}
+
+// State 容器的状态（created、running、exited），容器不存在时为空
+func (s *Server) State(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.find(name); c != nil {
+		return c.state
+	}
+	return ""
+}
+
+// Config 容器创建时的参数，容器不存在时返回 nil
+func (s *Server) Config(name string) *docker.ContainerConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.find(name); c != nil {
+		cfg := c.config
+		return &cfg
+	}
+	return nil
+}
+
+// AddVolume 添加数据卷
+func (s *Server) AddVolume(name string, labels map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.volumes[name] = &docker.Volume{
+		Name:       name,
+		Driver:     "local",
+		Mountpoint: "/var/lib/docker/volumes/" + name + "/_data",
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		Scope:      "local",
+		Labels:     labels,
+	}
+}
+
+// Execs 在容器（按容器名）中执行过的命令，按开始顺序
+func (s *Server) Execs(name string) []ExecRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(name)
+	if c == nil {
+		return nil
+	}
+	var list []*execSession
+	for _, e := range s.execs {
+		if e.container == c.id {
+			list = append(list, e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+	records := []ExecRecord{}
+	for _, e := range list {
+		records = append(records, ExecRecord{Cmd: e.config.Cmd, Tty: e.config.Tty, Running: e.running, Cols: e.cols, Rows: e.rows})
+	}
+	return records
+}
+
+// Networks 网络名称列表
+func (s *Server) Networks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := []string{}
+	for _, n := range s.networks {
+		names = append(names, n.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func normalize(ref string) string {
+	if strings.Contains(ref, "@") || strings.LastIndex(ref, ":") > strings.LastIndex(ref, "/") {
+		return ref
+	}
+	return ref + ":latest"
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// find 按 ID、ID 前缀或名称查找容器，调用方持有锁
+func (s *Server) find(ref string) *container {
+	for _, c := range s.containers {
+		if c.id == ref || c.name == ref || (len(ref) >= 12 && strings.HasPrefix(c.id, ref)) {
+			return c
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"message": fmt.Sprintf(format, args...)})
+}
+
+// labelsMatch 是否满足 filters 中的所有 label 条件
+func labelsMatch(r *http.Request, labels map[string]string) bool {
+	var filters map[string][]string
+	if f := r.URL.Query().Get("filters"); f != "" {
+		json.Unmarshal([]byte(f), &filters)
+	}
+	for _, cond := range filters["label"] {
+		key, value, hasValue := strings.Cut(cond, "=")
+		v, ok := labels[key]
+		if !ok || (hasValue && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	s.mu.Lock()
+	s.calls = append(s.calls, r.Method+" "+path)
+	s.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "/_ping":
+		w.Write([]byte("OK"))
+	case path == "/version":
+		writeJSON(w, http.StatusOK, docker.Version{Version: "24.0.7", APIVersion: "1.43", Os: "linux", Arch: "amd64", KernelVersion: "6.1.0"})
+	case path == "/containers/json" && r.Method == "GET":
+		s.listContainers(w, r)
+	case path == "/containers/create" && r.Method == "POST":
+		s.createContainer(w, r)
+	case parts[0] == "containers" && len(parts) == 2 && r.Method == "DELETE":
+		s.removeContainer(w, r, parts[1])
+	case parts[0] == "containers" && len(parts) == 3:
+		s.containerAction(w, r, parts[1], parts[2])
+	case parts[0] == "exec" && len(parts) == 3:
+		s.execAction(w, r, parts[1], parts[2])
+	case path == "/images/json" && r.Method == "GET":
+		s.listImages(w)
+	case path == "/images/prune" && r.Method == "POST":
+		s.pruneImages(w, r)
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json") && r.Method == "GET":
+		s.inspectImage(w, strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json"))
+	case path == "/images/create" && r.Method == "POST":
+		s.pullImage(w, r)
+	case path == "/build" && r.Method == "POST":
+		s.buildImage(w, r)
+	case path == "/volumes" && r.Method == "GET":
+		s.listVolumes(w, r)
+	case path == "/networks" && r.Method == "GET":
+		s.listNetworks(w, r)
+	case path == "/networks/create" && r.Method == "POST":
+		s.createNetwork(w, r)
+	case parts[0] == "networks" && len(parts) == 2 && r.Method == "DELETE":
+		s.removeNetwork(w, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func (s *Server) listContainers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := r.URL.Query().Get("all") == "1"
+	list := []docker.Container{}
+	for _, c := range s.containers {
+		if (!all && c.state != "running") || !labelsMatch(r, c.config.Labels) {
+			continue
+		}
+		list = append(list, s.summary(c))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Names[0] < list[j].Names[0] })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) summary(c *container) docker.Container {
+	status := "Created"
+	switch c.state {
+	case "running":
+		status = "Up 5 minutes"
+	case "exited":
+		status = "Exited (0) 1 minute ago"
+	}
+	ct := docker.Container{
+		ID:      c.id,
+		Names:   []string{"/" + c.name},
+		Image:   c.config.Image,
+		Command: strings.Join(c.config.Cmd, " "),
+		Created: c.created,
+		State:   c.state,
+		Status:  status,
+		Ports:   []docker.Port{},
+		Labels:  c.config.Labels,
+	}
+	if c.config.HostConfig != nil {
+		for key, bindings := range c.config.HostConfig.PortBindings {
+			port, proto, _ := strings.Cut(key, "/")
+			private, _ := strconv.Atoi(port)
+			for _, b := range bindings {
+				public, _ := strconv.Atoi(b.HostPort)
+				ip := b.HostIP
+				if ip == "" {
+					ip = "0.0.0.0"
+				}
+				ct.Ports = append(ct.Ports, docker.Port{IP: ip, PrivatePort: private, PublicPort: public, Type: proto})
+			}
+		}
+	}
+	return ct
+}
+
+func (s *Server) createContainer(w http.ResponseWriter, r *http.Request) {
+	var cfg docker.ContainerConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "container_" + newID()[:8]
+	}
+	if s.find(name) != nil {
+		writeError(w, http.StatusConflict, `Conflict. The container name "/%s" is already in use`, name)
+		return
+	}
+	if !s.images[normalize(cfg.Image)] {
+		writeError(w, http.StatusNotFound, "No such image: %s", cfg.Image)
+		return
+	}
+	if cfg.HostConfig != nil && cfg.HostConfig.NetworkMode != "" && cfg.HostConfig.NetworkMode != "bridge" {
+		found := false
+		for _, n := range s.networks {
+			found = found || n.Name == cfg.HostConfig.NetworkMode
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "network %s not found", cfg.HostConfig.NetworkMode)
+			return
+		}
+	}
+	c := &container{id: newID(), name: name, created: time.Now().Unix(), state: "created", config: cfg}
+	s.containers[c.id] = c
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"Id": c.id, "Warnings": []string{}})
+}
+
+func (s *Server) removeContainer(w http.ResponseWriter, r *http.Request, ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.find(ref)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "No such container: %s", ref)
+		return
+	}
+	if c.state == "running" && r.URL.Query().Get("force") != "1" {
+		writeError(w, http.StatusConflict, "You cannot remove a running container %s. Stop the container before attempting removal or force remove", c.id)
+		return
+	}
+	delete(s.containers, c.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) containerAction(w http.ResponseWriter, r *http.Request, ref, action string) {
+	s.mu.Lock()
+	c := s.find(ref)
+	if c == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "No such container: %s", ref)
+		return
+	}
+	if action == "logs" && r.Method == "GET" {
+		output, tty := s.logs[c.name], c.config.Tty
+		s.mu.Unlock()
+		s.writeLogs(w, r, output, tty)
+		return
+	}
+	if action == "stats" && r.Method == "GET" {
+		id, name, running := c.id, c.name, c.state == "running"
+		s.mu.Unlock()
+		s.writeStats(w, r, id, name, running)
+		return
+	}
+	defer s.mu.Unlock()
+
+	switch {
+	case action == "json" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, docker.ContainerInfo{
+			ID:      c.id,
+			Name:    "/" + c.name,
+			Created: time.Unix(c.created, 0).UTC().Format(time.RFC3339Nano),
+			Image:   c.config.Image,
+			State:   docker.ContainerState{Status: c.state, Running: c.state == "running"},
+			Config:  c.config,
+		})
+	case action == "start" && r.Method == "POST":
+		if c.state == "running" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.state = "running"
+		w.WriteHeader(http.StatusNoContent)
+	case action == "stop" && r.Method == "POST":
+		if c.state != "running" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.state = "exited"
+		w.WriteHeader(http.StatusNoContent)
+	case action == "restart" && r.Method == "POST":
+		c.state = "running"
+		w.WriteHeader(http.StatusNoContent)
+	case action == "exec" && r.Method == "POST":
+		if c.state != "running" {
+			writeError(w, http.StatusConflict, "Container %s is not running", c.id)
+			return
+		}
+		var cfg docker.ExecConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil || len(cfg.Cmd) == 0 {
+			writeError(w, http.StatusBadRequest, "No exec command specified")
+			return
+		}
+		// ID 以创建时间开头，便于按顺序列出
+		e := &execSession{id: fmt.Sprintf("%020d", time.Now().UnixNano()) + newID()[:44], container: c.id, config: cfg}
+		s.execs[e.id] = e
+		writeJSON(w, http.StatusCreated, map[string]string{"Id": e.id})
+	default:
+		writeError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+// writeLogs 按 tail 截取日志；非 TTY 容器使用多路复用格式，follow 时保持连接直到客户端断开
+func (s *Server) writeLogs(w http.ResponseWriter, r *http.Request, output string, tty bool) {
+	lines := strings.SplitAfter(output, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("tail")); err == nil && n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	w.Header().Set("Content-Type", "application/vnd.docker.raw-stream")
+	w.WriteHeader(http.StatusOK)
+	for _, line := range lines {
+		if tty {
+			io.WriteString(w, line)
+			continue
+		}
+		header := make([]byte, 8)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+		w.Write(header)
+		io.WriteString(w, line)
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	if r.URL.Query().Get("follow") == "1" {
+		<-r.Context().Done()
+	}
+}
+
+// writeStats 返回固定的资源占用：CPU 10%、内存 80 MiB / 1 GiB；stream=1 时每 100ms 输出一次直到客户端断开
+func (s *Server) writeStats(w http.ResponseWriter, r *http.Request, id, name string, running bool) {
+	const mib = 1 << 20
+	stats := map[string]interface{}{
+		"id":   id,
+		"name": "/" + name,
+		"read": time.Now().UTC().Format(time.RFC3339Nano),
+		"cpu_stats": map[string]interface{}{
+			"cpu_usage":        map[string]uint64{"total_usage": 1500000000},
+			"system_cpu_usage": uint64(20000000000),
+			"online_cpus":      2,
+		},
+		"precpu_stats": map[string]interface{}{
+			"cpu_usage":        map[string]uint64{"total_usage": 1000000000},
+			"system_cpu_usage": uint64(10000000000),
+		},
+		"memory_stats": map[string]interface{}{
+			"usage": 100 * mib,
+			"limit": 1024 * mib,
+			"stats": map[string]uint64{"inactive_file": 20 * mib},
+		},
+		"networks":    map[string]interface{}{"eth0": map[string]uint64{"rx_bytes": 1000, "tx_bytes": 2000}},
+		"blkio_stats": map[string]interface{}{"io_service_bytes_recursive": []map[string]interface{}{{"op": "read", "value": 4096}, {"op": "write", "value": 8192}}},
+		"pids_stats":  map[string]uint64{"current": 5},
+	}
+	if !running {
+		stats = map[string]interface{}{"id": id, "name": "/" + name, "read": "0001-01-01T00:00:00Z"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	if r.URL.Query().Get("stream") != "1" {
+		enc.Encode(stats)
+		return
+	}
+	for {
+		if err := enc.Encode(stats); err != nil {
+			return
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (s *Server) execAction(w http.ResponseWriter, r *http.Request, id, action string) {
+	s.mu.Lock()
+	e := s.execs[id]
+	if e == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "No such exec instance: %s", id)
+		return
+	}
+	switch {
+	case action == "json" && r.Method == "GET":
+		info := map[string]interface{}{"ID": e.id, "Running": e.running, "ExitCode": e.exitCode, "ContainerID": e.container}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, info)
+	case action == "resize" && r.Method == "POST":
+		cols, _ := strconv.Atoi(r.URL.Query().Get("w"))
+		rows, _ := strconv.Atoi(r.URL.Query().Get("h"))
+		e.cols, e.rows = uint16(cols), uint16(rows)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	case action == "start" && r.Method == "POST":
+		e.running = true
+		s.mu.Unlock()
+		s.startExec(w, r, e)
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+// startExec 接管连接并回显输入，收到 exit 一行时结束
+func (s *Server) startExec(w http.ResponseWriter, r *http.Request, e *execSession) {
+	if r.Header.Get("Upgrade") != "tcp" {
+		writeError(w, http.StatusBadRequest, "fake engine only supports hijacked exec")
+		return
+	}
+	io.Copy(io.Discard, r.Body)
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	buf.Flush()
+
+	pending := ""
+	chunk := make([]byte, 1024)
+	for exited := false; !exited; {
+		n, err := buf.Read(chunk)
+		if err != nil {
+			break
+		}
+		conn.Write(chunk[:n])
+		pending += string(chunk[:n])
+		for {
+			i := strings.IndexAny(pending, "\r\n")
+			if i < 0 {
+				break
+			}
+			exited = exited || strings.TrimSpace(pending[:i]) == "exit"
+			pending = pending[i+1:]
+		}
+	}
+	s.mu.Lock()
+	e.running = false
+	s.mu.Unlock()
+}
+
+// imageID 按镜像名生成稳定的 ID
+func imageID(ref string) string {
+	h := fmt.Sprintf("%x", ref)
+	for len(h) < 64 {
+		h += h
+	}
+	return "sha256:" + h[:64]
+}
+
+// inUse 镜像是否被容器使用，调用方持有锁
+func (s *Server) inUse(ref string) bool {
+	for _, c := range s.containers {
+		if normalize(c.config.Image) == ref {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) listImages(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []docker.Image{}
+	for ref := range s.images {
+		containers := int64(0)
+		for _, c := range s.containers {
+			if normalize(c.config.Image) == ref {
+				containers++
+			}
+		}
+		list = append(list, docker.Image{
+			ID:         imageID(ref),
+			RepoTags:   []string{ref},
+			Created:    time.Now().Unix(),
+			Size:       int64(len(ref)) << 20,
+			Containers: containers,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RepoTags[0] < list[j].RepoTags[0] })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// pruneImages dangling=false 时删除所有未被容器使用的镜像；假 Engine 中没有悬空镜像
+func (s *Server) pruneImages(w http.ResponseWriter, r *http.Request) {
+	var filters map[string][]string
+	json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := docker.PruneReport{ImagesDeleted: []docker.ImageDeleted{}}
+	if len(filters["dangling"]) == 1 && filters["dangling"][0] == "false" {
+		for ref := range s.images {
+			if s.inUse(ref) {
+				continue
+			}
+			delete(s.images, ref)
+			report.ImagesDeleted = append(report.ImagesDeleted, docker.ImageDeleted{Untagged: ref}, docker.ImageDeleted{Deleted: imageID(ref)})
+			report.SpaceReclaimed += int64(len(ref)) << 20
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) listVolumes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []docker.Volume{}
+	for _, v := range s.volumes {
+		if labelsMatch(r, v.Labels) {
+			list = append(list, *v)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Volumes": list, "Warnings": nil})
+}
+
+func (s *Server) inspectImage(w http.ResponseWriter, ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.images[normalize(ref)] {
+		writeError(w, http.StatusNotFound, "No such image: %s", ref)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Id": "sha256:" + newID(), "RepoTags": []string{normalize(ref)}})
+}
+
+func (s *Server) pullImage(w http.ResponseWriter, r *http.Request) {
+	ref := r.URL.Query().Get("fromImage")
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		ref += ":" + tag
+	}
+	ref = normalize(ref)
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Registry != nil && !s.Registry[ref] {
+		enc.Encode(map[string]string{"error": "pull access denied for " + ref + ", repository does not exist"})
+		return
+	}
+	name, tag, _ := strings.Cut(ref, ":")
+	enc.Encode(map[string]string{"status": "Pulling from " + name, "id": tag})
+	enc.Encode(map[string]string{"status": "Download complete", "id": "a1b2c3d4e5f6"})
+	enc.Encode(map[string]string{"status": "Status: Downloaded newer image for " + ref})
+	s.images[ref] = true
+}
+
+// buildImage 读取上传的构建上下文，Dockerfile 存在时生成镜像
+func (s *Server) buildImage(w http.ResponseWriter, r *http.Request) {
+	dockerfile := r.URL.Query().Get("dockerfile")
+	found := false
+	tr := tar.NewReader(r.Body)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid build context: %v", err)
+			return
+		}
+		found = found || header.Name == dockerfile
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	if !found {
+		enc.Encode(map[string]string{"error": "Cannot locate specified Dockerfile: " + dockerfile})
+		return
+	}
+	tag := normalize(r.URL.Query().Get("t"))
+	enc.Encode(map[string]string{"stream": "Step 1/1 : FROM scratch\n"})
+	enc.Encode(map[string]string{"stream": "Successfully tagged " + tag + "\n"})
+	s.mu.Lock()
+	s.images[tag] = true
+	s.mu.Unlock()
+}
+
+func (s *Server) listNetworks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []docker.Network{}
+	for _, n := range s.networks {
+		if labelsMatch(r, n.Labels) {
+			list = append(list, *n)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) createNetwork(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string
+		Driver string
+		Labels map[string]string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range s.networks {
+		if n.Name == req.Name {
+			writeError(w, http.StatusConflict, "network with name %s already exists", req.Name)
+			return
+		}
+	}
+	subnet := fmt.Sprintf("172.%d.0.0/16", 18+len(s.networks))
+	n := &docker.Network{
+		ID:      newID(),
+		Name:    req.Name,
+		Created: time.Now().UTC().Format(time.RFC3339Nano),
+		Scope:   "local",
+		Driver:  req.Driver,
+		IPAM:    docker.IPAM{Config: []docker.IPAMConfig{{Subnet: subnet}}},
+		Labels:  req.Labels,
+	}
+	s.networks[n.ID] = n
+	writeJSON(w, http.StatusCreated, map[string]string{"Id": n.ID})
+}
+
+func (s *Server) removeNetwork(w http.ResponseWriter, ref string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, n := range s.networks {
+		if id == ref || n.Name == ref {
+			delete(s.networks, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "network %s not found", ref)
+}
@@ -0,0 +1,190 @@
+package docker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"site_manager_panel/internal/docker"
+	"site_manager_panel/internal/docker/dockertest"
+)
+
+// startContainer 创建并启动一个容器，返回容器 ID
+func startContainer(t *testing.T, client *docker.Client, name, image string) string {
+	t.Helper()
+	id, err := client.CreateContainer(name, &docker.ContainerConfig{Image: image})
+	if err != nil {
+		t.Fatalf("CreateContainer failed: %v", err)
+	}
+	if err := client.StartContainer(id); err != nil {
+		t.Fatalf("StartContainer failed: %v", err)
+	}
+	return id
+}
+
+func TestContainers(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	server.AddImage("nginx:alpine")
+
+	v, err := client.Version()
+	if err != nil || v.Version == "" {
+		t.Fatalf("Version failed: %+v %v", v, err)
+	}
+
+	id := startContainer(t, client, "web", "nginx:alpine")
+	if _, err := client.CreateContainer("idle", &docker.ContainerConfig{Image: "nginx:alpine"}); err != nil {
+		t.Fatal(err)
+	}
+	running, _ := client.ListContainers(false)
+	all, _ := client.ListContainers(true)
+	if len(running) != 1 || running[0].Name() != "web" || len(all) != 2 {
+		t.Fatalf("Unexpected containers: %+v %+v", running, all)
+	}
+
+	if err := client.StopContainer("web", 1); err != nil {
+		t.Fatalf("StopContainer failed: %v", err)
+	}
+	if err := client.RestartContainer(id[:12], 1); err != nil {
+		t.Fatalf("RestartContainer failed: %v", err)
+	}
+	info, err := client.InspectContainer("web")
+	if err != nil || !info.State.Running || info.ID != id {
+		t.Fatalf("Expected container to be running after restart: %+v %v", info, err)
+	}
+
+	err = client.RemoveContainer("web", false)
+	var apiErr *docker.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 409 {
+		t.Errorf("Expected conflict removing a running container, got %v", err)
+	}
+	if err := client.RemoveContainer("web", true); err != nil {
+		t.Fatalf("RemoveContainer failed: %v", err)
+	}
+	if _, err := client.InspectContainer("web"); !errors.Is(err, docker.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestImagesVolumesNetworks(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+
+	if err := client.PullImage("redis:7", nil); err != nil {
+		t.Fatalf("PullImage failed: %v", err)
+	}
+	server.AddImage("nginx")
+	startContainer(t, client, "web", "nginx")
+
+	images, err := client.ListImages(false)
+	if err != nil || len(images) != 2 || images[0].RepoTags[0] != "nginx:latest" || images[0].Containers != 1 || images[0].Dangling() {
+		t.Fatalf("Unexpected images: %+v %v", images, err)
+	}
+
+	// 默认只清理悬空镜像，all 时清理未被使用的镜像
+	if report, err := client.PruneImages(false); err != nil || len(report.ImagesDeleted) != 0 {
+		t.Errorf("Expected dangling prune to remove nothing: %+v %v", report, err)
+	}
+	report, err := client.PruneImages(true)
+	if err != nil || len(report.ImagesDeleted) != 2 || report.ImagesDeleted[0].Untagged != "redis:7" || report.SpaceReclaimed == 0 {
+		t.Fatalf("Unexpected prune report: %+v %v", report, err)
+	}
+	if server.HasImage("redis:7") || !server.HasImage("nginx") {
+		t.Error("Expected only the unused image to be pruned")
+	}
+
+	server.AddVolume("app_data", map[string]string{docker.LabelProject: "app"})
+	server.AddVolume("scratch", nil)
+	volumes, err := client.ListVolumes()
+	if err != nil || len(volumes) != 2 || volumes[0].Name != "app_data" || volumes[0].Driver != "local" {
+		t.Errorf("Unexpected volumes: %+v %v", volumes, err)
+	}
+	if volumes, _ := client.ListVolumes(docker.LabelProject + "=app"); len(volumes) != 1 {
+		t.Errorf("Expected label filter to match one volume, got %+v", volumes)
+	}
+
+	client.CreateNetwork("app_default", nil)
+	networks, err := client.ListNetworks()
+	if err != nil || len(networks) != 1 || networks[0].Scope != "local" || len(networks[0].IPAM.Config) != 1 {
+		t.Errorf("Unexpected networks: %+v %v", networks, err)
+	}
+}
+
+func TestContainerStats(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	server.AddImage("nginx")
+	startContainer(t, client, "web", "nginx")
+
+	stats, err := client.ContainerStats("web")
+	if err != nil {
+		t.Fatalf("ContainerStats failed: %v", err)
+	}
+	// (1.5e9-1e9) / (2e10-1e10) * 2 CPU = 10%，内存扣除 20 MiB inactive_file
+	if stats.Name != "web" || stats.CPUPercent < 9.99 || stats.CPUPercent > 10.01 || stats.MemoryUsage != 80<<20 ||
+		stats.MemoryLimit != 1<<30 || stats.NetworkRx != 1000 || stats.BlockWrite != 8192 || stats.PIDs != 5 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	count := 0
+	err = client.StreamStats(ctx, "web", func(s *docker.Stats) error {
+		if count++; count == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil || count < 3 {
+		t.Errorf("Expected streaming until cancelled, got %d samples, %v", count, err)
+	}
+}
+
+func TestExec(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	server.AddImage("nginx")
+	id, _ := client.CreateContainer("web", &docker.ContainerConfig{Image: "nginx"})
+
+	if _, err := client.Exec("web", docker.ExecConfig{Cmd: []string{"sh"}, Tty: true}); err == nil {
+		t.Error("Expected exec in a stopped container to fail")
+	}
+	client.StartContainer(id)
+
+	exec, err := client.Exec("web", docker.ExecConfig{Cmd: []string{"sh"}, Tty: true})
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	defer exec.Close()
+	if err := exec.Resize(120, 40); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	records := server.Execs("web")
+	if len(records) != 1 || records[0].Cmd[0] != "sh" || !records[0].Tty || !records[0].Running || records[0].Cols != 120 || records[0].Rows != 40 {
+		t.Errorf("Unexpected exec records: %+v", records)
+	}
+
+	io.WriteString(exec, "echo hi\r")
+	buf := make([]byte, 64)
+	n, err := exec.Read(buf)
+	if err != nil || string(buf[:n]) != "echo hi\r" {
+		t.Errorf("Expected echoed input, got %q %v", buf[:n], err)
+	}
+
+	io.WriteString(exec, "exit\r")
+	output, _ := io.ReadAll(exec)
+	if !strings.Contains(string(output), "exit") {
+		t.Errorf("Unexpected output before exit: %q", output)
+	}
+	code, running, err := exec.ExitCode()
+	if err != nil || running || code != 0 {
+		t.Errorf("Expected exec to finish with 0, got %d %v %v", code, running, err)
+	}
+}
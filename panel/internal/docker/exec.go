@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ExecConfig 在容器中执行命令的参数
+type ExecConfig struct {
+	Cmd        []string `json:"Cmd"`
+	User       string   `json:"User,omitempty"`
+	WorkingDir string   `json:"WorkingDir,omitempty"`
+	Env        []string `json:"Env,omitempty"`
+	// Tty 分配伪终端；为 false 时输出为 stdout / stderr 多路复用格式
+	Tty bool `json:"Tty"`
+}
+
+// Exec 已连接的 exec 会话，读写即为命令的输出和输入
+type Exec struct {
+	ID     string
+	client *Client
+	conn   io.ReadWriteCloser
+}
+
+// Exec 在运行中的容器内启动命令并连接其输入输出
+func (c *Client) Exec(container string, cfg ExecConfig) (*Exec, error) {
+	body := struct {
+		ExecConfig
+		AttachStdin  bool `json:"AttachStdin"`
+		AttachStdout bool `json:"AttachStdout"`
+		AttachStderr bool `json:"AttachStderr"`
+	}{cfg, true, true, true}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := c.call("POST", "/containers/"+url.PathEscape(container)+"/exec", nil, body, &created); err != nil {
+		return nil, err
+	}
+
+	// 带 Upgrade 头时 Engine 返回 101 并把连接交给 exec 的输入输出
+	req, err := newRequest(context.Background(), "POST", "/exec/"+created.ID+"/start", nil, map[string]bool{"Detach": false, "Tty": cfg.Tty})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		resp.Body.Close()
+		return nil, errors.New("docker: exec 连接未升级")
+	}
+	return &Exec{ID: created.ID, client: c, conn: conn}, nil
+}
+
+func (e *Exec) Read(p []byte) (int, error) {
+	return e.conn.Read(p)
+}
+
+func (e *Exec) Write(p []byte) (int, error) {
+	return e.conn.Write(p)
+}
+
+// Close 断开连接；命令是否结束取决于它对输入关闭的处理
+func (e *Exec) Close() error {
+	return e.conn.Close()
+}
+
+// Resize 调整伪终端窗口大小
+func (e *Exec) Resize(cols, rows uint16) error {
+	q := url.Values{"w": {strconv.Itoa(int(cols))}, "h": {strconv.Itoa(int(rows))}}
+	return e.client.call("POST", "/exec/"+e.ID+"/resize", q, nil, nil)
+}
+
+// ExitCode 命令的退出码，仍在运行时 running 为 true
+func (e *Exec) ExitCode() (code int, running bool, err error) {
+	var info struct {
+		Running  bool `json:"Running"`
+		ExitCode int  `json:"ExitCode"`
+	}
+	if err := e.client.call("GET", "/exec/"+e.ID+"/json", nil, nil, &info); err != nil {
+		return 0, false, err
+	}
+	return info.ExitCode, info.Running, nil
+}
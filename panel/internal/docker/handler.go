@@ -0,0 +1,232 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DockerHandler 容器、镜像、数据卷和网络管理；容器终端通过 /ws/terminal?container= 连接
+type DockerHandler struct {
+	client *Client
+}
+
+// NewDockerHandler 创建处理器
+func NewDockerHandler(client *Client) *DockerHandler {
+	return &DockerHandler{client: client}
+}
+
+// RegisterRoutes 注册路由
+func (h *DockerHandler) RegisterRoutes(router fiber.Router) {
+	d := router.Group("/docker")
+	d.Get("/info", h.Info)
+	d.Get("/containers", h.ListContainers)
+	d.Get("/containers/:id", h.InspectContainer)
+	d.Get("/containers/:id/stats", h.ContainerStats)
+	d.Post("/containers/:id/:action", h.ContainerAction)
+	d.Delete("/containers/:id", h.RemoveContainer)
+	d.Get("/stats", h.Stats)
+	d.Get("/images", h.ListImages)
+	d.Post("/images/pull", h.PullImage)
+	d.Post("/images/prune", h.PruneImages)
+	d.Get("/volumes", h.ListVolumes)
+	d.Get("/networks", h.ListNetworks)
+}
+
+// fail 把 Engine API 错误转换为响应：资源不存在为 404，冲突为 409，连接失败等为 500
+func fail(c *fiber.Ctx, message string, err error) error {
+	status := 500
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.Status == 404 || apiErr.Status == 409) {
+		status = apiErr.Status
+	}
+	return c.Status(status).JSON(fiber.Map{"status": false, "message": message + ": " + err.Error()})
+}
+
+// Info Docker Engine 版本；Docker 未运行时 running 为 false
+func (h *DockerHandler) Info(c *fiber.Ctx) error {
+	v, err := h.client.Version()
+	if err != nil {
+		return c.JSON(fiber.Map{"status": true, "data": fiber.Map{"running": false, "error": err.Error()}})
+	}
+	return c.JSON(fiber.Map{"status": true, "data": fiber.Map{"running": true, "version": v}})
+}
+
+// ListContainers 列出容器，all=1 时包括已停止的
+func (h *DockerHandler) ListContainers(c *fiber.Ctx) error {
+	list, err := h.client.ListContainers(c.QueryBool("all"))
+	if err != nil {
+		return fail(c, "获取容器列表失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "data": list})
+}
+
+// InspectContainer 容器详情
+func (h *DockerHandler) InspectContainer(c *fiber.Ctx) error {
+	info, err := h.client.InspectContainer(c.Params("id"))
+	if err != nil {
+		return fail(c, "获取容器详情失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "data": info})
+}
+
+// ContainerAction 启动、停止或重启容器，返回操作后的详情
+func (h *DockerHandler) ContainerAction(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var err error
+	action := c.Params("action")
+	switch action {
+	case "start":
+		err = h.client.StartContainer(id)
+	case "stop":
+		err = h.client.StopContainer(id, c.QueryInt("timeout", 10))
+	case "restart":
+		err = h.client.RestartContainer(id, c.QueryInt("timeout", 10))
+	default:
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的操作"})
+	}
+	if err != nil {
+		return fail(c, "执行 "+action+" 失败", err)
+	}
+	info, err := h.client.InspectContainer(id)
+	if err != nil {
+		return fail(c, "获取容器详情失败", err)
+	}
+	messages := map[string]string{"start": "容器已启动", "stop": "容器已停止", "restart": "容器已重启"}
+	return c.JSON(fiber.Map{"status": true, "message": messages[action], "data": info})
+}
+
+// RemoveContainer 删除容器，force=1 时先结束运行中的容器
+func (h *DockerHandler) RemoveContainer(c *fiber.Ctx) error {
+	if err := h.client.RemoveContainer(c.Params("id"), c.QueryBool("force")); err != nil {
+		return fail(c, "删除容器失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "容器已删除"})
+}
+
+// ContainerStats 容器资源占用；stream=1 时以每行一个 JSON 的形式持续输出直到客户端断开
+func (h *DockerHandler) ContainerStats(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if !c.QueryBool("stream") {
+		stats, err := h.client.ContainerStats(id)
+		if err != nil {
+			return fail(c, "获取资源占用失败", err)
+		}
+		return c.JSON(fiber.Map{"status": true, "data": stats})
+	}
+	// 先确认容器存在，之后的错误无法再改变状态码
+	if _, err := h.client.InspectContainer(id); err != nil {
+		return fail(c, "获取资源占用失败", err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		enc := json.NewEncoder(w)
+		h.client.StreamStats(ctx, id, func(s *Stats) error {
+			if err := enc.Encode(s); err != nil {
+				return err
+			}
+			return w.Flush()
+		})
+	})
+	return nil
+}
+
+// Stats 所有运行中容器的资源占用，并发采样
+func (h *DockerHandler) Stats(c *fiber.Ctx) error {
+	list, err := h.client.ListContainers(false)
+	if err != nil {
+		return fail(c, "获取容器列表失败", err)
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := []*Stats{}
+	for _, ct := range list {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if s, err := h.client.ContainerStats(id); err == nil {
+				mu.Lock()
+				result = append(result, s)
+				mu.Unlock()
+			}
+		}(ct.ID)
+	}
+	wg.Wait()
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return c.JSON(fiber.Map{"status": true, "data": result})
+}
+
+// ListImages 列出本地镜像
+func (h *DockerHandler) ListImages(c *fiber.Ctx) error {
+	list, err := h.client.ListImages(false)
+	if err != nil {
+		return fail(c, "获取镜像列表失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "data": list})
+}
+
+// PullImage 拉取镜像，返回拉取过程的输出
+func (h *DockerHandler) PullImage(c *fiber.Ctx) error {
+	var req struct {
+		Image string `json:"image"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	req.Image = strings.TrimSpace(req.Image)
+	if req.Image == "" || strings.ContainsAny(req.Image, " \t\n?#") {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的镜像名"})
+	}
+
+	var output []string
+	if err := h.client.PullImage(req.Image, func(line string) { output = append(output, line) }); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "拉取镜像失败: " + err.Error(), "output": output})
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "镜像已拉取", "output": output})
+}
+
+// PruneImages 清理镜像；all 为 true 时清理所有未被容器使用的镜像，否则只清理悬空镜像
+func (h *DockerHandler) PruneImages(c *fiber.Ctx) error {
+	var req struct {
+		All bool `json:"all"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+		}
+	}
+	report, err := h.client.PruneImages(req.All)
+	if err != nil {
+		return fail(c, "清理镜像失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "镜像已清理", "data": report})
+}
+
+// ListVolumes 列出数据卷
+func (h *DockerHandler) ListVolumes(c *fiber.Ctx) error {
+	list, err := h.client.ListVolumes()
+	if err != nil {
+		return fail(c, "获取数据卷列表失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "data": list})
+}
+
+// ListNetworks 列出网络
+func (h *DockerHandler) ListNetworks(c *fiber.Ctx) error {
+	list, err := h.client.ListNetworks()
+	if err != nil {
+		return fail(c, "获取网络列表失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "data": list})
+}
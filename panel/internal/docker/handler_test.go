@@ -0,0 +1,173 @@
+package docker_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/docker"
+	"site_manager_panel/internal/docker/dockertest"
+)
+
+type apiResult struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Output  []string        `json:"output"`
+}
+
+func newHandlerApp(t *testing.T, client *docker.Client) (*fiber.App, func(method, path, body string) (int, apiResult)) {
+	t.Helper()
+	app := fiber.New()
+	docker.NewDockerHandler(client).RegisterRoutes(app)
+	return app, func(method, path, body string) (int, apiResult) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		var result apiResult
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+}
+
+func TestDockerHandler(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	_, do := newHandlerApp(t, client)
+
+	status, result := do("GET", "/docker/info", "")
+	if status != 200 || !strings.Contains(string(result.Data), `"running":true`) {
+		t.Fatalf("Unexpected info: %d %s", status, result.Data)
+	}
+
+	if status, _ := do("POST", "/docker/images/pull", `{"image":""}`); status != 400 {
+		t.Errorf("Expected 400 for empty image, got %d", status)
+	}
+	status, result = do("POST", "/docker/images/pull", `{"image":"nginx:alpine"}`)
+	if status != 200 || len(result.Output) == 0 || !server.HasImage("nginx:alpine") {
+		t.Fatalf("Pull failed: %d %+v", status, result)
+	}
+	startContainer(t, client, "web", "nginx:alpine")
+
+	var containers []docker.Container
+	status, result = do("GET", "/docker/containers?all=1", "")
+	json.Unmarshal(result.Data, &containers)
+	if status != 200 || len(containers) != 1 || containers[0].Name() != "web" {
+		t.Fatalf("Unexpected containers: %d %+v", status, containers)
+	}
+
+	var info docker.ContainerInfo
+	status, result = do("POST", "/docker/containers/web/stop", "")
+	json.Unmarshal(result.Data, &info)
+	if status != 200 || info.State.Running {
+		t.Errorf("Stop failed: %d %+v", status, info)
+	}
+	status, result = do("POST", "/docker/containers/web/restart", "")
+	json.Unmarshal(result.Data, &info)
+	if status != 200 || !info.State.Running {
+		t.Errorf("Restart failed: %d %+v", status, info)
+	}
+	if status, _ := do("POST", "/docker/containers/web/pause", ""); status != 400 {
+		t.Errorf("Expected 400 for unknown action, got %d", status)
+	}
+	if status, _ := do("POST", "/docker/containers/missing/start", ""); status != 404 {
+		t.Errorf("Expected 404 for missing container, got %d", status)
+	}
+
+	var stats []docker.Stats
+	status, result = do("GET", "/docker/stats", "")
+	json.Unmarshal(result.Data, &stats)
+	if status != 200 || len(stats) != 1 || stats[0].Name != "web" || stats[0].MemoryUsage == 0 {
+		t.Errorf("Unexpected stats: %d %+v", status, stats)
+	}
+
+	server.AddVolume("data", nil)
+	var volumes []docker.Volume
+	_, result = do("GET", "/docker/volumes", "")
+	json.Unmarshal(result.Data, &volumes)
+	if len(volumes) != 1 || volumes[0].Name != "data" {
+		t.Errorf("Unexpected volumes: %+v", volumes)
+	}
+	if status, result := do("GET", "/docker/networks", ""); status != 200 || string(result.Data) != "[]" {
+		t.Errorf("Unexpected networks: %d %s", status, result.Data)
+	}
+
+	if status, _ := do("DELETE", "/docker/containers/web", ""); status != 409 {
+		t.Errorf("Expected 409 removing a running container, got %d", status)
+	}
+	if status, _ := do("DELETE", "/docker/containers/web?force=1", ""); status != 200 || server.State("web") != "" {
+		t.Errorf("Force remove failed: %d", status)
+	}
+
+	var report docker.PruneReport
+	status, result = do("POST", "/docker/images/prune", `{"all":true}`)
+	json.Unmarshal(result.Data, &report)
+	if status != 200 || len(report.ImagesDeleted) != 2 || server.HasImage("nginx:alpine") {
+		t.Errorf("Prune failed: %d %+v", status, report)
+	}
+}
+
+func TestDockerHandlerStreamStats(t *testing.T) {
+	server := dockertest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	app, do := newHandlerApp(t, client)
+	server.AddImage("nginx")
+	startContainer(t, client, "web", "nginx")
+
+	if status, _ := do("GET", "/docker/containers/missing/stats?stream=1", ""); status != 404 {
+		t.Errorf("Expected 404 for missing container, got %d", status)
+	}
+
+	// 流式响应需要真实连接，app.Test 会等待响应结束
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/docker/containers/web/stats?stream=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		var s docker.Stats
+		if err != nil || json.Unmarshal([]byte(line), &s) != nil || s.Name != "web" || s.CPUPercent == 0 {
+			t.Fatalf("Unexpected stats line %d: %q %v", i, line, err)
+		}
+	}
+}
+
+func TestDockerHandlerUnavailable(t *testing.T) {
+	server := dockertest.NewServer()
+	client := server.Client()
+	server.Close()
+	_, do := newHandlerApp(t, client)
+
+	status, result := do("GET", "/docker/info", "")
+	if status != 200 || !strings.Contains(string(result.Data), `"running":false`) {
+		t.Errorf("Expected info to report docker not running: %d %s", status, result.Data)
+	}
+	if status, result := do("GET", "/docker/containers", ""); status != 500 || !strings.Contains(result.Message, "连接 Docker 失败") {
+		t.Errorf("Expected 500 when docker is unavailable, got %d %+v", status, result)
+	}
+}
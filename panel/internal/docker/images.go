@@ -0,0 +1,62 @@
+package docker
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// Image 镜像列表项
+type Image struct {
+	ID          string            `json:"Id"`
+	ParentID    string            `json:"ParentId"`
+	RepoTags    []string          `json:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests"`
+	Created     int64             `json:"Created"`
+	Size        int64             `json:"Size"`
+	Containers  int64             `json:"Containers"`
+	Labels      map[string]string `json:"Labels"`
+}
+
+// Dangling 没有标签的悬空镜像
+func (i *Image) Dangling() bool {
+	return len(i.RepoTags) == 0 || (len(i.RepoTags) == 1 && i.RepoTags[0] == "<none>:<none>")
+}
+
+// ListImages 列出本地镜像，all 为 false 时不含中间层镜像
+func (c *Client) ListImages(all bool) ([]Image, error) {
+	q := url.Values{}
+	if all {
+		q.Set("all", "1")
+	}
+	var list []Image
+	if err := c.call("GET", "/images/json", q, nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ImageDeleted 删除镜像时移除的标签或镜像层
+type ImageDeleted struct {
+	Untagged string `json:"Untagged,omitempty"`
+	Deleted  string `json:"Deleted,omitempty"`
+}
+
+// PruneReport 清理结果
+type PruneReport struct {
+	ImagesDeleted  []ImageDeleted `json:"ImagesDeleted"`
+	SpaceReclaimed int64          `json:"SpaceReclaimed"`
+}
+
+// PruneImages 清理未使用的镜像：all 为 false 时只清理悬空镜像，为 true 时清理所有未被容器使用的镜像
+func (c *Client) PruneImages(all bool) (*PruneReport, error) {
+	dangling := "true"
+	if all {
+		dangling = "false"
+	}
+	f, _ := json.Marshal(map[string][]string{"dangling": {dangling}})
+	var report PruneReport
+	if err := c.call("POST", "/images/prune", url.Values{"filters": {string(f)}}, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// LogsOptions 读取容器日志的参数
+type LogsOptions struct {
+	Tail       int  // 最后若干行，0 表示全部
+	Follow     bool // 持续输出新日志，直到 ctx 取消或容器退出
+	Timestamps bool
+}
+
+func (o LogsOptions) query() url.Values {
+	q := url.Values{"stdout": {"1"}, "stderr": {"1"}, "tail": {"all"}}
+	if o.Tail > 0 {
+		q.Set("tail", strconv.Itoa(o.Tail))
+	}
+	if o.Follow {
+		q.Set("follow", "1")
+	}
+	if o.Timestamps {
+		q.Set("timestamps", "1")
+	}
+	return q
+}
+
+// ContainerLogs 把容器的 stdout / stderr 日志写入 w
+func (c *Client) ContainerLogs(ctx context.Context, id string, opts LogsOptions, w io.Writer) error {
+	info, err := c.InspectContainer(id)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, "GET", "/containers/"+url.PathEscape(id)+"/logs", opts.query(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if info.Config.Tty {
+		_, err = io.Copy(w, resp.Body)
+	} else {
+		err = demux(w, resp.Body)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// demux 拆分非 TTY 容器的多路复用输出：每帧 8 字节头（流类型、3 字节填充、4 字节大端长度）后跟数据
+func demux(w io.Writer, r io.Reader) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// prefixWriter 在每行前加上服务名，多个容器的日志并发写入同一个 Writer
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(data), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush 写出最后不完整的一行
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.writeLine(line)
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := io.WriteString(p.w, p.prefix); err != nil {
+		return err
+	}
+	if _, err := p.w.Write(line); err != nil {
+		return err
+	}
+	if f, ok := p.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
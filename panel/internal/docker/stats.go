@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Stats 容器资源占用，计算方式与 docker stats 相同
+type Stats struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	Read          string  `json:"read"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryUsage   uint64  `json:"memory_usage"`
+	MemoryLimit   uint64  `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+	NetworkRx     uint64  `json:"network_rx"`
+	NetworkTx     uint64  `json:"network_tx"`
+	BlockRead     uint64  `json:"block_read"`
+	BlockWrite    uint64  `json:"block_write"`
+	PIDs          uint64  `json:"pids"`
+}
+
+// rawStats /containers/{id}/stats 返回的原始数据
+type rawStats struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Read        string   `json:"read"`
+	CPUStats    cpuStats `json:"cpu_stats"`
+	PreCPUStats cpuStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+	PidsStats struct {
+		Current uint64 `json:"current"`
+	} `json:"pids_stats"`
+}
+
+type cpuStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint64 `json:"online_cpus"`
+}
+
+// convert 按 docker CLI 的算法换算：CPU 为两次采样间的占比乘以 CPU 数，
+// 内存扣除页缓存（cgroup v2 为 inactive_file，v1 为 cache）
+func (r *rawStats) convert() *Stats {
+	s := &Stats{
+		ID:          r.ID,
+		Name:        strings.TrimPrefix(r.Name, "/"),
+		Read:        r.Read,
+		MemoryLimit: r.MemoryStats.Limit,
+		PIDs:        r.PidsStats.Current,
+	}
+
+	cpuDelta := float64(r.CPUStats.CPUUsage.TotalUsage) - float64(r.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(r.CPUStats.SystemUsage) - float64(r.PreCPUStats.SystemUsage)
+	cpus := float64(r.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(r.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		s.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	s.MemoryUsage = r.MemoryStats.Usage
+	cache, ok := r.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		cache = r.MemoryStats.Stats["cache"]
+	}
+	if cache < s.MemoryUsage {
+		s.MemoryUsage -= cache
+	}
+	if s.MemoryLimit > 0 {
+		s.MemoryPercent = float64(s.MemoryUsage) / float64(s.MemoryLimit) * 100
+	}
+
+	for _, n := range r.Networks {
+		s.NetworkRx += n.RxBytes
+		s.NetworkTx += n.TxBytes
+	}
+	for _, b := range r.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(b.Op) {
+		case "read":
+			s.BlockRead += b.Value
+		case "write":
+			s.BlockWrite += b.Value
+		}
+	}
+	return s
+}
+
+// ContainerStats 获取一次容器资源占用；Engine 需要两次采样计算 CPU，约耗时 1 秒
+func (c *Client) ContainerStats(id string) (*Stats, error) {
+	var raw rawStats
+	q := url.Values{"stream": {"0"}}
+	if err := c.call("GET", "/containers/"+url.PathEscape(id)+"/stats", q, nil, &raw); err != nil {
+		return nil, err
+	}
+	return raw.convert(), nil
+}
+
+// StreamStats 持续读取容器资源占用（约每秒一次）直到 ctx 取消或容器停止；ctx 取消时返回 nil
+func (c *Client) StreamStats(ctx context.Context, id string, fn func(*Stats) error) error {
+	resp, err := c.do(ctx, "GET", "/containers/"+url.PathEscape(id)+"/stats", url.Values{"stream": {"1"}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var raw rawStats
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := fn(raw.convert()); err != nil {
+			return err
+		}
+	}
+}
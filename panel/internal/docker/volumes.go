@@ -0,0 +1,25 @@
+package docker
+
+// Volume 数据卷
+type Volume struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	CreatedAt  string            `json:"CreatedAt"`
+	Scope      string            `json:"Scope"`
+	Labels     map[string]string `json:"Labels"`
+}
+
+// ListVolumes 列出数据卷；labels 形如 key=value
+func (c *Client) ListVolumes(labels ...string) ([]Volume, error) {
+	var resp struct {
+		Volumes []Volume `json:"Volumes"`
+	}
+	if err := c.call("GET", "/volumes", labelFilter(labels...), nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Volumes == nil {
+		resp.Volumes = []Volume{}
+	}
+	return resp.Volumes, nil
+}
@@ -1,6 +1,8 @@
 package firewall
 
 import (
+	"fmt"
+	"net"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -304,3 +306,35 @@ func parseRules(output string) []Rule {
 
 	return rules
 }
+
+// DenyIP 插入一条拒绝来源 IP 的规则（置于首位，优先于端口放行规则）
+func DenyIP(ip, comment string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fmt.Errorf("无效的 IP: %s", ip)
+	}
+	if parsed.IsLoopback() {
+		return fmt.Errorf("禁止封禁本机地址")
+	}
+
+	args := []string{"insert", "1", "deny", "from", parsed.String()}
+	if comment != "" {
+		args = append(args, "comment", comment)
+	}
+	if out, err := exec.Command("ufw", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RemoveDenyIP 删除 DenyIP 添加的规则
+func RemoveDenyIP(ip string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fmt.Errorf("无效的 IP: %s", ip)
+	}
+	if out, err := exec.Command("ufw", "delete", "deny", "from", parsed.String()).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Package layout 统一面板与 CLI 的站点文件约定。
+//
+// 历史上存在两种布局：
+//   - 面板：sites-available/<domain>，日志 /www/wwwlogs/<domain>_access.log
+//   - CLI：sites-available/<domain>.conf，日志 /www/wwwlogs/nginx/<domain>.access.log
+//
+// CLI 布局为规范布局，新站点一律使用它；Resolve 同时识别两种布局，
+// Migrate 将旧布局的站点迁移到规范布局。
+package layout
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"site_manager_panel/internal/nginx"
+)
+
+// Kind 站点布局类型
+type Kind string
+
+const (
+	Panel Kind = "panel"
+	CLI   Kind = "cli"
+
+	Canonical = CLI
+)
+
+const disabledSuffix = ".disabled"
+
+// 目录均为变量，便于测试时替换
+var (
+	ConfigDir  = "/etc/nginx/sites-available"
+	EnabledDir = "/etc/nginx/sites-enabled"
+	LogsDir    = "/www/wwwlogs"
+)
+
+var (
+	accessLogRe = regexp.MustCompile(`(?m)^\s*access_log\s+([^\s;]+)`)
+	errorLogRe  = regexp.MustCompile(`(?m)^\s*error_log\s+([^\s;]+)`)
+)
+
+// Paths 站点在磁盘上的各个文件位置
+type Paths struct {
+	Domain    string `json:"domain"`
+	Layout    Kind   `json:"layout"`
+	Config    string `json:"config"`  // sites-available 中的配置文件（CLI 禁用时带 .disabled 后缀）
+	Enabled   string `json:"enabled"` // sites-enabled 中的软链接
+	AccessLog string `json:"access_log"`
+	ErrorLog  string `json:"error_log"`
+}
+
+// For 返回指定布局下站点应有的路径
+func For(kind Kind, domain string) *Paths {
+	if kind == Panel {
+		return &Paths{
+			Domain:    domain,
+			Layout:    Panel,
+			Config:    filepath.Join(ConfigDir, domain),
+			Enabled:   filepath.Join(EnabledDir, domain),
+			AccessLog: filepath.Join(LogsDir, domain+"_access.log"),
+			ErrorLog:  filepath.Join(LogsDir, domain+"_error.log"),
+		}
+	}
+	return &Paths{
+		Domain:    domain,
+		Layout:    CLI,
+		Config:    filepath.Join(ConfigDir, domain+".conf"),
+		Enabled:   filepath.Join(EnabledDir, domain+".conf"),
+		AccessLog: filepath.Join(LogsDir, "nginx", domain+".access.log"),
+		ErrorLog:  filepath.Join(LogsDir, "nginx", domain+".error.log"),
+	}
+}
+
+// Resolve 查找站点现有的布局；站点不存在时返回规范布局
+func Resolve(domain string) *Paths {
+	p := For(Canonical, domain)
+	switch {
+	case exists(p.Config):
+	case exists(p.Config + disabledSuffix):
+		p.Config += disabledSuffix
+	case exists(filepath.Join(ConfigDir, domain)):
+		p = For(Panel, domain)
+	default:
+		return p
+	}
+
+	// 日志以配置中的 access_log / error_log 为准。配置可以通过面板编辑，而日志以 root 读取，
+	// 只接受 LogsDir 之下的路径，其他位置仍使用布局的默认路径
+	if content, err := os.ReadFile(p.Config); err == nil {
+		if m := accessLogRe.FindSubmatch(content); m != nil && inLogsDir(string(m[1])) {
+			p.AccessLog = string(m[1])
+		}
+		if m := errorLogRe.FindSubmatch(content); m != nil && inLogsDir(string(m[1])) {
+			p.ErrorLog = string(m[1])
+		}
+	}
+	return p
+}
+
+// inLogsDir 路径解析符号链接后是否位于 LogsDir 之下
+func inLogsDir(path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	rel, err := filepath.Rel(resolvePath(LogsDir), resolvePath(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// resolvePath 解析路径中的符号链接，末尾不存在的部分原样保留
+func resolvePath(path string) string {
+	rest := ""
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if dir == filepath.Dir(dir) {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// Exists 配置文件是否存在
+func (p *Paths) Exists() bool {
+	return exists(p.Config)
+}
+
+// IsEnabled 站点是否启用：配置未被 CLI 重命名为 .disabled，且存在 sites-enabled 链接
+func (p *Paths) IsEnabled() bool {
+	if strings.HasSuffix(p.Config, disabledSuffix) {
+		return false
+	}
+	return exists(p.Enabled) || exists(filepath.Join(EnabledDir, p.Domain)) ||
+		exists(filepath.Join(EnabledDir, p.Domain+".conf"))
+}
+
+// ActiveConfig 启用状态下配置文件的路径（去掉 .disabled 后缀）
+func (p *Paths) ActiveConfig() string {
+	return strings.TrimSuffix(p.Config, disabledSuffix)
+}
+
+// LogFile 返回 access 或 error 日志路径
+func (p *Paths) LogFile(kind string) string {
+	if kind == "error" {
+		return p.ErrorLog
+	}
+	return p.AccessLog
+}
+
+// Unlink 在事务中删除两种布局下的 sites-enabled 链接
+func Unlink(tx *nginx.Tx, domain string) {
+	for _, kind := range []Kind{Panel, CLI} {
+		tx.Remove(For(kind, domain).Enabled)
+	}
+}
+
+// Remove 在事务中删除站点在两种布局下的配置文件和链接
+func Remove(tx *nginx.Tx, domain string) {
+	Unlink(tx, domain)
+	for _, kind := range []Kind{Panel, CLI} {
+		config := For(kind, domain).Config
+		tx.Remove(config)
+		tx.Remove(config + disabledSuffix)
+	}
+}
+
+// DomainFromConfig 从配置文件名解析域名，非站点配置返回空
+func DomainFromConfig(name string) string {
+	if name == "default" || strings.HasSuffix(name, ".bak") {
+		return ""
+	}
+	name = strings.TrimSuffix(name, disabledSuffix)
+	return strings.TrimSuffix(name, ".conf")
+}
+
+// Domains 列出配置目录中的所有站点（两种布局）
+func Domains() ([]string, error) {
+	entries, err := os.ReadDir(ConfigDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	domains := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if d := DomainFromConfig(e.Name()); d != "" && !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
+
+// SiteLog 站点日志文件
+type SiteLog struct {
+	Domain string
+	Kind   string // access 或 error
+	Path   string
+	Size   int64
+}
+
+// SiteLogs 扫描两种布局的站点日志
+func SiteLogs() []SiteLog {
+	logs := []SiteLog{}
+	scan := func(dir, accessSuffix, errorSuffix string) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			l := SiteLog{Path: filepath.Join(dir, e.Name())}
+			switch name := e.Name(); {
+			case strings.HasSuffix(name, accessSuffix):
+				l.Domain, l.Kind = strings.TrimSuffix(name, accessSuffix), "access"
+			case strings.HasSuffix(name, errorSuffix):
+				l.Domain, l.Kind = strings.TrimSuffix(name, errorSuffix), "error"
+			default:
+				continue
+			}
+			// 域名不含下划线，排除 <domain>_node_error.log 这类进程日志
+			if strings.Contains(l.Domain, "_") {
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				l.Size = info.Size()
+			}
+			logs = append(logs, l)
+		}
+	}
+
+	scan(filepath.Join(LogsDir, "nginx"), ".access.log", ".error.log")
+	scan(LogsDir, "_access.log", "_error.log")
+
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].Domain != logs[j].Domain {
+			return logs[i].Domain < logs[j].Domain
+		}
+		return logs[i].Kind < logs[j].Kind
+	})
+	return logs
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,189 @@
+package layout
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"site_manager_panel/internal/nginx"
+)
+
+func setupDirs(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	oldConfig, oldEnabled, oldLogs := ConfigDir, EnabledDir, LogsDir
+	oldMain, oldTest, oldReload := nginx.MainConfig, nginx.Test, nginx.Reload
+	t.Cleanup(func() {
+		ConfigDir, EnabledDir, LogsDir = oldConfig, oldEnabled, oldLogs
+		nginx.MainConfig, nginx.Test, nginx.Reload = oldMain, oldTest, oldReload
+	})
+
+	ConfigDir = filepath.Join(root, "sites-available")
+	EnabledDir = filepath.Join(root, "sites-enabled")
+	LogsDir = filepath.Join(root, "wwwlogs")
+	for _, dir := range []string{ConfigDir, EnabledDir, filepath.Join(LogsDir, "nginx")} {
+		os.MkdirAll(dir, 0755)
+	}
+	nginx.MainConfig = filepath.Join(root, "nginx.conf")
+	writeFile(t, nginx.MainConfig, "http {\n    include "+EnabledDir+"/*;\n}\n")
+	nginx.Test = func(string) error { return nil }
+	nginx.Reload = func() error { return nil }
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func panelSite(t *testing.T, domain string) *Paths {
+	t.Helper()
+	p := For(Panel, domain)
+	writeFile(t, p.Config, "server {\n    server_name "+domain+";\n    access_log "+p.AccessLog+";\n    error_log "+p.ErrorLog+" warn;\n}\n")
+	os.Symlink(p.Config, p.Enabled)
+	return p
+}
+
+func TestResolve(t *testing.T) {
+	setupDirs(t)
+
+	panelSite(t, "panel.example.com")
+	cli := For(CLI, "cli.example.com")
+	custom := filepath.Join(LogsDir, "custom", "cli.log")
+	writeFile(t, cli.Config+".disabled", "server {\n    access_log "+custom+" main;\n    access_log off;\n}\n")
+
+	p := Resolve("panel.example.com")
+	if p.Layout != Panel || !p.Exists() || !p.IsEnabled() || p.IsCanonical() {
+		t.Errorf("Unexpected panel paths: %+v", p)
+	}
+	if p.LogFile("access") != filepath.Join(LogsDir, "panel.example.com_access.log") {
+		t.Errorf("Unexpected access log: %s", p.AccessLog)
+	}
+
+	p = Resolve("cli.example.com")
+	if p.Layout != CLI || !p.Exists() || p.IsEnabled() || !strings.HasSuffix(p.Config, ".conf.disabled") {
+		t.Errorf("Unexpected cli paths: %+v", p)
+	}
+	if p.ActiveConfig() != cli.Config || p.AccessLog != custom || p.ErrorLog != cli.ErrorLog {
+		t.Errorf("Expected log paths from config, got %+v", p)
+	}
+
+	// 日志以 root 读取，LogsDir 之外的路径（包括经符号链接指向外部的路径）使用默认位置
+	os.Symlink("/etc", filepath.Join(LogsDir, "etc"))
+	for _, path := range []string{"/etc/shadow", filepath.Join(LogsDir, "..", "shadow"), filepath.Join(LogsDir, "etc", "shadow"), "logs/access.log"} {
+		writeFile(t, cli.Config+".disabled", "server {\n    access_log "+path+";\n    error_log "+path+";\n}\n")
+		if p := Resolve("cli.example.com"); p.AccessLog != cli.AccessLog || p.ErrorLog != cli.ErrorLog {
+			t.Errorf("%s: expected default log paths, got %+v", path, p)
+		}
+	}
+
+	p = Resolve("new.example.com")
+	if p.Layout != Canonical || p.Exists() || !p.IsCanonical() {
+		t.Errorf("Expected canonical layout for new site, got %+v", p)
+	}
+
+	writeFile(t, filepath.Join(ConfigDir, "default"), "")
+	writeFile(t, filepath.Join(ConfigDir, "x.example.com.conf.bak"), "")
+	domains, err := Domains()
+	if err != nil || strings.Join(domains, ",") != "cli.example.com,panel.example.com" {
+		t.Errorf("Domains returned %v, %v", domains, err)
+	}
+}
+
+func TestSiteLogs(t *testing.T) {
+	setupDirs(t)
+
+	writeFile(t, filepath.Join(LogsDir, "nginx", "a.com.access.log"), "1234")
+	writeFile(t, filepath.Join(LogsDir, "nginx", "a.com.error.log"), "")
+	writeFile(t, filepath.Join(LogsDir, "b.com_access.log"), "")
+	writeFile(t, filepath.Join(LogsDir, "b.com_node_error.log"), "")
+	writeFile(t, filepath.Join(LogsDir, "panel.log"), "")
+
+	logs := SiteLogs()
+	got := []string{}
+	for _, l := range logs {
+		got = append(got, l.Domain+":"+l.Kind)
+	}
+	if strings.Join(got, ",") != "a.com:access,a.com:error,b.com:access" {
+		t.Errorf("Unexpected site logs: %v", got)
+	}
+	if logs[0].Size != 4 {
+		t.Errorf("Expected size 4, got %d", logs[0].Size)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	setupDirs(t)
+
+	old := panelSite(t, "example.com")
+	writeFile(t, old.AccessLog, "GET /\n")
+
+	p, err := Migrate("example.com")
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	want := For(Canonical, "example.com")
+	if p.Layout != CLI || p.Config != want.Config || !p.IsCanonical() || !p.IsEnabled() {
+		t.Errorf("Unexpected paths after migration: %+v", p)
+	}
+
+	if _, err := os.Stat(old.Config); !os.IsNotExist(err) {
+		t.Error("Expected old config to be removed")
+	}
+	if _, err := os.Lstat(old.Enabled); !os.IsNotExist(err) {
+		t.Error("Expected old symlink to be removed")
+	}
+	if target, _ := os.Readlink(want.Enabled); target != want.Config {
+		t.Errorf("Expected new symlink to point to %s, got %s", want.Config, target)
+	}
+
+	content, _ := os.ReadFile(want.Config)
+	if !strings.Contains(string(content), "access_log "+want.AccessLog+";") || !strings.Contains(string(content), "error_log "+want.ErrorLog+" warn;") {
+		t.Errorf("Log directives not rewritten:\n%s", content)
+	}
+	if data, _ := os.ReadFile(want.AccessLog); string(data) != "GET /\n" {
+		t.Error("Expected existing access log to be moved")
+	}
+
+	// 已是规范布局时不做任何修改
+	if _, err := Migrate("example.com"); err != nil {
+		t.Errorf("Second migration failed: %v", err)
+	}
+
+	if _, err := Migrate("missing.example.com"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMigrateRollback(t *testing.T) {
+	setupDirs(t)
+
+	old := panelSite(t, "example.com")
+	original, _ := os.ReadFile(old.Config)
+	writeFile(t, old.AccessLog, "GET /\n")
+	nginx.Test = func(string) error { return errors.New("nginx: [emerg] unexpected end of file") }
+
+	_, err := Migrate("example.com")
+	if err == nil || !strings.Contains(err.Error(), "unexpected end of file") {
+		t.Fatalf("Expected nginx error, got %v", err)
+	}
+
+	if content, _ := os.ReadFile(old.Config); string(content) != string(original) {
+		t.Error("Expected original config to be restored")
+	}
+	if target, _ := os.Readlink(old.Enabled); target != old.Config {
+		t.Error("Expected original symlink to be restored")
+	}
+	want := For(Canonical, "example.com")
+	for _, path := range []string{want.Config, want.Enabled, want.AccessLog} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be cleaned up", path)
+		}
+	}
+	if p := Resolve("example.com"); p.Layout != Panel {
+		t.Errorf("Expected site to stay on panel layout, got %s", p.Layout)
+	}
+}
@@ -0,0 +1,94 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"site_manager_panel/internal/nginx"
+)
+
+// ErrNotFound 站点配置不存在
+var ErrNotFound = errors.New("site config not found")
+
+var logDirectiveRe = regexp.MustCompile(`(?m)^(\s*(?:access_log|error_log)\s+)([^\s;]+)`)
+
+// IsCanonical 站点是否已使用规范布局
+func (p *Paths) IsCanonical() bool {
+	to := For(Canonical, p.Domain)
+	return p.ActiveConfig() == to.Config && p.AccessLog == to.AccessLog && p.ErrorLog == to.ErrorLog
+}
+
+// Migrate 将站点迁移到规范布局：重命名配置与 sites-enabled 链接、改写日志路径并移动已有日志。
+// nginx -t 失败时恢复原状。
+func Migrate(domain string) (*Paths, error) {
+	from := Resolve(domain)
+	if !from.Exists() {
+		return nil, ErrNotFound
+	}
+	if from.IsCanonical() {
+		return from, nil
+	}
+
+	to := For(Canonical, domain)
+	if strings.HasSuffix(from.Config, disabledSuffix) {
+		to.Config += disabledSuffix
+	}
+
+	original, err := os.ReadFile(from.Config)
+	if err != nil {
+		return nil, err
+	}
+	rewritten := logDirectiveRe.ReplaceAllStringFunc(string(original), func(m string) string {
+		sub := logDirectiveRe.FindStringSubmatch(m)
+		switch sub[2] {
+		case from.AccessLog:
+			return sub[1] + to.AccessLog
+		case from.ErrorLog:
+			return sub[1] + to.ErrorLog
+		}
+		return m
+	})
+
+	tx := nginx.Begin()
+	tx.WriteFile(to.Config, []byte(rewritten))
+	if to.Config != from.Config {
+		tx.Remove(from.Config)
+	}
+	linked := false
+	for _, name := range []string{domain, domain + ".conf"} {
+		link := filepath.Join(EnabledDir, name)
+		if _, err := os.Lstat(link); err == nil {
+			tx.Remove(link)
+			linked = true
+		}
+	}
+	if linked {
+		tx.Symlink(to.ActiveConfig(), to.Enabled)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to.AccessLog), 0755); err != nil {
+		return nil, err
+	}
+
+	// 已有日志先随之移动（目标已存在时保留原文件），重载后 nginx 直接写入新路径
+	moved := map[string]string{}
+	for old, dst := range map[string]string{from.AccessLog: to.AccessLog, from.ErrorLog: to.ErrorLog} {
+		if old != dst && exists(old) && !exists(dst) {
+			if err := os.Rename(old, dst); err == nil {
+				moved[dst] = old
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		for dst, old := range moved {
+			os.Rename(dst, old)
+		}
+		return nil, fmt.Errorf("nginx 配置测试失败: %w", err)
+	}
+	return Resolve(domain), nil
+}
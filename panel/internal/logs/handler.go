@@ -8,6 +8,9 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/php"
 )
 
 // 预定义日志文件列表
@@ -18,8 +21,6 @@ var logFiles = []struct {
 }{
 	{"Nginx Access", "/var/log/nginx/access.log", "web"},
 	{"Nginx Error", "/var/log/nginx/error.log", "web"},
-	{"PHP-FPM 8.3", "/var/log/php8.3-fpm.log", "php"},
-	{"PHP-FPM 8.1", "/var/log/php8.1-fpm.log", "php"},
 	{"MySQL Error", "/var/log/mysql/error.log", "database"},
 	{"MySQL Slow Query", "/var/log/mysql/slow.log", "database"},
 	{"System Messages", "/var/log/syslog", "system"},
@@ -56,36 +57,33 @@ func List(c *fiber.Ctx) error {
 		results = append(results, item)
 	}
 
-	// 扫描站点日志目录
-	siteLogsDir := "/www/wwwlogs/nginx"
-	if entries, err := os.ReadDir(siteLogsDir); err == nil {
-		for _, entry := range entries {
-			if entry.IsDir() {
-				continue
-			}
-			name := entry.Name()
-			fullPath := filepath.Join(siteLogsDir, name)
-
-			item := LogFile{
-				Path:     fullPath,
-				Category: "site",
-				Exists:   true,
-			}
-
-			if strings.HasSuffix(name, ".access.log") {
-				item.Name = strings.TrimSuffix(name, ".access.log") + " Access"
-			} else if strings.HasSuffix(name, ".error.log") {
-				item.Name = strings.TrimSuffix(name, ".error.log") + " Error"
-			} else {
-				item.Name = name
-			}
-
-			if info, err := entry.Info(); err == nil {
-				item.Size = info.Size()
-			}
-
-			results = append(results, item)
+	// 各版本 PHP-FPM 日志
+	for _, v := range php.Versions() {
+		item := LogFile{
+			Name:     "PHP-FPM " + v.Version,
+			Path:     v.LogFile,
+			Category: "php",
+		}
+		if info, err := os.Stat(v.LogFile); err == nil {
+			item.Exists = true
+			item.Size = info.Size()
+		}
+		results = append(results, item)
+	}
+
+	// 站点日志（同时识别面板和 CLI 两种布局）
+	for _, sl := range layout.SiteLogs() {
+		name := sl.Domain + " Access"
+		if sl.Kind == "error" {
+			name = sl.Domain + " Error"
 		}
+		results = append(results, LogFile{
+			Name:     name,
+			Path:     sl.Path,
+			Category: "site",
+			Exists:   true,
+			Size:     sl.Size,
+		})
 	}
 
 	return c.JSON(fiber.Map{
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AuditLog 审计日志
+type AuditLog struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"` // 路由模式，如 /api/sites/:domain
+	Path      string    `json:"path"`
+	Target    string    `json:"target"`
+	Summary   string    `json:"summary"` // 请求摘要，敏感字段已脱敏
+	Status    int       `json:"status"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditFilter 审计日志查询条件，零值字段不参与过滤
+type AuditFilter struct {
+	Username string
+	Method   string
+	Route    string // 路由或路径包含
+	Target   string // 目标包含
+	Status   int
+	Failed   bool // 仅查看状态码 >= 400 的记录
+	Keyword  string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+// InsertAuditLog 写入审计日志
+func InsertAuditLog(l *AuditLog) error {
+	if l.CreatedAt.IsZero() {
+		l.CreatedAt = time.Now()
+	}
+	result, err := DB.Exec(
+		`INSERT INTO audit_logs (user_id, username, method, route, path, target, summary, status, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		l.UserID, l.Username, l.Method, l.Route, l.Path, l.Target, l.Summary, l.Status, l.IP, l.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	l.ID, err = result.LastInsertId()
+	return err
+}
+
+func (f AuditFilter) where() (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+
+	if f.Username != "" {
+		conds = append(conds, "username = ?")
+		args = append(args, f.Username)
+	}
+	if f.Method != "" {
+		conds = append(conds, "method = ?")
+		args = append(args, strings.ToUpper(f.Method))
+	}
+	if f.Route != "" {
+		conds = append(conds, "(route LIKE ? OR path LIKE ?)")
+		args = append(args, "%"+f.Route+"%", "%"+f.Route+"%")
+	}
+	if f.Target != "" {
+		conds = append(conds, "target LIKE ?")
+		args = append(args, "%"+f.Target+"%")
+	}
+	if f.Status != 0 {
+		conds = append(conds, "status = ?")
+		args = append(args, f.Status)
+	}
+	if f.Failed {
+		conds = append(conds, "status >= 400")
+	}
+	if f.Keyword != "" {
+		conds = append(conds, "(summary LIKE ? OR path LIKE ? OR target LIKE ?)")
+		args = append(args, "%"+f.Keyword+"%", "%"+f.Keyword+"%", "%"+f.Keyword+"%")
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_at <= ?")
+		args = append(args, f.To.UTC())
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// CountAuditLogs 统计符合条件的记录数
+func CountAuditLogs(f AuditFilter) (int, error) {
+	where, args := f.where()
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM audit_logs"+where, args...).Scan(&count)
+	return count, err
+}
+
+// QueryAuditLogs 按条件查询，最新的在前；Limit 为 0 时不限制条数
+func QueryAuditLogs(f AuditFilter, fn func(*AuditLog) error) error {
+	where, args := f.where()
+	query := "SELECT id, user_id, username, method, route, path, target, summary, status, ip, created_at FROM audit_logs" +
+		where + " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := &AuditLog{}
+		if err := rows.Scan(&l.ID, &l.UserID, &l.Username, &l.Method, &l.Route, &l.Path,
+			&l.Target, &l.Summary, &l.Status, &l.IP, &l.CreatedAt); err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
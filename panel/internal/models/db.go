@@ -55,6 +55,12 @@ func createTables() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		username TEXT UNIQUE NOT NULL,
 		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'readonly',
+		permissions TEXT NOT NULL DEFAULT '',
+		totp_secret TEXT NOT NULL DEFAULT '',
+		totp_enabled INTEGER NOT NULL DEFAULT 0,
+		totp_last_step INTEGER NOT NULL DEFAULT 0,
+		recovery_codes TEXT NOT NULL DEFAULT '',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -63,14 +69,146 @@ func createTables() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
 		token TEXT UNIQUE NOT NULL,
+		ip TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
 		expires_at DATETIME NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (user_id) REFERENCES users(id)
 	);
+
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		kind TEXT NOT NULL,
+		key TEXT NOT NULL,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at DATETIME NOT NULL,
+		locked_until DATETIME NOT NULL,
+		banned INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (kind, key)
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL DEFAULT 0,
+		username TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL,
+		route TEXT NOT NULL,
+		path TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		summary TEXT NOT NULL DEFAULT '',
+		status INTEGER NOT NULL DEFAULT 0,
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+
+	CREATE TABLE IF NOT EXISTS sites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		domain TEXT UNIQUE NOT NULL,
+		type TEXT NOT NULL,
+		php_version TEXT NOT NULL DEFAULT '',
+		port INTEGER NOT NULL DEFAULT 0,
+		target TEXT NOT NULL DEFAULT '',
+		root TEXT NOT NULL DEFAULT '',
+		ssl_cert TEXT NOT NULL DEFAULT '',
+		ssl_key TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '',
+		template TEXT NOT NULL DEFAULT '',
+		params TEXT NOT NULL DEFAULT '',
+		aliases TEXT NOT NULL DEFAULT '',
+		redirect_domains TEXT NOT NULL DEFAULT '',
+		canonical_host TEXT NOT NULL DEFAULT '',
+		upstream TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS config_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		domain TEXT NOT NULL,
+		content TEXT NOT NULL,
+		author TEXT NOT NULL DEFAULT '',
+		comment TEXT NOT NULL DEFAULT '',
+		rendered INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_config_revisions_domain ON config_revisions(domain, id);
 	`
 
-	_, err := DB.Exec(schema)
-	return err
+	if _, err := DB.Exec(schema); err != nil {
+		return err
+	}
+	return migrateTables()
+}
+
+// addedColumns 建表之后新增的列，旧数据库启动时自动补齐
+var addedColumns = []struct {
+	table      string
+	name       string
+	definition string
+}{
+	{"users", "role", "TEXT NOT NULL DEFAULT 'readonly'"},
+	{"users", "permissions", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "totp_secret", "TEXT NOT NULL DEFAULT ''"},
+	{"users", "totp_enabled", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "totp_last_step", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "recovery_codes", "TEXT NOT NULL DEFAULT ''"},
+	{"sessions", "ip", "TEXT NOT NULL DEFAULT ''"},
+	{"sessions", "user_agent", "TEXT NOT NULL DEFAULT ''"},
+	{"sites", "template", "TEXT NOT NULL DEFAULT ''"},
+	{"sites", "params", "TEXT NOT NULL DEFAULT ''"},
+	{"sites", "aliases", "TEXT NOT NULL DEFAULT ''"},
+	{"sites", "redirect_domains", "TEXT NOT NULL DEFAULT ''"},
+	{"sites", "canonical_host", "TEXT NOT NULL DEFAULT ''"},
+	{"sites", "upstream", "TEXT NOT NULL DEFAULT ''"},
+	{"config_revisions", "rendered", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// migrateTables 为旧版本数据库补充新增的列
+func migrateTables() error {
+	existing := map[string]map[string]bool{}
+	for _, col := range addedColumns {
+		if existing[col.table] == nil {
+			columns, err := tableColumns(col.table)
+			if err != nil {
+				return err
+			}
+			existing[col.table] = columns
+		}
+		if existing[col.table][col.name] {
+			continue
+		}
+
+		if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.name, col.definition)); err != nil {
+			return err
+		}
+		// 旧版本只有一个拥有全部权限的账户
+		if col.table == "users" && col.name == "role" {
+			if _, err := DB.Exec("UPDATE users SET role = ?", RoleAdmin); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func tableColumns(table string) (map[string]bool, error) {
+	rows, err := DB.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
 }
 
 func createAdminUser(password string) error {
@@ -80,8 +218,8 @@ func createAdminUser(password string) error {
 	}
 
 	_, err = DB.Exec(
-		"INSERT INTO users (username, password_hash) VALUES (?, ?)",
-		"admin", string(hash),
+		"INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)",
+		"admin", string(hash), RoleAdmin,
 	)
 	return err
 }
@@ -97,17 +235,34 @@ type User struct {
 	ID           int64     `json:"id"`
 	Username     string    `json:"username"`
 	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	Permissions  []string  `json:"permissions"` // 角色之外额外授予的权限
+	TOTPSecret   string    `json:"-"`
+	TOTPEnabled  bool      `json:"totp_enabled"`
+	TOTPLastStep int64     `json:"-"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
-func GetUserByUsername(username string) (*User, error) {
+const userColumns = "id, username, password_hash, role, permissions, totp_secret, totp_enabled, totp_last_step, created_at, updated_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*User, error) {
 	user := &User{}
-	err := DB.QueryRow(
-		"SELECT id, username, password_hash, created_at, updated_at FROM users WHERE username = ?",
-		username,
-	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
-	
+	var permissions string
+	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &permissions,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	user.Permissions = splitList(permissions)
+	return user, nil
+}
+
+func GetUserByUsername(username string) (*User, error) {
+	user, err := scanUser(DB.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -118,12 +273,7 @@ func GetUserByUsername(username string) (*User, error) {
 }
 
 func GetUserByID(id int64) (*User, error) {
-	user := &User{}
-	err := DB.QueryRow(
-		"SELECT id, username, password_hash, created_at, updated_at FROM users WHERE id = ?",
-		id,
-	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
-	
+	user, err := scanUser(DB.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -133,6 +283,76 @@ func GetUserByID(id int64) (*User, error) {
 	return user, nil
 }
 
+// ListUsers 列出所有用户
+func ListUsers() ([]*User, error) {
+	rows, err := DB.Query("SELECT " + userColumns + " FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// CreateUser 创建用户
+func CreateUser(username, password, role string, permissions []string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO users (username, password_hash, role, permissions) VALUES (?, ?, ?, ?)",
+		username, string(hash), role, joinList(permissions),
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetUserByID(id)
+}
+
+// DeleteUser 删除用户及其会话
+func DeleteUser(id int64) error {
+	if _, err := DB.Exec("DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	_, err := DB.Exec("DELETE FROM users WHERE id = ?", id)
+	return err
+}
+
+// CountAdmins 统计管理员数量
+func CountAdmins() (int, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", RoleAdmin).Scan(&count)
+	return count, err
+}
+
+// UpdateRole 更新角色和额外权限
+func (u *User) UpdateRole(role string, permissions []string) error {
+	_, err := DB.Exec(
+		"UPDATE users SET role = ?, permissions = ?, updated_at = ? WHERE id = ?",
+		role, joinList(permissions), time.Now(), u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	u.Role = role
+	u.Permissions = permissions
+	return nil
+}
+
 func (u *User) UpdatePassword(newPassword string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 登录失败记录的维度
+const (
+	AttemptIP       = "ip"
+	AttemptUsername = "username"
+)
+
+// LoginAttempt 按 IP 或用户名统计的连续登录失败
+type LoginAttempt struct {
+	Kind          string    `json:"kind"`
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+	Banned        bool      `json:"banned"` // 已通过防火墙封禁
+}
+
+const attemptColumns = "kind, key, failures, last_failure_at, locked_until, banned"
+
+func scanAttempt(row rowScanner) (*LoginAttempt, error) {
+	a := &LoginAttempt{}
+	if err := row.Scan(&a.Kind, &a.Key, &a.Failures, &a.LastFailureAt, &a.LockedUntil, &a.Banned); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetLoginAttempt 获取失败记录，不存在时返回 nil
+func GetLoginAttempt(kind, key string) (*LoginAttempt, error) {
+	a, err := scanAttempt(DB.QueryRow("SELECT "+attemptColumns+" FROM login_attempts WHERE kind = ? AND key = ?", kind, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// AddLoginFailure 原子地累加一次失败并返回更新后的记录，并发的失败不会相互覆盖。
+// 上次失败早于 resetBefore 且未处于锁定中时重新计数
+func AddLoginFailure(kind, key string, now, resetBefore time.Time) (*LoginAttempt, error) {
+	return scanAttempt(DB.QueryRow(`
+		INSERT INTO login_attempts (kind, key, failures, last_failure_at, locked_until, banned)
+		VALUES (?, ?, 1, ?, ?, 0)
+		ON CONFLICT(kind, key) DO UPDATE SET
+			failures = CASE WHEN last_failure_at < ? AND locked_until <= excluded.last_failure_at THEN 1 ELSE failures + 1 END,
+			banned = CASE WHEN last_failure_at < ? AND locked_until <= excluded.last_failure_at THEN 0 ELSE banned END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING `+attemptColumns,
+		kind, key, now.UTC(), now.UTC(), resetBefore.UTC(), resetBefore.UTC(),
+	))
+}
+
+// ExtendLoginLock 把锁定时间延长到 until，已有更晚的锁定时保持不变
+func ExtendLoginLock(kind, key string, until time.Time) error {
+	_, err := DB.Exec(
+		"UPDATE login_attempts SET locked_until = ? WHERE kind = ? AND key = ? AND locked_until < ?",
+		until.UTC(), kind, key, until.UTC(),
+	)
+	return err
+}
+
+// SetLoginAttemptBanned 设置防火墙封禁标记，返回是否由本次调用改变，用于避免重复封禁
+func SetLoginAttemptBanned(kind, key string, banned bool) (bool, error) {
+	result, err := DB.Exec(
+		"UPDATE login_attempts SET banned = ? WHERE kind = ? AND key = ? AND banned != ?",
+		banned, kind, key, banned,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// ListLoginAttempts 列出所有失败记录，最近的在前
+func ListLoginAttempts() ([]*LoginAttempt, error) {
+	rows, err := DB.Query("SELECT " + attemptColumns + " FROM login_attempts ORDER BY last_failure_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []*LoginAttempt{}
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// DeleteLoginAttempt 清除失败记录（登录成功或管理员解锁）
+func DeleteLoginAttempt(kind, key string) error {
+	_, err := DB.Exec("DELETE FROM login_attempts WHERE kind = ? AND key = ?", kind, key)
+	return err
+}
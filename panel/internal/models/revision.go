@@ -0,0 +1,95 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MaxConfigRevisions 每个站点保留的配置版本数
+const MaxConfigRevisions = 100
+
+// ConfigRevision 站点 nginx 配置的一个版本
+type ConfigRevision struct {
+	ID        int64     `json:"id"`
+	Domain    string    `json:"domain"`
+	Content   string    `json:"content"`
+	Author    string    `json:"author"` // 面板用户名，面板之外的修改为空
+	Comment   string    `json:"comment"`
+	Rendered  bool      `json:"rendered"` // 内容可由站点模板重新生成，没有手工或结构化修改
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const revisionColumns = "id, domain, content, author, comment, rendered, created_at"
+
+func scanRevision(row rowScanner) (*ConfigRevision, error) {
+	r := &ConfigRevision{}
+	err := row.Scan(&r.ID, &r.Domain, &r.Content, &r.Author, &r.Comment, &r.Rendered, &r.CreatedAt)
+	return r, err
+}
+
+// CreateConfigRevision 保存新版本，超出 MaxConfigRevisions 的旧版本被删除
+func CreateConfigRevision(r *ConfigRevision) error {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	result, err := DB.Exec(
+		"INSERT INTO config_revisions (domain, content, author, comment, rendered, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		r.Domain, r.Content, r.Author, r.Comment, r.Rendered, r.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	if r.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(
+		`DELETE FROM config_revisions WHERE domain = ? AND id NOT IN
+		(SELECT id FROM config_revisions WHERE domain = ? ORDER BY id DESC LIMIT ?)`,
+		r.Domain, r.Domain, MaxConfigRevisions,
+	)
+	return err
+}
+
+// ListConfigRevisions 列出站点的配置版本，最新的在前
+func ListConfigRevisions(domain string) ([]*ConfigRevision, error) {
+	rows, err := DB.Query("SELECT "+revisionColumns+" FROM config_revisions WHERE domain = ? ORDER BY id DESC", domain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*ConfigRevision{}
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// GetConfigRevision 获取站点的指定版本，不存在时返回 nil
+func GetConfigRevision(domain string, id int64) (*ConfigRevision, error) {
+	r, err := scanRevision(DB.QueryRow("SELECT "+revisionColumns+" FROM config_revisions WHERE domain = ? AND id = ?", domain, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// LatestConfigRevision 获取站点的最新版本，没有版本时返回 nil
+func LatestConfigRevision(domain string) (*ConfigRevision, error) {
+	r, err := scanRevision(DB.QueryRow("SELECT "+revisionColumns+" FROM config_revisions WHERE domain = ? ORDER BY id DESC LIMIT 1", domain))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// DeleteConfigRevisions 删除站点的全部版本
+func DeleteConfigRevisions(domain string) error {
+	_, err := DB.Exec("DELETE FROM config_revisions WHERE domain = ?", domain)
+	return err
+}
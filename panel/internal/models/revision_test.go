@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConfigRevisions(t *testing.T) {
+	if err := InitDB(t.TempDir()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer DB.Close()
+
+	if r, err := LatestConfigRevision("example.com"); err != nil || r != nil {
+		t.Fatalf("Expected no revision, got %+v %v", r, err)
+	}
+
+	for i := 0; i < MaxConfigRevisions+5; i++ {
+		r := &ConfigRevision{Domain: "example.com", Content: fmt.Sprintf("# %d\n", i), Author: "admin", Comment: "edit"}
+		if err := CreateConfigRevision(r); err != nil {
+			t.Fatalf("CreateConfigRevision failed: %v", err)
+		}
+	}
+	CreateConfigRevision(&ConfigRevision{Domain: "other.com", Content: "x"})
+
+	revisions, err := ListConfigRevisions("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != MaxConfigRevisions {
+		t.Fatalf("Expected old revisions to be pruned, got %d", len(revisions))
+	}
+	latest, _ := LatestConfigRevision("example.com")
+	if revisions[0].ID != latest.ID || latest.Content != fmt.Sprintf("# %d\n", MaxConfigRevisions+4) {
+		t.Errorf("Unexpected latest revision %+v", latest)
+	}
+
+	got, err := GetConfigRevision("example.com", latest.ID)
+	if err != nil || got == nil || got.Author != "admin" || got.CreatedAt.IsZero() {
+		t.Errorf("GetConfigRevision failed: %+v %v", got, err)
+	}
+	if got, _ := GetConfigRevision("other.com", latest.ID); got != nil {
+		t.Error("Revision must belong to the requested domain")
+	}
+
+	if err := DeleteConfigRevisions("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, _ := ListConfigRevisions("example.com"); len(revisions) != 0 {
+		t.Error("Expected revisions to be deleted")
+	}
+	if revisions, _ := ListConfigRevisions("other.com"); len(revisions) != 1 {
+		t.Error("Other sites must keep their revisions")
+	}
+}
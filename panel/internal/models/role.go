@@ -0,0 +1,147 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// 内置角色
+const (
+	RoleAdmin    = "admin"    // 全部权限，包括用户管理
+	RoleOperator = "operator" // 日常运维，不含终端、文件管理、用户管理、计划任务和防火墙修改
+	RoleReadOnly = "readonly" // 只读
+)
+
+// 权限动作
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+	ActionUse   = "use"
+)
+
+// Modules 可授权的模块
+var Modules = []string{
+	"system", "sites", "software", "logs", "files", "terminal",
+	"firewall", "cron", "databases", "backups", "users", "audit", "ssl", "docker",
+}
+
+// AllPermissions 所有合法权限，格式为 模块:动作
+func AllPermissions() []string {
+	perms := []string{}
+	for _, m := range Modules {
+		if m == "terminal" {
+			perms = append(perms, m+":"+ActionUse)
+			continue
+		}
+		perms = append(perms, m+":"+ActionRead, m+":"+ActionWrite)
+	}
+	return perms
+}
+
+// rolePermissions 角色默认权限（admin 不在此列，直接拥有全部权限）
+var rolePermissions = map[string][]string{
+	RoleOperator: {
+		"system:read", "system:write",
+		"sites:read", "sites:write",
+		"software:read", "software:write",
+		"logs:read", "logs:write",
+		"firewall:read",
+		"cron:read",
+		"databases:read", "databases:write",
+		"backups:read", "backups:write",
+		"ssl:read", "ssl:write",
+		"docker:read", "docker:write",
+	},
+	RoleReadOnly: {
+		"system:read", "sites:read", "software:read", "logs:read",
+		"firewall:read", "cron:read", "databases:read", "backups:read", "ssl:read",
+		"docker:read",
+	},
+}
+
+// privilegedPermissions 等同 root 的权限，不在内置角色中，只能由管理员单独授予：
+// 终端；计划任务以 root 执行；文件管理不限目录，可读 /etc/shadow 和面板数据库、可写 /etc/cron.d；用户管理可给自己之外的人授权
+var privilegedPermissions = map[string]bool{
+	"terminal:use": true,
+	"cron:write":   true,
+	"files:read":   true,
+	"files:write":  true,
+	"users:read":   true,
+	"users:write":  true,
+}
+
+// IsPrivilegedPermission 权限是否等同 root，见 privilegedPermissions
+func IsPrivilegedPermission(perm string) bool {
+	return privilegedPermissions[perm]
+}
+
+// IsValidRole 检查角色是否存在
+func IsValidRole(role string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// IsValidPermission 检查权限字符串是否合法
+func IsValidPermission(perm string) bool {
+	for _, p := range AllPermissions() {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectivePermissions 角色权限与额外权限的并集
+func (u *User) EffectivePermissions() []string {
+	if u.Role == RoleAdmin {
+		return AllPermissions()
+	}
+
+	set := map[string]bool{}
+	for _, p := range rolePermissions[u.Role] {
+		set[p] = true
+	}
+	for _, p := range u.Permissions {
+		set[p] = true
+	}
+
+	perms := make([]string, 0, len(set))
+	for p := range set {
+		perms = append(perms, p)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// HasPermission 检查是否拥有权限；拥有 模块:write 即同时拥有 模块:read
+func (u *User) HasPermission(perm string) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+
+	module, action, _ := strings.Cut(perm, ":")
+	for _, p := range u.EffectivePermissions() {
+		if p == perm || (action == ActionRead && p == module+":"+ActionWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList 解析以逗号分隔存储的列表列
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func joinList(items []string) string {
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateAddsRoleToExistingUsers(t *testing.T) {
+	dir := t.TempDir()
+
+	// 旧版本的 users 表没有 role / permissions 列
+	old, err := sql.Open("sqlite3", filepath.Join(dir, "panel.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT UNIQUE NOT NULL,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO users (username, password_hash) VALUES ('admin', 'x');
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitDB(dir); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer DB.Close()
+
+	user, err := GetUserByUsername("admin")
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByUsername failed: %v", err)
+	}
+	if user.Role != RoleAdmin || len(user.Permissions) != 0 {
+		t.Errorf("Expected migrated admin role, got %+v", user)
+	}
+
+	created, err := CreateUser("viewer", "secret123", RoleReadOnly, []string{"files:write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Role != RoleReadOnly || len(created.Permissions) != 1 || created.Permissions[0] != "files:write" {
+		t.Errorf("Unexpected created user: %+v", created)
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		user *User
+		perm string
+		want bool
+	}{
+		{&User{Role: RoleAdmin}, "users:write", true},
+		{&User{Role: RoleOperator}, "sites:write", true},
+		{&User{Role: RoleOperator}, "terminal:use", false},
+		{&User{Role: RoleOperator}, "firewall:write", false},
+		{&User{Role: RoleReadOnly}, "sites:read", true},
+		{&User{Role: RoleReadOnly}, "sites:write", false},
+		{&User{Role: RoleReadOnly, Permissions: []string{"files:write"}}, "files:write", true},
+		{&User{Role: RoleReadOnly, Permissions: []string{"users:write"}}, "users:read", true},
+		{&User{Role: "unknown"}, "sites:read", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.user.HasPermission(tt.perm); got != tt.want {
+			t.Errorf("%s %v HasPermission(%s) = %v, want %v", tt.user.Role, tt.user.Permissions, tt.perm, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+)
+
+// Session 已签发的登录令牌，token 列保存 JWT 的 jti
+type Session struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	JTI       string    `json:"-"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	Current   bool      `json:"current"`
+}
+
+// CreateSession 记录新签发的令牌，同时清理已过期的会话
+func CreateSession(userID int64, jti, ip, userAgent string, expiresAt time.Time) error {
+	if _, err := DB.Exec("DELETE FROM sessions WHERE expires_at < ?", time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(
+		"INSERT INTO sessions (user_id, token, ip, user_agent, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, jti, ip, userAgent, expiresAt.UTC(), time.Now().UTC(),
+	)
+	return err
+}
+
+// IsSessionActive 检查令牌是否仍然有效（未吊销且未过期）
+func IsSessionActive(userID int64, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+
+	var count int
+	err := DB.QueryRow(
+		"SELECT COUNT(*) FROM sessions WHERE token = ? AND user_id = ? AND expires_at > ?",
+		jti, userID, time.Now().UTC(),
+	).Scan(&count)
+	return count > 0, err
+}
+
+// ListSessions 列出用户的有效会话，currentJTI 对应的会话标记为当前会话
+func ListSessions(userID int64, currentJTI string) ([]*Session, error) {
+	rows, err := DB.Query(
+		"SELECT id, user_id, token, ip, user_agent, expires_at, created_at FROM sessions WHERE user_id = ? AND expires_at > ? ORDER BY created_at DESC",
+		userID, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		s := &Session{}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.JTI, &s.IP, &s.UserAgent, &s.ExpiresAt, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.Current = s.JTI == currentJTI
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession 吊销用户的指定会话，返回是否存在
+func RevokeSession(userID, id int64) (bool, error) {
+	result, err := DB.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RevokeSessionByJTI 按 jti 吊销会话（用于退出登录）
+func RevokeSessionByJTI(jti string) error {
+	_, err := DB.Exec("DELETE FROM sessions WHERE token = ?", jti)
+	return err
+}
+
+// RevokeUserSessions 吊销用户除 exceptJTI 之外的所有会话，返回吊销数量
+func RevokeUserSessions(userID int64, exceptJTI string) (int64, error) {
+	result, err := DB.Exec("DELETE FROM sessions WHERE user_id = ? AND token != ?", userID, exceptJTI)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
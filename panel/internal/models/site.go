@@ -0,0 +1,239 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Site 站点元数据，站点的类型、运行参数等以此为准，不再从 nginx 配置反推
+type Site struct {
+	ID         int64     `json:"id"`
+	Domain     string    `json:"domain"`
+	Type       string    `json:"type"`
+	PHPVersion string    `json:"php_version"`
+	Port       int       `json:"port"`   // 上游端口（node / pm2 / python / docker）
+	Target     string    `json:"target"` // 反向代理目标
+	Root       string    `json:"root"`
+	SSLCert    string    `json:"ssl_cert"`
+	SSLKey     string    `json:"ssl_key"`
+	Tags       []string  `json:"tags"`
+	Template   string    `json:"template"`  // 生成配置所用的模板，为空表示非模板生成
+	Params     Params    `json:"params"`    // 模板参数
+	Aliases    []string  `json:"aliases"`   // 与主域名提供相同内容的域名
+	Redirects  Redirects `json:"redirects"` // 跳转到主域名的域名
+	Canonical  string    `json:"canonical"` // 规范域名，其余域名跳转到该域名；为空时不跳转
+	Upstream   Upstream  `json:"upstream"`  // 反向代理的负载均衡后端
+	Enabled    bool      `json:"enabled"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Params 模板参数，以 JSON 存储
+type Params map[string]interface{}
+
+func (p Params) encode() string {
+	if len(p) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+func decodeParams(s string) Params {
+	p := Params{}
+	if s != "" {
+		json.Unmarshal([]byte(s), &p)
+	}
+	return p
+}
+
+// RedirectDomain 整站跳转到主域名的域名
+type RedirectDomain struct {
+	Domain string `json:"domain"`
+	Code   int    `json:"code"` // 301 或 302
+}
+
+// Redirects 跳转域名，以 JSON 存储
+type Redirects []RedirectDomain
+
+func (r Redirects) encode() string {
+	if len(r) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+func decodeRedirects(s string) Redirects {
+	r := Redirects{}
+	if s != "" {
+		json.Unmarshal([]byte(s), &r)
+	}
+	return r
+}
+
+// UpstreamServer 负载均衡的后端
+type UpstreamServer struct {
+	Address     string `json:"address"`      // host:port 或 unix:/path
+	Weight      int    `json:"weight"`       // 0 表示默认权重 1
+	MaxFails    int    `json:"max_fails"`    // fail_timeout 内失败次数达到该值即暂停使用，0 表示 nginx 默认值 1
+	FailTimeout string `json:"fail_timeout"` // 统计失败和暂停使用的时长，为空时 nginx 默认 10s
+	Backup      bool   `json:"backup"`       // 备用后端，主后端都不可用时才使用
+	Draining    bool   `json:"draining"`     // 排空中：标记为 down，不再分配新请求
+}
+
+// Upstream 反向代理的后端组，以 JSON 存储；没有后端时直接代理到 Target
+type Upstream struct {
+	Method  string           `json:"method"` // 负载均衡方式：空为轮询，least_conn 或 ip_hash
+	Servers []UpstreamServer `json:"servers"`
+}
+
+func (u Upstream) encode() string {
+	if u.Method == "" && len(u.Servers) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(u)
+	return string(data)
+}
+
+func decodeUpstream(s string) Upstream {
+	u := Upstream{}
+	if s != "" {
+		json.Unmarshal([]byte(s), &u)
+	}
+	if u.Servers == nil {
+		u.Servers = []UpstreamServer{}
+	}
+	return u
+}
+
+// Server 按地址查找后端，不存在时返回 nil
+func (u *Upstream) Server(address string) *UpstreamServer {
+	for i := range u.Servers {
+		if u.Servers[i].Address == address {
+			return &u.Servers[i]
+		}
+	}
+	return nil
+}
+
+// Names 站点的全部域名：主域名、别名和跳转域名
+func (s *Site) Names() []string {
+	names := append([]string{s.Domain}, s.Aliases...)
+	for _, r := range s.Redirects {
+		names = append(names, r.Domain)
+	}
+	return names
+}
+
+const siteColumns = "id, domain, type, php_version, port, target, root, ssl_cert, ssl_key, tags, template, params, aliases, redirect_domains, canonical_host, upstream, enabled, created_by, created_at, updated_at"
+
+func scanSite(row rowScanner) (*Site, error) {
+	s := &Site{}
+	var tags, params, aliases, redirects, upstream string
+	if err := row.Scan(&s.ID, &s.Domain, &s.Type, &s.PHPVersion, &s.Port, &s.Target, &s.Root,
+		&s.SSLCert, &s.SSLKey, &tags, &s.Template, &params, &aliases, &redirects, &s.Canonical, &upstream,
+		&s.Enabled, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	s.Tags = splitList(tags)
+	s.Params = decodeParams(params)
+	s.Aliases = splitList(aliases)
+	s.Redirects = decodeRedirects(redirects)
+	s.Upstream = decodeUpstream(upstream)
+	return s, nil
+}
+
+// GetSite 按域名获取站点，不存在时返回 nil
+func GetSite(domain string) (*Site, error) {
+	s, err := scanSite(DB.QueryRow("SELECT "+siteColumns+" FROM sites WHERE domain = ?", domain))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListSites 列出所有站点，按域名排序
+func ListSites() ([]*Site, error) {
+	rows, err := DB.Query("SELECT " + siteColumns + " FROM sites ORDER BY domain")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sites := []*Site{}
+	for rows.Next() {
+		s, err := scanSite(rows)
+		if err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+// CreateSite 写入站点记录，CreatedAt 为空时取当前时间
+func CreateSite(s *Site) error {
+	now := time.Now()
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = now
+	}
+	s.UpdatedAt = now
+	if s.Tags == nil {
+		s.Tags = []string{}
+	}
+	if s.Params == nil {
+		s.Params = Params{}
+	}
+	if s.Aliases == nil {
+		s.Aliases = []string{}
+	}
+	if s.Redirects == nil {
+		s.Redirects = Redirects{}
+	}
+	if s.Upstream.Servers == nil {
+		s.Upstream.Servers = []UpstreamServer{}
+	}
+
+	result, err := DB.Exec(
+		`INSERT INTO sites (domain, type, php_version, port, target, root, ssl_cert, ssl_key, tags, template, params,
+		aliases, redirect_domains, canonical_host, upstream, enabled, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Domain, s.Type, s.PHPVersion, s.Port, s.Target, s.Root, s.SSLCert, s.SSLKey,
+		joinList(s.Tags), s.Template, s.Params.encode(), joinList(s.Aliases), s.Redirects.encode(), s.Canonical, s.Upstream.encode(),
+		s.Enabled, s.CreatedBy, s.CreatedAt.UTC(), s.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	s.ID, err = result.LastInsertId()
+	return err
+}
+
+// Update 保存站点的可变字段
+func (s *Site) Update() error {
+	s.UpdatedAt = time.Now()
+	_, err := DB.Exec(
+		`UPDATE sites SET type = ?, php_version = ?, port = ?, target = ?, root = ?, ssl_cert = ?, ssl_key = ?,
+		tags = ?, template = ?, params = ?, aliases = ?, redirect_domains = ?, canonical_host = ?, upstream = ?,
+		enabled = ?, updated_at = ? WHERE id = ?`,
+		s.Type, s.PHPVersion, s.Port, s.Target, s.Root, s.SSLCert, s.SSLKey,
+		joinList(s.Tags), s.Template, s.Params.encode(), joinList(s.Aliases), s.Redirects.encode(), s.Canonical, s.Upstream.encode(),
+		s.Enabled, s.UpdatedAt.UTC(), s.ID,
+	)
+	return err
+}
+
+// SetSiteEnabled 更新站点启用状态
+func SetSiteEnabled(domain string, enabled bool) error {
+	_, err := DB.Exec("UPDATE sites SET enabled = ?, updated_at = ? WHERE domain = ?", enabled, time.Now().UTC(), domain)
+	return err
+}
+
+// DeleteSite 删除站点记录
+func DeleteSite(domain string) error {
+	_, err := DB.Exec("DELETE FROM sites WHERE domain = ?", domain)
+	return err
+}
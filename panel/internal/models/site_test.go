@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSiteCRUD(t *testing.T) {
+	if err := InitDB(t.TempDir()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer DB.Close()
+
+	created := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	site := &Site{
+		Domain:     "example.com",
+		Type:       "php",
+		PHPVersion: "8.1",
+		Root:       "/www/wwwroot/example.com/public",
+		Tags:       []string{"prod", "blog"},
+		Enabled:    true,
+		CreatedBy:  "admin",
+		CreatedAt:  created,
+	}
+	if err := CreateSite(site); err != nil {
+		t.Fatalf("CreateSite failed: %v", err)
+	}
+	if err := CreateSite(&Site{Domain: "example.com", Type: "static"}); err == nil {
+		t.Error("Expected duplicate domain to fail")
+	}
+	if err := CreateSite(&Site{Domain: "api.example.com", Type: "node", Port: 3000}); err != nil {
+		t.Fatalf("CreateSite failed: %v", err)
+	}
+
+	got, err := GetSite("example.com")
+	if err != nil || got == nil {
+		t.Fatalf("GetSite failed: %v", err)
+	}
+	if got.PHPVersion != "8.1" || !got.Enabled || got.CreatedBy != "admin" || !got.CreatedAt.Equal(created) {
+		t.Errorf("Unexpected site: %+v", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[1] != "blog" {
+		t.Errorf("Expected tags to round-trip, got %v", got.Tags)
+	}
+
+	got.Tags = []string{}
+	got.SSLCert = "/www/ssl/example.com/fullchain.pem"
+	got.Aliases = []string{"www.example.com"}
+	got.Redirects = Redirects{{Domain: "example.net", Code: 301}}
+	got.Canonical = "www.example.com"
+	got.Upstream = Upstream{Method: "least_conn", Servers: []UpstreamServer{{Address: "10.0.0.2:8080", Weight: 3}, {Address: "10.0.0.3:8080", Backup: true}}}
+	if err := got.Update(); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := SetSiteEnabled("example.com", false); err != nil {
+		t.Fatalf("SetSiteEnabled failed: %v", err)
+	}
+	got, _ = GetSite("example.com")
+	if got.Enabled || len(got.Tags) != 0 || got.SSLCert == "" {
+		t.Errorf("Expected update to persist, got %+v", got)
+	}
+	if names := got.Names(); len(names) != 3 || names[1] != "www.example.com" || names[2] != "example.net" || got.Canonical != "www.example.com" {
+		t.Errorf("Expected aliases and redirects to round-trip, got %v %+v", names, got.Redirects)
+	}
+	if got.Upstream.Method != "least_conn" || len(got.Upstream.Servers) != 2 || got.Upstream.Server("10.0.0.3:8080") == nil || !got.Upstream.Server("10.0.0.3:8080").Backup {
+		t.Errorf("Expected upstream to round-trip, got %+v", got.Upstream)
+	}
+
+	sites, err := ListSites()
+	if err != nil || len(sites) != 2 || sites[0].Domain != "api.example.com" {
+		t.Fatalf("ListSites returned %v, %v", sites, err)
+	}
+	if sites[0].Port != 3000 || sites[0].Tags == nil || sites[0].Upstream.Servers == nil {
+		t.Errorf("Unexpected site: %+v", sites[0])
+	}
+
+	if err := DeleteSite("example.com"); err != nil {
+		t.Fatalf("DeleteSite failed: %v", err)
+	}
+	if got, err := GetSite("example.com"); err != nil || got != nil {
+		t.Errorf("Expected site to be deleted, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+)
+
+// SetTOTPSecret 保存待确认的 TOTP 密钥，确认前不启用
+func (u *User) SetTOTPSecret(secret string) error {
+	_, err := DB.Exec(
+		"UPDATE users SET totp_secret = ?, totp_enabled = 0, totp_last_step = 0, recovery_codes = '', updated_at = ? WHERE id = ?",
+		secret, time.Now(), u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	u.TOTPSecret = secret
+	u.TOTPEnabled = false
+	u.TOTPLastStep = 0
+	return nil
+}
+
+// EnableTOTP 启用两步验证并保存恢复码哈希
+func (u *User) EnableTOTP(recoveryHashes []string, step int64) error {
+	_, err := DB.Exec(
+		"UPDATE users SET totp_enabled = 1, totp_last_step = ?, recovery_codes = ?, updated_at = ? WHERE id = ?",
+		step, joinList(recoveryHashes), time.Now(), u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	u.TOTPEnabled = true
+	u.TOTPLastStep = step
+	return nil
+}
+
+// DisableTOTP 关闭两步验证并清除密钥和恢复码
+func (u *User) DisableTOTP() error {
+	_, err := DB.Exec(
+		"UPDATE users SET totp_secret = '', totp_enabled = 0, totp_last_step = 0, recovery_codes = '', updated_at = ? WHERE id = ?",
+		time.Now(), u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	u.TOTPSecret = ""
+	u.TOTPEnabled = false
+	u.TOTPLastStep = 0
+	return nil
+}
+
+// SetTOTPLastStep 记录最近一次使用的时间步，防止验证码重放
+func (u *User) SetTOTPLastStep(step int64) error {
+	_, err := DB.Exec("UPDATE users SET totp_last_step = ? WHERE id = ?", step, u.ID)
+	if err != nil {
+		return err
+	}
+	u.TOTPLastStep = step
+	return nil
+}
+
+// SetRecoveryCodes 替换恢复码哈希
+func (u *User) SetRecoveryCodes(hashes []string) error {
+	_, err := DB.Exec(
+		"UPDATE users SET recovery_codes = ?, updated_at = ? WHERE id = ?",
+		joinList(hashes), time.Now(), u.ID,
+	)
+	return err
+}
+
+func (u *User) recoveryCodes() ([]string, error) {
+	var codes string
+	if err := DB.QueryRow("SELECT recovery_codes FROM users WHERE id = ?", u.ID).Scan(&codes); err != nil {
+		return nil, err
+	}
+	return splitList(codes), nil
+}
+
+// RecoveryCodeCount 剩余可用的恢复码数量
+func (u *User) RecoveryCodeCount() (int, error) {
+	codes, err := u.recoveryCodes()
+	return len(codes), err
+}
+
+// UseRecoveryCode 消耗一个恢复码，返回是否匹配
+func (u *User) UseRecoveryCode(hash string) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var stored string
+	if err := tx.QueryRow("SELECT recovery_codes FROM users WHERE id = ?", u.ID).Scan(&stored); err != nil {
+		return false, err
+	}
+
+	codes := splitList(stored)
+	for i, c := range codes {
+		if c != hash {
+			continue
+		}
+		remaining := append(codes[:i:i], codes[i+1:]...)
+		if _, err := tx.Exec("UPDATE users SET recovery_codes = ? WHERE id = ?", joinList(remaining), u.ID); err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+	return false, nil
+}
@@ -0,0 +1,281 @@
+package nginx
+
+import "strings"
+
+// indentUnit 新插入指令的缩进单位
+const indentUnit = "    "
+
+// Config 解析后的配置文件
+type Config struct {
+	Directives []*Directive
+	Trailing   string // 文件末尾的空白和注释
+}
+
+// Directive 一条指令；IsBlock 为真时 Block 为其子指令。
+// 未导出字段记录原始格式，保证输出与输入一致
+type Directive struct {
+	Name     string
+	Args     []string // 原始参数，引号保留，可用 Unquote 取值
+	IsBlock  bool
+	Block    []*Directive
+	Leading  string // 指令之前的空白和注释
+	Trailing string // 指令结束后同一行的空白和注释
+	Line     int
+
+	argSep        []string // 每个参数之前的空白
+	beforeTerm    string   // ; 或 { 之前的空白
+	blockTrailing string   // } 之前的空白和注释
+	fresh         bool     // 新建的指令，插入时按所在位置生成格式
+}
+
+// NewDirective 新建简单指令
+func NewDirective(name string, args ...string) *Directive {
+	return &Directive{Name: name, Args: args, fresh: true}
+}
+
+// NewBlock 新建块指令
+func NewBlock(name string, args []string, children ...*Directive) *Directive {
+	return &Directive{Name: name, Args: args, IsBlock: true, Block: children, fresh: true}
+}
+
+// String 输出配置文本
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, d := range c.Directives {
+		d.write(&b)
+	}
+	b.WriteString(c.Trailing)
+	return b.String()
+}
+
+// String 输出单条指令（含前导空白）
+func (d *Directive) String() string {
+	var b strings.Builder
+	d.write(&b)
+	return b.String()
+}
+
+func (d *Directive) write(b *strings.Builder) {
+	b.WriteString(d.Leading)
+	b.WriteString(d.Name)
+	for i, arg := range d.Args {
+		if i < len(d.argSep) {
+			b.WriteString(d.argSep[i])
+		} else {
+			b.WriteString(" ")
+		}
+		b.WriteString(arg)
+	}
+	b.WriteString(d.beforeTerm)
+	if d.IsBlock {
+		if d.fresh && d.beforeTerm == "" {
+			b.WriteString(" ")
+		}
+		b.WriteString("{")
+		for _, child := range d.Block {
+			child.write(b)
+		}
+		b.WriteString(d.blockTrailing)
+		b.WriteString("}")
+	} else {
+		b.WriteString(";")
+	}
+	b.WriteString(d.Trailing)
+}
+
+// Arg 返回第 i 个参数去掉引号后的值，不存在时返回空
+func (d *Directive) Arg(i int) string {
+	if i < len(d.Args) {
+		return Unquote(d.Args[i])
+	}
+	return ""
+}
+
+// SetArgs 替换参数，保留原有参数之间的空白
+func (d *Directive) SetArgs(args ...string) {
+	d.Args = args
+	if len(d.argSep) > len(args) {
+		d.argSep = d.argSep[:len(args)]
+	}
+}
+
+// Find 查找直接子指令
+func (d *Directive) Find(name string) []*Directive {
+	return find(d.Block, name)
+}
+
+// FindOne 查找第一条直接子指令
+func (d *Directive) FindOne(name string) *Directive {
+	if found := d.Find(name); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// FindAll 递归查找所有同名指令
+func (d *Directive) FindAll(name string) []*Directive {
+	return findAll(d.Block, name)
+}
+
+// Find 查找顶层指令
+func (c *Config) Find(name string) []*Directive {
+	return find(c.Directives, name)
+}
+
+// FindAll 递归查找所有同名指令
+func (c *Config) FindAll(name string) []*Directive {
+	return findAll(c.Directives, name)
+}
+
+// Servers 返回所有 server 块（包括 http 块内的）
+func (c *Config) Servers() []*Directive {
+	return c.FindAll("server")
+}
+
+func find(list []*Directive, name string) []*Directive {
+	found := []*Directive{}
+	for _, d := range list {
+		if d.Name == name {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+func findAll(list []*Directive, name string) []*Directive {
+	found := []*Directive{}
+	for _, d := range list {
+		if d.Name == name {
+			found = append(found, d)
+		}
+		if d.IsBlock {
+			found = append(found, findAll(d.Block, name)...)
+		}
+	}
+	return found
+}
+
+// Set 设置简单指令：已存在时更新第一条的参数，否则新增
+func (d *Directive) Set(name string, args ...string) *Directive {
+	if existing := d.FindOne(name); existing != nil && !existing.IsBlock {
+		existing.SetArgs(args...)
+		return existing
+	}
+	child := NewDirective(name, args...)
+	d.Add(child)
+	return child
+}
+
+// Add 插入子指令：排在最后一条同名指令之后；没有同名指令时，
+// 简单指令排在第一个块之前的简单指令之后，块指令追加到末尾
+func (d *Directive) Add(child *Directive) {
+	index := len(d.Block)
+	if same := lastIndex(d.Block, func(c *Directive) bool { return c.Name == child.Name }); same >= 0 {
+		index = same + 1
+	} else if !child.IsBlock {
+		head := d.Block
+		if first := firstIndex(d.Block, func(c *Directive) bool { return c.IsBlock }); first >= 0 {
+			head = d.Block[:first]
+		}
+		index = lastIndex(head, func(c *Directive) bool { return !c.IsBlock }) + 1
+	}
+	d.Insert(index, child)
+}
+
+// Insert 在指定位置插入子指令
+func (d *Directive) Insert(index int, child *Directive) {
+	if child.fresh {
+		indent := d.childIndent()
+		child.format(indent, index > 0 && (child.IsBlock || d.Block[index-1].IsBlock))
+		if index == len(d.Block) && !strings.Contains(d.blockTrailing, "\n") {
+			// 原本为空或单行的块，结束的 } 另起一行
+			d.blockTrailing = "\n" + d.indent()
+		}
+	}
+	d.Block = append(d.Block, nil)
+	copy(d.Block[index+1:], d.Block[index:])
+	d.Block[index] = child
+}
+
+// Remove 删除满足条件的直接子指令，返回删除数量
+func (d *Directive) Remove(match func(*Directive) bool) int {
+	kept := d.Block[:0]
+	removed := 0
+	for _, c := range d.Block {
+		if match(c) {
+			removed++
+			continue
+		}
+		kept = append(kept, c)
+	}
+	d.Block = kept
+	return removed
+}
+
+func firstIndex(list []*Directive, match func(*Directive) bool) int {
+	for i, d := range list {
+		if match(d) {
+			return i
+		}
+	}
+	return -1
+}
+
+func lastIndex(list []*Directive, match func(*Directive) bool) int {
+	for i := len(list) - 1; i >= 0; i-- {
+		if match(list[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indent 指令自身的缩进
+func (d *Directive) indent() string {
+	return indentOf(d.Leading)
+}
+
+// childIndent 子指令的缩进：沿用已有子指令，否则比自身多一级
+func (d *Directive) childIndent() string {
+	for _, c := range d.Block {
+		if strings.Contains(c.Leading, "\n") {
+			return indentOf(c.Leading)
+		}
+	}
+	return d.indent() + indentUnit
+}
+
+// indentOf 取最后一个换行之后的空白
+func indentOf(leading string) string {
+	i := strings.LastIndex(leading, "\n")
+	if i < 0 {
+		return ""
+	}
+	rest := leading[i+1:]
+	if strings.TrimLeft(rest, " \t") != "" {
+		return ""
+	}
+	return rest
+}
+
+// format 为新建指令生成格式
+func (d *Directive) format(indent string, blankLine bool) {
+	d.Leading = "\n" + indent
+	if blankLine {
+		d.Leading = "\n" + d.Leading
+	}
+	if !d.IsBlock {
+		return
+	}
+	d.beforeTerm = " "
+	for _, c := range d.Block {
+		if c.fresh {
+			c.format(indent+indentUnit, false)
+		}
+	}
+	d.blockTrailing = "\n" + indent
+	if len(d.Block) == 0 {
+		d.blockTrailing = ""
+	}
+	d.fresh = false
+}
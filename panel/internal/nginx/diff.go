@@ -0,0 +1,131 @@
+package nginx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext unified diff 中每处修改前后保留的行数
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' '、'-' 或 '+'
+	line string
+}
+
+// Diff 生成两段配置的 unified diff，内容相同时返回空字符串
+func Diff(fromName, toName, from, to string) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	changes := []int{}
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(changes); {
+		// 相邻修改之间的相同行不超过两倍上下文时合并为一个 hunk
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContext+1 {
+			j++
+		}
+		start := max(changes[i]-diffContext, 0)
+		end := min(changes[j]+diffContext+1, len(ops))
+		writeHunk(&b, ops, start, end)
+		i = j + 1
+	}
+	return b.String()
+}
+
+func writeHunk(b *strings.Builder, ops []diffOp, start, end int) {
+	fromLine, toLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			fromLine++
+		}
+		if op.kind != '-' {
+			toLine++
+		}
+	}
+	fromCount, toCount := 0, 0
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			fromCount++
+		}
+		if op.kind != '-' {
+			toCount++
+		}
+	}
+
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+	for _, op := range ops[start:end] {
+		b.WriteByte(op.kind)
+		b.WriteString(op.line)
+		b.WriteByte('\n')
+	}
+}
+
+func hunkRange(line, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", line-1)
+	case 1:
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines 基于最长公共子序列生成逐行的编辑序列
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
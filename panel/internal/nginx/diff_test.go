@@ -0,0 +1,48 @@
+package nginx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	base := mustRead(t, "testdata/cli/php.conf")
+
+	if got := Diff("a", "b", base, base); got != "" {
+		t.Errorf("Expected empty diff, got:\n%s", got)
+	}
+
+	edited := strings.Replace(base, "    listen 80;\n", "    listen 80;\n    listen [::]:80;\n", 1)
+	edited = strings.Replace(edited, "        deny all;\n", "        deny all;\n        access_log off;\n", 1)
+	want := `--- #1
++++ #2
+@@ -1,5 +1,6 @@
+ server {
+     listen 80;
++    listen [::]:80;
+     server_name example.com;
+     root /www/wwwroot/example.com;
+     index index.php index.html;
+@@ -20,5 +21,6 @@
+ 
+     location ~ /\. {
+         deny all;
++        access_log off;
+     }
+ }
+`
+	if got := Diff("#1", "#2", base, edited); got != want {
+		t.Errorf("Unexpected diff:\n%s", got)
+	}
+
+	// 距离较近的修改合并为一个 hunk
+	got := Diff("a", "b", "1\n2\n3\n4\n5\n6\n7\n8\n9\n", "1\nx\n3\n4\n5\n6\n7\ny\n9\n")
+	want = "--- a\n+++ b\n@@ -1,9 +1,9 @@\n 1\n-2\n+x\n 3\n 4\n 5\n 6\n 7\n-8\n+y\n 9\n"
+	if got != want {
+		t.Errorf("Unexpected merged diff:\n%s", got)
+	}
+
+	if got := Diff("a", "b", "", "x\n"); got != "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("Unexpected diff from empty:\n%s", got)
+	}
+}
@@ -0,0 +1,311 @@
+package nginx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoServer        = errors.New("no server block")
+	ErrLocationExists  = errors.New("location already exists")
+	ErrLocationMissing = errors.New("location not found")
+	ErrHeaderMissing   = errors.New("header not found")
+	ErrNoPHP           = errors.New("php-fpm fastcgi_pass not found")
+
+	directiveNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	headerNameRe    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+	phpSocketRe     = regexp.MustCompile(`^unix:/run/php/php[^/]*-fpm\.sock$`)
+)
+
+// locationModifiers location 支持的匹配修饰符
+var locationModifiers = map[string]bool{"": true, "=": true, "~": true, "~*": true, "^~": true}
+
+// redirectCodes 允许的跳转状态码
+var redirectCodes = map[int]bool{301: true, 302: true, 307: true, 308: true}
+
+// defaultGzipTypes 开启 gzip 时补充的默认类型
+var defaultGzipTypes = []string{
+	"text/plain", "text/css", "text/xml", "application/json",
+	"application/javascript", "application/xml", "image/svg+xml",
+}
+
+// SimpleDirective 不含子块的指令，参数为未加引号的值
+type SimpleDirective struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+}
+
+// Location 一个 location 块
+type Location struct {
+	Modifier   string            `json:"modifier"`
+	Path       string            `json:"path"`
+	Directives []SimpleDirective `json:"directives"`
+}
+
+// SiteServers 站点的主 server 块，跳过只负责跳转的 server（如 certbot 生成的 80 端口跳转）
+func (c *Config) SiteServers() []*Directive {
+	servers := c.Servers()
+	main := []*Directive{}
+	for _, s := range servers {
+		if s.FindOne("return") == nil {
+			main = append(main, s)
+		}
+	}
+	if len(main) == 0 {
+		return servers
+	}
+	return main
+}
+
+func (c *Config) siteServers() ([]*Directive, error) {
+	servers := c.SiteServers()
+	if len(servers) == 0 {
+		return nil, ErrNoServer
+	}
+	return servers, nil
+}
+
+// locationKey 解析 location 的修饰符和路径
+func locationKey(d *Directive) (string, string) {
+	switch len(d.Args) {
+	case 0:
+		return "", ""
+	case 1:
+		return "", d.Arg(0)
+	default:
+		return d.Arg(0), d.Arg(1)
+	}
+}
+
+// Locations 列出主 server 块中的 location
+func (c *Config) Locations() []Location {
+	locations := []Location{}
+	for _, s := range c.SiteServers() {
+		for _, d := range s.Find("location") {
+			l := Location{Directives: []SimpleDirective{}}
+			l.Modifier, l.Path = locationKey(d)
+			for _, child := range d.Block {
+				if child.IsBlock {
+					continue
+				}
+				args := make([]string, len(child.Args))
+				for i := range child.Args {
+					args[i] = child.Arg(i)
+				}
+				l.Directives = append(l.Directives, SimpleDirective{Name: child.Name, Args: args})
+			}
+			locations = append(locations, l)
+		}
+	}
+	return locations
+}
+
+func (c *Config) hasLocation(s *Directive, modifier, path string) bool {
+	for _, d := range s.Find("location") {
+		if m, p := locationKey(d); m == modifier && p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func validateLocation(l Location) error {
+	if !locationModifiers[l.Modifier] {
+		return fmt.Errorf("无效的 location 修饰符: %s", l.Modifier)
+	}
+	if strings.TrimSpace(l.Path) == "" {
+		return fmt.Errorf("location 路径不能为空")
+	}
+	for _, d := range l.Directives {
+		if !directiveNameRe.MatchString(d.Name) {
+			return fmt.Errorf("无效的指令名: %s", d.Name)
+		}
+	}
+	return nil
+}
+
+func newLocation(l Location) *Directive {
+	args := []string{Quote(l.Path)}
+	if l.Modifier != "" {
+		args = []string{l.Modifier, Quote(l.Path)}
+	}
+	children := make([]*Directive, 0, len(l.Directives))
+	for _, d := range l.Directives {
+		quoted := make([]string, len(d.Args))
+		for i, a := range d.Args {
+			quoted[i] = Quote(a)
+		}
+		children = append(children, NewDirective(d.Name, quoted...))
+	}
+	return NewBlock("location", args, children...)
+}
+
+// AddLocation 在每个主 server 块中新增 location，同名 location 已存在时报错
+func (c *Config) AddLocation(l Location) error {
+	if err := validateLocation(l); err != nil {
+		return err
+	}
+	servers, err := c.siteServers()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		if c.hasLocation(s, l.Modifier, l.Path) {
+			return ErrLocationExists
+		}
+	}
+	for _, s := range servers {
+		s.Add(newLocation(l))
+	}
+	return nil
+}
+
+// RemoveLocation 删除匹配的 location
+func (c *Config) RemoveLocation(modifier, path string) error {
+	removed := 0
+	for _, s := range c.SiteServers() {
+		removed += s.Remove(func(d *Directive) bool {
+			if d.Name != "location" {
+				return false
+			}
+			m, p := locationKey(d)
+			return m == modifier && p == path
+		})
+	}
+	if removed == 0 {
+		return ErrLocationMissing
+	}
+	return nil
+}
+
+// SetClientMaxBodySize 设置上传大小限制，如 50m；0 表示不限制
+func (c *Config) SetClientMaxBodySize(size string) error {
+	if !sizeRe.MatchString(size) {
+		return fmt.Errorf("大小格式应为 10m")
+	}
+	servers, err := c.siteServers()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		s.Set("client_max_body_size", size)
+	}
+	return nil
+}
+
+// SetPHPSocket 将站点中指向 PHP-FPM 的 fastcgi_pass 改为新的 socket，其余配置保持不变，
+// 与 CLI 的 site php set 一致；没有找到时返回 ErrNoPHP
+func (c *Config) SetPHPSocket(socket string) error {
+	found := 0
+	for _, s := range c.SiteServers() {
+		for _, d := range s.FindAll("fastcgi_pass") {
+			if phpSocketRe.MatchString(Unquote(d.Arg(0))) {
+				d.SetArgs("unix:" + socket)
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		return ErrNoPHP
+	}
+	return nil
+}
+
+// AddHeader 添加响应头，同名响应头会被替换。
+// 注意 nginx 中 location 内有自己的 add_header 时不会继承 server 级别的设置
+func (c *Config) AddHeader(name, value string, always bool) error {
+	if !headerNameRe.MatchString(name) {
+		return fmt.Errorf("无效的响应头名称: %s", name)
+	}
+	if value == "" {
+		return fmt.Errorf("响应头的值不能为空")
+	}
+	servers, err := c.siteServers()
+	if err != nil {
+		return err
+	}
+
+	args := []string{name, Quote(value)}
+	if always {
+		args = append(args, "always")
+	}
+	for _, s := range servers {
+		if existing := findHeader(s, name); existing != nil {
+			existing.SetArgs(args...)
+			continue
+		}
+		s.Add(NewDirective("add_header", args...))
+	}
+	return nil
+}
+
+// RemoveHeader 删除 server 级别的响应头
+func (c *Config) RemoveHeader(name string) error {
+	removed := 0
+	for _, s := range c.SiteServers() {
+		removed += s.Remove(func(d *Directive) bool {
+			return d.Name == "add_header" && strings.EqualFold(d.Arg(0), name)
+		})
+	}
+	if removed == 0 {
+		return ErrHeaderMissing
+	}
+	return nil
+}
+
+func findHeader(s *Directive, name string) *Directive {
+	for _, d := range s.Find("add_header") {
+		if strings.EqualFold(d.Arg(0), name) {
+			return d
+		}
+	}
+	return nil
+}
+
+// SetGzip 开关 gzip；开启时补充缺少的 gzip_types 等默认设置，已有设置保持不变
+func (c *Config) SetGzip(on bool) error {
+	servers, err := c.siteServers()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		if !on {
+			s.Set("gzip", "off")
+			continue
+		}
+		s.Set("gzip", "on")
+		if s.FindOne("gzip_vary") == nil {
+			s.Set("gzip_vary", "on")
+		}
+		if s.FindOne("gzip_min_length") == nil {
+			s.Set("gzip_min_length", "1024")
+		}
+		if s.FindOne("gzip_types") == nil {
+			s.Set("gzip_types", defaultGzipTypes...)
+		}
+	}
+	return nil
+}
+
+// AddRedirect 将路径精确跳转到目标地址
+func (c *Config) AddRedirect(from, to string, code int) error {
+	if !strings.HasPrefix(from, "/") {
+		return fmt.Errorf("跳转路径必须以 / 开头")
+	}
+	if to == "" {
+		return fmt.Errorf("跳转目标不能为空")
+	}
+	if !redirectCodes[code] {
+		return fmt.Errorf("跳转状态码只能是 301、302、307 或 308")
+	}
+	return c.AddLocation(Location{
+		Modifier: "=",
+		Path:     from,
+		Directives: []SimpleDirective{
+			{Name: "return", Args: []string{strconv.Itoa(code), to}},
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package nginx
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// MainConfig nginx 主配置文件，验证配置时以它为入口构建临时配置树
+var MainConfig = "/etc/nginx/nginx.conf"
+
+// Test 与 Reload 为变量，测试时可替换
+var (
+	// Test 执行 nginx -t -c conf，失败时返回 nginx 的输出
+	Test = func(conf string) error {
+		output, err := exec.Command("nginx", "-t", "-c", conf).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	// Reload 重载 nginx
+	Reload = func() error {
+		return exec.Command("systemctl", "reload", "nginx").Run()
+	}
+)
@@ -0,0 +1,142 @@
+package nginx
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// TLS 配置档位
+const (
+	TLSModern       = "modern"       // 仅 TLS 1.3
+	TLSIntermediate = "intermediate" // TLS 1.2 / 1.3，兼容绝大多数客户端
+	TLSLegacy       = "legacy"       // 兼容 TLS 1.0 / 1.1 的旧客户端
+)
+
+// hstsPreloadMinAge 提交 HSTS preload 列表要求的最短 max-age（一年）
+const hstsPreloadMinAge = 31536000
+
+// TLSProfile 一组 ssl_protocols / ssl_ciphers 设置，取自 Mozilla SSL Configuration Generator
+type TLSProfile struct {
+	Name                string `json:"name"`
+	Protocols           string `json:"protocols"`
+	Ciphers             string `json:"ciphers,omitempty"` // 为空时使用 OpenSSL 默认值（TLS 1.3 不可配置）
+	PreferServerCiphers bool   `json:"prefer_server_ciphers"`
+}
+
+const intermediateCiphers = "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:" +
+	"ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:" +
+	"ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:" +
+	"DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384:DHE-RSA-CHACHA20-POLY1305"
+
+// TLSProfiles 可选的 TLS 档位
+var TLSProfiles = map[string]TLSProfile{
+	TLSModern: {Name: TLSModern, Protocols: "TLSv1.3"},
+	TLSIntermediate: {
+		Name:      TLSIntermediate,
+		Protocols: "TLSv1.2 TLSv1.3",
+		Ciphers:   intermediateCiphers,
+	},
+	TLSLegacy: {
+		Name:      TLSLegacy,
+		Protocols: "TLSv1 TLSv1.1 TLSv1.2 TLSv1.3",
+		Ciphers: intermediateCiphers + ":" +
+			"ECDHE-ECDSA-AES128-SHA256:ECDHE-RSA-AES128-SHA256:ECDHE-ECDSA-AES128-SHA:ECDHE-RSA-AES128-SHA:" +
+			"ECDHE-ECDSA-AES256-SHA384:ECDHE-RSA-AES256-SHA384:ECDHE-ECDSA-AES256-SHA:ECDHE-RSA-AES256-SHA:" +
+			"DHE-RSA-AES128-SHA256:DHE-RSA-AES256-SHA256:AES128-GCM-SHA256:AES256-GCM-SHA384:" +
+			"AES128-SHA256:AES256-SHA256:AES128-SHA:AES256-SHA:DES-CBC3-SHA",
+		PreferServerCiphers: true,
+	},
+}
+
+// httpsParams 所有内置模板共有的 HTTPS 参数，站点配置了证书时生效
+var httpsParams = []Param{
+	{Name: "force_https", Label: "强制 HTTPS", Type: ParamBool, Default: "false", Description: "HTTP 请求 301 跳转到 HTTPS"},
+	{Name: "hsts", Label: "HSTS", Type: ParamBool, Default: "false"},
+	{Name: "hsts_max_age", Label: "HSTS 有效期（秒）", Type: ParamInt, Default: strconv.Itoa(hstsPreloadMinAge)},
+	{Name: "hsts_subdomains", Label: "HSTS 包含子域名", Type: ParamBool, Default: "false"},
+	{Name: "hsts_preload", Label: "HSTS preload", Type: ParamBool, Default: "false", Description: "要求有效期至少一年、包含子域名并强制 HTTPS"},
+	{Name: "tls_profile", Label: "TLS 配置", Type: ParamEnum, Default: TLSIntermediate, Options: []string{TLSModern, TLSIntermediate, TLSLegacy}},
+	{Name: "ocsp_stapling", Label: "OCSP Stapling", Type: ParamBool, Default: "false"},
+}
+
+func init() {
+	for i := range builtinTemplates {
+		builtinTemplates[i].Params = append(builtinTemplates[i].Params, httpsParams...)
+	}
+}
+
+// HTTPS 站点的 HTTPS 设置，对应 httpsParams
+type HTTPS struct {
+	ForceHTTPS     bool   `json:"force_https"`
+	HSTS           bool   `json:"hsts"`
+	HSTSMaxAge     int    `json:"hsts_max_age"`
+	HSTSSubdomains bool   `json:"hsts_subdomains"`
+	HSTSPreload    bool   `json:"hsts_preload"`
+	TLSProfile     string `json:"tls_profile"`
+	OCSPStapling   bool   `json:"ocsp_stapling"`
+}
+
+// HTTPSFromParams 从校验后的模板参数读取 HTTPS 设置
+func HTTPSFromParams(values map[string]interface{}) HTTPS {
+	h := HTTPS{}
+	h.ForceHTTPS, _ = values["force_https"].(bool)
+	h.HSTS, _ = values["hsts"].(bool)
+	h.HSTSMaxAge, _ = values["hsts_max_age"].(int)
+	h.HSTSSubdomains, _ = values["hsts_subdomains"].(bool)
+	h.HSTSPreload, _ = values["hsts_preload"].(bool)
+	h.TLSProfile, _ = values["tls_profile"].(string)
+	h.OCSPStapling, _ = values["ocsp_stapling"].(bool)
+	return h
+}
+
+// Params 转换为模板参数；有效期为 0 和档位为空时使用默认值
+func (h HTTPS) Params() map[string]interface{} {
+	params := map[string]interface{}{
+		"force_https":     h.ForceHTTPS,
+		"hsts":            h.HSTS,
+		"hsts_subdomains": h.HSTSSubdomains,
+		"hsts_preload":    h.HSTSPreload,
+		"ocsp_stapling":   h.OCSPStapling,
+	}
+	if h.HSTSMaxAge != 0 {
+		params["hsts_max_age"] = h.HSTSMaxAge
+	}
+	if h.TLSProfile != "" {
+		params["tls_profile"] = h.TLSProfile
+	}
+	return params
+}
+
+// HSTSHeader Strict-Transport-Security 响应头的值
+func (h HTTPS) HSTSHeader() string {
+	value := fmt.Sprintf("max-age=%d", h.HSTSMaxAge)
+	if h.HSTSSubdomains {
+		value += "; includeSubDomains"
+	}
+	if h.HSTSPreload {
+		value += "; preload"
+	}
+	return value
+}
+
+// checkHTTPS 校验 HTTPS 参数之间的约束
+func checkHTTPS(values map[string]interface{}) []ParamError {
+	h := HTTPSFromParams(values)
+	errs := []ParamError{}
+	if h.HSTSMaxAge < 0 {
+		errs = append(errs, ParamError{"hsts_max_age", "不能为负数"})
+	}
+	if h.HSTSPreload {
+		switch {
+		case !h.HSTS:
+			errs = append(errs, ParamError{"hsts_preload", "需要开启 HSTS"})
+		case h.HSTSMaxAge < hstsPreloadMinAge:
+			errs = append(errs, ParamError{"hsts_preload", "HSTS 有效期至少为 31536000 秒"})
+		case !h.HSTSSubdomains:
+			errs = append(errs, ParamError{"hsts_preload", "HSTS 需要包含子域名"})
+		case !h.ForceHTTPS:
+			errs = append(errs, ParamError{"hsts_preload", "需要开启强制 HTTPS"})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,227 @@
+package nginx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError 配置语法错误
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// Parse 解析 nginx 配置。解析结果保留所有空白和注释，未修改时 String() 与输入完全一致
+func Parse(src string) (*Config, error) {
+	p := &parser{src: src, line: 1}
+	list, trailing, err := p.parseBlock(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{Directives: list, Trailing: trailing}, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *parser) advance() {
+	if p.src[p.pos] == '\n' {
+		p.line++
+	}
+	p.pos++
+}
+
+// trivia 读取空白和注释
+func (p *parser) trivia() string {
+	start := p.pos
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.advance()
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.advance()
+			}
+		default:
+			return p.src[start:p.pos]
+		}
+	}
+	return p.src[start:p.pos]
+}
+
+// lineTrailing 读取 ; 或 } 之后同一行的空白和注释，归属于前一条指令
+func (p *parser) lineTrailing() string {
+	start := p.pos
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.advance()
+	}
+	if !p.eof() && p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.advance()
+		}
+	}
+	return p.src[start:p.pos]
+}
+
+// word 读取指令名或参数，引号原样保留
+func (p *parser) word() (string, error) {
+	start := p.pos
+	if c := p.peek(); c == '"' || c == '\'' {
+		p.advance()
+		for {
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			switch p.peek() {
+			case '\\':
+				p.advance()
+				if !p.eof() {
+					p.advance()
+				}
+				continue
+			case c:
+				p.advance()
+				return p.src[start:p.pos], nil
+			}
+			p.advance()
+		}
+	}
+
+	for !p.eof() {
+		switch c := p.peek(); c {
+		case ' ', '\t', '\r', '\n', ';', '{', '}':
+			return p.src[start:p.pos], nil
+		case '\\':
+			p.advance()
+			if !p.eof() {
+				p.advance()
+			}
+		case '$':
+			// ${var} 中的花括号属于变量名
+			p.advance()
+			if !p.eof() && p.peek() == '{' {
+				for !p.eof() && p.peek() != '}' {
+					p.advance()
+				}
+				if p.eof() {
+					return "", p.errorf("unterminated variable")
+				}
+				p.advance()
+			}
+		default:
+			p.advance()
+		}
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *parser) parseBlock(inBlock bool) ([]*Directive, string, error) {
+	list := []*Directive{}
+	for {
+		leading := p.trivia()
+		if p.eof() {
+			if inBlock {
+				return nil, "", p.errorf("unexpected end of file, expecting \"}\"")
+			}
+			return list, leading, nil
+		}
+
+		switch p.peek() {
+		case '}':
+			if !inBlock {
+				return nil, "", p.errorf("unexpected \"}\"")
+			}
+			p.advance()
+			return list, leading, nil
+		case ';', '{':
+			return nil, "", p.errorf("unexpected %q", p.peek())
+		}
+
+		d := &Directive{Leading: leading, Line: p.line}
+		name, err := p.word()
+		if err != nil {
+			return nil, "", err
+		}
+		d.Name = name
+
+	args:
+		for {
+			sep := p.trivia()
+			if p.eof() {
+				return nil, "", p.errorf("unexpected end of file, expecting \";\" or \"{\"")
+			}
+			switch p.peek() {
+			case ';':
+				d.beforeTerm = sep
+				p.advance()
+				break args
+			case '{':
+				d.beforeTerm = sep
+				p.advance()
+				d.IsBlock = true
+				d.Block, d.blockTrailing, err = p.parseBlock(true)
+				if err != nil {
+					return nil, "", err
+				}
+				break args
+			case '}':
+				return nil, "", p.errorf("unexpected \"}\"")
+			}
+
+			arg, err := p.word()
+			if err != nil {
+				return nil, "", err
+			}
+			d.Args = append(d.Args, arg)
+			d.argSep = append(d.argSep, sep)
+		}
+
+		d.Trailing = p.lineTrailing()
+		list = append(list, d)
+	}
+}
+
+// Unquote 去掉参数两侧的引号并还原转义
+func Unquote(arg string) string {
+	if len(arg) >= 2 && (arg[0] == '"' || arg[0] == '\'') && arg[len(arg)-1] == arg[0] {
+		arg = arg[1 : len(arg)-1]
+		var b strings.Builder
+		for i := 0; i < len(arg); i++ {
+			// 与 nginx 一致，只还原引号和反斜杠的转义
+			if arg[i] == '\\' && i+1 < len(arg) && strings.IndexByte(`"'\\`, arg[i+1]) >= 0 {
+				i++
+			}
+			b.WriteByte(arg[i])
+		}
+		return b.String()
+	}
+	return arg
+}
+
+// Quote 参数包含空白或特殊字符时加上双引号
+func Quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n;{}#\"'") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(value) + `"`
+}
@@ -0,0 +1,279 @@
+package nginx
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// panelParams 渲染面板模板时使用的参数
+var panelParams = map[string]map[string]interface{}{
+	"node":   {"port": 3000},
+	"pm2":    {"port": 3001},
+	"python": {"port": 8000},
+	"docker": {"port": 8080},
+	"proxy":  {"target": "https://backend.internal:8443", "websocket": true},
+}
+
+// goldenInputs CLI 生成的配置（testdata/cli）和面板各模板渲染出的配置
+func goldenInputs(t *testing.T) map[string]string {
+	t.Helper()
+	inputs := map[string]string{}
+
+	files, err := filepath.Glob("testdata/cli/*.conf")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no CLI testdata: %v", err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs["cli_"+strings.TrimSuffix(filepath.Base(f), ".conf")] = string(data)
+	}
+
+	for _, tmpl := range builtinTemplates {
+		out, err := Render(tmpl.Name, testSite, panelParams[tmpl.Name])
+		if err != nil {
+			t.Fatalf("%s: %v", tmpl.Name, err)
+		}
+		inputs["panel_"+tmpl.Name] = out
+	}
+	return inputs
+}
+
+func sortedNames(inputs map[string]string) []string {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	inputs := goldenInputs(t)
+	for _, name := range sortedNames(inputs) {
+		cfg, err := Parse(inputs[name])
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := cfg.String(); got != inputs[name] {
+			t.Errorf("%s: round trip mismatch:\n%s", name, got)
+		}
+		if len(cfg.SiteServers()) == 0 {
+			t.Errorf("%s: no server block", name)
+		}
+	}
+}
+
+// applyEdits 各个结构化接口依次修改一遍
+func applyEdits(cfg *Config) error {
+	if err := cfg.SetClientMaxBodySize("100m"); err != nil {
+		return err
+	}
+	if err := cfg.AddHeader("X-Frame-Options", "DENY", true); err != nil {
+		return err
+	}
+	if err := cfg.AddHeader("Referrer-Policy", "strict-origin-when-cross-origin", false); err != nil {
+		return err
+	}
+	if err := cfg.SetGzip(true); err != nil {
+		return err
+	}
+	if err := cfg.AddLocation(Location{
+		Modifier: "^~",
+		Path:     "/api/",
+		Directives: []SimpleDirective{
+			{Name: "proxy_pass", Args: []string{"http://127.0.0.1:9000"}},
+			{Name: "proxy_set_header", Args: []string{"Host", "$host"}},
+		},
+	}); err != nil {
+		return err
+	}
+	if err := cfg.AddRedirect("/old", "https://example.com/new page", 301); err != nil {
+		return err
+	}
+	for _, l := range cfg.Locations() {
+		if l.Modifier == "~" && strings.HasPrefix(l.Path, `/\.`) {
+			return cfg.RemoveLocation(l.Modifier, l.Path)
+		}
+	}
+	return nil
+}
+
+func TestEditGolden(t *testing.T) {
+	inputs := goldenInputs(t)
+	for _, name := range sortedNames(inputs) {
+		cfg, err := Parse(inputs[name])
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := applyEdits(cfg); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		got := cfg.String()
+
+		golden := filepath.Join("testdata", "golden", name+".conf")
+		if *update {
+			os.MkdirAll(filepath.Dir(golden), 0755)
+			if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %v (run go test -update)", name, err)
+		}
+		if got != string(want) {
+			t.Errorf("%s: output differs from %s:\n%s", name, golden, got)
+		}
+
+		// 修改后的配置仍然可以无损解析
+		reparsed, err := Parse(got)
+		if err != nil {
+			t.Errorf("%s: reparse failed: %v", name, err)
+		} else if reparsed.String() != got {
+			t.Errorf("%s: reparse round trip mismatch", name)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+	}{
+		{"server {\n    listen 80;\n", 3},
+		{"server {\n}\n}\n", 3},
+		{"listen 80", 1},
+		{"server {\n    listen 80 }\n", 2},
+		{"server {\n    return 200 \"ok;\n}\n", 4},
+		{"; listen 80;", 1},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: expected ParseError, got %v", tt.src, err)
+			continue
+		}
+		if perr.Line != tt.line {
+			t.Errorf("%q: expected error on line %d, got %v", tt.src, tt.line, perr)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	src := `server {
+    set $x "a \"quoted\" value"; # comment
+    rewrite ^/(.*)$ /index.php?q=${1}x last;
+    return 301 https://example.com/#top;
+}
+`
+	cfg, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := cfg.Servers()[0]
+	if got := s.FindOne("set").Arg(1); got != `a "quoted" value` {
+		t.Errorf("unexpected set value %q", got)
+	}
+	if got := s.FindOne("set").Trailing; got != " # comment" {
+		t.Errorf("unexpected trailing comment %q", got)
+	}
+	if got := s.FindOne("rewrite").Args; len(got) != 3 || got[1] != "/index.php?q=${1}x" {
+		t.Errorf("unexpected rewrite args %q", got)
+	}
+	if got := s.FindOne("return").Arg(1); got != "https://example.com/#top" {
+		t.Errorf("unexpected return target %q", got)
+	}
+
+	for _, v := range []string{"plain", "two words", `say "hi"`, `a\b`, "semi;colon", ""} {
+		if got := Unquote(Quote(v)); got != v {
+			t.Errorf("Quote/Unquote(%q) = %q", v, got)
+		}
+	}
+}
+
+func TestEditErrors(t *testing.T) {
+	cfg, err := Parse(mustRead(t, "testdata/cli/php.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.AddLocation(Location{Path: "/"}); !errors.Is(err, ErrLocationExists) {
+		t.Errorf("expected ErrLocationExists, got %v", err)
+	}
+	if err := cfg.RemoveLocation("", "/missing"); !errors.Is(err, ErrLocationMissing) {
+		t.Errorf("expected ErrLocationMissing, got %v", err)
+	}
+
+	invalid := []func() error{
+		func() error { return cfg.AddLocation(Location{Modifier: "!", Path: "/a"}) },
+		func() error { return cfg.AddLocation(Location{Path: " "}) },
+		func() error {
+			return cfg.AddLocation(Location{Path: "/a", Directives: []SimpleDirective{{Name: "deny all; allow"}}})
+		},
+		func() error { return cfg.SetClientMaxBodySize("100 MB") },
+		func() error { return cfg.AddHeader("X Bad", "1", false) },
+		func() error { return cfg.AddHeader("X-Empty", "", false) },
+		func() error { return cfg.AddRedirect("old", "/new", 301) },
+		func() error { return cfg.AddRedirect("/old", "/new", 200) },
+	}
+	for i, f := range invalid {
+		if err := f(); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+	if got := cfg.String(); got != mustRead(t, "testdata/cli/php.conf") {
+		t.Errorf("failed edits modified config:\n%s", got)
+	}
+
+	empty, _ := Parse("# nothing here\n")
+	if err := empty.SetGzip(true); !errors.Is(err, ErrNoServer) {
+		t.Errorf("expected ErrNoServer, got %v", err)
+	}
+}
+
+func TestHeaderReplaceAndRemove(t *testing.T) {
+	cfg, err := Parse("server {\n    listen 80;\n    add_header X-Test \"a\";\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.AddHeader("x-test", "b c", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetGzip(false); err != nil {
+		t.Fatal(err)
+	}
+	want := "server {\n    listen 80;\n    add_header x-test \"b c\" always;\n    gzip off;\n}\n"
+	if got := cfg.String(); got != want {
+		t.Errorf("unexpected config:\n%s", got)
+	}
+	if err := cfg.RemoveHeader("X-TEST"); err != nil {
+		t.Errorf("RemoveHeader failed: %v", err)
+	}
+	if err := cfg.RemoveHeader("X-Test"); !errors.Is(err, ErrHeaderMissing) {
+		t.Errorf("expected ErrHeaderMissing, got %v", err)
+	}
+}
+
+func mustRead(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
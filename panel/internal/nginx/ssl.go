@@ -0,0 +1,125 @@
+package nginx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ACMEChallengePath HTTP-01 验证文件的访问路径
+const ACMEChallengePath = "/.well-known/acme-challenge/"
+
+// listensOn listen 指令是否监听指定端口（443、[::]:443、127.0.0.1:443 等）
+func listensOn(d *Directive, port string) bool {
+	addr := d.Arg(0)
+	if i := strings.LastIndex(addr, ":"); i >= 0 && !strings.HasSuffix(addr, "]") {
+		addr = addr[i+1:]
+	}
+	return addr == port
+}
+
+// SetCertificate 为主 server 块配置证书，缺少 443 监听时按现有的 80 监听补充 listen 443 ssl http2
+func (c *Config) SetCertificate(cert, key string) error {
+	if cert == "" || key == "" {
+		return fmt.Errorf("证书和私钥路径不能为空")
+	}
+	servers, err := c.siteServers()
+	if err != nil {
+		return err
+	}
+	for _, s := range servers {
+		listens := s.Find("listen")
+		has443 := false
+		for _, l := range listens {
+			if listensOn(l, "443") {
+				has443 = true
+			}
+		}
+		if !has443 {
+			s.Add(NewDirective("listen", "443", "ssl", "http2"))
+			for _, l := range listens {
+				if strings.HasPrefix(l.Arg(0), "[::]:") && listensOn(l, "80") {
+					s.Add(NewDirective("listen", "[::]:443", "ssl", "http2"))
+					break
+				}
+			}
+		}
+		s.Set("ssl_certificate", Quote(cert))
+		s.Set("ssl_certificate_key", Quote(key))
+	}
+	return nil
+}
+
+// RemoveCertificate 删除各 server 块的证书配置、443 监听和 HSTS 响应头，以及只负责跳转到 HTTPS 的 server；
+// server 因此没有任何监听时补充 listen 80
+func (c *Config) RemoveCertificate() error {
+	servers, err := c.siteServers()
+	if err != nil {
+		return err
+	}
+	site := map[*Directive]bool{}
+	for _, s := range servers {
+		site[s] = true
+	}
+	for _, s := range c.Servers() {
+		s.Remove(func(d *Directive) bool {
+			switch d.Name {
+			case "ssl_certificate", "ssl_certificate_key":
+				return true
+			case "listen":
+				return listensOn(d, "443")
+			case "add_header":
+				return strings.EqualFold(d.Arg(0), "Strict-Transport-Security")
+			}
+			return false
+		})
+		if s.FindOne("listen") == nil {
+			s.Insert(0, NewDirective("listen", "80"))
+		}
+	}
+	c.Directives = removeBlocks(c.Directives, func(d *Directive) bool {
+		return d.Name == "server" && !site[d] && redirectsToHTTPS(d)
+	})
+	return nil
+}
+
+// redirectsToHTTPS server 块是否把请求原样跳转到 https://$host（包括 certbot 写在 if 中的）；
+// 跳转到其他域名的 server（如跳转域名）不算
+func redirectsToHTTPS(server *Directive) bool {
+	for _, r := range server.FindAll("return") {
+		if strings.HasPrefix(Unquote(r.Arg(1)), "https://$host") {
+			return true
+		}
+	}
+	return false
+}
+
+// removeBlocks 递归删除满足条件的块
+func removeBlocks(list []*Directive, match func(*Directive) bool) []*Directive {
+	kept := list[:0]
+	for _, d := range list {
+		if d.IsBlock && match(d) {
+			continue
+		}
+		if d.IsBlock {
+			d.Block = removeBlocks(d.Block, match)
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// AddACMEChallenge 让 HTTP-01 验证文件从 dir 读取，已配置时不做修改
+func (c *Config) AddACMEChallenge(dir string) error {
+	err := c.AddLocation(Location{
+		Modifier: "^~",
+		Path:     ACMEChallengePath,
+		Directives: []SimpleDirective{
+			{Name: "root", Args: []string{dir}},
+			{Name: "default_type", Args: []string{"text/plain"}},
+		},
+	})
+	if err == ErrLocationExists {
+		return nil
+	}
+	return err
+}
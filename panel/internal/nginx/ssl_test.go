@@ -0,0 +1,74 @@
+package nginx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetCertificate(t *testing.T) {
+	cfg, err := Parse(mustRead(t, "testdata/cli/php_certbot.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetCertificate("/www/ssl/example.com/fullchain.pem", "/www/ssl/example.com/privkey.pem"); err != nil {
+		t.Fatalf("SetCertificate failed: %v", err)
+	}
+	out := cfg.String()
+	// 已有 443 监听时只替换证书路径，保留行尾注释；certbot 的跳转 server 不变
+	if strings.Count(out, "listen 443") != 1 {
+		t.Errorf("Expected existing listen 443 to be reused:\n%s", out)
+	}
+	if !strings.Contains(out, "ssl_certificate /www/ssl/example.com/fullchain.pem; # managed by Certbot") {
+		t.Errorf("Expected certificate path replaced in place:\n%s", out)
+	}
+	if strings.Count(out, "ssl_certificate_key") != 1 || !strings.Contains(out, "return 301 https://$host$request_uri;") {
+		t.Errorf("Unexpected config:\n%s", out)
+	}
+
+	if err := cfg.RemoveCertificate(); err != nil {
+		t.Fatal(err)
+	}
+	out = cfg.String()
+	if strings.Contains(out, "listen 443") || strings.Contains(out, "ssl_certificate") {
+		t.Errorf("Expected certificate directives removed:\n%s", out)
+	}
+	if !strings.Contains(out, "ssl_dhparam") {
+		t.Errorf("Expected unrelated ssl settings to be kept:\n%s", out)
+	}
+	// certbot 的跳转 server 一并删除，主 server 改为监听 80
+	if strings.Contains(out, "return 301") || strings.Count(out, "server {") != 1 || !strings.Contains(out, "server {\n    listen 80;\n    server_name example.com;") {
+		t.Errorf("Expected redirect server removed and listen 80 added:\n%s", out)
+	}
+
+	cfg, _ = Parse("server {\n    listen 80;\n    listen [::]:80;\n}\n")
+	cfg.SetCertificate("/a.pem", "/b.pem")
+	cfg.AddACMEChallenge("/www/ssl/_acme")
+	cfg.AddACMEChallenge("/www/ssl/_acme")
+	want := "server {\n    listen 80;\n    listen [::]:80;\n    listen 443 ssl http2;\n    listen [::]:443 ssl http2;\n    ssl_certificate /a.pem;\n    ssl_certificate_key /b.pem;\n\n" +
+		"    location ^~ /.well-known/acme-challenge/ {\n        root /www/ssl/_acme;\n        default_type text/plain;\n    }\n}\n"
+	if got := cfg.String(); got != want {
+		t.Errorf("Unexpected config:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRemoveCertificateHTTPS(t *testing.T) {
+	site := Site{Domain: "example.com", Root: "/www/wwwroot/example.com", AccessLog: "/dev/null", ErrorLog: "/dev/null",
+		SSLCert: "/a.pem", SSLKey: "/b.pem"}
+	out, err := Render("static", site, map[string]interface{}{"force_https": true, "hsts": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := Parse(out)
+	if err := cfg.RemoveCertificate(); err != nil {
+		t.Fatal(err)
+	}
+	out = cfg.String()
+	for _, w := range []string{"return 301", "listen 443", "Strict-Transport-Security", "ssl_certificate"} {
+		if strings.Contains(out, w) {
+			t.Errorf("Unexpected %q after removing certificate:\n%s", w, out)
+		}
+	}
+	if strings.Count(out, "server {") != 1 || !strings.Contains(out, "server {\n    listen 80;\n    server_name example.com;") {
+		t.Errorf("Expected a single server listening on 80:\n%s", out)
+	}
+}
@@ -0,0 +1,466 @@
+package nginx
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+// DefaultPHPVersion 未指定 PHP 版本时使用
+const DefaultPHPVersion = "8.3"
+
+// overrideDir 管理员自定义模板目录，同名文件覆盖内置模板
+var overrideDir = ""
+
+// SetTemplateDir 设置自定义模板目录（通常为 数据目录/nginx_templates）
+func SetTemplateDir(dir string) {
+	overrideDir = dir
+}
+
+// ParamType 模板参数类型
+type ParamType string
+
+const (
+	ParamString  ParamType = "string"
+	ParamInt     ParamType = "int"
+	ParamPort    ParamType = "port"
+	ParamBool    ParamType = "bool"
+	ParamEnum    ParamType = "enum"
+	ParamURL     ParamType = "url"
+	ParamVersion ParamType = "version"
+	ParamSize    ParamType = "size" // nginx 大小，如 10m
+	ParamTime    ParamType = "time" // nginx 时间，如 30d
+)
+
+// Param 模板声明的参数
+type Param struct {
+	Name        string    `json:"name"`
+	Label       string    `json:"label"`
+	Type        ParamType `json:"type"`
+	Required    bool      `json:"required"`
+	Default     string    `json:"default,omitempty"`
+	Options     []string  `json:"options,omitempty"` // enum 可选值
+	Description string    `json:"description,omitempty"`
+}
+
+// Template 站点配置模板，名称即站点类型
+type Template struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Params      []Param `json:"params"`
+	Overridden  bool    `json:"overridden"`      // 是否被自定义模板覆盖
+	Error       string  `json:"error,omitempty"` // 自定义模板无法渲染时的错误
+}
+
+var (
+	phpVersionParam = Param{Name: "php_version", Label: "PHP 版本", Type: ParamVersion, Default: DefaultPHPVersion}
+	portParam       = Param{Name: "port", Label: "端口", Type: ParamPort, Required: true, Description: "应用监听的本地端口"}
+	expiresParam    = Param{Name: "expires", Label: "静态资源缓存", Type: ParamTime, Default: "30d"}
+	websocketParam  = Param{Name: "websocket", Label: "WebSocket", Type: ParamBool, Default: "true"}
+)
+
+// builtinTemplates 内置模板及其参数声明，顺序即展示顺序
+var builtinTemplates = []Template{
+	{Name: "php", Description: "PHP-FPM 站点", Params: []Param{phpVersionParam}},
+	{Name: "laravel", Description: "Laravel 应用（public 目录为根）", Params: []Param{
+		phpVersionParam,
+		{Name: "client_max_body_size", Label: "上传大小限制", Type: ParamSize, Default: "50m"},
+	}},
+	{Name: "static", Description: "纯静态站点", Params: []Param{expiresParam}},
+	{Name: "spa", Description: "单页应用（history 路由回退到 index.html）", Params: []Param{expiresParam}},
+	{Name: "node", Description: "Node.js 应用（supervisor 托管）", Params: []Param{portParam, websocketParam}},
+	{Name: "pm2", Description: "Node.js 应用（PM2 托管）", Params: []Param{portParam, websocketParam}},
+	{Name: "python", Description: "Python 应用（HTTP 或 uWSGI）", Params: []Param{
+		portParam,
+		{Name: "protocol", Label: "协议", Type: ParamEnum, Default: "http", Options: []string{"http", "uwsgi"}},
+		{Name: "websocket", Label: "WebSocket", Type: ParamBool, Default: "false"},
+	}},
+	{Name: "docker", Description: "Docker 容器（反向代理到映射端口）", Params: []Param{
+		portParam,
+		{Name: "websocket", Label: "WebSocket", Type: ParamBool, Default: "false"},
+	}},
+	{Name: "proxy", Description: "反向代理", Params: []Param{
+		{Name: "target", Label: "代理目标", Type: ParamURL, Required: true, Description: "如 http://127.0.0.1:8080"},
+		{Name: "websocket", Label: "WebSocket", Type: ParamBool, Default: "false"},
+	}},
+}
+
+// Templates 列出所有模板
+func Templates() []Template {
+	list := make([]Template, 0, len(builtinTemplates))
+	for _, t := range builtinTemplates {
+		t.Overridden = overridePath(t.Name) != ""
+		if t.Overridden {
+			source, err := t.Source()
+			if err == nil {
+				err = t.CheckSource(source)
+			}
+			if err != nil {
+				t.Error = err.Error()
+			}
+		}
+		list = append(list, t)
+	}
+	return list
+}
+
+// GetTemplate 按名称获取模板
+func GetTemplate(name string) (*Template, error) {
+	for _, t := range builtinTemplates {
+		if t.Name == name {
+			t.Overridden = overridePath(name) != ""
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown template: %s", name)
+}
+
+// Declares 模板是否声明了该参数
+func (t *Template) Declares(name string) bool {
+	for _, p := range t.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func overridePath(name string) string {
+	if overrideDir == "" {
+		return ""
+	}
+	path := filepath.Join(overrideDir, name+".tmpl")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// Source 返回模板当前生效的源码
+func (t *Template) Source() (string, error) {
+	if path := overridePath(t.Name); path != "" {
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+	data, err := builtinFS.ReadFile("templates/" + t.Name + ".tmpl")
+	return string(data), err
+}
+
+// ParamError 参数校验错误
+type ParamError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// ValidationError 汇总所有参数错误
+type ValidationError struct {
+	Errors []ParamError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, pe := range e.Errors {
+		msgs = append(msgs, pe.Param+": "+pe.Message)
+	}
+	return "invalid params: " + strings.Join(msgs, "; ")
+}
+
+var (
+	versionRe = regexp.MustCompile(`^\d+\.\d+$`)
+	sizeRe    = regexp.MustCompile(`^\d+[kKmMgG]?$`)
+	timeRe    = regexp.MustCompile(`^(off|epoch|max|-?\d+(ms|s|m|h|d|w|M|y)?)$`)
+	// 参数值会写入 nginx 配置，禁止分号、花括号、引号和空白以防注入指令
+	unsafeRe = regexp.MustCompile(`[;{}"'\s\\$]`)
+)
+
+// Validate 校验参数并补充默认值，返回按类型转换后的值
+func (t *Template) Validate(params map[string]interface{}) (map[string]interface{}, error) {
+	declared := map[string]bool{}
+	result := map[string]interface{}{}
+	errs := []ParamError{}
+
+	for _, p := range t.Params {
+		declared[p.Name] = true
+
+		raw, ok := params[p.Name]
+		value := ""
+		if f, isFloat := raw.(float64); isFloat {
+			// JSON 中的数字解码为 float64，大数用 %v 会输出为 3.1536e+07
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		} else if ok && raw != nil {
+			value = strings.TrimSpace(fmt.Sprint(raw))
+		}
+		if value == "" {
+			value = p.Default
+		}
+		if value == "" {
+			if p.Required {
+				errs = append(errs, ParamError{p.Name, "必填"})
+			}
+			continue
+		}
+
+		v, err := p.convert(value)
+		if err != nil {
+			errs = append(errs, ParamError{p.Name, err.Error()})
+			continue
+		}
+		result[p.Name] = v
+	}
+
+	unknown := []string{}
+	for name := range params {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, ParamError{name, "未声明的参数"})
+	}
+	if t.Declares("force_https") {
+		errs = append(errs, checkHTTPS(result)...)
+	}
+
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return result, nil
+}
+
+func (p Param) convert(value string) (interface{}, error) {
+	switch p.Type {
+	case ParamInt, ParamPort:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("必须是整数")
+		}
+		if p.Type == ParamPort && (n < 1 || n > 65535) {
+			return nil, fmt.Errorf("端口范围 1-65535")
+		}
+		return n, nil
+	case ParamBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("必须是布尔值")
+		}
+		return b, nil
+	case ParamEnum:
+		for _, o := range p.Options {
+			if value == o {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("可选值: %s", strings.Join(p.Options, ", "))
+	case ParamVersion:
+		if !versionRe.MatchString(value) {
+			return nil, fmt.Errorf("版本格式应为 8.3")
+		}
+	case ParamSize:
+		if !sizeRe.MatchString(value) {
+			return nil, fmt.Errorf("大小格式应为 10m")
+		}
+	case ParamTime:
+		if !timeRe.MatchString(value) {
+			return nil, fmt.Errorf("时间格式应为 30d")
+		}
+	case ParamURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("必须是 http:// 或 https:// 地址")
+		}
+	}
+	if unsafeRe.MatchString(value) {
+		return nil, fmt.Errorf("包含非法字符")
+	}
+	return value, nil
+}
+
+// Site 渲染模板所需的站点信息
+type Site struct {
+	Domain    string
+	Type      string
+	Root      string
+	AccessLog string
+	ErrorLog  string
+	Created   string // 创建日期，写入配置头部注释
+	SSLCert   string // 证书路径，为空时只监听 80 端口
+	SSLKey    string
+	Aliases   []string   // server_name 中主域名之外的域名
+	Redirects []Redirect // 跳转到规范域名的域名，每个状态码一个 server
+	Canonical string     // 规范域名，其余域名跳转到该域名
+	Backends  *Upstream  // 负载均衡后端组，为 nil 或没有后端时直接代理到 target
+}
+
+// Upstream 负载均衡后端组，渲染为 upstream 块，proxy_pass 指向该组
+type Upstream struct {
+	Name    string
+	Method  string // 空为轮询，least_conn 或 ip_hash
+	Servers []UpstreamServer
+}
+
+// UpstreamServer upstream 块中的一个 server
+type UpstreamServer struct {
+	Address     string
+	Weight      int
+	MaxFails    int
+	FailTimeout string
+	Backup      bool
+	Down        bool
+}
+
+// UpstreamMethods 支持的负载均衡方式，空字符串为轮询
+var UpstreamMethods = []string{"", "least_conn", "ip_hash"}
+
+// UpstreamName 站点后端组的名称，与 lib/proxy.sh 一致
+func UpstreamName(domain string) string {
+	return "upstream_" + strings.ReplaceAll(domain, ".", "_")
+}
+
+// check 后端组会原样写入配置，拒绝未知的均衡方式和可注入指令的地址
+func (u *Upstream) check() error {
+	known := false
+	for _, m := range UpstreamMethods {
+		known = known || u.Method == m
+	}
+	if !known {
+		return fmt.Errorf("unknown upstream method: %s", u.Method)
+	}
+	for _, s := range u.Servers {
+		if s.Address == "" || unsafeRe.MatchString(s.Address) || unsafeRe.MatchString(s.FailTimeout) {
+			return fmt.Errorf("invalid upstream server: %s", s.Address)
+		}
+		if s.Backup && u.Method == "ip_hash" {
+			return fmt.Errorf("ip_hash does not support backup servers")
+		}
+	}
+	return nil
+}
+
+// Redirect 使用同一状态码跳转的域名
+type Redirect struct {
+	Code    int
+	Domains []string
+}
+
+// PrimaryHost 跳转的目标域名：规范域名，未设置时为主域名
+func (s Site) PrimaryHost() string {
+	if s.Canonical != "" {
+		return s.Canonical
+	}
+	return s.Domain
+}
+
+// renderData 模板可用的数据
+type renderData struct {
+	Site
+	Params   map[string]interface{}
+	Upstream string // 反向代理地址：target 参数或 127.0.0.1:port
+	TLS      TLSProfile
+	HSTS     string // Strict-Transport-Security 的值
+}
+
+// Render 校验参数并渲染站点配置
+func Render(name string, site Site, params map[string]interface{}) (string, error) {
+	t, err := GetTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	values, err := t.Validate(params)
+	if err != nil {
+		return "", err
+	}
+	source, err := t.Source()
+	if err != nil {
+		return "", err
+	}
+	return execute(site, name, source, values)
+}
+
+func execute(site Site, name, source string, values map[string]interface{}) (string, error) {
+	common, err := builtinFS.ReadFile("templates/_common.tmpl")
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("_common").Option("missingkey=zero").Parse(string(common))
+	if err == nil {
+		tmpl, err = tmpl.New(name).Parse(source)
+	}
+	if err != nil {
+		return "", fmt.Errorf("template %s: %w", name, err)
+	}
+
+	if site.Type == "" {
+		site.Type = name
+	}
+	data := renderData{Site: site, Params: values}
+	if target, ok := values["target"].(string); ok {
+		data.Upstream = target
+	} else if port, ok := values["port"].(int); ok {
+		data.Upstream = fmt.Sprintf("http://127.0.0.1:%d", port)
+	}
+	if b := site.Backends; b != nil && len(b.Servers) > 0 {
+		if err := b.check(); err != nil {
+			return "", err
+		}
+		// 沿用 target 的协议和路径，主机换成后端组
+		u, err := url.Parse(data.Upstream)
+		if err != nil || u.Scheme == "" {
+			u = &url.URL{Scheme: "http"}
+		}
+		u.Host = b.Name
+		data.Upstream = u.String()
+	} else {
+		data.Site.Backends = nil
+	}
+	https := HTTPSFromParams(values)
+	data.TLS = TLSProfiles[https.TLSProfile]
+	data.HSTS = https.HSTSHeader()
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// CheckSource 使用参数默认值试渲染模板源码，用于校验自定义模板
+func (t *Template) CheckSource(source string) error {
+	params := map[string]interface{}{}
+	for _, p := range t.Params {
+		if p.Required && p.Default == "" {
+			params[p.Name] = sampleValue(p)
+		}
+	}
+	values, err := t.Validate(params)
+	if err != nil {
+		return err
+	}
+	_, err = execute(Site{Domain: "example.com", Root: "/www/wwwroot/example.com", AccessLog: "/dev/null", ErrorLog: "/dev/null"}, t.Name, source, values)
+	return err
+}
+
+func sampleValue(p Param) string {
+	switch p.Type {
+	case ParamInt, ParamPort:
+		return "8080"
+	case ParamURL:
+		return "http://127.0.0.1:8080"
+	case ParamEnum:
+		return p.Options[0]
+	case ParamVersion:
+		return DefaultPHPVersion
+	}
+	return "x"
+}
@@ -0,0 +1,327 @@
+package nginx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testSite = Site{
+	Domain:    "example.com",
+	Root:      "/www/wwwroot/example.com",
+	AccessLog: "/www/wwwlogs/nginx/example.com.access.log",
+	ErrorLog:  "/www/wwwlogs/nginx/example.com.error.log",
+	Created:   "2024-01-01",
+}
+
+func TestRenderBuiltinTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    []string
+		notWant []string
+	}{
+		{"php", map[string]interface{}{"php_version": "8.1"}, []string{"# Type: php", "fastcgi_pass unix:/run/php/php8.1-fpm.sock;", "root /www/wwwroot/example.com;"}, nil},
+		{"laravel", nil, []string{"php8.3-fpm.sock", "client_max_body_size 50m;", "error_page 404 /index.php;"}, nil},
+		{"static", map[string]interface{}{"expires": "7d"}, []string{"try_files $uri $uri/ =404;", "expires 7d;"}, []string{"fastcgi_pass"}},
+		{"spa", nil, []string{"try_files $uri $uri/ /index.html;", "expires 30d;"}, nil},
+		{"node", map[string]interface{}{"port": 3000}, []string{"proxy_pass http://127.0.0.1:3000;", "Connection \"upgrade\""}, nil},
+		{"pm2", map[string]interface{}{"port": "3001", "websocket": false}, []string{"proxy_pass http://127.0.0.1:3001;"}, []string{"Upgrade"}},
+		{"python", map[string]interface{}{"port": 8000}, []string{"proxy_pass http://127.0.0.1:8000;", "alias /www/wwwroot/example.com/static;"}, []string{"uwsgi_pass"}},
+		{"python", map[string]interface{}{"port": 8000, "protocol": "uwsgi"}, []string{"uwsgi_pass 127.0.0.1:8000;", "include uwsgi_params;"}, []string{"proxy_pass"}},
+		{"docker", map[string]interface{}{"port": float64(8080)}, []string{"proxy_pass http://127.0.0.1:8080;"}, nil},
+		{"proxy", map[string]interface{}{"target": "https://backend.internal:8443", "websocket": "true"}, []string{"proxy_pass https://backend.internal:8443;", "proxy_set_header Upgrade"}, nil},
+	}
+
+	for _, tt := range tests {
+		out, err := Render(tt.name, testSite, tt.params)
+		if err != nil {
+			t.Errorf("%s: Render failed: %v", tt.name, err)
+			continue
+		}
+		for _, w := range append(tt.want, "server_name example.com;", "access_log "+testSite.AccessLog+";") {
+			if !strings.Contains(out, w) {
+				t.Errorf("%s: expected %q in output:\n%s", tt.name, w, out)
+			}
+		}
+		for _, w := range tt.notWant {
+			if strings.Contains(out, w) {
+				t.Errorf("%s: unexpected %q in output:\n%s", tt.name, w, out)
+			}
+		}
+		if strings.Contains(out, "<no value>") {
+			t.Errorf("%s: missing value in output:\n%s", tt.name, out)
+		}
+	}
+
+	if len(Templates()) != len(builtinTemplates) {
+		t.Errorf("Expected %d templates", len(builtinTemplates))
+	}
+	for _, tmpl := range Templates() {
+		if err := tmpl.CheckSource(mustSource(t, tmpl.Name)); err != nil {
+			t.Errorf("%s: %v", tmpl.Name, err)
+		}
+	}
+}
+
+func mustSource(t *testing.T, name string) string {
+	t.Helper()
+	tmpl, err := GetTemplate(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := tmpl.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+func TestRenderHTTPS(t *testing.T) {
+	site := testSite
+	site.SSLCert, site.SSLKey = "/www/ssl/example.com/fullchain.pem", "/www/ssl/example.com/privkey.pem"
+
+	// 有证书时同时监听 80 和 443，默认使用 intermediate 档位
+	out, err := Render("static", site, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{
+		"server {\n    listen 80;\n    listen 443 ssl http2;\n    server_name example.com;\n\n    ssl_certificate " + site.SSLCert + ";",
+		"ssl_protocols TLSv1.2 TLSv1.3;",
+		"ssl_ciphers ECDHE-ECDSA-AES128-GCM-SHA256:",
+		"ssl_prefer_server_ciphers off;",
+	} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Expected %q in output:\n%s", w, out)
+		}
+	}
+	for _, w := range []string{"return 301", "Strict-Transport-Security", "ssl_stapling"} {
+		if strings.Contains(out, w) {
+			t.Errorf("Unexpected %q in output:\n%s", w, out)
+		}
+	}
+
+	out, err = Render("proxy", site, map[string]interface{}{
+		"target": "http://127.0.0.1:8080", "force_https": true, "hsts": true, "hsts_max_age": 63072000,
+		"hsts_subdomains": true, "hsts_preload": true, "tls_profile": "modern", "ocsp_stapling": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect := "server {\n    listen 80;\n    server_name example.com;\n    return 301 https://$host$request_uri;\n}\n\nserver {\n    listen 443 ssl http2;\n    server_name example.com;"
+	for _, w := range []string{
+		redirect,
+		"ssl_protocols TLSv1.3;\n    ssl_prefer_server_ciphers off;",
+		"ssl_stapling on;\n    ssl_stapling_verify on;",
+		`add_header Strict-Transport-Security "max-age=63072000; includeSubDomains; preload" always;`,
+	} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Expected %q in output:\n%s", w, out)
+		}
+	}
+	if strings.Contains(out, "ssl_ciphers") {
+		t.Errorf("Expected no ssl_ciphers for modern profile:\n%s", out)
+	}
+
+	// 跳转 server 不是站点的主 server，结构化修改只作用于 443 的 server
+	cfg, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if servers := cfg.SiteServers(); len(servers) != 1 || servers[0].FindOne("ssl_certificate") == nil {
+		t.Errorf("Expected only the HTTPS server as site server, got %d", len(servers))
+	}
+
+	out, _ = Render("php", site, map[string]interface{}{"tls_profile": "legacy"})
+	if !strings.Contains(out, "ssl_protocols TLSv1 TLSv1.1 TLSv1.2 TLSv1.3;") || !strings.Contains(out, "DES-CBC3-SHA;") || !strings.Contains(out, "ssl_prefer_server_ciphers on;") {
+		t.Errorf("Unexpected legacy profile output:\n%s", out)
+	}
+}
+
+func TestRenderAliases(t *testing.T) {
+	site := testSite
+	site.Aliases = []string{"www.example.com", "example.org"}
+	site.Redirects = []Redirect{{Code: 301, Domains: []string{"example.net", "www.example.net"}}, {Code: 302, Domains: []string{"promo.example.com"}}}
+	site.Canonical = "www.example.com"
+
+	out, err := Render("static", site, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{
+		"server {\n    listen 80;\n    server_name example.net www.example.net;\n    return 301 $scheme://www.example.com$request_uri;\n}",
+		"server {\n    listen 80;\n    server_name promo.example.com;\n    return 302 $scheme://www.example.com$request_uri;\n}",
+		"server_name example.com www.example.com example.org;\n\n    if ($host != www.example.com) {\n        return 301 $scheme://www.example.com$request_uri;\n    }",
+	} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Expected %q in output:\n%s", w, out)
+		}
+	}
+	cfg, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if servers := cfg.SiteServers(); len(servers) != 1 || servers[0].FindOne("root") == nil {
+		t.Errorf("Expected redirect servers to be excluded from site servers, got %d", len(servers))
+	}
+
+	// 有证书时跳转域名同样监听 443；删除证书后跳转域名保留，只去掉 443
+	site.SSLCert, site.SSLKey = "/a.pem", "/b.pem"
+	out, _ = Render("static", site, map[string]interface{}{"force_https": true})
+	if !strings.Contains(out, "listen 443 ssl http2;\n    ssl_certificate /a.pem;\n    ssl_certificate_key /b.pem;\n    server_name example.net www.example.net;") ||
+		!strings.Contains(out, "server_name example.com www.example.com example.org;\n    return 301 https://$host$request_uri;") {
+		t.Errorf("Unexpected HTTPS output:\n%s", out)
+	}
+	cfg, _ = Parse(out)
+	cfg.RemoveCertificate()
+	out = cfg.String()
+	if strings.Contains(out, "listen 443") || strings.Contains(out, "https://$host") || strings.Count(out, "return 30") != 3 {
+		t.Errorf("Expected redirect domains kept without 443:\n%s", out)
+	}
+}
+
+func TestRenderUpstream(t *testing.T) {
+	site := testSite
+	site.Backends = &Upstream{Name: UpstreamName(site.Domain), Method: "least_conn", Servers: []UpstreamServer{
+		{Address: "10.0.0.2:8080", Weight: 3, MaxFails: 2, FailTimeout: "30s"},
+		{Address: "10.0.0.3:8080", Down: true},
+		{Address: "unix:/run/app.sock", Backup: true},
+	}}
+	out, err := Render("proxy", site, map[string]interface{}{"target": "https://127.0.0.1:8080/api/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{
+		"upstream upstream_example_com {\n    least_conn;\n    server 10.0.0.2:8080 weight=3 max_fails=2 fail_timeout=30s;\n    server 10.0.0.3:8080 down;\n    server unix:/run/app.sock backup;\n}",
+		"proxy_pass https://upstream_example_com/api/;",
+	} {
+		if !strings.Contains(out, w) {
+			t.Errorf("Expected %q in output:\n%s", w, out)
+		}
+	}
+	if _, err := Parse(out); err != nil {
+		t.Errorf("Rendered config does not parse: %v", err)
+	}
+
+	// 没有后端时直接代理到 target
+	site.Backends.Servers = nil
+	out, _ = Render("proxy", site, map[string]interface{}{"target": "http://127.0.0.1:8080"})
+	if strings.Contains(out, "upstream ") || !strings.Contains(out, "proxy_pass http://127.0.0.1:8080;") {
+		t.Errorf("Expected plain proxy_pass without backends:\n%s", out)
+	}
+
+	for _, b := range []*Upstream{
+		{Name: "u", Method: "random", Servers: []UpstreamServer{{Address: "10.0.0.2:80"}}},
+		{Name: "u", Servers: []UpstreamServer{{Address: "10.0.0.2:80; return 200"}}},
+		{Name: "u", Method: "ip_hash", Servers: []UpstreamServer{{Address: "10.0.0.2:80", Backup: true}}},
+	} {
+		site.Backends = b
+		if _, err := Render("proxy", site, map[string]interface{}{"target": "http://127.0.0.1:8080"}); err == nil {
+			t.Errorf("Expected invalid upstream to be rejected: %+v", b)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		errors []string // 出错的参数名
+	}{
+		{"node", map[string]interface{}{}, []string{"port"}},
+		{"node", map[string]interface{}{"port": 70000}, []string{"port"}},
+		{"node", map[string]interface{}{"port": "abc", "websocket": "maybe"}, []string{"port", "websocket"}},
+		{"php", map[string]interface{}{"php_version": "8"}, []string{"php_version"}},
+		{"php", map[string]interface{}{"port": 80, "extra": 1}, []string{"extra", "port"}},
+		{"proxy", map[string]interface{}{"target": "ftp://host"}, []string{"target"}},
+		{"proxy", map[string]interface{}{"target": "http://a;b"}, []string{"target"}},
+		{"proxy", map[string]interface{}{"target": "http://a/ { return 200; }"}, []string{"target"}},
+		{"python", map[string]interface{}{"port": 8000, "protocol": "fastcgi"}, []string{"protocol"}},
+		{"laravel", map[string]interface{}{"client_max_body_size": "10 m"}, []string{"client_max_body_size"}},
+		{"static", map[string]interface{}{"expires": "forever"}, []string{"expires"}},
+		{"static", map[string]interface{}{"tls_profile": "strict"}, []string{"tls_profile"}},
+		{"static", map[string]interface{}{"hsts_max_age": -1}, []string{"hsts_max_age"}},
+		{"static", map[string]interface{}{"hsts_preload": true}, []string{"hsts_preload"}},
+		{"static", map[string]interface{}{"hsts": true, "hsts_preload": true, "hsts_subdomains": true, "hsts_max_age": 300, "force_https": true}, []string{"hsts_preload"}},
+		{"static", map[string]interface{}{"hsts": true, "hsts_preload": true, "hsts_subdomains": true, "force_https": true}, nil},
+		{"php", nil, nil},
+	}
+
+	for _, tt := range tests {
+		tmpl, _ := GetTemplate(tt.name)
+		_, err := tmpl.Validate(tt.params)
+		if len(tt.errors) == 0 {
+			if err != nil {
+				t.Errorf("%s %v: unexpected error %v", tt.name, tt.params, err)
+			}
+			continue
+		}
+
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Errorf("%s %v: expected ValidationError, got %v", tt.name, tt.params, err)
+			continue
+		}
+		got := []string{}
+		for _, pe := range ve.Errors {
+			got = append(got, pe.Param)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.errors, ",") {
+			t.Errorf("%s %v: expected errors for %v, got %v", tt.name, tt.params, tt.errors, got)
+		}
+	}
+
+	tmpl, _ := GetTemplate("node")
+	values, err := tmpl.Validate(map[string]interface{}{"port": "3000"})
+	if err != nil || values["port"] != 3000 || values["websocket"] != true {
+		t.Errorf("Expected typed values with defaults, got %v, %v", values, err)
+	}
+
+	if _, err := GetTemplate("perl"); err == nil {
+		t.Error("Expected unknown template error")
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	SetTemplateDir(dir)
+	defer SetTemplateDir("")
+
+	custom := "{{template \"header\" .}}\nserver {\n{{template \"server\" .}}\n    root {{.Root}};\n    # custom php {{.Params.php_version}}\n}\n"
+	os.WriteFile(filepath.Join(dir, "php.tmpl"), []byte(custom), 0644)
+	os.WriteFile(filepath.Join(dir, "static.tmpl"), []byte("{{.Params.expires"), 0644)
+
+	out, err := Render("php", testSite, nil)
+	if err != nil || !strings.Contains(out, "# custom php 8.3") || strings.Contains(out, "fastcgi_pass") {
+		t.Errorf("Expected override to be used, got %v:\n%s", err, out)
+	}
+
+	// 覆盖模板仍然使用内置参数声明
+	if _, err := Render("php", testSite, map[string]interface{}{"php_version": "x"}); err == nil {
+		t.Error("Expected validation error for override template")
+	}
+
+	if _, err := Render("static", testSite, nil); err == nil {
+		t.Error("Expected parse error for broken override")
+	}
+
+	for _, tmpl := range Templates() {
+		switch tmpl.Name {
+		case "php":
+			if !tmpl.Overridden || tmpl.Error != "" {
+				t.Errorf("Unexpected php template state: %+v", tmpl)
+			}
+		case "static":
+			if !tmpl.Overridden || tmpl.Error == "" {
+				t.Errorf("Expected broken override to report an error: %+v", tmpl)
+			}
+		default:
+			if tmpl.Overridden {
+				t.Errorf("%s should not be overridden", tmpl.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package nginx
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxLinkDepth 解析符号链接的最大层数
+const maxLinkDepth = 16
+
+type changeKind int
+
+const (
+	changeWrite changeKind = iota
+	changeRemove
+	changeSymlink
+)
+
+type change struct {
+	kind   changeKind
+	data   []byte
+	target string
+}
+
+// Tx 一组配置文件变更。Commit 时先在临时配置树中执行 nginx -t，
+// 通过后再替换实际文件并重载，任何一步失败都恢复原文件
+type Tx struct {
+	order   []string
+	changes map[string]change
+}
+
+// TestError nginx -t 未通过
+type TestError struct {
+	Lines []string // nginx 输出的错误行，临时路径已替换为实际路径
+}
+
+func (e *TestError) Error() string {
+	return strings.Join(e.Lines, "\n")
+}
+
+// Begin 开始一组变更
+func Begin() *Tx {
+	return &Tx{changes: map[string]change{}}
+}
+
+func (tx *Tx) set(path string, c change) {
+	path = filepath.Clean(path)
+	if _, ok := tx.changes[path]; !ok {
+		tx.order = append(tx.order, path)
+	}
+	tx.changes[path] = c
+}
+
+// WriteFile 写入文件
+func (tx *Tx) WriteFile(path string, data []byte) {
+	tx.set(path, change{kind: changeWrite, data: data})
+}
+
+// Remove 删除文件或符号链接，不存在时忽略
+func (tx *Tx) Remove(path string) {
+	tx.set(path, change{kind: changeRemove})
+}
+
+// Symlink 创建符号链接，已存在时替换
+func (tx *Tx) Symlink(target, link string) {
+	tx.set(link, change{kind: changeSymlink, target: target})
+}
+
+// Rename 移动文件或符号链接，源文件可以是本事务中写入的
+func (tx *Tx) Rename(from, to string) error {
+	from = filepath.Clean(from)
+	if c, ok := tx.changes[from]; ok {
+		if c.kind == changeRemove {
+			return os.ErrNotExist
+		}
+		tx.set(to, c)
+	} else if target, err := os.Readlink(from); err == nil {
+		tx.Symlink(target, to)
+	} else {
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return err
+		}
+		tx.WriteFile(to, data)
+	}
+	tx.Remove(from)
+	return nil
+}
+
+// Empty 是否没有任何变更
+func (tx *Tx) Empty() bool {
+	return len(tx.order) == 0
+}
+
+// Validate 在临时配置树中执行 nginx -t，不修改实际文件
+func (tx *Tx) Validate() error {
+	dir, err := os.MkdirTemp("", "nginx-tx-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	conf, paths, err := tx.buildTree(dir)
+	if err != nil {
+		return err
+	}
+	if err := Test(conf); err != nil {
+		return newTestError(err.Error(), strings.NewReplacer(paths...))
+	}
+	return nil
+}
+
+// Commit 验证、替换并重载；验证或替换失败时恢复原文件
+func (tx *Tx) Commit() error {
+	if tx.Empty() {
+		return nil
+	}
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+
+	backups := make([]backup, 0, len(tx.order))
+	for _, path := range tx.order {
+		b, err := snapshot(path)
+		if err != nil {
+			return err
+		}
+		backups = append(backups, b)
+	}
+	for _, path := range tx.order {
+		if err := apply(path, tx.changes[path]); err != nil {
+			restore(backups)
+			return err
+		}
+	}
+
+	// 配置已通过验证，重载失败（如 nginx 未运行）不回滚
+	if err := Reload(); err != nil {
+		log.Printf("[nginx] 重载失败: %v", err)
+	}
+	return nil
+}
+
+// buildTree 在 dir 中构建临时配置树：主配置目录的其他文件以符号链接引入，
+// 主配置中 include 的目录复制为按提交后状态的镜像。返回临时主配置路径和临时路径到实际路径的替换表
+func (tx *Tx) buildTree(dir string) (string, []string, error) {
+	content, err := os.ReadFile(MainConfig)
+	if err != nil {
+		return "", nil, fmt.Errorf("读取 nginx 主配置失败: %w", err)
+	}
+	cfg, err := Parse(string(content))
+	if err != nil {
+		return "", nil, fmt.Errorf("解析 nginx 主配置失败: %w", err)
+	}
+
+	confDir := filepath.Dir(MainConfig)
+	entries, err := os.ReadDir(confDir)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, e := range entries {
+		if e.Name() != filepath.Base(MainConfig) {
+			os.Symlink(filepath.Join(confDir, e.Name()), filepath.Join(dir, e.Name()))
+		}
+	}
+
+	paths := []string{}
+	mirrors := map[string]string{}
+	for _, inc := range cfg.FindAll("include") {
+		pattern := inc.Arg(0)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(confDir, pattern)
+		}
+		realDir := filepath.Dir(pattern)
+		if realDir == confDir {
+			continue
+		}
+
+		mirror, ok := mirrors[realDir]
+		if !ok {
+			mirror = filepath.Join(dir, ".staged", strconv.Itoa(len(mirrors)))
+			if err := tx.mirror(realDir, mirror); err != nil {
+				return "", nil, err
+			}
+			mirrors[realDir] = mirror
+			paths = append(paths, mirror, realDir)
+		}
+		inc.SetArgs(Quote(filepath.Join(mirror, filepath.Base(pattern))))
+	}
+
+	conf := filepath.Join(dir, filepath.Base(MainConfig))
+	if err := os.WriteFile(conf, []byte(cfg.String()), 0644); err != nil {
+		return "", nil, err
+	}
+	paths = append(paths, conf, MainConfig, dir, confDir)
+	return conf, paths, nil
+}
+
+// mirror 将 realDir 按提交后的状态复制到 mirror：改动的文件写入内容，其余文件链接到实际路径
+func (tx *Tx) mirror(realDir, mirror string) error {
+	if err := os.MkdirAll(mirror, 0755); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	entries, _ := os.ReadDir(realDir)
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	for _, path := range tx.order {
+		if filepath.Dir(path) == realDir {
+			names[filepath.Base(path)] = true
+		}
+	}
+
+	for name := range names {
+		data, target, ok := tx.resolve(filepath.Join(realDir, name))
+		if !ok {
+			continue
+		}
+		dst := filepath.Join(mirror, name)
+		var err error
+		if data != nil {
+			err = os.WriteFile(dst, data, 0644)
+		} else {
+			err = os.Symlink(target, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve 按提交后的状态解析路径：返回暂存的内容，或未改动的实际文件路径
+func (tx *Tx) resolve(path string) ([]byte, string, bool) {
+	for i := 0; i < maxLinkDepth; i++ {
+		if c, ok := tx.changes[path]; ok {
+			switch c.kind {
+			case changeWrite:
+				return c.data, "", true
+			case changeRemove:
+				return nil, "", false
+			}
+			path = linkTarget(path, c.target)
+			continue
+		}
+
+		target, err := os.Readlink(path)
+		if err != nil {
+			if _, err := os.Stat(path); err != nil {
+				return nil, "", false
+			}
+			return nil, path, true
+		}
+		path = linkTarget(path, target)
+	}
+	return nil, "", false
+}
+
+func linkTarget(link, target string) string {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(link), target)
+	}
+	return filepath.Clean(target)
+}
+
+// newTestError 提取 nginx -t 输出中的错误行
+func newTestError(output string, paths *strings.Replacer) *TestError {
+	all, errs := []string{}, []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(paths.Replace(line))
+		if line == "" {
+			continue
+		}
+		all = append(all, line)
+		for _, level := range []string{"[emerg]", "[alert]", "[crit]", "[error]"} {
+			if strings.Contains(line, level) {
+				errs = append(errs, line)
+				break
+			}
+		}
+	}
+	if len(errs) == 0 {
+		errs = all
+	}
+	return &TestError{Lines: errs}
+}
+
+// backup 文件修改前的状态
+type backup struct {
+	path    string
+	exists  bool
+	target  string // 符号链接目标
+	data    []byte
+	mode    os.FileMode
+	symlink bool
+}
+
+func snapshot(path string) (backup, error) {
+	b := backup{path: path}
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	b.exists = true
+	b.mode = info.Mode().Perm()
+	if info.Mode()&os.ModeSymlink != 0 {
+		b.symlink = true
+		b.target, err = os.Readlink(path)
+		return b, err
+	}
+	b.data, err = os.ReadFile(path)
+	return b, err
+}
+
+// apply 执行单个变更，文件和链接先写入临时文件再原子替换
+func apply(path string, c change) error {
+	switch c.kind {
+	case changeRemove:
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	case changeSymlink:
+		tmp := path + ".tx-tmp"
+		os.Remove(tmp)
+		if err := os.Symlink(c.target, tmp); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tx-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(c.data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// restore 恢复修改前的状态
+func restore(backups []backup) {
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		os.Remove(b.path)
+		switch {
+		case !b.exists:
+		case b.symlink:
+			os.Symlink(b.target, b.path)
+		default:
+			os.WriteFile(b.path, b.data, b.mode)
+		}
+	}
+}
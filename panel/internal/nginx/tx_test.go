@@ -0,0 +1,224 @@
+package nginx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// txEnv 模拟 /etc/nginx：主配置通过相对路径 include sites-enabled
+type txEnv struct {
+	available, enabled string
+	reloads            int
+	seen               []string // 最近一次测试读到的站点配置内容
+}
+
+func setupTx(t *testing.T) *txEnv {
+	t.Helper()
+	root := t.TempDir()
+	env := &txEnv{
+		available: filepath.Join(root, "sites-available"),
+		enabled:   filepath.Join(root, "sites-enabled"),
+	}
+	os.MkdirAll(env.available, 0755)
+	os.MkdirAll(env.enabled, 0755)
+	os.WriteFile(filepath.Join(root, "mime.types"), []byte("types {}\n"), 0644)
+	os.WriteFile(filepath.Join(root, "nginx.conf"), []byte("events {}\nhttp {\n    include mime.types;\n    include sites-enabled/*;\n}\n"), 0644)
+
+	oldMain, oldTest, oldReload := MainConfig, Test, Reload
+	t.Cleanup(func() { MainConfig, Test, Reload = oldMain, oldTest, oldReload })
+	MainConfig = filepath.Join(root, "nginx.conf")
+	Test = env.fakeTest
+	Reload = func() error {
+		env.reloads++
+		return nil
+	}
+	return env
+}
+
+// fakeTest 代替 nginx -t：展开 include 并读取站点配置，包含 bad_directive 时报错
+func (env *txEnv) fakeTest(conf string) error {
+	content, err := os.ReadFile(conf)
+	if err != nil {
+		return err
+	}
+	cfg, err := Parse(string(content))
+	if err != nil {
+		return err
+	}
+	env.seen = nil
+	for _, inc := range cfg.FindAll("include") {
+		pattern := inc.Arg(0)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(conf), pattern)
+		}
+		files, _ := filepath.Glob(pattern)
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return fmt.Errorf("nginx: [emerg] open() %q failed", f)
+			}
+			if !strings.Contains(f, "sites-enabled") && !strings.Contains(f, ".staged") {
+				continue
+			}
+			env.seen = append(env.seen, string(data))
+			if strings.Contains(string(data), "bad_directive") {
+				return fmt.Errorf("nginx: [emerg] unknown directive \"bad_directive\" in %s:2\nnginx: configuration file %s test failed", f, conf)
+			}
+		}
+	}
+	return nil
+}
+
+func (env *txEnv) site(domain string) (string, string) {
+	return filepath.Join(env.available, domain+".conf"), filepath.Join(env.enabled, domain+".conf")
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestTxCommit(t *testing.T) {
+	env := setupTx(t)
+	config, link := env.site("a.example.com")
+
+	tx := Begin()
+	tx.WriteFile(config, []byte("server { listen 80; }\n"))
+	tx.Symlink(config, link)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if got := readFile(t, link); got != "server { listen 80; }\n" {
+		t.Errorf("Unexpected config through link: %q", got)
+	}
+	if len(env.seen) != 1 || env.reloads != 1 {
+		t.Errorf("Expected staged config to be tested and nginx reloaded, seen %q reloads %d", env.seen, env.reloads)
+	}
+
+	// 修改与禁用
+	tx = Begin()
+	tx.WriteFile(config, []byte("server { listen 8080; }\n"))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.seen) != 1 || env.seen[0] != "server { listen 8080; }\n" {
+		t.Errorf("Expected linked config to be tested with staged content, got %q", env.seen)
+	}
+
+	tx = Begin()
+	tx.Remove(link)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.seen) != 0 {
+		t.Errorf("Removed link should not be tested, got %q", env.seen)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Error("Expected link to be removed")
+	}
+
+	if err := Begin().Commit(); err != nil || env.reloads != 3 {
+		t.Errorf("Empty commit should do nothing, got %v (reloads %d)", err, env.reloads)
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	env := setupTx(t)
+	config, link := env.site("a.example.com")
+	os.WriteFile(config, []byte("server { listen 80; }\n"), 0644)
+	os.Symlink(config, link)
+	other, _ := env.site("b.example.com")
+
+	tx := Begin()
+	tx.WriteFile(config, []byte("server {\n    bad_directive on;\n}\n"))
+	tx.WriteFile(other, []byte("server { listen 81; }\n"))
+	err := tx.Commit()
+
+	var testErr *TestError
+	if !errors.As(err, &testErr) {
+		t.Fatalf("Expected TestError, got %v", err)
+	}
+	// 错误行中的临时路径替换为实际路径，只保留错误级别的行
+	if len(testErr.Lines) != 1 || testErr.Lines[0] != `nginx: [emerg] unknown directive "bad_directive" in `+link+":2" {
+		t.Errorf("Unexpected error lines: %q", testErr.Lines)
+	}
+	if got := readFile(t, config); got != "server { listen 80; }\n" {
+		t.Errorf("Live config must not change, got %q", got)
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Error("No file should be written when the test fails")
+	}
+	if env.reloads != 0 {
+		t.Error("nginx must not be reloaded when the test fails")
+	}
+	entries, _ := os.ReadDir(env.available)
+	if len(entries) != 1 {
+		t.Errorf("Expected no leftover files, got %d entries", len(entries))
+	}
+}
+
+func TestTxRename(t *testing.T) {
+	env := setupTx(t)
+	config, link := env.site("a.example.com")
+	os.WriteFile(config+".disabled", []byte("server { listen 80; }\n"), 0644)
+
+	tx := Begin()
+	if err := tx.Rename(config+".disabled", config); err != nil {
+		t.Fatal(err)
+	}
+	tx.Symlink(config, link)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(config + ".disabled"); !os.IsNotExist(err) {
+		t.Error("Expected source to be removed")
+	}
+	if got := readFile(t, link); got != "server { listen 80; }\n" {
+		t.Errorf("Unexpected config %q", got)
+	}
+
+	if err := Begin().Rename(config+".missing", config); err == nil {
+		t.Error("Expected error for missing source")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	file, link, created := filepath.Join(dir, "a.conf"), filepath.Join(dir, "a.link"), filepath.Join(dir, "new.conf")
+	os.WriteFile(file, []byte("old"), 0600)
+	os.Symlink(file, link)
+
+	backups := []backup{}
+	for _, path := range []string{file, link, created} {
+		b, err := snapshot(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backups = append(backups, b)
+	}
+	apply(file, change{kind: changeWrite, data: []byte("new")})
+	apply(link, change{kind: changeRemove})
+	apply(created, change{kind: changeWrite, data: []byte("x")})
+	restore(backups)
+
+	if got := readFile(t, file); got != "old" {
+		t.Errorf("Expected file to be restored, got %q", got)
+	}
+	if info, _ := os.Stat(file); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode to be restored, got %v", info.Mode())
+	}
+	if target, _ := os.Readlink(link); target != file {
+		t.Error("Expected link to be restored")
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("Expected created file to be removed")
+	}
+}
@@ -0,0 +1,130 @@
+// Package php 发现服务器上安装的 PHP-FPM 版本。
+// 版本来自两处：/etc/php/<版本>/fpm 表示已安装 FPM，/run/php/php<版本>-fpm.sock 表示 FPM 正在运行，
+// 与 CLI 的 php_list 一致。站点配置中的 fastcgi_pass 固定指向 /run/php 下的 socket
+package php
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ConfigDir 各版本配置目录的上级目录
+	ConfigDir = "/etc/php"
+	// RunDir FPM socket 所在目录
+	RunDir = "/run/php"
+	// LogDir FPM 日志目录
+	LogDir = "/var/log"
+)
+
+var (
+	versionRe = regexp.MustCompile(`^\d+\.\d+$`)
+	socketRe  = regexp.MustCompile(`^php(\d+\.\d+)-fpm\.sock$`)
+)
+
+// Version 一个 PHP 版本的安装和运行状态
+type Version struct {
+	Version   string `json:"version"`
+	Installed bool   `json:"installed"` // 存在 /etc/php/<版本>/fpm
+	Running   bool   `json:"running"`   // FPM socket 存在
+	Socket    string `json:"socket"`
+	Service   string `json:"service"` // systemd 服务名，如 php8.3-fpm
+	LogFile   string `json:"log_file"`
+}
+
+// SocketPath 版本对应的 FPM socket，与站点模板中的 fastcgi_pass 一致
+func SocketPath(version string) string {
+	return "/run/php/php" + version + "-fpm.sock"
+}
+
+// ServiceName 版本对应的 systemd 服务名
+func ServiceName(version string) string {
+	return "php" + version + "-fpm"
+}
+
+func isSocket(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// Versions 列出已安装或正在运行的版本，新版本在前
+func Versions() []Version {
+	found := map[string]*Version{}
+	get := func(v string) *Version {
+		if found[v] == nil {
+			found[v] = &Version{
+				Version: v,
+				Socket:  SocketPath(v),
+				Service: ServiceName(v),
+				LogFile: filepath.Join(LogDir, ServiceName(v)+".log"),
+			}
+		}
+		return found[v]
+	}
+
+	if entries, err := os.ReadDir(ConfigDir); err == nil {
+		for _, e := range entries {
+			if !versionRe.MatchString(e.Name()) {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(ConfigDir, e.Name(), "fpm")); err == nil && info.IsDir() {
+				get(e.Name()).Installed = true
+			}
+		}
+	}
+	if entries, err := os.ReadDir(RunDir); err == nil {
+		for _, e := range entries {
+			if m := socketRe.FindStringSubmatch(e.Name()); m != nil && isSocket(filepath.Join(RunDir, e.Name())) {
+				get(m[1]).Running = true
+			}
+		}
+	}
+
+	list := make([]Version, 0, len(found))
+	for _, v := range found {
+		list = append(list, *v)
+	}
+	sort.Slice(list, func(i, j int) bool { return compare(list[i].Version, list[j].Version) > 0 })
+	return list
+}
+
+// Find 按版本号查找，未安装也未运行时返回 nil
+func Find(version string) *Version {
+	for _, v := range Versions() {
+		if v.Version == version {
+			return &v
+		}
+	}
+	return nil
+}
+
+// Default 新建站点默认使用的版本：正在运行的最新版本，其次是已安装的最新版本；都没有时返回空
+func Default() string {
+	list := Versions()
+	for _, v := range list {
+		if v.Running {
+			return v.Version
+		}
+	}
+	if len(list) > 0 {
+		return list[0].Version
+	}
+	return ""
+}
+
+// compare 按数值比较 8.10 和 8.9 这类版本号
+func compare(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, _ := strconv.Atoi(pa[i])
+		y, _ := strconv.Atoi(pb[i])
+		if x != y {
+			return x - y
+		}
+	}
+	return len(pa) - len(pb)
+}
@@ -0,0 +1,66 @@
+package php
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupDirs(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	oldConfig, oldRun := ConfigDir, RunDir
+	ConfigDir, RunDir = filepath.Join(root, "etc"), filepath.Join(root, "run")
+	t.Cleanup(func() { ConfigDir, RunDir = oldConfig, oldRun })
+	os.MkdirAll(ConfigDir, 0755)
+	os.MkdirAll(RunDir, 0755)
+}
+
+func listen(t *testing.T, path string) {
+	t.Helper()
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+}
+
+func TestVersions(t *testing.T) {
+	setupDirs(t)
+	if v := Default(); v != "" || len(Versions()) != 0 {
+		t.Fatalf("Expected no versions, got %q %+v", v, Versions())
+	}
+
+	// 8.1 已安装未运行，8.2 只有 CLI 没有 FPM，8.10 和 7.4 正在运行
+	os.MkdirAll(filepath.Join(ConfigDir, "8.1", "fpm"), 0755)
+	os.MkdirAll(filepath.Join(ConfigDir, "8.2", "cli"), 0755)
+	os.MkdirAll(filepath.Join(ConfigDir, "8.10", "fpm"), 0755)
+	os.MkdirAll(filepath.Join(ConfigDir, "mods-available"), 0755)
+	listen(t, filepath.Join(RunDir, "php8.10-fpm.sock"))
+	listen(t, filepath.Join(RunDir, "php7.4-fpm.sock"))
+	listen(t, filepath.Join(RunDir, "php-fpm.sock"))
+	os.WriteFile(filepath.Join(RunDir, "php8.2-fpm.sock"), nil, 0644)
+
+	list := Versions()
+	got := []string{}
+	for _, v := range list {
+		got = append(got, v.Version)
+	}
+	if len(got) != 3 || got[0] != "8.10" || got[1] != "8.1" || got[2] != "7.4" {
+		t.Fatalf("Unexpected versions: %v", got)
+	}
+	if !list[0].Installed || !list[0].Running || list[1].Running || list[2].Installed {
+		t.Errorf("Unexpected state: %+v", list)
+	}
+	if list[0].Socket != "/run/php/php8.10-fpm.sock" || list[0].Service != "php8.10-fpm" || list[0].LogFile != "/var/log/php8.10-fpm.log" {
+		t.Errorf("Unexpected paths: %+v", list[0])
+	}
+
+	if v := Default(); v != "8.10" {
+		t.Errorf("Expected newest running version as default, got %q", v)
+	}
+	if Find("8.1") == nil || Find("8.2") != nil {
+		t.Error("Expected Find to match discovered versions only")
+	}
+}
@@ -0,0 +1,332 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PM2 以 PM2 应用管理进程，定义保存在站点目录的 ecosystem.config.js 中，与 CLI 的 _create_pm2_site 相同
+type PM2 struct {
+	SitesDir string // 站点目录，ecosystem 文件位于 <SitesDir>/<name>/ecosystem.config.js
+	LogDir   string // 未指定日志路径时的日志目录
+	User     string // pm2 以该用户运行，与 CLI 的 run_pm2 一致
+}
+
+// NewPM2 使用默认目录创建 PM2 进程管理器
+func NewPM2() *PM2 {
+	return &PM2{SitesDir: "/www/wwwroot", LogDir: "/www/wwwlogs/pm2", User: "www"}
+}
+
+func (m *PM2) Name() string { return "pm2" }
+
+func (m *PM2) ecosystemPath(name string) string {
+	return filepath.Join(m.SitesDir, name, "ecosystem.config.js")
+}
+
+// pm2 以 User 身份执行 pm2，HOME 为该用户的主目录（不存在时为 /www）
+func (m *PM2) pm2(args ...string) (string, error) {
+	name, cmdArgs := "pm2", args
+	if m.User != "" && os.Geteuid() == 0 {
+		home := "/www"
+		if u, err := user.Lookup(m.User); err == nil && u.HomeDir != "" {
+			if _, err := os.Stat(u.HomeDir); err == nil {
+				home = u.HomeDir
+			}
+		}
+		name, cmdArgs = "sudo", append([]string{"-u", m.User, "HOME=" + home, "pm2"}, args...)
+	}
+	out, err := run(name, cmdArgs...)
+	if err != nil {
+		out = strings.TrimSpace(out)
+		if out == "" {
+			out = err.Error()
+		}
+		return out, fmt.Errorf("pm2 %s: %s", args[0], out)
+	}
+	return out, nil
+}
+
+// ecosystemApp ecosystem.config.js 中的应用定义
+type ecosystemApp struct {
+	Name             string            `json:"name"`
+	Script           string            `json:"script"`
+	Args             string            `json:"args,omitempty"`
+	Cwd              string            `json:"cwd"`
+	Instances        int               `json:"instances"`
+	Autorestart      bool              `json:"autorestart"`
+	Watch            bool              `json:"watch"`
+	MaxMemoryRestart string            `json:"max_memory_restart"`
+	Env              map[string]string `json:"env"`
+	OutFile          string            `json:"out_file"`
+	ErrorFile        string            `json:"error_file"`
+}
+
+const ecosystemPrefix = "module.exports = "
+
+// Save 写入 ecosystem 文件；应用已注册时以新环境变量重启，否则启动，然后保存 PM2 进程列表
+func (m *PM2) Save(p Program) error {
+	if err := CheckProgram(p); err != nil {
+		return err
+	}
+	if p.StdoutLog == "" {
+		p.StdoutLog = filepath.Join(m.LogDir, p.Name+".out.log")
+	}
+	if p.StderrLog == "" {
+		p.StderrLog = filepath.Join(m.LogDir, p.Name+".err.log")
+	}
+	os.MkdirAll(filepath.Dir(p.StdoutLog), 0755)
+
+	fields := strings.Fields(p.Command)
+	if len(fields) == 0 {
+		return fmt.Errorf("启动脚本不能为空")
+	}
+	app := ecosystemApp{
+		Name:             p.Name,
+		Script:           fields[0],
+		Args:             strings.Join(fields[1:], " "),
+		Cwd:              p.Directory,
+		Instances:        1,
+		Autorestart:      true,
+		MaxMemoryRestart: "500M",
+		Env:              p.Env,
+		OutFile:          p.StdoutLog,
+		ErrorFile:        p.StderrLog,
+	}
+	if app.Env == nil {
+		app.Env = map[string]string{}
+	}
+	data, err := json.MarshalIndent(map[string][]ecosystemApp{"apps": {app}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := m.ecosystemPath(p.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(ecosystemPrefix+string(data)+";\n"), 0644); err != nil {
+		return err
+	}
+
+	if _, err := m.find(p.Name); err == nil {
+		return m.Restart(p.Name)
+	}
+	return m.Start(p.Name)
+}
+
+var (
+	ecosystemFieldRe = regexp.MustCompile(`(?m)^\s*(name|script|args|cwd|out_file|error_file)\s*:\s*['"]([^'"]*)['"]`)
+	ecosystemEnvRe   = regexp.MustCompile(`(?s)\benv\s*:\s*\{([^}]*)\}`)
+	ecosystemPairRe  = regexp.MustCompile(`(\w+)\s*:\s*(?:'([^']*)'|"([^"]*)"|([^,\s}]+))`)
+)
+
+// Load 读取 ecosystem 文件；面板生成的文件是 JSON，CLI 生成的 JS 对象字面量按字段提取
+func (m *PM2) Load(name string) (*Program, error) {
+	data, err := os.ReadFile(m.ecosystemPath(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var app ecosystemApp
+	var eco struct {
+		Apps []ecosystemApp `json:"apps"`
+	}
+	body := strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(data)), ecosystemPrefix)), ";")
+	if json.Unmarshal([]byte(body), &eco) == nil && len(eco.Apps) > 0 {
+		app = eco.Apps[0]
+	} else {
+		app.Env = map[string]string{}
+		for _, f := range ecosystemFieldRe.FindAllStringSubmatch(string(data), -1) {
+			switch f[1] {
+			case "name":
+				app.Name = f[2]
+			case "script":
+				app.Script = f[2]
+			case "args":
+				app.Args = f[2]
+			case "cwd":
+				app.Cwd = f[2]
+			case "out_file":
+				app.OutFile = f[2]
+			case "error_file":
+				app.ErrorFile = f[2]
+			}
+		}
+		if env := ecosystemEnvRe.FindStringSubmatch(string(data)); env != nil {
+			for _, pair := range ecosystemPairRe.FindAllStringSubmatch(env[1], -1) {
+				app.Env[pair[1]] = pair[2] + pair[3] + pair[4]
+			}
+		}
+	}
+
+	p := &Program{
+		Name:      name,
+		Command:   strings.TrimSpace(app.Script + " " + app.Args),
+		Directory: app.Cwd,
+		User:      m.User,
+		Env:       app.Env,
+		StdoutLog: m.logFile(app.OutFile, name+".out.log"),
+		StderrLog: m.logFile(app.ErrorFile, name+".err.log"),
+	}
+	if p.Env == nil {
+		p.Env = map[string]string{}
+	}
+	return p, nil
+}
+
+// logFile ecosystem 文件在运行用户可写的站点目录中，其中的日志路径不可信：面板以 root 读取日志，
+// 只接受解析符号链接后仍位于 LogDir 之下的路径，未指定或位于其他位置时使用 LogDir 下的默认文件
+func (m *PM2) logFile(path, base string) string {
+	if path != "" && filepath.IsAbs(path) {
+		root := resolvePath(m.LogDir)
+		if rel, err := filepath.Rel(root, resolvePath(path)); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return filepath.Clean(path)
+		}
+	}
+	return filepath.Join(m.LogDir, base)
+}
+
+// resolvePath 解析路径中的符号链接，末尾不存在的部分原样保留
+func resolvePath(path string) string {
+	rest := ""
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if dir == filepath.Dir(dir) {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// pm2Process pm2 jlist 中的一项
+type pm2Process struct {
+	Name string `json:"name"`
+	PID  int    `json:"pid"`
+	Env  struct {
+		Status string `json:"status"`
+		Uptime int64  `json:"pm_uptime"` // 启动时间，毫秒时间戳
+	} `json:"pm2_env"`
+}
+
+// find 在 pm2 jlist 中查找应用，未注册时返回 ErrNotFound
+func (m *PM2) find(name string) (*pm2Process, error) {
+	out, err := m.pm2("jlist")
+	if err != nil {
+		return nil, err
+	}
+	// jlist 之前可能有 PM2 的提示信息
+	if i := strings.Index(out, "["); i > 0 {
+		out = out[i:]
+	}
+	var list []pm2Process
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, fmt.Errorf("无法解析 pm2 jlist: %w", err)
+	}
+	for i := range list {
+		if list[i].Name == name {
+			return &list[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Remove 从 PM2 删除应用并删除 ecosystem 文件
+func (m *PM2) Remove(name string) error {
+	path := m.ecosystemPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if _, err := m.find(name); err == nil {
+		if _, err := m.pm2("delete", name); err != nil {
+			return err
+		}
+		if _, err := m.pm2("save"); err != nil {
+			return err
+		}
+	}
+	return os.Remove(path)
+}
+
+// Start 从 ecosystem 文件启动，应用未注册时会先注册
+func (m *PM2) Start(name string) error {
+	path := m.ecosystemPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if _, err := m.pm2("start", path, "--only", name); err != nil {
+		return err
+	}
+	_, err := m.pm2("save")
+	return err
+}
+
+// Stop 停止应用；未注册到 PM2 的应用本来就没有运行
+func (m *PM2) Stop(name string) error {
+	if _, err := m.find(name); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			if _, statErr := os.Stat(m.ecosystemPath(name)); statErr == nil {
+				return nil
+			}
+		}
+		return err
+	}
+	if _, err := m.pm2("stop", name); err != nil {
+		return err
+	}
+	_, err := m.pm2("save")
+	return err
+}
+
+// Restart 按 ecosystem 文件重启，使修改的环境变量生效
+func (m *PM2) Restart(name string) error {
+	path := m.ecosystemPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if _, err := m.pm2("restart", path, "--only", name, "--update-env"); err != nil {
+		return err
+	}
+	_, err := m.pm2("save")
+	return err
+}
+
+// Status 应用状态；有 ecosystem 文件但未注册到 PM2 时为 stopped
+func (m *PM2) Status(name string) (*Status, error) {
+	info, err := m.find(name)
+	if errors.Is(err, ErrNotFound) {
+		if _, statErr := os.Stat(m.ecosystemPath(name)); statErr == nil {
+			return &Status{Name: name, Backend: m.Name(), State: StateStopped}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Status{Name: name, Backend: m.Name(), State: StateUnknown, Detail: info.Env.Status}
+	switch info.Env.Status {
+	case "online":
+		st.State = StateRunning
+		st.PID = info.PID
+		if info.Env.Uptime > 0 {
+			st.Uptime = int64(time.Since(time.UnixMilli(info.Env.Uptime)).Seconds())
+		}
+	case "stopped", "stopping":
+		st.State = StateStopped
+	case "launching":
+		st.State = StateStarting
+	case "errored":
+		st.State = StateFailed
+	}
+	return st, nil
+}
@@ -0,0 +1,223 @@
+// Package process 管理站点的应用进程：Node.js / Python 站点使用 supervisor 程序，PM2 站点使用 PM2 应用。
+// 两种方式实现同一个 Backend 接口，进程名与站点域名相同。
+package process
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// 进程状态
+const (
+	StateRunning  = "running"
+	StateStopped  = "stopped"
+	StateStarting = "starting"
+	StateFailed   = "failed" // 启动失败或反复退出
+	StateUnknown  = "unknown"
+)
+
+// 日志流
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// ErrNotFound 进程不存在
+var ErrNotFound = errors.New("进程不存在")
+
+// run 执行命令并返回合并的输出；测试中替换
+var run = func(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	return string(out), err
+}
+
+// Program 进程定义
+type Program struct {
+	Name      string            `json:"name"`
+	Command   string            `json:"command"` // 启动命令；PM2 中第一个字段为脚本，其余为参数
+	Directory string            `json:"directory"`
+	User      string            `json:"user"`
+	Env       map[string]string `json:"env"`
+	StdoutLog string            `json:"stdout_log"`
+	StderrLog string            `json:"stderr_log"`
+}
+
+// Status 进程运行状态
+type Status struct {
+	Name    string `json:"name"`
+	Backend string `json:"backend"`
+	State   string `json:"state"`
+	PID     int    `json:"pid"`
+	Uptime  int64  `json:"uptime"`           // 秒
+	Detail  string `json:"detail,omitempty"` // 进程管理器返回的原始状态
+}
+
+// Backend 进程管理器
+type Backend interface {
+	Name() string
+	// Save 创建或更新进程定义并使其以新定义运行
+	Save(p Program) error
+	// Load 读取进程定义，不存在时返回 ErrNotFound
+	Load(name string) (*Program, error)
+	// Remove 停止进程并删除定义
+	Remove(name string) error
+	Start(name string) error
+	Stop(name string) error
+	Restart(name string) error
+	Status(name string) (*Status, error)
+}
+
+// Manager 按名称选择进程管理器
+type Manager struct {
+	backends map[string]Backend
+}
+
+// NewManager 创建进程管理器
+func NewManager(backends ...Backend) *Manager {
+	m := &Manager{backends: map[string]Backend{}}
+	for _, b := range backends {
+		m.backends[b.Name()] = b
+	}
+	return m
+}
+
+// Backend 获取指定名称的进程管理器
+func (m *Manager) Backend(name string) (Backend, error) {
+	b, ok := m.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("不支持的进程管理方式: %s", name)
+	}
+	return b, nil
+}
+
+var envKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CheckEnv 校验环境变量名和值，值中不能有换行
+func CheckEnv(env map[string]string) error {
+	for k, v := range env {
+		if !envKeyRe.MatchString(k) {
+			return fmt.Errorf("无效的环境变量名: %s", k)
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("环境变量 %s 的值不能包含换行", k)
+		}
+	}
+	return nil
+}
+
+// CheckProgram 校验进程定义。supervisor 配置每项占一行，值中的换行可以注入 user=root 或新的程序段
+func CheckProgram(p Program) error {
+	for _, field := range []struct{ name, value string }{
+		{"启动命令", p.Command},
+		{"工作目录", p.Directory},
+		{"运行用户", p.User},
+		{"日志路径", p.StdoutLog},
+		{"日志路径", p.StderrLog},
+	} {
+		if strings.ContainsAny(field.value, "\r\n") {
+			return fmt.Errorf("%s不能包含换行", field.name)
+		}
+	}
+	return CheckEnv(p.Env)
+}
+
+// SetEnv 替换进程的环境变量，运行中的进程会重启
+func (m *Manager) SetEnv(backend, name string, env map[string]string) error {
+	if err := CheckEnv(env); err != nil {
+		return err
+	}
+	b, err := m.Backend(backend)
+	if err != nil {
+		return err
+	}
+	p, err := b.Load(name)
+	if err != nil {
+		return err
+	}
+	p.Env = env
+	return b.Save(*p)
+}
+
+// Tail 读取进程 stdout 或 stderr 日志的最后 lines 行；日志文件尚未创建时返回空
+func (m *Manager) Tail(backend, name, stream string, lines int) ([]string, error) {
+	b, err := m.Backend(backend)
+	if err != nil {
+		return nil, err
+	}
+	p, err := b.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	path := p.StdoutLog
+	if stream == Stderr {
+		path = p.StderrLog
+	}
+	if path == "" {
+		return []string{}, nil
+	}
+	result, err := tailFile(path, lines)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	return result, err
+}
+
+// tailFile 从文件末尾向前按块读取，直到读到 n 行。日志目录可能被运行用户写入，
+// 不跟随末尾的符号链接，也不读取普通文件以外的文件
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s 不是普通文件", path)
+	}
+
+	const chunk = 16 * 1024
+	var data []byte
+	offset := info.Size()
+	for offset > 0 && bytes.Count(data, []byte("\n")) <= n {
+		size := int64(chunk)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		buf := make([]byte, size)
+		if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(buf, data...)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}, nil
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// sortedKeys 环境变量按名称排序，生成的配置文件内容稳定
+func sortedKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
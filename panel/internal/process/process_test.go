@@ -0,0 +1,280 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRun 替换 run，记录执行的命令；reply 根据命令返回输出
+func fakeRun(t *testing.T, reply func(cmd string) (string, error)) *[]string {
+	t.Helper()
+	calls := []string{}
+	old := run
+	run = func(name string, args ...string) (string, error) {
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		calls = append(calls, cmd)
+		return reply(cmd)
+	}
+	t.Cleanup(func() { run = old })
+	return &calls
+}
+
+func TestSupervisor(t *testing.T) {
+	root := t.TempDir()
+	s := &Supervisor{ConfDir: filepath.Join(root, "conf.d"), LogDir: filepath.Join(root, "logs"), User: "www"}
+	status := "app.example.com                  STOPPED   Not started"
+	calls := fakeRun(t, func(cmd string) (string, error) {
+		switch {
+		case strings.HasPrefix(cmd, "supervisorctl status"):
+			return status, errors.New("exit status 3")
+		case cmd == "supervisorctl stop missing.example.com":
+			return "missing.example.com: ERROR (no such process)", errors.New("exit status 1")
+		case cmd == "supervisorctl stop app.example.com" && !strings.Contains(status, "RUNNING"):
+			return "app.example.com: ERROR (not running)", errors.New("exit status 1")
+		}
+		return "", nil
+	})
+
+	p := Program{
+		Name:      "app.example.com",
+		Command:   "node server.js --started=%s",
+		Directory: "/www/wwwroot/app.example.com",
+		Env:       map[string]string{"PORT": "3000", "NODE_ENV": "production", "GREETING": `say "hi" 100%`},
+	}
+	if err := s.Save(p); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	want := "supervisorctl reread,supervisorctl update app.example.com,supervisorctl start app.example.com"
+	if got := strings.Join(*calls, ","); got != want {
+		t.Errorf("Unexpected commands: %s", got)
+	}
+	data, _ := os.ReadFile(filepath.Join(s.ConfDir, "app.example.com.conf"))
+	for _, line := range []string{
+		"[program:app.example.com]",
+		"command=node server.js --started=%%s",
+		"user=www",
+		"stdout_logfile=" + filepath.Join(s.LogDir, "app.example.com.out.log"),
+		`environment=GREETING="say \"hi\" 100%%",NODE_ENV="production",PORT="3000"`,
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Expected %q in config:\n%s", line, data)
+		}
+	}
+
+	loaded, err := s.Load("app.example.com")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Command != p.Command || loaded.Directory != p.Directory || fmt.Sprint(loaded.Env) != fmt.Sprint(p.Env) {
+		t.Errorf("Expected program to round-trip, got %+v", loaded)
+	}
+	if _, err := s.Load("missing.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// 已停止的进程再次停止不算错误；不存在的进程返回 ErrNotFound
+	if err := s.Stop("app.example.com"); err != nil {
+		t.Errorf("Expected stopping a stopped program to succeed, got %v", err)
+	}
+	if err := s.Stop("missing.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	st, err := s.Status("app.example.com")
+	if err != nil || st.State != StateStopped || st.PID != 0 {
+		t.Errorf("Unexpected status: %+v %v", st, err)
+	}
+	status = "app.example.com                  RUNNING   pid 4242, uptime 1 day, 2:03:04"
+	st, _ = s.Status("app.example.com")
+	if st.State != StateRunning || st.PID != 4242 || st.Uptime != 86400+2*3600+3*60+4 {
+		t.Errorf("Unexpected status: %+v", st)
+	}
+
+	*calls = nil
+	if err := s.Remove("app.example.com"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.ConfDir, "app.example.com.conf")); !os.IsNotExist(err) {
+		t.Error("Expected config to be removed")
+	}
+	if got := strings.Join(*calls, ","); got != "supervisorctl stop app.example.com,supervisorctl reread,supervisorctl update app.example.com" {
+		t.Errorf("Unexpected commands: %s", got)
+	}
+}
+
+func TestSupervisorRejectsNewlines(t *testing.T) {
+	root := t.TempDir()
+	s := &Supervisor{ConfDir: root, LogDir: root, User: "www"}
+	calls := fakeRun(t, func(string) (string, error) { return "", nil })
+
+	base := Program{Name: "app.example.com", Command: "node server.js", Directory: "/www/wwwroot/app.example.com"}
+	for name, edit := range map[string]func(p *Program){
+		"command":   func(p *Program) { p.Command = "node server.js\nuser=root" },
+		"directory": func(p *Program) { p.Directory = "/www/wwwroot/app.example.com\r\n[program:x]" },
+		"env value": func(p *Program) { p.Env = map[string]string{"PORT": "3000\n[program:x]\ncommand=sh"} },
+	} {
+		p := base
+		edit(&p)
+		if err := s.Save(p); err == nil || !strings.Contains(err.Error(), "换行") {
+			t.Errorf("%s: expected newline to be rejected, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "app.example.com.conf")); !os.IsNotExist(err) {
+		t.Error("Expected no config to be written")
+	}
+	if len(*calls) != 0 {
+		t.Errorf("Expected no supervisorctl calls, got %v", *calls)
+	}
+}
+
+func TestPM2(t *testing.T) {
+	root := t.TempDir()
+	m := &PM2{SitesDir: root, LogDir: filepath.Join(root, "logs")}
+	started := time.Now().Add(-90 * time.Second).UnixMilli()
+	jlist := "[]"
+	calls := fakeRun(t, func(cmd string) (string, error) {
+		if cmd == "pm2 jlist" {
+			return jlist, nil
+		}
+		return "", nil
+	})
+
+	// CLI 生成的 ecosystem 文件
+	writeFile(t, filepath.Join(root, "cli.example.com", "ecosystem.config.js"), `module.exports = {
+  apps: [{
+    name: 'cli.example.com',
+    script: 'server.js',
+    cwd: '/www/wwwroot/cli.example.com',
+    instances: 1,
+    env: {
+      NODE_ENV: 'production',
+      PORT: 3001
+    }
+  }]
+};
+`)
+	p, err := m.Load("cli.example.com")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if p.Command != "server.js" || p.Directory != "/www/wwwroot/cli.example.com" || p.Env["PORT"] != "3001" || p.Env["NODE_ENV"] != "production" {
+		t.Errorf("Unexpected program from CLI ecosystem: %+v", p)
+	}
+	st, err := m.Status("cli.example.com")
+	if err != nil || st.State != StateStopped {
+		t.Errorf("Expected unregistered app to be stopped, got %+v %v", st, err)
+	}
+
+	// 未注册的应用保存后启动，已注册的以新环境变量重启
+	p.Env["PORT"] = "3002"
+	p.Command = "npm run start"
+	*calls = nil
+	if err := m.Save(*p); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	eco := filepath.Join(root, "cli.example.com", "ecosystem.config.js")
+	if got := strings.Join(*calls, ","); got != "pm2 jlist,pm2 start "+eco+" --only cli.example.com,pm2 save" {
+		t.Errorf("Unexpected commands: %s", got)
+	}
+	p, _ = m.Load("cli.example.com")
+	if p.Command != "npm run start" || p.Env["PORT"] != "3002" || p.StdoutLog != filepath.Join(m.LogDir, "cli.example.com.out.log") {
+		t.Errorf("Expected program to round-trip, got %+v", p)
+	}
+
+	jlist = fmt.Sprintf(`[{"name":"cli.example.com","pid":777,"pm2_env":{"status":"online","pm_uptime":%d}}]`, started)
+	*calls = nil
+	if err := m.Save(*p); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if got := strings.Join(*calls, ","); got != "pm2 jlist,pm2 restart "+eco+" --only cli.example.com --update-env,pm2 save" {
+		t.Errorf("Unexpected commands: %s", got)
+	}
+	st, _ = m.Status("cli.example.com")
+	if st.State != StateRunning || st.PID != 777 || st.Uptime < 90 {
+		t.Errorf("Unexpected status: %+v", st)
+	}
+
+	*calls = nil
+	if err := m.Remove("cli.example.com"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if got := strings.Join(*calls, ","); got != "pm2 jlist,pm2 delete cli.example.com,pm2 save" {
+		t.Errorf("Unexpected commands: %s", got)
+	}
+	if _, err := m.Status("missing.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// ecosystem 文件可被运行用户修改，指向日志目录之外的路径不会被面板读取
+	secret := filepath.Join(root, "secret")
+	writeFile(t, secret, "password\n")
+	os.MkdirAll(m.LogDir, 0755)
+	os.Symlink(root, filepath.Join(m.LogDir, "escape"))
+	writeFile(t, eco, fmt.Sprintf(`module.exports = {
+  apps: [{
+    name: 'cli.example.com',
+    script: 'server.js',
+    out_file: '%s',
+    error_file: '%s'
+  }]
+};
+`, secret, filepath.Join(m.LogDir, "escape", "secret")))
+	p, _ = m.Load("cli.example.com")
+	if p.StdoutLog != filepath.Join(m.LogDir, "cli.example.com.out.log") || p.StderrLog != filepath.Join(m.LogDir, "cli.example.com.err.log") {
+		t.Errorf("Expected log paths outside the log directory to be replaced, got %+v", p)
+	}
+	os.Symlink(secret, p.StdoutLog)
+	if lines, err := NewManager(m).Tail("pm2", "cli.example.com", Stdout, 10); err == nil {
+		t.Errorf("Expected symlinked log to be refused, got %v", lines)
+	}
+}
+
+func TestManager(t *testing.T) {
+	root := t.TempDir()
+	s := &Supervisor{ConfDir: root, LogDir: root, User: "www"}
+	fakeRun(t, func(string) (string, error) { return "", nil })
+	m := NewManager(s)
+
+	if err := s.Save(Program{Name: "app.example.com", Command: "python3 app.py", Env: map[string]string{"PORT": "8000"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetEnv("supervisor", "app.example.com", map[string]string{"BAD-NAME": "x"}); err == nil {
+		t.Error("Expected invalid env name to be rejected")
+	}
+	if err := m.SetEnv("pm2", "app.example.com", nil); err == nil {
+		t.Error("Expected unknown backend to be rejected")
+	}
+	if err := m.SetEnv("supervisor", "app.example.com", map[string]string{"PORT": "8001", "DEBUG": "1"}); err != nil {
+		t.Fatalf("SetEnv failed: %v", err)
+	}
+	if p, _ := s.Load("app.example.com"); p.Env["PORT"] != "8001" || p.Env["DEBUG"] != "1" || p.Command != "python3 app.py" {
+		t.Errorf("Expected env to be replaced, got %+v", p)
+	}
+
+	// 日志尚未创建时为空
+	if lines, err := m.Tail("supervisor", "app.example.com", Stdout, 10); err != nil || len(lines) != 0 {
+		t.Errorf("Expected empty log, got %v %v", lines, err)
+	}
+	var b strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	writeFile(t, filepath.Join(root, "app.example.com.err.log"), b.String())
+	lines, err := m.Tail("supervisor", "app.example.com", Stderr, 3)
+	if err != nil || strings.Join(lines, ",") != "line 4998,line 4999,line 5000" {
+		t.Errorf("Unexpected tail: %v %v", lines, err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
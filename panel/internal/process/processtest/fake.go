@@ -0,0 +1,144 @@
+// Package processtest 提供内存中的进程管理器，用于测试依赖 process.Backend 的代码。
+package processtest
+
+import (
+	"sync"
+
+	"site_manager_panel/internal/process"
+)
+
+// Fake 模拟 supervisor / PM2：保存进程定义和运行状态，记录每次调用
+type Fake struct {
+	BackendName string
+	// Fail 非空时，对应操作（save、start、stop、restart、remove）返回该错误
+	Fail map[string]error
+
+	mu       sync.Mutex
+	programs map[string]process.Program
+	running  map[string]bool
+	pids     map[string]int
+	nextPID  int
+	calls    []string
+}
+
+// New 创建名为 name 的进程管理器
+func New(name string) *Fake {
+	return &Fake{
+		BackendName: name,
+		Fail:        map[string]error{},
+		programs:    map[string]process.Program{},
+		running:     map[string]bool{},
+		pids:        map[string]int{},
+		nextPID:     1000,
+	}
+}
+
+func (f *Fake) Name() string { return f.BackendName }
+
+// Calls 按顺序返回调用记录，如 "save app.example.com"
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+// Running 进程是否在运行
+func (f *Fake) Running(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running[name]
+}
+
+func (f *Fake) call(op, name string) error {
+	f.calls = append(f.calls, op+" "+name)
+	return f.Fail[op]
+}
+
+func (f *Fake) start(name string) {
+	if !f.running[name] {
+		f.nextPID++
+		f.pids[name] = f.nextPID
+	}
+	f.running[name] = true
+}
+
+// Save 保存定义并以新定义（重新）启动
+func (f *Fake) Save(p process.Program) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("save", p.Name); err != nil {
+		return err
+	}
+	if p.Env == nil {
+		p.Env = map[string]string{}
+	}
+	f.programs[p.Name] = p
+	f.running[p.Name] = false
+	f.start(p.Name)
+	return nil
+}
+
+func (f *Fake) Load(name string) (*process.Program, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.programs[name]
+	if !ok {
+		return nil, process.ErrNotFound
+	}
+	env := map[string]string{}
+	for k, v := range p.Env {
+		env[k] = v
+	}
+	p.Env = env
+	return &p, nil
+}
+
+func (f *Fake) Remove(name string) error {
+	return f.op("remove", name, func() {
+		delete(f.programs, name)
+		delete(f.running, name)
+	})
+}
+
+func (f *Fake) Start(name string) error {
+	return f.op("start", name, func() { f.start(name) })
+}
+
+func (f *Fake) Stop(name string) error {
+	return f.op("stop", name, func() { f.running[name] = false })
+}
+
+func (f *Fake) Restart(name string) error {
+	return f.op("restart", name, func() {
+		f.running[name] = false
+		f.start(name)
+	})
+}
+
+func (f *Fake) op(op, name string, apply func()) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(op, name); err != nil {
+		return err
+	}
+	if _, ok := f.programs[name]; !ok {
+		return process.ErrNotFound
+	}
+	apply()
+	return nil
+}
+
+func (f *Fake) Status(name string) (*process.Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.programs[name]; !ok {
+		return nil, process.ErrNotFound
+	}
+	st := &process.Status{Name: name, Backend: f.BackendName, State: process.StateStopped, Detail: "STOPPED"}
+	if f.running[name] {
+		st.State, st.PID, st.Detail = process.StateRunning, f.pids[name], "RUNNING"
+	}
+	return st, nil
+}
+
+var _ process.Backend = (*Fake)(nil)
@@ -0,0 +1,265 @@
+package process
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Supervisor 以 supervisor 程序管理进程，配置文件格式与 CLI 的 _create_node_site 相同
+type Supervisor struct {
+	ConfDir string // 程序配置目录，每个进程一个 <name>.conf
+	LogDir  string // 未指定日志路径时的日志目录
+	User    string // 未指定用户时的运行用户
+}
+
+// NewSupervisor 使用默认目录创建 supervisor 进程管理器
+func NewSupervisor() *Supervisor {
+	return &Supervisor{ConfDir: "/etc/supervisor/conf.d", LogDir: "/www/wwwlogs/supervisor", User: "www"}
+}
+
+func (s *Supervisor) Name() string { return "supervisor" }
+
+func (s *Supervisor) confPath(name string) string {
+	return filepath.Join(s.ConfDir, name+".conf")
+}
+
+// ctl 执行 supervisorctl；supervisorctl 的退出码不可靠，以输出中的 ERROR 判断失败
+func (s *Supervisor) ctl(args ...string) (string, error) {
+	out, err := run("supervisorctl", args...)
+	out = strings.TrimSpace(out)
+	switch {
+	case strings.Contains(out, "no such process") || strings.Contains(out, "no such group"):
+		return out, ErrNotFound
+	case strings.Contains(out, "ERROR"):
+		return out, fmt.Errorf("supervisorctl %s: %s", args[0], out)
+	case err != nil && args[0] != "status":
+		if out == "" {
+			out = err.Error()
+		}
+		return out, fmt.Errorf("supervisorctl %s: %s", args[0], out)
+	}
+	return out, nil
+}
+
+// Save 写入程序配置并通过 reread / update 生效（配置变化的程序会被重启），然后确保程序在运行
+func (s *Supervisor) Save(p Program) error {
+	if err := CheckProgram(p); err != nil {
+		return err
+	}
+	if p.User == "" {
+		p.User = s.User
+	}
+	if p.StdoutLog == "" {
+		p.StdoutLog = filepath.Join(s.LogDir, p.Name+".out.log")
+	}
+	if p.StderrLog == "" {
+		p.StderrLog = filepath.Join(s.LogDir, p.Name+".err.log")
+	}
+	os.MkdirAll(filepath.Dir(p.StdoutLog), 0755)
+	if err := os.MkdirAll(s.ConfDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.confPath(p.Name), []byte(renderSupervisor(p)), 0644); err != nil {
+		return err
+	}
+	if _, err := s.ctl("reread"); err != nil {
+		return err
+	}
+	if _, err := s.ctl("update", p.Name); err != nil {
+		return err
+	}
+	return s.Start(p.Name)
+}
+
+// renderSupervisor 生成程序配置；supervisor 对配置值做 %(name)s 展开，% 需要转义
+func renderSupervisor(p Program) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[program:%s]\n", p.Name)
+	fmt.Fprintf(&b, "directory=%s\n", escapePercent(p.Directory))
+	fmt.Fprintf(&b, "command=%s\n", escapePercent(p.Command))
+	fmt.Fprintf(&b, "user=%s\n", p.User)
+	b.WriteString("autostart=true\nautorestart=true\n")
+	fmt.Fprintf(&b, "stderr_logfile=%s\n", p.StderrLog)
+	fmt.Fprintf(&b, "stdout_logfile=%s\n", p.StdoutLog)
+	if len(p.Env) > 0 {
+		pairs := []string{}
+		for _, k := range sortedKeys(p.Env) {
+			v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(p.Env[k])
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, v))
+		}
+		fmt.Fprintf(&b, "environment=%s\n", strings.Join(pairs, ","))
+	}
+	return b.String()
+}
+
+// Load 解析程序配置
+func (s *Supervisor) Load(name string) (*Program, error) {
+	f, err := os.Open(s.confPath(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &Program{Name: name, Env: map[string]string{}}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "program:"+name {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "command":
+			p.Command = unescapePercent(value)
+		case "directory":
+			p.Directory = unescapePercent(value)
+		case "user":
+			p.User = value
+		case "stdout_logfile":
+			p.StdoutLog = value
+		case "stderr_logfile":
+			p.StderrLog = value
+		case "environment":
+			p.Env = parseEnvironment(value)
+		}
+	}
+	return p, scanner.Err()
+}
+
+func escapePercent(s string) string   { return strings.ReplaceAll(s, "%", "%%") }
+func unescapePercent(s string) string { return strings.ReplaceAll(s, "%%", "%") }
+
+// parseEnvironment 解析 KEY="value",KEY2=value2 形式的 environment
+func parseEnvironment(s string) map[string]string {
+	env := map[string]string{}
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var value strings.Builder
+		i := 0
+		if strings.HasPrefix(rest, `"`) {
+			for i = 1; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			i++ // 结束引号
+		} else {
+			for ; i < len(rest) && rest[i] != ','; i++ {
+				value.WriteByte(rest[i])
+			}
+		}
+		env[strings.TrimSpace(key)] = unescapePercent(value.String())
+		s = strings.TrimLeft(rest[min(i, len(rest)):], ", ")
+	}
+	return env
+}
+
+// Remove 停止程序、删除配置并从 supervisor 中移除
+func (s *Supervisor) Remove(name string) error {
+	if _, err := os.Stat(s.confPath(name)); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if _, err := s.ctl("stop", name); err != nil && !errors.Is(err, ErrNotFound) && !strings.Contains(err.Error(), "not running") {
+		return err
+	}
+	if err := os.Remove(s.confPath(name)); err != nil {
+		return err
+	}
+	if _, err := s.ctl("reread"); err != nil {
+		return err
+	}
+	_, err := s.ctl("update", name)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *Supervisor) Start(name string) error {
+	_, err := s.ctl("start", name)
+	if err != nil && strings.Contains(err.Error(), "already started") {
+		return nil
+	}
+	return err
+}
+
+func (s *Supervisor) Stop(name string) error {
+	_, err := s.ctl("stop", name)
+	if err != nil && strings.Contains(err.Error(), "not running") {
+		return nil
+	}
+	return err
+}
+
+func (s *Supervisor) Restart(name string) error {
+	_, err := s.ctl("restart", name)
+	return err
+}
+
+var (
+	supervisorPIDRe    = regexp.MustCompile(`pid (\d+)`)
+	supervisorUptimeRe = regexp.MustCompile(`uptime (?:(\d+) days?, )?(\d+):(\d+):(\d+)`)
+)
+
+// Status 解析 supervisorctl status 的输出，如 "app RUNNING pid 123, uptime 1 day, 2:03:04"
+func (s *Supervisor) Status(name string) (*Status, error) {
+	out, err := s.ctl("status", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseSupervisorStatus(name, out), nil
+}
+
+func parseSupervisorStatus(name, out string) *Status {
+	st := &Status{Name: name, Backend: "supervisor", State: StateUnknown}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != name {
+			continue
+		}
+		st.Detail = fields[1]
+		switch fields[1] {
+		case "RUNNING":
+			st.State = StateRunning
+		case "STOPPED", "STOPPING", "EXITED":
+			st.State = StateStopped
+		case "STARTING":
+			st.State = StateStarting
+		case "BACKOFF", "FATAL":
+			st.State = StateFailed
+		}
+		if m := supervisorPIDRe.FindStringSubmatch(line); m != nil {
+			st.PID, _ = strconv.Atoi(m[1])
+		}
+		if m := supervisorUptimeRe.FindStringSubmatch(line); m != nil {
+			var parts [4]int64
+			for i := range parts {
+				parts[i], _ = strconv.ParseInt(m[i+1], 10, 64)
+			}
+			st.Uptime = parts[0]*86400 + parts[1]*3600 + parts[2]*60 + parts[3]
+		}
+	}
+	return st
+}
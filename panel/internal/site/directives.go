@@ -0,0 +1,203 @@
+package site
+
+import (
+	"errors"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/nginx"
+)
+
+// applyConfig 通过事务写入新配置：验证通过后替换并重载，失败时原配置不变。
+// 成功后记录为新版本，comment 为版本说明
+func applyConfig(c *fiber.Ctx, paths *layout.Paths, config, comment string) error {
+	return writeConfig(c, paths, config, comment, false)
+}
+
+// applyRendered 写入用站点模板生成的配置
+func applyRendered(c *fiber.Ctx, paths *layout.Paths, config, comment string) error {
+	return writeConfig(c, paths, config, comment, true)
+}
+
+// applyManaged 写入面板对证书、PHP 版本的局部修改。这些设置重新生成时会从站点参数和证书得到，
+// 修改前的配置由模板生成时，修改后仍可重新生成
+func applyManaged(c *fiber.Ctx, paths *layout.Paths, config, comment string) error {
+	return writeConfig(c, paths, config, comment, rendered(paths))
+}
+
+func writeConfig(c *fiber.Ctx, paths *layout.Paths, config, comment string, rendered bool) error {
+	captureExternal(paths)
+
+	tx := nginx.Begin()
+	tx.WriteFile(paths.Config, []byte(config))
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	recordRevision(c, paths.Domain, config, comment, rendered)
+	return nil
+}
+
+// applyError 事务提交失败时的响应，nginx -t 的错误行放在 output 中
+func applyError(c *fiber.Ctx, err error) error {
+	var testErr *nginx.TestError
+	if errors.As(err, &testErr) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "配置测试失败，原配置保持不变: " + testErr.Error(),
+			"output":  testErr.Lines,
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存失败: " + err.Error()})
+}
+
+// loadConfig 读取并解析站点配置
+func loadConfig(c *fiber.Ctx) (*layout.Paths, *nginx.Config, error) {
+	paths := layout.Resolve(c.Params("domain"))
+	if !paths.Exists() {
+		return nil, nil, c.Status(404).JSON(fiber.Map{"status": false, "message": "配置不存在"})
+	}
+	content, err := os.ReadFile(paths.Config)
+	if err != nil {
+		return nil, nil, c.Status(500).JSON(fiber.Map{"status": false, "message": "读取配置失败"})
+	}
+	cfg, err := nginx.Parse(string(content))
+	if err != nil {
+		return nil, nil, c.Status(400).JSON(fiber.Map{"status": false, "message": "配置解析失败: " + err.Error()})
+	}
+	return paths, cfg, nil
+}
+
+// editConfig 在语法树上执行修改，验证通过后写回
+func editConfig(c *fiber.Ctx, message string, edit func(cfg *nginx.Config) error) error {
+	paths, cfg, err := loadConfig(c)
+	if cfg == nil {
+		return err
+	}
+
+	if err := edit(cfg); err != nil {
+		status := 400
+		if errors.Is(err, nginx.ErrLocationMissing) || errors.Is(err, nginx.ErrHeaderMissing) {
+			status = 404
+		}
+		return c.Status(status).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	config := cfg.String()
+	if err := applyConfig(c, paths, config, message); err != nil {
+		return applyError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": message,
+		"data":    fiber.Map{"config": config},
+	})
+}
+
+// ListLocations 列出站点的 location
+func ListLocations(c *fiber.Ctx) error {
+	_, cfg, err := loadConfig(c)
+	if cfg == nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   cfg.Locations(),
+	})
+}
+
+// AddLocation 新增 location
+func AddLocation(c *fiber.Ctx) error {
+	var req nginx.Location
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	return editConfig(c, "location 已添加", func(cfg *nginx.Config) error {
+		return cfg.AddLocation(req)
+	})
+}
+
+// RemoveLocation 删除 location，通过 modifier 和 path 查询参数指定
+func RemoveLocation(c *fiber.Ctx) error {
+	modifier, path := c.Query("modifier"), c.Query("path")
+	if path == "" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "location 路径不能为空"})
+	}
+	return editConfig(c, "location 已删除", func(cfg *nginx.Config) error {
+		return cfg.RemoveLocation(modifier, path)
+	})
+}
+
+// SetClientMaxBodySize 设置上传大小限制
+func SetClientMaxBodySize(c *fiber.Ctx) error {
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	return editConfig(c, "上传大小限制已更新", func(cfg *nginx.Config) error {
+		return cfg.SetClientMaxBodySize(req.Value)
+	})
+}
+
+// AddHeader 添加响应头，同名响应头会被替换
+func AddHeader(c *fiber.Ctx) error {
+	var req struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Always bool   `json:"always"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	return editConfig(c, "响应头已添加", func(cfg *nginx.Config) error {
+		return cfg.AddHeader(req.Name, req.Value, req.Always)
+	})
+}
+
+// RemoveHeader 删除响应头
+func RemoveHeader(c *fiber.Ctx) error {
+	name := c.Params("name")
+	return editConfig(c, "响应头已删除", func(cfg *nginx.Config) error {
+		return cfg.RemoveHeader(name)
+	})
+}
+
+// SetGzip 开关 gzip 压缩
+func SetGzip(c *fiber.Ctx) error {
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	message := "gzip 已关闭"
+	if req.Enabled {
+		message = "gzip 已开启"
+	}
+	return editConfig(c, message, func(cfg *nginx.Config) error {
+		return cfg.SetGzip(req.Enabled)
+	})
+}
+
+// AddRedirect 添加路径跳转，默认 301
+func AddRedirect(c *fiber.Ctx) error {
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+		Code int    `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	if req.Code == 0 {
+		req.Code = 301
+	}
+	return editConfig(c, "跳转已添加", func(cfg *nginx.Config) error {
+		return cfg.AddRedirect(req.From, req.To, req.Code)
+	})
+}
@@ -0,0 +1,143 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/nginx"
+)
+
+const cliPHPConfig = `server {
+    listen 80;
+    server_name php.example.com;
+    root /www/wwwroot/php.example.com;
+    index index.php index.html;
+
+    access_log /www/wwwlogs/nginx/php.example.com.access.log;
+    error_log /www/wwwlogs/nginx/php.example.com.error.log;
+
+    # PHP
+    location ~ \.php$ {
+        fastcgi_pass unix:/run/php/php8.3-fpm.sock;
+        include fastcgi_params;
+    }
+}
+`
+
+func newDirectivesApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/sites/:domain/nginx/locations", ListLocations)
+	app.Post("/sites/:domain/nginx/locations", AddLocation)
+	app.Delete("/sites/:domain/nginx/locations", RemoveLocation)
+	app.Put("/sites/:domain/nginx/client-max-body-size", SetClientMaxBodySize)
+	app.Post("/sites/:domain/nginx/headers", AddHeader)
+	app.Delete("/sites/:domain/nginx/headers/:name", RemoveHeader)
+	app.Put("/sites/:domain/nginx/gzip", SetGzip)
+	app.Post("/sites/:domain/nginx/redirects", AddRedirect)
+	return newTestClient(t, app)
+}
+
+func TestStructuredEdits(t *testing.T) {
+	setupDirs(t)
+	do := newDirectivesApp(t)
+	writeFile(t, layout.For(layout.CLI, "php.example.com").Config, cliPHPConfig)
+
+	steps := []struct {
+		method, path, body string
+	}{
+		{"PUT", "/sites/php.example.com/nginx/client-max-body-size", `{"value":"64m"}`},
+		{"POST", "/sites/php.example.com/nginx/headers", `{"name":"X-Frame-Options","value":"SAMEORIGIN","always":true}`},
+		{"PUT", "/sites/php.example.com/nginx/gzip", `{"enabled":true}`},
+		{"POST", "/sites/php.example.com/nginx/locations", `{"path":"/api/","directives":[{"name":"proxy_pass","args":["http://127.0.0.1:9000"]}]}`},
+		{"POST", "/sites/php.example.com/nginx/redirects", `{"from":"/old","to":"/new"}`},
+	}
+	for _, s := range steps {
+		if status, result := do(s.method, s.path, s.body); status != 200 {
+			t.Fatalf("%s %s returned %d: %+v", s.method, s.path, status, result)
+		}
+	}
+
+	config := readConfig(t, "php.example.com")
+	for _, want := range []string{
+		"client_max_body_size 64m;",
+		"add_header X-Frame-Options SAMEORIGIN always;",
+		"gzip on;",
+		"    location /api/ {\n        proxy_pass http://127.0.0.1:9000;\n    }",
+		"    location = /old {\n        return 301 /new;\n    }",
+		"    # PHP\n    location ~ \\.php$ {",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("Expected %q in config:\n%s", want, config)
+		}
+	}
+
+	status, result := do("GET", "/sites/php.example.com/nginx/locations", "")
+	var locations []nginx.Location
+	json.Unmarshal(result.Data, &locations)
+	if status != 200 || len(locations) != 3 || locations[0].Path != `\.php$` || locations[0].Modifier != "~" {
+		t.Errorf("Unexpected locations %d: %+v", status, locations)
+	}
+
+	query := url.Values{"modifier": {"="}, "path": {"/old"}}.Encode()
+	if status, _ := do("DELETE", "/sites/php.example.com/nginx/locations?"+query, ""); status != 200 {
+		t.Errorf("RemoveLocation returned %d", status)
+	}
+	if status, _ := do("DELETE", "/sites/php.example.com/nginx/locations?"+query, ""); status != 404 {
+		t.Errorf("Expected 404 for missing location, got %d", status)
+	}
+	if status, _ := do("DELETE", "/sites/php.example.com/nginx/headers/x-frame-options", ""); status != 200 {
+		t.Errorf("RemoveHeader returned %d", status)
+	}
+	if config := readConfig(t, "php.example.com"); strings.Contains(config, "/old") || strings.Contains(config, "X-Frame-Options") {
+		t.Errorf("Expected redirect and header to be removed:\n%s", config)
+	}
+}
+
+func TestStructuredEditErrors(t *testing.T) {
+	setupDirs(t)
+	do := newDirectivesApp(t)
+	writeFile(t, layout.For(layout.CLI, "php.example.com").Config, cliPHPConfig)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"PUT", "/sites/php.example.com/nginx/client-max-body-size", `{"value":"1g; root /"}`, 400},
+		{"POST", "/sites/php.example.com/nginx/headers", `{"name":"X Bad","value":"1"}`, 400},
+		{"POST", "/sites/php.example.com/nginx/locations", `{"modifier":"~","path":"\\.php$"}`, 400},
+		{"POST", "/sites/php.example.com/nginx/redirects", `{"from":"/a","to":"/b","code":200}`, 400},
+		{"DELETE", "/sites/php.example.com/nginx/headers/X-Missing", "", 404},
+		{"PUT", "/sites/missing.example.com/nginx/gzip", `{"enabled":true}`, 404},
+	}
+	for _, tt := range tests {
+		if status, result := do(tt.method, tt.path, tt.body); status != tt.status {
+			t.Errorf("%s %s: expected %d, got %d %+v", tt.method, tt.path, tt.status, status, result)
+		}
+	}
+	if readConfig(t, "php.example.com") != cliPHPConfig {
+		t.Error("Rejected edits must not change the config")
+	}
+
+	// nginx -t 失败时恢复原配置
+	nginx.Test = func(string) error { return errors.New("nginx: [emerg] unknown directive") }
+	status, result := do("PUT", "/sites/php.example.com/nginx/gzip", `{"enabled":true}`)
+	if status != 400 || !strings.Contains(result.Message, "unknown directive") {
+		t.Errorf("Expected nginx error, got %d %+v", status, result)
+	}
+	if readConfig(t, "php.example.com") != cliPHPConfig {
+		t.Error("Expected original config to be restored")
+	}
+
+	// 无法解析的配置不做修改
+	writeFile(t, layout.For(layout.CLI, "broken.example.com").Config, "server {\n    listen 80;\n")
+	if status, _ := do("PUT", "/sites/broken.example.com/nginx/gzip", `{"enabled":true}`); status != 400 {
+		t.Errorf("Expected 400 for unparsable config, got %d", status)
+	}
+}
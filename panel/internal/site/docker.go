@@ -0,0 +1,350 @@
+package site
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/docker"
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+)
+
+var dockerClient *docker.Client
+
+// SetDockerClient 设置 Docker 站点使用的 Engine API 客户端
+func SetDockerClient(c *docker.Client) {
+	dockerClient = c
+}
+
+// composeDir Docker 站点的 compose 目录，与 CLI 的 DOCKER_DIR/<domain> 相同
+func composeDir(domain string) string {
+	return filepath.Join(dockerDir, domain)
+}
+
+// defaultCompose 新建 Docker 站点的默认 compose 文件：挂载站点目录运行示例 Node.js 应用，端口只映射到本机供 nginx 代理
+func defaultCompose(domain string, port int) string {
+	return fmt.Sprintf(`services:
+  app:
+    image: node:20-alpine
+    working_dir: /app
+    command: node server.js
+    ports:
+      - "127.0.0.1:%d:%d"
+    volumes:
+      - %s:/app
+    environment:
+      - PORT=%d
+    restart: unless-stopped
+`, port, port, filepath.Join(sitesDir, domain), port)
+}
+
+// checkCompose 绑定挂载和构建目录只能位于 compose 目录或站点目录之下。
+// 保存和启动前都要检查，启动时检查可拦截直接写入磁盘的 compose 文件；停止和删除不受限制
+func checkCompose(domain string, p *docker.Project) error {
+	return p.CheckPaths(filepath.Join(sitesDir, domain))
+}
+
+// parseCompose 解析站点的 compose 内容，必须有映射到宿主机的端口供反向代理使用
+func parseCompose(domain string, content []byte) (*docker.Project, error) {
+	dir := composeDir(domain)
+	p, err := docker.ParseProject(docker.ProjectName(dir), dir, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCompose(domain, p); err != nil {
+		return nil, err
+	}
+	if p.PublishedPort() == 0 {
+		return nil, errors.New("compose 文件中没有映射到宿主机的 TCP 端口，无法配置反向代理")
+	}
+	return p, nil
+}
+
+// prepareCompose 新建 Docker 站点时确定 compose 内容：请求中带 compose 时反向代理指向其映射的端口，否则生成默认文件
+func prepareCompose(req *CreateRequest) ([]byte, error) {
+	if req.Type != "docker" {
+		return nil, nil
+	}
+	if req.Compose == "" {
+		if req.Port == 0 {
+			return nil, nil // 由模板校验报告缺少端口
+		}
+		return []byte(defaultCompose(req.Domain, req.Port)), nil
+	}
+	p, err := parseCompose(req.Domain, []byte(req.Compose))
+	if err != nil {
+		return nil, err
+	}
+	req.Port = p.PublishedPort()
+	return []byte(req.Compose), nil
+}
+
+// writeCompose 写入 compose 文件；使用默认文件时同时写入示例应用
+func writeCompose(record *models.Site, content []byte, sample bool) error {
+	dir := composeDir(record.Domain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if sample {
+		if path := filepath.Join(sitesDir, record.Domain, "server.js"); !fileExists(path) {
+			os.WriteFile(path, []byte(sampleServerJS), 0644)
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, docker.ComposeFile), content, 0644)
+}
+
+// controlCompose 启用、禁用和删除 Docker 站点时同步容器：启用时 up，禁用时停止，删除时 down（保留 compose 文件）
+func controlCompose(record *models.Site, action string) {
+	if dockerClient == nil {
+		return
+	}
+	p, err := docker.LoadProject(composeDir(record.Domain))
+	if err != nil {
+		return
+	}
+	switch action {
+	case "start":
+		if err = checkCompose(record.Domain, p); err == nil {
+			err = dockerClient.Up(p, nil)
+		}
+	case "stop":
+		err = dockerClient.Stop(p)
+	case "remove":
+		err = dockerClient.Down(p, nil)
+	}
+	if err != nil {
+		log.Printf("[site] %s 容器 %s 失败: %v", record.Domain, action, err)
+	}
+}
+
+// dockerSite Docker 站点的记录；失败时已写入响应，返回的 record 为 nil
+func dockerSite(c *fiber.Ctx) (*models.Site, error) {
+	record, err := lookupSite(c.Params("domain"))
+	if err != nil {
+		return nil, c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return nil, c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	if record.Type != "docker" {
+		return nil, c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点不是 Docker 站点"})
+	}
+	if dockerClient == nil {
+		return nil, c.Status(500).JSON(fiber.Map{"status": false, "message": "Docker 管理未启用"})
+	}
+	return record, nil
+}
+
+// siteProject Docker 站点的 compose 项目；失败时已写入响应，返回的 project 为 nil
+func siteProject(c *fiber.Ctx) (*models.Site, *docker.Project, error) {
+	record, err := dockerSite(c)
+	if record == nil {
+		return nil, nil, err
+	}
+	p, err := docker.LoadProject(composeDir(record.Domain))
+	if os.IsNotExist(err) {
+		return nil, nil, c.Status(404).JSON(fiber.Map{"status": false, "message": "compose 文件不存在"})
+	}
+	if err != nil {
+		return nil, nil, c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return record, p, nil
+}
+
+// GetDocker 获取 Docker 站点的 compose 文件和各服务的容器状态；Docker 不可用时 services 为空并返回 error
+func GetDocker(c *fiber.Ctx) error {
+	record, p, err := siteProject(c)
+	if p == nil {
+		return err
+	}
+	content, _ := os.ReadFile(filepath.Join(p.Dir, docker.ComposeFile))
+	data := fiber.Map{
+		"project": p.Name,
+		"compose": string(content),
+		"port":    record.Port,
+	}
+	if services, err := dockerClient.PS(p); err != nil {
+		data["error"] = err.Error()
+	} else {
+		data["services"] = services
+	}
+	return c.JSON(fiber.Map{"status": true, "data": data})
+}
+
+// SaveCompose 保存 compose 文件；映射端口变化时重新生成配置，让反向代理指向新端口，
+// 配置在上次生成后被修改过时返回 409。配置验证失败时 compose 文件恢复原样。保存后不会自动 up
+func SaveCompose(c *fiber.Ctx) error {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	// compose 文件不存在或已损坏时也可以保存
+	record, err := dockerSite(c)
+	if record == nil {
+		return err
+	}
+
+	p, err := parseCompose(record.Domain, []byte(req.Content))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	if port := p.PublishedPort(); port != record.Port && !rendered(layout.Resolve(record.Domain)) {
+		return modifiedError(c)
+	}
+	path := filepath.Join(composeDir(record.Domain), docker.ComposeFile)
+	previous, readErr := os.ReadFile(path)
+	if err := writeCompose(record, []byte(req.Content), false); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存 compose 文件失败: " + err.Error()})
+	}
+
+	restore := func() {
+		if readErr == nil {
+			os.WriteFile(path, previous, 0644)
+		} else {
+			os.Remove(path)
+		}
+	}
+
+	message := "compose 文件已保存"
+	if port := p.PublishedPort(); port != record.Port {
+		paths := layout.Resolve(record.Domain)
+		t, err := siteTemplate(record)
+		if err != nil {
+			restore()
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点类型没有可用的模板"})
+		}
+		fillCertificate(record, paths)
+		params := storedParams(record, t)
+		params["port"] = port
+		config, err := renderSite(record, paths, params, record.CreatedAt)
+		if err != nil {
+			restore()
+			return templateError(c, err)
+		}
+		if err := applyRendered(c, paths, config, fmt.Sprintf("反向代理指向容器端口 %d", port)); err != nil {
+			restore()
+			return applyError(c, err)
+		}
+		if err := record.Update(); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存站点参数失败"})
+		}
+		message = fmt.Sprintf("compose 文件已保存，反向代理已指向端口 %d", port)
+	}
+	return c.JSON(fiber.Map{"status": true, "message": message, "data": fiber.Map{"port": record.Port}})
+}
+
+// DockerAction 对 Docker 站点执行 up、down 或 pull，返回执行过程的输出和容器状态
+func DockerAction(c *fiber.Ctx) error {
+	record, p, err := siteProject(c)
+	if p == nil {
+		return err
+	}
+
+	var output []string
+	progress := func(line string) { output = append(output, line) }
+	action := c.Params("action")
+	switch action {
+	case "up":
+		if err := checkCompose(record.Domain, p); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		err = dockerClient.Up(p, progress)
+	case "down":
+		err = dockerClient.Down(p, progress)
+	case "pull":
+		err = dockerClient.Pull(p, progress)
+	default:
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的操作"})
+	}
+	if err != nil {
+		log.Printf("[site] %s docker %s 失败: %v", record.Domain, action, err)
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": "执行 " + action + " 失败: " + err.Error(),
+			"output":  output,
+		})
+	}
+
+	services, _ := dockerClient.PS(p)
+	messages := map[string]string{"up": "容器已启动", "down": "容器已停止并删除", "pull": "镜像已更新，执行 up 后生效"}
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": messages[action],
+		"output":  output,
+		"data":    services,
+	})
+}
+
+// DockerPS 各服务的容器状态
+func DockerPS(c *fiber.Ctx) error {
+	_, p, err := siteProject(c)
+	if p == nil {
+		return err
+	}
+	services, err := dockerClient.PS(p)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "获取容器状态失败: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "data": services})
+}
+
+// cancelWriter 写入失败（客户端断开）时取消日志读取
+type cancelWriter struct {
+	w      *bufio.Writer
+	cancel context.CancelFunc
+}
+
+func (cw *cancelWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	if err != nil {
+		cw.cancel()
+	}
+	return n, err
+}
+
+func (cw *cancelWriter) Flush() error {
+	err := cw.w.Flush()
+	if err != nil {
+		cw.cancel()
+	}
+	return err
+}
+
+// DockerLogs 以纯文本流输出容器日志；service 为空时输出所有服务，follow=1 时持续输出直到客户端断开
+func DockerLogs(c *fiber.Ctx) error {
+	_, p, err := siteProject(c)
+	if p == nil {
+		return err
+	}
+	service := c.Query("service")
+	if service != "" && p.Service(service) == nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "服务不存在"})
+	}
+	opts := docker.LogsOptions{Tail: c.QueryInt("tail", 200), Follow: c.QueryBool("follow"), Timestamps: c.QueryBool("timestamps")}
+	if opts.Tail < 0 || opts.Tail > 5000 {
+		opts.Tail = 200
+	}
+
+	c.Set(fiber.HeaderContentType, "text/plain; charset=utf-8")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		out := &cancelWriter{w: w, cancel: cancel}
+		if err := dockerClient.Logs(ctx, p, service, opts, out); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(out, "[读取日志失败: %v]\n", err)
+		}
+		out.Flush()
+	})
+	return nil
+}
@@ -0,0 +1,237 @@
+package site
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/docker"
+	"site_manager_panel/internal/docker/dockertest"
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+)
+
+func setupDocker(t *testing.T) *dockertest.Server {
+	t.Helper()
+	server := dockertest.NewServer()
+	SetDockerClient(server.Client())
+	t.Cleanup(func() {
+		dockerClient = nil
+		server.Close()
+	})
+	return server
+}
+
+func newDockerApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/sites", Create)
+	app.Delete("/sites/:domain", Delete)
+	app.Post("/sites/:domain/enable", Enable)
+	app.Post("/sites/:domain/disable", Disable)
+	app.Get("/sites/:domain/docker", GetDocker)
+	app.Put("/sites/:domain/docker/compose", SaveCompose)
+	app.Get("/sites/:domain/docker/ps", DockerPS)
+	app.Get("/sites/:domain/docker/logs", DockerLogs)
+	app.Post("/sites/:domain/docker/:action", DockerAction)
+	return app
+}
+
+func TestDockerSite(t *testing.T) {
+	root := setupDirs(t)
+	server := setupDocker(t)
+	app := newDockerApp()
+	do := newTestClient(t, app)
+
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "app.example.com", "type": "docker", "port": 3000})); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	compose, err := os.ReadFile(filepath.Join(root, "docker", "app.example.com", docker.ComposeFile))
+	if err != nil || !strings.Contains(string(compose), `"127.0.0.1:3000:3000"`) {
+		t.Fatalf("Expected default compose file, got %q %v", compose, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "wwwroot", "app.example.com", "server.js")); err != nil {
+		t.Errorf("Expected sample server.js: %v", err)
+	}
+	if len(server.Calls()) != 0 {
+		t.Errorf("Expected create not to start containers, got %v", server.Calls())
+	}
+
+	var info struct {
+		Project  string                `json:"project"`
+		Port     int                   `json:"port"`
+		Services []docker.ServiceState `json:"services"`
+	}
+	status, result := do("GET", "/sites/app.example.com/docker", "")
+	json.Unmarshal(result.Data, &info)
+	if status != 200 || info.Project != "appexamplecom" || info.Port != 3000 || len(info.Services) != 1 || info.Services[0].State != "" {
+		t.Fatalf("Unexpected docker info: %d %+v", status, info)
+	}
+
+	server.AddImage("node:20-alpine")
+	var services []docker.ServiceState
+	status, result = do("POST", "/sites/app.example.com/docker/up", "")
+	json.Unmarshal(result.Data, &services)
+	if status != 200 || len(services) != 1 || services[0].State != "running" || !strings.Contains(strings.Join(result.Output, "\n"), "创建容器 appexamplecom-app-1") {
+		t.Fatalf("Up failed: %d %+v", status, result)
+	}
+	if status, _ := do("POST", "/sites/app.example.com/docker/restart", ""); status != 400 {
+		t.Errorf("Expected 400 for unknown action, got %d", status)
+	}
+
+	// 禁用时停止容器，启用时重新启动，删除时移除容器但保留 compose 文件
+	do("POST", "/sites/app.example.com/disable", "")
+	if server.State("appexamplecom-app-1") != "exited" {
+		t.Errorf("Expected container to stop on disable, got %q", server.State("appexamplecom-app-1"))
+	}
+	do("POST", "/sites/app.example.com/enable", "")
+	if server.State("appexamplecom-app-1") != "running" {
+		t.Errorf("Expected container to start on enable, got %q", server.State("appexamplecom-app-1"))
+	}
+
+	status, result = do("GET", "/sites/app.example.com/docker/ps", "")
+	json.Unmarshal(result.Data, &services)
+	if status != 200 || len(services) != 1 || !strings.Contains(strings.Join(services[0].Ports, ","), "127.0.0.1:3000->3000/tcp") {
+		t.Errorf("Unexpected ps: %d %+v", status, services)
+	}
+
+	if status, result := do("POST", "/sites/app.example.com/docker/down", ""); status != 200 || server.State("appexamplecom-app-1") != "" {
+		t.Errorf("Down failed: %d %+v", status, result)
+	}
+	server.Registry = map[string]bool{}
+	if status, result := do("POST", "/sites/app.example.com/docker/pull", ""); status != 500 || !strings.Contains(strings.Join(result.Output, "\n"), "node:20-alpine") {
+		t.Errorf("Expected pull to fail, got %d %+v", status, result)
+	}
+
+	do("POST", "/sites/app.example.com/docker/up", "")
+	if status, _ := do("DELETE", "/sites/app.example.com", ""); status != 200 {
+		t.Fatalf("Delete failed: %d", status)
+	}
+	if server.State("appexamplecom-app-1") != "" || len(server.Networks()) != 0 {
+		t.Error("Expected containers and network to be removed on delete")
+	}
+	if !fileExists(filepath.Join(root, "docker", "app.example.com", docker.ComposeFile)) {
+		t.Error("Expected compose file to be kept on delete")
+	}
+}
+
+func TestDockerCompose(t *testing.T) {
+	root := setupDirs(t)
+	setupDocker(t)
+	app := newDockerApp()
+	do := newTestClient(t, app)
+
+	custom := "services:\n  web:\n    image: nginx:alpine\n    ports:\n      - \"127.0.0.1:8081:80\"\n"
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "web.example.com", "type": "docker", "compose": "services: {}\n"})); status != 400 {
+		t.Errorf("Expected 400 for compose without services, got %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "web.example.com", "type": "docker", "compose": custom})); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	paths := layout.Resolve("web.example.com")
+	config, _ := os.ReadFile(paths.Config)
+	if !strings.Contains(string(config), "127.0.0.1:8081") {
+		t.Fatalf("Expected proxy to the compose port:\n%s", config)
+	}
+	if fileExists(filepath.Join(root, "wwwroot", "web.example.com", "server.js")) {
+		t.Error("Expected no sample app for a custom compose file")
+	}
+
+	// 端口变化时反向代理指向新端口
+	changed := strings.Replace(custom, "8081", "8082", 1)
+	status, result := do("PUT", "/sites/web.example.com/docker/compose", jsonBody(t, fiber.Map{"content": changed}))
+	if status != 200 || !strings.Contains(result.Message, "8082") {
+		t.Fatalf("SaveCompose failed: %d %+v", status, result)
+	}
+	config, _ = os.ReadFile(paths.Config)
+	record, _ := models.GetSite("web.example.com")
+	if !strings.Contains(string(config), "127.0.0.1:8082") || record.Port != 8082 {
+		t.Errorf("Expected proxy to be rewired to 8082 (port %d):\n%s", record.Port, config)
+	}
+
+	// 配置在面板外修改过时不重新生成，compose 文件也不保存
+	edited := string(config) + "# keep me\n"
+	writeFile(t, paths.Config, edited)
+	if status, result := do("PUT", "/sites/web.example.com/docker/compose", jsonBody(t, fiber.Map{"content": custom})); status != 409 {
+		t.Errorf("Expected 409 for modified config, got %d %+v", status, result)
+	}
+	if current, _ := os.ReadFile(paths.Config); string(current) != edited {
+		t.Error("Expected modified config to be kept")
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "docker", "web.example.com", docker.ComposeFile)); string(content) != changed {
+		t.Errorf("Expected compose file unchanged, got %q", content)
+	}
+	writeFile(t, paths.Config, string(config))
+
+	if status, _ := do("PUT", "/sites/web.example.com/docker/compose", jsonBody(t, fiber.Map{"content": "services:\n  web:\n    image: nginx\n"})); status != 400 {
+		t.Errorf("Expected 400 for compose without published port, got %d", status)
+	}
+	// 不允许挂载站点目录之外的宿主机路径
+	for _, volume := range []string{"/:/host", "../../etc:/etc", "/var/run/docker.sock:/var/run/docker.sock"} {
+		content := changed + "    volumes:\n      - " + volume + "\n"
+		if status, result := do("PUT", "/sites/web.example.com/docker/compose", jsonBody(t, fiber.Map{"content": content})); status != 400 {
+			t.Errorf("%s: expected 400, got %d %+v", volume, status, result)
+		}
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "docker", "web.example.com", docker.ComposeFile)); string(content) != changed {
+		t.Errorf("Expected invalid compose not to be saved, got %q", content)
+	}
+
+	// 直接写入磁盘的 compose 文件在 up 时同样被拦截
+	writeFile(t, filepath.Join(root, "docker", "web.example.com", docker.ComposeFile), changed+"    volumes:\n      - /:/host\n")
+	if status, result := do("POST", "/sites/web.example.com/docker/up", ""); status != 400 || !strings.Contains(result.Message, "宿主机路径") {
+		t.Errorf("Expected up to be rejected, got %d %+v", status, result)
+	}
+
+	do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "static.example.com", "type": "static"}))
+	if status, result := do("GET", "/sites/static.example.com/docker", ""); status != 400 || result.Message != "该站点不是 Docker 站点" {
+		t.Errorf("Expected 400 for non-docker site, got %d %+v", status, result)
+	}
+	if status, _ := do("GET", "/sites/missing.example.com/docker", ""); status != 404 {
+		t.Errorf("Expected 404 for missing site, got %d", status)
+	}
+}
+
+func TestDockerLogs(t *testing.T) {
+	setupDirs(t)
+	server := setupDocker(t)
+	app := newDockerApp()
+	do := newTestClient(t, app)
+
+	do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "app.example.com", "type": "docker", "port": 3000}))
+	server.AddImage("node:20-alpine")
+	do("POST", "/sites/app.example.com/docker/up", "")
+	server.SetLogs("appexamplecom-app-1", "listening on 3000\nGET / 200\n")
+
+	if status, _ := do("GET", "/sites/app.example.com/docker/logs?service=db", ""); status != 400 {
+		t.Errorf("Expected 400 for unknown service, got %d", status)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/sites/app.example.com/docker/logs?tail=1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") || string(body) != "app | GET / 200\n" {
+		t.Errorf("Unexpected logs: %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/sites/app.example.com/docker/logs?service=app", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if strings.Join(lines, ",") != "listening on 3000,GET / 200" {
+		t.Errorf("Unexpected service logs: %q", lines)
+	}
+}
@@ -0,0 +1,292 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/ssl"
+)
+
+// siteTemplate 站点使用的模板，未记录模板时按站点类型选择
+func siteTemplate(record *models.Site) (*nginx.Template, error) {
+	name := record.Template
+	if name == "" {
+		name = record.Type
+	}
+	return nginx.GetTemplate(name)
+}
+
+// fillCertificate 站点记录中没有证书路径时（如 certbot 配置的站点）沿用配置中的证书
+func fillCertificate(record *models.Site, paths *layout.Paths) {
+	if record.SSLCert != "" && record.SSLKey != "" {
+		return
+	}
+	if content, err := os.ReadFile(paths.Config); err == nil {
+		if cfg, err := nginx.Parse(string(content)); err == nil {
+			record.SSLCert, record.SSLKey = directiveArg(cfg, "ssl_certificate"), directiveArg(cfg, "ssl_certificate_key")
+		}
+	}
+}
+
+// redirectGroups 按状态码分组跳转域名，301 在前
+func redirectGroups(redirects models.Redirects) []nginx.Redirect {
+	groups := []nginx.Redirect{}
+	for _, code := range []int{301, 302} {
+		g := nginx.Redirect{Code: code}
+		for _, r := range redirects {
+			if r.Code == code {
+				g.Domains = append(g.Domains, r.Domain)
+			}
+		}
+		if len(g.Domains) > 0 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+func hasDomain(list []string, name string) bool {
+	for _, n := range list {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNewDomain 校验要加入站点的域名：格式正确，不与站点已有的域名重复，也没有被其他站点使用
+func checkNewDomain(record *models.Site, name string) error {
+	if !isValidDomain(name) {
+		return fmt.Errorf("无效的域名格式: %s", name)
+	}
+	if hasDomain(record.Names(), name) {
+		return fmt.Errorf("域名 %s 已在站点中", name)
+	}
+	sites, err := models.ListSites()
+	if err != nil {
+		return fmt.Errorf("读取站点失败")
+	}
+	for _, s := range sites {
+		if s.Domain != record.Domain && hasDomain(s.Names(), name) {
+			return fmt.Errorf("域名 %s 已被站点 %s 使用", name, s.Domain)
+		}
+	}
+	if layout.Resolve(name).Exists() {
+		return fmt.Errorf("域名 %s 已被站点 %s 使用", name, name)
+	}
+	return nil
+}
+
+// certCovers 证书域名是否包含 host，支持单级通配符
+func certCovers(names []string, host string) bool {
+	for _, n := range names {
+		if n == host {
+			return true
+		}
+		if strings.HasPrefix(n, "*.") {
+			if i := strings.Index(host, "."); i > 0 && host[i+1:] == n[2:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func domainsData(record *models.Site) fiber.Map {
+	return fiber.Map{
+		"domain":    record.Domain,
+		"aliases":   record.Aliases,
+		"redirects": record.Redirects,
+		"canonical": record.Canonical,
+	}
+}
+
+// GetDomains 获取站点的别名、跳转域名和规范域名
+func GetDomains(c *fiber.Ctx) error {
+	record, err := lookupSite(c.Params("domain"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	return c.JSON(fiber.Map{"status": true, "data": domainsData(record)})
+}
+
+// updateDomains 修改站点域名后用模板重新生成配置并保存；配置在上次生成后被修改过时返回 409。
+// 配置通过事务写入，nginx -t 失败时原配置不变。
+// 站点已有证书且新域名不在证书中时，重新签发包含全部域名的证书
+func updateDomains(c *fiber.Ctx, edit func(record *models.Site) error, comment, success string) error {
+	domain := c.Params("domain")
+	record, err := lookupSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	paths := layout.Resolve(domain)
+	if record == nil || !paths.Exists() {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	t, err := siteTemplate(record)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点类型没有可用的模板"})
+	}
+	if !rendered(paths) {
+		return modifiedError(c)
+	}
+	if err := edit(record); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	fillCertificate(record, paths)
+	config, err := renderSite(record, paths, storedParams(record, t), record.CreatedAt)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if err := applyRendered(c, paths, config, comment); err != nil {
+		return applyError(c, err)
+	}
+	if err := record.Update(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存站点域名失败"})
+	}
+	return extendCertificate(c, record, success)
+}
+
+// extendCertificate 证书缺少站点的域名时，沿用上次申请的验证方式重新签发；自定义证书只提示重新上传
+func extendCertificate(c *fiber.Ctx, record *models.Site, success string) error {
+	missing := []string{}
+	if record.SSLCert != "" {
+		names := ssl.Inspect(record.SSLCert, record.SSLKey).Names
+		for _, n := range record.Names() {
+			if !certCovers(names, n) {
+				missing = append(missing, n)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return c.JSON(fiber.Map{"status": true, "message": success, "data": domainsData(record)})
+	}
+
+	warn := func(hint string) error {
+		return c.JSON(fiber.Map{
+			"status":  true,
+			"message": fmt.Sprintf("%s；证书不包含 %s，%s", success, strings.Join(missing, ", "), hint),
+			"data":    domainsData(record),
+		})
+	}
+	if sslManager == nil {
+		return warn("请重新申请证书")
+	}
+	req, err := sslManager.RenewRequest(record.Domain, true)
+	if errors.Is(err, ssl.ErrCustom) {
+		return warn("请重新上传证书")
+	}
+	if err != nil {
+		return warn("请重新申请证书")
+	}
+	req.Domains = append(req.Domains, record.Names()...)
+	return issueSSL(c, *req, success+"，但更新证书失败", "更新证书域名", success+"，证书已包含新域名")
+}
+
+// AddAlias 添加别名，别名与主域名提供相同的内容
+func AddAlias(c *fiber.Ctx) error {
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	alias := strings.ToLower(strings.TrimSpace(req.Domain))
+	return updateDomains(c, func(record *models.Site) error {
+		if err := checkNewDomain(record, alias); err != nil {
+			return err
+		}
+		record.Aliases = append(record.Aliases, alias)
+		return nil
+	}, "添加别名 "+alias, "别名已添加")
+}
+
+// RemoveAlias 删除别名；该别名是规范域名时同时取消规范域名跳转
+func RemoveAlias(c *fiber.Ctx) error {
+	alias := c.Params("alias")
+	return updateDomains(c, func(record *models.Site) error {
+		aliases := []string{}
+		for _, a := range record.Aliases {
+			if a != alias {
+				aliases = append(aliases, a)
+			}
+		}
+		if len(aliases) == len(record.Aliases) {
+			return fmt.Errorf("别名 %s 不存在", alias)
+		}
+		record.Aliases = aliases
+		if record.Canonical == alias {
+			record.Canonical = ""
+		}
+		return nil
+	}, "删除别名 "+alias, "别名已删除")
+}
+
+// AddRedirectDomain 添加跳转域名，该域名的全部请求以 301 或 302 跳转到规范域名（未设置时为主域名）
+func AddRedirectDomain(c *fiber.Ctx) error {
+	var req models.RedirectDomain
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	req.Domain = strings.ToLower(strings.TrimSpace(req.Domain))
+	if req.Code == 0 {
+		req.Code = 301
+	}
+	if req.Code != 301 && req.Code != 302 {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "跳转状态码只能是 301 或 302"})
+	}
+	return updateDomains(c, func(record *models.Site) error {
+		if err := checkNewDomain(record, req.Domain); err != nil {
+			return err
+		}
+		record.Redirects = append(record.Redirects, req)
+		return nil
+	}, "添加跳转域名 "+req.Domain, "跳转域名已添加")
+}
+
+// RemoveRedirectDomain 删除跳转域名
+func RemoveRedirectDomain(c *fiber.Ctx) error {
+	name := c.Params("name")
+	return updateDomains(c, func(record *models.Site) error {
+		redirects := models.Redirects{}
+		for _, r := range record.Redirects {
+			if r.Domain != name {
+				redirects = append(redirects, r)
+			}
+		}
+		if len(redirects) == len(record.Redirects) {
+			return fmt.Errorf("跳转域名 %s 不存在", name)
+		}
+		record.Redirects = redirects
+		return nil
+	}, "删除跳转域名 "+name, "跳转域名已删除")
+}
+
+// SetCanonical 设置规范域名，主域名和别名中的其余域名 301 跳转到该域名；host 为空时取消
+func SetCanonical(c *fiber.Ctx) error {
+	var req struct {
+		Host string `json:"host"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	host := strings.ToLower(strings.TrimSpace(req.Host))
+	return updateDomains(c, func(record *models.Site) error {
+		if host != "" && host != record.Domain && !hasDomain(record.Aliases, host) {
+			return fmt.Errorf("规范域名须为主域名或别名")
+		}
+		record.Canonical = host
+		return nil
+	}, "设置规范域名", "规范域名已保存")
+}
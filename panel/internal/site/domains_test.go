@@ -0,0 +1,169 @@
+package site
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/ssl"
+)
+
+func newDomainsApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	app.Post("/sites", Create)
+	app.Post("/sites/:domain/ssl", RequestSSL)
+	app.Get("/sites/:domain/domains", GetDomains)
+	app.Post("/sites/:domain/aliases", AddAlias)
+	app.Delete("/sites/:domain/aliases/:alias", RemoveAlias)
+	app.Post("/sites/:domain/redirect-domains", AddRedirectDomain)
+	app.Delete("/sites/:domain/redirect-domains/:name", RemoveRedirectDomain)
+	app.Put("/sites/:domain/canonical", SetCanonical)
+	return newTestClient(t, app)
+}
+
+func TestSiteDomains(t *testing.T) {
+	setupDirs(t)
+	do := newDomainsApp(t)
+
+	status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "app.example.com", "type": "proxy", "port": 3000, "aliases": []string{"WWW.app.example.com"}}))
+	if status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	if config := readConfig(t, "app.example.com"); !strings.Contains(config, "server_name app.example.com www.app.example.com;") {
+		t.Fatalf("Expected alias in server_name:\n%s", config)
+	}
+
+	// 别名不能被其他站点重复使用，也不能作为新站点的主域名
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "other.example.com", "type": "static", "aliases": []string{"www.app.example.com"}})); status != 400 || !strings.Contains(result.Message, "已被站点 app.example.com 使用") {
+		t.Errorf("Expected alias conflict, got %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "www.app.example.com", "type": "static"})); status != 400 {
+		t.Errorf("Expected alias to be rejected as site domain, got %d %+v", status, result)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               string
+	}{
+		{"POST", "/sites/app.example.com/aliases", `{"domain":"bad domain"}`, "无效的域名格式"},
+		{"POST", "/sites/app.example.com/aliases", `{"domain":"app.example.com"}`, "已在站点中"},
+		{"POST", "/sites/app.example.com/redirect-domains", `{"domain":"old.example.com","code":307}`, "301 或 302"},
+		{"PUT", "/sites/app.example.com/canonical", `{"host":"old.example.com"}`, "主域名或别名"},
+		{"DELETE", "/sites/app.example.com/aliases/none.example.com", "", "不存在"},
+	} {
+		if status, result := do(tt.method, tt.path, tt.body); status != 400 || !strings.Contains(result.Message, tt.want) {
+			t.Errorf("%s %s: expected 400 %q, got %d %+v", tt.method, tt.path, tt.want, status, result)
+		}
+	}
+
+	if status, result := do("POST", "/sites/app.example.com/aliases", `{"domain":"m.app.example.com"}`); status != 200 {
+		t.Fatalf("AddAlias failed: %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites/app.example.com/redirect-domains", `{"domain":"old.example.com","code":302}`); status != 200 {
+		t.Fatalf("AddRedirectDomain failed: %d %+v", status, result)
+	}
+	if status, result := do("PUT", "/sites/app.example.com/canonical", `{"host":"www.app.example.com"}`); status != 200 {
+		t.Fatalf("SetCanonical failed: %d %+v", status, result)
+	}
+	config := readConfig(t, "app.example.com")
+	for _, want := range []string{
+		"server_name app.example.com www.app.example.com m.app.example.com;",
+		"server_name old.example.com;\n    return 302 $scheme://www.app.example.com$request_uri;",
+		"if ($host != www.app.example.com) {\n        return 301 $scheme://www.app.example.com$request_uri;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("Expected %q in config:\n%s", want, config)
+		}
+	}
+
+	// 删除作为规范域名的别名时同时取消跳转
+	if status, result := do("DELETE", "/sites/app.example.com/aliases/www.app.example.com", ""); status != 200 {
+		t.Fatalf("RemoveAlias failed: %d %+v", status, result)
+	}
+	if status, result := do("DELETE", "/sites/app.example.com/redirect-domains/old.example.com", ""); status != 200 {
+		t.Fatalf("RemoveRedirectDomain failed: %d %+v", status, result)
+	}
+	var domains struct {
+		Aliases   []string         `json:"aliases"`
+		Redirects models.Redirects `json:"redirects"`
+		Canonical string           `json:"canonical"`
+	}
+	status, result = do("GET", "/sites/app.example.com/domains", "")
+	json.Unmarshal(result.Data, &domains)
+	if status != 200 || strings.Join(domains.Aliases, ",") != "m.app.example.com" || len(domains.Redirects) != 0 || domains.Canonical != "" {
+		t.Errorf("Unexpected domains: %d %+v", status, domains)
+	}
+	config = readConfig(t, "app.example.com")
+	if strings.Contains(config, "old.example.com") || strings.Contains(config, "if ($host") {
+		t.Errorf("Expected redirect domain and canonical redirect to be removed:\n%s", config)
+	}
+
+	revisions, _ := models.ListConfigRevisions("app.example.com")
+	if len(revisions) == 0 || revisions[0].Comment != "删除跳转域名 old.example.com" {
+		t.Errorf("Unexpected revisions: %+v", revisions)
+	}
+
+	// 面板外修改过的配置不会被重新生成覆盖，域名也不变
+	edited := strings.Replace(config, "    location / {", "    # keep me\n    location / {", 1)
+	if edited == config {
+		t.Fatalf("Expected location in config:\n%s", config)
+	}
+	writeFile(t, layout.Resolve("app.example.com").Config, edited)
+	if status, result := do("POST", "/sites/app.example.com/aliases", `{"domain":"new.app.example.com"}`); status != 409 {
+		t.Errorf("Expected 409 for modified config, got %d %+v", status, result)
+	}
+	if readConfig(t, "app.example.com") != edited {
+		t.Error("Expected modified config to be kept")
+	}
+	if record, _ := models.GetSite("app.example.com"); strings.Join(record.Aliases, ",") != "m.app.example.com" {
+		t.Errorf("Expected aliases unchanged, got %v", record.Aliases)
+	}
+}
+
+func TestAliasExtendsCertificate(t *testing.T) {
+	root := setupDirs(t)
+	srv := setupSSL(t, root)
+	do := newDomainsApp(t)
+
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "app.example.com", "type": "static", "aliases": []string{"www.app.example.com"}})); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	// 申请证书时包含站点的全部域名
+	if status, result := do("POST", "/sites/app.example.com/ssl", ""); status != 200 {
+		t.Fatalf("RequestSSL failed: %d %+v", status, result)
+	}
+	names := ssl.Inspect(ssl.CertPath("app.example.com"), ssl.KeyPath("app.example.com")).Names
+	if strings.Join(names, ",") != "app.example.com,www.app.example.com" {
+		t.Fatalf("Unexpected certificate names: %v", names)
+	}
+
+	status, result := do("POST", "/sites/app.example.com/redirect-domains", `{"domain":"old.example.com"}`)
+	if status != 200 || !strings.Contains(result.Message, "证书已包含新域名") {
+		t.Fatalf("Expected certificate to be extended, got %d %+v", status, result)
+	}
+	if srv.Orders() != 2 {
+		t.Errorf("Expected 2 orders, got %d", srv.Orders())
+	}
+	names = ssl.Inspect(ssl.CertPath("app.example.com"), ssl.KeyPath("app.example.com")).Names
+	if strings.Join(names, ",") != "app.example.com,www.app.example.com,old.example.com" {
+		t.Errorf("Unexpected certificate names: %v", names)
+	}
+	config := readConfig(t, "app.example.com")
+	if !strings.Contains(config, "server_name old.example.com;") || strings.Count(config, "ssl_certificate "+ssl.CertPath("app.example.com")+";") != 2 {
+		t.Errorf("Expected redirect server with certificate:\n%s", config)
+	}
+
+	// 删除域名不需要重新签发
+	if status, result := do("DELETE", "/sites/app.example.com/aliases/www.app.example.com", ""); status != 200 || srv.Orders() != 2 {
+		t.Errorf("Expected no new order on removal, got %d %+v (%d orders)", status, result, srv.Orders())
+	}
+}
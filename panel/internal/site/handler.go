@@ -1,10 +1,8 @@
 package site
 
 import (
-	
-	"crypto/x509"
-	"encoding/pem"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,43 +11,84 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/backup"
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/process"
+	"site_manager_panel/internal/ssl"
+)
+
+// nginx 配置与日志目录由 layout 包统一管理
+var (
+	sitesDir          = "/www/wwwroot"
+	supervisorConfDir = "/etc/supervisor/conf.d"
+	dockerDir         = "/www/docker"
 )
 
-const nginxConfigDir = "/etc/nginx/sites-available"
-const nginxEnabledDir = "/etc/nginx/sites-enabled"
-const sitesDir = "/www/wwwroot"
-const logsDir = "/www/wwwlogs"
+var backupManager *backup.Manager
+
+// SetBackupManager 设置站点备份使用的备份管理器
+func SetBackupManager(m *backup.Manager) {
+	backupManager = m
+}
 
 type Site struct {
-	Domain string `json:"domain"`
-	Type   string `json:"type"`
-	Status string `json:"status"`
-	Path   string `json:"path"`
+	Domain    string    `json:"domain"`
+	Type      string    `json:"type"`
+	Status    string    `json:"status"`
+	Path      string    `json:"path"`
+	PHP       string    `json:"php,omitempty"`
+	Port      int       `json:"port,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	Root      string    `json:"root"`
+	Tags      []string  `json:"tags"`
+	Aliases   []string  `json:"aliases,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type SiteDetail struct {
 	Site
-	Size    string   `json:"size"`
-	SSL     string   `json:"ssl"`
-	SSLInfo *SSLInfo `json:"ssl_info,omitempty"`
-	Config  string   `json:"config"`
+	Size    string        `json:"size"`
+	SSL     string        `json:"ssl"`
+	SSLInfo *SSLInfo      `json:"ssl_info,omitempty"`
+	HTTPS   *nginx.HTTPS  `json:"https,omitempty"`
+	Config  string        `json:"config"`
+	Paths   *layout.Paths `json:"paths"`
 }
 
 type SSLInfo struct {
-	Enabled   bool   `json:"enabled"`
-	Issuer    string `json:"issuer,omitempty"`
-	ValidFrom string `json:"valid_from,omitempty"`
-	ValidTo   string `json:"valid_to,omitempty"`
-	CertPath  string `json:"cert_path,omitempty"`
-	KeyPath   string `json:"key_path,omitempty"`
+	Enabled    bool     `json:"enabled"`
+	Issuer     string   `json:"issuer,omitempty"`
+	ValidFrom  string   `json:"valid_from,omitempty"`
+	ValidTo    string   `json:"valid_to,omitempty"`
+	CertPath   string   `json:"cert_path,omitempty"`
+	KeyPath    string   `json:"key_path,omitempty"`
+	Names      []string `json:"names,omitempty"`
+	DaysLeft   int      `json:"days_left"`
+	ChainValid bool     `json:"chain_valid"`
+	KeyMatch   bool     `json:"key_match"`
+	Status     string   `json:"status,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
 }
 
 type CreateRequest struct {
-	Domain string `json:"domain"`
-	Type   string `json:"type"`
-	PHP    string `json:"php,omitempty"`
-	Port   int    `json:"port,omitempty"`
-	Target string `json:"target,omitempty"`
+	Domain string   `json:"domain"`
+	Type   string   `json:"type"`
+	PHP    string   `json:"php,omitempty"`
+	Port   int      `json:"port,omitempty"`
+	Target string   `json:"target,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// Aliases 别名，如 www 子域名
+	Aliases []string `json:"aliases,omitempty"`
+	// Command 应用站点（node / pm2 / python）的启动命令，为空时使用示例应用
+	Command string `json:"command,omitempty"`
+	// Compose Docker 站点的 compose 文件内容，为空时生成默认文件；反向代理指向其中映射的端口
+	Compose string `json:"compose,omitempty"`
+	// Params 模板参数，优先于上面的 PHP / Port / Target
+	Params map[string]interface{} `json:"params,omitempty"`
 }
 
 // 验证域名格式
@@ -60,7 +99,7 @@ func isValidDomain(domain string) bool {
 
 // 验证站点类型
 func isValidType(t string) bool {
-	validTypes := []string{"php", "static", "node", "python", "docker", "proxy"}
+	validTypes := []string{"php", "laravel", "static", "spa", "node", "pm2", "python", "docker", "proxy"}
 	for _, v := range validTypes {
 		if t == v || strings.HasPrefix(t, v+":") {
 			return true
@@ -69,52 +108,16 @@ func isValidType(t string) bool {
 	return false
 }
 
-// List 列出所有站点 - 直接读取 nginx 配置
+// List 列出所有站点
 func List(c *fiber.Ctx) error {
-	sites := []Site{}
-
-	files, err := os.ReadDir(nginxConfigDir)
+	records, err := models.ListSites()
 	if err != nil {
-		return c.JSON(fiber.Map{
-			"status": true,
-			"data":   sites,
-		})
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		name := file.Name()
-		// 跳过 default 和备份文件
-		if name == "default" || strings.HasSuffix(name, ".bak") {
-			continue
-		}
-
-		domain := strings.TrimSuffix(name, ".conf")
-		domain = strings.TrimSuffix(domain, ".disabled")
-
-		// 读取配置判断类型
-		configPath := filepath.Join(nginxConfigDir, name)
-		siteType := detectSiteType(configPath)
-
-		// 判断状态
-		status := "disabled"
-		enabledPath := filepath.Join(nginxEnabledDir, domain)
-		enabledPathConf := filepath.Join(nginxEnabledDir, domain+".conf")
-		if _, err := os.Lstat(enabledPath); err == nil {
-			status = "enabled"
-		} else if _, err := os.Lstat(enabledPathConf); err == nil {
-			status = "enabled"
-		}
-
-		sites = append(sites, Site{
-			Domain: domain,
-			Type:   siteType,
-			Status: status,
-			Path:   filepath.Join(sitesDir, domain),
-		})
+	sites := make([]Site, 0, len(records))
+	for _, r := range records {
+		sites = append(sites, siteFromRecord(r))
 	}
 
 	return c.JSON(fiber.Map{
@@ -123,26 +126,43 @@ func List(c *fiber.Ctx) error {
 	})
 }
 
-// detectSiteType 从 nginx 配置检测站点类型
-func detectSiteType(configPath string) string {
-	content, err := os.ReadFile(configPath)
-	if err != nil {
-		return "unknown"
+func siteFromRecord(r *models.Site) Site {
+	status := "disabled"
+	if r.Enabled {
+		status = "enabled"
 	}
+	return Site{
+		Domain:    r.Domain,
+		Type:      r.Type,
+		Status:    status,
+		Path:      filepath.Join(sitesDir, r.Domain),
+		PHP:       r.PHPVersion,
+		Port:      r.Port,
+		Target:    r.Target,
+		Root:      r.Root,
+		Tags:      r.Tags,
+		Aliases:   r.Aliases,
+		CreatedBy: r.CreatedBy,
+		CreatedAt: r.CreatedAt,
+	}
+}
 
-	configStr := string(content)
-
-	if strings.Contains(configStr, "fastcgi_pass") || strings.Contains(configStr, "php-fpm") {
-		return "php"
+// lookupSite 获取站点记录；配置存在但尚未登记的站点（CLI 新建）会被自动导入
+func lookupSite(domain string) (*models.Site, error) {
+	record, err := models.GetSite(domain)
+	if err != nil || record != nil {
+		return record, err
 	}
-	if strings.Contains(configStr, "proxy_pass") {
-		return "proxy"
+
+	p := layout.Resolve(domain)
+	if !p.Exists() {
+		return nil, nil
 	}
-	if strings.Contains(configStr, "uwsgi_pass") {
-		return "python"
+	record, err = inspectSite(p)
+	if err != nil {
+		return nil, err
 	}
-
-	return "static"
+	return record, models.CreateSite(record)
 }
 
 // Info 获取站点详情
@@ -152,25 +172,17 @@ func Info(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": false, "message": "域名不能为空"})
 	}
 
-	// 查找配置文件
-	configPath := filepath.Join(nginxConfigDir, domain)
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		configPath = filepath.Join(nginxConfigDir, domain+".conf")
-		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
-		}
+	record, err := lookupSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
 	}
 
 	// 读取配置
-	config, _ := os.ReadFile(configPath)
-
-	// 检测类型和状态
-	siteType := detectSiteType(configPath)
-	status := "disabled"
-	enabledPath := filepath.Join(nginxEnabledDir, domain)
-	if _, err := os.Lstat(enabledPath); err == nil {
-		status = "enabled"
-	}
+	paths := layout.Resolve(domain)
+	config, _ := os.ReadFile(paths.Config)
 
 	// 计算目录大小
 	sitePath := filepath.Join(sitesDir, domain)
@@ -184,16 +196,13 @@ func Info(c *fiber.Ctx) error {
 	}
 
 	detail := SiteDetail{
-		Site: Site{
-			Domain: domain,
-			Type:   siteType,
-			Status: status,
-			Path:   sitePath,
-		},
+		Site:    siteFromRecord(record),
 		Size:    size,
 		SSL:     sslStatus,
 		SSLInfo: sslInfo,
+		HTTPS:   siteHTTPS(record),
 		Config:  string(config),
+		Paths:   paths,
 	}
 
 	return c.JSON(fiber.Map{
@@ -217,37 +226,33 @@ func getDirSize(path string) string {
 
 func getSSLInfo(config string, domain string) *SSLInfo {
 	// 从配置中提取证书路径
-	certRe := regexp.MustCompile(`ssl_certificate\s+([^;]+);`)
-	keyRe := regexp.MustCompile(`ssl_certificate_key\s+([^;]+);`)
-
-	certMatch := certRe.FindStringSubmatch(config)
-	keyMatch := keyRe.FindStringSubmatch(config)
-
-	if len(certMatch) < 2 || len(keyMatch) < 2 {
+	cfg, err := nginx.Parse(config)
+	if err != nil {
+		return nil
+	}
+	certPath := directiveArg(cfg, "ssl_certificate")
+	keyPath := directiveArg(cfg, "ssl_certificate_key")
+	if certPath == "" || keyPath == "" {
 		return nil
 	}
 
-	certPath := strings.TrimSpace(certMatch[1])
-	keyPath := strings.TrimSpace(keyMatch[1])
-
+	// 解析完整证书链，检查私钥是否匹配
+	cert := ssl.Inspect(certPath, keyPath)
 	info := &SSLInfo{
-		Enabled:  true,
-		CertPath: certPath,
-		KeyPath:  keyPath,
-	}
-
-	// 解析证书获取详细信息
-	certData, err := os.ReadFile(certPath)
-	if err == nil {
-		block, _ := pem.Decode(certData)
-		if block != nil {
-			cert, err := x509.ParseCertificate(block.Bytes)
-			if err == nil {
-				info.Issuer = cert.Issuer.CommonName
-				info.ValidFrom = cert.NotBefore.Format("2006-01-02")
-				info.ValidTo = cert.NotAfter.Format("2006-01-02")
-			}
-		}
+		Enabled:    true,
+		CertPath:   certPath,
+		KeyPath:    keyPath,
+		Issuer:     cert.Issuer,
+		Names:      cert.Names,
+		DaysLeft:   cert.DaysLeft,
+		ChainValid: cert.ChainValid,
+		KeyMatch:   cert.KeyMatch,
+		Status:     cert.Status,
+		Errors:     cert.Errors,
+	}
+	if !cert.NotAfter.IsZero() {
+		info.ValidFrom = cert.NotBefore.Format("2006-01-02")
+		info.ValidTo = cert.NotAfter.Format("2006-01-02")
 	}
 
 	return info
@@ -271,16 +276,57 @@ func Create(c *fiber.Ctx) error {
 	if !isValidType(req.Type) {
 		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的站点类型"})
 	}
+	req.Type, req.PHP = splitType(req.Type, req.PHP)
+	compose, err := prepareCompose(&req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	if err := process.CheckProgram(process.Program{Command: req.Command}); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
 
 	// 检查是否已存在
-	configPath := filepath.Join(nginxConfigDir, req.Domain)
-	if _, err := os.Stat(configPath); err == nil {
+	existing, err := models.GetSite(req.Domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if existing != nil || layout.Resolve(req.Domain).Exists() {
 		return c.Status(400).JSON(fiber.Map{"status": false, "message": "站点已存在"})
 	}
+	// 不能是其他站点的别名或跳转域名
+	if err := checkNewDomain(&models.Site{}, req.Domain); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	// 先渲染配置，参数有误时不创建任何文件
+	paths := layout.For(layout.Canonical, req.Domain)
+	record := &models.Site{
+		Domain:   req.Domain,
+		Type:     req.Type,
+		Root:     siteRoot(req.Domain, req.Type),
+		Tags:     normalizeTags(req.Tags),
+		Template: req.Type,
+		Aliases:  []string{},
+		Enabled:  true,
+	}
+	for _, alias := range req.Aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if err := checkNewDomain(record, alias); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		record.Aliases = append(record.Aliases, alias)
+	}
+	record.CreatedBy, _ = c.Locals("username").(string)
+	nginxConfig, err := renderSite(record, paths, requestParams(req), time.Now())
+	if err != nil {
+		return templateError(c, err)
+	}
 
 	// 创建站点目录
 	sitePath := filepath.Join(sitesDir, req.Domain)
-	if req.Type == "php" {
+	_, statErr := os.Stat(sitePath)
+	created := os.IsNotExist(statErr)
+	if req.Type == "php" || req.Type == "laravel" {
 		os.MkdirAll(filepath.Join(sitePath, "public"), 0755)
 		// 创建默认 index.php
 		indexContent := `<?php
@@ -302,113 +348,54 @@ phpinfo();
 	// 设置权限
 	exec.Command("chown", "-R", "www:www", sitePath).Run()
 
-	// 生成 nginx 配置
-	nginxConfig := generateNginxConfig(req)
-	if err := os.WriteFile(configPath, []byte(nginxConfig), 0644); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": false, "message": "创建配置失败"})
+	// 创建日志目录
+	os.MkdirAll(filepath.Dir(paths.AccessLog), 0755)
+
+	// 写入并启用 nginx 配置（新站点统一使用规范布局），验证失败时不留下配置和新建的目录
+	tx := nginx.Begin()
+	tx.WriteFile(paths.Config, []byte(nginxConfig))
+	tx.Symlink(paths.Config, paths.Enabled)
+	if err := tx.Commit(); err != nil {
+		if created {
+			os.RemoveAll(sitePath)
+		}
+		return applyError(c, err)
 	}
+	recordRevision(c, req.Domain, nginxConfig, "创建站点", true)
 
-	// 启用站点
-	enabledPath := filepath.Join(nginxEnabledDir, req.Domain)
-	os.Symlink(configPath, enabledPath)
-
-	// 创建日志目录
-	os.MkdirAll(logsDir, 0755)
+	// 登记站点
+	if err := models.CreateSite(record); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "登记站点失败: " + err.Error()})
+	}
 
-	// 重载 nginx
-	exec.Command("nginx", "-t").Run()
-	exec.Command("systemctl", "reload", "nginx").Run()
+	// 应用站点创建并启动进程；失败时站点照常创建，可在站点详情中重试
+	message := "站点创建成功"
+	if compose != nil {
+		if err := writeCompose(record, compose, req.Compose == ""); err != nil {
+			message = "站点创建成功，但写入 compose 文件失败: " + err.Error()
+		} else {
+			message = "站点创建成功，启动容器后即可访问"
+		}
+	}
+	if err := createProcess(record, req.Command); err != nil {
+		log.Printf("[site] 创建 %s 应用进程失败: %v", req.Domain, err)
+		message = "站点创建成功，但应用进程启动失败: " + err.Error()
+	}
 
 	return c.JSON(fiber.Map{
 		"status":  true,
-		"message": "站点创建成功",
-		"data": Site{
-			Domain: req.Domain,
-			Type:   req.Type,
-			Status: "enabled",
-			Path:   sitePath,
-		},
+		"message": message,
+		"data":    siteFromRecord(record),
 	})
 }
 
-func generateNginxConfig(req CreateRequest) string {
-	sitePath := filepath.Join(sitesDir, req.Domain)
-	root := sitePath
-	if req.Type == "php" {
-		root = filepath.Join(sitePath, "public")
-	}
-
-	config := fmt.Sprintf(`# Site Manager managed - %s
-# Type: %s
-# Created: %s
-
-server {
-    listen 80;
-    server_name %s;
-    root %s;
-    index index.php index.html index.htm;
-
-    access_log %s/%s_access.log;
-    error_log %s/%s_error.log;
-
-    add_header X-Frame-Options "SAMEORIGIN" always;
-    add_header X-Content-Type-Options "nosniff" always;
-
-`, req.Domain, req.Type, time.Now().Format("2006-01-02"), req.Domain, root, logsDir, req.Domain, logsDir, req.Domain)
-
-	if req.Type == "php" {
-		phpVersion := "8.3"
-		if req.PHP != "" {
-			phpVersion = req.PHP
-		}
-		config += fmt.Sprintf(`    location / {
-        try_files $uri $uri/ /index.php?$query_string;
-    }
-
-    location ~ \.php$ {
-        fastcgi_pass unix:/run/php/php%s-fpm.sock;
-        fastcgi_index index.php;
-        fastcgi_param SCRIPT_FILENAME $document_root$fastcgi_script_name;
-        include fastcgi_params;
-        fastcgi_read_timeout 300;
-    }
-
-    location ~ /\.(?!well-known).* {
-        deny all;
-    }
-}
-`, phpVersion)
-	} else if req.Type == "proxy" {
-		target := req.Target
-		if target == "" {
-			target = fmt.Sprintf("http://127.0.0.1:%d", req.Port)
-		}
-		config += fmt.Sprintf(`    location / {
-        proxy_pass %s;
-        proxy_http_version 1.1;
-        proxy_set_header Upgrade $http_upgrade;
-        proxy_set_header Connection "upgrade";
-        proxy_set_header Host $host;
-        proxy_set_header X-Real-IP $remote_addr;
-        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        proxy_set_header X-Forwarded-Proto $scheme;
-    }
-}
-`, target)
-	} else {
-		config += `    location / {
-        try_files $uri $uri/ /index.html;
-    }
-
-    location ~* \.(js|css|png|jpg|jpeg|gif|ico|svg|woff|woff2)$ {
-        expires 30d;
-        add_header Cache-Control "public, immutable";
-    }
-}
-`
+// siteRoot 站点的 nginx root 目录，PHP / Laravel 站点使用 public 子目录
+func siteRoot(domain, siteType string) string {
+	sitePath := filepath.Join(sitesDir, domain)
+	if siteType == "php" || siteType == "laravel" {
+		return filepath.Join(sitePath, "public")
 	}
-
-	return config
+	return sitePath
 }
 
 // Delete 删除站点
@@ -418,17 +405,27 @@ func Delete(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": false, "message": "域名不能为空"})
 	}
 
-	// 删除 nginx 配置
-	configPath := filepath.Join(nginxConfigDir, domain)
-	enabledPath := filepath.Join(nginxEnabledDir, domain)
+	record, err := models.GetSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
 
-	os.Remove(enabledPath)
-	os.Remove(configPath)
-	os.Remove(configPath + ".conf")
-	os.Remove(filepath.Join(nginxEnabledDir, domain+".conf"))
+	// 删除 nginx 配置并重载
+	tx := nginx.Begin()
+	layout.Remove(tx, domain)
+	if err := tx.Commit(); err != nil {
+		return applyError(c, err)
+	}
+	if record != nil {
+		controlProcess(record, "remove")
+	}
 
-	// 重载 nginx
-	exec.Command("systemctl", "reload", "nginx").Run()
+	if err := models.DeleteSite(domain); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "删除站点记录失败"})
+	}
+	if err := models.DeleteConfigRevisions(domain); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "删除配置历史失败"})
+	}
 
 	// 可选：删除站点文件（危险操作，暂时保留文件）
 	// sitePath := filepath.Join(sitesDir, domain)
@@ -447,18 +444,33 @@ func Enable(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": false, "message": "域名不能为空"})
 	}
 
-	configPath := filepath.Join(nginxConfigDir, domain)
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+	paths := layout.Resolve(domain)
+	if !paths.Exists() {
 		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
 	}
 
-	enabledPath := filepath.Join(nginxEnabledDir, domain)
-	os.Remove(enabledPath) // 先删除可能存在的
-	if err := os.Symlink(configPath, enabledPath); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": false, "message": "启用失败"})
+	tx := nginx.Begin()
+
+	// CLI 禁用的站点先去掉 .disabled 后缀
+	configPath := paths.ActiveConfig()
+	if configPath != paths.Config {
+		if err := tx.Rename(paths.Config, configPath); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": false, "message": "启用失败"})
+		}
 	}
 
-	exec.Command("systemctl", "reload", "nginx").Run()
+	layout.Unlink(tx, domain) // 先删除可能存在的
+	tx.Symlink(configPath, paths.Enabled)
+	if err := tx.Commit(); err != nil {
+		return applyError(c, err)
+	}
+
+	if err := syncEnabled(domain, true); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "更新站点状态失败"})
+	}
+	if record, _ := models.GetSite(domain); record != nil {
+		controlProcess(record, "start")
+	}
 
 	return c.JSON(fiber.Map{"status": true, "message": "站点已启用"})
 }
@@ -470,16 +482,32 @@ func Disable(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": false, "message": "域名不能为空"})
 	}
 
-	enabledPath := filepath.Join(nginxEnabledDir, domain)
-	os.Remove(enabledPath)
-	os.Remove(filepath.Join(nginxEnabledDir, domain+".conf"))
+	tx := nginx.Begin()
+	layout.Unlink(tx, domain)
+	if err := tx.Commit(); err != nil {
+		return applyError(c, err)
+	}
 
-	exec.Command("systemctl", "reload", "nginx").Run()
+	if err := syncEnabled(domain, false); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "更新站点状态失败"})
+	}
+	if record, _ := models.GetSite(domain); record != nil {
+		controlProcess(record, "stop")
+	}
 
 	return c.JSON(fiber.Map{"status": true, "message": "站点已禁用"})
 }
 
-// Backup 备份站点
+// syncEnabled 更新站点表中的启用状态，未登记的站点先导入
+func syncEnabled(domain string, enabled bool) error {
+	record, err := lookupSite(domain)
+	if err != nil || record == nil {
+		return err
+	}
+	return models.SetSiteEnabled(domain, enabled)
+}
+
+// Backup 备份站点（写入备份目录 site/，与 backup_cron.sh 共用保留规则）
 func Backup(c *fiber.Ctx) error {
 	domain := c.Params("domain")
 	if domain == "" {
@@ -491,22 +519,16 @@ func Backup(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点目录不存在"})
 	}
 
-	backupDir := "/www/backup"
-	os.MkdirAll(backupDir, 0755)
-
-	timestamp := time.Now().Format("20060102_150405")
-	backupFile := filepath.Join(backupDir, fmt.Sprintf("%s_%s.tar.gz", domain, timestamp))
-
-	cmd := exec.Command("tar", "-czf", backupFile, "-C", sitesDir, domain)
-	if err := cmd.Run(); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": false, "message": "备份失败"})
+	entry, err := backupManager.BackupSite(domain, 0)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "备份失败: " + err.Error()})
 	}
 
 	return c.JSON(fiber.Map{
 		"status":  true,
 		"message": "备份成功",
 		"data": fiber.Map{
-			"file": backupFile,
+			"file": entry.Path,
 		},
 	})
 }
@@ -514,13 +536,8 @@ func Backup(c *fiber.Ctx) error {
 // GetNginxConfig 获取 Nginx 配置
 func GetNginxConfig(c *fiber.Ctx) error {
 	domain := c.Params("domain")
-	configPath := filepath.Join(nginxConfigDir, domain)
-
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		configPath = filepath.Join(nginxConfigDir, domain+".conf")
-	}
 
-	config, err := os.ReadFile(configPath)
+	config, err := os.ReadFile(layout.Resolve(domain).Config)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"status": false, "message": "配置不存在"})
 	}
@@ -536,36 +553,30 @@ func SaveNginxConfig(c *fiber.Ctx) error {
 	domain := c.Params("domain")
 
 	var req struct {
-		Config string `json:"config"`
+		Config  string `json:"config"`
+		Comment string `json:"comment"` // 版本说明
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
 	}
 
-	configPath := filepath.Join(nginxConfigDir, domain)
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		configPath = filepath.Join(nginxConfigDir, domain+".conf")
+	paths := layout.Resolve(domain)
+	if !paths.Exists() {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "配置不存在"})
 	}
 
-	// 先测试配置
-	tmpFile := "/tmp/nginx_test_" + domain
-	os.WriteFile(tmpFile, []byte(req.Config), 0644)
-	defer os.Remove(tmpFile)
-
-	// 保存配置
-	if err := os.WriteFile(configPath, []byte(req.Config), 0644); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存失败"})
+	if _, err := nginx.Parse(req.Config); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "配置语法错误: " + err.Error()})
 	}
 
-	// 测试并重载
-	if err := exec.Command("nginx", "-t").Run(); err != nil {
-		return c.JSON(fiber.Map{
-			"status":  true,
-			"message": "配置已保存，但语法可能有误，请检查",
-		})
+	if req.Comment == "" {
+		req.Comment = "编辑配置"
 	}
 
-	exec.Command("systemctl", "reload", "nginx").Run()
+	// 验证通过才替换并重载，否则原配置保持不变
+	if err := applyConfig(c, paths, req.Config, req.Comment); err != nil {
+		return applyError(c, err)
+	}
 
 	return c.JSON(fiber.Map{"status": true, "message": "配置已保存并重载"})
 }
@@ -576,12 +587,7 @@ func GetLogs(c *fiber.Ctx) error {
 	logType := c.Query("type", "access")
 	lines := c.QueryInt("lines", 100)
 
-	var logFile string
-	if logType == "error" {
-		logFile = filepath.Join(logsDir, domain+"_error.log")
-	} else {
-		logFile = filepath.Join(logsDir, domain+"_access.log")
-	}
+	logFile := layout.Resolve(domain).LogFile(logType)
 
 	cmd := exec.Command("tail", "-n", fmt.Sprintf("%d", lines), logFile)
 	output, err := cmd.Output()
@@ -597,44 +603,3 @@ func GetLogs(c *fiber.Ctx) error {
 		"data":   string(output),
 	})
 }
-
-// RequestSSL 申请 SSL 证书
-func RequestSSL(c *fiber.Ctx) error {
-	domain := c.Params("domain")
-	if domain == "" {
-		return c.Status(400).JSON(fiber.Map{"status": false, "message": "域名不能为空"})
-	}
-
-	// 使用 certbot 申请证书
-	cmd := exec.Command("certbot", "certonly", "--nginx", "-d", domain, "--non-interactive", "--agree-tos", "--email", "admin@"+domain)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  false,
-			"message": "SSL 申请失败: " + string(output),
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"status":  true,
-		"message": "SSL 证书申请成功",
-	})
-}
-
-// RenewSSL 续期 SSL 证书
-func RenewSSL(c *fiber.Ctx) error {
-	cmd := exec.Command("certbot", "renew")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  false,
-			"message": "续期失败: " + string(output),
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"status":  true,
-		"message": "证书续期完成",
-		"data":    string(output),
-	})
-}
@@ -0,0 +1,167 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+)
+
+var includeRe = regexp.MustCompile(`include\s+"?([^";]+)"?;`)
+
+// failOn 代替 nginx -t：临时配置树中任一站点配置包含 marker 时报错
+func failOn(marker string) func(string) error {
+	return func(conf string) error {
+		main, err := os.ReadFile(conf)
+		if err != nil {
+			return err
+		}
+		for _, m := range includeRe.FindAllStringSubmatch(string(main), -1) {
+			files, _ := filepath.Glob(m[1])
+			for _, f := range files {
+				data, _ := os.ReadFile(f)
+				if strings.Contains(string(data), marker) {
+					return fmt.Errorf("nginx: [emerg] unknown directive %q in %s:3\nnginx: configuration file %s test failed", marker, f, conf)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func jsonBody(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func newHandlerApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/sites", Create)
+	app.Delete("/sites/:domain", Delete)
+	app.Post("/sites/:domain/enable", Enable)
+	app.Post("/sites/:domain/disable", Disable)
+	app.Put("/sites/:domain/nginx", SaveNginxConfig)
+	return newTestClient(t, app)
+}
+
+func TestSaveNginxConfig(t *testing.T) {
+	setupDirs(t)
+	do := newHandlerApp(t)
+	p := layout.For(layout.CLI, "app.example.com")
+	writeFile(t, p.Config, proxyConfig("app.example.com", "http://127.0.0.1:3000"))
+	os.Symlink(p.Config, p.Enabled)
+	original := readConfig(t, "app.example.com")
+
+	nginx.Test = failOn("bad_directive")
+	reloads := 0
+	nginx.Reload = func() error {
+		reloads++
+		return nil
+	}
+
+	broken := strings.Replace(original, "listen 80;", "listen 80;\n    bad_directive on;", 1)
+	status, result := do("PUT", "/sites/app.example.com/nginx", jsonBody(t, fiber.Map{"config": broken}))
+	if status != 400 {
+		t.Fatalf("Expected 400, got %d %+v", status, result)
+	}
+	if len(result.Output) != 1 || result.Output[0] != `nginx: [emerg] unknown directive "bad_directive" in `+p.Enabled+":3" {
+		t.Errorf("Expected nginx error lines with real paths, got %q", result.Output)
+	}
+	if readConfig(t, "app.example.com") != original || reloads != 0 {
+		t.Error("Failed save must keep the original config and skip reload")
+	}
+
+	if status, _ := do("PUT", "/sites/app.example.com/nginx", jsonBody(t, fiber.Map{"config": "server {\n"})); status != 400 {
+		t.Errorf("Expected 400 for syntax error, got %d", status)
+	}
+
+	updated := strings.Replace(original, "3000", "4000", 1)
+	if status, result := do("PUT", "/sites/app.example.com/nginx", jsonBody(t, fiber.Map{"config": updated})); status != 200 {
+		t.Fatalf("Save failed: %d %+v", status, result)
+	}
+	if readConfig(t, "app.example.com") != updated || reloads != 1 {
+		t.Error("Expected config to be replaced and nginx reloaded")
+	}
+}
+
+func TestEnableDisableTransaction(t *testing.T) {
+	setupDirs(t)
+	do := newHandlerApp(t)
+
+	// CLI 禁用的站点：配置带 .disabled 后缀且无链接
+	p := layout.For(layout.CLI, "app.example.com")
+	writeFile(t, p.Config+".disabled", proxyConfig("app.example.com", "http://127.0.0.1:3000")+"# bad_directive\n")
+
+	nginx.Test = failOn("bad_directive")
+	if status, _ := do("POST", "/sites/app.example.com/enable", ""); status != 400 {
+		t.Fatalf("Expected enable to fail validation, got %d", status)
+	}
+	if _, err := os.Stat(p.Config + ".disabled"); err != nil {
+		t.Error("Failed enable must keep the disabled config")
+	}
+	if _, err := os.Lstat(p.Enabled); !os.IsNotExist(err) {
+		t.Error("Failed enable must not create the link")
+	}
+
+	nginx.Test = func(string) error { return nil }
+	if status, result := do("POST", "/sites/app.example.com/enable", ""); status != 200 {
+		t.Fatalf("Enable failed: %d %+v", status, result)
+	}
+	if target, _ := os.Readlink(p.Enabled); target != p.Config {
+		t.Errorf("Expected link to %s, got %q", p.Config, target)
+	}
+	if record, _ := models.GetSite("app.example.com"); record == nil || !record.Enabled {
+		t.Errorf("Expected site to be enabled, got %+v", record)
+	}
+
+	if status, _ := do("POST", "/sites/app.example.com/disable", ""); status != 200 {
+		t.Fatal("Disable failed")
+	}
+	if _, err := os.Lstat(p.Enabled); !os.IsNotExist(err) {
+		t.Error("Expected link to be removed")
+	}
+	if record, _ := models.GetSite("app.example.com"); record.Enabled {
+		t.Error("Expected site to be disabled")
+	}
+
+	if status, _ := do("DELETE", "/sites/app.example.com", ""); status != 200 {
+		t.Fatal("Delete failed")
+	}
+	if layout.Resolve("app.example.com").Exists() {
+		t.Error("Expected config to be removed")
+	}
+}
+
+func TestCreateRollback(t *testing.T) {
+	setupDirs(t)
+	do := newHandlerApp(t)
+	nginx.Test = failOn("proxy_pass")
+
+	status, result := do("POST", "/sites", `{"domain":"api.example.com","type":"node","port":3000}`)
+	if status != 400 || !strings.Contains(result.Message, "proxy_pass") {
+		t.Fatalf("Expected validation failure, got %d %+v", status, result)
+	}
+	if layout.Resolve("api.example.com").Exists() {
+		t.Error("Failed create must not leave a config")
+	}
+	if _, err := os.Stat(filepath.Join(sitesDir, "api.example.com")); !os.IsNotExist(err) {
+		t.Error("Failed create must remove the new site directory")
+	}
+	if record, _ := models.GetSite("api.example.com"); record != nil {
+		t.Error("Failed create must not register the site")
+	}
+}
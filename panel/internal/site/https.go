@@ -0,0 +1,95 @@
+package site
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+)
+
+// siteHTTPS 站点当前的 HTTPS 设置（未保存的参数取模板默认值）；站点类型没有模板时返回 nil
+func siteHTTPS(record *models.Site) *nginx.HTTPS {
+	t, err := siteTemplate(record)
+	if err != nil || !t.Declares("force_https") {
+		return nil
+	}
+	values, err := t.Validate(storedParams(record, t))
+	if err != nil {
+		return nil
+	}
+	https := nginx.HTTPSFromParams(values)
+	return &https
+}
+
+// GetHTTPS 获取站点的 HTTPS 设置和可选的 TLS 档位
+func GetHTTPS(c *fiber.Ctx) error {
+	record, err := lookupSite(c.Params("domain"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	https := siteHTTPS(record)
+	if https == nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点类型没有可用的模板"})
+	}
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   fiber.Map{"https": https, "profiles": nginx.TLSProfiles},
+	})
+}
+
+// SetHTTPS 保存 HTTPS 设置，并用站点模板重新生成配置（强制跳转、HSTS、TLS 档位、OCSP Stapling）；
+// 站点须已配置证书，配置在上次生成后被修改过时返回 409。配置通过事务写入，nginx -t 失败时原配置不变
+func SetHTTPS(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	var req nginx.HTTPS
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	record, err := lookupSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	paths := layout.Resolve(domain)
+	if record == nil || !paths.Exists() {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+
+	t, err := siteTemplate(record)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点类型没有可用的模板"})
+	}
+
+	fillCertificate(record, paths)
+	if record.SSLCert == "" || record.SSLKey == "" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "站点未配置证书，请先申请或上传证书"})
+	}
+	if !rendered(paths) {
+		return modifiedError(c)
+	}
+
+	params := storedParams(record, t)
+	for k, v := range req.Params() {
+		params[k] = v
+	}
+	config, err := renderSite(record, paths, params, record.CreatedAt)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if err := applyRendered(c, paths, config, "更新 HTTPS 设置"); err != nil {
+		return applyError(c, err)
+	}
+	if err := record.Update(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存站点参数失败"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "HTTPS 设置已保存",
+		"data":    fiber.Map{"https": nginx.HTTPSFromParams(record.Params), "config": config},
+	})
+}
@@ -0,0 +1,137 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/ssl"
+)
+
+func newHTTPSApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	app.Get("/sites/:domain", Info)
+	app.Post("/sites/:domain/ssl/upload", UploadSSL)
+	app.Get("/sites/:domain/https", GetHTTPS)
+	app.Put("/sites/:domain/https", SetHTTPS)
+	app.Post("/sites/:domain/render", Rerender)
+	app.Put("/sites/:domain/nginx/client-max-body-size", SetClientMaxBodySize)
+	return newTestClient(t, app)
+}
+
+func TestSetHTTPS(t *testing.T) {
+	root := setupDirs(t)
+	setupSSL(t, root)
+	do := newHTTPSApp(t)
+
+	p := layout.For(layout.CLI, "app.example.com")
+	writeFile(t, p.Config, proxyConfig("app.example.com", "http://127.0.0.1:3000"))
+	os.Symlink(p.Config, p.Enabled)
+
+	// 没有证书时不能开启
+	if status, result := do("PUT", "/sites/app.example.com/https", `{"force_https":true}`); status != 400 || !strings.Contains(result.Message, "未配置证书") {
+		t.Fatalf("Expected 400 without certificate, got %d %+v", status, result)
+	}
+
+	cert, key := selfSigned(t, "app.example.com")
+	if status, result := do("POST", "/sites/app.example.com/ssl/upload", jsonBody(t, fiber.Map{"certificate": cert, "private_key": key})); status != 200 {
+		t.Fatalf("UploadSSL failed: %d %+v", status, result)
+	}
+
+	// CLI 生成的配置没有由面板模板生成过，重新生成前须先确认
+	before := readConfig(t, "app.example.com")
+	if status, result := do("PUT", "/sites/app.example.com/https", `{"force_https":true}`); status != 409 {
+		t.Fatalf("Expected 409 for config not rendered by the panel, got %d %+v", status, result)
+	}
+	if readConfig(t, "app.example.com") != before {
+		t.Fatal("Expected config unchanged after 409")
+	}
+	if status, result := do("POST", "/sites/app.example.com/render", ""); status != 200 {
+		t.Fatalf("Rerender failed: %d %+v", status, result)
+	}
+
+	var settings struct {
+		HTTPS    nginx.HTTPS                 `json:"https"`
+		Profiles map[string]nginx.TLSProfile `json:"profiles"`
+	}
+	status, result := do("GET", "/sites/app.example.com/https", "")
+	json.Unmarshal(result.Data, &settings)
+	if status != 200 || settings.HTTPS.ForceHTTPS || settings.HTTPS.TLSProfile != nginx.TLSIntermediate || len(settings.Profiles) != 3 {
+		t.Fatalf("Unexpected defaults: %d %+v", status, settings)
+	}
+
+	// preload 的前置条件不满足时逐项报错
+	status, result = do("PUT", "/sites/app.example.com/https", `{"hsts":true,"hsts_preload":true}`)
+	if status != 400 || len(result.Errors) != 1 {
+		t.Fatalf("Expected preload validation error, got %d %+v", status, result)
+	}
+
+	body := `{"force_https":true,"hsts":true,"hsts_subdomains":true,"hsts_preload":true,"tls_profile":"modern","ocsp_stapling":true}`
+	if status, result := do("PUT", "/sites/app.example.com/https", body); status != 200 {
+		t.Fatalf("SetHTTPS failed: %d %+v", status, result)
+	}
+	config := readConfig(t, "app.example.com")
+	for _, want := range []string{
+		"server {\n    listen 80;\n    server_name app.example.com;\n    return 301 https://$host$request_uri;\n}",
+		"server {\n    listen 443 ssl http2;\n    server_name app.example.com;",
+		"ssl_certificate " + ssl.CertPath("app.example.com") + ";",
+		"ssl_protocols TLSv1.3;",
+		"ssl_stapling on;",
+		`add_header Strict-Transport-Security "max-age=31536000; includeSubDomains; preload" always;`,
+		"proxy_pass http://127.0.0.1:3000;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("Expected %q in config:\n%s", want, config)
+		}
+	}
+	if revisions, _ := models.ListConfigRevisions("app.example.com"); len(revisions) == 0 || revisions[0].Comment != "更新 HTTPS 设置" {
+		t.Errorf("Expected HTTPS revision, got %+v", revisions)
+	}
+
+	var detail SiteDetail
+	_, result = do("GET", "/sites/app.example.com", "")
+	json.Unmarshal(result.Data, &detail)
+	if detail.HTTPS == nil || !detail.HTTPS.ForceHTTPS || !detail.HTTPS.HSTSPreload || detail.HTTPS.TLSProfile != nginx.TLSModern {
+		t.Errorf("Expected HTTPS settings in site detail, got %+v", detail.HTTPS)
+	}
+
+	// nginx -t 失败时配置和参数不变
+	nginx.Test = func(string) error { return errors.New("nginx: [emerg] invalid ssl_protocols") }
+	if status, _ := do("PUT", "/sites/app.example.com/https", `{"tls_profile":"legacy"}`); status != 400 {
+		t.Errorf("Expected nginx test failure, got %d", status)
+	}
+	if readConfig(t, "app.example.com") != config {
+		t.Error("Expected original config to be kept")
+	}
+	if record, _ := models.GetSite("app.example.com"); record.Params["tls_profile"] != nginx.TLSModern {
+		t.Errorf("Expected stored params unchanged, got %v", record.Params)
+	}
+	nginx.Test = func(string) error { return nil }
+
+	// 结构化修改后重新生成会覆盖修改，拒绝执行
+	if status, result := do("PUT", "/sites/app.example.com/nginx/client-max-body-size", `{"value":"64m"}`); status != 200 {
+		t.Fatalf("SetClientMaxBodySize failed: %d %+v", status, result)
+	}
+	if status, result := do("PUT", "/sites/app.example.com/https", `{"tls_profile":"legacy"}`); status != 409 {
+		t.Errorf("Expected 409 after structured edit, got %d %+v", status, result)
+	}
+	if config := readConfig(t, "app.example.com"); !strings.Contains(config, "client_max_body_size 64m;") {
+		t.Errorf("Expected structured edit to be kept:\n%s", config)
+	}
+
+	if status, _ := do("PUT", "/sites/missing.example.com/https", "{}"); status != 404 {
+		t.Errorf("Expected 404 for missing site, got %d", status)
+	}
+}
@@ -0,0 +1,91 @@
+package site
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/php"
+)
+
+// defaultPHPVersion 新建 PHP 站点默认使用的版本：服务器上正在运行的最新版本，没有发现任何版本时使用模板默认值
+func defaultPHPVersion() string {
+	if v := php.Default(); v != "" {
+		return v
+	}
+	return nginx.DefaultPHPVersion
+}
+
+// GetPHP 获取站点使用的 PHP 版本和服务器上可切换的版本
+func GetPHP(c *fiber.Ctx) error {
+	record, err := lookupSite(c.Params("domain"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	return c.JSON(fiber.Map{"status": true, "data": fiber.Map{
+		"php_version": record.PHPVersion,
+		"versions":    php.Versions(),
+	}})
+}
+
+// SetPHPVersion 切换站点的 PHP 版本。目标版本的 FPM 必须正在运行；只修改配置中的
+// fastcgi_pass socket，其余手工或结构化修改保持不变。配置通过事务写入并在 nginx -t 通过后重载，失败时原配置不变
+func SetPHPVersion(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	var req struct {
+		Version string `json:"version"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	record, err := lookupSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	paths, cfg, err := loadConfig(c)
+	if cfg == nil {
+		return err
+	}
+
+	v := php.Find(req.Version)
+	if v == nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": fmt.Sprintf("PHP %s 未安装", req.Version)})
+	}
+	if !v.Running {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": fmt.Sprintf("PHP %s 未运行，请先启动 %s", v.Version, v.Service)})
+	}
+	if err := cfg.SetPHPSocket(php.SocketPath(v.Version)); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点不使用 PHP"})
+	}
+	previous := record.PHPVersion
+	if previous == v.Version {
+		return c.JSON(fiber.Map{"status": true, "message": "PHP 版本未变化", "data": fiber.Map{"php_version": previous}})
+	}
+
+	config := cfg.String()
+	if err := applyManaged(c, paths, config, fmt.Sprintf("切换 PHP 版本 %s -> %s", previous, v.Version)); err != nil {
+		return applyError(c, err)
+	}
+	// 没有保存模板参数的站点重新生成时从 PHPVersion 取值
+	record.PHPVersion = v.Version
+	if record.Params != nil {
+		record.Params["php_version"] = v.Version
+	}
+	if err := record.Update(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存站点参数失败"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "PHP 版本已切换为 " + v.Version,
+		"data":    fiber.Map{"php_version": record.PHPVersion, "config": config},
+	})
+}
@@ -0,0 +1,112 @@
+package site
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/php"
+)
+
+// installPHP 在临时目录中模拟已安装的 PHP-FPM 版本，running 为 true 时创建 socket
+func installPHP(t *testing.T, version string, running bool) {
+	t.Helper()
+	os.MkdirAll(filepath.Join(php.ConfigDir, version, "fpm"), 0755)
+	if !running {
+		return
+	}
+	os.MkdirAll(php.RunDir, 0755)
+	ln, err := net.Listen("unix", filepath.Join(php.RunDir, "php"+version+"-fpm.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+}
+
+func newPHPApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	app.Post("/sites", Create)
+	app.Get("/sites/:domain/php", GetPHP)
+	app.Put("/sites/:domain/php", SetPHPVersion)
+	return newTestClient(t, app)
+}
+
+func TestSitePHPVersion(t *testing.T) {
+	setupDirs(t)
+	installPHP(t, "8.1", true)
+	installPHP(t, "8.2", true)
+	installPHP(t, "8.4", false)
+	do := newPHPApp(t)
+
+	// 未指定版本时使用正在运行的最新版本
+	if status, result := do("POST", "/sites", `{"domain":"blog.example.com","type":"php"}`); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	if config := readConfig(t, "blog.example.com"); !strings.Contains(config, "fastcgi_pass unix:/run/php/php8.2-fpm.sock;") {
+		t.Fatalf("Expected discovered default version:\n%s", config)
+	}
+	if status, result := do("POST", "/sites", `{"domain":"static.example.com","type":"static"}`); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+
+	var data struct {
+		PHPVersion string        `json:"php_version"`
+		Versions   []php.Version `json:"versions"`
+	}
+	status, result := do("GET", "/sites/blog.example.com/php", "")
+	json.Unmarshal(result.Data, &data)
+	if status != 200 || data.PHPVersion != "8.2" || len(data.Versions) != 3 || data.Versions[0].Version != "8.4" || data.Versions[0].Running {
+		t.Fatalf("Unexpected php info: %d %+v", status, data)
+	}
+
+	for _, tt := range []struct {
+		domain, body string
+		want         string
+	}{
+		{"blog.example.com", `{"version":"7.4"}`, "未安装"},
+		{"blog.example.com", `{"version":"8.4"}`, "未运行"},
+		{"static.example.com", `{"version":"8.1"}`, "不使用 PHP"},
+	} {
+		if status, result := do("PUT", "/sites/"+tt.domain+"/php", tt.body); status != 400 || !strings.Contains(result.Message, tt.want) {
+			t.Errorf("%s %s: expected 400 %q, got %d %+v", tt.domain, tt.body, tt.want, status, result)
+		}
+	}
+	if config := readConfig(t, "blog.example.com"); !strings.Contains(config, "php8.2-fpm.sock") {
+		t.Errorf("Expected config unchanged after rejected switch:\n%s", config)
+	}
+
+	// 手工修改的配置在切换版本后保留
+	config := readConfig(t, "blog.example.com")
+	custom := "    client_max_body_size 64m; # uploads\n"
+	config = strings.Replace(config, "    root ", custom+"    root ", 1)
+	writeFile(t, layout.Resolve("blog.example.com").Config, config)
+
+	if status, result := do("PUT", "/sites/blog.example.com/php", `{"version":"8.1"}`); status != 200 || !strings.Contains(result.Message, "8.1") {
+		t.Fatalf("SetPHPVersion failed: %d %+v", status, result)
+	}
+	if config := readConfig(t, "blog.example.com"); !strings.Contains(config, "fastcgi_pass unix:/run/php/php8.1-fpm.sock;") {
+		t.Errorf("Expected switched socket:\n%s", config)
+	} else if !strings.Contains(config, custom) || strings.Contains(config, "php8.2") {
+		t.Errorf("Expected only fastcgi_pass to change:\n%s", config)
+	}
+	record, _ := models.GetSite("blog.example.com")
+	if record.PHPVersion != "8.1" || record.Params["php_version"] != "8.1" {
+		t.Errorf("Expected version to be saved, got %+v", record)
+	}
+	revisions, _ := models.ListConfigRevisions("blog.example.com")
+	if len(revisions) == 0 || revisions[0].Comment != "切换 PHP 版本 8.2 -> 8.1" {
+		t.Errorf("Unexpected revisions: %+v", revisions)
+	}
+}
@@ -0,0 +1,309 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/process"
+)
+
+var processManager *process.Manager
+
+// SetProcessManager 设置应用站点使用的进程管理器
+func SetProcessManager(m *process.Manager) {
+	processManager = m
+}
+
+// processBackend 站点类型对应的进程管理方式，与 CLI 一致：Node.js / Python 使用 supervisor，PM2 站点使用 PM2
+func processBackend(siteType string) string {
+	switch siteType {
+	case "node", "python":
+		return "supervisor"
+	case "pm2":
+		return "pm2"
+	}
+	return ""
+}
+
+// defaultProgram 新建应用站点的默认进程，命令和环境变量与 CLI 生成的相同
+func defaultProgram(record *models.Site, command string) process.Program {
+	p := process.Program{
+		Name:      record.Domain,
+		Command:   command,
+		Directory: filepath.Join(sitesDir, record.Domain),
+		Env:       map[string]string{},
+	}
+	if record.Port != 0 {
+		p.Env["PORT"] = strconv.Itoa(record.Port)
+	}
+	switch record.Type {
+	case "node":
+		p.Env["NODE_ENV"] = "production"
+		if p.Command == "" {
+			p.Command = "node server.js"
+		}
+	case "pm2":
+		p.Env["NODE_ENV"] = "production"
+		if p.Command == "" {
+			p.Command = "server.js"
+		}
+	case "python":
+		if p.Command == "" {
+			p.Command = "python3 app.py"
+		}
+	}
+	return p
+}
+
+// 示例应用，监听 PORT 环境变量指定的端口
+const (
+	sampleServerJS = `const http = require('http');
+const port = process.env.PORT || 3000;
+http.createServer((req, res) => {
+    res.writeHead(200, {'Content-Type': 'text/html; charset=utf-8'});
+    res.end('<h1>Node.js 站点运行中</h1>');
+}).listen(port, () => console.log(` + "`Server on port ${port}`" + `));
+`
+	sampleAppPy = `import os
+from http.server import HTTPServer, SimpleHTTPRequestHandler
+port = int(os.environ.get('PORT', 8000))
+print(f'Server on port {port}')
+HTTPServer(('127.0.0.1', port), SimpleHTTPRequestHandler).serve_forever()
+`
+)
+
+// createProcess 为新建的应用站点写入示例应用并创建进程；站点类型没有应用进程时不做任何事
+func createProcess(record *models.Site, command string) error {
+	name := processBackend(record.Type)
+	if name == "" || processManager == nil {
+		return nil
+	}
+	backend, err := processManager.Backend(name)
+	if err != nil {
+		return err
+	}
+	p := defaultProgram(record, command)
+	if command == "" {
+		sample, content := "server.js", sampleServerJS
+		if record.Type == "python" {
+			sample, content = "app.py", sampleAppPy
+		}
+		if path := filepath.Join(p.Directory, sample); !fileExists(path) {
+			os.WriteFile(path, []byte(content), 0644)
+		}
+	}
+	return backend.Save(p)
+}
+
+// controlProcess 启用、禁用和删除站点时同步应用进程（Docker 站点为容器），进程不存在时忽略
+func controlProcess(record *models.Site, action string) {
+	if record.Type == "docker" {
+		controlCompose(record, action)
+		return
+	}
+	name := processBackend(record.Type)
+	if name == "" || processManager == nil {
+		return
+	}
+	backend, err := processManager.Backend(name)
+	if err != nil {
+		return
+	}
+	switch action {
+	case "start":
+		err = backend.Start(record.Domain)
+	case "stop":
+		err = backend.Stop(record.Domain)
+	case "remove":
+		err = backend.Remove(record.Domain)
+	}
+	if err != nil && !errors.Is(err, process.ErrNotFound) {
+		log.Printf("[site] %s 应用进程 %s 失败: %v", record.Domain, action, err)
+	}
+}
+
+// siteBackend 站点的进程管理器；失败时已写入响应，返回的 backend 为 nil
+func siteBackend(c *fiber.Ctx) (*models.Site, process.Backend, error) {
+	record, err := lookupSite(c.Params("domain"))
+	if err != nil {
+		return nil, nil, c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return nil, nil, c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	name := processBackend(record.Type)
+	if name == "" {
+		return nil, nil, c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点类型没有应用进程"})
+	}
+	if processManager == nil {
+		return nil, nil, c.Status(500).JSON(fiber.Map{"status": false, "message": "进程管理未启用"})
+	}
+	backend, err := processManager.Backend(name)
+	if err != nil {
+		return nil, nil, c.Status(500).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return record, backend, nil
+}
+
+// processError 进程不存在返回 404，其余为 500
+func processError(c *fiber.Ctx, message string, err error) error {
+	if errors.Is(err, process.ErrNotFound) {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "应用进程不存在"})
+	}
+	return c.Status(500).JSON(fiber.Map{"status": false, "message": message + ": " + err.Error()})
+}
+
+// GetProcess 获取站点应用进程的定义和运行状态
+func GetProcess(c *fiber.Ctx) error {
+	_, backend, err := siteBackend(c)
+	if backend == nil {
+		return err
+	}
+	domain := c.Params("domain")
+	program, err := backend.Load(domain)
+	if err != nil {
+		return processError(c, "读取进程失败", err)
+	}
+	status, err := backend.Status(domain)
+	if err != nil {
+		return processError(c, "获取进程状态失败", err)
+	}
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   fiber.Map{"backend": backend.Name(), "program": program, "process": status},
+	})
+}
+
+// processDir 校验应用进程的工作目录，必须是站点目录或其子目录
+func processDir(domain, dir string) (string, error) {
+	root := filepath.Join(sitesDir, domain)
+	dir = filepath.Clean(dir)
+	if rel, err := filepath.Rel(root, dir); !filepath.IsAbs(dir) || err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("工作目录必须位于站点目录 %s 之下", root)
+	}
+	return dir, nil
+}
+
+// SaveProcess 创建或更新站点的应用进程（启动命令、工作目录、环境变量），保存后以新定义运行
+func SaveProcess(c *fiber.Ctx) error {
+	var req struct {
+		Command   string            `json:"command"`
+		Directory string            `json:"directory"`
+		Env       map[string]string `json:"env"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	record, backend, err := siteBackend(c)
+	if backend == nil {
+		return err
+	}
+
+	p, err := backend.Load(record.Domain)
+	if errors.Is(err, process.ErrNotFound) {
+		program := defaultProgram(record, "")
+		p, err = &program, nil
+	}
+	if err != nil {
+		return processError(c, "读取进程失败", err)
+	}
+	if req.Command != "" {
+		p.Command = req.Command
+	}
+	if req.Directory != "" {
+		dir, err := processDir(record.Domain, req.Directory)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		p.Directory = dir
+	}
+	if req.Env != nil {
+		p.Env = req.Env
+	}
+	if err := process.CheckProgram(*p); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	if err := backend.Save(*p); err != nil {
+		return processError(c, "保存进程失败", err)
+	}
+	status, _ := backend.Status(record.Domain)
+	return c.JSON(fiber.Map{"status": true, "message": "应用进程已保存", "data": fiber.Map{"program": p, "process": status}})
+}
+
+// ProcessAction 启动、停止或重启站点的应用进程
+func ProcessAction(c *fiber.Ctx) error {
+	record, backend, err := siteBackend(c)
+	if backend == nil {
+		return err
+	}
+	actions := map[string]struct {
+		run     func(string) error
+		message string
+	}{
+		"start":   {backend.Start, "应用已启动"},
+		"stop":    {backend.Stop, "应用已停止"},
+		"restart": {backend.Restart, "应用已重启"},
+	}
+	action, ok := actions[c.Params("action")]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的操作"})
+	}
+	if err := action.run(record.Domain); err != nil {
+		return processError(c, "操作失败", err)
+	}
+	status, _ := backend.Status(record.Domain)
+	return c.JSON(fiber.Map{"status": true, "message": action.message, "data": status})
+}
+
+// SetProcessEnv 替换应用进程的环境变量，运行中的进程会重启
+func SetProcessEnv(c *fiber.Ctx) error {
+	var req struct {
+		Env map[string]string `json:"env"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	if req.Env == nil {
+		req.Env = map[string]string{}
+	}
+	if err := process.CheckEnv(req.Env); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	record, backend, err := siteBackend(c)
+	if backend == nil {
+		return err
+	}
+	if err := processManager.SetEnv(backend.Name(), record.Domain, req.Env); err != nil {
+		return processError(c, "保存环境变量失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "环境变量已保存", "data": req.Env})
+}
+
+// GetProcessLogs 应用进程 stdout / stderr 日志的最后若干行
+func GetProcessLogs(c *fiber.Ctx) error {
+	stream := c.Query("stream", process.Stdout)
+	if stream != process.Stdout && stream != process.Stderr {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "stream 只能是 stdout 或 stderr"})
+	}
+	lines := c.QueryInt("lines", 100)
+	if lines <= 0 || lines > 5000 {
+		lines = 100
+	}
+	record, backend, err := siteBackend(c)
+	if backend == nil {
+		return err
+	}
+	result, err := processManager.Tail(backend.Name(), record.Domain, stream, lines)
+	if err != nil {
+		return processError(c, "读取日志失败", err)
+	}
+	return c.JSON(fiber.Map{"status": true, "data": result})
+}
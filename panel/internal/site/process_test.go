@@ -0,0 +1,196 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/process"
+	"site_manager_panel/internal/process/processtest"
+)
+
+func setupProcess(t *testing.T) (supervisor, pm2 *processtest.Fake) {
+	t.Helper()
+	supervisor, pm2 = processtest.New("supervisor"), processtest.New("pm2")
+	SetProcessManager(process.NewManager(supervisor, pm2))
+	t.Cleanup(func() { processManager = nil })
+	return supervisor, pm2
+}
+
+func newProcessApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/sites", Create)
+	app.Delete("/sites/:domain", Delete)
+	app.Post("/sites/:domain/enable", Enable)
+	app.Post("/sites/:domain/disable", Disable)
+	app.Get("/sites/:domain/process", GetProcess)
+	app.Put("/sites/:domain/process", SaveProcess)
+	app.Put("/sites/:domain/process/env", SetProcessEnv)
+	app.Get("/sites/:domain/process/logs", GetProcessLogs)
+	app.Post("/sites/:domain/process/:action", ProcessAction)
+	return newTestClient(t, app)
+}
+
+func TestSiteProcess(t *testing.T) {
+	root := setupDirs(t)
+	supervisor, _ := setupProcess(t)
+	do := newProcessApp(t)
+
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "app.example.com", "type": "node", "port": 3000})); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	program, err := supervisor.Load("app.example.com")
+	if err != nil {
+		t.Fatalf("Expected supervisor program to be created: %v", err)
+	}
+	if program.Command != "node server.js" || program.Directory != filepath.Join(root, "wwwroot", "app.example.com") ||
+		program.Env["PORT"] != "3000" || program.Env["NODE_ENV"] != "production" {
+		t.Errorf("Unexpected program: %+v", program)
+	}
+	if _, err := os.Stat(filepath.Join(program.Directory, "server.js")); err != nil {
+		t.Errorf("Expected sample server.js: %v", err)
+	}
+
+	var info struct {
+		Backend string          `json:"backend"`
+		Program process.Program `json:"program"`
+		Process process.Status  `json:"process"`
+	}
+	status, result := do("GET", "/sites/app.example.com/process", "")
+	json.Unmarshal(result.Data, &info)
+	if status != 200 || info.Backend != "supervisor" || info.Process.State != process.StateRunning || info.Process.PID == 0 {
+		t.Fatalf("Unexpected process info: %d %+v", status, info)
+	}
+
+	if status, _ := do("POST", "/sites/app.example.com/process/kill", ""); status != 400 {
+		t.Errorf("Expected 400 for unknown action, got %d", status)
+	}
+	var st process.Status
+	status, result = do("POST", "/sites/app.example.com/process/stop", "")
+	json.Unmarshal(result.Data, &st)
+	if status != 200 || st.State != process.StateStopped {
+		t.Errorf("Expected process to stop, got %d %+v", status, st)
+	}
+
+	// 环境变量整体替换
+	if status, _ := do("PUT", "/sites/app.example.com/process/env", `{"env":{"BAD-KEY":"1"}}`); status != 400 {
+		t.Errorf("Expected 400 for invalid env name, got %d", status)
+	}
+	if status, result := do("PUT", "/sites/app.example.com/process/env", `{"env":{"PORT":"3000","API_URL":"https://api.example.com"}}`); status != 200 {
+		t.Fatalf("SetProcessEnv failed: %d %+v", status, result)
+	}
+	if p, _ := supervisor.Load("app.example.com"); len(p.Env) != 2 || p.Env["API_URL"] != "https://api.example.com" || p.Command != "node server.js" {
+		t.Errorf("Unexpected env: %+v", p.Env)
+	}
+
+	if status, result := do("PUT", "/sites/app.example.com/process", `{"command":"node dist/main.js"}`); status != 200 || !supervisor.Running("app.example.com") {
+		t.Fatalf("SaveProcess failed: %d %+v", status, result)
+	}
+
+	// 换行会注入 supervisor 配置项，工作目录不能离开站点目录
+	for _, tt := range []struct {
+		body string
+		want string
+	}{
+		{`{"command":"node dist/main.js\nuser=root"}`, "换行"},
+		{`{"directory":"` + program.Directory + `/dist\n[program:x]"}`, "换行"},
+		{`{"env":{"PORT":"3000\r\ncommand=sh"}}`, "换行"},
+		{`{"directory":"/etc"}`, "站点目录"},
+		{`{"directory":"` + program.Directory + `/../other.example.com"}`, "站点目录"},
+		{`{"directory":"dist"}`, "站点目录"},
+	} {
+		if status, result := do("PUT", "/sites/app.example.com/process", tt.body); status != 400 || !strings.Contains(result.Message, tt.want) {
+			t.Errorf("%s: expected 400 %q, got %d %+v", tt.body, tt.want, status, result)
+		}
+	}
+	if status, result := do("PUT", "/sites/app.example.com/process/env", `{"env":{"PORT":"3000\nuser=root"}}`); status != 400 {
+		t.Errorf("Expected 400 for env value with newline, got %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "evil.example.com", "type": "node", "port": 3001, "command": "node a.js\nuser=root"})); status != 400 {
+		t.Errorf("Expected 400 for create with newline in command, got %d %+v", status, result)
+	}
+	if status, result := do("PUT", "/sites/app.example.com/process", `{"directory":"`+program.Directory+`/dist"}`); status != 200 {
+		t.Fatalf("SaveProcess failed: %d %+v", status, result)
+	}
+	if p, _ := supervisor.Load("app.example.com"); p.Command != "node dist/main.js" || p.Directory != filepath.Join(program.Directory, "dist") {
+		t.Errorf("Unexpected program: %+v", p)
+	}
+
+	logFile := filepath.Join(root, "app.out.log")
+	writeFile(t, logFile, "starting\nlistening on 3000\n")
+	p, _ := supervisor.Load("app.example.com")
+	p.StdoutLog = logFile
+	supervisor.Save(*p)
+	var lines []string
+	status, result = do("GET", "/sites/app.example.com/process/logs?stream=stdout&lines=1", "")
+	json.Unmarshal(result.Data, &lines)
+	if status != 200 || strings.Join(lines, ",") != "listening on 3000" {
+		t.Errorf("Unexpected logs: %d %v", status, lines)
+	}
+	if status, _ := do("GET", "/sites/app.example.com/process/logs?stream=both", ""); status != 400 {
+		t.Errorf("Expected 400 for invalid stream, got %d", status)
+	}
+
+	// 启用、禁用和删除站点时同步进程
+	do("POST", "/sites/app.example.com/disable", "")
+	if supervisor.Running("app.example.com") {
+		t.Error("Expected process to stop when site is disabled")
+	}
+	do("POST", "/sites/app.example.com/enable", "")
+	if !supervisor.Running("app.example.com") {
+		t.Error("Expected process to start when site is enabled")
+	}
+	if status, result := do("DELETE", "/sites/app.example.com", ""); status != 200 {
+		t.Fatalf("Delete failed: %d %+v", status, result)
+	}
+	if _, err := supervisor.Load("app.example.com"); !errors.Is(err, process.ErrNotFound) {
+		t.Errorf("Expected program to be removed, got %v", err)
+	}
+}
+
+func TestSiteProcessBackends(t *testing.T) {
+	setupDirs(t)
+	supervisor, pm2 := setupProcess(t)
+	do := newProcessApp(t)
+
+	// PM2 未安装等错误不影响站点创建
+	pm2.Fail["save"] = errors.New("pm2: command not found")
+	status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "pm2.example.com", "type": "pm2", "port": 3001}))
+	if status != 200 || !strings.Contains(result.Message, "应用进程启动失败") {
+		t.Fatalf("Expected site to be created with a warning, got %d %+v", status, result)
+	}
+	if status, _ := do("GET", "/sites/pm2.example.com/process", ""); status != 404 {
+		t.Errorf("Expected 404 for missing process, got %d", status)
+	}
+	// 重新保存即可创建进程
+	delete(pm2.Fail, "save")
+	if status, result := do("PUT", "/sites/pm2.example.com/process", `{}`); status != 200 {
+		t.Fatalf("SaveProcess failed: %d %+v", status, result)
+	}
+	if p, err := pm2.Load("pm2.example.com"); err != nil || p.Command != "server.js" || p.Env["PORT"] != "3001" {
+		t.Errorf("Unexpected PM2 program: %+v %v", p, err)
+	}
+
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "py.example.com", "type": "python", "port": 8000, "command": "gunicorn -b 127.0.0.1:8000 app:app"})); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	if p, err := supervisor.Load("py.example.com"); err != nil || p.Command != "gunicorn -b 127.0.0.1:8000 app:app" {
+		t.Errorf("Expected custom command, got %+v %v", p, err)
+	}
+
+	if status, result := do("POST", "/sites", jsonBody(t, fiber.Map{"domain": "static.example.com", "type": "static"})); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	if status, _ := do("GET", "/sites/static.example.com/process", ""); status != 400 {
+		t.Errorf("Expected 400 for static site, got %d", status)
+	}
+	if len(supervisor.Calls()) != 1 {
+		t.Errorf("Expected only the python site to use supervisor, got %v", supervisor.Calls())
+	}
+}
@@ -0,0 +1,299 @@
+package site
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+)
+
+var (
+	headerTypeRe = regexp.MustCompile(`(?m)^#\s*Type:\s*(\S+)`)
+	phpSocketRe  = regexp.MustCompile(`php(\d+\.\d+)-fpm\.sock`)
+	localPortRe  = regexp.MustCompile(`^https?://(?:127\.0\.0\.1|localhost|\[::1\]):(\d+)`)
+)
+
+// splitType 拆分 php:8.1 这类带参数的类型，node:static 按静态站点处理
+func splitType(t, php string) (string, string) {
+	base, arg, _ := strings.Cut(t, ":")
+	switch {
+	case arg == "static":
+		return "static", ""
+	case base != "php" && base != "laravel":
+		return base, ""
+	case arg != "":
+		php = arg
+	}
+	if php == "" {
+		php = defaultPHPVersion()
+	}
+	return base, php
+}
+
+// inspectSite 从 nginx 配置和 CLI 生成的辅助文件推断站点元数据
+func inspectSite(p *layout.Paths) (*models.Site, error) {
+	domain := p.Domain
+	info, err := os.Stat(p.Config)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(p.Config)
+	if err != nil {
+		return nil, err
+	}
+	config := string(content)
+
+	s := &models.Site{
+		Domain:    domain,
+		Type:      detectSiteType(domain, config),
+		Root:      filepath.Join(sitesDir, domain),
+		Tags:      []string{},
+		Enabled:   p.IsEnabled(),
+		CreatedBy: "cli",
+		CreatedAt: info.ModTime(),
+	}
+	if m := headerTypeRe.FindStringSubmatch(config); m != nil {
+		// 面板生成的配置带有类型注释
+		s.CreatedBy = "panel"
+		if isValidType(m[1]) {
+			s.Type, _ = splitType(m[1], "")
+		}
+	}
+
+	// 语法错误的配置照常导入，只是缺少下面这些字段
+	cfg, err := nginx.Parse(config)
+	if err != nil {
+		return s, nil
+	}
+	if root := directiveArg(cfg, "root"); root != "" {
+		s.Root = root
+	}
+	// server_name 中主域名以外的域名作为别名（通配符和正则除外）
+	s.Aliases = []string{}
+	if servers := cfg.SiteServers(); len(servers) > 0 {
+		for _, name := range servers[0].FindAll("server_name") {
+			for i := range name.Args {
+				if alias := strings.ToLower(name.Arg(i)); alias != domain && isValidDomain(alias) && !hasDomain(s.Aliases, alias) {
+					s.Aliases = append(s.Aliases, alias)
+				}
+			}
+		}
+	}
+	if m := phpSocketRe.FindStringSubmatch(directiveArg(cfg, "fastcgi_pass")); m != nil && s.Type == "php" {
+		s.PHPVersion = m[1]
+	}
+	if target := directiveArg(cfg, "proxy_pass"); target != "" {
+		s.Target = target
+		if p := localPortRe.FindStringSubmatch(s.Target); p != nil {
+			s.Port, _ = strconv.Atoi(p[1])
+		}
+	}
+	s.SSLCert = directiveArg(cfg, "ssl_certificate")
+	s.SSLKey = directiveArg(cfg, "ssl_certificate_key")
+	return s, nil
+}
+
+// directiveArg 站点 server 块中第一条同名指令的第一个参数
+func directiveArg(cfg *nginx.Config, name string) string {
+	for _, server := range cfg.SiteServers() {
+		if found := server.FindAll(name); len(found) > 0 {
+			return found[0].Arg(0)
+		}
+	}
+	return ""
+}
+
+// detectSiteType 与 CLI 的 get_site_type 规则一致：反向代理站点再根据进程管理文件细分
+func detectSiteType(domain, config string) string {
+	compose := filepath.Join(dockerDir, domain, "docker-compose.yml")
+
+	switch {
+	case strings.Contains(config, "fastcgi_pass"):
+		return "php"
+	case strings.Contains(config, "uwsgi_pass"):
+		return "python"
+	case strings.Contains(config, "proxy_pass"):
+		if fileExists(filepath.Join(sitesDir, domain, "ecosystem.config.js")) {
+			return "pm2"
+		}
+		if program, err := os.ReadFile(filepath.Join(supervisorConfDir, domain+".conf")); err == nil {
+			if regexp.MustCompile(`python|uvicorn|gunicorn`).Match(program) {
+				return "python"
+			}
+			return "node"
+		}
+		if fileExists(compose) {
+			return "docker"
+		}
+		return "proxy"
+	case fileExists(compose):
+		return "docker"
+	}
+	return "static"
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// ImportSites 将尚未登记的站点（通常由 CLI 创建）写入站点表，返回新增数量
+func ImportSites() (int, error) {
+	domains, err := layout.Domains()
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, domain := range domains {
+		if !isValidDomain(domain) {
+			continue
+		}
+
+		existing, err := models.GetSite(domain)
+		if err != nil {
+			return imported, err
+		}
+		if existing != nil {
+			continue
+		}
+
+		s, err := inspectSite(layout.Resolve(domain))
+		if err != nil {
+			log.Printf("[site] 读取 %s 配置失败: %v", domain, err)
+			continue
+		}
+		if err := models.CreateSite(s); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// Import 导入 CLI 创建的站点
+func Import(c *fiber.Ctx) error {
+	imported, err := ImportSites()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "导入失败: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "导入完成",
+		"data":    fiber.Map{"imported": imported},
+	})
+}
+
+// UpdateTags 设置站点标签
+func UpdateTags(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	record, err := models.GetSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+
+	record.Tags = normalizeTags(req.Tags)
+	if err := record.Update(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存失败"})
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "标签已更新", "data": record.Tags})
+}
+
+// normalizeTags 去除空白和重复标签；标签以逗号分隔存储，不允许包含逗号
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, t := range tags {
+		t = strings.TrimSpace(strings.ReplaceAll(t, ",", " "))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		result = append(result, t)
+	}
+	return result
+}
+
+// MigrateLayout 将旧布局站点迁移到规范布局，可通过 domains 指定站点，默认全部
+func MigrateLayout(c *fiber.Ctx) error {
+	var req struct {
+		Domains []string `json:"domains"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+		}
+	}
+
+	domains := req.Domains
+	if len(domains) == 0 {
+		var err error
+		if domains, err = layout.Domains(); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+		}
+	}
+
+	type result struct {
+		Domain   string        `json:"domain"`
+		From     layout.Kind   `json:"from"`
+		Migrated bool          `json:"migrated"`
+		Paths    *layout.Paths `json:"paths,omitempty"`
+		Error    string        `json:"error,omitempty"`
+	}
+	results := []result{}
+	failed := 0
+	for _, domain := range domains {
+		if !isValidDomain(domain) {
+			continue
+		}
+		from := layout.Resolve(domain)
+		r := result{Domain: domain, From: from.Layout}
+		if from.Exists() && from.IsCanonical() {
+			r.Paths = from
+			results = append(results, r)
+			continue
+		}
+
+		paths, err := layout.Migrate(domain)
+		if err != nil {
+			r.Error = err.Error()
+			failed++
+		} else {
+			r.Migrated = true
+			r.Paths = paths
+		}
+		results = append(results, r)
+	}
+
+	message := "迁移完成"
+	if failed > 0 {
+		message = fmt.Sprintf("迁移完成，%d 个站点失败", failed)
+	}
+	return c.JSON(fiber.Map{
+		"status":  failed == 0,
+		"message": message,
+		"data":    results,
+	})
+}
@@ -0,0 +1,212 @@
+package site
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/php"
+)
+
+// setupDirs 将站点相关目录指向临时目录
+func setupDirs(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	dirs := map[*string]string{
+		&layout.ConfigDir:  "sites-available",
+		&layout.EnabledDir: "sites-enabled",
+		&layout.LogsDir:    "wwwlogs",
+		&sitesDir:          "wwwroot",
+		&supervisorConfDir: "supervisor",
+		&dockerDir:         "docker",
+		&php.ConfigDir:     "php",
+		&php.RunDir:        "php-run",
+	}
+	for ptr, name := range dirs {
+		old := *ptr
+		*ptr = filepath.Join(root, name)
+		os.MkdirAll(*ptr, 0755)
+		t.Cleanup(func() { *ptr = old })
+	}
+
+	oldMain, oldTest, oldReload := nginx.MainConfig, nginx.Test, nginx.Reload
+	nginx.MainConfig = filepath.Join(root, "nginx.conf")
+	writeFile(t, nginx.MainConfig, "http {\n    include "+layout.EnabledDir+"/*;\n}\n")
+	nginx.Test = func(string) error { return nil }
+	nginx.Reload = func() error { return nil }
+	t.Cleanup(func() { nginx.MainConfig, nginx.Test, nginx.Reload = oldMain, oldTest, oldReload })
+
+	if err := models.InitDB(t.TempDir()); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	t.Cleanup(func() { models.DB.Close() })
+	return root
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func proxyConfig(domain, target string) string {
+	return "server {\n    listen 80;\n    server_name " + domain + ";\n\n    location / {\n        proxy_pass " + target + ";\n    }\n}\n"
+}
+
+func TestImportSites(t *testing.T) {
+	setupDirs(t)
+
+	conf := func(name string) string { return filepath.Join(layout.ConfigDir, name) }
+	enable := func(name string) { os.Symlink(conf(name), filepath.Join(layout.EnabledDir, name)) }
+
+	writeFile(t, conf("php.example.com.conf"), `server {
+    listen 443 ssl;
+    server_name php.example.com;
+    root /www/wwwroot/php.example.com;
+    ssl_certificate /www/ssl/php.example.com/fullchain.pem;
+    ssl_certificate_key /www/ssl/php.example.com/privkey.pem;
+    location ~ \.php$ {
+        fastcgi_pass unix:/run/php/php8.1-fpm.sock;
+    }
+}
+`)
+	enable("php.example.com.conf")
+
+	writeFile(t, conf("pm2.example.com.conf"), proxyConfig("pm2.example.com", "http://127.0.0.1:3001"))
+	writeFile(t, filepath.Join(sitesDir, "pm2.example.com", "ecosystem.config.js"), "module.exports = {}")
+	enable("pm2.example.com.conf")
+
+	writeFile(t, conf("node.example.com.conf"), proxyConfig("node.example.com", "http://127.0.0.1:3002"))
+	writeFile(t, filepath.Join(supervisorConfDir, "node.example.com.conf"), "command=node server.js\n")
+	enable("node.example.com.conf")
+
+	writeFile(t, conf("py.example.com.conf.disabled"), proxyConfig("py.example.com", "http://127.0.0.1:8000"))
+	writeFile(t, filepath.Join(supervisorConfDir, "py.example.com.conf"), "command=/www/runtime/python/.venv/bin/uvicorn main:app\n")
+
+	writeFile(t, conf("app.example.com.conf"), proxyConfig("app.example.com", "http://localhost:3003"))
+	writeFile(t, filepath.Join(dockerDir, "app.example.com", "docker-compose.yml"), "services: {}\n")
+	enable("app.example.com.conf")
+
+	writeFile(t, conf("proxy.example.com.conf"), proxyConfig("proxy.example.com", "https://upstream.internal"))
+
+	// 面板生成的配置
+	writeFile(t, conf("panel.example.com"), "# Site Manager managed - panel.example.com\n# Type: static\n\nserver {\n    root /www/wwwroot/panel.example.com;\n}\n")
+	enable("panel.example.com")
+
+	writeFile(t, conf("default"), "server {}\n")
+	writeFile(t, conf("old.example.com.conf.bak"), "server {}\n")
+
+	n, err := ImportSites()
+	if err != nil {
+		t.Fatalf("ImportSites failed: %v", err)
+	}
+	if n != 7 {
+		t.Fatalf("Expected 7 imported sites, got %d", n)
+	}
+
+	tests := []struct {
+		domain  string
+		typ     string
+		php     string
+		port    int
+		target  string
+		enabled bool
+		by      string
+	}{
+		{"php.example.com", "php", "8.1", 0, "", true, "cli"},
+		{"pm2.example.com", "pm2", "", 3001, "http://127.0.0.1:3001", true, "cli"},
+		{"node.example.com", "node", "", 3002, "http://127.0.0.1:3002", true, "cli"},
+		{"py.example.com", "python", "", 8000, "http://127.0.0.1:8000", false, "cli"},
+		{"app.example.com", "docker", "", 3003, "http://localhost:3003", true, "cli"},
+		{"proxy.example.com", "proxy", "", 0, "https://upstream.internal", false, "cli"},
+		{"panel.example.com", "static", "", 0, "", true, "panel"},
+	}
+	for _, tt := range tests {
+		s, err := models.GetSite(tt.domain)
+		if err != nil || s == nil {
+			t.Errorf("%s: not imported (%v)", tt.domain, err)
+			continue
+		}
+		if s.Type != tt.typ || s.PHPVersion != tt.php || s.Port != tt.port || s.Target != tt.target || s.Enabled != tt.enabled || s.CreatedBy != tt.by {
+			t.Errorf("%s: unexpected metadata %+v", tt.domain, s)
+		}
+	}
+
+	php, _ := models.GetSite("php.example.com")
+	if php.Root != "/www/wwwroot/php.example.com" || php.SSLKey != "/www/ssl/php.example.com/privkey.pem" {
+		t.Errorf("Unexpected root/ssl: %+v", php)
+	}
+
+	// 已登记的站点不会被覆盖
+	php.Tags = []string{"legacy"}
+	php.Update()
+	if n, err := ImportSites(); err != nil || n != 0 {
+		t.Errorf("Expected second import to be a no-op, got %d, %v", n, err)
+	}
+	php, _ = models.GetSite("php.example.com")
+	if len(php.Tags) != 1 {
+		t.Errorf("Expected existing record to be kept, got %+v", php)
+	}
+}
+
+func TestListAndTags(t *testing.T) {
+	setupDirs(t)
+	writeFile(t, filepath.Join(layout.ConfigDir, "cli.example.com.conf"), proxyConfig("cli.example.com", "http://127.0.0.1:4000"))
+
+	app := fiber.New()
+	app.Get("/sites", List)
+	app.Get("/sites/:domain", Info)
+	app.Post("/sites/import", Import)
+	app.Put("/sites/:domain/tags", UpdateTags)
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		result := map[string]interface{}{}
+		json.Unmarshal(data, &result)
+		return resp.StatusCode, result
+	}
+
+	// 尚未导入时详情页会自动登记
+	if status, result := do("GET", "/sites/cli.example.com", ""); status != 200 {
+		t.Fatalf("Info returned %d: %v", status, result)
+	}
+	if _, result := do("GET", "/sites", ""); len(result["data"].([]interface{})) != 1 {
+		t.Fatalf("Expected site to be registered, got %v", result)
+	}
+	if _, result := do("POST", "/sites/import", ""); result["data"].(map[string]interface{})["imported"] != float64(0) {
+		t.Errorf("Expected nothing left to import, got %v", result)
+	}
+
+	status, result := do("PUT", "/sites/cli.example.com/tags", `{"tags":[" prod ","prod","a,b",""]}`)
+	if status != 200 {
+		t.Fatalf("UpdateTags returned %d: %v", status, result)
+	}
+	tags := result["data"].([]interface{})
+	if len(tags) != 2 || tags[0] != "prod" || tags[1] != "a b" {
+		t.Errorf("Unexpected tags: %v", tags)
+	}
+
+	if status, _ := do("PUT", "/sites/missing.example.com/tags", `{"tags":[]}`); status != 404 {
+		t.Errorf("Expected 404 for unknown site, got %d", status)
+	}
+	if status, _ := do("GET", "/sites/missing.example.com", ""); status != 404 {
+		t.Errorf("Expected 404 for unknown site, got %d", status)
+	}
+}
@@ -0,0 +1,212 @@
+package site
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+)
+
+// currentRevision diff 中表示磁盘上当前配置的版本号
+const currentRevision = "current"
+
+// RevisionSummary 版本列表项，不含配置内容
+type RevisionSummary struct {
+	ID        int64     `json:"id"`
+	Author    string    `json:"author"`
+	Comment   string    `json:"comment"`
+	Lines     int       `json:"lines"`
+	Current   bool      `json:"current"` // 与磁盘上的配置一致
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func username(c *fiber.Ctx) string {
+	name, _ := c.Locals("username").(string)
+	return name
+}
+
+// saveRevision 内容与最新版本不同时保存新版本，rendered 表示内容由站点模板生成
+func saveRevision(domain, content, author, comment string, rendered bool) {
+	latest, err := models.LatestConfigRevision(domain)
+	if err == nil && latest != nil && latest.Content == content {
+		return
+	}
+	if err == nil {
+		err = models.CreateConfigRevision(&models.ConfigRevision{
+			Domain:   domain,
+			Content:  content,
+			Author:   author,
+			Comment:  comment,
+			Rendered: rendered,
+		})
+	}
+	if err != nil {
+		log.Printf("[site] 记录 %s 配置版本失败: %v", domain, err)
+	}
+}
+
+// captureExternal 修改前记录磁盘上的配置：首次记录为初始版本，
+// 与最新版本不同说明在面板之外（CLI、certbot、手工编辑）被修改过
+func captureExternal(paths *layout.Paths) {
+	content, err := os.ReadFile(paths.Config)
+	if err != nil {
+		return
+	}
+	comment := "面板外的修改"
+	if latest, _ := models.LatestConfigRevision(paths.Domain); latest == nil {
+		comment = "初始版本"
+	}
+	saveRevision(paths.Domain, string(content), "", comment, false)
+}
+
+// recordRevision 记录面板写入的配置
+func recordRevision(c *fiber.Ctx, domain, content, comment string, rendered bool) {
+	saveRevision(domain, content, username(c), comment, rendered)
+}
+
+// rendered 磁盘上的配置是否仍可由站点模板重新生成：最新版本由模板生成（之后只经过面板的证书、
+// PHP 版本修改），且没有在面板外被修改。为 false 时重新生成会覆盖手工或结构化的修改
+func rendered(paths *layout.Paths) bool {
+	content, err := os.ReadFile(paths.Config)
+	if err != nil {
+		return false
+	}
+	latest, err := models.LatestConfigRevision(paths.Domain)
+	return err == nil && latest != nil && latest.Rendered && latest.Content == string(content)
+}
+
+// ListRevisions 列出站点的配置版本，最新的在前
+func ListRevisions(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	paths := layout.Resolve(domain)
+	if paths.Exists() {
+		captureExternal(paths)
+	}
+
+	revisions, err := models.ListConfigRevisions(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取配置历史失败"})
+	}
+	current, _ := os.ReadFile(paths.Config)
+
+	list := make([]RevisionSummary, 0, len(revisions))
+	for _, r := range revisions {
+		list = append(list, RevisionSummary{
+			ID:        r.ID,
+			Author:    r.Author,
+			Comment:   r.Comment,
+			Lines:     strings.Count(r.Content, "\n"),
+			Current:   r.Content == string(current),
+			CreatedAt: r.CreatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   list,
+	})
+}
+
+// findRevision 按 ID 查找版本，找不到时已写入响应
+func findRevision(c *fiber.Ctx, domain, id string) (*models.ConfigRevision, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的版本号"})
+	}
+	r, err := models.GetConfigRevision(domain, n)
+	if err != nil {
+		return nil, c.Status(500).JSON(fiber.Map{"status": false, "message": "读取配置历史失败"})
+	}
+	if r == nil {
+		return nil, c.Status(404).JSON(fiber.Map{"status": false, "message": "版本不存在"})
+	}
+	return r, nil
+}
+
+// GetRevision 获取某个版本的完整配置
+func GetRevision(c *fiber.Ctx) error {
+	r, err := findRevision(c, c.Params("domain"), c.Params("id"))
+	if r == nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   r,
+	})
+}
+
+// DiffRevisions 两个版本之间的 unified diff；from / to 为版本号或 current，to 默认为 current
+func DiffRevisions(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	from, to := c.Query("from"), c.Query("to", currentRevision)
+	if from == "" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "缺少 from 参数"})
+	}
+
+	contents := [2]string{}
+	names := [2]string{}
+	for i, id := range []string{from, to} {
+		if id == currentRevision {
+			content, err := os.ReadFile(layout.Resolve(domain).Config)
+			if err != nil {
+				return c.Status(404).JSON(fiber.Map{"status": false, "message": "配置不存在"})
+			}
+			contents[i], names[i] = string(content), currentRevision
+			continue
+		}
+		r, err := findRevision(c, domain, id)
+		if r == nil {
+			return err
+		}
+		contents[i], names[i] = r.Content, fmt.Sprintf("#%d", r.ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"from": names[0],
+			"to":   names[1],
+			"diff": nginx.Diff(names[0], names[1], contents[0], contents[1]),
+		},
+	})
+}
+
+// RollbackRevision 回滚到指定版本，与保存配置一样先验证再替换
+func RollbackRevision(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	paths := layout.Resolve(domain)
+	if !paths.Exists() {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "配置不存在"})
+	}
+
+	r, err := findRevision(c, domain, c.Params("id"))
+	if r == nil {
+		return err
+	}
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+		}
+	}
+	if req.Comment == "" {
+		req.Comment = fmt.Sprintf("回滚到版本 #%d", r.ID)
+	}
+
+	if err := applyConfig(c, paths, r.Content, req.Comment); err != nil {
+		return applyError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"status": true, "message": "配置已回滚"})
+}
@@ -0,0 +1,142 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/nginx"
+)
+
+func newRevisionApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	app.Put("/sites/:domain/nginx", SaveNginxConfig)
+	app.Put("/sites/:domain/nginx/gzip", SetGzip)
+	app.Get("/sites/:domain/nginx/revisions", ListRevisions)
+	app.Get("/sites/:domain/nginx/revisions/:id", GetRevision)
+	app.Post("/sites/:domain/nginx/revisions/:id/rollback", RollbackRevision)
+	app.Get("/sites/:domain/nginx/diff", DiffRevisions)
+	return newTestClient(t, app)
+}
+
+func listRevisions(t *testing.T, do func(method, path, body string) (int, apiResult), domain string) []RevisionSummary {
+	t.Helper()
+	status, result := do("GET", "/sites/"+domain+"/nginx/revisions", "")
+	if status != 200 {
+		t.Fatalf("ListRevisions returned %d", status)
+	}
+	var list []RevisionSummary
+	json.Unmarshal(result.Data, &list)
+	return list
+}
+
+func TestConfigRevisions(t *testing.T) {
+	setupDirs(t)
+	do := newRevisionApp(t)
+	p := layout.For(layout.CLI, "app.example.com")
+	original := proxyConfig("app.example.com", "http://127.0.0.1:3000")
+	writeFile(t, p.Config, original)
+
+	edited := strings.Replace(original, "3000", "4000", 1)
+	if status, _ := do("PUT", "/sites/app.example.com/nginx", jsonBody(t, fiber.Map{"config": edited, "comment": "切换端口"})); status != 200 {
+		t.Fatalf("Save failed: %d", status)
+	}
+
+	// CLI 手工修改后再通过结构化接口修改
+	external := strings.Replace(edited, "listen 80;", "listen 8080;", 1)
+	writeFile(t, p.Config, external)
+	if status, _ := do("PUT", "/sites/app.example.com/nginx/gzip", `{"enabled":true}`); status != 200 {
+		t.Fatalf("SetGzip failed: %d", status)
+	}
+
+	list := listRevisions(t, do, "app.example.com")
+	want := []struct{ author, comment string }{
+		{"alice", "gzip 已开启"},
+		{"", "面板外的修改"},
+		{"alice", "切换端口"},
+		{"", "初始版本"},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("Expected %d revisions, got %+v", len(want), list)
+	}
+	for i, w := range want {
+		if list[i].Author != w.author || list[i].Comment != w.comment {
+			t.Errorf("Revision %d: expected %+v, got %+v", i, w, list[i])
+		}
+	}
+	if !list[0].Current || list[1].Current {
+		t.Errorf("Expected only the latest revision to be current: %+v", list)
+	}
+	first := list[3].ID
+
+	status, result := do("GET", fmt.Sprintf("/sites/app.example.com/nginx/revisions/%d", first), "")
+	var revision struct {
+		Content string `json:"content"`
+	}
+	json.Unmarshal(result.Data, &revision)
+	if status != 200 || revision.Content != original {
+		t.Errorf("Unexpected revision %d: %q", status, revision.Content)
+	}
+
+	status, result = do("GET", fmt.Sprintf("/sites/app.example.com/nginx/diff?from=%d&to=%d", first, list[2].ID), "")
+	var diff struct {
+		Diff string `json:"diff"`
+	}
+	json.Unmarshal(result.Data, &diff)
+	if status != 200 || !strings.Contains(diff.Diff, "-        proxy_pass http://127.0.0.1:3000;\n+        proxy_pass http://127.0.0.1:4000;\n") {
+		t.Errorf("Unexpected diff %d:\n%s", status, diff.Diff)
+	}
+	status, result = do("GET", fmt.Sprintf("/sites/app.example.com/nginx/diff?from=%d", list[0].ID), "")
+	json.Unmarshal(result.Data, &diff)
+	if status != 200 || diff.Diff != "" {
+		t.Errorf("Expected no diff against current config, got %d:\n%s", status, diff.Diff)
+	}
+
+	// 回滚验证失败时配置和历史都不变
+	nginx.Test = func(string) error { return errors.New("nginx: [emerg] bind() failed") }
+	if status, _ := do("POST", fmt.Sprintf("/sites/app.example.com/nginx/revisions/%d/rollback", first), ""); status != 400 {
+		t.Errorf("Expected rollback to fail validation, got %d", status)
+	}
+	if len(listRevisions(t, do, "app.example.com")) != len(want) {
+		t.Error("Failed rollback must not add a revision")
+	}
+
+	nginx.Test = func(string) error { return nil }
+	if status, _ := do("POST", fmt.Sprintf("/sites/app.example.com/nginx/revisions/%d/rollback", first), ""); status != 200 {
+		t.Fatalf("Rollback failed: %d", status)
+	}
+	if readConfig(t, "app.example.com") != original {
+		t.Error("Expected original config after rollback")
+	}
+	list = listRevisions(t, do, "app.example.com")
+	if list[0].Comment != fmt.Sprintf("回滚到版本 #%d", first) || !list[0].Current || !list[len(list)-1].Current {
+		t.Errorf("Unexpected revisions after rollback: %+v", list)
+	}
+
+	for path, status := range map[string]int{
+		"/sites/app.example.com/nginx/revisions/abc":    400,
+		"/sites/app.example.com/nginx/revisions/999":    404,
+		"/sites/other.example.com/nginx/revisions/1":    404,
+		"/sites/app.example.com/nginx/diff":             400,
+		"/sites/app.example.com/nginx/diff?from=1&to=x": 400,
+	} {
+		if got, _ := do("GET", path, ""); got != status {
+			t.Errorf("%s: expected %d, got %d", path, status, got)
+		}
+	}
+	os.Remove(p.Config)
+	if got, _ := do("POST", fmt.Sprintf("/sites/app.example.com/nginx/revisions/%d/rollback", first), ""); got != 404 {
+		t.Errorf("Expected 404 rollback for missing config, got %d", got)
+	}
+}
@@ -0,0 +1,323 @@
+package site
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/acme"
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/ssl"
+)
+
+var sslManager *ssl.Manager
+
+// SetSSLManager 设置站点证书使用的证书管理器
+func SetSSLManager(m *ssl.Manager) {
+	sslManager = m
+}
+
+// sslError 证书操作失败的响应；CA 返回的错误为 500，error 中是 ACME 错误，data 中是本次申请的进度
+func sslError(c *fiber.Ctx, message string, err error) error {
+	var problem *acme.Problem
+	if errors.As(err, &problem) {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  false,
+			"message": message + ": " + err.Error(),
+			"error":   problem,
+			"data":    sslManager.Progress(c.Params("domain")),
+		})
+	}
+	return c.Status(400).JSON(fiber.Map{"status": false, "message": message + ": " + err.Error()})
+}
+
+// updateSiteSSL 将证书路径写回站点记录
+func updateSiteSSL(domain, cert, key string) {
+	record, err := lookupSite(domain)
+	if err != nil || record == nil {
+		return
+	}
+	record.SSLCert, record.SSLKey = cert, key
+	if err := record.Update(); err != nil {
+		log.Printf("[site] 保存 %s 证书路径失败: %v", domain, err)
+	}
+}
+
+// RequestSSL 申请 SSL 证书并写入站点配置，证书包含站点的全部域名
+// HTTP 验证会先为站点添加 ACME 验证路径；通配符或指定 challenge=dns 时通过绑定的 DNS 账号验证
+func RequestSSL(c *fiber.Ctx) error {
+	var req ssl.IssueRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+		}
+	}
+	req.Domain = c.Params("domain")
+	// 证书同时包含站点的别名和跳转域名
+	if record, err := lookupSite(req.Domain); err == nil && record != nil {
+		req.Domains = append(req.Domains, record.Names()...)
+	}
+	return issueSSL(c, req, "SSL 申请失败", "申请 SSL 证书", "SSL 证书申请成功")
+}
+
+// issueSSL 签发证书并更新站点配置，申请和续期共用
+func issueSSL(c *fiber.Ctx, req ssl.IssueRequest, failure, comment, success string) error {
+	paths, cfg, err := loadConfig(c)
+	if cfg == nil {
+		return err
+	}
+
+	if req.ChallengeType() == ssl.ChallengeHTTP {
+		if !paths.IsEnabled() {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "站点未启用，无法使用 HTTP 验证"})
+		}
+		before := cfg.String()
+		if err := cfg.AddACMEChallenge(ssl.ChallengeDir); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+		}
+		if config := cfg.String(); config != before {
+			if err := applyManaged(c, paths, config, "添加 ACME 验证路径"); err != nil {
+				return applyError(c, err)
+			}
+		}
+	}
+
+	cert, err := sslManager.Issue(req)
+	if err != nil {
+		return sslError(c, failure, err)
+	}
+
+	// 证书路径不变时（如续期）只需重载 nginx
+	before := cfg.String()
+	if err := cfg.SetCertificate(cert.CertPath, cert.KeyPath); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	if config := cfg.String(); config != before {
+		if err := applyManaged(c, paths, config, comment); err != nil {
+			return applyError(c, err)
+		}
+	} else if err := nginx.Reload(); err != nil {
+		log.Printf("[site] 更新证书后重载 nginx 失败: %v", err)
+	}
+	updateSiteSSL(req.Domain, cert.CertPath, cert.KeyPath)
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": success,
+		"data":    cert,
+	})
+}
+
+// RenewSSL 续期站点证书，沿用上次申请的域名和验证方式；force=true 时不论是否到期都重新签发
+func RenewSSL(c *fiber.Ctx) error {
+	var body struct {
+		Force bool `json:"force"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+		}
+	}
+
+	req, err := sslManager.RenewRequest(c.Params("domain"), body.Force)
+	var notDue *ssl.NotDueError
+	if errors.As(err, &notDue) {
+		return c.JSON(fiber.Map{"status": true, "message": err.Error()})
+	}
+	if err != nil {
+		return sslError(c, "续期失败", err)
+	}
+	return issueSSL(c, *req, "续期失败", "续期 SSL 证书", "证书续期完成")
+}
+
+// GetSSLProgress 站点最近一次证书申请或续期的进度，申请进行中时可轮询
+func GetSSLProgress(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": true, "data": sslManager.Progress(c.Params("domain"))})
+}
+
+// RevokeSSL 吊销站点证书，并从站点配置中移除证书和 443 监听
+func RevokeSSL(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	paths, cfg, err := loadConfig(c)
+	if cfg == nil {
+		return err
+	}
+
+	if err := sslManager.Revoke(domain); err != nil {
+		return sslError(c, "吊销失败", err)
+	}
+
+	if err := cfg.RemoveCertificate(); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	if err := applyManaged(c, paths, cfg.String(), "吊销 SSL 证书"); err != nil {
+		return applyError(c, err)
+	}
+	if err := sslManager.RemoveFiles(domain); err != nil {
+		log.Printf("[site] 删除 %s 证书文件失败: %v", domain, err)
+	}
+	updateSiteSSL(domain, "", "")
+
+	return c.JSON(fiber.Map{"status": true, "message": "证书已吊销"})
+}
+
+// UploadRequest 上传的证书：PEM 证书链和私钥，或 base64 编码的 PFX 及其密码
+type UploadRequest struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+	PFX         string `json:"pfx"`
+	Password    string `json:"password"`
+}
+
+// parseUpload 读取 JSON 或 multipart 表单（文件字段 certificate、private_key、pfx）中的证书
+func parseUpload(c *fiber.Ctx) (*ssl.Bundle, error) {
+	var req UploadRequest
+	var pfx []byte
+	if form, err := c.MultipartForm(); err == nil {
+		read := func(name string) ([]byte, error) {
+			files := form.File[name]
+			if len(files) == 0 {
+				if v := form.Value[name]; len(v) > 0 {
+					return []byte(v[0]), nil
+				}
+				return nil, nil
+			}
+			return readFormFile(files[0])
+		}
+		cert, err := read("certificate")
+		if err != nil {
+			return nil, err
+		}
+		key, err := read("private_key")
+		if err != nil {
+			return nil, err
+		}
+		if pfx, err = read("pfx"); err != nil {
+			return nil, err
+		}
+		req.Certificate, req.PrivateKey = string(cert), string(key)
+		if v := form.Value["password"]; len(v) > 0 {
+			req.Password = v[0]
+		}
+	} else {
+		if err := c.BodyParser(&req); err != nil {
+			return nil, errors.New("无效的请求")
+		}
+		if req.PFX != "" {
+			if pfx, err = base64.StdEncoding.DecodeString(req.PFX); err != nil {
+				return nil, errors.New("PFX 不是有效的 base64 编码")
+			}
+		}
+	}
+
+	switch {
+	case len(pfx) > 0:
+		return ssl.ParsePFX(pfx, req.Password)
+	case req.Certificate != "":
+		return ssl.ParsePEM([]byte(req.Certificate), []byte(req.PrivateKey))
+	}
+	return nil, errors.New("请上传证书和私钥，或 PFX 文件")
+}
+
+// maxCertUpload 上传的证书文件大小上限
+const maxCertUpload = 1 << 20
+
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	if fh.Size > maxCertUpload {
+		return nil, errors.New("证书文件过大")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// UploadSSL 安装上传的证书：校验私钥、证书链和域名后写入 CertDir/<domain>，通过事务更新站点配置；
+// 配置校验失败时恢复原有证书文件
+func UploadSSL(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	paths, cfg, err := loadConfig(c)
+	if cfg == nil {
+		return err
+	}
+
+	bundle, err := parseUpload(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "证书无效: " + err.Error()})
+	}
+	if err := bundle.Validate(domain); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "证书无效: " + err.Error()})
+	}
+
+	cert, restore, err := ssl.InstallCustom(domain, bundle)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存证书失败: " + err.Error()})
+	}
+	before := cfg.String()
+	if err := cfg.SetCertificate(cert.CertPath, cert.KeyPath); err != nil {
+		restore()
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	if config := cfg.String(); config != before {
+		if err := applyManaged(c, paths, config, "上传 SSL 证书"); err != nil {
+			restore()
+			return applyError(c, err)
+		}
+	} else if err := nginx.Reload(); err != nil {
+		log.Printf("[site] 更新证书后重载 nginx 失败: %v", err)
+	}
+	updateSiteSSL(domain, cert.CertPath, cert.KeyPath)
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "证书已安装",
+		"data":    cert,
+	})
+}
+
+// siteExport 读取站点配置当前使用的证书和私钥
+func siteExport(c *fiber.Ctx) (*ssl.Export, error) {
+	_, cfg, err := loadConfig(c)
+	if cfg == nil {
+		return nil, err
+	}
+	certPath, keyPath := directiveArg(cfg, "ssl_certificate"), directiveArg(cfg, "ssl_certificate_key")
+	if certPath == "" || keyPath == "" {
+		return nil, c.Status(404).JSON(fiber.Map{"status": false, "message": "站点未配置证书"})
+	}
+	export, err := ssl.ExportFiles(certPath, keyPath)
+	if err != nil {
+		return nil, c.Status(500).JSON(fiber.Map{"status": false, "message": "导出失败: " + err.Error()})
+	}
+	return export, nil
+}
+
+// ExportSSL 导出站点正在使用的证书和私钥（PEM）；包含私钥，因此使用 POST
+func ExportSSL(c *fiber.Ctx) error {
+	export, err := siteExport(c)
+	if export == nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"status": true, "data": export})
+}
+
+// DownloadSSL 下载站点证书的 zip 包，包含 cert.pem、chain.pem、fullchain.pem 和 privkey.pem
+func DownloadSSL(c *fiber.Ctx) error {
+	export, err := siteExport(c)
+	if export == nil {
+		return err
+	}
+	data, err := export.Zip()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "打包失败: " + err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+c.Params("domain")+`.zip"`)
+	return c.Send(data)
+}
@@ -0,0 +1,353 @@
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/acme"
+	"site_manager_panel/internal/acme/acmetest"
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/ssl"
+)
+
+// setupSSL 将证书目录指向临时目录，并启动本地 ACME 服务端；HTTP-01 验证读取 ChallengeDir 中的文件
+func setupSSL(t *testing.T, root string) *acmetest.Server {
+	t.Helper()
+	oldCert, oldLive, oldChallenge := ssl.CertDir, ssl.LiveDir, ssl.ChallengeDir
+	t.Cleanup(func() {
+		ssl.CertDir, ssl.LiveDir, ssl.ChallengeDir = oldCert, oldLive, oldChallenge
+		ssl.SetDirectoryURL(acme.LetsEncrypt)
+		sslManager = nil
+	})
+	ssl.CertDir = filepath.Join(root, "ssl")
+	ssl.LiveDir = filepath.Join(root, "letsencrypt")
+	ssl.ChallengeDir = filepath.Join(root, "acme")
+	SetSSLManager(ssl.NewManager(ssl.NewStore(filepath.Join(root, "config"))))
+
+	srv := acmetest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.HTTPGet = func(domain, path string) (string, error) {
+		data, err := os.ReadFile(filepath.Join(ssl.ChallengeDir, path))
+		return string(data), err
+	}
+	ssl.SetDirectoryURL(srv.DirectoryURL())
+	return srv
+}
+
+func newSSLApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	app.Post("/sites/:domain/ssl", RequestSSL)
+	app.Post("/sites/:domain/ssl/renew", RenewSSL)
+	app.Post("/sites/:domain/ssl/revoke", RevokeSSL)
+	app.Get("/sites/:domain/ssl/progress", GetSSLProgress)
+	app.Post("/sites/:domain/ssl/upload", UploadSSL)
+	app.Post("/sites/:domain/ssl/export", ExportSSL)
+	return newTestClient(t, app)
+}
+
+func TestRequestSSL(t *testing.T) {
+	root := setupDirs(t)
+	srv := setupSSL(t, root)
+	do := newSSLApp(t)
+
+	p := layout.For(layout.CLI, "app.example.com")
+	writeFile(t, p.Config, strings.Replace(proxyConfig("app.example.com", "http://127.0.0.1:3000"), "listen 80;", "listen 80;\n    listen [::]:80;", 1))
+
+	// HTTP 验证要求站点已启用
+	if status, result := do("POST", "/sites/app.example.com/ssl", ""); status != 400 {
+		t.Fatalf("Expected 400 for disabled site, got %d %+v", status, result)
+	}
+	os.Symlink(p.Config, p.Enabled)
+
+	status, result := do("POST", "/sites/app.example.com/ssl", jsonBody(t, fiber.Map{"domains": []string{"www.app.example.com"}, "email": "ops@example.com"}))
+	if status != 200 {
+		t.Fatalf("RequestSSL failed: %d %+v", status, result)
+	}
+	if srv.Orders() != 1 {
+		t.Errorf("Expected 1 order, got %d", srv.Orders())
+	}
+
+	config := readConfig(t, "app.example.com")
+	cert, key := ssl.CertPath("app.example.com"), ssl.KeyPath("app.example.com")
+	for _, want := range []string{
+		"location ^~ " + nginx.ACMEChallengePath + " {\n        root " + ssl.ChallengeDir + ";",
+		"listen [::]:80;\n    listen 443 ssl http2;\n    listen [::]:443 ssl http2;",
+		"ssl_certificate " + cert + ";",
+		"ssl_certificate_key " + key + ";",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("Expected %q in config:\n%s", want, config)
+		}
+	}
+
+	revisions, _ := models.ListConfigRevisions("app.example.com")
+	comments := []string{}
+	for _, r := range revisions {
+		comments = append(comments, r.Comment)
+	}
+	if strings.Join(comments, ",") != "申请 SSL 证书,添加 ACME 验证路径,初始版本" {
+		t.Errorf("Unexpected revisions: %v", comments)
+	}
+	if record, _ := models.GetSite("app.example.com"); record == nil || record.SSLCert != cert || record.SSLKey != key {
+		t.Errorf("Expected certificate paths on site record, got %+v", record)
+	}
+
+	// 再次申请不重复添加验证路径和监听
+	if status, _ := do("POST", "/sites/app.example.com/ssl", ""); status != 200 {
+		t.Fatalf("Second RequestSSL failed: %d", status)
+	}
+	if again := readConfig(t, "app.example.com"); again != config {
+		t.Errorf("Expected config unchanged on reissue:\n%s", again)
+	}
+
+	// 未到期时不续期；强制续期沿用上次申请的域名，配置不变
+	if status, result := do("POST", "/sites/app.example.com/ssl/renew", ""); status != 200 || !strings.Contains(result.Message, "无需续期") || srv.Orders() != 2 {
+		t.Fatalf("Expected renewal to be skipped, got %d %+v", status, result)
+	}
+	status, result = do("POST", "/sites/app.example.com/ssl/renew", `{"force":true}`)
+	if status != 200 || srv.Orders() != 3 {
+		t.Fatalf("RenewSSL failed: %d %+v", status, result)
+	}
+	var renewed ssl.Certificate
+	json.Unmarshal(result.Data, &renewed)
+	if strings.Join(renewed.Names, ",") != "app.example.com" {
+		t.Errorf("Expected renewal with previous names, got %v", renewed.Names)
+	}
+	if again := readConfig(t, "app.example.com"); again != config {
+		t.Errorf("Expected config unchanged on renewal:\n%s", again)
+	}
+
+	if status, result := do("POST", "/sites/app.example.com/ssl/revoke", ""); status != 200 {
+		t.Fatalf("RevokeSSL failed: %d %+v", status, result)
+	}
+	config = readConfig(t, "app.example.com")
+	if strings.Contains(config, "443") || strings.Contains(config, "ssl_certificate") {
+		t.Errorf("Expected certificate removed from config:\n%s", config)
+	}
+	if _, err := os.Stat(cert); !os.IsNotExist(err) {
+		t.Error("Expected certificate copy to be removed")
+	}
+}
+
+func TestRequestSSLErrors(t *testing.T) {
+	root := setupDirs(t)
+	srv := setupSSL(t, root)
+	do := newSSLApp(t)
+
+	p := layout.For(layout.CLI, "app.example.com")
+	writeFile(t, p.Config, proxyConfig("app.example.com", "http://127.0.0.1:3000"))
+	os.Symlink(p.Config, p.Enabled)
+
+	// 未绑定 DNS 账号时通配符证书直接失败，不修改配置
+	if status, result := do("POST", "/sites/app.example.com/ssl", `{"wildcard":true}`); status != 400 || !strings.Contains(result.Message, "未绑定") {
+		t.Errorf("Expected unbound account error, got %d %+v", status, result)
+	}
+	if config := readConfig(t, "app.example.com"); strings.Contains(config, "acme-challenge") {
+		t.Errorf("Expected config unchanged for DNS challenge:\n%s", config)
+	}
+
+	// 验证失败时返回 ACME 错误和每个域名的验证状态
+	srv.HTTPGet = func(domain, path string) (string, error) {
+		return "", errors.New("connection refused")
+	}
+	status, result := do("POST", "/sites/app.example.com/ssl", "")
+	if status != 500 || !strings.Contains(result.Message, "connection refused") {
+		t.Fatalf("Expected ACME problem, got %d %+v", status, result)
+	}
+	var progress ssl.Progress
+	json.Unmarshal(result.Data, &progress)
+	if progress.Stage != ssl.StageFailed || len(progress.Authorizations) != 1 || progress.Authorizations[0].Error == nil {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+	_, result = do("GET", "/sites/app.example.com/ssl/progress", "")
+	json.Unmarshal(result.Data, &progress)
+	if progress.Stage != ssl.StageFailed || progress.Error.Kind() != "connection" {
+		t.Errorf("Expected progress endpoint to report failure, got %+v", progress)
+	}
+	if strings.Contains(readConfig(t, "app.example.com"), "ssl_certificate") {
+		t.Error("Expected no certificate directives after failed issue")
+	}
+
+	if status, _ := do("POST", "/sites/missing.example.com/ssl", ""); status != 404 {
+		t.Errorf("Expected 404 for missing site, got %d", status)
+	}
+	if status, _ := do("POST", "/sites/app.example.com/ssl/renew", ""); status != 400 {
+		t.Errorf("Expected 400 renewing missing certificate, got %d", status)
+	}
+}
+
+// selfSigned 生成覆盖 names 的自签名证书和私钥（PEM）
+func selfSigned(t *testing.T, names ...string) (cert, key string) {
+	t.Helper()
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()), Subject: pkix.Name{CommonName: names[0]}, DNSNames: names,
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(priv)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestUploadSSL(t *testing.T) {
+	root := setupDirs(t)
+	setupSSL(t, root)
+	do := newSSLApp(t)
+
+	p := layout.For(layout.CLI, "app.example.com")
+	writeFile(t, p.Config, proxyConfig("app.example.com", "http://127.0.0.1:3000"))
+	os.Symlink(p.Config, p.Enabled)
+
+	cert, key := selfSigned(t, "app.example.com")
+	other, otherKey := selfSigned(t, "other.example.com")
+	_, wrongKey := selfSigned(t, "app.example.com")
+	for _, tt := range []struct {
+		body fiber.Map
+		want string
+	}{
+		{fiber.Map{}, "请上传证书"},
+		{fiber.Map{"certificate": other, "private_key": otherKey}, "不包含域名 app.example.com"},
+		{fiber.Map{"certificate": cert, "private_key": wrongKey}, "私钥与证书不匹配"},
+		{fiber.Map{"pfx": "%%%"}, "base64"},
+	} {
+		if status, result := do("POST", "/sites/app.example.com/ssl/upload", jsonBody(t, tt.body)); status != 400 || !strings.Contains(result.Message, tt.want) {
+			t.Errorf("Expected %q, got %d %+v", tt.want, status, result)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(ssl.CertDir, "app.example.com")); !os.IsNotExist(err) {
+		t.Error("Expected no certificate files after rejected uploads")
+	}
+
+	status, result := do("POST", "/sites/app.example.com/ssl/upload", jsonBody(t, fiber.Map{"certificate": cert, "private_key": key}))
+	if status != 200 {
+		t.Fatalf("UploadSSL failed: %d %+v", status, result)
+	}
+	config := readConfig(t, "app.example.com")
+	if !strings.Contains(config, "ssl_certificate "+ssl.CertPath("app.example.com")+";") || !strings.Contains(config, "listen 443 ssl http2;") {
+		t.Errorf("Expected certificate in config:\n%s", config)
+	}
+	if revisions, _ := models.ListConfigRevisions("app.example.com"); len(revisions) == 0 || revisions[0].Comment != "上传 SSL 证书" {
+		t.Errorf("Expected upload revision, got %+v", revisions)
+	}
+	if status, result := do("POST", "/sites/app.example.com/ssl/renew", `{"force":true}`); status != 400 || !strings.Contains(result.Message, "重新上传") {
+		t.Errorf("Expected renewal of uploaded certificate to be rejected, got %d %+v", status, result)
+	}
+
+	var export ssl.Export
+	status, result = do("POST", "/sites/app.example.com/ssl/export", "")
+	json.Unmarshal(result.Data, &export)
+	installedKey, _ := os.ReadFile(ssl.KeyPath("app.example.com"))
+	if status != 200 || export.Certificate != cert || export.PrivateKey != string(installedKey) || export.Chain != "" {
+		t.Errorf("Unexpected export: %d %+v", status, export)
+	}
+
+	// nginx -t 失败时恢复原有证书文件，配置不变
+	nginx.Test = failOn("ssl_certificate")
+	os.WriteFile(p.Config, []byte(proxyConfig("app.example.com", "http://127.0.0.1:3000")), 0644)
+	newCert, newKey := selfSigned(t, "app.example.com")
+	if status, result := do("POST", "/sites/app.example.com/ssl/upload", jsonBody(t, fiber.Map{"certificate": newCert, "private_key": newKey})); status != 400 || len(result.Output) == 0 {
+		t.Fatalf("Expected nginx test failure, got %d %+v", status, result)
+	}
+	if data, _ := os.ReadFile(ssl.CertPath("app.example.com")); string(data) != cert {
+		t.Error("Expected previous certificate to be restored")
+	}
+}
+
+func TestUploadPFXAndDownload(t *testing.T) {
+	root := setupDirs(t)
+	setupSSL(t, root)
+	app := fiber.New()
+	app.Post("/sites/:domain/ssl/upload", UploadSSL)
+	app.Post("/sites/:domain/ssl/download", DownloadSSL)
+
+	p := layout.For(layout.CLI, "pfx.example.com")
+	writeFile(t, p.Config, proxyConfig("pfx.example.com", "http://127.0.0.1:3000"))
+	os.Symlink(p.Config, p.Enabled)
+
+	pfx, err := os.ReadFile(filepath.Join("..", "ssl", "testdata", "bundle.pfx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// JSON 中的 PFX 为 base64 编码
+	req := httptest.NewRequest("POST", "/sites/pfx.example.com/ssl/upload", strings.NewReader(jsonBody(t, fiber.Map{"pfx": base64.StdEncoding.EncodeToString(pfx), "password": "wrong"})))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err := app.Test(req, -1); err != nil || resp.StatusCode != 400 {
+		t.Errorf("Expected 400 for wrong password, got %v %v", resp, err)
+	}
+
+	upload := func(password string) int {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("pfx", "bundle.pfx")
+		fw.Write(pfx)
+		mw.WriteField("password", password)
+		mw.Close()
+		req := httptest.NewRequest("POST", "/sites/pfx.example.com/ssl/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	if status := upload("secret"); status != 200 {
+		t.Fatalf("PFX upload failed: %d", status)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/sites/pfx.example.com/ssl/download", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(resp.Header.Get("Content-Disposition"), "pfx.example.com.zip") {
+		t.Fatalf("Unexpected download response: %d %v", resp.StatusCode, resp.Header)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "cert.pem,chain.pem,fullchain.pem,privkey.pem" {
+		t.Errorf("Unexpected zip entries: %v", names)
+	}
+
+	// 上传 PFX 时其中的证书已按叶子、中间、根的顺序写入
+	chain, _ := os.ReadFile(ssl.CertPath("pfx.example.com"))
+	if n := strings.Count(string(chain), "BEGIN CERTIFICATE"); n != 3 {
+		t.Errorf("Expected 3 certificates in fullchain, got %d", n)
+	}
+}
@@ -0,0 +1,248 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+)
+
+// fieldParams 将 php / port / target 这类独立字段转换为模板参数，只保留模板声明的参数
+func fieldParams(t *nginx.Template, php string, port int, target string) map[string]interface{} {
+	params := map[string]interface{}{}
+	if php != "" && t.Declares("php_version") {
+		params["php_version"] = php
+	}
+	if port != 0 && t.Declares("port") {
+		params["port"] = port
+	}
+	if t.Declares("target") {
+		if target != "" {
+			params["target"] = target
+		} else if port != 0 {
+			params["target"] = fmt.Sprintf("http://127.0.0.1:%d", port)
+		}
+	}
+	return params
+}
+
+// requestParams 创建请求中的模板参数
+func requestParams(req CreateRequest) map[string]interface{} {
+	params := map[string]interface{}{}
+	if t, err := nginx.GetTemplate(req.Type); err == nil {
+		params = fieldParams(t, req.PHP, req.Port, req.Target)
+	}
+	for k, v := range req.Params {
+		params[k] = v
+	}
+	return params
+}
+
+// storedParams 站点已保存的模板参数；CLI 导入的站点没有参数，由元数据推导
+func storedParams(record *models.Site, t *nginx.Template) map[string]interface{} {
+	if len(record.Params) > 0 {
+		params := map[string]interface{}{}
+		for k, v := range record.Params {
+			params[k] = v
+		}
+		return params
+	}
+	return fieldParams(t, record.PHPVersion, record.Port, record.Target)
+}
+
+// renderSite 用站点模板渲染配置，成功后把校验过的参数写回 record（不保存）
+func renderSite(record *models.Site, paths *layout.Paths, params map[string]interface{}, created time.Time) (string, error) {
+	name := record.Template
+	if name == "" {
+		name = record.Type
+	}
+	t, err := nginx.GetTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	values, err := t.Validate(params)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := nginx.Render(name, nginx.Site{
+		Domain:    record.Domain,
+		Root:      record.Root,
+		AccessLog: paths.AccessLog,
+		ErrorLog:  paths.ErrorLog,
+		Created:   created.Format("2006-01-02"),
+		SSLCert:   record.SSLCert,
+		SSLKey:    record.SSLKey,
+		Aliases:   record.Aliases,
+		Redirects: redirectGroups(record.Redirects),
+		Canonical: record.Canonical,
+		Backends:  upstreamGroup(record, name),
+	}, values)
+	if err != nil {
+		return "", err
+	}
+
+	record.Template = name
+	record.Params = values
+	record.PHPVersion, _ = values["php_version"].(string)
+	record.Port, _ = values["port"].(int)
+	record.Target, _ = values["target"].(string)
+	if record.Target == "" && record.Port != 0 {
+		record.Target = fmt.Sprintf("http://127.0.0.1:%d", record.Port)
+	}
+	return config, nil
+}
+
+// modifiedError 配置在上次生成后被手工或结构化修改过时，拒绝用模板重新生成以免覆盖这些修改
+func modifiedError(c *fiber.Ctx) error {
+	return c.Status(409).JSON(fiber.Map{
+		"status":  false,
+		"message": "配置在上次生成后被修改过，重新生成会覆盖这些修改；请先在配置历史中确认后重新生成配置",
+	})
+}
+
+// templateError 参数校验失败返回 400 和逐项错误
+func templateError(c *fiber.Ctx, err error) error {
+	var ve *nginx.ValidationError
+	if errors.As(err, &ve) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  false,
+			"message": "模板参数无效",
+			"errors":  ve.Errors,
+		})
+	}
+	return c.Status(400).JSON(fiber.Map{"status": false, "message": "生成配置失败: " + err.Error()})
+}
+
+// ListTemplates 列出站点配置模板及其参数
+func ListTemplates(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data":   nginx.Templates(),
+	})
+}
+
+// GetTemplate 获取模板详情及当前生效的源码
+func GetTemplate(c *fiber.Ctx) error {
+	t, err := nginx.GetTemplate(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "模板不存在"})
+	}
+
+	source, err := t.Source()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取模板失败"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"template": t,
+			"source":   source,
+		},
+	})
+}
+
+// PreviewTemplate 用给定参数预览生成的配置，不写入任何文件
+func PreviewTemplate(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if _, err := nginx.GetTemplate(name); err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "模板不存在"})
+	}
+
+	var req struct {
+		Domain string                 `json:"domain"`
+		Params map[string]interface{} `json:"params"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	if req.Domain == "" {
+		req.Domain = "example.com"
+	}
+	if !isValidDomain(req.Domain) {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的域名格式"})
+	}
+
+	record := &models.Site{Domain: req.Domain, Type: name, Root: siteRoot(req.Domain, name)}
+	config, err := renderSite(record, layout.For(layout.Canonical, req.Domain), req.Params, time.Now())
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"config": config,
+			"params": record.Params,
+		},
+	})
+}
+
+// Rerender 用站点保存的模板参数重新生成配置；可传入 params 覆盖部分参数，dry_run 仅预览
+func Rerender(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+
+	var req struct {
+		Params map[string]interface{} `json:"params"`
+		DryRun bool                   `json:"dry_run"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+		}
+	}
+
+	record, err := lookupSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	paths := layout.Resolve(domain)
+	if record == nil || !paths.Exists() {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+
+	name := record.Template
+	if name == "" {
+		name = record.Type
+	}
+	t, err := nginx.GetTemplate(name)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点类型没有可用的模板"})
+	}
+
+	params := storedParams(record, t)
+	for k, v := range req.Params {
+		params[k] = v
+	}
+	config, err := renderSite(record, paths, params, record.CreatedAt)
+	if err != nil {
+		return templateError(c, err)
+	}
+
+	if req.DryRun {
+		return c.JSON(fiber.Map{
+			"status": true,
+			"data":   fiber.Map{"config": config, "params": record.Params},
+		})
+	}
+
+	if err := applyRendered(c, paths, config, "重新生成配置"); err != nil {
+		return applyError(c, err)
+	}
+
+	if err := record.Update(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存站点参数失败"})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"message": "配置已重新生成",
+		"data":    fiber.Map{"config": config, "params": record.Params},
+	})
+}
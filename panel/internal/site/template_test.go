@@ -0,0 +1,203 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+)
+
+type apiResult struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Errors  []struct {
+		Param string `json:"param"`
+	} `json:"errors"`
+	Output []string `json:"output"`
+}
+
+func newTemplateApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	app.Get("/sites/templates", ListTemplates)
+	app.Get("/sites/templates/:name", GetTemplate)
+	app.Post("/sites/templates/:name/preview", PreviewTemplate)
+	app.Post("/sites", Create)
+	app.Post("/sites/:domain/render", Rerender)
+	return newTestClient(t, app)
+}
+
+// newTestClient 返回向 app 发送 JSON 请求的函数
+func newTestClient(t *testing.T, app *fiber.App) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	return func(method, path, body string) (int, apiResult) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		var result apiResult
+		json.Unmarshal(data, &result)
+		return resp.StatusCode, result
+	}
+}
+
+func readConfig(t *testing.T, domain string) string {
+	t.Helper()
+	data, err := os.ReadFile(layout.Resolve(domain).Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCreateRendersTemplate(t *testing.T) {
+	setupDirs(t)
+	do := newTemplateApp(t)
+
+	status, result := do("POST", "/sites", `{"domain":"api.example.com","type":"node","port":3000,"params":{"websocket":false}}`)
+	if status != 200 {
+		t.Fatalf("Create returned %d: %+v", status, result)
+	}
+	config := readConfig(t, "api.example.com")
+	if !strings.Contains(config, "proxy_pass http://127.0.0.1:3000;") || strings.Contains(config, "Upgrade") {
+		t.Errorf("Unexpected config:\n%s", config)
+	}
+	if !strings.Contains(config, "access_log "+filepath.Join(layout.LogsDir, "nginx", "api.example.com.access.log")) {
+		t.Errorf("Expected canonical log path:\n%s", config)
+	}
+
+	record, _ := models.GetSite("api.example.com")
+	if record.Template != "node" || record.Port != 3000 || record.Params["websocket"] != false || record.CreatedBy != "alice" {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+
+	status, _ = do("POST", "/sites", `{"domain":"shop.example.com","type":"laravel","php":"8.2"}`)
+	if status != 200 {
+		t.Fatalf("Create laravel returned %d", status)
+	}
+	if config := readConfig(t, "shop.example.com"); !strings.Contains(config, "php8.2-fpm.sock") || !strings.Contains(config, "shop.example.com/public;") {
+		t.Errorf("Unexpected laravel config:\n%s", config)
+	}
+
+	// 参数无效时不创建任何文件
+	status, result = do("POST", "/sites", `{"domain":"bad.example.com","type":"proxy","params":{"target":"http://x;"}}`)
+	if status != 400 || len(result.Errors) != 1 || result.Errors[0].Param != "target" {
+		t.Errorf("Expected target validation error, got %d %+v", status, result)
+	}
+	if layout.Resolve("bad.example.com").Exists() {
+		t.Error("Config should not be written for invalid params")
+	}
+	if _, err := os.Stat(filepath.Join(sitesDir, "bad.example.com")); !os.IsNotExist(err) {
+		t.Error("Site directory should not be created for invalid params")
+	}
+}
+
+func TestTemplateEndpoints(t *testing.T) {
+	setupDirs(t)
+	do := newTemplateApp(t)
+
+	status, result := do("GET", "/sites/templates", "")
+	var templates []nginx.Template
+	json.Unmarshal(result.Data, &templates)
+	if status != 200 || len(templates) != 9 {
+		t.Fatalf("Expected 9 templates, got %d (%d)", len(templates), status)
+	}
+
+	if status, _ := do("GET", "/sites/templates/spa", ""); status != 200 {
+		t.Errorf("GetTemplate returned %d", status)
+	}
+	if status, _ := do("GET", "/sites/templates/perl", ""); status != 404 {
+		t.Errorf("Expected 404 for unknown template, got %d", status)
+	}
+
+	status, result = do("POST", "/sites/templates/python/preview", `{"domain":"py.example.com","params":{"port":8000,"protocol":"uwsgi"}}`)
+	var preview struct {
+		Config string `json:"config"`
+	}
+	json.Unmarshal(result.Data, &preview)
+	if status != 200 || !strings.Contains(preview.Config, "uwsgi_pass 127.0.0.1:8000;") || !strings.Contains(preview.Config, "server_name py.example.com;") {
+		t.Errorf("Unexpected preview %d:\n%s", status, preview.Config)
+	}
+	if layout.Resolve("py.example.com").Exists() {
+		t.Error("Preview must not write files")
+	}
+
+	status, result = do("POST", "/sites/templates/python/preview", `{}`)
+	if status != 400 || len(result.Errors) != 1 || result.Errors[0].Param != "port" {
+		t.Errorf("Expected port error, got %d %+v", status, result)
+	}
+}
+
+func TestRerender(t *testing.T) {
+	setupDirs(t)
+	do := newTemplateApp(t)
+
+	// CLI 创建的站点没有保存参数，从元数据推导
+	p := layout.For(layout.CLI, "app.example.com")
+	writeFile(t, p.Config, proxyConfig("app.example.com", "http://127.0.0.1:3002"))
+	writeFile(t, filepath.Join(supervisorConfDir, "app.example.com.conf"), "command=node server.js\n")
+
+	status, result := do("POST", "/sites/app.example.com/render", `{"dry_run":true}`)
+	var data struct {
+		Config string                 `json:"config"`
+		Params map[string]interface{} `json:"params"`
+	}
+	json.Unmarshal(result.Data, &data)
+	if status != 200 || !strings.Contains(data.Config, "proxy_pass http://127.0.0.1:3002;") {
+		t.Fatalf("Unexpected dry run %d %+v:\n%s", status, result, data.Config)
+	}
+	if strings.Contains(readConfig(t, "app.example.com"), "# Type: node") {
+		t.Error("Dry run must not write the config")
+	}
+
+	status, result = do("POST", "/sites/app.example.com/render", `{"params":{"port":4000}}`)
+	if status != 200 {
+		t.Fatalf("Rerender returned %d: %+v", status, result)
+	}
+	config := readConfig(t, "app.example.com")
+	if !strings.Contains(config, "# Type: node") || !strings.Contains(config, "proxy_pass http://127.0.0.1:4000;") {
+		t.Errorf("Unexpected config:\n%s", config)
+	}
+	record, _ := models.GetSite("app.example.com")
+	if record.Template != "node" || record.Port != 4000 || record.Params["port"] != float64(4000) {
+		t.Errorf("Expected stored params to be updated, got %+v", record)
+	}
+
+	// nginx -t 失败时恢复原配置，参数不变
+	nginx.Test = func(string) error { return errors.New("nginx: [emerg] host not found") }
+	status, result = do("POST", "/sites/app.example.com/render", `{"params":{"port":5000}}`)
+	if status != 400 || !strings.Contains(result.Message, "host not found") {
+		t.Errorf("Expected nginx error, got %d %+v", status, result)
+	}
+	if readConfig(t, "app.example.com") != config {
+		t.Error("Expected original config to be restored")
+	}
+	if record, _ := models.GetSite("app.example.com"); record.Port != 4000 {
+		t.Errorf("Expected stored params to be kept, got %+v", record)
+	}
+
+	if status, _ := do("POST", "/sites/app.example.com/render", `{"params":{"port":"x"}}`); status != 400 {
+		t.Errorf("Expected 400 for invalid params, got %d", status)
+	}
+	if status, _ := do("POST", "/sites/missing.example.com/render", ""); status != 404 {
+		t.Errorf("Expected 404, got %d", status)
+	}
+}
@@ -0,0 +1,435 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+)
+
+const (
+	// healthWindow 被动健康统计的时间窗口，窗口内有失败记录的后端标记为 failing
+	healthWindow = 5 * time.Minute
+	// healthTailSize 统计时最多读取错误日志末尾的字节数
+	healthTailSize = 512 << 10
+)
+
+var (
+	failTimeoutRe = regexp.MustCompile(`^\d+(ms|s|m|h)?$`)
+	// nginx 错误日志中与上游有关的行，upstream 字段为实际连接的后端
+	upstreamLogRe = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(\w+)\] \d+#\d+: (?:\*\d+ )?(.*?)(?:, client: .*)?, upstream: "[a-z]+://([^"]*)"`)
+)
+
+// upstreamGroup 代理站点的负载均衡后端组；其他类型的站点和没有后端的代理站点直接代理到目标地址
+func upstreamGroup(record *models.Site, template string) *nginx.Upstream {
+	if template != "proxy" || len(record.Upstream.Servers) == 0 {
+		return nil
+	}
+	group := &nginx.Upstream{Name: nginx.UpstreamName(record.Domain), Method: record.Upstream.Method}
+	for _, s := range record.Upstream.Servers {
+		group.Servers = append(group.Servers, nginx.UpstreamServer{
+			Address:     s.Address,
+			Weight:      s.Weight,
+			MaxFails:    s.MaxFails,
+			FailTimeout: s.FailTimeout,
+			Backup:      s.Backup,
+			Down:        s.Draining,
+		})
+	}
+	return group
+}
+
+// checkUpstreamServer 校验后端地址和参数：地址为 host:port 或 unix:/path
+func checkUpstreamServer(s *models.UpstreamServer) error {
+	if path, ok := strings.CutPrefix(s.Address, "unix:"); ok {
+		if !filepath.IsAbs(path) || strings.ContainsAny(path, ";{}\"' \t\n\\$") {
+			return fmt.Errorf("无效的 socket 路径: %s", path)
+		}
+	} else {
+		host, port, err := net.SplitHostPort(s.Address)
+		if err != nil || host == "" {
+			return fmt.Errorf("后端地址格式应为 host:port 或 unix:/path")
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("端口范围 1-65535")
+		}
+		if net.ParseIP(host) == nil && host != "localhost" && !isValidDomain(host) {
+			return fmt.Errorf("无效的后端主机: %s", host)
+		}
+	}
+	if s.Weight < 0 || s.Weight > 100 {
+		return fmt.Errorf("权重范围 0-100，0 为默认权重 1")
+	}
+	if s.MaxFails < 0 || s.MaxFails > 100 {
+		return fmt.Errorf("最大失败次数范围 0-100")
+	}
+	if s.FailTimeout != "" && !failTimeoutRe.MatchString(s.FailTimeout) {
+		return fmt.Errorf("失败超时格式应为 10s")
+	}
+	return nil
+}
+
+// targetServer 代理目标对应的后端地址，添加第一个后端时保留原目标
+func targetServer(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// BackendHealth 根据错误日志推断的被动健康状态。nginx 开源版不提供主动检查，
+// 后端在 fail_timeout 内失败 max_fails 次后被暂停使用 fail_timeout，这里按同样的规则统计
+type BackendHealth struct {
+	State       string     `json:"state"`    // up、failing（窗口内有失败）、unavailable（已被暂停使用）或 draining
+	Failures    int        `json:"failures"` // 统计窗口内的失败次数
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type upstreamFailure struct {
+	at       time.Time
+	message  string
+	disabled bool // nginx 记录的 "upstream server temporarily disabled"
+}
+
+// readTail 读取文件末尾最多 size 字节，丢弃可能不完整的首行
+func readTail(path string, size int64) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - size
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// upstreamAddress 日志 upstream 字段中的后端地址：http://10.0.0.2:8080/path 或 http://unix:/run/app.sock:/path
+func upstreamAddress(field string) string {
+	if path, ok := strings.CutPrefix(field, "unix:"); ok {
+		if i := strings.Index(path, ":"); i >= 0 {
+			path = path[:i]
+		}
+		return "unix:" + path
+	}
+	if i := strings.Index(field, "/"); i >= 0 {
+		field = field[:i]
+	}
+	return field
+}
+
+// upstreamFailures 按后端地址汇总错误日志中 since 之后的上游失败
+func upstreamFailures(errorLog string, since time.Time) map[string][]upstreamFailure {
+	failures := map[string][]upstreamFailure{}
+	lines, err := readTail(errorLog, healthTailSize)
+	if err != nil {
+		return failures
+	}
+	for _, line := range lines {
+		m := upstreamLogRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		at, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local)
+		if err != nil || at.Before(since) {
+			continue
+		}
+		addr := upstreamAddress(m[4])
+		failures[addr] = append(failures[addr], upstreamFailure{
+			at:       at,
+			message:  m[3],
+			disabled: strings.Contains(m[3], "temporarily disabled"),
+		})
+	}
+	return failures
+}
+
+// backendHealth 统计单个后端的被动健康状态
+func backendHealth(s models.UpstreamServer, failures []upstreamFailure, now time.Time) BackendHealth {
+	h := BackendHealth{State: "up"}
+	timeout := 10 * time.Second
+	if d, err := time.ParseDuration(s.FailTimeout); err == nil {
+		timeout = d
+	} else if n, err := strconv.Atoi(s.FailTimeout); err == nil {
+		timeout = time.Duration(n) * time.Second
+	}
+	maxFails := s.MaxFails
+	if maxFails == 0 {
+		maxFails = 1
+	}
+
+	recent := 0
+	disabled := false
+	for _, f := range failures {
+		if f.disabled {
+			disabled = disabled || now.Sub(f.at) <= timeout
+			continue
+		}
+		h.Failures++
+		if now.Sub(f.at) <= timeout {
+			recent++
+		}
+		at := f.at
+		h.LastFailure, h.LastError = &at, f.message
+	}
+	switch {
+	case s.Draining:
+		h.State = "draining"
+	case disabled || recent >= maxFails:
+		h.State = "unavailable"
+	case h.Failures > 0:
+		h.State = "failing"
+	}
+	return h
+}
+
+// UpstreamServerStatus 后端及其健康状态
+type UpstreamServerStatus struct {
+	models.UpstreamServer
+	Health BackendHealth `json:"health"`
+}
+
+func upstreamData(record *models.Site, paths *layout.Paths) fiber.Map {
+	now := time.Now()
+	failures := upstreamFailures(paths.ErrorLog, now.Add(-healthWindow))
+	servers := []UpstreamServerStatus{}
+	for _, s := range record.Upstream.Servers {
+		servers = append(servers, UpstreamServerStatus{s, backendHealth(s, failures[s.Address], now)})
+	}
+	return fiber.Map{
+		"name":    nginx.UpstreamName(record.Domain),
+		"method":  record.Upstream.Method,
+		"target":  record.Target,
+		"servers": servers,
+	}
+}
+
+// GetUpstream 获取代理站点的负载均衡后端及被动健康状态
+func GetUpstream(c *fiber.Ctx) error {
+	domain := c.Params("domain")
+	record, err := lookupSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	if record == nil {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	if record.Type != "proxy" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "只有反向代理站点支持负载均衡"})
+	}
+	return c.JSON(fiber.Map{"status": true, "data": upstreamData(record, layout.Resolve(domain))})
+}
+
+// updateUpstream 修改后端组后用模板重新生成配置并保存；配置在上次生成后被修改过时返回 409。
+// 配置通过事务写入，nginx -t 失败时原配置不变
+func updateUpstream(c *fiber.Ctx, edit func(record *models.Site) error, comment, success string) error {
+	domain := c.Params("domain")
+	record, err := lookupSite(domain)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "读取站点失败"})
+	}
+	paths := layout.Resolve(domain)
+	if record == nil || !paths.Exists() {
+		return c.Status(404).JSON(fiber.Map{"status": false, "message": "站点不存在"})
+	}
+	if record.Type != "proxy" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "只有反向代理站点支持负载均衡"})
+	}
+	t, err := siteTemplate(record)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "该站点类型没有可用的模板"})
+	}
+	if !rendered(paths) {
+		return modifiedError(c)
+	}
+	if err := edit(record); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+
+	fillCertificate(record, paths)
+	config, err := renderSite(record, paths, storedParams(record, t), record.CreatedAt)
+	if err != nil {
+		return templateError(c, err)
+	}
+	if err := applyRendered(c, paths, config, comment); err != nil {
+		return applyError(c, err)
+	}
+	if err := record.Update(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": "保存负载均衡配置失败"})
+	}
+	return c.JSON(fiber.Map{"status": true, "message": success, "data": upstreamData(record, paths)})
+}
+
+// activeServers 未排空的后端数量
+func activeServers(u models.Upstream) int {
+	n := 0
+	for _, s := range u.Servers {
+		if !s.Draining {
+			n++
+		}
+	}
+	return n
+}
+
+// SetUpstreamMethod 设置负载均衡方式：round_robin（默认）、least_conn 或 ip_hash
+func SetUpstreamMethod(c *fiber.Ctx) error {
+	var req struct {
+		Method string `json:"method"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	method := strings.TrimSpace(req.Method)
+	if method == "round_robin" {
+		method = ""
+	}
+	return updateUpstream(c, func(record *models.Site) error {
+		known := false
+		for _, m := range nginx.UpstreamMethods {
+			known = known || m == method
+		}
+		if !known {
+			return fmt.Errorf("负载均衡方式可选值: round_robin, least_conn, ip_hash")
+		}
+		if method == "ip_hash" {
+			for _, s := range record.Upstream.Servers {
+				if s.Backup {
+					return fmt.Errorf("ip_hash 不支持备用后端，请先移除 %s", s.Address)
+				}
+			}
+		}
+		record.Upstream.Method = method
+		return nil
+	}, "设置负载均衡方式", "负载均衡方式已保存")
+}
+
+// AddUpstreamServer 添加后端；站点还没有后端时先把当前代理目标加入后端组，proxy_pass 随之指向后端组
+func AddUpstreamServer(c *fiber.Ctx) error {
+	var req models.UpstreamServer
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	req.Address = strings.TrimSpace(req.Address)
+	req.Draining = false
+	if err := checkUpstreamServer(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return updateUpstream(c, func(record *models.Site) error {
+		if req.Backup && record.Upstream.Method == "ip_hash" {
+			return fmt.Errorf("ip_hash 不支持备用后端")
+		}
+		if len(record.Upstream.Servers) == 0 {
+			if addr := targetServer(record.Target); addr != "" && addr != req.Address {
+				record.Upstream.Servers = append(record.Upstream.Servers, models.UpstreamServer{Address: addr})
+			}
+		}
+		if record.Upstream.Server(req.Address) != nil {
+			return fmt.Errorf("后端 %s 已存在", req.Address)
+		}
+		record.Upstream.Servers = append(record.Upstream.Servers, req)
+		return nil
+	}, "添加后端 "+req.Address, "后端已添加")
+}
+
+// upstreamParam 路径中的后端地址，unix:/path 需要转义斜杠
+func upstreamParam(c *fiber.Ctx) string {
+	addr, err := url.PathUnescape(c.Params("address"))
+	if err != nil {
+		return c.Params("address")
+	}
+	return addr
+}
+
+// UpstreamServerAction 排空（drain）或恢复（resume）后端。排空的后端标记为 down，
+// 不再分配新请求，nginx 重载后旧 worker 处理完已有请求再退出
+func UpstreamServerAction(c *fiber.Ctx) error {
+	addr := upstreamParam(c)
+	action := c.Params("action")
+	if action != "drain" && action != "resume" {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的操作"})
+	}
+	comment, success := "排空后端 "+addr, "后端已排空，不再分配新请求"
+	if action == "resume" {
+		comment, success = "恢复后端 "+addr, "后端已恢复"
+	}
+	return updateUpstream(c, func(record *models.Site) error {
+		s := record.Upstream.Server(addr)
+		if s == nil {
+			return fmt.Errorf("后端 %s 不存在", addr)
+		}
+		if action == "drain" && !s.Draining && activeServers(record.Upstream) == 1 {
+			return fmt.Errorf("至少保留一个可用的后端")
+		}
+		s.Draining = action == "drain"
+		return nil
+	}, comment, success)
+}
+
+// RemoveUpstreamServer 删除后端，须先排空，force=1 时直接删除；删除最后一个后端后恢复直接代理到目标地址
+func RemoveUpstreamServer(c *fiber.Ctx) error {
+	addr := upstreamParam(c)
+	force := c.QueryBool("force")
+	return updateUpstream(c, func(record *models.Site) error {
+		servers := []models.UpstreamServer{}
+		for _, s := range record.Upstream.Servers {
+			if s.Address != addr {
+				servers = append(servers, s)
+				continue
+			}
+			if !s.Draining && !force {
+				return fmt.Errorf("请先排空后端 %s 再删除", addr)
+			}
+		}
+		if len(servers) == len(record.Upstream.Servers) {
+			return fmt.Errorf("后端 %s 不存在", addr)
+		}
+		if len(servers) > 0 && activeServers(models.Upstream{Servers: servers}) == 0 {
+			return fmt.Errorf("至少保留一个可用的后端")
+		}
+		record.Upstream.Servers = servers
+		return nil
+	}, "删除后端 "+addr, "后端已删除")
+}
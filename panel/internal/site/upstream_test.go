@@ -0,0 +1,206 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/layout"
+)
+
+func newUpstreamApp(t *testing.T) func(method, path, body string) (int, apiResult) {
+	t.Helper()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("username", "alice")
+		return c.Next()
+	})
+	app.Post("/sites", Create)
+	app.Get("/sites/:domain/upstream", GetUpstream)
+	app.Put("/sites/:domain/upstream/method", SetUpstreamMethod)
+	app.Post("/sites/:domain/upstream/servers", AddUpstreamServer)
+	app.Delete("/sites/:domain/upstream/servers/:address", RemoveUpstreamServer)
+	app.Post("/sites/:domain/upstream/servers/:address/:action", UpstreamServerAction)
+	return newTestClient(t, app)
+}
+
+type upstreamResult struct {
+	Name    string                 `json:"name"`
+	Method  string                 `json:"method"`
+	Servers []UpstreamServerStatus `json:"servers"`
+}
+
+func TestSiteUpstream(t *testing.T) {
+	setupDirs(t)
+	do := newUpstreamApp(t)
+
+	if status, result := do("POST", "/sites", `{"domain":"app.example.com","type":"proxy","params":{"target":"http://127.0.0.1:8080/api/"}}`); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites", `{"domain":"static.example.com","type":"static"}`); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	if status, result := do("GET", "/sites/static.example.com/upstream", ""); status != 400 || !strings.Contains(result.Message, "反向代理") {
+		t.Errorf("Expected non-proxy site to be rejected, got %d %+v", status, result)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               string
+	}{
+		{"POST", "/sites/app.example.com/upstream/servers", `{"address":"10.0.0.2"}`, "host:port"},
+		{"POST", "/sites/app.example.com/upstream/servers", `{"address":"10.0.0.2:8080;return"}`, "端口"},
+		{"POST", "/sites/app.example.com/upstream/servers", `{"address":"unix:run/app.sock"}`, "socket"},
+		{"POST", "/sites/app.example.com/upstream/servers", `{"address":"10.0.0.2:8080","weight":500}`, "权重"},
+		{"POST", "/sites/app.example.com/upstream/servers", `{"address":"10.0.0.2:8080","fail_timeout":"10 s"}`, "失败超时"},
+		{"PUT", "/sites/app.example.com/upstream/method", `{"method":"random"}`, "可选值"},
+		{"POST", "/sites/app.example.com/upstream/servers/10.0.0.9:80/pause", "", "无效的操作"},
+	} {
+		if status, result := do(tt.method, tt.path, tt.body); status != 400 || !strings.Contains(result.Message, tt.want) {
+			t.Errorf("%s %s %s: expected 400 %q, got %d %+v", tt.method, tt.path, tt.body, tt.want, status, result)
+		}
+	}
+
+	// 添加第一个后端时保留原代理目标，proxy_pass 指向后端组并沿用目标路径
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers", `{"address":"10.0.0.2:8080","weight":3,"max_fails":2,"fail_timeout":"30s"}`); status != 200 {
+		t.Fatalf("AddUpstreamServer failed: %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers", `{"address":"10.0.0.3:8080","backup":true}`); status != 200 {
+		t.Fatalf("AddUpstreamServer failed: %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers", `{"address":"10.0.0.2:8080"}`); status != 400 || !strings.Contains(result.Message, "已存在") {
+		t.Errorf("Expected duplicate backend to be rejected, got %d %+v", status, result)
+	}
+	if status, result := do("PUT", "/sites/app.example.com/upstream/method", `{"method":"ip_hash"}`); status != 400 || !strings.Contains(result.Message, "备用后端") {
+		t.Errorf("Expected ip_hash with backup to be rejected, got %d %+v", status, result)
+	}
+	if status, result := do("PUT", "/sites/app.example.com/upstream/method", `{"method":"least_conn"}`); status != 200 {
+		t.Fatalf("SetUpstreamMethod failed: %d %+v", status, result)
+	}
+	config := readConfig(t, "app.example.com")
+	for _, want := range []string{
+		"upstream upstream_app_example_com {\n    least_conn;\n    server 127.0.0.1:8080;\n    server 10.0.0.2:8080 weight=3 max_fails=2 fail_timeout=30s;\n    server 10.0.0.3:8080 backup;\n}",
+		"proxy_pass http://upstream_app_example_com/api/;",
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("Expected %q in config:\n%s", want, config)
+		}
+	}
+
+	// 删除前须先排空
+	if status, result := do("DELETE", "/sites/app.example.com/upstream/servers/127.0.0.1:8080", ""); status != 400 || !strings.Contains(result.Message, "先排空") {
+		t.Errorf("Expected removal of an active backend to be rejected, got %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers/127.0.0.1:8080/drain", ""); status != 200 {
+		t.Fatalf("Drain failed: %d %+v", status, result)
+	}
+	if config := readConfig(t, "app.example.com"); !strings.Contains(config, "server 127.0.0.1:8080 down;") {
+		t.Errorf("Expected drained backend to be marked down:\n%s", config)
+	}
+
+	// 面板外修改过的配置不会被重新生成覆盖
+	config = readConfig(t, "app.example.com")
+	edited := config + "# keep me\n"
+	writeFile(t, layout.Resolve("app.example.com").Config, edited)
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers/10.0.0.2:8080/drain", ""); status != 409 {
+		t.Errorf("Expected 409 for modified config, got %d %+v", status, result)
+	}
+	if readConfig(t, "app.example.com") != edited {
+		t.Error("Expected modified config to be kept")
+	}
+	writeFile(t, layout.Resolve("app.example.com").Config, config)
+
+	// 被动健康：按 fail_timeout / max_fails 统计错误日志中的上游失败
+	now := time.Now()
+	logLine := func(ago time.Duration, level, msg, upstream string) string {
+		return fmt.Sprintf("%s [%s] 100#100: *1 %s, client: 1.2.3.4, server: app.example.com, request: \"GET / HTTP/1.1\", upstream: \"http://%s/api/\", host: \"app.example.com\"\n",
+			now.Add(-ago).Format("2006/01/02 15:04:05"), level, msg, upstream)
+	}
+	writeFile(t, layout.Resolve("app.example.com").ErrorLog,
+		logLine(time.Hour, "error", "connect() failed (111: Connection refused) while connecting to upstream", "10.0.0.3:8080")+
+			logLine(2*time.Minute, "error", "upstream timed out (110: Connection timed out) while reading response header from upstream", "10.0.0.2:8080")+
+			logLine(time.Second, "error", "connect() failed (111: Connection refused) while connecting to upstream", "10.0.0.3:8080")+
+			"2024/01/01 00:00:00 [notice] 1#1: signal process started\n")
+
+	var data upstreamResult
+	status, result := do("GET", "/sites/app.example.com/upstream", "")
+	json.Unmarshal(result.Data, &data)
+	if status != 200 || data.Name != "upstream_app_example_com" || data.Method != "least_conn" || len(data.Servers) != 3 {
+		t.Fatalf("Unexpected upstream: %d %+v", status, data)
+	}
+	states := map[string]BackendHealth{}
+	for _, s := range data.Servers {
+		states[s.Address] = s.Health
+	}
+	if h := states["127.0.0.1:8080"]; h.State != "draining" {
+		t.Errorf("Expected draining backend, got %+v", h)
+	}
+	if h := states["10.0.0.2:8080"]; h.State != "failing" || h.Failures != 1 || !strings.Contains(h.LastError, "timed out") {
+		t.Errorf("Expected failing backend, got %+v", h)
+	}
+	if h := states["10.0.0.3:8080"]; h.State != "unavailable" || h.Failures != 1 {
+		t.Errorf("Expected unavailable backend, got %+v", h)
+	}
+
+	if status, result := do("DELETE", "/sites/app.example.com/upstream/servers/127.0.0.1:8080", ""); status != 200 {
+		t.Fatalf("RemoveUpstreamServer failed: %d %+v", status, result)
+	}
+	// 最后一个主后端不能排空，force 可以直接删除
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers/10.0.0.3:8080/drain", ""); status != 200 {
+		t.Fatalf("Drain failed: %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers/10.0.0.2:8080/drain", ""); status != 400 || !strings.Contains(result.Message, "至少保留") {
+		t.Errorf("Expected draining the last backend to be rejected, got %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers/10.0.0.3:8080/resume", ""); status != 200 {
+		t.Fatalf("Resume failed: %d %+v", status, result)
+	}
+	if status, result := do("DELETE", "/sites/app.example.com/upstream/servers/10.0.0.3:8080?force=1", ""); status != 200 {
+		t.Fatalf("Force remove failed: %d %+v", status, result)
+	}
+	if status, result := do("DELETE", "/sites/app.example.com/upstream/servers/10.0.0.2:8080?force=1", ""); status != 200 {
+		t.Fatalf("Force remove failed: %d %+v", status, result)
+	}
+
+	// 删除全部后端后恢复直接代理到目标地址
+	config = readConfig(t, "app.example.com")
+	if strings.Contains(config, "upstream ") || !strings.Contains(config, "proxy_pass http://127.0.0.1:8080/api/;") {
+		t.Errorf("Expected plain proxy_pass after removing all backends:\n%s", config)
+	}
+	if status, result := do("DELETE", "/sites/app.example.com/upstream/servers/10.0.0.2:8080", ""); status != 400 || !strings.Contains(result.Message, "不存在") {
+		t.Errorf("Expected missing backend, got %d %+v", status, result)
+	}
+}
+
+func TestUpstreamSocketAddress(t *testing.T) {
+	setupDirs(t)
+	do := newUpstreamApp(t)
+
+	if status, result := do("POST", "/sites", `{"domain":"app.example.com","type":"proxy","params":{"target":"http://127.0.0.1:8080"}}`); status != 200 {
+		t.Fatalf("Create failed: %d %+v", status, result)
+	}
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers", `{"address":"unix:/run/app.sock"}`); status != 200 {
+		t.Fatalf("AddUpstreamServer failed: %d %+v", status, result)
+	}
+	writeFile(t, layout.Resolve("app.example.com").ErrorLog, fmt.Sprintf(
+		"%s [warn] 100#100: *1 upstream server temporarily disabled while connecting to upstream, client: 1.2.3.4, server: app.example.com, request: \"GET / HTTP/1.1\", upstream: \"http://unix:/run/app.sock:/\", host: \"app.example.com\"\n",
+		time.Now().Format("2006/01/02 15:04:05")))
+
+	var data upstreamResult
+	_, result := do("GET", "/sites/app.example.com/upstream", "")
+	json.Unmarshal(result.Data, &data)
+	if len(data.Servers) != 2 || data.Servers[1].Address != "unix:/run/app.sock" || data.Servers[1].Health.State != "unavailable" {
+		t.Fatalf("Unexpected upstream: %+v", data)
+	}
+
+	if status, result := do("POST", "/sites/app.example.com/upstream/servers/unix:%2Frun%2Fapp.sock/drain", ""); status != 200 {
+		t.Fatalf("Drain failed: %d %+v", status, result)
+	}
+	if config := readConfig(t, "app.example.com"); !strings.Contains(config, "server unix:/run/app.sock down;") {
+		t.Errorf("Expected drained socket backend:\n%s", config)
+	}
+}
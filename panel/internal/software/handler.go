@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/php"
 )
 
 type Software struct {
@@ -15,16 +17,16 @@ type Software struct {
 	Installed   bool   `json:"installed"`
 }
 
-// 预定义软件列表
-var softwareList = []struct {
+type softwareEntry struct {
 	Name        string
 	Service     string
 	VersionCmd  string
 	Description string
-}{
+}
+
+// 预定义软件列表，PHP-FPM 按服务器上的版本动态加入
+var softwareList = []softwareEntry{
 	{"nginx", "nginx", "nginx -v 2>&1 | grep -oP '\\d+\\.\\d+\\.\\d+'", "高性能 Web 服务器"},
-	{"php8.3-fpm", "php8.3-fpm", "php8.3 -v 2>&1 | head -1 | grep -oP '\\d+\\.\\d+\\.\\d+'", "PHP 8.3 FastCGI 进程管理器"},
-	{"php8.1-fpm", "php8.1-fpm", "php8.1 -v 2>&1 | head -1 | grep -oP '\\d+\\.\\d+\\.\\d+'", "PHP 8.1 FastCGI 进程管理器"},
 	{"mysql", "mysql", "mysql --version 2>&1 | grep -oP '\\d+\\.\\d+\\.\\d+'", "MySQL 数据库服务器"},
 	{"mariadb", "mariadb", "mariadb --version 2>&1 | grep -oP '\\d+\\.\\d+\\.\\d+'", "MariaDB 数据库服务器"},
 	{"redis", "redis-server", "redis-server --version 2>&1 | grep -oP 'v=\\d+\\.\\d+\\.\\d+' | cut -d= -f2", "Redis 内存数据库"},
@@ -34,11 +36,25 @@ var softwareList = []struct {
 	{"mongodb", "mongod", "mongod --version 2>&1 | grep -oP 'v\\d+\\.\\d+\\.\\d+' | tr -d v", "MongoDB NoSQL 数据库"},
 }
 
+// entries 软件列表，已发现的 PHP-FPM 版本排在 nginx 之后
+func entries() []softwareEntry {
+	list := []softwareEntry{softwareList[0]}
+	for _, v := range php.Versions() {
+		list = append(list, softwareEntry{
+			v.Service,
+			v.Service,
+			"php" + v.Version + " -v 2>&1 | head -1 | grep -oP '\\d+\\.\\d+\\.\\d+'",
+			"PHP " + v.Version + " FastCGI 进程管理器",
+		})
+	}
+	return append(list, softwareList[1:]...)
+}
+
 // List 获取软件列表
 func List(c *fiber.Ctx) error {
 	var results []Software
 
-	for _, sw := range softwareList {
+	for _, sw := range entries() {
 		soft := Software{
 			Name:        sw.Name,
 			Description: sw.Description,
@@ -103,7 +119,7 @@ func Reload(c *fiber.Ctx) error {
 func serviceAction(c *fiber.Ctx, name, action string) error {
 	// 验证服务名
 	valid := false
-	for _, sw := range softwareList {
+	for _, sw := range entries() {
 		if sw.Name == name || sw.Service == name {
 			name = sw.Service // 使用实际服务名
 			valid = true
@@ -140,7 +156,7 @@ func Status(c *fiber.Ctx) error {
 
 	// 验证服务名
 	var serviceName string
-	for _, sw := range softwareList {
+	for _, sw := range entries() {
 		if sw.Name == name || sw.Service == name {
 			serviceName = sw.Service
 			break
@@ -165,3 +181,29 @@ func Status(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// PHPVersion PHP 版本及其 FPM 服务状态
+type PHPVersion struct {
+	php.Version
+	Status string `json:"status"`
+}
+
+// PHPVersions 服务器上的 PHP-FPM 版本，default 为新建站点默认使用的版本（未发现任何版本时为空）
+func PHPVersions(c *fiber.Ctx) error {
+	versions := []PHPVersion{}
+	for _, v := range php.Versions() {
+		item := PHPVersion{Version: v, Status: "inactive"}
+		if output, err := exec.Command("systemctl", "is-active", v.Service).Output(); err == nil {
+			item.Status = strings.TrimSpace(string(output))
+		}
+		versions = append(versions, item)
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"versions": versions,
+			"default":  php.Default(),
+		},
+	})
+}
@@ -0,0 +1,140 @@
+package ssl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cloudflareAPI Cloudflare API 地址，测试中替换为本地服务
+var cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare 通过 Cloudflare API 管理验证用的 TXT 记录
+// 与 certbot-dns-cloudflare 一致：有 email 时使用 Global API Key，否则按 API Token 处理
+type Cloudflare struct {
+	email  string
+	key    string
+	client *http.Client
+}
+
+// NewCloudflare 创建 Cloudflare 服务商
+func NewCloudflare(email, key string) *Cloudflare {
+	return &Cloudflare{email: email, key: key, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+type cfResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+type cfRecord struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+func (cf *Cloudflare) do(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, cloudflareAPI+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cf.email != "" {
+		req.Header.Set("X-Auth-Email", cf.email)
+		req.Header.Set("X-Auth-Key", cf.key)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+cf.key)
+	}
+
+	resp, err := cf.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var r cfResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("cloudflare: %s 响应无法解析 (HTTP %d)", path, resp.StatusCode)
+	}
+	if !r.Success {
+		msgs := []string{}
+		for _, e := range r.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("cloudflare: %s", strings.Join(msgs, "; "))
+	}
+	if result != nil {
+		return json.Unmarshal(r.Result, result)
+	}
+	return nil
+}
+
+// zoneID 从记录名逐级向上查找所属的 zone
+func (cf *Cloudflare) zoneID(fqdn string) (string, error) {
+	name := strings.TrimSuffix(fqdn, ".")
+	for strings.Contains(name, ".") {
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		if err := cf.do("GET", "/zones?name="+url.QueryEscape(name), nil, &zones); err != nil {
+			return "", err
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+	return "", fmt.Errorf("cloudflare: 账号中没有 %s 所属的域名", fqdn)
+}
+
+// Present 添加 TXT 记录
+func (cf *Cloudflare) Present(fqdn, value string) error {
+	zone, err := cf.zoneID(fqdn)
+	if err != nil {
+		return err
+	}
+	record := cfRecord{Type: "TXT", Name: strings.TrimSuffix(fqdn, "."), Content: value, TTL: 120}
+	return cf.do("POST", "/zones/"+zone+"/dns_records", record, nil)
+}
+
+// CleanUp 删除 Present 添加的 TXT 记录
+func (cf *Cloudflare) CleanUp(fqdn, value string) error {
+	zone, err := cf.zoneID(fqdn)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"type": {"TXT"}, "name": {strings.TrimSuffix(fqdn, ".")}}
+	var records []cfRecord
+	if err := cf.do("GET", "/zones/"+zone+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return err
+	}
+	for _, r := range records {
+		// 部分接口返回的 TXT 内容带引号
+		if strings.Trim(r.Content, `"`) != value {
+			continue
+		}
+		if err := cf.do("DELETE", "/zones/"+zone+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package ssl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCloudflare 模拟 zones 和 dns_records 接口
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	zones   map[string]string // name -> id
+	records map[string]cfRecord
+	nextID  int
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reply := func(result interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "errors": []interface{}{}, "result": result})
+	}
+	if r.Header.Get("X-Auth-Email") != "ops@example.com" || r.Header.Get("X-Auth-Key") != "secret" {
+		w.WriteHeader(403)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": []map[string]interface{}{{"code": 9103, "message": "Unknown X-Auth-Key or X-Auth-Email"}}})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "GET" && len(parts) == 1:
+		zones := []map[string]string{}
+		if id, ok := f.zones[r.URL.Query().Get("name")]; ok {
+			zones = append(zones, map[string]string{"id": id})
+		}
+		reply(zones)
+	case r.Method == "POST" && len(parts) == 3:
+		var rec cfRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		f.nextID++
+		rec.ID = parts[1] + "-" + string(rune('0'+f.nextID))
+		f.records[rec.ID] = rec
+		reply(rec)
+	case r.Method == "GET" && len(parts) == 3:
+		list := []cfRecord{}
+		for _, rec := range f.records {
+			if rec.Name == r.URL.Query().Get("name") && rec.Type == r.URL.Query().Get("type") {
+				// 与真实接口一样返回带引号的 TXT 内容
+				rec.Content = `"` + rec.Content + `"`
+				list = append(list, rec)
+			}
+		}
+		reply(list)
+	case r.Method == "DELETE" && len(parts) == 4:
+		delete(f.records, parts[3])
+		reply(map[string]string{"id": parts[3]})
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+func TestCloudflare(t *testing.T) {
+	fake := &fakeCloudflare{zones: map[string]string{"example.com": "z1"}, records: map[string]cfRecord{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	old := cloudflareAPI
+	cloudflareAPI = server.URL
+	defer func() { cloudflareAPI = old }()
+
+	cf := NewCloudflare("ops@example.com", "secret")
+	if err := cf.Present("_acme-challenge.www.example.com", "token-1"); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if err := cf.Present("_acme-challenge.www.example.com", "token-2"); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+	if len(fake.records) != 2 {
+		t.Fatalf("Expected 2 records, got %+v", fake.records)
+	}
+	for _, rec := range fake.records {
+		if rec.Type != "TXT" || rec.Name != "_acme-challenge.www.example.com" || rec.ID[:2] != "z1" {
+			t.Errorf("Unexpected record: %+v", rec)
+		}
+	}
+
+	if err := cf.CleanUp("_acme-challenge.www.example.com", "token-1"); err != nil {
+		t.Fatalf("CleanUp failed: %v", err)
+	}
+	if len(fake.records) != 1 {
+		t.Fatalf("Expected only the matching record to be removed, got %+v", fake.records)
+	}
+	for _, rec := range fake.records {
+		if rec.Content != "token-2" {
+			t.Errorf("Wrong record removed, left %+v", rec)
+		}
+	}
+
+	if err := cf.Present("_acme-challenge.example.org", "x"); err == nil || !strings.Contains(err.Error(), "example.org") {
+		t.Errorf("Expected missing zone error, got %v", err)
+	}
+	err := NewCloudflare("ops@example.com", "wrong").Present("_acme-challenge.example.com", "x")
+	if err == nil || !strings.Contains(err.Error(), "9103") {
+		t.Errorf("Expected API error, got %v", err)
+	}
+}
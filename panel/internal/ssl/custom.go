@@ -0,0 +1,271 @@
+package ssl
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+
+	"site_manager_panel/internal/acme"
+)
+
+// ErrCustom 自定义证书不能通过 ACME 续期
+var ErrCustom = errors.New("自定义证书不支持自动续期，请重新上传")
+
+// Bundle 证书链和私钥，Chain[0] 为站点证书
+type Bundle struct {
+	Chain []*x509.Certificate
+	Key   crypto.Signer
+}
+
+// ParsePEM 解析 PEM 证书链和私钥；私钥可以与证书放在同一个文件中
+func ParsePEM(certPEM, keyPEM []byte) (*Bundle, error) {
+	b := &Bundle{}
+	var keyBlock []byte
+	for _, data := range [][]byte{certPEM, keyPEM} {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			switch {
+			case block.Type == "CERTIFICATE":
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, fmt.Errorf("无法解析证书: %w", err)
+				}
+				b.Chain = append(b.Chain, cert)
+			case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+				if block.Type == "ENCRYPTED PRIVATE KEY" || block.Headers["Proc-Type"] != "" {
+					return nil, fmt.Errorf("不支持加密的私钥，请先解密")
+				}
+				if keyBlock != nil {
+					return nil, fmt.Errorf("包含多个私钥")
+				}
+				keyBlock = pem.EncodeToMemory(block)
+			}
+		}
+	}
+	if len(b.Chain) == 0 {
+		return nil, fmt.Errorf("没有找到证书")
+	}
+	if keyBlock == nil {
+		return nil, fmt.Errorf("没有找到私钥")
+	}
+	key, err := acme.ParseKey(keyBlock)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析私钥: %w", err)
+	}
+	b.Key = key
+	return b, nil
+}
+
+// ParsePFX 解析 PKCS#12（.pfx/.p12）文件；其中证书没有顺序，按签发关系排成叶子在前的链
+func ParsePFX(data []byte, password string) (*Bundle, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, fmt.Errorf("PFX 密码错误")
+		}
+		return nil, fmt.Errorf("无法解析 PFX: %w", err)
+	}
+	var buf bytes.Buffer
+	for _, block := range blocks {
+		// ToPEM 附带的 friendlyName、localKeyId 等属性不是 PEM 的一部分
+		pem.Encode(&buf, &pem.Block{Type: block.Type, Bytes: block.Bytes})
+	}
+	b, err := ParsePEM(buf.Bytes(), nil)
+	if err != nil {
+		return nil, err
+	}
+	b.order()
+	return b, nil
+}
+
+// order 将与私钥匹配的证书放在最前，其后依次为其签发者；无法串联的证书保持原有顺序附在末尾
+func (b *Bundle) order() {
+	rest := append([]*x509.Certificate{}, b.Chain...)
+	take := func(match func(c *x509.Certificate) bool) *x509.Certificate {
+		for i, c := range rest {
+			if match(c) {
+				rest = append(rest[:i], rest[i+1:]...)
+				return c
+			}
+		}
+		return nil
+	}
+	leaf := take(func(c *x509.Certificate) bool { return keyMatches(c, b.Key) })
+	if leaf == nil {
+		return
+	}
+	chain := []*x509.Certificate{leaf}
+	for {
+		last := chain[len(chain)-1]
+		if selfSigned(last) {
+			break
+		}
+		next := take(func(c *x509.Certificate) bool { return last.CheckSignatureFrom(c) == nil })
+		if next == nil {
+			break
+		}
+		chain = append(chain, next)
+	}
+	b.Chain = append(chain, rest...)
+}
+
+func keyMatches(cert *x509.Certificate, key crypto.Signer) bool {
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(key.Public())
+}
+
+// Validate 检查私钥匹配、证书链顺序、有效期，以及证书是否覆盖 domain
+func (b *Bundle) Validate(domain string) error {
+	leaf := b.Chain[0]
+	if !keyMatches(leaf, b.Key) {
+		for _, c := range b.Chain[1:] {
+			if keyMatches(c, b.Key) {
+				return fmt.Errorf("证书链顺序错误: 站点证书 %s 须放在最前", c.Subject.CommonName)
+			}
+		}
+		return fmt.Errorf("私钥与证书不匹配")
+	}
+	for i := 0; i+1 < len(b.Chain); i++ {
+		if err := b.Chain[i].CheckSignatureFrom(b.Chain[i+1]); err != nil {
+			return fmt.Errorf("证书链顺序错误: %s 不是由 %s 签发，请按站点证书、中间证书的顺序排列", b.Chain[i].Subject.CommonName, b.Chain[i+1].Subject.CommonName)
+		}
+	}
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("证书已于 %s 过期", leaf.NotAfter.Format("2006-01-02"))
+	}
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("证书尚未生效，生效时间 %s", leaf.NotBefore.Format("2006-01-02"))
+	}
+	if err := leaf.VerifyHostname(domain); err != nil {
+		return fmt.Errorf("证书不包含域名 %s，证书域名: %s", domain, strings.Join(leaf.DNSNames, ", "))
+	}
+	return nil
+}
+
+// PEM 编码为 fullchain 和私钥
+func (b *Bundle) PEM() (chain, key []byte, err error) {
+	var buf bytes.Buffer
+	for _, c := range b.Chain {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+	key, err = acme.MarshalKey(b.Key)
+	return buf.Bytes(), key, err
+}
+
+// InstallCustom 将上传的证书写入 CertDir/<domain>，并标记为自定义证书；返回恢复原有文件的函数，供配置校验失败时回滚
+func InstallCustom(domain string, b *Bundle) (*Certificate, func(), error) {
+	chain, key, err := b.PEM()
+	if err != nil {
+		return nil, nil, err
+	}
+	restore := snapshot(domain)
+	cert, err := install(domain, chain, key, certMeta{Custom: true})
+	if err != nil {
+		restore()
+		return nil, nil, err
+	}
+	return cert, restore, nil
+}
+
+// snapshot 记录域名证书目录中的文件，返回的函数将目录恢复为记录时的状态
+func snapshot(domain string) func() {
+	dir := filepath.Join(CertDir, domain)
+	saved := map[string][]byte{}
+	for _, name := range []string{"fullchain.pem", "privkey.pem", metaFile} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			saved[name] = data
+		}
+	}
+	return func() {
+		if len(saved) == 0 {
+			os.RemoveAll(dir)
+			return
+		}
+		for _, name := range []string{"fullchain.pem", "privkey.pem", metaFile} {
+			path := filepath.Join(dir, name)
+			mode := os.FileMode(0600)
+			if name == "fullchain.pem" {
+				mode = 0644
+			}
+			if data, ok := saved[name]; ok {
+				os.WriteFile(path, data, mode)
+			} else {
+				os.Remove(path)
+			}
+		}
+	}
+}
+
+// Export 已安装的证书，按用途拆分
+type Export struct {
+	Certificate string `json:"certificate"` // 站点证书
+	Chain       string `json:"chain"`       // 中间证书
+	FullChain   string `json:"fullchain"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// ExportFiles 读取证书和私钥文件
+func ExportFiles(certPath, keyPath string) (*Export, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取证书: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取私钥: %w", err)
+	}
+	b, err := ParsePEM(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	chain, key, err := b.PEM()
+	if err != nil {
+		return nil, err
+	}
+	leaf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b.Chain[0].Raw})
+	return &Export{
+		Certificate: string(leaf),
+		Chain:       string(chain[len(leaf):]),
+		FullChain:   string(chain),
+		PrivateKey:  string(key),
+	}, nil
+}
+
+// Zip 打包为 zip，文件名与 certbot 相同
+func (e *Export) Zip() ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, data string }{
+		{"cert.pem", e.Certificate},
+		{"chain.pem", e.Chain},
+		{"fullchain.pem", e.FullChain},
+		{"privkey.pem", e.PrivateKey},
+	} {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write([]byte(f.data)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
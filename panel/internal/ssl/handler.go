@@ -0,0 +1,152 @@
+package ssl
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// SSLHandler DNS 账号、域名绑定与证书清单
+type SSLHandler struct {
+	manager   *Manager
+	inventory *Inventory
+}
+
+// NewSSLHandler 创建处理器
+func NewSSLHandler(manager *Manager, inventory *Inventory) *SSLHandler {
+	return &SSLHandler{manager: manager, inventory: inventory}
+}
+
+// RegisterRoutes 注册路由；站点证书的申请、续期、吊销在 /sites/:domain/ssl 下
+func (h *SSLHandler) RegisterRoutes(router fiber.Router) {
+	s := router.Group("/ssl")
+	s.Get("/accounts", h.ListAccounts)
+	s.Post("/accounts", h.AddAccount)
+	s.Delete("/accounts/:alias", h.RemoveAccount)
+	s.Get("/bindings", h.ListBindings)
+	s.Post("/bindings", h.Bind)
+	s.Delete("/bindings/:domain", h.Unbind)
+	s.Get("/certificates", h.ListCertificates)
+	s.Get("/notify", h.GetNotify)
+	s.Put("/notify", h.SetNotify)
+}
+
+// ListAccounts 列出 DNS 账号（不含密钥）
+func (h *SSLHandler) ListAccounts(c *fiber.Ctx) error {
+	accounts, err := h.manager.Store().Accounts()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	for i := range accounts {
+		accounts[i] = accounts[i].Redacted()
+	}
+	return c.JSON(fiber.Map{"status": true, "data": accounts})
+}
+
+// AddAccount 添加 DNS 账号
+func (h *SSLHandler) AddAccount(c *fiber.Ctx) error {
+	var req Account
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	if req.Provider == "" {
+		req.Provider = "cloudflare"
+	}
+
+	account, err := h.manager.Store().AddAccount(req)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "账号已添加", "data": account.Redacted()})
+}
+
+// RemoveAccount 删除 DNS 账号
+func (h *SSLHandler) RemoveAccount(c *fiber.Ctx) error {
+	if err := h.manager.Store().RemoveAccount(c.Params("alias")); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "账号已删除"})
+}
+
+// ListBindings 列出域名绑定
+func (h *SSLHandler) ListBindings(c *fiber.Ctx) error {
+	bindings, err := h.manager.Store().Bindings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "data": bindings})
+}
+
+// Bind 绑定根域名到账号
+func (h *SSLHandler) Bind(c *fiber.Ctx) error {
+	var req struct {
+		Domain string `json:"domain"`
+		Alias  string `json:"alias"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+
+	if err := h.manager.Store().Bind(req.Domain, req.Alias); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "已绑定: " + req.Domain + " -> " + req.Alias})
+}
+
+// Unbind 解除域名绑定
+func (h *SSLHandler) Unbind(c *fiber.Ctx) error {
+	if err := h.manager.Store().Unbind(c.Params("domain")); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "已解绑"})
+}
+
+// ListCertificates 证书清单，默认按到期时间升序
+// 参数：sort=expiry|-expiry，status=ok|expiring|expired|error，refresh=true 时立即重新扫描
+func (h *SSLHandler) ListCertificates(c *fiber.Ctx) error {
+	certs, scannedAt := h.inventory.Certificates()
+	if c.QueryBool("refresh") || scannedAt.IsZero() {
+		var err error
+		if certs, err = h.inventory.Scan(); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": false, "message": "扫描证书失败: " + err.Error()})
+		}
+		_, scannedAt = h.inventory.Certificates()
+	}
+
+	switch c.Query("sort", "expiry") {
+	case "expiry":
+		SortCertificates(certs, false)
+	case "-expiry":
+		SortCertificates(certs, true)
+	default:
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "sort 仅支持 expiry 或 -expiry"})
+	}
+	certs = FilterCertificates(certs, c.Query("status"))
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"data": fiber.Map{
+			"certificates": certs,
+			"scanned_at":   scannedAt,
+		},
+	})
+}
+
+// GetNotify 到期提醒设置
+func (h *SSLHandler) GetNotify(c *fiber.Ctx) error {
+	settings, err := h.manager.Store().NotifySettings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "data": fiber.Map{"webhook": settings.Webhook, "days": NotifyDays}})
+}
+
+// SetNotify 保存到期提醒设置
+func (h *SSLHandler) SetNotify(c *fiber.Ctx) error {
+	var req NotifySettings
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": "无效的请求"})
+	}
+	if err := h.manager.Store().SetNotifySettings(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": false, "message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": true, "message": "提醒设置已保存"})
+}
@@ -0,0 +1,324 @@
+package ssl
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"site_manager_panel/internal/acme"
+	"site_manager_panel/internal/layout"
+	"site_manager_panel/internal/nginx"
+)
+
+// 证书来源
+const (
+	SourceSite        = "site"        // 站点配置中的 ssl_certificate
+	SourcePanel       = "ssl"         // CertDir
+	SourceLetsEncrypt = "letsencrypt" // LiveDir
+)
+
+// 证书状态
+const (
+	CertOK       = "ok"
+	CertExpiring = "expiring" // 剩余不足 30 天
+	CertExpired  = "expired"
+	CertError    = "error" // 无法解析、私钥不匹配或证书链不完整
+)
+
+// expiringDays 剩余天数不超过该值时状态为 expiring
+const expiringDays = 30
+
+// roots 校验证书链使用的根证书，nil 为系统根证书；测试中替换
+var roots *x509.CertPool
+
+// CertInfo 证书清单中的一项
+type CertInfo struct {
+	Name       string    `json:"name"` // 目录名或站点域名
+	Sources    []string  `json:"sources"`
+	Sites      []string  `json:"sites"` // 使用该证书的站点
+	CertPath   string    `json:"cert_path"`
+	KeyPath    string    `json:"key_path"`
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	Serial     string    `json:"serial"`
+	Names      []string  `json:"names"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	DaysLeft   int       `json:"days_left"`
+	ChainValid bool      `json:"chain_valid"`
+	KeyMatch   bool      `json:"key_match"`
+	SelfSigned bool      `json:"self_signed"`
+	Status     string    `json:"status"`
+	Errors     []string  `json:"errors"`
+}
+
+// Inspect 读取证书和私钥，检查证书链与私钥是否匹配
+func Inspect(certPath, keyPath string) *CertInfo {
+	info := &CertInfo{CertPath: certPath, KeyPath: keyPath, Sources: []string{}, Sites: []string{}, Errors: []string{}}
+	defer info.setStatus()
+
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		info.Errors = append(info.Errors, "无法读取证书: "+err.Error())
+		return info
+	}
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			info.Errors = append(info.Errors, "无法解析证书: "+err.Error())
+			return info
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		info.Errors = append(info.Errors, "文件中没有证书")
+		return info
+	}
+
+	leaf := chain[0]
+	info.Subject = leaf.Subject.CommonName
+	info.Issuer = leaf.Issuer.CommonName
+	info.Serial = hex.EncodeToString(leaf.SerialNumber.Bytes())
+	info.Names = leaf.DNSNames
+	if len(info.Names) == 0 && leaf.Subject.CommonName != "" {
+		info.Names = []string{leaf.Subject.CommonName}
+	}
+	info.NotBefore, info.NotAfter = leaf.NotBefore, leaf.NotAfter
+	info.DaysLeft = int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+	info.SelfSigned = len(chain) == 1 && selfSigned(leaf)
+
+	if err := checkChain(chain); err != nil {
+		info.Errors = append(info.Errors, err.Error())
+	} else {
+		info.ChainValid = true
+	}
+
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		info.Errors = append(info.Errors, "无法读取私钥: "+err.Error())
+		return info
+	}
+	key, err := acme.ParseKey(keyData)
+	if err != nil {
+		info.Errors = append(info.Errors, "无法解析私钥: "+err.Error())
+		return info
+	}
+	if keyMatches(leaf, key) {
+		info.KeyMatch = true
+	} else {
+		info.Errors = append(info.Errors, "私钥与证书不匹配")
+	}
+	return info
+}
+
+func selfSigned(cert *x509.Certificate) bool {
+	return cert.Subject.String() == cert.Issuer.String() && cert.CheckSignatureFrom(cert) == nil
+}
+
+// checkChain 证书链须按叶子、中间证书的顺序排列，且末尾的证书自签名或由受信任的根证书签发
+func checkChain(chain []*x509.Certificate) error {
+	for i := 0; i+1 < len(chain); i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return fmt.Errorf("证书链顺序错误: 第 %d 个证书 (%s) 不是由下一个证书签发", i+1, chain[i].Subject.CommonName)
+		}
+	}
+	last := chain[len(chain)-1]
+	if selfSigned(last) {
+		return nil
+	}
+	pool := roots
+	if pool == nil {
+		var err error
+		if pool, err = x509.SystemCertPool(); err != nil {
+			pool = x509.NewCertPool()
+		}
+	}
+	if _, err := last.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		return fmt.Errorf("证书链不完整: 缺少 %s 的签发证书", last.Subject.CommonName)
+	}
+	return nil
+}
+
+func (info *CertInfo) setStatus() {
+	switch {
+	case len(info.Errors) > 0:
+		info.Status = CertError
+	case info.DaysLeft < 0:
+		info.Status = CertExpired
+	case info.DaysLeft <= expiringDays:
+		info.Status = CertExpiring
+	default:
+		info.Status = CertOK
+	}
+}
+
+// Inventory 全部证书的清单，由后台定期扫描，到期前按 NotifyDays 发送提醒
+type Inventory struct {
+	store    *Store
+	notifier Notifier
+
+	mu        sync.Mutex
+	certs     []CertInfo
+	scannedAt time.Time
+}
+
+// NewInventory 创建证书清单；notifier 为 nil 时使用 store 中配置的 Webhook
+func NewInventory(store *Store, notifier Notifier) *Inventory {
+	if notifier == nil {
+		notifier = &WebhookNotifier{store: store}
+	}
+	return &Inventory{store: store, notifier: notifier}
+}
+
+// Run 启动时和之后每隔 interval 扫描一次
+func (inv *Inventory) Run(interval time.Duration) {
+	for {
+		if _, err := inv.Scan(); err != nil {
+			log.Printf("[ssl] 扫描证书失败: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Certificates 最近一次扫描的结果
+func (inv *Inventory) Certificates() ([]CertInfo, time.Time) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return append([]CertInfo{}, inv.certs...), inv.scannedAt
+}
+
+// Scan 扫描站点配置引用的证书、CertDir 和 LiveDir，同一证书文件只记录一次，然后发送到期提醒
+func (inv *Inventory) Scan() ([]CertInfo, error) {
+	found := map[string]*CertInfo{}
+	order := []string{}
+	add := func(name, source, site, certPath, keyPath string) {
+		certPath = resolvePath(certPath)
+		info := found[certPath]
+		if info == nil {
+			info = Inspect(certPath, resolvePath(keyPath))
+			info.Name = name
+			found[certPath] = info
+			order = append(order, certPath)
+		}
+		if !contains(info.Sources, source) {
+			info.Sources = append(info.Sources, source)
+		}
+		if site != "" && !contains(info.Sites, site) {
+			info.Sites = append(info.Sites, site)
+		}
+	}
+
+	domains, err := layout.Domains()
+	if err != nil {
+		return nil, err
+	}
+	for _, domain := range domains {
+		data, err := os.ReadFile(layout.Resolve(domain).Config)
+		if err != nil {
+			continue
+		}
+		cfg, err := nginx.Parse(string(data))
+		if err != nil {
+			log.Printf("[ssl] 解析 %s 配置失败: %v", domain, err)
+			continue
+		}
+		for _, server := range cfg.SiteServers() {
+			cert, key := server.FindAll("ssl_certificate"), server.FindAll("ssl_certificate_key")
+			if len(cert) > 0 && len(key) > 0 {
+				add(domain, SourceSite, domain, cert[0].Arg(0), key[0].Arg(0))
+			}
+		}
+	}
+	for _, dir := range []struct{ root, source string }{{CertDir, SourcePanel}, {LiveDir, SourceLetsEncrypt}} {
+		entries, err := os.ReadDir(dir.root)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			cert := filepath.Join(dir.root, e.Name(), "fullchain.pem")
+			if _, err := os.Stat(cert); err != nil {
+				continue
+			}
+			add(e.Name(), dir.source, "", cert, filepath.Join(dir.root, e.Name(), "privkey.pem"))
+		}
+	}
+
+	certs := make([]CertInfo, 0, len(order))
+	for _, path := range order {
+		certs = append(certs, *found[path])
+	}
+	SortCertificates(certs, false)
+
+	inv.mu.Lock()
+	inv.certs, inv.scannedAt = certs, time.Now()
+	inv.mu.Unlock()
+
+	inv.notify(certs)
+	return certs, nil
+}
+
+// resolvePath 解析软链接，使 LiveDir 与站点配置中引用的同一证书合并
+func resolvePath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return filepath.Clean(path)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SortCertificates 按到期时间排序，desc 为 true 时最晚到期的在前；无法解析的证书总在最前
+func SortCertificates(certs []CertInfo, desc bool) {
+	sort.SliceStable(certs, func(i, j int) bool {
+		a, b := certs[i], certs[j]
+		if a.NotAfter.IsZero() != b.NotAfter.IsZero() {
+			return a.NotAfter.IsZero()
+		}
+		if a.NotAfter.Equal(b.NotAfter) {
+			return a.CertPath < b.CertPath
+		}
+		if desc {
+			return a.NotAfter.After(b.NotAfter)
+		}
+		return a.NotAfter.Before(b.NotAfter)
+	})
+}
+
+// FilterCertificates 按状态过滤，status 为空时返回全部
+func FilterCertificates(certs []CertInfo, status string) []CertInfo {
+	if status == "" {
+		return certs
+	}
+	result := []CertInfo{}
+	for _, c := range certs {
+		if strings.EqualFold(c.Status, status) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
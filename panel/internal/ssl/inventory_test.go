@@ -0,0 +1,299 @@
+package ssl
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/acme"
+	"site_manager_panel/internal/layout"
+)
+
+// testCA 根证书和中间证书
+type testCA struct {
+	root, inter       *x509.Certificate
+	rootKey, interKey *ecdsa.PrivateKey
+	serial            int64
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	ca := &testCA{serial: 10}
+	ca.rootKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca.interKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	root := &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "Test Root"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(10 * 365 * 24 * time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, root, root, &ca.rootKey.PublicKey, ca.rootKey)
+	ca.root, _ = x509.ParseCertificate(der)
+	inter := &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "Test Intermediate"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(5 * 365 * 24 * time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}
+	der, _ = x509.CreateCertificate(rand.Reader, inter, ca.root, &ca.interKey.PublicKey, ca.rootKey)
+	ca.inter, _ = x509.ParseCertificate(der)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.root)
+	old := roots
+	roots = pool
+	t.Cleanup(func() { roots = old })
+	return ca
+}
+
+// issue 由中间证书签发叶子证书，返回叶子证书、中间证书和私钥的 PEM
+func (ca *testCA) issue(t *testing.T, days int, names ...string) (leaf, inter, key []byte) {
+	t.Helper()
+	ca.serial++
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial), Subject: pkix.Name{CommonName: names[0]}, DNSNames: names,
+		NotBefore: time.Now().Add(-24 * time.Hour), NotAfter: time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.inter, &priv.PublicKey, ca.interKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ = acme.MarshalKey(priv)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.inter.Raw}), key
+}
+
+func writeTestFile(t *testing.T, path string, data ...[]byte) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, bytes.Join(data, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type recordNotifier struct {
+	notices []Notice
+}
+
+func (r *recordNotifier) Notify(n Notice) error {
+	r.notices = append(r.notices, n)
+	return nil
+}
+
+// setupInventory 将站点配置和证书目录指向临时目录
+func setupInventory(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	oldCert, oldLive := CertDir, LiveDir
+	oldConfig, oldEnabled := layout.ConfigDir, layout.EnabledDir
+	t.Cleanup(func() {
+		CertDir, LiveDir = oldCert, oldLive
+		layout.ConfigDir, layout.EnabledDir = oldConfig, oldEnabled
+	})
+	CertDir = filepath.Join(root, "ssl")
+	LiveDir = filepath.Join(root, "live")
+	layout.ConfigDir = filepath.Join(root, "sites-available")
+	layout.EnabledDir = filepath.Join(root, "sites-enabled")
+	os.MkdirAll(layout.ConfigDir, 0755)
+	return root
+}
+
+func findCert(certs []CertInfo, name string) *CertInfo {
+	for i := range certs {
+		if certs[i].Name == name {
+			return &certs[i]
+		}
+	}
+	return nil
+}
+
+func TestInventoryScan(t *testing.T) {
+	root := setupInventory(t)
+	ca := newTestCA(t)
+
+	// 站点引用 CertDir 中的证书
+	leaf, inter, key := ca.issue(t, 60, "good.com", "www.good.com")
+	writeTestFile(t, CertPath("good.com"), leaf, inter)
+	writeTestFile(t, KeyPath("good.com"), key)
+	writeTestFile(t, filepath.Join(layout.ConfigDir, "good.com.conf"), []byte("server {\n    listen 443 ssl;\n    server_name good.com;\n    ssl_certificate "+CertPath("good.com")+";\n    ssl_certificate_key "+KeyPath("good.com")+";\n}\n"))
+
+	// 私钥不匹配
+	leaf, inter, _ = ca.issue(t, 60, "badkey.com")
+	_, _, other := ca.issue(t, 60, "other.com")
+	writeTestFile(t, CertPath("badkey.com"), leaf, inter)
+	writeTestFile(t, KeyPath("badkey.com"), other)
+
+	// 中间证书顺序颠倒
+	leaf, inter, key = ca.issue(t, 60, "order.com")
+	writeTestFile(t, CertPath("order.com"), inter, leaf)
+	writeTestFile(t, KeyPath("order.com"), key)
+
+	// certbot 的 live 目录是指向 archive 的软链接，缺少中间证书；站点通过 live 路径引用
+	leaf, _, key = ca.issue(t, 10, "soon.com")
+	archive := filepath.Join(root, "archive", "soon.com")
+	writeTestFile(t, filepath.Join(archive, "fullchain1.pem"), leaf)
+	writeTestFile(t, filepath.Join(archive, "privkey1.pem"), key)
+	os.MkdirAll(filepath.Join(LiveDir, "soon.com"), 0755)
+	os.Symlink(filepath.Join(archive, "fullchain1.pem"), filepath.Join(LiveDir, "soon.com", "fullchain.pem"))
+	os.Symlink(filepath.Join(archive, "privkey1.pem"), filepath.Join(LiveDir, "soon.com", "privkey.pem"))
+	writeTestFile(t, filepath.Join(layout.ConfigDir, "soon.com.conf"), []byte("server {\n    listen 443 ssl;\n    ssl_certificate "+filepath.Join(LiveDir, "soon.com", "fullchain.pem")+";\n    ssl_certificate_key "+filepath.Join(LiveDir, "soon.com", "privkey.pem")+";\n}\n"))
+
+	// 已过期
+	leaf, inter, key = ca.issue(t, -3, "old.com")
+	writeTestFile(t, CertPath("old.com"), leaf, inter)
+	writeTestFile(t, KeyPath("old.com"), key)
+
+	notifier := &recordNotifier{}
+	inv := NewInventory(NewStore(t.TempDir()), notifier)
+	certs, err := inv.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(certs) != 5 {
+		t.Fatalf("Expected 5 certificates, got %d", len(certs))
+	}
+	names := []string{}
+	for _, c := range certs {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names[:2], ",") != "old.com,soon.com" {
+		t.Errorf("Expected certificates sorted by expiry, got %v", names)
+	}
+
+	good := findCert(certs, "good.com")
+	if good.Status != CertOK || !good.ChainValid || !good.KeyMatch || good.Issuer != "Test Intermediate" ||
+		strings.Join(good.Names, ",") != "good.com,www.good.com" || good.DaysLeft != 60 {
+		t.Errorf("Unexpected good.com: %+v", good)
+	}
+	if strings.Join(good.Sources, ",") != "site,ssl" || strings.Join(good.Sites, ",") != "good.com" {
+		t.Errorf("Expected site and CertDir entries merged, got %v %v", good.Sources, good.Sites)
+	}
+	if c := findCert(certs, "badkey.com"); c.Status != CertError || c.KeyMatch || !c.ChainValid || c.Errors[0] != "私钥与证书不匹配" {
+		t.Errorf("Unexpected badkey.com: %+v", c)
+	}
+	if c := findCert(certs, "order.com"); c.Status != CertError || c.ChainValid || !strings.Contains(c.Errors[0], "顺序错误") {
+		t.Errorf("Unexpected order.com: %+v", c)
+	}
+	soon := findCert(certs, "soon.com")
+	if soon.Status != CertError || soon.ChainValid || !strings.Contains(soon.Errors[0], "证书链不完整") || !soon.KeyMatch {
+		t.Errorf("Unexpected soon.com: %+v", soon)
+	}
+	if strings.Join(soon.Sources, ",") != "site,letsencrypt" || soon.DaysLeft != 10 {
+		t.Errorf("Expected live symlink merged with site reference, got %+v", soon)
+	}
+	if c := findCert(certs, "old.com"); c.Status != CertExpired || c.DaysLeft != -3 {
+		t.Errorf("Unexpected old.com: %+v", c)
+	}
+
+	// 过期和 10 天后到期的证书各提醒一次，再次扫描不重复
+	if len(notifier.notices) != 2 || notifier.notices[0].Threshold != 1 || notifier.notices[1].Threshold != 14 {
+		t.Fatalf("Unexpected notices: %+v", notifier.notices)
+	}
+	if !strings.Contains(notifier.notices[0].Message, "已于") || !strings.Contains(notifier.notices[1].Message, "剩余 10 天") {
+		t.Errorf("Unexpected messages: %+v", notifier.notices)
+	}
+	inv.Scan()
+	if len(notifier.notices) != 2 {
+		t.Errorf("Expected no repeated notice, got %+v", notifier.notices[2:])
+	}
+
+	// 续期后旧证书的记录被清除
+	leaf, inter, key = ca.issue(t, 90, "old.com")
+	writeTestFile(t, CertPath("old.com"), leaf, inter)
+	writeTestFile(t, KeyPath("old.com"), key)
+	inv.Scan()
+	var sent map[string]int
+	inv.store.readJSON(notifiedFile, &sent)
+	if len(sent) != 1 || sent[soon.Serial] != 14 {
+		t.Errorf("Unexpected notify records: %v", sent)
+	}
+}
+
+func TestDueThreshold(t *testing.T) {
+	for days, want := range map[int]int{90: 0, 31: 0, 30: 30, 20: 30, 14: 14, 8: 14, 7: 7, 2: 7, 1: 1, 0: 1, -5: 1} {
+		if got := dueThreshold(days); got != want {
+			t.Errorf("dueThreshold(%d) = %d, want %d", days, got, want)
+		}
+	}
+}
+
+func TestCertificatesAPI(t *testing.T) {
+	setupInventory(t)
+	ca := newTestCA(t)
+	for _, d := range []struct {
+		name string
+		days int
+	}{{"a.com", 80}, {"b.com", 20}, {"c.com", 50}} {
+		leaf, inter, key := ca.issue(t, d.days, d.name)
+		writeTestFile(t, CertPath(d.name), leaf, inter)
+		writeTestFile(t, KeyPath(d.name), key)
+	}
+
+	var hook []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hook, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	store := NewStore(t.TempDir())
+	h := NewSSLHandler(NewManager(store), NewInventory(store, nil))
+	app := fiber.New()
+	h.RegisterRoutes(app)
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	if status, _ := do("PUT", "/ssl/notify", `{"webhook":"ftp://example.com"}`); status != 400 {
+		t.Errorf("Expected invalid webhook to be rejected, got %d", status)
+	}
+	if status, _ := do("PUT", "/ssl/notify", `{"webhook":"`+srv.URL+`"}`); status != 200 {
+		t.Fatalf("SetNotify failed: %d", status)
+	}
+
+	status, result := do("GET", "/ssl/certificates?sort=-expiry", "")
+	if status != 200 {
+		t.Fatalf("ListCertificates failed: %d %v", status, result)
+	}
+	certs := result["data"].(map[string]interface{})["certificates"].([]interface{})
+	order := []string{}
+	for _, c := range certs {
+		order = append(order, c.(map[string]interface{})["name"].(string))
+	}
+	if strings.Join(order, ",") != "a.com,c.com,b.com" {
+		t.Errorf("Expected descending expiry, got %v", order)
+	}
+	if !strings.Contains(string(hook), "b.com") || !strings.Contains(string(hook), `"threshold":30`) {
+		t.Errorf("Expected webhook notice for b.com, got %s", hook)
+	}
+
+	_, result = do("GET", "/ssl/certificates?status=expiring", "")
+	if certs := result["data"].(map[string]interface{})["certificates"].([]interface{}); len(certs) != 1 {
+		t.Errorf("Expected 1 expiring certificate, got %v", certs)
+	}
+	if status, _ := do("GET", "/ssl/certificates?sort=name", ""); status != 400 {
+		t.Errorf("Expected invalid sort to be rejected, got %d", status)
+	}
+}
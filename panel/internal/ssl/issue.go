@@ -0,0 +1,521 @@
+package ssl
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"site_manager_panel/internal/acme"
+)
+
+// 证书目录，与 lib/ssl.sh 保持一致
+var (
+	CertDir      = "/www/ssl"              // 站点使用的证书，SSL_DIR
+	LiveDir      = "/etc/letsencrypt/live" // 旧版 certbot 签发的证书，续期和吊销时作为来源
+	ChallengeDir = "/www/ssl/_acme"        // HTTP-01 验证文件的 webroot
+	directoryURL = acme.LetsEncrypt
+	pollInterval = 2 * time.Second
+	propagation  = 30 * time.Second    // TXT 记录添加后等待生效的时间，与 CLI 的 propagation-seconds 相同
+	renewBefore  = 30 * 24 * time.Hour // 剩余有效期少于该时间才续期，与 ssl_renew 相同
+)
+
+// 验证方式
+const (
+	ChallengeHTTP = "http"
+	ChallengeDNS  = "dns"
+)
+
+// metaFile 证书目录中记录申请参数的文件，续期时使用
+const metaFile = "acme.json"
+
+// certMeta 证书的申请参数；Custom 为上传的证书，不能通过 ACME 续期
+type certMeta struct {
+	IssueRequest
+	Custom bool `json:"custom,omitempty"`
+}
+
+var domainRegex = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// SetDirectoryURL 设置 ACME 服务目录地址，默认为 Let's Encrypt 正式环境
+func SetDirectoryURL(url string) {
+	directoryURL = url
+}
+
+// NotDueError 证书未到续期时间
+type NotDueError struct {
+	Domain string
+	Days   int
+}
+
+func (e *NotDueError) Error() string {
+	return fmt.Sprintf("证书 %s 剩余 %d 天，无需续期", e.Domain, e.Days)
+}
+
+// IssueRequest 申请证书参数
+type IssueRequest struct {
+	Domain    string   `json:"domain"`    // 证书名称，通常为站点主域名
+	Domains   []string `json:"domains"`   // 额外的域名
+	Wildcard  bool     `json:"wildcard"`  // 同时申请 *.domain，只能使用 DNS 验证
+	Challenge string   `json:"challenge"` // http 或 dns，默认 http；通配符时为 dns
+	Email     string   `json:"email"`     // 注册邮箱，为空时使用 DNS 账号的邮箱
+}
+
+// Names 证书包含的全部域名，主域名在前并去重
+func (r IssueRequest) Names() []string {
+	names := []string{r.Domain}
+	if r.Wildcard {
+		names = append(names, "*."+r.Domain)
+	}
+	seen := map[string]bool{}
+	result := []string{}
+	for _, n := range append(names, r.Domains...) {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		result = append(result, n)
+	}
+	return result
+}
+
+// ChallengeType 验证方式，未指定时通配符证书使用 DNS 验证，其余使用 HTTP 验证
+func (r IssueRequest) ChallengeType() string {
+	switch {
+	case r.Challenge != "":
+		return r.Challenge
+	case r.Wildcard:
+		return ChallengeDNS
+	}
+	return ChallengeHTTP
+}
+
+// Certificate 站点证书
+type Certificate struct {
+	Domain    string    `json:"domain"`
+	Names     []string  `json:"names"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	CertPath  string    `json:"cert_path"`
+	KeyPath   string    `json:"key_path"`
+}
+
+// Manager 证书申请、续期与吊销
+type Manager struct {
+	store *Store
+
+	mu       sync.Mutex
+	client   *acme.Client
+	progress map[string]*Progress
+}
+
+// NewManager 创建证书管理器
+func NewManager(store *Store) *Manager {
+	return &Manager{store: store, progress: map[string]*Progress{}}
+}
+
+// Store 账号与绑定存储
+func (m *Manager) Store() *Store {
+	return m.store
+}
+
+// CertPath 站点证书（含中间证书）路径
+func CertPath(domain string) string {
+	return filepath.Join(CertDir, domain, "fullchain.pem")
+}
+
+// KeyPath 站点私钥路径
+func KeyPath(domain string) string {
+	return filepath.Join(CertDir, domain, "privkey.pem")
+}
+
+// acmeClient 使用账号私钥的 ACME 客户端，目录地址变化时重新创建
+func (m *Manager) acmeClient() (*acme.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != nil && m.client.DirectoryURL == directoryURL {
+		return m.client, nil
+	}
+	key, err := m.store.AccountKey()
+	if err != nil {
+		return nil, fmt.Errorf("加载 ACME 账号私钥失败: %w", err)
+	}
+	m.client = acme.NewClient(directoryURL, key)
+	m.client.PollInterval = pollInterval
+	return m.client, nil
+}
+
+// Issue 申请证书并写入 CertDir；HTTP 验证要求站点已配置 ChallengeDir 作为验证路径
+func (m *Manager) Issue(req IssueRequest) (*Certificate, error) {
+	names := req.Names()
+	for _, n := range names {
+		if !domainRegex.MatchString(n) {
+			return nil, fmt.Errorf("无效的域名: %s", n)
+		}
+	}
+	req.Challenge = req.ChallengeType()
+
+	switch req.Challenge {
+	case ChallengeHTTP:
+		for _, n := range names {
+			if strings.HasPrefix(n, "*.") {
+				return nil, fmt.Errorf("通配符证书只能使用 DNS 验证")
+			}
+		}
+	case ChallengeDNS:
+		// 每个域名都要能找到账号，否则会在验证中途失败
+		for _, n := range names {
+			account, err := m.store.AccountFor(n)
+			if err != nil {
+				return nil, err
+			}
+			if req.Email == "" {
+				req.Email = account.Email
+			}
+		}
+	default:
+		return nil, fmt.Errorf("不支持的验证方式: %s", req.Challenge)
+	}
+
+	progress, err := m.begin(req.Domain, names, req.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[ssl] 申请证书: %s (%s)", strings.Join(names, ","), req.Challenge)
+	cert, err := m.obtain(progress, req)
+	m.finish(progress, err)
+	if err != nil {
+		log.Printf("[ssl] 证书申请失败: %s: %v", strings.Join(names, ","), err)
+		return nil, err
+	}
+	return cert, nil
+}
+
+// obtain 完成一次 ACME 签发：注册账号、创建订单、逐个完成验证、提交 CSR 并保存证书
+func (m *Manager) obtain(p *Progress, req IssueRequest) (*Certificate, error) {
+	client, err := m.acmeClient()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.Register(req.Email); err != nil {
+		return nil, err
+	}
+
+	m.update(p, func(p *Progress) { p.Stage = StageOrder })
+	order, err := client.NewOrder(p.Names)
+	if err != nil {
+		return nil, err
+	}
+	m.update(p, func(p *Progress) { p.Stage, p.OrderStatus = StageValidate, order.Status })
+
+	if err := m.authorize(p, client, order, req.Challenge); err != nil {
+		return nil, err
+	}
+
+	m.update(p, func(p *Progress) { p.Stage = StageFinalize })
+	key, err := acme.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	csr, err := acme.NewCSR(key, p.Names)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.Finalize(order, csr); err != nil {
+		return nil, err
+	}
+	order, err = client.WaitOrder(order.URL)
+	if order != nil {
+		m.update(p, func(p *Progress) { p.OrderStatus = order.Status })
+	}
+	if err != nil {
+		return nil, err
+	}
+	chain, err := client.Certificate(order.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := acme.MarshalKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := certMeta{IssueRequest: IssueRequest{Domain: req.Domain, Domains: p.Names, Challenge: req.Challenge, Email: req.Email}}
+	return install(req.Domain, chain, keyPEM, meta)
+}
+
+// authorize 为订单中待验证的域名放置验证文件或 TXT 记录，通知 CA 验证并等待结果，完成后清理
+func (m *Manager) authorize(p *Progress, client *acme.Client, order *acme.Order, challenge string) error {
+	typ := acme.ChallengeHTTP01
+	if challenge == ChallengeDNS {
+		typ = acme.ChallengeDNS01
+	}
+
+	type pending struct {
+		url       string
+		domain    string
+		challenge *acme.Challenge
+	}
+	var todo []pending
+	var cleanups []func()
+	defer func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}()
+
+	for _, url := range order.Authorizations {
+		authz, err := client.Authorization(url)
+		if err != nil {
+			return err
+		}
+		domain := authz.Domain()
+		// 近期验证过的域名 CA 会直接返回 valid
+		if authz.Status == acme.StatusValid {
+			m.setAuthz(p, AuthzProgress{Domain: domain, Status: authz.Status, Challenge: typ})
+			continue
+		}
+		ch := authz.Challenge(typ)
+		if ch == nil {
+			return fmt.Errorf("%s 不支持 %s 验证", domain, typ)
+		}
+		keyAuth, err := acme.KeyAuthorization(client.Key, ch.Token)
+		if err != nil {
+			return err
+		}
+		cleanup, err := m.present(authz.Identifier.Value, typ, ch.Token, keyAuth)
+		if err != nil {
+			return err
+		}
+		cleanups = append(cleanups, cleanup)
+		m.setAuthz(p, AuthzProgress{Domain: domain, Status: authz.Status, Challenge: typ})
+		todo = append(todo, pending{url, domain, ch})
+	}
+
+	if typ == acme.ChallengeDNS01 && len(todo) > 0 {
+		time.Sleep(propagation)
+	}
+
+	for _, t := range todo {
+		if err := client.Accept(t.challenge); err != nil {
+			return err
+		}
+		m.setAuthz(p, AuthzProgress{Domain: t.domain, Status: acme.StatusProcessing, Challenge: typ})
+		authz, err := client.WaitAuthorization(t.url)
+		state := AuthzProgress{Domain: t.domain, Challenge: typ}
+		if authz != nil {
+			state.Status = authz.Status
+		}
+		var problem *acme.Problem
+		if errors.As(err, &problem) {
+			state.Error = problem
+		}
+		m.setAuthz(p, state)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// present 放置挑战响应，返回对应的清理函数
+func (m *Manager) present(domain, typ, token, keyAuth string) (func(), error) {
+	if typ == acme.ChallengeHTTP01 {
+		path := filepath.Join(ChallengeDir, filepath.FromSlash(acme.HTTP01Path(token)))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(keyAuth), 0644); err != nil {
+			return nil, err
+		}
+		return func() { os.Remove(path) }, nil
+	}
+
+	account, err := m.store.AccountFor(domain)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := NewProvider(*account)
+	if err != nil {
+		return nil, err
+	}
+	record, value := ChallengeRecord(domain), acme.DNS01Value(keyAuth)
+	if err := provider.Present(record, value); err != nil {
+		return nil, fmt.Errorf("添加 %s 的 TXT 记录失败: %w", record, err)
+	}
+	return func() {
+		if err := provider.CleanUp(record, value); err != nil {
+			log.Printf("[ssl] 清理 %s 的 TXT 记录失败: %v", record, err)
+		}
+	}, nil
+}
+
+// RenewRequest 续期单个证书所需的申请参数，沿用上次申请的域名和验证方式；force 为 false 时剩余有效期超过 30 天返回 NotDueError
+// 没有申请记录的证书（如 certbot 签发）按证书中的域名重新申请，含通配符时使用 DNS 验证
+func (m *Manager) RenewRequest(domain string, force bool) (*IssueRequest, error) {
+	cert, source, err := loadExisting(domain)
+	if err != nil {
+		return nil, fmt.Errorf("证书不存在: %s", domain)
+	}
+	if left := time.Until(cert.NotAfter); !force && left > renewBefore {
+		return nil, &NotDueError{Domain: domain, Days: int(left.Hours() / 24)}
+	}
+
+	req := IssueRequest{Domain: domain}
+	var meta certMeta
+	if data, err := os.ReadFile(filepath.Join(CertDir, domain, metaFile)); err == nil && json.Unmarshal(data, &meta) == nil {
+		if meta.Custom {
+			return nil, ErrCustom
+		}
+		req = meta.IssueRequest
+		req.Domain = domain
+	} else {
+		req.Domains = cert.Names
+		for _, n := range cert.Names {
+			if strings.HasPrefix(n, "*.") {
+				req.Challenge = ChallengeDNS
+			}
+		}
+	}
+	log.Printf("[ssl] 续期证书: %s (来源 %s)", domain, source)
+	return &req, nil
+}
+
+// Renew 续期单个证书，见 RenewRequest
+func (m *Manager) Renew(domain string, force bool) (*Certificate, error) {
+	req, err := m.RenewRequest(domain, force)
+	if err != nil {
+		return nil, err
+	}
+	return m.Issue(*req)
+}
+
+// Revoke 用证书私钥向 CA 吊销证书，适用于面板和 certbot 签发的证书；CertDir 中的文件由 RemoveFiles 在站点配置更新后删除
+func (m *Manager) Revoke(domain string) error {
+	cert, source, err := loadExisting(domain)
+	if err != nil {
+		return fmt.Errorf("证书不存在: %s", domain)
+	}
+	der, key, err := readPair(cert.CertPath, cert.KeyPath)
+	if err != nil {
+		return err
+	}
+	client, err := m.acmeClient()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[ssl] 吊销证书: %s (来源 %s)", domain, source)
+	err = client.Revoke(der, key, 0)
+	var problem *acme.Problem
+	if errors.As(err, &problem) && problem.Kind() == "alreadyRevoked" {
+		return nil
+	}
+	return err
+}
+
+// RemoveFiles 删除站点证书
+func (m *Manager) RemoveFiles(domain string) error {
+	return os.RemoveAll(filepath.Join(CertDir, domain))
+}
+
+// install 写入证书链、私钥和申请记录，与 ssl_request 使用相同的文件名和权限
+func install(domain string, chain, key []byte, meta certMeta) (*Certificate, error) {
+	dir := filepath.Join(CertDir, domain)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := []struct {
+		path string
+		data []byte
+		mode os.FileMode
+	}{
+		{CertPath(domain), chain, 0644},
+		{KeyPath(domain), key, 0600},
+		{filepath.Join(dir, metaFile), append(metaData, '\n'), 0600},
+	}
+	for _, f := range files {
+		tmp := f.path + ".tmp"
+		if err := os.WriteFile(tmp, f.data, f.mode); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmp, f.path); err != nil {
+			return nil, err
+		}
+	}
+	return Load(domain)
+}
+
+// loadExisting 读取站点证书，CertDir 中没有时读取 certbot 的证书，返回来源目录
+func loadExisting(domain string) (*Certificate, string, error) {
+	if cert, err := Load(domain); err == nil {
+		return cert, CertDir, nil
+	}
+	cert, err := loadFile(domain, filepath.Join(LiveDir, domain, "fullchain.pem"), filepath.Join(LiveDir, domain, "privkey.pem"))
+	return cert, LiveDir, err
+}
+
+// readPair 读取叶子证书（DER）和私钥
+func readPair(certPath, keyPath string) ([]byte, crypto.Signer, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("无法解析证书: %s", certPath)
+	}
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := acme.ParseKey(keyData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block.Bytes, key, nil
+}
+
+// Load 读取站点证书
+func Load(domain string) (*Certificate, error) {
+	return loadFile(domain, CertPath(domain), KeyPath(domain))
+}
+
+func loadFile(domain, certPath, keyPath string) (*Certificate, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("无法解析证书: %s", certPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{
+		Domain:    domain,
+		Names:     cert.DNSNames,
+		Issuer:    cert.Issuer.CommonName,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		CertPath:  certPath,
+		KeyPath:   keyPath,
+	}, nil
+}
@@ -0,0 +1,267 @@
+package ssl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"site_manager_panel/internal/acme"
+	"site_manager_panel/internal/acme/acmetest"
+)
+
+// fakeDNS 记录 Present / CleanUp 调用，并作为 acmetest 查询的 TXT 记录
+type fakeDNS struct {
+	mu      sync.Mutex
+	calls   []string
+	records map[string][]string
+}
+
+type fakeProvider struct {
+	account Account
+	dns     *fakeDNS
+}
+
+func (p fakeProvider) Present(fqdn, value string) error {
+	p.dns.mu.Lock()
+	defer p.dns.mu.Unlock()
+	p.dns.calls = append(p.dns.calls, "present "+p.account.Alias+" "+fqdn)
+	p.dns.records[fqdn] = append(p.dns.records[fqdn], value)
+	return nil
+}
+
+func (p fakeProvider) CleanUp(fqdn, value string) error {
+	p.dns.mu.Lock()
+	defer p.dns.mu.Unlock()
+	p.dns.calls = append(p.dns.calls, "cleanup "+p.account.Alias+" "+fqdn)
+	delete(p.dns.records, fqdn)
+	return nil
+}
+
+func (d *fakeDNS) lookup(fqdn string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.records[fqdn], nil
+}
+
+func registerFake(t *testing.T, srv *acmetest.Server) *fakeDNS {
+	t.Helper()
+	dns := &fakeDNS{records: map[string][]string{}}
+	RegisterProvider("fake", func(a Account) DNSProvider { return fakeProvider{account: a, dns: dns} })
+	t.Cleanup(func() { delete(providers, "fake") })
+	srv.LookupTXT = dns.lookup
+	return dns
+}
+
+// setupACME 将证书目录指向临时目录，并启动本地 ACME 服务端；HTTP-01 验证读取 ChallengeDir 中的文件
+func setupACME(t *testing.T) *acmetest.Server {
+	t.Helper()
+	root := t.TempDir()
+	oldCert, oldLive, oldChallenge := CertDir, LiveDir, ChallengeDir
+	oldDirectory, oldPoll, oldPropagation := directoryURL, pollInterval, propagation
+	t.Cleanup(func() {
+		CertDir, LiveDir, ChallengeDir = oldCert, oldLive, oldChallenge
+		directoryURL, pollInterval, propagation = oldDirectory, oldPoll, oldPropagation
+	})
+	CertDir = filepath.Join(root, "ssl")
+	LiveDir = filepath.Join(root, "live")
+	ChallengeDir = filepath.Join(root, "acme")
+	pollInterval = 10 * time.Millisecond
+	propagation = 0
+
+	srv := acmetest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.HTTPGet = func(domain, path string) (string, error) {
+		data, err := os.ReadFile(filepath.Join(ChallengeDir, path))
+		return string(data), err
+	}
+	directoryURL = srv.DirectoryURL()
+	return srv
+}
+
+func TestIssueHTTP(t *testing.T) {
+	srv := setupACME(t)
+	dir := t.TempDir()
+	m := NewManager(NewStore(dir))
+
+	cert, err := m.Issue(IssueRequest{Domain: "example.com", Domains: []string{"www.example.com", "Example.com"}})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if cert.CertPath != filepath.Join(CertDir, "example.com", "fullchain.pem") || strings.Join(cert.Names, ",") != "example.com,www.example.com" {
+		t.Errorf("Unexpected certificate: %+v", cert)
+	}
+	if cert.Issuer != srv.Issuer().Subject.CommonName {
+		t.Errorf("Unexpected issuer: %s", cert.Issuer)
+	}
+	if info, err := os.Stat(cert.KeyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected private key with mode 0600, got %v", info)
+	}
+	if info, err := os.Stat(filepath.Join(dir, accountKeyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected account key with mode 0600, got %v", info)
+	}
+	if files, _ := os.ReadDir(filepath.Join(ChallengeDir, ".well-known", "acme-challenge")); len(files) != 0 {
+		t.Errorf("Expected challenge files to be removed, got %d", len(files))
+	}
+
+	p := m.Progress("example.com")
+	if p == nil || p.Stage != StageDone || p.OrderStatus != acme.StatusValid || len(p.Authorizations) != 2 {
+		t.Fatalf("Unexpected progress: %+v", p)
+	}
+	for _, a := range p.Authorizations {
+		if a.Status != acme.StatusValid || a.Challenge != acme.ChallengeHTTP01 {
+			t.Errorf("Unexpected authorization progress: %+v", a)
+		}
+	}
+
+	// 账号私钥复用：新的管理器使用同一私钥
+	key, _ := os.ReadFile(filepath.Join(dir, accountKeyFile))
+	if _, err := NewManager(NewStore(dir)).Issue(IssueRequest{Domain: "example.com"}); err != nil {
+		t.Fatalf("Reissue failed: %v", err)
+	}
+	if again, _ := os.ReadFile(filepath.Join(dir, accountKeyFile)); string(again) != string(key) {
+		t.Error("Expected account key to be reused")
+	}
+
+	if _, err := m.Issue(IssueRequest{Domain: "example.com", Domains: []string{"*.example.com"}, Challenge: ChallengeHTTP}); err == nil {
+		t.Error("Expected wildcard over HTTP to be rejected")
+	}
+	if _, err := m.Issue(IssueRequest{Domain: "bad domain"}); err == nil {
+		t.Error("Expected invalid domain to be rejected")
+	}
+}
+
+func TestIssueDNS(t *testing.T) {
+	srv := setupACME(t)
+	dns := registerFake(t, srv)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "dns_accounts.json"), []byte(`{"fake":[
+		{"id":"a1","alias":"main","email":"main@example.com","api_key":"k1"},
+		{"id":"a2","alias":"other","email":"other@example.net","api_key":"k2"}]}`), 0600)
+	s := NewStore(dir)
+	m := NewManager(s)
+
+	if _, err := m.Issue(IssueRequest{Domain: "example.com", Wildcard: true}); err == nil {
+		t.Fatal("Expected unbound domain to fail before creating an order")
+	}
+	if srv.Orders() != 0 {
+		t.Fatalf("Expected no order, got %d", srv.Orders())
+	}
+
+	// 多个账号的域名合并在同一张证书中，通配符与主域名共用一条记录名
+	s.Bind("example.com", "main")
+	s.Bind("example.net", "other")
+	cert, err := m.Issue(IssueRequest{Domain: "example.com", Wildcard: true, Domains: []string{"example.net"}})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if strings.Join(cert.Names, ",") != "example.com,*.example.com,example.net" {
+		t.Errorf("Unexpected names: %v", cert.Names)
+	}
+	want := []string{
+		"present main _acme-challenge.example.com",
+		"present main _acme-challenge.example.com",
+		"present other _acme-challenge.example.net",
+		"cleanup main _acme-challenge.example.com",
+		"cleanup main _acme-challenge.example.com",
+		"cleanup other _acme-challenge.example.net",
+	}
+	if strings.Join(dns.calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected DNS calls:\n%s", strings.Join(dns.calls, "\n"))
+	}
+	if p := m.Progress("example.com"); p == nil || p.Challenge != ChallengeDNS || len(p.Authorizations) != 3 {
+		t.Errorf("Unexpected progress: %+v", p)
+	}
+}
+
+func TestIssueFailure(t *testing.T) {
+	srv := setupACME(t)
+	m := NewManager(NewStore(t.TempDir()))
+	srv.HTTPGet = func(domain, path string) (string, error) {
+		return "<html>404</html>", nil
+	}
+
+	_, err := m.Issue(IssueRequest{Domain: "example.com"})
+	var problem *acme.Problem
+	if !errors.As(err, &problem) || problem.Kind() != "unauthorized" {
+		t.Fatalf("Expected unauthorized problem, got %v", err)
+	}
+
+	p := m.Progress("example.com")
+	if p.Stage != StageFailed || p.Error == nil || len(p.Authorizations) != 1 {
+		t.Fatalf("Unexpected progress: %+v", p)
+	}
+	a := p.Authorizations[0]
+	if a.Status != acme.StatusInvalid || a.Error == nil || a.Error.Identifier.Value != "example.com" || !strings.Contains(a.Error.Detail, "404") {
+		t.Errorf("Expected failure reason per domain, got %+v", a)
+	}
+	if _, err := Load("example.com"); err == nil {
+		t.Error("Expected no certificate after failure")
+	}
+}
+
+func TestRenewAndRevoke(t *testing.T) {
+	srv := setupACME(t)
+	m := NewManager(NewStore(t.TempDir()))
+
+	if _, err := m.Renew("example.com", false); err == nil {
+		t.Error("Expected renewing a missing certificate to fail")
+	}
+	first, err := m.Issue(IssueRequest{Domain: "example.com", Domains: []string{"www.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notDue *NotDueError
+	if _, err := m.Renew("example.com", false); !errors.As(err, &notDue) || notDue.Days < 80 {
+		t.Errorf("Expected NotDueError, got %v", err)
+	}
+	renewed, err := m.Renew("example.com", true)
+	if err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+	if strings.Join(renewed.Names, ",") != "example.com,www.example.com" || !renewed.NotBefore.After(first.NotBefore.Add(-time.Second)) {
+		t.Errorf("Expected renewal with previous names, got %+v", renewed)
+	}
+
+	// certbot 签发的证书：按证书中的域名重新申请并写入 CertDir
+	if _, err := m.Issue(IssueRequest{Domain: "other.com", Domains: []string{"www.other.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(LiveDir, 0755)
+	os.Rename(filepath.Join(CertDir, "other.com"), filepath.Join(LiveDir, "other.com"))
+	os.Remove(filepath.Join(LiveDir, "other.com", metaFile))
+	cert, err := m.Renew("other.com", true)
+	if err != nil {
+		t.Fatalf("Renew legacy certificate failed: %v", err)
+	}
+	if cert.CertPath != CertPath("other.com") || strings.Join(cert.Names, ",") != "other.com,www.other.com" {
+		t.Errorf("Unexpected renewed certificate: %+v", cert)
+	}
+
+	der, _, err := readPair(CertPath("example.com"), KeyPath("example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Revoke("example.com"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if !srv.Revoked(der) {
+		t.Error("Expected certificate revoked at the CA")
+	}
+	if err := m.Revoke("example.com"); err != nil {
+		t.Errorf("Expected already revoked certificate to be accepted, got %v", err)
+	}
+	if err := m.RemoveFiles("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("example.com"); err == nil {
+		t.Error("Expected certificate to be removed")
+	}
+	if _, err := Load("other.com"); err != nil {
+		t.Errorf("Expected other certificate to be kept: %v", err)
+	}
+}
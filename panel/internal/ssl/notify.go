@@ -0,0 +1,160 @@
+package ssl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	notifyFile   = "ssl_notify.json"
+	notifiedFile = "ssl_notified.json" // 已发送的提醒：证书序列号 -> 最近一次提醒的天数
+)
+
+// NotifyDays 到期前发送提醒的剩余天数
+var NotifyDays = []int{30, 14, 7, 1}
+
+// Notice 证书到期提醒
+type Notice struct {
+	Name      string    `json:"name"`
+	Names     []string  `json:"names"`
+	Sites     []string  `json:"sites"`
+	CertPath  string    `json:"cert_path"`
+	NotAfter  time.Time `json:"not_after"`
+	DaysLeft  int       `json:"days_left"`
+	Threshold int       `json:"threshold"`
+	Message   string    `json:"message"`
+}
+
+// Notifier 发送到期提醒
+type Notifier interface {
+	Notify(n Notice) error
+}
+
+// NotifySettings 提醒设置
+type NotifySettings struct {
+	Webhook string `json:"webhook"` // 收到 POST JSON {"text": ..., "notice": {...}}，为空时只写日志
+}
+
+// NotifySettings 读取提醒设置
+func (s *Store) NotifySettings() (NotifySettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var settings NotifySettings
+	err := s.readJSON(notifyFile, &settings)
+	return settings, err
+}
+
+// SetNotifySettings 保存提醒设置
+func (s *Store) SetNotifySettings(settings NotifySettings) error {
+	settings.Webhook = strings.TrimSpace(settings.Webhook)
+	if settings.Webhook != "" {
+		u, err := url.Parse(settings.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("无效的 Webhook 地址")
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeJSON(notifyFile, settings)
+}
+
+// WebhookNotifier 将提醒写入日志，并发送到设置中的 Webhook
+type WebhookNotifier struct {
+	store  *Store
+	client *http.Client
+}
+
+// Notify 发送提醒
+func (w *WebhookNotifier) Notify(n Notice) error {
+	log.Printf("[ssl] %s", n.Message)
+	settings, err := w.store.NotifySettings()
+	if err != nil || settings.Webhook == "" {
+		return err
+	}
+	body, _ := json.Marshal(map[string]interface{}{"text": n.Message, "notice": n})
+	client := w.client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(settings.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook 返回 HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// dueThreshold 剩余天数对应的提醒档位，即不小于 days 的最小 NotifyDays；未到任何档位返回 0
+func dueThreshold(days int) int {
+	thresholds := append([]int{}, NotifyDays...)
+	sort.Ints(thresholds)
+	for _, t := range thresholds {
+		if days <= t {
+			return t
+		}
+	}
+	return 0
+}
+
+// notify 对进入新档位的证书发送一次提醒；按序列号记录，续期后的新证书重新计算
+func (inv *Inventory) notify(certs []CertInfo) {
+	inv.store.mu.Lock()
+	sent := map[string]int{}
+	err := inv.store.readJSON(notifiedFile, &sent)
+	inv.store.mu.Unlock()
+	if err != nil {
+		log.Printf("[ssl] 读取提醒记录失败: %v", err)
+		return
+	}
+
+	current := map[string]bool{}
+	changed := false
+	for _, c := range certs {
+		if c.Serial == "" {
+			continue
+		}
+		current[c.Serial] = true
+		threshold := dueThreshold(c.DaysLeft)
+		if last, ok := sent[c.Serial]; threshold == 0 || (ok && last <= threshold) {
+			continue
+		}
+
+		n := Notice{Name: c.Name, Names: c.Names, Sites: c.Sites, CertPath: c.CertPath, NotAfter: c.NotAfter, DaysLeft: c.DaysLeft, Threshold: threshold}
+		if c.DaysLeft < 0 {
+			n.Message = fmt.Sprintf("证书 %s 已于 %s 过期", c.Name, c.NotAfter.Format("2006-01-02"))
+		} else {
+			n.Message = fmt.Sprintf("证书 %s 将于 %s 到期，剩余 %d 天", c.Name, c.NotAfter.Format("2006-01-02"), c.DaysLeft)
+		}
+		if err := inv.notifier.Notify(n); err != nil {
+			log.Printf("[ssl] 发送 %s 到期提醒失败: %v", c.Name, err)
+			continue
+		}
+		sent[c.Serial] = threshold
+		changed = true
+	}
+	for serial := range sent {
+		if !current[serial] {
+			delete(sent, serial)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	inv.store.mu.Lock()
+	defer inv.store.mu.Unlock()
+	if err := inv.store.writeJSON(notifiedFile, sent); err != nil {
+		log.Printf("[ssl] 保存提醒记录失败: %v", err)
+	}
+}
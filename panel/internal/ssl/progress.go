@@ -0,0 +1,112 @@
+package ssl
+
+import (
+	"errors"
+	"time"
+
+	"site_manager_panel/internal/acme"
+)
+
+// 申请进度阶段
+const (
+	StageAccount  = "account"  // 注册或加载 ACME 账号
+	StageOrder    = "order"    // 创建订单
+	StageValidate = "validate" // 放置验证文件或 TXT 记录并等待验证
+	StageFinalize = "finalize" // 提交 CSR 并等待签发
+	StageDone     = "done"
+	StageFailed   = "failed"
+)
+
+// AuthzProgress 单个域名的验证状态
+type AuthzProgress struct {
+	Domain    string        `json:"domain"`
+	Status    string        `json:"status"`
+	Challenge string        `json:"challenge"`
+	Error     *acme.Problem `json:"error,omitempty"`
+}
+
+// Progress 证书申请进度，失败时 Error 为 ACME 服务端返回的错误，Message 为其他错误
+type Progress struct {
+	Domain         string          `json:"domain"`
+	Names          []string        `json:"names"`
+	Challenge      string          `json:"challenge"`
+	Stage          string          `json:"stage"`
+	OrderStatus    string          `json:"order_status,omitempty"`
+	Authorizations []AuthzProgress `json:"authorizations"`
+	Error          *acme.Problem   `json:"error,omitempty"`
+	Message        string          `json:"message,omitempty"`
+	StartedAt      time.Time       `json:"started_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Running 申请是否仍在进行
+func (p *Progress) Running() bool {
+	return p.Stage != StageDone && p.Stage != StageFailed
+}
+
+func (p *Progress) copy() *Progress {
+	cp := *p
+	cp.Names = append([]string{}, p.Names...)
+	cp.Authorizations = append([]AuthzProgress{}, p.Authorizations...)
+	return &cp
+}
+
+// begin 开始跟踪域名的申请；同一证书已在申请中时返回错误
+func (m *Manager) begin(domain string, names []string, challenge string) (*Progress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p := m.progress[domain]; p != nil && p.Running() {
+		return nil, errors.New("证书正在申请中: " + domain)
+	}
+	now := time.Now()
+	p := &Progress{Domain: domain, Names: names, Challenge: challenge, Stage: StageAccount, StartedAt: now, UpdatedAt: now}
+	m.progress[domain] = p
+	return p, nil
+}
+
+// update 在锁内修改进度
+func (m *Manager) update(p *Progress, fn func(p *Progress)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(p)
+	p.UpdatedAt = time.Now()
+}
+
+// finish 记录申请结果
+func (m *Manager) finish(p *Progress, err error) {
+	m.update(p, func(p *Progress) {
+		if err == nil {
+			p.Stage = StageDone
+			return
+		}
+		p.Stage = StageFailed
+		var problem *acme.Problem
+		if errors.As(err, &problem) {
+			p.Error = problem
+		}
+		p.Message = err.Error()
+	})
+}
+
+// setAuthz 更新单个域名的验证状态
+func (m *Manager) setAuthz(p *Progress, a AuthzProgress) {
+	m.update(p, func(p *Progress) {
+		for i := range p.Authorizations {
+			if p.Authorizations[i].Domain == a.Domain {
+				p.Authorizations[i] = a
+				return
+			}
+		}
+		p.Authorizations = append(p.Authorizations, a)
+	})
+}
+
+// Progress 域名最近一次申请或续期的进度
+func (m *Manager) Progress(domain string) *Progress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p := m.progress[domain]; p != nil {
+		return p.copy()
+	}
+	return nil
+}
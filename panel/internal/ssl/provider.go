@@ -0,0 +1,37 @@
+package ssl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DNSProvider 用于 DNS-01 验证的 DNS 服务商
+// fqdn 为完整的验证记录名（如 _acme-challenge.example.com），value 为 TXT 记录值
+type DNSProvider interface {
+	Present(fqdn, value string) error
+	CleanUp(fqdn, value string) error
+}
+
+// providers 已支持的 DNS 服务商，键与 dns_accounts.json 中的分组名一致
+var providers = map[string]func(Account) DNSProvider{
+	"cloudflare": func(a Account) DNSProvider { return NewCloudflare(a.Email, a.APIKey) },
+}
+
+// RegisterProvider 注册 DNS 服务商，测试中可注册假的实现
+func RegisterProvider(name string, factory func(Account) DNSProvider) {
+	providers[name] = factory
+}
+
+// NewProvider 根据账号创建 DNS 服务商
+func NewProvider(a Account) (DNSProvider, error) {
+	factory, ok := providers[a.Provider]
+	if !ok {
+		return nil, fmt.Errorf("不支持的 DNS 服务商: %s", a.Provider)
+	}
+	return factory(a), nil
+}
+
+// ChallengeRecord 域名对应的 DNS-01 验证记录名，通配符与主域名共用同一条记录
+func ChallengeRecord(domain string) string {
+	return "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+}
@@ -0,0 +1,388 @@
+package ssl
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"site_manager_panel/internal/acme"
+)
+
+// DefaultConfigDir 与 lib/ssl.sh 共用的配置目录
+const DefaultConfigDir = "/opt/site_manager/config"
+
+const (
+	accountsFile   = "dns_accounts.json"
+	domainsFile    = "ssl_domains.json"
+	accountKeyFile = "acme_account.key"
+)
+
+// DefaultAlias lib/ssl.sh 的 ssl_dns_config 写入的默认账号（cloudflare.ini），未绑定的域名使用它
+const DefaultAlias = "default"
+
+var aliasRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// Account DNS API 账号
+type Account struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider,omitempty"` // 文件中按 provider 分组，不单独存储
+	Alias    string `json:"alias"`
+	Email    string `json:"email"`
+	APIKey   string `json:"api_key"`
+}
+
+// Redacted 返回去掉密钥的副本，用于接口输出
+func (a Account) Redacted() Account {
+	if a.APIKey != "" {
+		a.APIKey = "******"
+	}
+	return a
+}
+
+// Binding 根域名与 DNS 账号的绑定，格式与 ssl_domains.json 一致
+type Binding struct {
+	Domain    string `json:"domain,omitempty"` // 文件中域名为键
+	AccountID string `json:"account_id"`
+	Alias     string `json:"alias"`
+}
+
+// Store 读写 dns_accounts.json / ssl_domains.json，CLI 与面板共用
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore 创建存储，dir 通常为 DefaultConfigDir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir 配置目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+func (s *Store) readJSON(name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+func (s *Store) writeJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	// 含 API Key，仅 root 可读
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *Store) loadAccounts() (map[string][]Account, error) {
+	grouped := map[string][]Account{}
+	if err := s.readJSON(accountsFile, &grouped); err != nil {
+		return nil, err
+	}
+	return grouped, nil
+}
+
+// Accounts 列出所有账号，按 provider、别名排序
+func (s *Store) Accounts() ([]Account, error) {
+	grouped, err := s.loadAccounts()
+	if err != nil {
+		return nil, err
+	}
+	providers := make([]string, 0, len(grouped))
+	for p := range grouped {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+
+	accounts := []Account{}
+	for _, p := range providers {
+		for _, a := range grouped[p] {
+			a.Provider = p
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts, nil
+}
+
+// Account 按别名获取账号；default 对应 CLI 的 cloudflare.ini
+func (s *Store) Account(alias string) (*Account, error) {
+	accounts, err := s.Accounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if a.Alias == alias {
+			return &a, nil
+		}
+	}
+	if alias == DefaultAlias {
+		if a := s.defaultAccount(); a != nil {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("账号不存在: %s", alias)
+}
+
+// AddAccount 新增账号并生成 certbot 凭据文件
+func (s *Store) AddAccount(a Account) (*Account, error) {
+	if !aliasRegex.MatchString(a.Alias) || a.Alias == DefaultAlias {
+		return nil, fmt.Errorf("无效的账号别名")
+	}
+	if _, ok := providers[a.Provider]; !ok {
+		return nil, fmt.Errorf("不支持的 DNS 服务商: %s", a.Provider)
+	}
+	if a.Email == "" || a.APIKey == "" {
+		return nil, fmt.Errorf("Email 和 API Key 不能为空")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grouped, err := s.loadAccounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range grouped {
+		for _, existing := range list {
+			if existing.Alias == a.Alias {
+				return nil, fmt.Errorf("账号别名已存在: %s", a.Alias)
+			}
+		}
+	}
+
+	a.ID = newID()
+	stored := a
+	stored.Provider = ""
+	grouped[a.Provider] = append(grouped[a.Provider], stored)
+	if err := s.writeJSON(accountsFile, grouped); err != nil {
+		return nil, err
+	}
+	if err := s.writeCredentials(a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RemoveAccount 删除账号及凭据文件；仍有域名绑定时拒绝删除
+func (s *Store) RemoveAccount(alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings, err := s.loadBindings()
+	if err != nil {
+		return err
+	}
+	for domain, b := range bindings {
+		if b.Alias == alias {
+			return fmt.Errorf("账号仍绑定域名 %s，请先解绑", domain)
+		}
+	}
+
+	grouped, err := s.loadAccounts()
+	if err != nil {
+		return err
+	}
+	for p, list := range grouped {
+		for i, a := range list {
+			if a.Alias != alias {
+				continue
+			}
+			grouped[p] = append(list[:i], list[i+1:]...)
+			if err := s.writeJSON(accountsFile, grouped); err != nil {
+				return err
+			}
+			os.Remove(s.credentialsPath(p, alias))
+			return nil
+		}
+	}
+	return fmt.Errorf("账号不存在: %s", alias)
+}
+
+func (s *Store) credentialsPath(provider, alias string) string {
+	if alias == DefaultAlias {
+		return filepath.Join(s.dir, provider+".ini")
+	}
+	return filepath.Join(s.dir, provider+"_"+alias+".ini")
+}
+
+// writeCredentials 生成与 ssl_account_add 相同的凭据文件
+func (s *Store) writeCredentials(a Account) error {
+	content := fmt.Sprintf("dns_%s_email = %s\ndns_%s_api_key = %s\n", a.Provider, a.Email, a.Provider, a.APIKey)
+	return os.WriteFile(s.credentialsPath(a.Provider, a.Alias), []byte(content), 0600)
+}
+
+// defaultAccount 读取 cloudflare.ini，兼容旧版单账号配置
+func (s *Store) defaultAccount() *Account {
+	data, err := os.ReadFile(s.credentialsPath("cloudflare", DefaultAlias))
+	if err != nil {
+		return nil
+	}
+	a := &Account{ID: DefaultAlias, Provider: "cloudflare", Alias: DefaultAlias}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "dns_cloudflare_email":
+			a.Email = strings.TrimSpace(value)
+		case "dns_cloudflare_api_key":
+			a.APIKey = strings.TrimSpace(value)
+		}
+	}
+	if a.APIKey == "" {
+		return nil
+	}
+	return a
+}
+
+func (s *Store) loadBindings() (map[string]Binding, error) {
+	bindings := map[string]Binding{}
+	if err := s.readJSON(domainsFile, &bindings); err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// Bindings 列出所有绑定，按域名排序
+func (s *Store) Bindings() ([]Binding, error) {
+	bindings, err := s.loadBindings()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Binding, 0, len(bindings))
+	for domain, b := range bindings {
+		b.Domain = domain
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	return list, nil
+}
+
+// Bind 将根域名绑定到账号
+func (s *Store) Bind(domain, alias string) error {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "*."))
+	if domain == "" {
+		return fmt.Errorf("域名不能为空")
+	}
+	account, err := s.Account(alias)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings, err := s.loadBindings()
+	if err != nil {
+		return err
+	}
+	bindings[domain] = Binding{AccountID: account.ID, Alias: account.Alias}
+	return s.writeJSON(domainsFile, bindings)
+}
+
+// Unbind 解除绑定
+func (s *Store) Unbind(domain string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bindings, err := s.loadBindings()
+	if err != nil {
+		return err
+	}
+	if _, ok := bindings[domain]; !ok {
+		return fmt.Errorf("域名未绑定: %s", domain)
+	}
+	delete(bindings, domain)
+	return s.writeJSON(domainsFile, bindings)
+}
+
+// AccountFor 查找域名使用的账号：先按完整域名逐级向上匹配绑定，再回退到默认账号
+func (s *Store) AccountFor(domain string) (*Account, error) {
+	bindings, err := s.loadBindings()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(strings.TrimPrefix(domain, "*."))
+	for {
+		if b, ok := bindings[name]; ok {
+			return s.Account(b.Alias)
+		}
+		_, parent, ok := strings.Cut(name, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			break
+		}
+		name = parent
+	}
+	if a := s.defaultAccount(); a != nil {
+		return a, nil
+	}
+	return nil, fmt.Errorf("域名 %s 未绑定 DNS 账号，且无默认配置", domain)
+}
+
+// AccountKey ACME 账号私钥，首次使用时生成；同一私钥在 CA 侧对应同一个账号
+func (s *Store) AccountKey() (crypto.Signer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, accountKeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return acme.ParseKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := acme.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = acme.MarshalKey(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,111 @@
+package ssl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccounts(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	a, err := s.AddAccount(Account{Provider: "cloudflare", Alias: "main", Email: "ops@example.com", APIKey: "secret"})
+	if err != nil {
+		t.Fatalf("AddAccount failed: %v", err)
+	}
+	if a.ID == "" || a.Provider != "cloudflare" {
+		t.Errorf("Unexpected account: %+v", a)
+	}
+	if _, err := s.AddAccount(Account{Provider: "cloudflare", Alias: "main", Email: "x@example.com", APIKey: "k"}); err == nil {
+		t.Error("Expected duplicate alias to be rejected")
+	}
+	if _, err := s.AddAccount(Account{Provider: "route53", Alias: "aws", Email: "x@example.com", APIKey: "k"}); err == nil {
+		t.Error("Expected unknown provider to be rejected")
+	}
+	if _, err := s.AddAccount(Account{Provider: "cloudflare", Alias: "bad alias", Email: "x@example.com", APIKey: "k"}); err == nil {
+		t.Error("Expected invalid alias to be rejected")
+	}
+
+	// 文件格式与 lib/ssl.sh 一致：按服务商分组，不含 provider 字段
+	data, _ := os.ReadFile(filepath.Join(dir, "dns_accounts.json"))
+	var raw map[string][]map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Invalid accounts file: %v\n%s", err, data)
+	}
+	stored := raw["cloudflare"]
+	if len(stored) != 1 || stored[0]["alias"] != "main" || stored[0]["api_key"] != "secret" || stored[0]["provider"] != "" {
+		t.Errorf("Unexpected accounts file:\n%s", data)
+	}
+
+	cred := filepath.Join(dir, "cloudflare_main.ini")
+	content, _ := os.ReadFile(cred)
+	if string(content) != "dns_cloudflare_email = ops@example.com\ndns_cloudflare_api_key = secret\n" {
+		t.Errorf("Unexpected credentials file:\n%s", content)
+	}
+	if info, err := os.Stat(cred); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected credentials mode 0600, got %v", info)
+	}
+
+	if err := s.Bind("example.com", "main"); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if err := s.RemoveAccount("main"); err == nil || !strings.Contains(err.Error(), "example.com") {
+		t.Errorf("Expected bound account to be kept, got %v", err)
+	}
+	if err := s.Unbind("example.com"); err != nil {
+		t.Fatalf("Unbind failed: %v", err)
+	}
+	if err := s.RemoveAccount("main"); err != nil {
+		t.Fatalf("RemoveAccount failed: %v", err)
+	}
+	if _, err := os.Stat(cred); !os.IsNotExist(err) {
+		t.Error("Expected credentials file to be removed")
+	}
+	if accounts, _ := s.Accounts(); len(accounts) != 0 {
+		t.Errorf("Expected no accounts, got %+v", accounts)
+	}
+}
+
+func TestAccountFor(t *testing.T) {
+	dir := t.TempDir()
+	// CLI 写入的文件
+	os.WriteFile(filepath.Join(dir, "dns_accounts.json"), []byte(`{"cloudflare":[
+		{"id":"a1","alias":"main","email":"main@example.com","api_key":"k1"},
+		{"id":"a2","alias":"other","email":"other@example.net","api_key":"k2"}]}`), 0600)
+	os.WriteFile(filepath.Join(dir, "ssl_domains.json"), []byte(`{"example.com":{"account_id":"a1","alias":"main"}}`), 0600)
+	s := NewStore(dir)
+
+	if err := s.Bind("*.shop.example.net", "other"); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	bindings, _ := s.Bindings()
+	if len(bindings) != 2 || bindings[0].Domain != "example.com" || bindings[1].Domain != "shop.example.net" || bindings[1].AccountID != "a2" {
+		t.Errorf("Unexpected bindings: %+v", bindings)
+	}
+
+	tests := map[string]string{
+		"example.com":          "main",
+		"*.example.com":        "main",
+		"www.api.example.com":  "main",
+		"*.shop.example.net":   "other",
+		"cdn.shop.example.net": "other",
+	}
+	for domain, alias := range tests {
+		a, err := s.AccountFor(domain)
+		if err != nil || a.Alias != alias {
+			t.Errorf("AccountFor(%s) = %+v, %v; want %s", domain, a, err, alias)
+		}
+	}
+
+	if _, err := s.AccountFor("example.org"); err == nil {
+		t.Error("Expected unbound domain to fail without default credentials")
+	}
+	os.WriteFile(filepath.Join(dir, "cloudflare.ini"), []byte("dns_cloudflare_email = legacy@example.org\ndns_cloudflare_api_key = k3\n"), 0600)
+	a, err := s.AccountFor("example.org")
+	if err != nil || a.Alias != DefaultAlias || a.Email != "legacy@example.org" || a.APIKey != "k3" {
+		t.Errorf("Expected default account, got %+v, %v", a, err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+
+	"site_manager_panel/internal/php"
 )
 
 type SystemStatus struct {
@@ -126,11 +128,11 @@ type ServiceStatus struct {
 }
 
 func GetServices(c *fiber.Ctx) error {
-	services := []ServiceStatus{
-		checkService("nginx"),
-		checkService("php8.3-fpm"),
-		checkService("supervisor"),
+	services := []ServiceStatus{checkService("nginx")}
+	for _, v := range php.Versions() {
+		services = append(services, checkService(v.Service))
 	}
+	services = append(services, checkService("supervisor"))
 
 	return c.JSON(fiber.Map{
 		"status": true,
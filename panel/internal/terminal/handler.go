@@ -2,18 +2,24 @@ package terminal
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"os/user"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/creack/pty"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"github.com/golang-jwt/jwt/v5"
+
+	"site_manager_panel/internal/audit"
+	"site_manager_panel/internal/docker"
+	"site_manager_panel/internal/models"
 )
 
 var jwtSecret []byte
@@ -42,13 +48,39 @@ type TerminalSession struct {
 // TerminalHandler 终端处理器
 type TerminalHandler struct {
 	sessions sync.Map
+	docker   *docker.Client
+}
+
+// NewTerminalHandler 创建处理器，dockerClient 用于连接容器终端，为 nil 时不支持
+func NewTerminalHandler(dockerClient *docker.Client) *TerminalHandler {
+	return &TerminalHandler{docker: dockerClient}
+}
+
+// session 终端会话的输入输出：本机 shell 的 PTY 或容器内的 exec
+type session interface {
+	io.ReadWriteCloser
+	Resize(cols, rows uint16) error
+}
+
+// ptySession 本机 shell，关闭时结束进程
+type ptySession struct {
+	*os.File
+	cmd *exec.Cmd
+}
+
+func (p *ptySession) Resize(cols, rows uint16) error {
+	setWinsize(p.File, cols, rows)
+	return nil
 }
 
-// NewTerminalHandler 创建处理器
-func NewTerminalHandler() *TerminalHandler {
-	return &TerminalHandler{}
+func (p *ptySession) Close() error {
+	p.cmd.Process.Kill()
+	return p.File.Close()
 }
 
+// containerShell 容器内优先使用 bash，没有时使用 sh
+var containerShell = []string{"/bin/sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"}
+
 // validateToken 验证 JWT token
 func validateToken(tokenString string) (int64, string, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -64,10 +96,16 @@ func validateToken(tokenString string) (int64, string, error) {
 		return 0, "", fiber.ErrUnauthorized
 	}
 
-	userID := int64(claims["user_id"].(float64))
-	username := claims["username"].(string)
+	userID, _ := claims["user_id"].(float64)
+	username, _ := claims["username"].(string)
+	jti, _ := claims["jti"].(string)
+
+	// 拒绝已退出或被吊销的令牌
+	if active, err := models.IsSessionActive(int64(userID), jti); err != nil || !active {
+		return 0, "", fiber.ErrUnauthorized
+	}
 
-	return userID, username, nil
+	return int64(userID), username, nil
 }
 
 // getDefaultDir 获取默认工作目录
@@ -103,6 +141,24 @@ func (h *TerminalHandler) RegisterRoutes(app *fiber.App) {
 				})
 			}
 
+			// 终端等同 root shell，需要 terminal:use 权限
+			user, err := models.GetUserByID(userID)
+			if err != nil || user == nil || !user.HasPermission("terminal:use") {
+				return c.Status(403).JSON(fiber.Map{
+					"status":  false,
+					"message": "Permission denied: terminal:use",
+				})
+			}
+
+			// 连接容器终端（可选），等同在容器内执行任意命令，同时需要 docker:write 权限
+			container := c.Query("container", "")
+			if container != "" && !user.HasPermission("docker:write") {
+				return c.Status(403).JSON(fiber.Map{
+					"status":  false,
+					"message": "Permission denied: docker:write",
+				})
+			}
+
 			// 获取 cwd 参数（可选）
 			cwd := c.Query("cwd", "")
 
@@ -110,6 +166,8 @@ func (h *TerminalHandler) RegisterRoutes(app *fiber.App) {
 			c.Locals("user_id", userID)
 			c.Locals("username", username)
 			c.Locals("cwd", cwd)
+			c.Locals("container", container)
+			c.Locals("ip", c.IP())
 			c.Locals("allowed", true)
 
 			return c.Next()
@@ -131,45 +189,98 @@ func (h *TerminalHandler) HandleWebSocket(c *websocket.Conn) {
 		return
 	}
 
-	// 获取工作目录
-	cwd, _ := c.Locals("cwd").(string)
-	if cwd == "" {
-		cwd = getDefaultDir()
-	}
-
-	// 验证目录是否存在
-	if info, err := os.Stat(cwd); err != nil || !info.IsDir() {
-		cwd = getDefaultDir()
-	}
-
-	// 创建 PTY
-	cmd := exec.Command("/bin/bash")
-	cmd.Dir = cwd
-	cmd.Env = append(os.Environ(),
-		"TERM=xterm-256color",
-		"LANG=en_US.UTF-8",
-		"LC_ALL=en_US.UTF-8",
-		"HOME="+getDefaultDir(),
+	var (
+		sess   session
+		target string
+		ref    string
 	)
+	if container, _ := c.Locals("container").(string); container != "" {
+		if h.docker == nil {
+			c.WriteMessage(websocket.TextMessage, []byte("Error: Docker 管理未启用"))
+			return
+		}
+		ex, err := h.docker.Exec(container, docker.ExecConfig{
+			Cmd: containerShell,
+			Env: []string{"TERM=xterm-256color", "LANG=C.UTF-8"},
+			Tty: true,
+		})
+		if err != nil {
+			c.WriteMessage(websocket.TextMessage, []byte("Error: "+err.Error()))
+			return
+		}
+		sess, target, ref = ex, "container:"+container, fmt.Sprintf(`"exec":%q`, ex.ID)
+	} else {
+		// 获取工作目录
+		cwd, _ := c.Locals("cwd").(string)
+		if cwd == "" {
+			cwd = getDefaultDir()
+		}
 
-	ptmx, err := pty.Start(cmd)
-	if err != nil {
-		c.WriteMessage(websocket.TextMessage, []byte("Error: "+err.Error()))
-		return
+		// 验证目录是否存在
+		if info, err := os.Stat(cwd); err != nil || !info.IsDir() {
+			cwd = getDefaultDir()
+		}
+
+		// 创建 PTY
+		cmd := exec.Command("/bin/bash")
+		cmd.Dir = cwd
+		cmd.Env = append(os.Environ(),
+			"TERM=xterm-256color",
+			"LANG=en_US.UTF-8",
+			"LC_ALL=en_US.UTF-8",
+			"HOME="+getDefaultDir(),
+		)
+
+		ptmx, err := pty.Start(cmd)
+		if err != nil {
+			c.WriteMessage(websocket.TextMessage, []byte("Error: "+err.Error()))
+			return
+		}
+		sess, target, ref = &ptySession{File: ptmx, cmd: cmd}, cwd, fmt.Sprintf(`"pid":%d`, cmd.Process.Pid)
+	}
+	defer sess.Close()
+
+	// 审计：记录终端会话的打开与关闭
+	userID, _ := c.Locals("user_id").(int64)
+	username, _ := c.Locals("username").(string)
+	ip, _ := c.Locals("ip").(string)
+	openedAt := time.Now()
+	recordSession := func(summary string) {
+		audit.Record(&models.AuditLog{
+			UserID:   userID,
+			Username: username,
+			Method:   "WS",
+			Route:    "/ws/terminal",
+			Path:     "/ws/terminal",
+			Target:   target,
+			Summary:  summary,
+			Status:   fiber.StatusSwitchingProtocols,
+			IP:       ip,
+		})
 	}
+	recordSession(fmt.Sprintf(`{"event":"open",%s}`, ref))
 	defer func() {
-		cmd.Process.Kill()
-		ptmx.Close()
+		recordSession(fmt.Sprintf(`{"event":"close",%s,"duration":%q}`, ref, time.Since(openedAt).Round(time.Second).String()))
 	}()
 
 	// 设置初始窗口大小
-	setWinsize(ptmx, 80, 24)
+	sess.Resize(80, 24)
 
-	// 读取 PTY 输出并发送到 WebSocket
+	serve(c, sess)
+}
+
+// serve 在 WebSocket 和会话之间转发数据，处理窗口大小调整和心跳，直到任一端断开
+func serve(c *websocket.Conn, sess session) {
+	// 读取会话输出并发送到 WebSocket
 	go func() {
 		buf := make([]byte, 4096)
 		for {
-			n, err := ptmx.Read(buf)
+			n, err := sess.Read(buf)
+			if n > 0 {
+				if err := c.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+					return
+				}
+			}
 			if err != nil {
 				if err != io.EOF {
 					c.WriteMessage(websocket.TextMessage, []byte("\r\n[连接已断开]\r\n"))
@@ -177,15 +288,10 @@ func (h *TerminalHandler) HandleWebSocket(c *websocket.Conn) {
 				c.Close()
 				return
 			}
-			if n > 0 {
-				if err := c.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
-					return
-				}
-			}
 		}
 	}()
 
-	// 从 WebSocket 读取并写入 PTY
+	// 从 WebSocket 读取并写入会话
 	for {
 		msgType, msg, err := c.ReadMessage()
 		if err != nil {
@@ -204,15 +310,15 @@ func (h *TerminalHandler) HandleWebSocket(c *websocket.Conn) {
 				if len(msg) >= 5 {
 					cols := uint16(msg[1])<<8 | uint16(msg[2])
 					rows := uint16(msg[3])<<8 | uint16(msg[4])
-					setWinsize(ptmx, cols, rows)
+					sess.Resize(cols, rows)
 				}
 			case OpHeartbeat:
 				// 心跳请求，回复心跳响应
 				pong := []byte{OpPong}
 				c.WriteMessage(websocket.BinaryMessage, pong)
 			default:
-				// 普通输入，写入 PTY
-				ptmx.Write(msg)
+				// 普通输入，写入会话
+				sess.Write(msg)
 			}
 		}
 	}
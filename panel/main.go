@@ -5,20 +5,29 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 
 	"site_manager_panel/config"
+	"site_manager_panel/internal/audit"
 	"site_manager_panel/internal/auth"
+	"site_manager_panel/internal/backup"
 	"site_manager_panel/internal/cron"
+	"site_manager_panel/internal/database"
+	"site_manager_panel/internal/docker"
 	"site_manager_panel/internal/files"
 	"site_manager_panel/internal/firewall"
 	"site_manager_panel/internal/logs"
 	"site_manager_panel/internal/models"
+	"site_manager_panel/internal/nginx"
+	"site_manager_panel/internal/process"
 	"site_manager_panel/internal/site"
 	"site_manager_panel/internal/software"
+	"site_manager_panel/internal/ssl"
 	"site_manager_panel/internal/system"
 	"site_manager_panel/internal/terminal"
 )
@@ -30,11 +39,20 @@ func main() {
 	cfg := config.Load()
 	auth.SetJWTSecret(cfg.JWTSecret)
 	terminal.SetJWTSecret(cfg.JWTSecret)
+	auth.SetLoginBanThreshold(cfg.LoginBanThreshold)
+	nginx.SetTemplateDir(filepath.Join(cfg.DataDir, "nginx_templates"))
 
 	if err := models.InitDB(cfg.DataDir); err != nil {
 		log.Fatalf("Failed to init database: %v", err)
 	}
 
+	// 登记 CLI 创建的站点
+	if n, err := site.ImportSites(); err != nil {
+		log.Printf("[WARN] Failed to import sites: %v", err)
+	} else if n > 0 {
+		log.Printf("Imported %d existing sites", n)
+	}
+
 	app := fiber.New(fiber.Config{
 		AppName:      "Site Manager Panel",
 		BodyLimit:    100 * 1024 * 1024,
@@ -54,32 +72,121 @@ func main() {
 		AllowCredentials: allowedOrigins != "*",
 	}))
 
-	api := app.Group("/api")
+	// 审计中间件需在所有 /api 路由之前注册
+	api := app.Group("/api", audit.Middleware())
 	authRoutes := api.Group("/auth")
 	authRoutes.Post("/login", auth.Login)
+	authRoutes.Post("/login/2fa", auth.LoginTwoFactor)
 
-	protected := api.Group("", auth.JWTMiddleware())
+	protected := api.Group("", auth.JWTMiddleware(), auth.RBACMiddleware())
 	protected.Get("/auth/me", auth.Me)
 	protected.Post("/auth/logout", auth.Logout)
 	protected.Post("/auth/password", auth.ChangePassword)
+	protected.Get("/auth/sessions", auth.ListSessions)
+	protected.Delete("/auth/sessions", auth.RevokeAllSessions)
+	protected.Delete("/auth/sessions/:id", auth.RevokeSession)
+	protected.Get("/auth/2fa", auth.TwoFactorStatus)
+	protected.Post("/auth/2fa/setup", auth.SetupTwoFactor)
+	protected.Post("/auth/2fa/enable", auth.EnableTwoFactor)
+	protected.Post("/auth/2fa/disable", auth.DisableTwoFactor)
+	protected.Post("/auth/2fa/recovery-codes", auth.RegenerateRecoveryCodes)
+
+	protected.Get("/users", auth.ListUsers)
+	protected.Get("/users/roles", auth.ListRoles)
+	protected.Post("/users", auth.CreateUser)
+	protected.Put("/users/:id", auth.UpdateUser)
+	protected.Delete("/users/:id", auth.DeleteUser)
+	protected.Delete("/users/:id/2fa", auth.ResetUserTwoFactor)
+
+	// security 不在可授权模块中，仅 admin 可访问
+	protected.Get("/security/lockouts", auth.ListLockouts)
+	protected.Delete("/security/lockouts", auth.ClearAllLockouts)
+	protected.Delete("/security/lockouts/:kind/:key", auth.ClearLockout)
+
+	auditHandler := audit.NewAuditHandler()
+	auditHandler.RegisterRoutes(protected)
 
 	protected.Get("/system/status", system.GetStatus)
 	protected.Get("/system/services", system.GetServices)
 
+	protected.Get("/sites/templates", site.ListTemplates)
+	protected.Get("/sites/templates/:name", site.GetTemplate)
+	protected.Post("/sites/templates/:name/preview", site.PreviewTemplate)
 	protected.Get("/sites", site.List)
 	protected.Post("/sites", site.Create)
+	protected.Post("/sites/import", site.Import)
+	protected.Post("/sites/layout/migrate", site.MigrateLayout)
 	protected.Get("/sites/:domain", site.Info)
 	protected.Delete("/sites/:domain", site.Delete)
 	protected.Post("/sites/:domain/enable", site.Enable)
 	protected.Post("/sites/:domain/disable", site.Disable)
+	protected.Put("/sites/:domain/tags", site.UpdateTags)
 	protected.Post("/sites/:domain/backup", site.Backup)
 	protected.Get("/sites/:domain/nginx", site.GetNginxConfig)
 	protected.Put("/sites/:domain/nginx", site.SaveNginxConfig)
+	protected.Get("/sites/:domain/nginx/revisions", site.ListRevisions)
+	protected.Get("/sites/:domain/nginx/revisions/:id", site.GetRevision)
+	protected.Post("/sites/:domain/nginx/revisions/:id/rollback", site.RollbackRevision)
+	protected.Get("/sites/:domain/nginx/diff", site.DiffRevisions)
+	protected.Get("/sites/:domain/nginx/locations", site.ListLocations)
+	protected.Post("/sites/:domain/nginx/locations", site.AddLocation)
+	protected.Delete("/sites/:domain/nginx/locations", site.RemoveLocation)
+	protected.Put("/sites/:domain/nginx/client-max-body-size", site.SetClientMaxBodySize)
+	protected.Post("/sites/:domain/nginx/headers", site.AddHeader)
+	protected.Delete("/sites/:domain/nginx/headers/:name", site.RemoveHeader)
+	protected.Put("/sites/:domain/nginx/gzip", site.SetGzip)
+	protected.Post("/sites/:domain/nginx/redirects", site.AddRedirect)
+	protected.Post("/sites/:domain/render", site.Rerender)
 	protected.Get("/sites/:domain/logs", site.GetLogs)
 	protected.Post("/sites/:domain/ssl", site.RequestSSL)
 	protected.Post("/sites/:domain/ssl/renew", site.RenewSSL)
+	protected.Post("/sites/:domain/ssl/revoke", site.RevokeSSL)
+	protected.Get("/sites/:domain/ssl/progress", site.GetSSLProgress)
+	protected.Post("/sites/:domain/ssl/upload", site.UploadSSL)
+	protected.Post("/sites/:domain/ssl/export", site.ExportSSL)
+	protected.Post("/sites/:domain/ssl/download", site.DownloadSSL)
+	protected.Get("/sites/:domain/https", site.GetHTTPS)
+	protected.Put("/sites/:domain/https", site.SetHTTPS)
+	protected.Get("/sites/:domain/domains", site.GetDomains)
+	protected.Post("/sites/:domain/aliases", site.AddAlias)
+	protected.Delete("/sites/:domain/aliases/:alias", site.RemoveAlias)
+	protected.Post("/sites/:domain/redirect-domains", site.AddRedirectDomain)
+	protected.Delete("/sites/:domain/redirect-domains/:name", site.RemoveRedirectDomain)
+	protected.Put("/sites/:domain/canonical", site.SetCanonical)
+	protected.Get("/sites/:domain/php", site.GetPHP)
+	protected.Put("/sites/:domain/php", site.SetPHPVersion)
+	protected.Get("/sites/:domain/upstream", site.GetUpstream)
+	protected.Put("/sites/:domain/upstream/method", site.SetUpstreamMethod)
+	protected.Post("/sites/:domain/upstream/servers", site.AddUpstreamServer)
+	protected.Delete("/sites/:domain/upstream/servers/:address", site.RemoveUpstreamServer)
+	protected.Post("/sites/:domain/upstream/servers/:address/:action", site.UpstreamServerAction)
+	protected.Get("/sites/:domain/process", site.GetProcess)
+	protected.Put("/sites/:domain/process", site.SaveProcess)
+	protected.Put("/sites/:domain/process/env", site.SetProcessEnv)
+	protected.Get("/sites/:domain/process/logs", site.GetProcessLogs)
+	protected.Post("/sites/:domain/process/:action", site.ProcessAction)
+	protected.Get("/sites/:domain/docker", site.GetDocker)
+	protected.Put("/sites/:domain/docker/compose", site.SaveCompose)
+	protected.Get("/sites/:domain/docker/ps", site.DockerPS)
+	protected.Get("/sites/:domain/docker/logs", site.DockerLogs)
+	protected.Post("/sites/:domain/docker/:action", site.DockerAction)
+
+	site.SetProcessManager(process.NewManager(process.NewSupervisor(), process.NewPM2()))
+	dockerClient := docker.NewClient(docker.DefaultSocket)
+	site.SetDockerClient(dockerClient)
+	dockerHandler := docker.NewDockerHandler(dockerClient)
+	dockerHandler.RegisterRoutes(protected)
+
+	ssl.SetDirectoryURL(cfg.ACMEDirectory)
+	sslManager := ssl.NewManager(ssl.NewStore(ssl.DefaultConfigDir))
+	site.SetSSLManager(sslManager)
+	sslInventory := ssl.NewInventory(sslManager.Store(), nil)
+	go sslInventory.Run(6 * time.Hour)
+	sslHandler := ssl.NewSSLHandler(sslManager, sslInventory)
+	sslHandler.RegisterRoutes(protected)
 
 	protected.Get("/software", software.List)
+	protected.Get("/software/php", software.PHPVersions)
 	protected.Get("/software/:name/status", software.Status)
 	protected.Post("/software/:name/start", software.Start)
 	protected.Post("/software/:name/stop", software.Stop)
@@ -108,7 +215,7 @@ func main() {
 	filesGroup.Post("/chmod", fileHandler.Chmod)
 	filesGroup.Get("/search", fileHandler.Search)
 
-	termHandler := terminal.NewTerminalHandler()
+	termHandler := terminal.NewTerminalHandler(dockerClient)
 	protected.Post("/terminal/exec", termHandler.ExecuteCommand)
 	termHandler.RegisterRoutes(app)
 
@@ -118,6 +225,16 @@ func main() {
 	cronHandler := cron.NewCronHandler()
 	cronHandler.RegisterRoutes(protected)
 
+	mysqlClient := database.NewMySQLClient(cfg.MySQLPwdFile)
+	backupManager := backup.NewManager(backup.LoadConfig(backup.DefaultConfigFile, backup.DefaultExcludeFile), mysqlClient)
+	site.SetBackupManager(backupManager)
+
+	databaseHandler := database.NewDatabaseHandler(mysqlClient, backupManager.Config().BackupDir)
+	databaseHandler.RegisterRoutes(protected)
+
+	backupHandler := backup.NewBackupHandler(backupManager)
+	backupHandler.RegisterRoutes(protected)
+
 	app.Static("/", "./web/dist", fiber.Static{
 		Index:         "index.html",
 		CacheDuration: 0,
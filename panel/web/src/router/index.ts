@@ -45,6 +45,12 @@ const router = createRouter({
       component: () => import("../views/Terminal.vue"),
       meta: { requiresAuth: true }
     },
+    {
+      path: "/docker",
+      name: "docker",
+      component: () => import("../views/Docker.vue"),
+      meta: { requiresAuth: true }
+    },
     {
       path: "/firewall",
       name: "firewall",